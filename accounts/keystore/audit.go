@@ -0,0 +1,212 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package keystore
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/crypto"
+	"github.com/wanchain/go-wanchain/rlp"
+)
+
+// Kinds of the audited signing operations.
+const (
+	AuditSignHash  = "signHash"  // Hash or message signature
+	AuditSignTx    = "signTx"    // Transaction signature
+	AuditOTAKeys   = "otaKeys"   // OTA key derivation, used to ring sign privacy transactions
+	AuditRingShare = "ringShare" // Joint ring signature share
+	AuditOTASign   = "otaSign"   // Hash signature with the key of a received OTA
+	AuditDecrypt   = "decrypt"   // Decryption of a message sealed to the account
+	AuditViewKey   = "viewKey"   // Decryption of the view key, detecting the OTAs of the account
+)
+
+var errAuditChainBroken = errors.New("audit log hash chain broken")
+
+// AuditEntry is a signing operation recorded in the audit log. The hash of an
+// entry commits to its content and to the hash of the previous entry, so that
+// altering, removing or reordering entries breaks the chain.
+type AuditEntry struct {
+	Seq     uint64         `json:"seq"`
+	Time    time.Time      `json:"time"`
+	Kind    string         `json:"kind"`
+	Account common.Address `json:"account"`
+	Digest  common.Hash    `json:"digest"` // Signed hash, transaction hash or OTA hash
+	Error   string         `json:"error,omitempty"`
+	Prev    common.Hash    `json:"prev"`
+	Hash    common.Hash    `json:"hash"`
+}
+
+// chainHash computes the hash of the entry linking it to the previous one.
+func (e *AuditEntry) chainHash() common.Hash {
+	blob, _ := rlp.EncodeToBytes([]interface{}{
+		e.Seq, uint64(e.Time.UnixNano()), e.Kind, e.Account, e.Digest, e.Error, e.Prev,
+	})
+	return crypto.Keccak256Hash(blob)
+}
+
+// AuditLog is an append-only, hash-chained log of the signing operations, held
+// in a file of JSON entries, one per line.
+type AuditLog struct {
+	path string
+
+	lock sync.Mutex
+	seq  uint64      // Sequence number of the next entry
+	head common.Hash // Hash of the last entry
+}
+
+// OpenAuditLog opens the audit log at path, creating it if missing. The chain
+// of an existing log is verified before new entries are appended to it.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	log := &AuditLog{path: path}
+	result, err := VerifyAuditLog(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, err
+	case !result.Valid:
+		return nil, fmt.Errorf("%s: %s", path, result.Error)
+	default:
+		log.seq, log.head = result.Entries, result.Head
+	}
+	return log, nil
+}
+
+// Append records a signing operation.
+func (l *AuditLog) Append(kind string, account common.Address, digest common.Hash, opErr error) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	entry := &AuditEntry{
+		Seq:     l.seq,
+		Time:    time.Now().UTC(),
+		Kind:    kind,
+		Account: account,
+		Digest:  digest,
+		Prev:    l.head,
+	}
+	if opErr != nil {
+		entry.Error = opErr.Error()
+	}
+	entry.Hash = entry.chainHash()
+
+	blob, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(blob, '\n')); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	l.seq, l.head = l.seq+1, entry.Hash
+	return nil
+}
+
+// Export returns up to count entries starting at sequence number from, all of
+// them if count is zero.
+func (l *AuditLog) Export(from, count uint64) ([]*AuditEntry, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	entries := []*AuditEntry{}
+	err := readAuditLog(l.path, func(entry *AuditEntry) bool {
+		if entry.Seq >= from {
+			entries = append(entries, entry)
+		}
+		return count == 0 || uint64(len(entries)) < count
+	})
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return entries, err
+}
+
+// Verify checks the hash chain of the log.
+func (l *AuditLog) Verify() (*AuditVerification, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	result, err := VerifyAuditLog(l.path)
+	if os.IsNotExist(err) {
+		return &AuditVerification{Valid: true}, nil
+	}
+	return result, err
+}
+
+// AuditVerification is the outcome of an audit log verification.
+type AuditVerification struct {
+	Entries uint64      `json:"entries"` // Number of entries verified
+	Head    common.Hash `json:"head"`    // Hash of the last valid entry
+	Valid   bool        `json:"valid"`
+	Error   string      `json:"error,omitempty"` // Reason the chain is invalid
+}
+
+// VerifyAuditLog checks the hash chain of the audit log file at path, stopping
+// at the first invalid entry.
+func VerifyAuditLog(path string) (*AuditVerification, error) {
+	result := &AuditVerification{Valid: true}
+	err := readAuditLog(path, func(entry *AuditEntry) bool {
+		switch {
+		case entry.Seq != result.Entries:
+			result.Error = fmt.Sprintf("entry %d: sequence number %d", result.Entries, entry.Seq)
+		case entry.Prev != result.Head:
+			result.Error = fmt.Sprintf("entry %d: %v", result.Entries, errAuditChainBroken)
+		case entry.Hash != entry.chainHash():
+			result.Error = fmt.Sprintf("entry %d: hash mismatch", result.Entries)
+		default:
+			result.Entries, result.Head = result.Entries+1, entry.Hash
+			return true
+		}
+		result.Valid = false
+		return false
+	})
+	if err != nil {
+		if _, ok := err.(*auditDecodeError); ok {
+			result.Valid, result.Error = false, fmt.Sprintf("entry %d: %v", result.Entries, err)
+			return result, nil
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+// auditDecodeError is returned when an audit log line can't be decoded.
+type auditDecodeError struct{ err error }
+
+func (e *auditDecodeError) Error() string { return "malformed entry: " + e.err.Error() }
+
+// readAuditLog decodes the entries of the audit log file at path, until fn
+// returns false.
+func readAuditLog(path string, fn func(*AuditEntry) bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		entry := new(AuditEntry)
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			return &auditDecodeError{err}
+		}
+		if !fn(entry) {
+			break
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,103 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package keystore
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core/types"
+)
+
+func TestAuditLog(t *testing.T) {
+	dir, ks := tmpKeyStore(t, true)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.log")
+	auditLog, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks.SetAuditLog(auditLog)
+
+	pass := "foo"
+	acc, err := ks.NewAccount(pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A failed and a successful signature, then a transaction signature
+	if _, err := ks.SignHash(acc, testSigData); err != ErrLocked {
+		t.Fatalf("SignHash error mismatch: have %v, want %v", err, ErrLocked)
+	}
+	if _, err := ks.SignHashWithPassphrase(acc, pass, testSigData); err != nil {
+		t.Fatal(err)
+	}
+	tx := types.NewTransaction(0, common.Address{}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil)
+	if _, err := ks.SignTxWithPassphrase(acc, pass, tx, big.NewInt(1)); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := auditLog.Export(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		kind   string
+		digest common.Hash
+		failed bool
+	}{
+		{AuditSignHash, common.BytesToHash(testSigData), true},
+		{AuditSignHash, common.BytesToHash(testSigData), false},
+		{AuditSignTx, tx.Hash(), false},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("entry count mismatch: have %d, want %d", len(entries), len(want))
+	}
+	for i, entry := range entries {
+		if entry.Kind != want[i].kind || entry.Digest != want[i].digest || entry.Account != acc.Address || (entry.Error != "") != want[i].failed {
+			t.Errorf("entry %d mismatch: %+v", i, entry)
+		}
+	}
+	if tail, _ := auditLog.Export(1, 1); len(tail) != 1 || tail[0].Seq != 1 {
+		t.Errorf("partial export mismatch: %+v", tail)
+	}
+	if result, err := auditLog.Verify(); err != nil || !result.Valid || result.Entries != 3 || result.Head != entries[2].Hash {
+		t.Fatalf("verification failed: %+v, %v", result, err)
+	}
+	// Reopening the log continues the chain
+	reopened, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.Append(AuditSignHash, acc.Address, common.Hash{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if result, _ := reopened.Verify(); !result.Valid || result.Entries != 4 {
+		t.Fatalf("reopened chain invalid: %+v", result)
+	}
+	// Tampering with an entry breaks the chain
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Split(blob, []byte("\n"))
+	lines[1] = bytes.Replace(lines[1], []byte(AuditSignHash), []byte(AuditSignTx), 1)
+	if err := ioutil.WriteFile(path, bytes.Join(lines, []byte("\n")), 0600); err != nil {
+		t.Fatal(err)
+	}
+	result, err := VerifyAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Valid || result.Entries != 1 || !strings.Contains(result.Error, "entry 1") {
+		t.Errorf("tampered log verification mismatch: %+v", result)
+	}
+	if _, err := OpenAuditLog(path); err == nil {
+		t.Error("tampered log opened")
+	}
+}
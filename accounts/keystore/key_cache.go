@@ -0,0 +1,120 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package keystore
+
+import (
+	"crypto/ecdsa"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"math/big"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/wanchain/go-wanchain/common"
+)
+
+// keyCache is a bounded cache of decrypted keys, sparing the scrypt derivation
+// when the same key is repeatedly decrypted with the same passphrase, e.g. by
+// OTA scanning. Entries are keyed by address and a salted digest of the
+// passphrase, and expire after a timeout.
+type keyCache struct {
+	salt    [32]byte
+	timeout time.Duration
+
+	lock  sync.Mutex
+	cache *lru.Cache
+}
+
+type keyCacheEntry struct {
+	key     *Key
+	expires time.Time
+}
+
+// newKeyCache creates a cache holding up to size keys for timeout.
+func newKeyCache(size int, timeout time.Duration) (*keyCache, error) {
+	cache, err := lru.NewWithEvict(size, func(_ interface{}, value interface{}) {
+		zeroKeys(value.(*keyCacheEntry).key)
+	})
+	if err != nil {
+		return nil, err
+	}
+	c := &keyCache{timeout: timeout, cache: cache}
+	if _, err := crand.Read(c.salt[:]); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *keyCache) id(addr common.Address, auth string) string {
+	digest := sha256.Sum256(append(c.salt[:], auth...))
+	return string(append(addr.Bytes(), digest[:]...))
+}
+
+// get returns a copy of the cached key of addr decrypted with auth, if any.
+func (c *keyCache) get(addr common.Address, auth string) *Key {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	id := c.id(addr, auth)
+	value, ok := c.cache.Get(id)
+	if !ok {
+		return nil
+	}
+	entry := value.(*keyCacheEntry)
+	if time.Now().After(entry.expires) {
+		c.cache.Remove(id)
+		return nil
+	}
+	return copyKey(entry.key)
+}
+
+// add caches a copy of the key of addr decrypted with auth.
+func (c *keyCache) add(addr common.Address, auth string, key *Key) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.cache.Add(c.id(addr, auth), &keyCacheEntry{key: copyKey(key), expires: time.Now().Add(c.timeout)})
+}
+
+// drop evicts the cached keys of addr.
+func (c *keyCache) drop(addr common.Address) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, id := range c.cache.Keys() {
+		if id.(string)[:common.AddressLength] == string(addr.Bytes()) {
+			c.cache.Remove(id)
+		}
+	}
+}
+
+// purge evicts all the cached keys.
+func (c *keyCache) purge() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.cache.Purge()
+}
+
+// copyKey deep copies a key, as the callers zero the private keys after use.
+func copyKey(key *Key) *Key {
+	cpy := *key
+	cpy.PrivateKey = copyPrivateKey(key.PrivateKey)
+	cpy.PrivateKey2 = copyPrivateKey(key.PrivateKey2)
+	return &cpy
+}
+
+func copyPrivateKey(priv *ecdsa.PrivateKey) *ecdsa.PrivateKey {
+	if priv == nil {
+		return nil
+	}
+	cpy := *priv
+	cpy.D = new(big.Int).Set(priv.D)
+	return &cpy
+}
+
+func zeroKeys(key *Key) {
+	zeroKey(key.PrivateKey)
+	zeroKey(key.PrivateKey2)
+}
@@ -26,6 +26,7 @@ import (
 	crand "crypto/rand"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"math/big"
 	"os"
 	"path/filepath"
@@ -39,13 +40,20 @@ import (
 	"github.com/wanchain/go-wanchain/common/hexutil"
 	"github.com/wanchain/go-wanchain/core/types"
 	"github.com/wanchain/go-wanchain/crypto"
+	"github.com/wanchain/go-wanchain/crypto/ecies"
 	"github.com/wanchain/go-wanchain/event"
+	"github.com/wanchain/go-wanchain/log"
 )
 
 var (
 	ErrLocked  = accounts.NewAuthNeededError("password or unlock")
 	ErrNoMatch = errors.New("no key for given address or file")
 	ErrDecrypt = errors.New("could not decrypt key with given passphrase")
+
+	ErrReadOnly     = errors.New("keystore is read-only")
+	ErrUnlockDenied = errors.New("keystore policy forbids unlocking keys")
+
+	ErrNotOTAOwner = errors.New("OTA not received by account")
 )
 
 // KeyStoreType is the reflect type of a keystore backend.
@@ -63,6 +71,9 @@ type KeyStore struct {
 	cache    *accountCache                // In-memory account cache over the filesystem storage
 	changes  chan struct{}                // Channel receiving change notifications from the cache
 	unlocked map[common.Address]*unlocked // Currently unlocked account (decrypted private keys)
+	policy   Policy                       // Operations allowed on the keys
+	keys     *keyCache                    // Cache of decrypted keys, nil if disabled
+	auditLog *AuditLog                    // Log of the signing operations, nil if disabled
 
 	wallets     []accounts.Wallet       // Wallet wrappers around the individual key files
 	updateFeed  event.Feed              // Event feed to notify wallet additions/removals
@@ -77,14 +88,73 @@ type unlocked struct {
 	abort chan struct{}
 }
 
+// Policy restricts the operations allowed on the keys of a keystore.
+type Policy struct {
+	ReadOnly bool // Forbid creating, importing, updating and deleting keys
+	NoUnlock bool // Forbid unlocking keys, signing requires the passphrase
+}
+
 // NewKeyStore creates a keystore for the given directory.
 func NewKeyStore(keydir string, scryptN, scryptP int) *KeyStore {
+	return NewKeyStoreWithPolicy(keydir, scryptN, scryptP, Policy{})
+}
+
+// NewKeyStoreWithPolicy creates a keystore for the given directory, restricting
+// the operations on its keys according to policy.
+func NewKeyStoreWithPolicy(keydir string, scryptN, scryptP int, policy Policy) *KeyStore {
 	keydir, _ = filepath.Abs(keydir)
-	ks := &KeyStore{storage: &keyStorePassphrase{keydir, scryptN, scryptP}}
+	ks := &KeyStore{storage: &keyStorePassphrase{keydir, scryptN, scryptP}, policy: policy}
 	ks.init(keydir)
 	return ks
 }
 
+// EnableKeyCache caches up to size decrypted keys for timeout, so that keys
+// repeatedly decrypted with their passphrase aren't derived again each time.
+func (ks *KeyStore) EnableKeyCache(size int, timeout time.Duration) error {
+	keys, err := newKeyCache(size, timeout)
+	if err != nil {
+		return err
+	}
+	ks.mu.Lock()
+	ks.keys = keys
+	ks.mu.Unlock()
+	return nil
+}
+
+// SetAuditLog records all the signing operations of the keystore into log.
+func (ks *KeyStore) SetAuditLog(log *AuditLog) {
+	ks.mu.Lock()
+	ks.auditLog = log
+	ks.mu.Unlock()
+}
+
+// AuditLog returns the log of the signing operations, nil if disabled.
+func (ks *KeyStore) AuditLog() *AuditLog {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	return ks.auditLog
+}
+
+// audit records a signing operation into the audit log, if any.
+func (ks *KeyStore) audit(kind string, a accounts.Account, digest common.Hash, err error) {
+	ks.mu.RLock()
+	auditLog := ks.auditLog
+	ks.mu.RUnlock()
+
+	if auditLog == nil {
+		return
+	}
+	if err := auditLog.Append(kind, a.Address, digest, err); err != nil {
+		log.Error("Failed to write signer audit log", "kind", kind, "account", a.Address, "err", err)
+	}
+}
+
+// Policy returns the operations allowed on the keys of the keystore.
+func (ks *KeyStore) Policy() Policy {
+	return ks.policy
+}
+
 // NewPlaintextKeyStore creates a keystore for the given directory.
 // Deprecated: Use NewKeyStore.
 func NewPlaintextKeyStore(keydir string) *KeyStore {
@@ -220,6 +290,23 @@ func (ks *KeyStore) updater() {
 	}
 }
 
+// PrimaryKeyStore returns the keystore of the account manager new keys are
+// created in, the one of the node's key directory.
+func PrimaryKeyStore(am *accounts.Manager) *KeyStore {
+	return am.Backends(KeyStoreType)[0].(*KeyStore)
+}
+
+// AccountKeyStore returns the keystore of the account manager holding addr,
+// defaulting to the primary keystore if none does.
+func AccountKeyStore(am *accounts.Manager, addr common.Address) *KeyStore {
+	for _, backend := range am.Backends(KeyStoreType) {
+		if ks := backend.(*KeyStore); ks.HasAddress(addr) {
+			return ks
+		}
+	}
+	return PrimaryKeyStore(am)
+}
+
 // HasAddress reports whether a key with the given address is present.
 func (ks *KeyStore) HasAddress(addr common.Address) bool {
 	return ks.cache.hasAddress(addr)
@@ -233,6 +320,9 @@ func (ks *KeyStore) Accounts() []accounts.Account {
 // Delete deletes the key matched by account if the passphrase is correct.
 // If the account contains no filename, the address must match a unique key.
 func (ks *KeyStore) Delete(a accounts.Account, passphrase string) error {
+	if ks.policy.ReadOnly {
+		return ErrReadOnly
+	}
 	// Decrypting the key isn't really necessary, but we do
 	// it anyway to check the password and zero out the key
 	// immediately afterwards.
@@ -248,6 +338,7 @@ func (ks *KeyStore) Delete(a accounts.Account, passphrase string) error {
 	// between won't insert it into the cache again.
 	err = os.Remove(a.URL.Path)
 	if err == nil {
+		ks.dropCachedKeys(a.Address)
 		ks.cache.delete(a)
 		ks.refreshWallets()
 	}
@@ -256,7 +347,9 @@ func (ks *KeyStore) Delete(a accounts.Account, passphrase string) error {
 
 // SignHash calculates a ECDSA signature for the given hash. The produced
 // signature is in the [R || S || V] format where V is 0 or 1.
-func (ks *KeyStore) SignHash(a accounts.Account, hash []byte) ([]byte, error) {
+func (ks *KeyStore) SignHash(a accounts.Account, hash []byte) (signature []byte, err error) {
+	defer func() { ks.audit(AuditSignHash, a, common.BytesToHash(hash), err) }()
+
 	// Look up the key to sign with and abort if it cannot be found
 	ks.mu.RLock()
 	defer ks.mu.RUnlock()
@@ -270,7 +363,9 @@ func (ks *KeyStore) SignHash(a accounts.Account, hash []byte) ([]byte, error) {
 }
 
 // SignTx signs the given transaction with the requested account.
-func (ks *KeyStore) SignTx(a accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+func (ks *KeyStore) SignTx(a accounts.Account, tx *types.Transaction, chainID *big.Int) (signed *types.Transaction, err error) {
+	defer func() { ks.audit(AuditSignTx, a, tx.Hash(), err) }()
+
 	// Look up the key to sign with and abort if it cannot be found
 	ks.mu.RLock()
 	defer ks.mu.RUnlock()
@@ -286,7 +381,9 @@ func (ks *KeyStore) SignTx(a accounts.Account, tx *types.Transaction, chainID *b
 	return types.SignTx(tx, types.HomesteadSigner{}, unlockedKey.PrivateKey)
 }
 
-func (ks *KeyStore) ComputeOTAPPKeys(a accounts.Account, AX, AY, BX, BY string) ([]string, error) {
+func (ks *KeyStore) ComputeOTAPPKeys(a accounts.Account, AX, AY, BX, BY string) (keys []string, err error) {
+	defer func() { ks.audit(AuditOTAKeys, a, crypto.Keccak256Hash([]byte(AX+AY+BX+BY)), err) }()
+
 	ks.mu.RLock()
 	defer ks.mu.RUnlock()
 
@@ -305,10 +402,176 @@ func (ks *KeyStore) ComputeOTAPPKeys(a accounts.Account, AX, AY, BX, BY string)
 	return []string{pub1X, pub1Y, priv1D, priv2D}, err
 }
 
+// SignHashWithOTA signs hash with the private key of an OTA received by the
+// unlocked account, proving the control of the OTA without spending it. The
+// produced signature is in the [R || S || V] format where V is 0 or 1.
+func (ks *KeyStore) SignHashWithOTA(a accounts.Account, ota []byte, hash []byte) (signature []byte, err error) {
+	defer func() { ks.audit(AuditOTASign, a, common.BytesToHash(hash), err) }()
+
+	A1, R, err := GeneratePKPairFromWAddress(ota)
+	if err != nil {
+		return nil, err
+	}
+
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	unlockedKey, found := ks.unlocked[a.Address]
+	if !found {
+		return nil, ErrLocked
+	}
+	priv, _, err := crypto.GenerateOneTimePrivateKey2528(unlockedKey.PrivateKey, unlockedKey.PrivateKey2, A1, R)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(priv)
+
+	priv.Curve = crypto.S256()
+	priv.X, priv.Y = priv.Curve.ScalarBaseMult(priv.D.Bytes())
+	if priv.X.Cmp(A1.X) != 0 || priv.Y.Cmp(A1.Y) != 0 {
+		return nil, ErrNotOTAOwner
+	}
+	return crypto.Sign(hash, priv)
+}
+
+// IsOTAOwner reports whether the OTA wan address ota was generated for the
+// unlocked account. Only the second private key of the account is involved,
+// which can't spend the OTA.
+func (ks *KeyStore) IsOTAOwner(a accounts.Account, ota []byte) (bool, error) {
+	A1, S1, err := GeneratePKPairFromWAddress(ota)
+	if err != nil {
+		return false, err
+	}
+
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	unlockedKey, found := ks.unlocked[a.Address]
+	if !found {
+		return false, ErrLocked
+	}
+	return crypto.CompareA1(unlockedKey.PrivateKey2.D.Bytes(), &unlockedKey.PrivateKey.PublicKey, S1, A1), nil
+}
+
+// OTAViewKey is the view key of an account, detecting the OTAs generated for
+// its wan address without being able to spend them.
+type OTAViewKey struct {
+	b *ecdsa.PrivateKey // Second private key of the account
+	A *ecdsa.PublicKey  // First public key of the account
+}
+
+// ViewKey decrypts the view key of an account with its passphrase. Unlike
+// unlocking the account, the key spending its OTAs stays encrypted, so the
+// view keys of accounts which can't be unlocked can be decrypted.
+func (ks *KeyStore) ViewKey(a accounts.Account, passphrase string) (viewKey *OTAViewKey, err error) {
+	defer func() { ks.audit(AuditViewKey, a, common.Hash{}, err) }()
+
+	a, err = ks.Find(a)
+	if err != nil {
+		return nil, err
+	}
+	keyjson, err := ioutil.ReadFile(a.URL.Path)
+	if err != nil {
+		return nil, err
+	}
+	b, wAddr, err := DecryptViewKey(keyjson, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	A, _, err := GeneratePKPairFromWAddress(wAddr[:])
+	if err != nil {
+		return nil, err
+	}
+	return &OTAViewKey{b: b, A: &ecdsa.PublicKey{Curve: crypto.S256(), X: A.X, Y: A.Y}}, nil
+}
+
+// Owns reports whether the OTA wan address ota was generated for the wan
+// address of the view key.
+func (k *OTAViewKey) Owns(ota []byte) (bool, error) {
+	A1, S1, err := GeneratePKPairFromWAddress(ota)
+	if err != nil {
+		return false, err
+	}
+	return crypto.CompareA1(k.b.D.Bytes(), k.A, S1, A1), nil
+}
+
+// SealToWAddress encrypts a message with ECIES to the first public key of a wan
+// address, for its account to decrypt with DecryptWithAccount.
+func SealToWAddress(wAddr []byte, msg []byte) ([]byte, error) {
+	A, _, err := GeneratePKPairFromWAddress(wAddr)
+	if err != nil {
+		return nil, err
+	}
+	pub := &ecdsa.PublicKey{Curve: crypto.S256(), X: A.X, Y: A.Y}
+	return ecies.Encrypt(crand.Reader, ecies.ImportECDSAPublic(pub), msg, nil, nil)
+}
+
+// DecryptWithAccount decrypts a message sealed by SealToWAddress to the wan
+// address of the unlocked account.
+func (ks *KeyStore) DecryptWithAccount(a accounts.Account, ciphertext []byte) (plaintext []byte, err error) {
+	defer func() { ks.audit(AuditDecrypt, a, crypto.Keccak256Hash(ciphertext), err) }()
+
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	unlockedKey, found := ks.unlocked[a.Address]
+	if !found {
+		return nil, ErrLocked
+	}
+	return ecies.ImportECDSA(unlockedKey.PrivateKey).Decrypt(crand.Reader, ciphertext, nil, nil)
+}
+
+// VerifyOTASignature reports whether sig is a signature of hash made with the
+// private key of ota, in the [R || S || V] format where V is 0 or 1.
+func VerifyOTASignature(ota []byte, hash []byte, sig []byte) (bool, error) {
+	A1, _, err := GeneratePKPairFromWAddress(ota)
+	if err != nil {
+		return false, err
+	}
+	pub, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return false, err
+	}
+	return pub.X.Cmp(A1.X) == 0 && pub.Y.Cmp(A1.Y) == 0, nil
+}
+
+// NewJointRingSignShare creates the signing state of an unlocked account in
+// the joint ring signature of an OTA received by a joint wan address. The
+// account holding the view key of the joint wan address contributes the share
+// hash([b]R)+a of the OTA private key, the other accounts their spend key a.
+func (ks *KeyStore) NewJointRingSignShare(a accounts.Account, ota []byte, viewKey bool) (share *crypto.RingSignShare, err error) {
+	defer func() { ks.audit(AuditRingShare, a, crypto.Keccak256Hash(ota), err) }()
+
+	A1, R, err := GeneratePKPairFromWAddress(ota)
+	if err != nil {
+		return nil, err
+	}
+
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	unlockedKey, found := ks.unlocked[a.Address]
+	if !found {
+		return nil, ErrLocked
+	}
+
+	x := unlockedKey.PrivateKey.D
+	if viewKey {
+		priv, _, err := crypto.GenerateOneTimePrivateKey2528(unlockedKey.PrivateKey, unlockedKey.PrivateKey2, A1, R)
+		if err != nil {
+			return nil, err
+		}
+		x = priv.D
+	}
+	return crypto.NewRingSignShare(x, A1)
+}
+
 // SignHashWithPassphrase signs hash if the private key matching the given address
 // can be decrypted with the given passphrase. The produced signature is in the
 // [R || S || V] format where V is 0 or 1.
 func (ks *KeyStore) SignHashWithPassphrase(a accounts.Account, passphrase string, hash []byte) (signature []byte, err error) {
+	defer func() { ks.audit(AuditSignHash, a, common.BytesToHash(hash), err) }()
+
 	_, key, err := ks.getDecryptedKey(a, passphrase)
 	if err != nil {
 		return nil, err
@@ -319,7 +582,9 @@ func (ks *KeyStore) SignHashWithPassphrase(a accounts.Account, passphrase string
 
 // SignTxWithPassphrase signs the transaction if the private key matching the
 // given address can be decrypted with the given passphrase.
-func (ks *KeyStore) SignTxWithPassphrase(a accounts.Account, passphrase string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+func (ks *KeyStore) SignTxWithPassphrase(a accounts.Account, passphrase string, tx *types.Transaction, chainID *big.Int) (signed *types.Transaction, err error) {
+	defer func() { ks.audit(AuditSignTx, a, tx.Hash(), err) }()
+
 	_, key, err := ks.getDecryptedKey(a, passphrase)
 	if err != nil {
 		return nil, err
@@ -340,6 +605,7 @@ func (ks *KeyStore) Unlock(a accounts.Account, passphrase string) error {
 
 // Lock removes the private key with the given address from memory.
 func (ks *KeyStore) Lock(addr common.Address) error {
+	ks.dropCachedKeys(addr)
 	ks.mu.Lock()
 	if unl, found := ks.unlocked[addr]; found {
 		ks.mu.Unlock()
@@ -358,6 +624,9 @@ func (ks *KeyStore) Lock(addr common.Address) error {
 // shortens the active unlock timeout. If the address was previously unlocked
 // indefinitely the timeout is not altered.
 func (ks *KeyStore) TimedUnlock(a accounts.Account, passphrase string, timeout time.Duration) error {
+	if ks.policy.NoUnlock {
+		return ErrUnlockDenied
+	}
 	a, key, err := ks.getDecryptedKey(a, passphrase)
 	if err != nil {
 		return err
@@ -400,10 +669,33 @@ func (ks *KeyStore) getDecryptedKey(a accounts.Account, auth string) (accounts.A
 	if err != nil {
 		return a, nil, err
 	}
+	ks.mu.RLock()
+	keys := ks.keys
+	ks.mu.RUnlock()
+
+	if keys != nil {
+		if key := keys.get(a.Address, auth); key != nil {
+			return a, key, nil
+		}
+	}
 	key, err := ks.storage.GetKey(a.Address, a.URL.Path, auth)
+	if err == nil && keys != nil {
+		keys.add(a.Address, auth, key)
+	}
 	return a, key, err
 }
 
+// dropCachedKeys evicts the decrypted keys of addr from the key cache.
+func (ks *KeyStore) dropCachedKeys(addr common.Address) {
+	ks.mu.RLock()
+	keys := ks.keys
+	ks.mu.RUnlock()
+
+	if keys != nil {
+		keys.drop(addr)
+	}
+}
+
 // getEncryptedKey loads an encrypted keyfile from the disk
 func (ks *KeyStore) getEncryptedKey(a accounts.Account) (accounts.Account, *Key, error) {
 	a, err := ks.Find(a)
@@ -441,6 +733,9 @@ func (ks *KeyStore) expire(addr common.Address, u *unlocked, timeout time.Durati
 // NewAccount generates a new key and stores it into the key directory,
 // encrypting it with the passphrase.
 func (ks *KeyStore) NewAccount(passphrase string) (accounts.Account, error) {
+	if ks.policy.ReadOnly {
+		return accounts.Account{}, ErrReadOnly
+	}
 	_, account, err := storeNewKey(ks.storage, crand.Reader, passphrase)
 	if err != nil {
 		return accounts.Account{}, err
@@ -497,6 +792,9 @@ func (ks *KeyStore) ImportECDSA(priv1, priv2 *ecdsa.PrivateKey, passphrase strin
 }
 
 func (ks *KeyStore) importKey(key *Key, passphrase string) (accounts.Account, error) {
+	if ks.policy.ReadOnly {
+		return accounts.Account{}, ErrReadOnly
+	}
 	a := accounts.Account{Address: key.Address, URL: accounts.URL{Scheme: KeyStoreScheme, Path: ks.storage.JoinPath(keyFileName(key.Address))}}
 	if err := ks.storage.StoreKey(a.URL.Path, key, passphrase); err != nil {
 		return accounts.Account{}, err
@@ -508,6 +806,9 @@ func (ks *KeyStore) importKey(key *Key, passphrase string) (accounts.Account, er
 
 // Update transitions an account from a previous format to the current one, also providing the possibility to change the pass-phrase
 func (ks *KeyStore) Update(a accounts.Account, passphrase, newPassphrase string) error {
+	if ks.policy.ReadOnly {
+		return ErrReadOnly
+	}
 	a, key, err := ks.getDecryptedKey(a, passphrase)
 	if err != nil {
 		return err
@@ -520,12 +821,16 @@ func (ks *KeyStore) Update(a accounts.Account, passphrase, newPassphrase string)
 		key.PrivateKey2 = sk2
 	}
 	updateWaddress(key)
+	ks.dropCachedKeys(a.Address)
 	return ks.storage.StoreKey(a.URL.Path, key, newPassphrase)
 }
 
 // ImportPreSaleKey decrypts the given Ethereum presale wallet and stores
 // a key file in the key directory. The key file is encrypted with the same passphrase.
 func (ks *KeyStore) ImportPreSaleKey(keyJSON []byte, passphrase string) (accounts.Account, error) {
+	if ks.policy.ReadOnly {
+		return accounts.Account{}, ErrReadOnly
+	}
 	a, _, err := importPreSaleKey(ks.storage, keyJSON, passphrase)
 	if err != nil {
 		return a, err
@@ -305,6 +305,34 @@ func DecryptKey(keyjson []byte, auth string) (*Key, error) {
 	}, nil
 }
 
+// DecryptViewKey decrypts only the second private key of a key json blob, the
+// view key detecting the OTAs of its wan address, returning it along with the
+// wan address. The first private key, which spends the OTAs, isn't decrypted.
+func DecryptViewKey(keyjson []byte, auth string) (*ecdsa.PrivateKey, common.WAddress, error) {
+	k := new(encryptedKeyJSONV3)
+	if err := json.Unmarshal(keyjson, k); err != nil {
+		return nil, common.WAddress{}, err
+	}
+	if k.Version != version || k.Crypto2.Cipher == "" {
+		return nil, common.WAddress{}, fmt.Errorf("key without view key, version %v", k.Version)
+	}
+	keyBytes2, err := decryptKeyV3Item(k.Crypto2, auth)
+	if err != nil {
+		return nil, common.WAddress{}, err
+	}
+	key2, err := crypto.ToECDSA(keyBytes2)
+	if err != nil || key2 == nil {
+		return nil, common.WAddress{}, ErrInvalidPrivateKey
+	}
+	waddressRaw, err := hex.DecodeString(k.WAddress)
+	if err != nil || len(waddressRaw) != common.WAddressLength {
+		return nil, common.WAddress{}, ErrInvalidPrivateKey
+	}
+	var waddress common.WAddress
+	copy(waddress[:], waddressRaw)
+	return key2, waddress, nil
+}
+
 func decryptKeyV3(keyProtected *encryptedKeyJSONV3, auth string) (keyBytes []byte, keyBytes2 []byte, keyId []byte, err error) {
 	if keyProtected.Version != version {
 		return nil, nil, nil, fmt.Errorf("Version not supported: %v", keyProtected.Version)
@@ -18,9 +18,11 @@
 package keystore
 
 import (
+	"bytes"
 	"io/ioutil"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"runtime"
 	"sort"
 	"strings"
@@ -188,6 +190,63 @@ func TestSignWithPassphrase(t *testing.T) {
 	}
 }
 
+func TestKeyStorePolicy(t *testing.T) {
+	dir, ks := tmpKeyStore(t, true)
+	defer os.RemoveAll(dir)
+
+	pass := "foo"
+	acc, err := ks.NewAccount(pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared := NewKeyStoreWithPolicy(dir, veryLightScryptN, veryLightScryptP, Policy{ReadOnly: true, NoUnlock: true})
+	if !shared.HasAddress(acc.Address) {
+		t.Fatalf("shared keystore misses account %x", acc.Address)
+	}
+	if _, err := shared.NewAccount(pass); err != ErrReadOnly {
+		t.Errorf("NewAccount error mismatch: have %v, want %v", err, ErrReadOnly)
+	}
+	if err := shared.Update(acc, pass, "bar"); err != ErrReadOnly {
+		t.Errorf("Update error mismatch: have %v, want %v", err, ErrReadOnly)
+	}
+	if err := shared.Delete(acc, pass); err != ErrReadOnly {
+		t.Errorf("Delete error mismatch: have %v, want %v", err, ErrReadOnly)
+	}
+	if err := shared.Unlock(acc, pass); err != ErrUnlockDenied {
+		t.Errorf("Unlock error mismatch: have %v, want %v", err, ErrUnlockDenied)
+	}
+	if _, err := shared.SignHashWithPassphrase(acc, pass, testSigData); err != nil {
+		t.Errorf("SignHashWithPassphrase failed: %v", err)
+	}
+	if _, err := os.Stat(acc.URL.Path); err != nil {
+		t.Errorf("account file removed: %v", err)
+	}
+}
+
+func TestAccountKeyStore(t *testing.T) {
+	dir, primary := tmpKeyStore(t, true)
+	defer os.RemoveAll(dir)
+	sharedDir, shared := tmpKeyStore(t, true)
+	defer os.RemoveAll(sharedDir)
+
+	acc, err := shared.NewAccount("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	am := accounts.NewManager(primary, shared)
+	defer am.Close()
+
+	if ks := PrimaryKeyStore(am); ks != primary {
+		t.Errorf("primary keystore mismatch")
+	}
+	if ks := AccountKeyStore(am, acc.Address); ks != shared {
+		t.Errorf("keystore of %x mismatch", acc.Address)
+	}
+	if ks := AccountKeyStore(am, common.Address{0x01}); ks != primary {
+		t.Errorf("unknown account not defaulting to the primary keystore")
+	}
+}
+
 func TestTimedUnlock(t *testing.T) {
 	dir, ks := tmpKeyStore(t, true)
 	defer os.RemoveAll(dir)
@@ -534,3 +593,272 @@ func TestComputeOTAPPKeys(t *testing.T) {
 		t.Errorf("invalid ota pk. pk lenght:%d", len(pk))
 	}
 }
+
+func TestSignHashWithOTA(t *testing.T) {
+	dir, ks := tmpKeyStore(t, true)
+	defer os.RemoveAll(dir)
+
+	auth := "wanchain_test"
+	owner, err := ks.NewAccount(auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ks.NewAccount(auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.SignHashWithOTA(owner, make([]byte, common.WAddressLength), testSigData); err == nil {
+		t.Error("signed with an invalid OTA")
+	}
+	wAddr, err := ks.GetWanAddress(owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otaStr, err := genOTA(hexutil.Encode(wAddr[:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ota := common.FromHex(otaStr)
+
+	if _, err := ks.SignHashWithOTA(owner, ota, testSigData); err != ErrLocked {
+		t.Errorf("locked account error mismatch: have %v, want %v", err, ErrLocked)
+	}
+	ks.Unlock(owner, auth)
+	ks.Unlock(other, auth)
+
+	if _, err := ks.SignHashWithOTA(other, ota, testSigData); err != ErrNotOTAOwner {
+		t.Errorf("foreign OTA error mismatch: have %v, want %v", err, ErrNotOTAOwner)
+	}
+	sig, err := ks.SignHashWithOTA(owner, ota, testSigData)
+	if err != nil {
+		t.Fatalf("failed to sign with OTA: %v", err)
+	}
+	if valid, err := VerifyOTASignature(ota, testSigData, sig); !valid || err != nil {
+		t.Errorf("OTA signature rejected: %v", err)
+	}
+	if valid, _ := VerifyOTASignature(ota, crypto.Keccak256([]byte("other")), sig); valid {
+		t.Error("OTA signature accepted for another hash")
+	}
+	// A signature of the main account key doesn't prove control of the OTA
+	sig, err = ks.SignHash(owner, testSigData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if valid, _ := VerifyOTASignature(ota, testSigData, sig); valid {
+		t.Error("account signature accepted for OTA")
+	}
+}
+
+func TestKeyCache(t *testing.T) {
+	dir, ks := tmpKeyStore(t, true)
+	defer os.RemoveAll(dir)
+
+	if err := ks.EnableKeyCache(2, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	pass := "foo"
+	acc, err := ks.NewAccount(pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.SignHashWithPassphrase(acc, pass, testSigData); err != nil {
+		t.Fatal(err)
+	}
+	// The cached key must survive the callers zeroing their copy
+	cached := ks.keys.get(acc.Address, pass)
+	if cached == nil || cached.PrivateKey.D.Sign() == 0 {
+		t.Fatal("decrypted key not cached")
+	}
+	if _, err := ks.SignHashWithPassphrase(acc, "bar", testSigData); err != ErrDecrypt {
+		t.Errorf("wrong passphrase error mismatch: have %v, want %v", err, ErrDecrypt)
+	}
+	// Locking the account evicts its keys
+	ks.Lock(acc.Address)
+	if ks.keys.get(acc.Address, pass) != nil {
+		t.Error("decrypted key cached after lock")
+	}
+	// Expired keys are not returned
+	ks.keys.timeout = -time.Second
+	if _, err := ks.SignHashWithPassphrase(acc, pass, testSigData); err != nil {
+		t.Fatal(err)
+	}
+	if ks.keys.get(acc.Address, pass) != nil {
+		t.Error("expired key returned")
+	}
+}
+
+func TestLoadOrTuneScrypt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wanchain-scrypt-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "scrypt.json")
+	params, err := LoadOrTuneScrypt(path, time.Millisecond, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.N < LightScryptN || params.N > MaxTunedScryptN || params.P != 1 || !params.Light {
+		t.Fatalf("tuned parameters out of range: %+v", params)
+	}
+	// Stored parameters are reused for the same target and mode
+	if err := ioutil.WriteFile(path, []byte(`{"n": 8192, "p": 2, "target": 1000000, "light": true}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if params, err = LoadOrTuneScrypt(path, time.Millisecond, true); err != nil || params.N != 8192 || params.P != 2 {
+		t.Errorf("stored parameters not reused: %+v, %v", params, err)
+	}
+	if params, err = LoadOrTuneScrypt(path, 2*time.Millisecond, true); err != nil || params.N == 8192 {
+		t.Errorf("parameters not retuned for a new target: %+v, %v", params, err)
+	}
+	// Stored parameters weaker than the floor are retuned
+	if err := ioutil.WriteFile(path, []byte(`{"n": 1024, "p": 1, "target": 1000000, "light": true}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if params, err = LoadOrTuneScrypt(path, time.Millisecond, true); err != nil || params.N < LightScryptN {
+		t.Errorf("weak parameters not retuned: %+v, %v", params, err)
+	}
+}
+
+// Tests that tuning never selects parameters weaker than the standard ones
+// unless the lightweight KDF is chosen, and extrapolates beyond the probes.
+func TestTuneScryptBounds(t *testing.T) {
+	if params := TuneScrypt(time.Nanosecond, false); params.N != StandardScryptN || params.Light {
+		t.Errorf("standard floor not applied: %+v", params)
+	}
+	if params := TuneScrypt(time.Nanosecond, true); params.N != LightScryptN || !params.Light {
+		t.Errorf("light floor not applied: %+v", params)
+	}
+	if params := TuneScrypt(time.Hour, true); params.N != MaxTunedScryptN {
+		t.Errorf("generous target not capped: %+v", params)
+	}
+}
+
+func TestIsOTAOwner(t *testing.T) {
+	dir, ks := tmpKeyStore(t, true)
+	defer os.RemoveAll(dir)
+
+	auth := "wanchain_test"
+	owner, err := ks.NewAccount(auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ks.NewAccount(auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wAddr, err := ks.GetWanAddress(owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otaStr, err := genOTA(hexutil.Encode(wAddr[:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ota := common.FromHex(otaStr)
+
+	if _, err := ks.IsOTAOwner(owner, ota); err != ErrLocked {
+		t.Errorf("locked account error mismatch: have %v, want %v", err, ErrLocked)
+	}
+	ks.Unlock(owner, auth)
+	ks.Unlock(other, auth)
+
+	if _, err := ks.IsOTAOwner(owner, ota[1:]); err == nil {
+		t.Error("checked an invalid OTA")
+	}
+	if owns, err := ks.IsOTAOwner(owner, ota); !owns || err != nil {
+		t.Errorf("owner check mismatch: have %v (%v), want true", owns, err)
+	}
+	if owns, err := ks.IsOTAOwner(other, ota); owns || err != nil {
+		t.Errorf("foreign check mismatch: have %v (%v), want false", owns, err)
+	}
+}
+
+func TestViewKey(t *testing.T) {
+	dir, ks := tmpKeyStore(t, true)
+	defer os.RemoveAll(dir)
+
+	auth := "wanchain_test"
+	owner, err := ks.NewAccount(auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wAddr, err := ks.GetWanAddress(owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otaStr, err := genOTA(hexutil.Encode(wAddr[:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ota := common.FromHex(otaStr)
+
+	// View keys are decrypted from keystores forbidding to unlock keys too
+	shared := NewKeyStoreWithPolicy(dir, veryLightScryptN, veryLightScryptP, Policy{ReadOnly: true, NoUnlock: true})
+	if _, err := shared.ViewKey(owner, "bad"); err != ErrDecrypt {
+		t.Errorf("bad passphrase error mismatch: have %v, want %v", err, ErrDecrypt)
+	}
+	viewKey, err := shared.ViewKey(owner, auth)
+	if err != nil {
+		t.Fatalf("failed to decrypt view key: %v", err)
+	}
+	if owns, err := viewKey.Owns(ota); !owns || err != nil {
+		t.Errorf("owner check mismatch: have %v (%v), want true", owns, err)
+	}
+	other, _ := genOTA(hexutil.Encode(wAddr[:]))
+	if owns, err := viewKey.Owns(common.FromHex(other)); !owns || err != nil {
+		t.Errorf("second OTA check mismatch: have %v (%v), want true", owns, err)
+	}
+	if _, err := viewKey.Owns(ota[1:]); err == nil {
+		t.Error("checked an invalid OTA")
+	}
+	foreign, err := ks.NewAccount(auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreignKey, err := ks.ViewKey(foreign, auth)
+	if err != nil {
+		t.Fatalf("failed to decrypt view key: %v", err)
+	}
+	if owns, err := foreignKey.Owns(ota); owns || err != nil {
+		t.Errorf("foreign check mismatch: have %v (%v), want false", owns, err)
+	}
+}
+
+func TestDecryptWithAccount(t *testing.T) {
+	dir, ks := tmpKeyStore(t, true)
+	defer os.RemoveAll(dir)
+
+	auth := "wanchain_test"
+	owner, err := ks.NewAccount(auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ks.NewAccount(auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wAddr, err := ks.GetWanAddress(owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("one-time secret")
+	ciphertext, err := SealToWAddress(wAddr[:], msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ks.DecryptWithAccount(owner, ciphertext); err != ErrLocked {
+		t.Errorf("locked account error mismatch: have %v, want %v", err, ErrLocked)
+	}
+	ks.Unlock(owner, auth)
+	ks.Unlock(other, auth)
+
+	if plaintext, err := ks.DecryptWithAccount(owner, ciphertext); err != nil || !bytes.Equal(plaintext, msg) {
+		t.Errorf("decryption mismatch: have %q (%v), want %q", plaintext, err, msg)
+	}
+	if _, err := ks.DecryptWithAccount(other, ciphertext); err == nil {
+		t.Error("decrypted with another account")
+	}
+}
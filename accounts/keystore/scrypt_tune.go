@@ -0,0 +1,108 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package keystore
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/wanchain/go-wanchain/log"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// MaxTunedScryptN is the largest N parameter TuneScrypt selects, using 1GB
+	// of memory per key derivation.
+	MaxTunedScryptN = 1 << 20
+
+	// maxScryptProbeN is the largest N parameter benchmarked, the derivation
+	// time of larger ones being extrapolated so that tuning doesn't claim more
+	// memory than a standard key derivation.
+	maxScryptProbeN = StandardScryptN
+)
+
+// ScryptParams are the scrypt parameters of the newly encrypted keys, along
+// with the unlock latency and the KDF mode they were tuned for.
+type ScryptParams struct {
+	N      int           `json:"n"`
+	P      int           `json:"p"`
+	Target time.Duration `json:"target"`
+	Light  bool          `json:"light"`
+}
+
+// scryptFloor returns the smallest N parameter tuning may select: the standard
+// one, unless the lightweight KDF was chosen explicitly.
+func scryptFloor(light bool) int {
+	if light {
+		return LightScryptN
+	}
+	return StandardScryptN
+}
+
+// TuneScrypt benchmarks the scrypt key derivation on the host and returns the
+// parameters with the largest N, doubling from StandardScryptN, or from
+// LightScryptN if light, whose derivation stays within the target latency. The
+// r parameter is always the standard 8.
+func TuneScrypt(target time.Duration, light bool) ScryptParams {
+	var (
+		params  = ScryptParams{N: scryptFloor(light), P: 1, Target: target, Light: light}
+		salt    = make([]byte, 32)
+		elapsed time.Duration
+	)
+	for params.N < MaxTunedScryptN {
+		if params.N <= maxScryptProbeN {
+			start := time.Now()
+			if _, err := scrypt.Key([]byte("benchmark"), salt, params.N, scryptR, params.P, scryptDKLen); err != nil {
+				break
+			}
+			elapsed = time.Since(start)
+		}
+		// The derivation time is linear in N, stop if doubling it overshoots
+		if 2*elapsed > target {
+			break
+		}
+		params.N *= 2
+		if params.N > maxScryptProbeN {
+			elapsed *= 2
+		}
+	}
+	return params
+}
+
+// LoadOrTuneScrypt returns the scrypt parameters tuned for the target latency
+// and KDF mode stored in the file at path, benchmarking the host and storing
+// them on first use or when the target or the mode changes. An empty path
+// disables the storage.
+func LoadOrTuneScrypt(path string, target time.Duration, light bool) (ScryptParams, error) {
+	if path != "" {
+		var params ScryptParams
+		blob, err := ioutil.ReadFile(path)
+		switch {
+		case err == nil:
+			if err := json.Unmarshal(blob, &params); err != nil {
+				return ScryptParams{}, err
+			}
+			if params.Target == target && params.Light == light && params.N >= scryptFloor(light) && params.P > 0 {
+				return params, nil
+			}
+		case !os.IsNotExist(err):
+			return ScryptParams{}, err
+		}
+	}
+	log.Info("Tuning scrypt parameters", "target", target, "light", light)
+	params := TuneScrypt(target, light)
+	log.Info("Tuned scrypt parameters", "n", params.N, "r", scryptR, "p", params.P)
+
+	if path != "" {
+		blob, err := json.MarshalIndent(params, "", "  ")
+		if err != nil {
+			return ScryptParams{}, err
+		}
+		if err := ioutil.WriteFile(path, blob, 0600); err != nil {
+			return ScryptParams{}, err
+		}
+	}
+	return params, nil
+}
@@ -0,0 +1,163 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package kms
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// awsProvider signs with AWS KMS asymmetric keys of the ECC_SECG_P256K1 spec,
+// authenticating the requests with the AWS signature version 4.
+type awsProvider struct {
+	endpoint string // KMS endpoint, https://kms.<region>.amazonaws.com by default
+	region   string
+	keyID    string // Access key identifier
+	secret   string // Secret access key
+	token    string // Session token of temporary credentials, if any
+}
+
+// NewAWSProvider creates the AWS KMS provider, configured by the standard AWS
+// environment variables: AWS_REGION (or AWS_DEFAULT_REGION), AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN. AWS_KMS_ENDPOINT overrides the
+// regional endpoint.
+func NewAWSProvider() Provider {
+	p := &awsProvider{
+		endpoint: os.Getenv("AWS_KMS_ENDPOINT"),
+		region:   os.Getenv("AWS_REGION"),
+		keyID:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secret:   os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		token:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if p.region == "" {
+		p.region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if p.endpoint == "" && p.region != "" {
+		p.endpoint = fmt.Sprintf("https://kms.%s.amazonaws.com", p.region)
+	}
+	return p
+}
+
+// Name implements Provider.
+func (p *awsProvider) Name() string { return "aws" }
+
+// PublicKey implements Provider, the key identifier being a key id, ARN or alias.
+func (p *awsProvider) PublicKey(keyID string) ([]byte, error) {
+	var result struct {
+		PublicKey []byte
+	}
+	if err := p.call("GetPublicKey", map[string]interface{}{"KeyId": keyID}, &result); err != nil {
+		return nil, err
+	}
+	return result.PublicKey, nil
+}
+
+// Sign implements Provider.
+func (p *awsProvider) Sign(keyID string, digest []byte) ([]byte, error) {
+	var result struct {
+		Signature []byte
+	}
+	args := map[string]interface{}{
+		"KeyId":            keyID,
+		"Message":          base64.StdEncoding.EncodeToString(digest),
+		"MessageType":      "DIGEST",
+		"SigningAlgorithm": "ECDSA_SHA_256",
+	}
+	if err := p.call("Sign", args, &result); err != nil {
+		return nil, err
+	}
+	return result.Signature, nil
+}
+
+// call invokes a KMS action.
+func (p *awsProvider) call(action string, args interface{}, result interface{}) error {
+	if p.endpoint == "" || p.keyID == "" || p.secret == "" {
+		return errors.New("AWS credentials or region not configured")
+	}
+	body, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", p.endpoint+"/", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService."+action)
+	p.sign(req, body, time.Now().UTC())
+
+	return doJSON(req, body, result)
+}
+
+// sign authenticates a request with the AWS signature version 4.
+func (p *awsProvider) sign(req *http.Request, body []byte, now time.Time) {
+	var (
+		amzDate = now.Format("20060102T150405Z")
+		date    = now.Format("20060102")
+		scope   = date + "/" + p.region + "/kms/aws4_request"
+	)
+	req.Header.Set("X-Amz-Date", amzDate)
+	if p.token != "" {
+		req.Header.Set("X-Amz-Security-Token", p.token)
+	}
+	// Canonicalize the headers, the host included
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(req.Header.Get(name))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonical strings.Builder
+	for _, name := range names {
+		canonical.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	request := strings.Join([]string{
+		req.Method, path, canonicalQuery(req.URL.Query()), canonical.String(), signed, hexSHA256(body),
+	}, "\n")
+
+	toSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hexSHA256([]byte(request))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+p.secret), date)
+	key = hmacSHA256(key, p.region)
+	key = hmacSHA256(key, "kms")
+	key = hmacSHA256(key, "aws4_request")
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%x",
+		p.keyID, scope, signed, hmacSHA256(key, toSign)))
+}
+
+func canonicalQuery(query url.Values) string {
+	return strings.Replace(query.Encode(), "+", "%20", -1)
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,259 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package kms
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	ethereum "github.com/wanchain/go-wanchain"
+	"github.com/wanchain/go-wanchain/accounts"
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/crypto"
+	"github.com/wanchain/go-wanchain/event"
+	"github.com/wanchain/go-wanchain/log"
+)
+
+// Backend is an account backend exposing one wallet per configured key.
+type Backend struct {
+	wallets []accounts.Wallet
+	feed    event.Feed
+}
+
+// NewBackend creates the backend of the given keys, signing with the providers
+// of the same name.
+func NewBackend(keys []KeyConfig, providers map[string]Provider) (*Backend, error) {
+	b := new(Backend)
+	for _, key := range keys {
+		provider, ok := providers[key.Provider]
+		if !ok {
+			return nil, fmt.Errorf("kms: unknown provider %q for key %s", key.Provider, key.KeyID)
+		}
+		b.wallets = append(b.wallets, newWallet(provider, key))
+	}
+	sort.Slice(b.wallets, func(i, j int) bool { return b.wallets[i].URL().Cmp(b.wallets[j].URL()) < 0 })
+	return b, nil
+}
+
+// NewProviders creates the providers of the supported services configured in
+// the environment, see NewAWSProvider, NewGCPProvider and NewVaultProvider.
+func NewProviders() map[string]Provider {
+	providers := make(map[string]Provider)
+	for _, p := range []Provider{NewAWSProvider(), NewGCPProvider(), NewVaultProvider()} {
+		providers[p.Name()] = p
+	}
+	return providers
+}
+
+// Wallets implements accounts.Backend, returning the wallets of the keys.
+func (b *Backend) Wallets() []accounts.Wallet {
+	return b.wallets
+}
+
+// Subscribe implements accounts.Backend. The keys are configured statically, no
+// wallet ever arrives nor departs.
+func (b *Backend) Subscribe(sink chan<- accounts.WalletEvent) event.Subscription {
+	return b.feed.Subscribe(sink)
+}
+
+// wallet is a key held by a key management service.
+type wallet struct {
+	provider Provider
+	config   KeyConfig
+	url      accounts.URL
+
+	lock    sync.Mutex
+	pubkey  *ecdsa.PublicKey // Public key of the key, cached once retrieved
+	address common.Address   // Address of the public key
+	err     error            // Failure retrieving the public key, reported by Status
+}
+
+func newWallet(provider Provider, config KeyConfig) *wallet {
+	return &wallet{
+		provider: provider,
+		config:   config,
+		url:      accounts.URL{Scheme: provider.Name(), Path: config.KeyID},
+	}
+}
+
+// key returns the public key, retrieving it from the service on first use.
+func (w *wallet) key() (*ecdsa.PublicKey, common.Address, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.pubkey != nil {
+		return w.pubkey, w.address, nil
+	}
+	der, err := w.provider.PublicKey(w.config.KeyID)
+	if err != nil {
+		w.err = fmt.Errorf("kms: failed to retrieve public key: %v", err)
+		return nil, common.Address{}, w.err
+	}
+	pub, err := parsePublicKey(der)
+	if err != nil {
+		w.err = fmt.Errorf("kms: %v", err)
+		return nil, common.Address{}, w.err
+	}
+	address := crypto.PubkeyToAddress(*pub)
+	if w.config.Address != nil && *w.config.Address != address {
+		w.err = fmt.Errorf("kms: key address mismatch: have %x, want %x", address, *w.config.Address)
+		return nil, common.Address{}, w.err
+	}
+	w.pubkey, w.address, w.err = pub, address, nil
+	return pub, address, nil
+}
+
+// URL implements accounts.Wallet, returning the provider and key identifier.
+func (w *wallet) URL() accounts.URL {
+	return w.url
+}
+
+// Status implements accounts.Wallet, reporting whether the public key could be
+// retrieved from the service.
+func (w *wallet) Status() (string, error) {
+	if _, _, err := w.key(); err != nil {
+		return "Unavailable", err
+	}
+	return "Online", nil
+}
+
+// Open implements accounts.Wallet, retrieving the public key. The passphrase is
+// not used, the services authenticate the node from its environment.
+func (w *wallet) Open(passphrase string) error {
+	_, _, err := w.key()
+	return err
+}
+
+// Close implements accounts.Wallet, there are no resources to release.
+func (w *wallet) Close() error {
+	return nil
+}
+
+// Accounts implements accounts.Wallet, returning the account of the key, none
+// if its public key cannot be retrieved.
+func (w *wallet) Accounts() []accounts.Account {
+	_, address, err := w.key()
+	if err != nil {
+		log.Warn("KMS key unavailable", "url", w.url, "err", err)
+		return nil
+	}
+	return []accounts.Account{{Address: address, URL: w.url}}
+}
+
+// Contains implements accounts.Wallet, returning whether the account is the
+// one of the key.
+func (w *wallet) Contains(account accounts.Account) bool {
+	_, address, err := w.key()
+	return err == nil && account.Address == address && (account.URL == (accounts.URL{}) || account.URL == w.url)
+}
+
+// Derive implements accounts.Wallet, but is not supported by single keys.
+func (w *wallet) Derive(path accounts.DerivationPath, pin bool) (accounts.Account, error) {
+	return accounts.Account{}, accounts.ErrNotSupported
+}
+
+// SelfDerive implements accounts.Wallet, but is a noop for single keys.
+func (w *wallet) SelfDerive(base accounts.DerivationPath, chain ethereum.ChainStateReader) {}
+
+// sign signs a digest with the key, returning the signature in the [R || S || V]
+// form.
+func (w *wallet) sign(account accounts.Account, digest []byte) ([]byte, error) {
+	if !w.Contains(account) {
+		return nil, accounts.ErrUnknownAccount
+	}
+	pub, _, err := w.key()
+	if err != nil {
+		return nil, err
+	}
+	der, err := w.provider.Sign(w.config.KeyID, digest)
+	if err != nil {
+		return nil, fmt.Errorf("kms: signing failed: %v", err)
+	}
+	sig, err := recoverableSignature(digest, der, pub)
+	if err != nil {
+		return nil, fmt.Errorf("kms: %v", err)
+	}
+	return sig, nil
+}
+
+// SignHash implements accounts.Wallet, signing the hash if the key policy allows
+// raw hash signing.
+func (w *wallet) SignHash(account accounts.Account, hash []byte) ([]byte, error) {
+	if !w.config.Policy.AllowHashSigning {
+		return nil, ErrHashSigningDisabled
+	}
+	return w.sign(account, hash)
+}
+
+// SignTx implements accounts.Wallet, signing the transaction if the key policy
+// allows it. Both plain and privacy transactions are signed, the ring signature
+// a privacy transaction carries being made beforehand by its stamp owner.
+func (w *wallet) SignTx(account accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	if !types.IsValidTransactionType(tx.Txtype()) {
+		return nil, fmt.Errorf("kms: unsupported transaction type %d", tx.Txtype())
+	}
+	if err := w.config.Policy.check(tx, chainID); err != nil {
+		return nil, err
+	}
+	var signer types.Signer = types.HomesteadSigner{}
+	if chainID != nil {
+		signer = types.NewEIP155Signer(chainID)
+	}
+	hash := signer.Hash(tx)
+	sig, err := w.sign(account, hash[:])
+	if err != nil {
+		return nil, err
+	}
+	return tx.WithSignature(signer, sig)
+}
+
+// SignHashWithPassphrase implements accounts.Wallet, the passphrase is ignored.
+func (w *wallet) SignHashWithPassphrase(account accounts.Account, passphrase string, hash []byte) ([]byte, error) {
+	return w.SignHash(account, hash)
+}
+
+// SignTxWithPassphrase implements accounts.Wallet, the passphrase is ignored.
+func (w *wallet) SignTxWithPassphrase(account accounts.Account, passphrase string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return w.SignTx(account, tx, chainID)
+}
+
+// GetWanAddress implements accounts.Wallet, but the wan address derivation needs
+// the private key.
+func (w *wallet) GetWanAddress(account accounts.Account) (common.WAddress, error) {
+	return common.WAddress{}, ErrRingSignUnsupported
+}
+
+// ComputeOTAPPKeys implements accounts.Wallet, but the one-time address keys
+// derivation needs the private key.
+func (w *wallet) ComputeOTAPPKeys(account accounts.Account, AX, AY, BX, BY string) ([]string, error) {
+	return nil, ErrRingSignUnsupported
+}
+
+// check returns the policy violation of signing the transaction, if any.
+func (p *Policy) check(tx *types.Transaction, chainID *big.Int) error {
+	if p.ChainID != nil && (chainID == nil || chainID.Cmp(p.ChainID) != 0) {
+		return fmt.Errorf("kms: policy requires chain id %v, have %v", p.ChainID, chainID)
+	}
+	if p.MaxValue != nil && tx.Value().Cmp(p.MaxValue) > 0 {
+		return fmt.Errorf("kms: value %v above policy limit %v", tx.Value(), p.MaxValue)
+	}
+	if tx.To() == nil {
+		if p.NoContractCreate {
+			return fmt.Errorf("kms: contract creation forbidden by policy")
+		}
+		return nil
+	}
+	if len(p.AllowedTo) == 0 {
+		return nil
+	}
+	for _, to := range p.AllowedTo {
+		if *tx.To() == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("kms: recipient %x not allowed by policy", *tx.To())
+}
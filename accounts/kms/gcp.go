@@ -0,0 +1,85 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package kms
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// gcpProvider signs with Google Cloud KMS keys of the EC_SIGN_SECP256K1_SHA256
+// algorithm, authenticating with an OAuth2 access token.
+type gcpProvider struct {
+	endpoint string // Cloud KMS endpoint, https://cloudkms.googleapis.com by default
+	token    string // OAuth2 access token
+}
+
+// NewGCPProvider creates the Google Cloud KMS provider, authenticated by the
+// access token in GOOGLE_OAUTH_ACCESS_TOKEN, e.g. set from the output of
+// "gcloud auth print-access-token". GCP_KMS_ENDPOINT overrides the endpoint.
+func NewGCPProvider() Provider {
+	p := &gcpProvider{
+		endpoint: os.Getenv("GCP_KMS_ENDPOINT"),
+		token:    os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"),
+	}
+	if p.endpoint == "" {
+		p.endpoint = "https://cloudkms.googleapis.com"
+	}
+	return p
+}
+
+// Name implements Provider.
+func (p *gcpProvider) Name() string { return "gcp" }
+
+// PublicKey implements Provider, the key identifier being the resource name of
+// a crypto key version:
+// projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>/cryptoKeyVersions/<version>.
+func (p *gcpProvider) PublicKey(keyID string) ([]byte, error) {
+	var result struct {
+		Pem string `json:"pem"`
+	}
+	if err := p.call("GET", keyID+"/publicKey", nil, &result); err != nil {
+		return nil, err
+	}
+	return []byte(result.Pem), nil
+}
+
+// Sign implements Provider.
+func (p *gcpProvider) Sign(keyID string, digest []byte) ([]byte, error) {
+	var result struct {
+		Signature []byte `json:"signature"`
+	}
+	args := map[string]interface{}{
+		"digest": map[string][]byte{"sha256": digest},
+	}
+	if err := p.call("POST", keyID+":asymmetricSign", args, &result); err != nil {
+		return nil, err
+	}
+	return result.Signature, nil
+}
+
+// call invokes a Cloud KMS method on a resource.
+func (p *gcpProvider) call(method, resource string, args interface{}, result interface{}) error {
+	if p.token == "" {
+		return errors.New("Google Cloud access token not configured")
+	}
+	var body []byte
+	if args != nil {
+		var err error
+		if body, err = json.Marshal(args); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, p.endpoint+"/v1/"+strings.TrimPrefix(resource, "/"), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return doJSON(req, body, result)
+}
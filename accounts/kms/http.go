@@ -0,0 +1,42 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package kms
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// httpClient is the client of the key management service requests.
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// doJSON sends a request with a JSON body, if any, and decodes the JSON reply
+// into result. The services report their errors in the reply body.
+func doJSON(req *http.Request, body []byte, result interface{}) error {
+	if body != nil {
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	blob, err := ioutil.ReadAll(io.LimitReader(res.Body, 1024*1024))
+	if err != nil {
+		return err
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		if len(blob) > 256 {
+			blob = blob[:256]
+		}
+		return fmt.Errorf("%s: %s", res.Status, bytes.TrimSpace(blob))
+	}
+	return json.Unmarshal(blob, result)
+}
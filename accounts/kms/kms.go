@@ -0,0 +1,138 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+// Package kms implements an account backend signing with keys held by external
+// key management services: AWS KMS, Google Cloud KMS and HashiCorp Vault. The
+// key material never leaves the service, the node only ever sees the public
+// keys and the signatures of the digests it submits.
+//
+// The services sign with plain ECDSA over secp256k1, so the one-time address
+// and ring signature operations of the privacy transactions, which need the
+// private key itself, are not available to these accounts.
+package kms
+
+import (
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/crypto"
+)
+
+var (
+	// ErrRingSignUnsupported is returned for the privacy operations needing the
+	// private key, unavailable to a key held by a key management service.
+	ErrRingSignUnsupported = errors.New("kms: one-time address and ring signature operations need the private key, use a keystore account for privacy transactions")
+
+	// ErrHashSigningDisabled is returned when signing a raw hash with a key whose
+	// policy only allows signing transactions.
+	ErrHashSigningDisabled = errors.New("kms: raw hash signing disabled by the key policy, set AllowHashSigning to enable it")
+)
+
+// Provider is a key management service holding secp256k1 keys.
+type Provider interface {
+	// Name returns the name of the service, used as the scheme of the wallet URLs.
+	Name() string
+
+	// PublicKey retrieves the public key of a key, DER encoded as a PKIX
+	// SubjectPublicKeyInfo.
+	PublicKey(keyID string) ([]byte, error)
+
+	// Sign signs a 32 bytes digest with a key, returning the DER encoded ECDSA
+	// signature.
+	Sign(keyID string, digest []byte) ([]byte, error)
+}
+
+// KeyConfig is a key held by a key management service and its signing policy.
+type KeyConfig struct {
+	Provider string          // Service holding the key: aws, gcp or vault
+	KeyID    string          // Key identifier within the service
+	Address  *common.Address `toml:",omitempty"` // Expected address of the key, checked against the service
+	Policy   Policy          `toml:",omitempty"`
+}
+
+// Policy restricts the requests a key signs, checked before reaching the
+// service. Zero values don't restrict.
+type Policy struct {
+	ChainID          *big.Int         `toml:",omitempty"` // Chain the transactions must be replay protected for
+	MaxValue         *big.Int         `toml:",omitempty"` // Value a transaction may transfer
+	AllowedTo        []common.Address `toml:",omitempty"` // Recipients a transaction may be sent to
+	NoContractCreate bool             `toml:",omitempty"` // Forbid contract creations
+	AllowHashSigning bool             `toml:",omitempty"` // Allow signing raw hashes, which may be transactions
+}
+
+var (
+	oidPublicKeyECDSA  = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidNamedCurveS256K = asn1.ObjectIdentifier{1, 3, 132, 0, 10}
+)
+
+// subjectPublicKeyInfo is the PKIX encoding of a public key, not parsed by the
+// standard library for the secp256k1 curve.
+type subjectPublicKeyInfo struct {
+	Algorithm struct {
+		Algorithm  asn1.ObjectIdentifier
+		Parameters asn1.ObjectIdentifier
+	}
+	PublicKey asn1.BitString
+}
+
+// ecdsaSignature is the DER encoding of an ECDSA signature.
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// parsePublicKey decodes a secp256k1 public key, DER or PEM encoded as a PKIX
+// SubjectPublicKeyInfo.
+func parsePublicKey(der []byte) (*ecdsa.PublicKey, error) {
+	if block, _ := pem.Decode(der); block != nil {
+		der = block.Bytes
+	}
+	var spki subjectPublicKeyInfo
+	if rest, err := asn1.Unmarshal(der, &spki); err != nil {
+		return nil, fmt.Errorf("invalid public key: %v", err)
+	} else if len(rest) > 0 {
+		return nil, errors.New("invalid public key: trailing data")
+	}
+	if !spki.Algorithm.Algorithm.Equal(oidPublicKeyECDSA) || !spki.Algorithm.Parameters.Equal(oidNamedCurveS256K) {
+		return nil, fmt.Errorf("unsupported key type %v/%v, want an ECC secp256k1 key", spki.Algorithm.Algorithm, spki.Algorithm.Parameters)
+	}
+	pub := crypto.ToECDSAPub(spki.PublicKey.RightAlign())
+	if pub == nil || pub.X == nil {
+		return nil, errors.New("invalid secp256k1 public key")
+	}
+	return pub, nil
+}
+
+// recoverableSignature converts the DER encoded signature of a digest into the
+// [R || S || V] form, normalizing S to the lower half of the curve order and
+// finding the recovery identifier of the public key.
+func recoverableSignature(digest, der []byte, pub *ecdsa.PublicKey) ([]byte, error) {
+	var sig ecdsaSignature
+	if rest, err := asn1.Unmarshal(der, &sig); err != nil {
+		return nil, fmt.Errorf("invalid signature: %v", err)
+	} else if len(rest) > 0 {
+		return nil, errors.New("invalid signature: trailing data")
+	}
+	n := crypto.S256().Params().N
+	if sig.R == nil || sig.S == nil || sig.R.Sign() <= 0 || sig.S.Sign() <= 0 || sig.R.Cmp(n) >= 0 || sig.S.Cmp(n) >= 0 {
+		return nil, errors.New("invalid signature values")
+	}
+	if sig.S.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		sig.S = new(big.Int).Sub(n, sig.S)
+	}
+	rs := make([]byte, 65)
+	copy(rs[32-len(sig.R.Bytes()):32], sig.R.Bytes())
+	copy(rs[64-len(sig.S.Bytes()):64], sig.S.Bytes())
+
+	want := crypto.FromECDSAPub(pub)
+	for v := byte(0); v < 2; v++ {
+		rs[64] = v
+		if recovered, err := crypto.Ecrecover(digest, rs); err == nil && string(recovered) == string(want) {
+			return rs, nil
+		}
+	}
+	return nil, errors.New("signature not made by the key")
+}
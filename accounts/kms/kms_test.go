@@ -0,0 +1,267 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package kms
+
+import (
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/accounts"
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/crypto"
+)
+
+// testKey is a secp256k1 key as held by a key management service.
+type testKey struct {
+	key    *ecdsa.PrivateKey
+	highS  bool // Whether to return the high S form of the signatures
+	signed int
+}
+
+func newTestKey(t *testing.T) *testKey {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testKey{key: key}
+}
+
+func (k *testKey) address() common.Address {
+	return crypto.PubkeyToAddress(k.key.PublicKey)
+}
+
+// spki returns the DER encoded SubjectPublicKeyInfo of the key.
+func (k *testKey) spki() []byte {
+	var spki subjectPublicKeyInfo
+	spki.Algorithm.Algorithm = oidPublicKeyECDSA
+	spki.Algorithm.Parameters = oidNamedCurveS256K
+	pub := crypto.FromECDSAPub(&k.key.PublicKey)
+	spki.PublicKey = asn1.BitString{Bytes: pub, BitLength: 8 * len(pub)}
+
+	der, _ := asn1.Marshal(spki)
+	return der
+}
+
+// sign returns the DER encoded signature of a digest, without recovery id.
+func (k *testKey) sign(digest []byte) []byte {
+	k.signed++
+	sig, _ := crypto.Sign(digest, k.key)
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:64])
+	if k.highS {
+		s.Sub(crypto.S256().Params().N, s)
+	}
+	der, _ := asn1.Marshal(ecdsaSignature{r, s})
+	return der
+}
+
+// newVaultServer serves the key as the transit key "test".
+func newVaultServer(t *testing.T, key *testKey) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/transit/keys/test":
+			pub := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: key.spki()})
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{
+				"latest_version": 2,
+				"keys":           map[string]interface{}{"2": map[string]string{"public_key": string(pub)}},
+			}})
+		case "/v1/transit/sign/test":
+			var args struct {
+				Input     string `json:"input"`
+				Prehashed bool   `json:"prehashed"`
+			}
+			json.NewDecoder(r.Body).Decode(&args)
+			digest, _ := base64.StdEncoding.DecodeString(args.Input)
+			if !args.Prehashed || len(digest) != 32 {
+				http.Error(w, `{"errors":["invalid input"]}`, http.StatusBadRequest)
+				return
+			}
+			sig := "vault:v2:" + base64.StdEncoding.EncodeToString(key.sign(digest))
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]string{"signature": sig}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+// Tests that transactions of the standard types are signed by the service key,
+// whichever half of the curve order its signatures are in, and that the privacy
+// operations needing the private key are rejected.
+func TestWalletSignTx(t *testing.T) {
+	key := newTestKey(t)
+	server := newVaultServer(t, key)
+	defer server.Close()
+
+	providers := map[string]Provider{"vault": &vaultProvider{addr: server.URL, token: "token", mount: "transit"}}
+	backend, err := NewBackend([]KeyConfig{{Provider: "vault", KeyID: "test"}}, providers)
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	wallet := backend.Wallets()[0]
+	if err := wallet.Open(""); err != nil {
+		t.Fatalf("failed to open wallet: %v", err)
+	}
+	accs := wallet.Accounts()
+	if len(accs) != 1 || accs[0].Address != key.address() {
+		t.Fatalf("accounts mismatch: have %v, want %x", accs, key.address())
+	}
+	if url := wallet.URL().String(); url != "vault://test" {
+		t.Errorf("wallet URL mismatch: have %s, want vault://test", url)
+	}
+	to := common.HexToAddress("0x01")
+	for _, highS := range []bool{false, true} {
+		key.highS = highS
+		for _, tx := range []*types.Transaction{
+			types.NewTransaction(0, to, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil),
+			types.NewOTATransaction(0, to, nil, big.NewInt(200000), big.NewInt(1), []byte{1}),
+		} {
+			signed, err := wallet.SignTx(accs[0], tx, big.NewInt(3))
+			if err != nil {
+				t.Fatalf("high S %v, type %d: failed to sign: %v", highS, tx.Txtype(), err)
+			}
+			if from, err := types.Sender(types.NewEIP155Signer(big.NewInt(3)), signed); err != nil || from != key.address() {
+				t.Fatalf("high S %v, type %d: sender mismatch: have %x (%v), want %x", highS, tx.Txtype(), from, err, key.address())
+			}
+		}
+	}
+	// The public key is cached, raw hashes and privacy operations are refused
+	if _, err := wallet.SignHash(accs[0], make([]byte, 32)); err != ErrHashSigningDisabled {
+		t.Errorf("hash signing error mismatch: have %v, want %v", err, ErrHashSigningDisabled)
+	}
+	if _, err := wallet.GetWanAddress(accs[0]); err != ErrRingSignUnsupported {
+		t.Errorf("wan address error mismatch: have %v, want %v", err, ErrRingSignUnsupported)
+	}
+	if _, err := wallet.ComputeOTAPPKeys(accs[0], "", "", "", ""); err != ErrRingSignUnsupported {
+		t.Errorf("OTA keys error mismatch: have %v, want %v", err, ErrRingSignUnsupported)
+	}
+	if _, err := wallet.SignTx(accounts.Account{Address: to}, types.NewTransaction(0, to, nil, nil, nil, nil), nil); err != accounts.ErrUnknownAccount {
+		t.Errorf("unknown account error mismatch: have %v, want %v", err, accounts.ErrUnknownAccount)
+	}
+}
+
+// Tests that the wallet refuses a key whose address isn't the configured one.
+func TestWalletAddressMismatch(t *testing.T) {
+	key := newTestKey(t)
+	server := newVaultServer(t, key)
+	defer server.Close()
+
+	wrong := common.HexToAddress("0x02")
+	wallet := newWallet(&vaultProvider{addr: server.URL, token: "token", mount: "transit"}, KeyConfig{KeyID: "test", Address: &wrong})
+	if err := wallet.Open(""); err == nil || !strings.Contains(err.Error(), "mismatch") {
+		t.Fatalf("address mismatch not detected: %v", err)
+	}
+	if accs := wallet.Accounts(); len(accs) != 0 {
+		t.Fatalf("mismatching key exposed: %v", accs)
+	}
+}
+
+// Tests that the signing policy is enforced before reaching the service.
+func TestWalletPolicy(t *testing.T) {
+	key := newTestKey(t)
+	server := newVaultServer(t, key)
+	defer server.Close()
+
+	allowed, other := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+	policy := Policy{ChainID: big.NewInt(3), MaxValue: big.NewInt(100), AllowedTo: []common.Address{allowed}, NoContractCreate: true}
+	wallet := newWallet(&vaultProvider{addr: server.URL, token: "token", mount: "transit"}, KeyConfig{KeyID: "test", Policy: policy})
+	account := accounts.Account{Address: key.address()}
+
+	tests := []struct {
+		tx      *types.Transaction
+		chainID *big.Int
+		ok      bool
+	}{
+		{types.NewTransaction(0, allowed, big.NewInt(100), big.NewInt(21000), big.NewInt(1), nil), big.NewInt(3), true},
+		{types.NewTransaction(0, allowed, big.NewInt(100), big.NewInt(21000), big.NewInt(1), nil), nil, false},
+		{types.NewTransaction(0, allowed, big.NewInt(100), big.NewInt(21000), big.NewInt(1), nil), big.NewInt(1), false},
+		{types.NewTransaction(0, allowed, big.NewInt(101), big.NewInt(21000), big.NewInt(1), nil), big.NewInt(3), false},
+		{types.NewTransaction(0, other, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil), big.NewInt(3), false},
+		{types.NewContractCreation(0, big.NewInt(0), big.NewInt(100000), big.NewInt(1), nil), big.NewInt(3), false},
+	}
+	for i, tt := range tests {
+		signed := key.signed
+		_, err := wallet.SignTx(account, tt.tx, tt.chainID)
+		if tt.ok && err != nil {
+			t.Errorf("test %d: allowed transaction refused: %v", i, err)
+		}
+		if !tt.ok && (err == nil || key.signed != signed) {
+			t.Errorf("test %d: forbidden transaction reached the service: %v", i, err)
+		}
+	}
+}
+
+// Tests the requests of the AWS and Google Cloud providers against fake
+// services.
+func TestProviders(t *testing.T) {
+	key := newTestKey(t)
+
+	aws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(auth, "/eu-west-1/kms/aws4_request") {
+			http.Error(w, `{"message":"invalid signature"}`, http.StatusForbidden)
+			return
+		}
+		var args struct {
+			KeyId   string
+			Message []byte
+		}
+		json.NewDecoder(r.Body).Decode(&args)
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.GetPublicKey":
+			json.NewEncoder(w).Encode(map[string]interface{}{"KeyId": args.KeyId, "PublicKey": key.spki()})
+		case "TrentService.Sign":
+			json.NewEncoder(w).Encode(map[string]interface{}{"KeyId": args.KeyId, "Signature": key.sign(args.Message)})
+		}
+	}))
+	defer aws.Close()
+
+	gcp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, `{"error":"unauthenticated"}`, http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/publicKey"):
+			pub := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: key.spki()})
+			json.NewEncoder(w).Encode(map[string]string{"pem": string(pub)})
+		case strings.HasSuffix(r.URL.Path, ":asymmetricSign"):
+			var args struct {
+				Digest struct {
+					Sha256 []byte `json:"sha256"`
+				} `json:"digest"`
+			}
+			json.NewDecoder(r.Body).Decode(&args)
+			json.NewEncoder(w).Encode(map[string][]byte{"signature": key.sign(args.Digest.Sha256)})
+		}
+	}))
+	defer gcp.Close()
+
+	providers := []Provider{
+		&awsProvider{endpoint: aws.URL, region: "eu-west-1", keyID: "AKID", secret: "secret"},
+		&gcpProvider{endpoint: gcp.URL, token: "token"},
+	}
+	for _, provider := range providers {
+		wallet := newWallet(provider, KeyConfig{KeyID: "projects/p/keys/k", Policy: Policy{AllowHashSigning: true}})
+		account := accounts.Account{Address: key.address()}
+
+		hash := crypto.Keccak256([]byte("hash"))
+		sig, err := wallet.SignHash(account, hash)
+		if err != nil {
+			t.Fatalf("%s: failed to sign: %v", provider.Name(), err)
+		}
+		if pub, err := crypto.SigToPub(hash, sig); err != nil || crypto.PubkeyToAddress(*pub) != key.address() {
+			t.Fatalf("%s: signer mismatch: %v", provider.Name(), err)
+		}
+	}
+}
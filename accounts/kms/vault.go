@@ -0,0 +1,108 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package kms
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// vaultProvider signs with the keys of a HashiCorp Vault transit secrets engine.
+// The built-in engine has no secp256k1 keys, the mount must be served by a
+// transit compatible plugin supporting them.
+type vaultProvider struct {
+	addr  string // Vault server address
+	token string // Vault token
+	mount string // Mount path of the transit engine
+}
+
+// NewVaultProvider creates the Vault provider, configured by the standard
+// VAULT_ADDR and VAULT_TOKEN environment variables. VAULT_TRANSIT_PATH sets the
+// mount path of the transit engine, transit by default.
+func NewVaultProvider() Provider {
+	p := &vaultProvider{
+		addr:  strings.TrimSuffix(os.Getenv("VAULT_ADDR"), "/"),
+		token: os.Getenv("VAULT_TOKEN"),
+		mount: strings.Trim(os.Getenv("VAULT_TRANSIT_PATH"), "/"),
+	}
+	if p.mount == "" {
+		p.mount = "transit"
+	}
+	return p
+}
+
+// Name implements Provider.
+func (p *vaultProvider) Name() string { return "vault" }
+
+// PublicKey implements Provider, the key identifier being the transit key name.
+// The public key of the latest key version is returned.
+func (p *vaultProvider) PublicKey(keyID string) ([]byte, error) {
+	var result struct {
+		Data struct {
+			LatestVersion int `json:"latest_version"`
+			Keys          map[string]struct {
+				PublicKey string `json:"public_key"`
+			} `json:"keys"`
+		} `json:"data"`
+	}
+	if err := p.call("GET", "keys/"+keyID, nil, &result); err != nil {
+		return nil, err
+	}
+	key, ok := result.Data.Keys[strconv.Itoa(result.Data.LatestVersion)]
+	if !ok || key.PublicKey == "" {
+		return nil, fmt.Errorf("no public key for version %d", result.Data.LatestVersion)
+	}
+	return []byte(key.PublicKey), nil
+}
+
+// Sign implements Provider.
+func (p *vaultProvider) Sign(keyID string, digest []byte) ([]byte, error) {
+	var result struct {
+		Data struct {
+			Signature string `json:"signature"`
+		} `json:"data"`
+	}
+	args := map[string]interface{}{
+		"input":                base64.StdEncoding.EncodeToString(digest),
+		"prehashed":            true,
+		"marshaling_algorithm": "asn1",
+	}
+	if err := p.call("POST", "sign/"+keyID, args, &result); err != nil {
+		return nil, err
+	}
+	// Signatures are formatted as vault:v<version>:<base64>
+	parts := strings.Split(result.Data.Signature, ":")
+	if len(parts) != 3 || parts[0] != "vault" {
+		return nil, fmt.Errorf("invalid signature format %q", result.Data.Signature)
+	}
+	return base64.StdEncoding.DecodeString(parts[2])
+}
+
+// call invokes a transit engine endpoint.
+func (p *vaultProvider) call(method, path string, args interface{}, result interface{}) error {
+	if p.addr == "" || p.token == "" {
+		return errors.New("Vault address or token not configured")
+	}
+	var body []byte
+	if args != nil {
+		var err error
+		if body, err = json.Marshal(args); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, p.addr+"/v1/"+p.mount+"/"+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", p.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return doJSON(req, body, result)
+}
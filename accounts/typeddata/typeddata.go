@@ -0,0 +1,416 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+// Package typeddata implements the hashing of EIP-712 typed structured data,
+// signed by the wallets in place of opaque hashes so that users can review
+// what they sign.
+package typeddata
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/common/hexutil"
+	"github.com/wanchain/go-wanchain/common/math"
+	"github.com/wanchain/go-wanchain/crypto"
+)
+
+// DomainType is the name of the type of the signing domain.
+const DomainType = "EIP712Domain"
+
+var (
+	ErrNoChainId     = errors.New("typed data domain has no chain id")
+	ErrChainMismatch = errors.New("typed data domain bound to another chain")
+
+	nameRegexp   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	arrayRegexp  = regexp.MustCompile(`^(.*)\[([0-9]*)\]$`)
+	atomicRegexp = regexp.MustCompile(`^(address|bool|string|bytes([1-9]|[12][0-9]|3[0-2])?|u?int(8|16|24|32|40|48|56|64|72|80|88|96|104|112|120|128|136|144|152|160|168|176|184|192|200|208|216|224|232|240|248|256))$`)
+)
+
+// Field is a member of a struct type.
+type Field struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Types are the struct types of the typed data, keyed by name.
+type Types map[string][]Field
+
+// Domain identifies the dApp, contract and chain a signature is valid for.
+type Domain struct {
+	Name              string   `json:"name,omitempty"`
+	Version           string   `json:"version,omitempty"`
+	ChainId           *ChainId `json:"chainId,omitempty"`
+	VerifyingContract string   `json:"verifyingContract,omitempty"`
+	Salt              string   `json:"salt,omitempty"`
+}
+
+// ChainId is the chain id of a domain, decoded from a JSON number or from a
+// hex or decimal string.
+type ChainId big.Int
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (c *ChainId) UnmarshalJSON(input []byte) error {
+	if len(input) > 1 && input[0] == '"' && input[len(input)-1] == '"' {
+		return (*math.HexOrDecimal256)(c).UnmarshalText(input[1 : len(input)-1])
+	}
+	id, ok := new(big.Int).SetString(string(input), 10)
+	if !ok || id.Sign() < 0 {
+		return fmt.Errorf("invalid chain id %s", input)
+	}
+	*c = ChainId(*id)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (c *ChainId) MarshalJSON() ([]byte, error) {
+	return []byte((*big.Int)(c).String()), nil
+}
+
+// TypedData is a message of struct type PrimaryType, signed within Domain.
+type TypedData struct {
+	Types       Types                  `json:"types"`
+	PrimaryType string                 `json:"primaryType"`
+	Domain      Domain                 `json:"domain"`
+	Message     map[string]interface{} `json:"message"`
+}
+
+// values returns the domain fields as a message of the domain type.
+func (d *Domain) values() map[string]interface{} {
+	values := make(map[string]interface{})
+	if d.Name != "" {
+		values["name"] = d.Name
+	}
+	if d.Version != "" {
+		values["version"] = d.Version
+	}
+	if d.ChainId != nil {
+		values["chainId"] = (*big.Int)(d.ChainId)
+	}
+	if d.VerifyingContract != "" {
+		values["verifyingContract"] = d.VerifyingContract
+	}
+	if d.Salt != "" {
+		values["salt"] = d.Salt
+	}
+	return values
+}
+
+// CheckChain ensures the domain is bound to the chain with the given id,
+// preventing the replay of signatures across chains.
+func (td *TypedData) CheckChain(chainId *big.Int) error {
+	if td.Domain.ChainId == nil {
+		return ErrNoChainId
+	}
+	if (*big.Int)(td.Domain.ChainId).Cmp(chainId) != 0 {
+		return ErrChainMismatch
+	}
+	return nil
+}
+
+// Validate checks that all the referenced types are defined.
+func (td *TypedData) Validate() error {
+	if _, ok := td.Types[DomainType]; !ok {
+		return fmt.Errorf("type %s undefined", DomainType)
+	}
+	if _, ok := td.Types[td.PrimaryType]; !ok {
+		return fmt.Errorf("primary type %q undefined", td.PrimaryType)
+	}
+	for name, fields := range td.Types {
+		if !nameRegexp.MatchString(name) || isAtomic(name) {
+			return fmt.Errorf("invalid type name %q", name)
+		}
+		for _, field := range fields {
+			if field.Name == "" {
+				return fmt.Errorf("type %s has an unnamed field", name)
+			}
+			base := field.Type
+			for {
+				match := arrayRegexp.FindStringSubmatch(base)
+				if match == nil {
+					break
+				}
+				base = match[1]
+			}
+			if _, ok := td.Types[base]; !ok && !isAtomic(base) {
+				return fmt.Errorf("type %s field %s has undefined type %q", name, field.Name, field.Type)
+			}
+		}
+	}
+	return nil
+}
+
+// Hash returns the hash signed for the typed data:
+//
+//	keccak256("\x19\x01" ‖ domainSeparator ‖ hashStruct(message))
+func (td *TypedData) Hash() (common.Hash, error) {
+	if err := td.Validate(); err != nil {
+		return common.Hash{}, err
+	}
+	domain, err := td.HashStruct(DomainType, td.Domain.values())
+	if err != nil {
+		return common.Hash{}, err
+	}
+	message, err := td.HashStruct(td.PrimaryType, td.Message)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash([]byte{0x19, 0x01}, domain[:], message[:]), nil
+}
+
+// HashStruct returns the hash of a message of the given struct type.
+func (td *TypedData) HashStruct(typ string, data map[string]interface{}) (common.Hash, error) {
+	encoded, err := td.EncodeData(typ, data, 1)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash(encoded), nil
+}
+
+// TypeHash returns the hash of the encoding of a struct type.
+func (td *TypedData) TypeHash(typ string) common.Hash {
+	return crypto.Keccak256Hash([]byte(td.EncodeType(typ)))
+}
+
+// EncodeType encodes a struct type along with the struct types it references,
+// the latter sorted by name:
+//
+//	Mail(Person from,Person to,string contents)Person(string name,address wallet)
+func (td *TypedData) EncodeType(typ string) string {
+	deps := td.dependencies(typ, map[string]bool{})
+	sort.Strings(deps[1:])
+
+	var buf bytes.Buffer
+	for _, dep := range deps {
+		buf.WriteString(dep + "(")
+		for i, field := range td.Types[dep] {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString(field.Type + " " + field.Name)
+		}
+		buf.WriteString(")")
+	}
+	return buf.String()
+}
+
+// dependencies returns typ followed by the struct types it references.
+func (td *TypedData) dependencies(typ string, found map[string]bool) []string {
+	typ = baseType(typ)
+	if found[typ] {
+		return nil
+	}
+	if _, ok := td.Types[typ]; !ok {
+		return nil
+	}
+	found[typ] = true
+
+	deps := []string{typ}
+	for _, field := range td.Types[typ] {
+		deps = append(deps, td.dependencies(field.Type, found)...)
+	}
+	return deps
+}
+
+// EncodeData encodes a message of the given struct type as its type hash
+// followed by the 32 byte encoding of each of its fields.
+func (td *TypedData) EncodeData(typ string, data map[string]interface{}, depth int) ([]byte, error) {
+	fields, ok := td.Types[typ]
+	if !ok {
+		return nil, fmt.Errorf("type %q undefined", typ)
+	}
+	for name := range data {
+		if !hasField(fields, name) {
+			return nil, fmt.Errorf("%s message has unknown field %s", typ, name)
+		}
+	}
+	if depth > 32 {
+		return nil, fmt.Errorf("%s message nested too deep", typ)
+	}
+	typeHash := td.TypeHash(typ)
+	buf := bytes.NewBuffer(typeHash[:])
+	for _, field := range fields {
+		value, ok := data[field.Name]
+		if !ok {
+			return nil, fmt.Errorf("%s message misses field %s", typ, field.Name)
+		}
+		encoded, err := td.encodeValue(field.Type, value, depth)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %v", typ, field.Name, err)
+		}
+		buf.Write(encoded)
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeValue encodes a field value into 32 bytes, hashing the dynamic values.
+func (td *TypedData) encodeValue(typ string, value interface{}, depth int) ([]byte, error) {
+	if match := arrayRegexp.FindStringSubmatch(typ); match != nil {
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%T is not an array", value)
+		}
+		if match[2] != "" {
+			if size, _ := strconv.Atoi(match[2]); size != len(items) {
+				return nil, fmt.Errorf("array has %d items, want %d", len(items), size)
+			}
+		}
+		var buf bytes.Buffer
+		for i, item := range items {
+			encoded, err := td.encodeValue(match[1], item, depth+1)
+			if err != nil {
+				return nil, fmt.Errorf("item %d: %v", i, err)
+			}
+			buf.Write(encoded)
+		}
+		return crypto.Keccak256(buf.Bytes()), nil
+	}
+	if _, ok := td.Types[typ]; ok {
+		data, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%T is not a %s struct", value, typ)
+		}
+		encoded, err := td.EncodeData(typ, data, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.Keccak256(encoded), nil
+	}
+	return encodeAtomic(typ, value)
+}
+
+// encodeAtomic encodes a value of an atomic type.
+func encodeAtomic(typ string, value interface{}) ([]byte, error) {
+	switch {
+	case typ == "string":
+		str, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%T is not a string", value)
+		}
+		return crypto.Keccak256([]byte(str)), nil
+
+	case typ == "bytes":
+		blob, err := parseBytes(value)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.Keccak256(blob), nil
+
+	case typ == "bool":
+		flag, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%T is not a bool", value)
+		}
+		if flag {
+			return math.PaddedBigBytes(common.Big1, 32), nil
+		}
+		return make([]byte, 32), nil
+
+	case typ == "address":
+		blob, err := parseBytes(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(blob) != common.AddressLength {
+			return nil, fmt.Errorf("address has %d bytes", len(blob))
+		}
+		return common.LeftPadBytes(blob, 32), nil
+
+	case isAtomic(typ) && strings.HasPrefix(typ, "bytes"):
+		size, _ := strconv.Atoi(typ[5:])
+		blob, err := parseBytes(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(blob) != size {
+			return nil, fmt.Errorf("%s value has %d bytes", typ, len(blob))
+		}
+		return common.RightPadBytes(blob, 32), nil
+
+	case isAtomic(typ) && strings.Contains(typ, "int"):
+		signed := strings.HasPrefix(typ, "int")
+		bits, _ := strconv.Atoi(strings.TrimLeft(typ, "uint"))
+
+		num, err := parseInteger(value)
+		if err != nil {
+			return nil, err
+		}
+		if signed {
+			limit := new(big.Int).Lsh(common.Big1, uint(bits-1))
+			if num.Cmp(new(big.Int).Neg(limit)) < 0 || num.Cmp(limit) >= 0 {
+				return nil, fmt.Errorf("%v overflows %s", num, typ)
+			}
+			return math.PaddedBigBytes(math.U256(new(big.Int).Set(num)), 32), nil
+		}
+		if num.Sign() < 0 || num.BitLen() > bits {
+			return nil, fmt.Errorf("%v overflows %s", num, typ)
+		}
+		return math.PaddedBigBytes(num, 32), nil
+	}
+	return nil, fmt.Errorf("unknown type %q", typ)
+}
+
+// isAtomic reports whether typ is a known atomic type.
+func isAtomic(typ string) bool {
+	return atomicRegexp.MatchString(typ)
+}
+
+func hasField(fields []Field, name string) bool {
+	for _, field := range fields {
+		if field.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// baseType strips the array dimensions of a type.
+func baseType(typ string) string {
+	if i := strings.Index(typ, "["); i >= 0 {
+		return typ[:i]
+	}
+	return typ
+}
+
+// parseBytes decodes a 0x prefixed hex string.
+func parseBytes(value interface{}) ([]byte, error) {
+	str, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("%T is not a hex string", value)
+	}
+	return hexutil.Decode(str)
+}
+
+// parseInteger decodes an integer given as a JSON number, or as a decimal or
+// 0x prefixed hex string for the values beyond the float64 precision.
+func parseInteger(value interface{}) (*big.Int, error) {
+	switch v := value.(type) {
+	case *big.Int:
+		return v, nil
+	case float64:
+		if v != float64(int64(v)) {
+			return nil, fmt.Errorf("%v is not an integer", v)
+		}
+		return big.NewInt(int64(v)), nil
+	case json.Number:
+		num, ok := new(big.Int).SetString(string(v), 10)
+		if !ok {
+			return nil, fmt.Errorf("%v is not an integer", v)
+		}
+		return num, nil
+	case string:
+		num, ok := math.ParseBig256(v)
+		if !ok || v == "" {
+			return nil, fmt.Errorf("%q is not an integer", v)
+		}
+		return num, nil
+	}
+	return nil, fmt.Errorf("%T is not an integer", value)
+}
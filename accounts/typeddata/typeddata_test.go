@@ -0,0 +1,119 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package typeddata
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/common"
+)
+
+// mail is the example message of the EIP-712 specification.
+const mail = `{
+	"types": {
+		"EIP712Domain": [
+			{"name": "name", "type": "string"},
+			{"name": "version", "type": "string"},
+			{"name": "chainId", "type": "uint256"},
+			{"name": "verifyingContract", "type": "address"}
+		],
+		"Person": [
+			{"name": "name", "type": "string"},
+			{"name": "wallet", "type": "address"}
+		],
+		"Mail": [
+			{"name": "from", "type": "Person"},
+			{"name": "to", "type": "Person"},
+			{"name": "contents", "type": "string"}
+		]
+	},
+	"primaryType": "Mail",
+	"domain": {
+		"name": "Ether Mail",
+		"version": "1",
+		"chainId": 1,
+		"verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"
+	},
+	"message": {
+		"from": {"name": "Cow", "wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},
+		"to": {"name": "Bob", "wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"},
+		"contents": "Hello, Bob!"
+	}
+}`
+
+func decodeMail(t *testing.T) *TypedData {
+	td := new(TypedData)
+	if err := json.Unmarshal([]byte(mail), td); err != nil {
+		t.Fatalf("failed to decode typed data: %v", err)
+	}
+	return td
+}
+
+// Tests the hashing against the reference values of the EIP-712 specification.
+func TestMailHash(t *testing.T) {
+	td := decodeMail(t)
+
+	if enc := td.EncodeType("Mail"); enc != "Mail(Person from,Person to,string contents)Person(string name,address wallet)" {
+		t.Errorf("type encoding mismatch: %s", enc)
+	}
+	if hash := td.TypeHash("Mail"); hash != common.HexToHash("0xa0cedeb2dc280ba39b857546d74f5549c3a1d7bdc2dd96bf881f76108e23dac2") {
+		t.Errorf("type hash mismatch: %x", hash)
+	}
+	domain, err := td.HashStruct(DomainType, td.Domain.values())
+	if err != nil || domain != common.HexToHash("0xf2cee375fa42b42143804025fc449deafd50cc031ca257e0b194a650a912090f") {
+		t.Errorf("domain separator mismatch: %x, %v", domain, err)
+	}
+	message, err := td.HashStruct("Mail", td.Message)
+	if err != nil || message != common.HexToHash("0xc52c0ee5d84264471806290a3f2c4cecfc5490626bf912d01f240d7a274b371e") {
+		t.Errorf("message hash mismatch: %x, %v", message, err)
+	}
+	hash, err := td.Hash()
+	if err != nil || hash != common.HexToHash("0xbe609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2") {
+		t.Errorf("typed data hash mismatch: %x, %v", hash, err)
+	}
+}
+
+func TestCheckChain(t *testing.T) {
+	td := decodeMail(t)
+	if err := td.CheckChain(common.Big1); err != nil {
+		t.Errorf("same chain rejected: %v", err)
+	}
+	if err := td.CheckChain(common.Big3); err != ErrChainMismatch {
+		t.Errorf("chain mismatch error: have %v, want %v", err, ErrChainMismatch)
+	}
+	td.Domain.ChainId = nil
+	if err := td.CheckChain(common.Big1); err != ErrNoChainId {
+		t.Errorf("unbound domain error: have %v, want %v", err, ErrNoChainId)
+	}
+}
+
+func TestInvalidTypedData(t *testing.T) {
+	tests := []struct {
+		modify func(td *TypedData)
+		err    string
+	}{
+		{func(td *TypedData) { td.PrimaryType = "Letter" }, "primary type"},
+		{func(td *TypedData) { td.Types["Mail"][2].Type = "text" }, "undefined type"},
+		{func(td *TypedData) { td.Message["contents"] = 5.0 }, "not a string"},
+		{func(td *TypedData) { td.Message["extra"] = "x" }, "unknown field"},
+		{func(td *TypedData) { delete(td.Message, "to") }, "misses field"},
+		{func(td *TypedData) { td.Message["from"].(map[string]interface{})["wallet"] = "0x01" }, "address has 1 bytes"},
+		{func(td *TypedData) {
+			td.Types["Mail"] = append(td.Types["Mail"], Field{"count", "uint8"})
+			td.Message["count"] = "256"
+		}, "overflows uint8"},
+		{func(td *TypedData) {
+			td.Types["Mail"] = append(td.Types["Mail"], Field{"tags", "string[2]"})
+			td.Message["tags"] = []interface{}{"a"}
+		}, "array has 1 items"},
+	}
+	for i, tt := range tests {
+		td := decodeMail(t)
+		tt.modify(td)
+		if _, err := td.Hash(); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %q", i, err, tt.err)
+		}
+	}
+}
@@ -23,7 +23,9 @@ import (
 	"github.com/wanchain/go-wanchain/accounts"
 	"github.com/wanchain/go-wanchain/accounts/keystore"
 	"github.com/wanchain/go-wanchain/cmd/utils"
+	"github.com/wanchain/go-wanchain/common"
 	"github.com/wanchain/go-wanchain/console"
+	"github.com/wanchain/go-wanchain/crypto"
 	"github.com/wanchain/go-wanchain/log"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -53,6 +55,7 @@ passwordfile as argument containing the wallet password in plaintext.`,
 					utils.KeyStoreDirFlag,
 					utils.PasswordFileFlag,
 					utils.LightKDFFlag,
+					utils.ScryptTargetFlag,
 				},
 				Description: `
 	geth wallet [options] /path/to/my/presale.wallet
@@ -96,6 +99,7 @@ Make sure you backup your keys regularly.`,
 				Flags: []cli.Flag{
 					utils.DataDirFlag,
 					utils.KeyStoreDirFlag,
+					utils.ReadOnlyKeyStoresFlag,
 				},
 				Description: `
 Print a short summary of all accounts`,
@@ -109,6 +113,7 @@ Print a short summary of all accounts`,
 					utils.KeyStoreDirFlag,
 					utils.PasswordFileFlag,
 					utils.LightKDFFlag,
+					utils.ScryptTargetFlag,
 				},
 				Description: `
     geth account new
@@ -134,6 +139,7 @@ password to file or expose in any other way.
 					utils.DataDirFlag,
 					utils.KeyStoreDirFlag,
 					utils.LightKDFFlag,
+					utils.ScryptTargetFlag,
 				},
 				Description: `
     geth account update <address>
@@ -163,6 +169,7 @@ changing your password is only possible interactively.
 					utils.KeyStoreDirFlag,
 					utils.PasswordFileFlag,
 					utils.LightKDFFlag,
+					utils.ScryptTargetFlag,
 				},
 				ArgsUsage: "<keyFile>",
 				Description: `
@@ -203,12 +210,33 @@ func accountList(ctx *cli.Context) error {
 	return nil
 }
 
+// accountKeystore returns the keystore holding the account given by address,
+// defaulting to the primary keystore for account indexes and unknown addresses.
+func accountKeystore(am *accounts.Manager, address string) *keystore.KeyStore {
+	if common.IsHexAddress(address) {
+		return keystore.AccountKeyStore(am, common.HexToAddress(address))
+	}
+	return keystore.PrimaryKeyStore(am)
+}
+
+// importKeystore returns the keystore to import the key of addr into, failing
+// if any keystore, read-only ones included, already holds it.
+func importKeystore(am *accounts.Manager, addr common.Address) *keystore.KeyStore {
+	if ks := keystore.AccountKeyStore(am, addr); ks.HasAddress(addr) {
+		utils.Fatalf("Account %x already exists in a keystore", addr)
+	}
+	return keystore.PrimaryKeyStore(am)
+}
+
 // tries unlocking the specified account a few times.
 func unlockAccount(ctx *cli.Context, ks *keystore.KeyStore, address string, i int, passwords []string) (accounts.Account, string) {
 	account, err := utils.MakeAddress(ks, address)
 	if err != nil {
 		utils.Fatalf("Could not list accounts: %v", err)
 	}
+	if ks.Policy().NoUnlock {
+		utils.Fatalf("Failed to unlock account %s (%v)", address, keystore.ErrUnlockDenied)
+	}
 	for trials := 0; trials < 3; trials++ {
 		prompt := fmt.Sprintf("Unlocking account %s | Attempt %d/%d", address, trials+1, 3)
 		password := getPassPhrase(prompt, false, i, passwords)
@@ -293,7 +321,7 @@ func accountCreate(ctx *cli.Context) error {
 	stack, _ := makeConfigNode(ctx)
 	password := getPassPhrase("Your new account is locked with a password. Please give a password. Do not forget this password.", true, 0, utils.MakePasswordList(ctx))
 
-	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+	ks := keystore.PrimaryKeyStore(stack.AccountManager())
 	account, err := ks.NewAccount(password)
 	if err != nil {
 		utils.Fatalf("Failed to create account: %v", err)
@@ -309,10 +337,26 @@ func accountUpdate(ctx *cli.Context) error {
 		utils.Fatalf("No accounts specified to update")
 	}
 	stack, _ := makeConfigNode(ctx)
-	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
 
 	for _, addr := range ctx.Args() {
-		account, oldPassword := unlockAccount(ctx, ks, addr, 0, nil)
+		ks := accountKeystore(stack.AccountManager(), addr)
+		if ks.Policy().ReadOnly {
+			utils.Fatalf("Could not update the account: %v", keystore.ErrReadOnly)
+		}
+		var (
+			account     accounts.Account
+			oldPassword string
+		)
+		if ks.Policy().NoUnlock {
+			// The update checks the passphrase, don't unlock the key for it
+			var err error
+			if account, err = utils.MakeAddress(ks, addr); err != nil {
+				utils.Fatalf("Could not list accounts: %v", err)
+			}
+			oldPassword = getPassPhrase(fmt.Sprintf("Updating account %s", addr), false, 0, nil)
+		} else {
+			account, oldPassword = unlockAccount(ctx, ks, addr, 0, nil)
+		}
 		newPassword := getPassPhrase("Please give a new password. Do not forget this password.", true, 0, nil)
 		if err := ks.Update(account, oldPassword, newPassword); err != nil {
 			utils.Fatalf("Could not update the account: %v", err)
@@ -334,7 +378,7 @@ func importWallet(ctx *cli.Context) error {
 	stack, _ := makeConfigNode(ctx)
 	passphrase := getPassPhrase("", false, 0, utils.MakePasswordList(ctx))
 
-	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+	ks := keystore.PrimaryKeyStore(stack.AccountManager())
 	acct, err := ks.ImportPreSaleKey(keyJson, passphrase)
 	if err != nil {
 		utils.Fatalf("%v", err)
@@ -355,7 +399,7 @@ func accountImport(ctx *cli.Context) error {
 	stack, _ := makeConfigNode(ctx)
 	passphrase := getPassPhrase("Your new account is locked with a password. Please give a password. Do not forget this password.", true, 0, utils.MakePasswordList(ctx))
 
-	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+	ks := importKeystore(stack.AccountManager(), crypto.PubkeyToAddress(key.PublicKey))
 	acct, err := ks.ImportECDSA(key, key1, passphrase)
 	if err != nil {
 		utils.Fatalf("Could not create the account: %v", err)
@@ -17,11 +17,15 @@
 package main
 
 import (
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
+	"io"
+	"math/big"
 	"os"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -76,6 +80,11 @@ with several RLP-encoded blocks, or several files can be used.
 If only one file is used, import error will result in failure. If several files are used,
 processing will proceed even if an individual RLP-file import failure occurs.`,
 	}
+	logFormatFlag = cli.StringFlag{
+		Name:  "format",
+		Usage: `Format of the exported records ("json" or "csv")`,
+		Value: utils.LogExportJSON,
+	}
 	exportCommand = cli.Command{
 		Action:    utils.MigrateFlags(exportChain),
 		Name:      "export",
@@ -92,6 +101,111 @@ Requires a first argument of the file to write to.
 Optional second and third arguments control the first and
 last block to write. In this mode, the file will be appended
 if already existing.`,
+	}
+	exportLogsCommand = cli.Command{
+		Action:    utils.MigrateFlags(exportLogs),
+		Name:      "export-logs",
+		Usage:     "Export the logs of a block range into a file",
+		ArgsUsage: "<filename> <blockNumFirst> <blockNumLast>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.CacheFlag,
+			logFormatFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The export-logs command writes the logs of the canonical blocks in the range,
+along with the stamp used and the key images spent by privacy transactions, to
+the file as newline delimited JSON or CSV records.
+
+The progress is checkpointed to <filename>.checkpoint: running the same export
+again after an interruption resumes it where it stopped.`,
+	}
+	tableFormatFlag = cli.StringFlag{
+		Name:  "format",
+		Usage: `Format of the exported tables ("csv" or "parquet")`,
+		Value: utils.TableExportCSV,
+	}
+	tablePartitionFlag = cli.Uint64Flag{
+		Name:  "partition",
+		Usage: "Number of blocks per exported file",
+		Value: utils.DefaultTablePartition,
+	}
+	exportTablesCommand = cli.Command{
+		Action:    utils.MigrateFlags(exportTables),
+		Name:      "export-tables",
+		Usage:     "Export a block range into analytic tables",
+		ArgsUsage: "<dirname> <blockNumFirst> <blockNumLast>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.CacheFlag,
+			tableFormatFlag,
+			tablePartitionFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The export-tables command writes the blocks, transactions, receipts and privacy
+events (OTA deposits, stamps used and key images spent) of the canonical blocks
+in the range to the directory, as CSV or Parquet files loadable by the usual
+analytic tools. Every table is partitioned by block range:
+
+    <dirname>/<table>/<first>-<last>.<format>
+
+The partitions already exported are skipped: running the same export again after
+an interruption resumes it where it stopped.`,
+	}
+	auditDenominationsFlag = cli.StringFlag{
+		Name:  "denominations",
+		Usage: "Comma separated denominations (in wei) of the trees to audit, all if empty",
+	}
+	auditOTAsCommand = cli.Command{
+		Action:    utils.MigrateFlags(auditOTAs),
+		Name:      "audit-otas",
+		Usage:     "Report the orphan OTAs of the denomination trees",
+		ArgsUsage: "[<filename>]",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.CacheFlag,
+			logFormatFlag,
+			auditDenominationsFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The audit-otas command scans the coin and stamp denomination trees of the head
+state for OTAs no successful deposit accounts for, such as the ones written by
+deposits failing after their OTA was stored. Every orphan OTA is reported, as
+newline delimited JSON or CSV records, with the reason and the block and
+transaction that wrote it when known. The report is written to the file if
+given, to the standard output otherwise.
+
+The database is only read: the report is meant to back a cleanup fork, not to
+remove anything.`,
+	}
+	snapshotImportCommand = cli.Command{
+		Action:    utils.MigrateFlags(importSnapshot),
+		Name:      "snapshot-import",
+		Usage:     "Bootstrap a fresh node from a snapshot archive",
+		ArgsUsage: "<filename>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.CacheFlag,
+			utils.NetworkFlag,
+			utils.TestnetFlag,
+			utils.DevInternalFlag,
+			utils.PlutoFlag,
+			utils.FakePoWFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The snapshot-import command initializes an empty database with the chain and the
+state of a snapshot archive, as exported by admin.exportSnapshot.
+
+The archive must be of the selected network, or else of the main net: its
+genesis must match and its headers pass the consensus rules, the chain config
+being the node's own. Every block, receipt and state node is checked against
+the hashes of the chain, and the state and the privacy indexes of the head are
+verified to be complete before it is set. An archive failing the checks leaves
+no head behind.`,
 	}
 	copydbCommand = cli.Command{
 		Action:    utils.MigrateFlags(copyDb),
@@ -103,6 +217,7 @@ if already existing.`,
 			utils.CacheFlag,
 			utils.SyncModeFlag,
 			utils.FakePoWFlag,
+			utils.NetworkFlag,
 			utils.TestnetFlag,
 			utils.DevInternalFlag,
 		},
@@ -158,6 +273,12 @@ func initGenesis(ctx *cli.Context) error {
 	if err := json.NewDecoder(file).Decode(genesis); err != nil {
 		utils.Fatalf("invalid genesis file: %v", err)
 	}
+	writeGenesis(ctx, genesis)
+	return nil
+}
+
+// writeGenesis writes a genesis block to both the full and light databases.
+func writeGenesis(ctx *cli.Context, genesis *core.Genesis) {
 	// Open an initialise both full and light databases
 	stack := makeFullNode(ctx)
 	for _, name := range []string{"chaindata", "lightchaindata"} {
@@ -171,7 +292,6 @@ func initGenesis(ctx *cli.Context) error {
 		}
 		log.Info("Successfully wrote genesis state", "database", name, "hash", hash)
 	}
-	return nil
 }
 
 func importChain(ctx *cli.Context) error {
@@ -287,6 +407,122 @@ func exportChain(ctx *cli.Context) error {
 	return nil
 }
 
+func exportLogs(ctx *cli.Context) error {
+	if len(ctx.Args()) != 3 {
+		utils.Fatalf("This command requires three arguments.")
+	}
+	first, ferr := strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+	last, lerr := strconv.ParseUint(ctx.Args().Get(2), 10, 64)
+	if ferr != nil || lerr != nil {
+		utils.Fatalf("Export error in parsing parameters: block number not an integer\n")
+	}
+	stack := makeFullNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+	start := time.Now()
+
+	if err := utils.ExportLogs(chainDb, ctx.Args().First(), first, last, ctx.String(logFormatFlag.Name)); err != nil {
+		utils.Fatalf("Export error: %v\n", err)
+	}
+	fmt.Printf("Export done in %v\n", time.Since(start))
+	return nil
+}
+
+func exportTables(ctx *cli.Context) error {
+	if len(ctx.Args()) != 3 {
+		utils.Fatalf("This command requires three arguments.")
+	}
+	first, ferr := strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+	last, lerr := strconv.ParseUint(ctx.Args().Get(2), 10, 64)
+	if ferr != nil || lerr != nil {
+		utils.Fatalf("Export error in parsing parameters: block number not an integer\n")
+	}
+	stack := makeFullNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+	start := time.Now()
+
+	if err := utils.ExportTables(chainDb, ctx.Args().First(), first, last, ctx.Uint64(tablePartitionFlag.Name), ctx.String(tableFormatFlag.Name)); err != nil {
+		utils.Fatalf("Export error: %v\n", err)
+	}
+	fmt.Printf("Export done in %v\n", time.Since(start))
+	return nil
+}
+
+func auditOTAs(ctx *cli.Context) error {
+	if len(ctx.Args()) > 1 {
+		utils.Fatalf("This command accepts at most one argument.")
+	}
+	var denominations []*big.Int
+	if list := ctx.String(auditDenominationsFlag.Name); list != "" {
+		for _, field := range strings.Split(list, ",") {
+			denomination, ok := new(big.Int).SetString(strings.TrimSpace(field), 10)
+			if !ok {
+				utils.Fatalf("Invalid denomination %q", field)
+			}
+			denominations = append(denominations, denomination)
+		}
+	}
+	var out io.Writer = os.Stdout
+	if fn := ctx.Args().First(); fn != "" {
+		fh, err := os.Create(fn)
+		if err != nil {
+			utils.Fatalf("Failed to create report: %v", err)
+		}
+		defer fh.Close()
+		out = fh
+	}
+	stack := makeFullNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+	start := time.Now()
+
+	orphans, err := utils.AuditOrphanOTAs(chainDb, out, denominations, ctx.String(logFormatFlag.Name))
+	if err != nil {
+		utils.Fatalf("Audit error: %v\n", err)
+	}
+	fmt.Fprintf(os.Stderr, "Found %d orphan OTAs in %v\n", len(orphans), time.Since(start))
+	return nil
+}
+
+func importSnapshot(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack := makeFullNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	fn := ctx.Args().First()
+	in, err := os.Open(fn)
+	if err != nil {
+		utils.Fatalf("Failed to open snapshot: %v", err)
+	}
+	defer in.Close()
+
+	var reader io.Reader = in
+	if strings.HasSuffix(fn, ".gz") {
+		if reader, err = gzip.NewReader(reader); err != nil {
+			utils.Fatalf("Failed to open snapshot: %v", err)
+		}
+	}
+	// Verify the snapshot against the selected network rather than trusting
+	// the genesis and config it carries
+	genesis := utils.MakeGenesis(ctx)
+	if genesis == nil {
+		genesis = core.DefaultGenesisBlock()
+	}
+	engine := utils.MakeEngine(ctx, stack, genesis.Config, chainDb)
+
+	start := time.Now()
+	header, err := core.ImportSnapshot(chainDb, genesis, engine, reader)
+	if err != nil {
+		utils.Fatalf("Snapshot import error: %v", err)
+	}
+	fmt.Printf("Imported snapshot of block #%d [%x…], state root %x, in %v\n", header.Number, header.Hash.Bytes()[:4], header.Root, time.Since(start))
+	return nil
+}
+
 func copyDb(ctx *cli.Context) error {
 	// Ensure we have a source chain directory to copy
 	if len(ctx.Args()) != 1 {
@@ -0,0 +1,126 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/wanchain/go-wanchain/cmd/utils"
+	"github.com/wanchain/go-wanchain/core"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	chainspecGenesisFlag = cli.StringFlag{
+		Name:  "genesis",
+		Usage: "Genesis JSON file of the chain, instead of the selected network's",
+	}
+	chainspecCommand = cli.Command{
+		Name:     "chainspec",
+		Usage:    "Export and import client independent chain specs",
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The chainspec commands convert between the genesis of a chain and a versioned
+JSON chain spec, for alternative clients and test harnesses to be configured
+identically to this node. The spec holds:
+
+    version      format version
+    genesisHash  hash of the genesis block
+    genesis      genesis block as accepted by init, forks included in its config
+    precompiles  name, address and activationBlock of the precompiled contracts
+    privacy      coin and stamp denominations, privacy storage accounts, ring
+                 signature limits and gas prices`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "export",
+				Usage:     "Export the chain spec of a genesis block",
+				ArgsUsage: "[<filename>]",
+				Action:    utils.MigrateFlags(exportChainSpec),
+				Category:  "BLOCKCHAIN COMMANDS",
+				Flags: []cli.Flag{
+					chainspecGenesisFlag,
+					utils.NetworkFlag,
+					utils.TestnetFlag,
+					utils.DevInternalFlag,
+					utils.PlutoFlag,
+				},
+				Description: `
+    gwan chainspec export [--genesis <genesisPath>] [<filename>]
+
+writes the spec of the genesis file, or else of the selected network or the main
+net, to the file or to the standard output if none. The same genesis always
+exports to the same spec.`,
+			},
+			{
+				Name:      "import",
+				Usage:     "Bootstrap and initialize a new genesis block from a chain spec",
+				ArgsUsage: "<filename>",
+				Action:    utils.MigrateFlags(importChainSpec),
+				Category:  "BLOCKCHAIN COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.LightModeFlag,
+				},
+				Description: `
+    gwan chainspec import <filename>
+
+checks the spec against its genesis block and the rules of the node, and
+initializes the genesis block as init does. Specs whose precompiles or privacy
+parameters differ from the node's are rejected.`,
+			},
+		},
+	}
+)
+
+func exportChainSpec(ctx *cli.Context) error {
+	if len(ctx.Args()) > 1 {
+		utils.Fatalf("This command accepts at most one argument.")
+	}
+	var genesis *core.Genesis
+	if path := ctx.String(chainspecGenesisFlag.Name); path != "" {
+		blob, err := ioutil.ReadFile(path)
+		if err != nil {
+			utils.Fatalf("Failed to read genesis file: %v", err)
+		}
+		genesis = new(core.Genesis)
+		if err := json.Unmarshal(blob, genesis); err != nil {
+			utils.Fatalf("invalid genesis file: %v", err)
+		}
+	} else if genesis = utils.MakeGenesis(ctx); genesis == nil {
+		genesis = core.DefaultGenesisBlock()
+	}
+	spec, err := core.NewChainSpec(genesis)
+	if err != nil {
+		utils.Fatalf("Failed to create chain spec: %v", err)
+	}
+	blob, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		utils.Fatalf("Failed to encode chain spec: %v", err)
+	}
+	if fn := ctx.Args().First(); fn != "" {
+		if err := ioutil.WriteFile(fn, append(blob, '\n'), 0644); err != nil {
+			utils.Fatalf("Failed to write chain spec: %v", err)
+		}
+		return nil
+	}
+	fmt.Println(string(blob))
+	return nil
+}
+
+func importChainSpec(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	blob, err := ioutil.ReadFile(ctx.Args().First())
+	if err != nil {
+		utils.Fatalf("Failed to read chain spec: %v", err)
+	}
+	genesis, err := core.ImportChainSpec(blob)
+	if err != nil {
+		utils.Fatalf("Invalid chain spec: %v", err)
+	}
+	writeGenesis(ctx, genesis)
+	return nil
+}
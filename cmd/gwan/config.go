@@ -32,8 +32,10 @@ import (
 	"github.com/wanchain/go-wanchain/cmd/utils"
 	"github.com/wanchain/go-wanchain/contracts/release"
 	"github.com/wanchain/go-wanchain/eth"
+	"github.com/wanchain/go-wanchain/faucet"
 	"github.com/wanchain/go-wanchain/node"
 	"github.com/wanchain/go-wanchain/params"
+	"github.com/wanchain/go-wanchain/walletnotify"
 	whisper "github.com/wanchain/go-wanchain/whisper/whisperv5"
 )
 
@@ -80,6 +82,11 @@ type gethConfig struct {
 	Shh      whisper.Config
 	Node     node.Config
 	Ethstats ethstatsConfig
+	Faucet   faucet.Config
+	Notify   walletnotify.Config
+
+	// Instances are additional nodes run in the same process.
+	Instances []instanceConfig `toml:",omitempty"`
 }
 
 func loadConfig(file string, cfg *gethConfig) error {
@@ -112,9 +119,11 @@ func defaultNodeConfig() node.Config {
 func makeConfigNode(ctx *cli.Context) (*node.Node, gethConfig) {
 	// Load defaults.
 	cfg := gethConfig{
-		Eth:  eth.DefaultConfig,
-		Shh:  whisper.DefaultConfig,
-		Node: defaultNodeConfig(),
+		Eth:    eth.DefaultConfig,
+		Shh:    whisper.DefaultConfig,
+		Node:   defaultNodeConfig(),
+		Faucet: faucet.DefaultConfig,
+		Notify: walletnotify.DefaultConfig,
 	}
 
 	// Load config file.
@@ -136,6 +145,8 @@ func makeConfigNode(ctx *cli.Context) (*node.Node, gethConfig) {
 	}
 
 	utils.SetShhConfig(ctx, stack, &cfg.Shh)
+	utils.SetFaucetConfig(ctx, &cfg.Faucet)
+	utils.SetWalletNotifyConfig(ctx, &cfg.Notify)
 
 	return stack, cfg
 }
@@ -151,6 +162,18 @@ func enableWhisper(ctx *cli.Context) bool {
 }
 
 func makeFullNode(ctx *cli.Context) *node.Node {
+	stack, _ := makeMainNode(ctx)
+	return stack
+}
+
+// makeFullNodes creates the main node along with the additional node instances
+// declared in the config.
+func makeFullNodes(ctx *cli.Context) (*node.Node, []*node.Node) {
+	stack, cfg := makeMainNode(ctx)
+	return stack, makeInstanceNodes(&cfg)
+}
+
+func makeMainNode(ctx *cli.Context) (*node.Node, gethConfig) {
 	stack, cfg := makeConfigNode(ctx)
 
 	utils.RegisterEthService(stack, &cfg.Eth)
@@ -173,6 +196,16 @@ func makeFullNode(ctx *cli.Context) *node.Node {
 		utils.RegisterEthStatsService(stack, cfg.Ethstats.URL)
 	}
 
+	// Add the testnet faucet if requested.
+	if cfg.Faucet.Addr != "" {
+		utils.RegisterFaucetService(stack, &cfg.Faucet)
+	}
+
+	// Add the wallet notification bridge if requested.
+	if len(cfg.Notify.Accounts) > 0 {
+		utils.RegisterWalletNotifyService(stack, &cfg.Notify)
+	}
+
 	// Add the release oracle service so it boots along with node.
 	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
 		config := release.Config{
@@ -187,7 +220,7 @@ func makeFullNode(ctx *cli.Context) *node.Node {
 	}); err != nil {
 		utils.Fatalf("Failed to register the Geth release oracle service: %v", err)
 	}
-	return stack
+	return stack, cfg
 }
 
 // dumpConfig is the dumpconfig command.
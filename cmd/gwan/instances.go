@@ -0,0 +1,188 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+
+	"github.com/wanchain/go-wanchain/cmd/utils"
+	"github.com/wanchain/go-wanchain/core"
+	"github.com/wanchain/go-wanchain/eth"
+	"github.com/wanchain/go-wanchain/log"
+	"github.com/wanchain/go-wanchain/node"
+	"github.com/wanchain/go-wanchain/p2p/discover"
+	"github.com/wanchain/go-wanchain/p2p/discv5"
+)
+
+// instanceConfig is the configuration of an additional node instance run in
+// the same process as the main node, declared in the TOML config as
+//
+//	[[Instances]]
+//	Network = "testnet"
+//	[Instances.Node]
+//	DataDir = "/data/testnet"
+//
+// Every instance has its own data directory, listening ports and RPC
+// endpoints. The command line flags only apply to the main node.
+type instanceConfig struct {
+	Network  string `toml:",omitempty"` // Preset network of core.Networks, mainnet if empty
+	Eth      eth.Config
+	Node     node.Config
+	Ethstats ethstatsConfig
+}
+
+// defaultInstanceConfig returns the configuration the instance settings of
+// the TOML config are applied on.
+func defaultInstanceConfig() instanceConfig {
+	return instanceConfig{
+		Eth:  eth.DefaultConfig,
+		Node: defaultNodeConfig(),
+	}
+}
+
+// UnmarshalTOML implements toml.UnmarshalerRec, decoding the instance on top
+// of the default configuration.
+func (c *instanceConfig) UnmarshalTOML(decode func(interface{}) error) error {
+	type plain instanceConfig
+
+	*c = defaultInstanceConfig()
+	if err := decode((*plain)(c)); err != nil {
+		return err
+	}
+	return c.applyNetwork()
+}
+
+// applyNetwork overrides the network id, genesis and bootstrap nodes of the
+// instance with the ones of its preset network, unless set explicitly.
+func (c *instanceConfig) applyNetwork() error {
+	name := c.Network
+	if name == "" {
+		name = "mainnet"
+	}
+	network, ok := core.Networks[name]
+	if !ok {
+		return fmt.Errorf("unknown network %q, known networks: %s", c.Network, strings.Join(core.NetworkNames(), ", "))
+	}
+	if c.Eth.NetworkId == eth.DefaultConfig.NetworkId {
+		c.Eth.NetworkId = network.NetworkId
+	}
+	if c.Eth.Genesis == nil {
+		c.Eth.Genesis = network.Genesis()
+	}
+	if len(c.Node.P2P.BootstrapNodes) == 0 {
+		for _, url := range network.Bootnodes {
+			node, err := discover.ParseNode(url)
+			if err != nil {
+				log.Error("Bootstrap URL invalid", "enode", url, "err", err)
+				continue
+			}
+			c.Node.P2P.BootstrapNodes = append(c.Node.P2P.BootstrapNodes, node)
+		}
+	}
+	if len(c.Node.P2P.BootstrapNodesV5) == 0 {
+		for _, url := range network.BootnodesV5 {
+			node, err := discv5.ParseNode(url)
+			if err != nil {
+				log.Error("Bootstrap URL invalid", "enode", url, "err", err)
+				continue
+			}
+			c.Node.P2P.BootstrapNodesV5 = append(c.Node.P2P.BootstrapNodesV5, node)
+		}
+	}
+	return nil
+}
+
+// checkInstances ensures that the main node and the additional instances
+// don't share a data directory, a listening port or an IPC endpoint.
+func checkInstances(main *node.Config, instances []instanceConfig) error {
+	var (
+		datadirs  = make(map[string]string)
+		endpoints = make(map[string]string)
+	)
+	claim := func(owner string, claimed map[string]string, kind, resource string) error {
+		if resource == "" {
+			return nil
+		}
+		if other, ok := claimed[resource]; ok {
+			return fmt.Errorf("%s and %s share the %s %s", other, owner, kind, resource)
+		}
+		claimed[resource] = owner
+		return nil
+	}
+	configs := []*node.Config{main}
+	for i := range instances {
+		configs = append(configs, &instances[i].Node)
+	}
+	for i, cfg := range configs {
+		owner := "the main node"
+		if i > 0 {
+			owner = fmt.Sprintf("instance %d", i)
+			if cfg.DataDir == "" {
+				return fmt.Errorf("%s has no data directory", owner)
+			}
+		}
+		datadir := cfg.DataDir
+		if datadir != "" {
+			if abs, err := filepath.Abs(datadir); err == nil {
+				datadir = abs
+			}
+		}
+		if err := claim(owner, datadirs, "data directory", datadir); err != nil {
+			return err
+		}
+		ports := []struct{ kind, endpoint string }{
+			{"IPC endpoint", cfg.IPCEndpoint()},
+			{"HTTP endpoint", cfg.HTTPEndpoint()},
+			{"WebSocket endpoint", cfg.WSEndpoint()},
+			{"P2P port", listenPort(cfg.P2P.ListenAddr)},
+		}
+		if cfg.P2P.DiscoveryV5 {
+			ports = append(ports, struct{ kind, endpoint string }{"discovery port", listenPort(cfg.P2P.DiscoveryV5Addr)})
+		}
+		for _, port := range ports {
+			if err := claim(owner, endpoints, port.kind, port.endpoint); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// listenPort returns the port of a listening address, as the instances bind
+// to all the interfaces by default.
+func listenPort(addr string) string {
+	if addr == "" {
+		return ""
+	}
+	if _, port, err := net.SplitHostPort(addr); err == nil {
+		return ":" + port
+	}
+	return addr
+}
+
+// makeInstanceNodes creates the protocol stacks of the additional node
+// instances declared in the config, with the Wanchain service registered.
+func makeInstanceNodes(cfg *gethConfig) []*node.Node {
+	if err := checkInstances(&cfg.Node, cfg.Instances); err != nil {
+		utils.Fatalf("Invalid node instances: %v", err)
+	}
+	stacks := make([]*node.Node, 0, len(cfg.Instances))
+	for i := range cfg.Instances {
+		instance := &cfg.Instances[i]
+
+		stack, err := node.New(&instance.Node)
+		if err != nil {
+			utils.Fatalf("Failed to create the protocol stack of instance %d: %v", i+1, err)
+		}
+		utils.RegisterEthService(stack, &instance.Eth)
+		if instance.Ethstats.URL != "" {
+			utils.RegisterEthStatsService(stack, instance.Ethstats.URL)
+		}
+		log.Info("Configured node instance", "instance", i+1, "network", instance.Eth.NetworkId, "datadir", instance.Node.DataDir)
+		stacks = append(stacks, stack)
+	}
+	return stacks
+}
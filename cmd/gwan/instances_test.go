@@ -0,0 +1,115 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/core"
+	"github.com/wanchain/go-wanchain/eth"
+)
+
+const instancesConfig = `
+[Node]
+DataDir = "/data/mainnet"
+
+[[Instances]]
+Network = "testnet"
+[Instances.Node]
+DataDir = "/data/testnet"
+HTTPHost = "127.0.0.1"
+HTTPPort = 18545
+[Instances.Node.P2P]
+ListenAddr = ":27717"
+`
+
+// Tests that the instances are decoded on top of the defaults and configured
+// for their preset network.
+func TestInstancesConfig(t *testing.T) {
+	cfg := gethConfig{Eth: eth.DefaultConfig, Node: defaultNodeConfig()}
+	if err := tomlSettings.NewDecoder(strings.NewReader(instancesConfig)).Decode(&cfg); err != nil {
+		t.Fatalf("failed to decode config: %v", err)
+	}
+	if len(cfg.Instances) != 1 {
+		t.Fatalf("instance count mismatch: have %d, want 1", len(cfg.Instances))
+	}
+	instance := cfg.Instances[0]
+	if instance.Eth.NetworkId != 3 || instance.Eth.Genesis == nil {
+		t.Errorf("testnet preset not applied: network %d, genesis %v", instance.Eth.NetworkId, instance.Eth.Genesis)
+	}
+	if instance.Eth.SyncMode != eth.DefaultConfig.SyncMode {
+		t.Errorf("sync mode mismatch: have %v, want default %v", instance.Eth.SyncMode, eth.DefaultConfig.SyncMode)
+	}
+	if instance.Node.IPCPath != "gwan.ipc" || instance.Node.IPCEndpoint() == cfg.Node.IPCEndpoint() {
+		t.Errorf("IPC endpoint not isolated: %s", instance.Node.IPCEndpoint())
+	}
+	if len(instance.Node.P2P.BootstrapNodes) == 0 {
+		t.Error("no testnet bootstrap nodes")
+	}
+	if err := checkInstances(&cfg.Node, cfg.Instances); err != nil {
+		t.Errorf("valid instances rejected: %v", err)
+	}
+}
+
+// Tests that instances sharing resources with the main node or each other
+// are rejected.
+func TestInstancesCollisions(t *testing.T) {
+	main := defaultNodeConfig()
+	main.DataDir = "/data/mainnet"
+
+	valid := func() instanceConfig {
+		instance := defaultInstanceConfig()
+		instance.Node.DataDir = "/data/testnet"
+		instance.Node.P2P.ListenAddr = ":27717"
+		return instance
+	}
+	tests := []struct {
+		name   string
+		modify func(*instanceConfig)
+		err    string
+	}{
+		{"datadir", func(c *instanceConfig) { c.Node.DataDir = "/data/mainnet" }, "data directory"},
+		{"no datadir", func(c *instanceConfig) { c.Node.DataDir = "" }, "no data directory"},
+		{"p2p port", func(c *instanceConfig) { c.Node.P2P.ListenAddr = "0.0.0.0:17717" }, "P2P port"},
+		{"ipc", func(c *instanceConfig) { c.Node.IPCPath = "/data/mainnet/gwan.ipc" }, "IPC endpoint"},
+		{"http", func(c *instanceConfig) { c.Node.HTTPHost = "localhost"; main.HTTPHost = "localhost" }, "HTTP endpoint"},
+	}
+	for _, tt := range tests {
+		main.HTTPHost = ""
+		instance := valid()
+		tt.modify(&instance)
+
+		err := checkInstances(&main, []instanceConfig{instance})
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: error mismatch: have %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+// Tests that the instance presets are those of the network registry.
+func TestInstancesNetwork(t *testing.T) {
+	for _, name := range core.NetworkNames() {
+		instance := defaultInstanceConfig()
+		instance.Network = name
+		if err := instance.applyNetwork(); err != nil {
+			t.Fatalf("%s: failed to apply network: %v", name, err)
+		}
+		network := core.Networks[name]
+		if instance.Eth.NetworkId != network.NetworkId {
+			t.Errorf("%s: network id mismatch: have %d, want %d", name, instance.Eth.NetworkId, network.NetworkId)
+		}
+		if !reflect.DeepEqual(instance.Eth.Genesis, network.Genesis()) {
+			t.Errorf("%s: genesis mismatch", name)
+		}
+		if len(instance.Node.P2P.BootstrapNodesV5) != len(network.BootnodesV5) {
+			t.Errorf("%s: v5 bootstrap node count mismatch: have %d, want %d", name, len(instance.Node.P2P.BootstrapNodesV5), len(network.BootnodesV5))
+		}
+	}
+	instance := defaultInstanceConfig()
+	instance.Network = "unknown"
+	if err := instance.applyNetwork(); err == nil {
+		t.Error("unknown network accepted")
+	}
+}
@@ -26,7 +26,6 @@ import (
 	"time"
 
 	"github.com/wanchain/go-wanchain/accounts"
-	"github.com/wanchain/go-wanchain/accounts/keystore"
 	"github.com/wanchain/go-wanchain/cmd/utils"
 	"github.com/wanchain/go-wanchain/common"
 	"github.com/wanchain/go-wanchain/console"
@@ -60,6 +59,12 @@ var (
 		utils.BootnodesV5Flag,
 		utils.DataDirFlag,
 		utils.KeyStoreDirFlag,
+		utils.ReadOnlyKeyStoresFlag,
+		utils.ScryptTargetFlag,
+		utils.KeyCacheSizeFlag,
+		utils.KeyCacheTimeoutFlag,
+		utils.SignerAuditLogFlag,
+		utils.KMSKeysFlag,
 		utils.NoUSBFlag,
 		utils.EthashCacheDirFlag,
 		utils.EthashCachesInMemoryFlag,
@@ -77,8 +82,16 @@ var (
 		utils.TxPoolAccountQueueFlag,
 		utils.TxPoolGlobalQueueFlag,
 		utils.TxPoolLifetimeFlag,
+		utils.TxPoolTTLFlag,
+		utils.TxPoolPrivacyTTLFlag,
+		utils.TxPoolBlacklistFlag,
+		utils.TxPoolBlacklistAuditFlag,
+		utils.TxPoolRelayUnknownFlag,
+		utils.TxPoolUnknownSlotsFlag,
+		utils.DandelionFlag,
 		utils.FastSyncFlag,
 		utils.LightModeFlag,
+		utils.ProfileFlag,
 		utils.SyncModeFlag,
 		utils.LightServFlag,
 		utils.LightPeersFlag,
@@ -100,10 +113,15 @@ var (
 		utils.NodeKeyFileFlag,
 		utils.NodeKeyHexFlag,
 		utils.DevModeFlag,
+		utils.NetworkFlag,
 		utils.TestnetFlag,
 		utils.DevInternalFlag,
 		utils.PlutoFlag,
 		utils.VMEnableDebugFlag,
+		utils.VMProfileFlag,
+		utils.ParallelExecFlag,
+		utils.StorageLayoutsFlag,
+		utils.BlockPluginsRejectFlag,
 		utils.NetworkIdFlag,
 		utils.RPCCORSDomainFlag,
 		utils.EthStatsURLFlag,
@@ -113,6 +131,19 @@ var (
 		utils.GpoBlocksFlag,
 		utils.GpoPercentileFlag,
 		utils.ExtraDataFlag,
+		utils.MinerMaxClockDriftFlag,
+		utils.ReplicationPrimaryFlag,
+		utils.ReplicationSecretFlag,
+		utils.FaucetAddrFlag,
+		utils.FaucetAccountFlag,
+		utils.FaucetPeriodFlag,
+		utils.FaucetAPIKeysFlag,
+		utils.FaucetCaptchaSecretFlag,
+		utils.NotifyAccountsFlag,
+		utils.NotifyWebhooksFlag,
+		utils.NotifyMQTTFlag,
+		utils.NotifyMQTTTopicFlag,
+		utils.NotifyConfirmationsFlag,
 		configFileFlag,
 	}
 
@@ -128,6 +159,10 @@ var (
 		utils.WSAllowedOriginsFlag,
 		utils.IPCDisabledFlag,
 		utils.IPCPathFlag,
+		utils.HealthAddrFlag,
+		utils.HealthMinPeersFlag,
+		utils.HealthMaxHeadAgeFlag,
+		utils.HealthMaxLagFlag,
 	}
 
 	whisperFlags = []cli.Flag{
@@ -147,9 +182,15 @@ func init() {
 		initCommand,
 		importCommand,
 		exportCommand,
+		exportLogsCommand,
+		exportTablesCommand,
+		auditOTAsCommand,
+		snapshotImportCommand,
 		copydbCommand,
 		removedbCommand,
 		dumpCommand,
+		// See chainspeccmd.go:
+		chainspecCommand,
 		// See monitorcmd.go:
 		monitorCommand,
 		// See accountcmd.go:
@@ -164,8 +205,11 @@ func init() {
 		makecacheCommand,
 		makedagCommand,
 		versionCommand,
+		rotateNodeKeyCommand,
 		bugCommand,
 		licenseCommand,
+		// See vectorcmd.go:
+		vectorsCommand,
 		// See config.go
 		dumpConfigCommand,
 	}
@@ -207,9 +251,15 @@ func main() {
 // It creates a default node based on the command line arguments and runs it in
 // blocking mode, waiting for it to be shut down.
 func geth(ctx *cli.Context) error {
-	node := makeFullNode(ctx)
+	node, instances := makeFullNodes(ctx)
 	startNode(ctx, node)
+	for _, instance := range instances {
+		utils.StartNode(instance)
+	}
 	node.Wait()
+	for _, instance := range instances {
+		instance.Wait()
+	}
 	return nil
 }
 
@@ -221,13 +271,11 @@ func startNode(ctx *cli.Context, stack *node.Node) {
 	utils.StartNode(stack)
 
 	// Unlock any account specifically requested
-	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
-
 	passwords := utils.MakePasswordList(ctx)
 	unlocks := strings.Split(ctx.GlobalString(utils.UnlockedAccountFlag.Name), ",")
 	for i, account := range unlocks {
 		if trimmed := strings.TrimSpace(account); trimmed != "" {
-			unlockAccount(ctx, ks, trimmed, i, passwords)
+			unlockAccount(ctx, accountKeystore(stack.AccountManager(), trimmed), trimmed, i, passwords)
 		}
 	}
 	// Register wallet event handlers to open and auto-derive wallets
@@ -65,6 +65,25 @@ Regular users do not need to execute it.
 		Category:  "MISCELLANEOUS COMMANDS",
 		Description: `
 The output of this command is supposed to be machine-readable.
+`,
+	}
+	rotateNodeKeyCommand = cli.Command{
+		Action:    utils.MigrateFlags(rotateNodeKey),
+		Name:      "rotate-nodekey",
+		Usage:     "Replace the node key, linking the old node identity to the new one",
+		ArgsUsage: " ",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+		},
+		Category: "MISCELLANEOUS COMMANDS",
+		Description: `
+The rotate-nodekey command replaces the node key in the data directory with a
+newly generated one. The link from the old node identity to the new one, signed
+by both keys, is appended to nodekey-links.json. The node publishes the links
+in admin.nodeInfo and announces the last one to its peers, which report it in
+admin.peers, so they track the node across its key rotations.
+
+The node must be stopped while rotating its key.
 `,
 	}
 	licenseCommand = cli.Command{
@@ -76,6 +95,19 @@ The output of this command is supposed to be machine-readable.
 	}
 )
 
+// rotateNodeKey replaces the node key, printing the link from the old node
+// identity to the new one.
+func rotateNodeKey(ctx *cli.Context) error {
+	_, cfg := makeConfigNode(ctx)
+
+	link, err := cfg.Node.RotateNodeKey()
+	if err != nil {
+		utils.Fatalf("Failed to rotate the node key: %v", err)
+	}
+	fmt.Printf("Rotated node key #%d\nOld: %s\nNew: %s\n", link.Seq, link.Old, link.New)
+	return nil
+}
+
 // makecache generates an ethash verification cache into the provided folder.
 func makecache(ctx *cli.Context) error {
 	args := ctx.Args()
@@ -68,12 +68,20 @@ var AppHelpFlagGroups = []flagGroup{
 			configFileFlag,
 			utils.DataDirFlag,
 			utils.KeyStoreDirFlag,
+			utils.ReadOnlyKeyStoresFlag,
+			utils.ScryptTargetFlag,
+			utils.KeyCacheSizeFlag,
+			utils.KeyCacheTimeoutFlag,
+			utils.SignerAuditLogFlag,
+			utils.KMSKeysFlag,
 			utils.NoUSBFlag,
 			utils.NetworkIdFlag,
+			utils.NetworkFlag,
 			utils.TestnetFlag,
 			utils.DevInternalFlag,
 			utils.PlutoFlag,
 			utils.DevModeFlag,
+			utils.ProfileFlag,
 			utils.SyncModeFlag,
 			utils.EthStatsURLFlag,
 			utils.IdentityFlag,
@@ -106,6 +114,40 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.TxPoolAccountQueueFlag,
 			utils.TxPoolGlobalQueueFlag,
 			utils.TxPoolLifetimeFlag,
+			utils.TxPoolTTLFlag,
+			utils.TxPoolPrivacyTTLFlag,
+			utils.TxPoolBlacklistFlag,
+			utils.TxPoolBlacklistAuditFlag,
+			utils.TxPoolRelayUnknownFlag,
+			utils.TxPoolUnknownSlotsFlag,
+			utils.DandelionFlag,
+		},
+	},
+	{
+		Name: "REPLICATION",
+		Flags: []cli.Flag{
+			utils.ReplicationPrimaryFlag,
+			utils.ReplicationSecretFlag,
+		},
+	},
+	{
+		Name: "FAUCET",
+		Flags: []cli.Flag{
+			utils.FaucetAddrFlag,
+			utils.FaucetAccountFlag,
+			utils.FaucetPeriodFlag,
+			utils.FaucetAPIKeysFlag,
+			utils.FaucetCaptchaSecretFlag,
+		},
+	},
+	{
+		Name: "WALLET NOTIFICATIONS",
+		Flags: []cli.Flag{
+			utils.NotifyAccountsFlag,
+			utils.NotifyWebhooksFlag,
+			utils.NotifyMQTTFlag,
+			utils.NotifyMQTTTopicFlag,
+			utils.NotifyConfirmationsFlag,
 		},
 	},
 	{
@@ -137,6 +179,10 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.IPCDisabledFlag,
 			utils.IPCPathFlag,
 			utils.RPCCORSDomainFlag,
+			utils.HealthAddrFlag,
+			utils.HealthMinPeersFlag,
+			utils.HealthMaxHeadAgeFlag,
+			utils.HealthMaxLagFlag,
 			utils.JSpathFlag,
 			utils.ExecFlag,
 			utils.PreloadJSFlag,
@@ -168,6 +214,7 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.TargetGasLimitFlag,
 			utils.GasPriceFlag,
 			utils.ExtraDataFlag,
+			utils.MinerMaxClockDriftFlag,
 		},
 	},
 	{
@@ -181,6 +228,10 @@ var AppHelpFlagGroups = []flagGroup{
 		Name: "VIRTUAL MACHINE",
 		Flags: []cli.Flag{
 			utils.VMEnableDebugFlag,
+			utils.VMProfileFlag,
+			utils.ParallelExecFlag,
+			utils.StorageLayoutsFlag,
+			utils.BlockPluginsRejectFlag,
 		},
 	},
 	{
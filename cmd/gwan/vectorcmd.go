@@ -0,0 +1,115 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/wanchain/go-wanchain/cmd/utils"
+	"github.com/wanchain/go-wanchain/params"
+	"github.com/wanchain/go-wanchain/tests"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	vectorSeedFlag = cli.StringFlag{
+		Name:  "seed",
+		Usage: "Seed the keys of the vectors are derived from",
+		Value: "wanchain privacy vectors",
+	}
+	vectorCountFlag = cli.IntFlag{
+		Name:  "count",
+		Usage: "Number of OTA and ring signature vectors to generate",
+		Value: 8,
+	}
+	vectorsCommand = cli.Command{
+		Name:     "privacy-vectors",
+		Usage:    "Generate and verify OTA and ring signature test vectors",
+		Category: "MISCELLANEOUS COMMANDS",
+		Description: `
+The privacy-vectors commands emit and check versioned JSON test vectors of the
+OTA derivation and the ring signatures of privacy transactions: the keys of a
+wan address, the OTA derived from it and its private key, ring signatures by
+the OTAs with their key images and their transaction payloads.
+
+Wallets implementing the privacy protocol check their derivations against the
+generated vectors, and have their own vectors checked with the verify command.`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "generate",
+				Usage:     "Generate test vectors with the node's crypto code",
+				ArgsUsage: "[<filename>]",
+				Action:    utils.MigrateFlags(generateVectors),
+				Category:  "MISCELLANEOUS COMMANDS",
+				Flags: []cli.Flag{
+					vectorSeedFlag,
+					vectorCountFlag,
+				},
+				Description: `
+    gwan privacy-vectors generate [--seed <seed>] [--count <n>] [<filename>]
+
+writes the vectors to the file, or to the standard output if none. The keys are
+reproducible from the seed, the one-time secrets and ring signatures are not.`,
+			},
+			{
+				Name:      "verify",
+				Usage:     "Verify test vectors against the node's crypto code",
+				ArgsUsage: "<filename>",
+				Action:    utils.MigrateFlags(verifyVectors),
+				Category:  "MISCELLANEOUS COMMANDS",
+				Description: `
+    gwan privacy-vectors verify <filename>
+
+checks every vector of the file, whoever produced it, failing on the first one
+the node disagrees with.`,
+			},
+		},
+	}
+)
+
+func generateVectors(ctx *cli.Context) error {
+	if len(ctx.Args()) > 1 {
+		utils.Fatalf("This command accepts at most one argument.")
+	}
+	vectors, err := tests.GeneratePrivacyVectors([]byte(ctx.String(vectorSeedFlag.Name)), ctx.Int(vectorCountFlag.Name))
+	if err != nil {
+		utils.Fatalf("Failed to generate vectors: %v", err)
+	}
+	vectors.Generator = "gwan/" + params.Version
+
+	blob, err := json.MarshalIndent(vectors, "", "  ")
+	if err != nil {
+		utils.Fatalf("Failed to encode vectors: %v", err)
+	}
+	if fn := ctx.Args().First(); fn != "" {
+		if err := ioutil.WriteFile(fn, append(blob, '\n'), 0644); err != nil {
+			utils.Fatalf("Failed to write vectors: %v", err)
+		}
+		return nil
+	}
+	fmt.Println(string(blob))
+	return nil
+}
+
+func verifyVectors(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	blob, err := ioutil.ReadFile(ctx.Args().First())
+	if err != nil {
+		utils.Fatalf("Failed to read vectors: %v", err)
+	}
+	var vectors tests.PrivacyVectors
+	if err := json.Unmarshal(blob, &vectors); err != nil {
+		utils.Fatalf("Invalid vectors: %v", err)
+	}
+	if err := tests.VerifyPrivacyVectors(&vectors); err != nil {
+		fmt.Fprintf(os.Stderr, "Verification failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Verified %d OTA and %d ring signature vectors\n", len(vectors.OTAs), len(vectors.Rings))
+	return nil
+}
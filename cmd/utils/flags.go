@@ -30,6 +30,7 @@ import (
 
 	"github.com/wanchain/go-wanchain/accounts"
 	"github.com/wanchain/go-wanchain/accounts/keystore"
+	"github.com/wanchain/go-wanchain/accounts/kms"
 	"github.com/wanchain/go-wanchain/common"
 	"github.com/wanchain/go-wanchain/consensus"
 	"github.com/wanchain/go-wanchain/consensus/clique"
@@ -43,6 +44,7 @@ import (
 	"github.com/wanchain/go-wanchain/eth/gasprice"
 	"github.com/wanchain/go-wanchain/ethdb"
 	"github.com/wanchain/go-wanchain/ethstats"
+	"github.com/wanchain/go-wanchain/faucet"
 	"github.com/wanchain/go-wanchain/les"
 	"github.com/wanchain/go-wanchain/log"
 	"github.com/wanchain/go-wanchain/metrics"
@@ -53,6 +55,7 @@ import (
 	"github.com/wanchain/go-wanchain/p2p/nat"
 	"github.com/wanchain/go-wanchain/p2p/netutil"
 	"github.com/wanchain/go-wanchain/params"
+	"github.com/wanchain/go-wanchain/walletnotify"
 	whisper "github.com/wanchain/go-wanchain/whisper/whisperv5"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -120,6 +123,31 @@ var (
 		Name:  "keystore",
 		Usage: "Directory for the keystore (default = inside the datadir)",
 	}
+	ReadOnlyKeyStoresFlag = cli.StringFlag{
+		Name:  "keystore.readonly",
+		Usage: "Comma separated additional read-only keystore directories",
+	}
+	ScryptTargetFlag = cli.DurationFlag{
+		Name:  "keystore.scrypttarget",
+		Usage: "Unlock latency to tune the key encryption scrypt parameters for on first run, never below the standard or --lightkdf ones (0 = untuned)",
+	}
+	KeyCacheSizeFlag = cli.IntFlag{
+		Name:  "keystore.cachesize",
+		Usage: "Number of decrypted keys cached to spare repeated key derivations (0 = disabled)",
+	}
+	KeyCacheTimeoutFlag = cli.DurationFlag{
+		Name:  "keystore.cachetimeout",
+		Usage: "Time decrypted keys are cached for",
+		Value: node.DefaultConfig.KeyCacheTimeout,
+	}
+	SignerAuditLogFlag = cli.StringFlag{
+		Name:  "signer.auditlog",
+		Usage: "File recording the keystore signing operations in a hash-chained audit log",
+	}
+	KMSKeysFlag = cli.StringFlag{
+		Name:  "signer.kms",
+		Usage: "Comma separated keys held by key management services, as provider:keyid (providers: aws, gcp, vault)",
+	}
 	NoUSBFlag = cli.BoolFlag{
 		Name:  "nousb",
 		Usage: "Disables monitoring for and managing USB hardware wallets",
@@ -130,6 +158,10 @@ var (
 		Value: eth.DefaultConfig.NetworkId,
 	}
 
+	NetworkFlag = cli.StringFlag{
+		Name:  "network",
+		Usage: "Network preset setting the genesis, bootnodes, network and chain IDs (" + strings.Join(core.NetworkNames(), ", ") + ")",
+	}
 	TestnetFlag = cli.BoolFlag{
 		Name:  "testnet",
 		Usage: "Wan test network: pre-configured proof-of-work test network",
@@ -156,6 +188,10 @@ var (
 		Usage: "Document Root for HTTPClient file scheme",
 		Value: DirectoryString{homeDir()},
 	}
+	ProfileFlag = cli.StringFlag{
+		Name:  "profile",
+		Usage: "Node profile bundling cache, peer and txpool defaults (light-home, exchange, archive)",
+	}
 	FastSyncFlag = cli.BoolFlag{
 		Name:  "fast",
 		Usage: "Enable fast syncing through state downloads",
@@ -215,6 +251,60 @@ var (
 		Usage: "Number of recent ethash mining DAGs to keep on disk (1+GB each)",
 		Value: eth.DefaultConfig.EthashDatasetsOnDisk,
 	}
+	// Hot standby replication settings
+	ReplicationPrimaryFlag = cli.StringFlag{
+		Name:  "replication.primary",
+		Usage: "RPC endpoint of a primary node to follow as a hot standby",
+	}
+	ReplicationSecretFlag = cli.StringFlag{
+		Name:  "replication.secret",
+		Usage: "Shared secret authenticating replication between primary and standby",
+	}
+	// Faucet settings
+	FaucetAddrFlag = cli.StringFlag{
+		Name:  "faucet.addr",
+		Usage: "Listening address of the testnet faucet endpoint (disabled if empty)",
+	}
+	FaucetAccountFlag = cli.StringFlag{
+		Name:  "faucet.account",
+		Usage: "Unlocked account the faucet sends funds from",
+	}
+	FaucetPeriodFlag = cli.DurationFlag{
+		Name:  "faucet.period",
+		Usage: "Minimum time between two fundings of the same client or recipient",
+		Value: faucet.DefaultConfig.Period,
+	}
+	FaucetAPIKeysFlag = cli.StringFlag{
+		Name:  "faucet.apikeys",
+		Usage: "Comma separated API keys authorizing faucet requests without captcha",
+	}
+	FaucetCaptchaSecretFlag = cli.StringFlag{
+		Name:  "faucet.captcha",
+		Usage: "Recaptcha secret key verifying the faucet requests without API key",
+	}
+	// Wallet notification settings
+	NotifyAccountsFlag = cli.StringFlag{
+		Name:  "notify.accounts",
+		Usage: "Comma separated accounts whose OTA deposits are notified, their view keys being decrypted with --password (disabled if empty)",
+	}
+	NotifyWebhooksFlag = cli.StringFlag{
+		Name:  "notify.webhooks",
+		Usage: "Comma separated URLs the deposit notifications are POSTed to",
+	}
+	NotifyMQTTFlag = cli.StringFlag{
+		Name:  "notify.mqtt",
+		Usage: "MQTT broker the deposit notifications are published to (tcp:// or tls:// URL)",
+	}
+	NotifyMQTTTopicFlag = cli.StringFlag{
+		Name:  "notify.mqtttopic",
+		Usage: "MQTT topic the deposit notifications are published to",
+		Value: walletnotify.DefaultConfig.MQTTTopic,
+	}
+	NotifyConfirmationsFlag = cli.Uint64Flag{
+		Name:  "notify.confirmations",
+		Usage: "Confirmations after which a deposit is notified again as confirmed",
+		Value: walletnotify.DefaultConfig.Confirmations,
+	}
 	// Transaction pool settings
 	TxPoolNoLocalsFlag = cli.BoolFlag{
 		Name:  "txpool.nolocals",
@@ -265,6 +355,37 @@ var (
 		Usage: "Maximum amount of time non-executable transaction are queued",
 		Value: eth.DefaultConfig.TxPool.Lifetime,
 	}
+	TxPoolTTLFlag = cli.DurationFlag{
+		Name:  "txpool.ttl",
+		Usage: "Maximum amount of time any transaction stays in the pool (0 = unlimited)",
+		Value: eth.DefaultConfig.TxPool.TTL,
+	}
+	TxPoolBlacklistFlag = cli.StringFlag{
+		Name:  "txpool.blacklist",
+		Usage: "File listing the addresses whose transactions this node rejects (reloaded on change)",
+	}
+	TxPoolBlacklistAuditFlag = cli.StringFlag{
+		Name:  "txpool.blacklistaudit",
+		Usage: "File logging the transactions rejected by the blacklist",
+	}
+	TxPoolPrivacyTTLFlag = cli.DurationFlag{
+		Name:  "txpool.privacyttl",
+		Usage: "Maximum amount of time privacy transactions stay in the pool (0 = txpool.ttl)",
+		Value: eth.DefaultConfig.TxPool.PrivacyTTL,
+	}
+	TxPoolRelayUnknownFlag = cli.BoolFlag{
+		Name:  "txpool.relayunknown",
+		Usage: "Relays the remote transactions of unknown types to the peers accepting them, instead of rejecting them",
+	}
+	TxPoolUnknownSlotsFlag = cli.Uint64Flag{
+		Name:  "txpool.unknownslots",
+		Usage: "Maximum number of unknown type transactions retained for relay",
+		Value: eth.DefaultConfig.TxPool.UnknownSlots,
+	}
+	DandelionFlag = cli.BoolFlag{
+		Name:  "dandelion",
+		Usage: "Relays the local privacy transactions along a random stem of peers before broadcasting them",
+	}
 	// Performance tuning settings
 	CacheFlag = cli.IntFlag{
 		Name:  "cache",
@@ -305,6 +426,10 @@ var (
 		Name:  "extradata",
 		Usage: "Block extra data set by the miner (default = client version)",
 	}
+	MinerMaxClockDriftFlag = cli.DurationFlag{
+		Name:  "maxclockdrift",
+		Usage: "Clock drift measured by NTP above which the miner refuses to seal (0 = unlimited)",
+	}
 	// Account settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -321,6 +446,22 @@ var (
 		Name:  "vmdebug",
 		Usage: "Record information useful for VM and contract debugging",
 	}
+	VMProfileFlag = cli.BoolFlag{
+		Name:  "vmprofile",
+		Usage: "Profile the execution time of the opcodes and precompiled contracts (debug_vmProfile)",
+	}
+	ParallelExecFlag = cli.IntFlag{
+		Name:  "parallelexec",
+		Usage: "Number of workers executing the transactions of imported blocks in parallel (experimental, 0 = serial)",
+	}
+	StorageLayoutsFlag = cli.StringFlag{
+		Name:  "storagelayouts",
+		Usage: "JSON descriptor file or directory of contract storage layouts to decode in dumps and traces",
+	}
+	BlockPluginsRejectFlag = cli.StringFlag{
+		Name:  "blockplugins.reject",
+		Usage: "Comma separated block plugins rejecting the blocks failing their checks (others only warn)",
+	}
 	// Logging and debug settings
 	EthStatsURLFlag = cli.StringFlag{
 		Name:  "ethstats",
@@ -371,6 +512,25 @@ var (
 		Name:  "ipcpath",
 		Usage: "Filename for IPC socket/pipe within the datadir (explicit paths escape it)",
 	}
+	HealthAddrFlag = cli.StringFlag{
+		Name:  "health.addr",
+		Usage: "Listening interface and port of the /health and /ready endpoints (e.g. 127.0.0.1:8550)",
+	}
+	HealthMinPeersFlag = cli.IntFlag{
+		Name:  "health.minpeers",
+		Usage: "Peers needed to be ready",
+		Value: node.DefaultConfig.Health.MinPeers,
+	}
+	HealthMaxHeadAgeFlag = cli.DurationFlag{
+		Name:  "health.maxheadage",
+		Usage: "Age of the last imported block above which the node isn't ready (0 = unchecked)",
+		Value: node.DefaultConfig.Health.MaxHeadAge,
+	}
+	HealthMaxLagFlag = cli.Uint64Flag{
+		Name:  "health.maxlag",
+		Usage: "Blocks the node may lag behind its peers and still be ready",
+		Value: node.DefaultConfig.Health.MaxLag,
+	}
 	WSEnabledFlag = cli.BoolFlag{
 		Name:  "ws",
 		Usage: "Enable the WS-RPC server",
@@ -500,15 +660,8 @@ var (
 // the a subdirectory of the specified datadir will be used.
 func MakeDataDir(ctx *cli.Context) string {
 	if path := ctx.GlobalString(DataDirFlag.Name); path != "" {
-		if ctx.GlobalBool(TestnetFlag.Name) {
-			return filepath.Join(path, "testnet")
-		}
-
-		if ctx.GlobalBool(DevInternalFlag.Name) {
-			return filepath.Join(path, "interal")
-		}
-		if ctx.GlobalBool(PlutoFlag.Name) {
-			return filepath.Join(path, "pluto")
+		if network := selectedNetwork(ctx); network != nil && network.DataDir != "" {
+			return filepath.Join(path, network.DataDir)
 		}
 		return path
 	}
@@ -516,6 +669,28 @@ func MakeDataDir(ctx *cli.Context) string {
 	return ""
 }
 
+// selectedNetwork returns the preset of the network selected by --network or
+// by one of the legacy network flags, or nil if none is.
+func selectedNetwork(ctx *cli.Context) *core.Network {
+	name := ctx.GlobalString(NetworkFlag.Name)
+	switch {
+	case name != "":
+	case ctx.GlobalBool(TestnetFlag.Name):
+		name = "testnet"
+	case ctx.GlobalBool(DevInternalFlag.Name):
+		name = "internal"
+	case ctx.GlobalBool(PlutoFlag.Name):
+		name = "pluto"
+	default:
+		return nil
+	}
+	network, ok := core.Networks[name]
+	if !ok {
+		Fatalf("Unknown network %q, known networks: %s", name, strings.Join(core.NetworkNames(), ", "))
+	}
+	return network
+}
+
 // setNodeKey creates a node key from set command line flags, either loading it
 // from a file or as a specified hex value. If neither flags were provided, this
 // method returns nil and an emphemeral key is to be generated.
@@ -553,19 +728,15 @@ func setNodeUserIdent(ctx *cli.Context, cfg *node.Config) {
 // flags, reverting to pre-configured ones if none have been specified.
 func setBootstrapNodes(ctx *cli.Context, cfg *p2p.Config) {
 	urls := params.MainnetBootnodes
-	switch {
+	switch network := selectedNetwork(ctx); {
 	case ctx.GlobalIsSet(BootnodesFlag.Name) || ctx.GlobalIsSet(BootnodesV4Flag.Name):
 		if ctx.GlobalIsSet(BootnodesV4Flag.Name) {
 			urls = strings.Split(ctx.GlobalString(BootnodesV4Flag.Name), ",")
 		} else {
 			urls = strings.Split(ctx.GlobalString(BootnodesFlag.Name), ",")
 		}
-	case ctx.GlobalBool(TestnetFlag.Name):
-		urls = params.TestnetBootnodes
-	case ctx.GlobalBool(DevInternalFlag.Name):
-		urls = params.InternalBootnodes
-	case ctx.GlobalBool(PlutoFlag.Name):
-		urls = params.PlutoBootnodes
+	case network != nil:
+		urls = network.Bootnodes
 	}
 
 	cfg.BootstrapNodes = make([]*discover.Node, 0, len(urls))
@@ -583,17 +754,15 @@ func setBootstrapNodes(ctx *cli.Context, cfg *p2p.Config) {
 // flags, reverting to pre-configured ones if none have been specified.
 func setBootstrapNodesV5(ctx *cli.Context, cfg *p2p.Config) {
 	urls := params.DiscoveryV5Bootnodes
-	switch {
+	switch network := selectedNetwork(ctx); {
 	case ctx.GlobalIsSet(BootnodesFlag.Name) || ctx.GlobalIsSet(BootnodesV5Flag.Name):
 		if ctx.GlobalIsSet(BootnodesV5Flag.Name) {
 			urls = strings.Split(ctx.GlobalString(BootnodesV5Flag.Name), ",")
 		} else {
 			urls = strings.Split(ctx.GlobalString(BootnodesFlag.Name), ",")
 		}
-	case ctx.GlobalBool(DevInternalFlag.Name):
-		urls = params.InternalV5Bootnodes
-	case ctx.GlobalBool(PlutoFlag.Name):
-		urls = params.PlutoV5ootnodes
+	case network != nil && network.BootnodesV5 != nil:
+		urls = network.BootnodesV5
 	case cfg.BootstrapNodesV5 != nil:
 		return // already set, don't apply defaults.
 	}
@@ -667,6 +836,22 @@ func setHTTP(ctx *cli.Context, cfg *node.Config) {
 	}
 }
 
+// setHealth applies the health endpoint flags to the node configuration.
+func setHealth(ctx *cli.Context, cfg *node.Config) {
+	if ctx.GlobalIsSet(HealthAddrFlag.Name) {
+		cfg.Health.Addr = ctx.GlobalString(HealthAddrFlag.Name)
+	}
+	if ctx.GlobalIsSet(HealthMinPeersFlag.Name) {
+		cfg.Health.MinPeers = ctx.GlobalInt(HealthMinPeersFlag.Name)
+	}
+	if ctx.GlobalIsSet(HealthMaxHeadAgeFlag.Name) {
+		cfg.Health.MaxHeadAge = ctx.GlobalDuration(HealthMaxHeadAgeFlag.Name)
+	}
+	if ctx.GlobalIsSet(HealthMaxLagFlag.Name) {
+		cfg.Health.MaxLag = ctx.GlobalUint64(HealthMaxLagFlag.Name)
+	}
+}
+
 // setWS creates the WebSocket RPC listener interface string from the set
 // command line flags, returning empty if the HTTP endpoint is disabled.
 func setWS(ctx *cli.Context, cfg *node.Config) {
@@ -736,23 +921,26 @@ func MakeAddress(ks *keystore.KeyStore, account string) (accounts.Account, error
 }
 
 // setEtherbase retrieves the etherbase either from the directly specified
-// command line flags or from the keystore if CLI indexed.
-func setEtherbase(ctx *cli.Context, ks *keystore.KeyStore, cfg *eth.Config) {
+// command line flags or from the keystores if CLI indexed. The default one is
+// the first account of the keystores allowing to unlock it.
+func setEtherbase(ctx *cli.Context, am *accounts.Manager, cfg *eth.Config) {
 	if ctx.GlobalIsSet(EtherbaseFlag.Name) {
-		account, err := MakeAddress(ks, ctx.GlobalString(EtherbaseFlag.Name))
+		account, err := MakeAddress(keystore.PrimaryKeyStore(am), ctx.GlobalString(EtherbaseFlag.Name))
 		if err != nil {
 			Fatalf("Option %q: %v", EtherbaseFlag.Name, err)
 		}
 		cfg.Etherbase = account.Address
 		return
 	}
-	accounts := ks.Accounts()
 	if (cfg.Etherbase == common.Address{}) {
-		if len(accounts) > 0 {
-			cfg.Etherbase = accounts[0].Address
-		} else {
-			log.Warn("No etherbase set and no accounts found as default")
+		for _, backend := range am.Backends(keystore.KeyStoreType) {
+			ks := backend.(*keystore.KeyStore)
+			if accs := ks.Accounts(); len(accs) > 0 && !ks.Policy().NoUnlock {
+				cfg.Etherbase = accs[0].Address
+				return
+			}
 		}
+		log.Warn("No etherbase set and no accounts found as default")
 	}
 }
 
@@ -781,6 +969,7 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 	setDiscoveryV5Address(ctx, cfg)
 	setBootstrapNodes(ctx, cfg)
 	setBootstrapNodesV5(ctx, cfg)
+	setP2PProfile(ctx, cfg)
 
 	if ctx.GlobalIsSet(MaxPeersFlag.Name) {
 		cfg.MaxPeers = ctx.GlobalInt(MaxPeersFlag.Name)
@@ -826,27 +1015,52 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	setIPC(ctx, cfg)
 	setHTTP(ctx, cfg)
 	setWS(ctx, cfg)
+	setHealth(ctx, cfg)
 	setNodeUserIdent(ctx, cfg)
 
-	switch {
+	switch network := selectedNetwork(ctx); {
 	case ctx.GlobalIsSet(DataDirFlag.Name):
 		cfg.DataDir = ctx.GlobalString(DataDirFlag.Name)
 	case ctx.GlobalBool(DevModeFlag.Name):
 		cfg.DataDir = filepath.Join(os.TempDir(), "ethereum_dev_mode")
-	case ctx.GlobalBool(TestnetFlag.Name):
-		cfg.DataDir = filepath.Join(node.DefaultDataDir(), "testnet")
-	case ctx.GlobalBool(DevInternalFlag.Name):
-		cfg.DataDir = filepath.Join(node.DefaultDataDir(), "internal")
-	case ctx.GlobalBool(PlutoFlag.Name):
-		cfg.DataDir = filepath.Join(node.DefaultDataDir(), "pluto")
+	case network != nil && network.DataDir != "":
+		cfg.DataDir = filepath.Join(node.DefaultDataDir(), network.DataDir)
 	}
 
 	if ctx.GlobalIsSet(KeyStoreDirFlag.Name) {
 		cfg.KeyStoreDir = ctx.GlobalString(KeyStoreDirFlag.Name)
 	}
+	if ctx.GlobalIsSet(ReadOnlyKeyStoresFlag.Name) {
+		for _, dir := range strings.Split(ctx.GlobalString(ReadOnlyKeyStoresFlag.Name), ",") {
+			if dir = strings.TrimSpace(dir); dir != "" {
+				cfg.KeyStores = append(cfg.KeyStores, node.KeyStoreConfig{Dir: expandPath(dir), ReadOnly: true})
+			}
+		}
+	}
 	if ctx.GlobalIsSet(LightKDFFlag.Name) {
 		cfg.UseLightweightKDF = ctx.GlobalBool(LightKDFFlag.Name)
 	}
+	if ctx.GlobalIsSet(ScryptTargetFlag.Name) {
+		cfg.ScryptTarget = ctx.GlobalDuration(ScryptTargetFlag.Name)
+	}
+	if ctx.GlobalIsSet(KeyCacheSizeFlag.Name) {
+		cfg.KeyCacheSize = ctx.GlobalInt(KeyCacheSizeFlag.Name)
+	}
+	if ctx.GlobalIsSet(KeyCacheTimeoutFlag.Name) {
+		cfg.KeyCacheTimeout = ctx.GlobalDuration(KeyCacheTimeoutFlag.Name)
+	}
+	if ctx.GlobalIsSet(SignerAuditLogFlag.Name) {
+		cfg.SignerAuditLog = ctx.GlobalString(SignerAuditLogFlag.Name)
+	}
+	if ctx.GlobalIsSet(KMSKeysFlag.Name) {
+		for _, key := range strings.Split(ctx.GlobalString(KMSKeysFlag.Name), ",") {
+			parts := strings.SplitN(strings.TrimSpace(key), ":", 2)
+			if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+				Fatalf("Invalid KMS key %q, want provider:keyid", key)
+			}
+			cfg.KMSKeys = append(cfg.KMSKeys, kms.KeyConfig{Provider: parts[0], KeyID: parts[1]})
+		}
+	}
 	if ctx.GlobalIsSet(NoUSBFlag.Name) {
 		cfg.NoUSB = ctx.GlobalBool(NoUSBFlag.Name)
 	}
@@ -892,6 +1106,24 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolLifetimeFlag.Name) {
 		cfg.Lifetime = ctx.GlobalDuration(TxPoolLifetimeFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolTTLFlag.Name) {
+		cfg.TTL = ctx.GlobalDuration(TxPoolTTLFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolPrivacyTTLFlag.Name) {
+		cfg.PrivacyTTL = ctx.GlobalDuration(TxPoolPrivacyTTLFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolBlacklistFlag.Name) {
+		cfg.Blacklist = ctx.GlobalString(TxPoolBlacklistFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolBlacklistAuditFlag.Name) {
+		cfg.BlacklistAudit = ctx.GlobalString(TxPoolBlacklistAuditFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolRelayUnknownFlag.Name) {
+		cfg.RelayUnknown = ctx.GlobalBool(TxPoolRelayUnknownFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolUnknownSlotsFlag.Name) {
+		cfg.UnknownSlots = ctx.GlobalUint64(TxPoolUnknownSlotsFlag.Name)
+	}
 }
 
 func setEthash(ctx *cli.Context, cfg *eth.Config) {
@@ -937,14 +1169,66 @@ func SetShhConfig(ctx *cli.Context, stack *node.Node, cfg *whisper.Config) {
 	}
 }
 
+// SetFaucetConfig applies faucet-related command line flags to the config.
+func SetFaucetConfig(ctx *cli.Context, cfg *faucet.Config) {
+	if ctx.GlobalIsSet(FaucetAddrFlag.Name) {
+		cfg.Addr = ctx.GlobalString(FaucetAddrFlag.Name)
+	}
+	if ctx.GlobalIsSet(FaucetAccountFlag.Name) {
+		account := ctx.GlobalString(FaucetAccountFlag.Name)
+		if !common.IsHexAddress(account) {
+			Fatalf("Invalid faucet account: %s", account)
+		}
+		cfg.Account = common.HexToAddress(account)
+	}
+	if ctx.GlobalIsSet(FaucetPeriodFlag.Name) {
+		cfg.Period = ctx.GlobalDuration(FaucetPeriodFlag.Name)
+	}
+	if ctx.GlobalIsSet(FaucetAPIKeysFlag.Name) {
+		cfg.APIKeys = strings.Split(ctx.GlobalString(FaucetAPIKeysFlag.Name), ",")
+	}
+	if ctx.GlobalIsSet(FaucetCaptchaSecretFlag.Name) {
+		cfg.CaptchaSecret = ctx.GlobalString(FaucetCaptchaSecretFlag.Name)
+	}
+}
+
+// SetWalletNotifyConfig applies wallet notification related command line flags
+// to the config.
+func SetWalletNotifyConfig(ctx *cli.Context, cfg *walletnotify.Config) {
+	if ctx.GlobalIsSet(NotifyAccountsFlag.Name) {
+		cfg.Accounts = nil
+		for _, account := range strings.Split(ctx.GlobalString(NotifyAccountsFlag.Name), ",") {
+			if account = strings.TrimSpace(account); !common.IsHexAddress(account) {
+				Fatalf("Invalid notification account: %s", account)
+			}
+			cfg.Accounts = append(cfg.Accounts, common.HexToAddress(account))
+		}
+	}
+	if len(cfg.Accounts) > 0 {
+		cfg.Passwords = MakePasswordList(ctx)
+	}
+	if ctx.GlobalIsSet(NotifyWebhooksFlag.Name) {
+		cfg.Webhooks = strings.Split(ctx.GlobalString(NotifyWebhooksFlag.Name), ",")
+	}
+	if ctx.GlobalIsSet(NotifyMQTTFlag.Name) {
+		cfg.MQTTBroker = ctx.GlobalString(NotifyMQTTFlag.Name)
+	}
+	if ctx.GlobalIsSet(NotifyMQTTTopicFlag.Name) {
+		cfg.MQTTTopic = ctx.GlobalString(NotifyMQTTTopicFlag.Name)
+	}
+	if ctx.GlobalIsSet(NotifyConfirmationsFlag.Name) {
+		cfg.Confirmations = ctx.GlobalUint64(NotifyConfirmationsFlag.Name)
+	}
+}
+
 // SetEthConfig applies eth-related command line flags to the config.
 func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	// Avoid conflicting network flags
-	checkExclusive(ctx, DevModeFlag, TestnetFlag, DevInternalFlag, PlutoFlag)
+	checkExclusive(ctx, DevModeFlag, NetworkFlag, TestnetFlag, DevInternalFlag, PlutoFlag)
 	checkExclusive(ctx, FastSyncFlag, LightModeFlag, SyncModeFlag)
 
-	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
-	setEtherbase(ctx, ks, cfg)
+	setEthProfile(ctx, cfg)
+	setEtherbase(ctx, stack.AccountManager(), cfg)
 	setGPO(ctx, &cfg.GPO)
 	setTxPool(ctx, &cfg.TxPool)
 	setEthash(ctx, cfg)
@@ -957,6 +1241,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	case ctx.GlobalBool(LightModeFlag.Name):
 		cfg.SyncMode = downloader.LightSync
 	}
+	if ctx.GlobalIsSet(DandelionFlag.Name) {
+		cfg.Dandelion = ctx.GlobalBool(DandelionFlag.Name)
+	}
 	if ctx.GlobalIsSet(LightServFlag.Name) {
 		cfg.LightServ = ctx.GlobalInt(LightServFlag.Name)
 	}
@@ -984,28 +1271,39 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	if ctx.GlobalIsSet(GasPriceFlag.Name) {
 		cfg.GasPrice = GlobalBig(ctx, GasPriceFlag.Name)
 	}
+	if ctx.GlobalIsSet(MinerMaxClockDriftFlag.Name) {
+		cfg.MinerMaxClockDrift = ctx.GlobalDuration(MinerMaxClockDriftFlag.Name)
+	}
+	if ctx.GlobalIsSet(ReplicationPrimaryFlag.Name) {
+		cfg.ReplicationPrimary = ctx.GlobalString(ReplicationPrimaryFlag.Name)
+	}
+	if ctx.GlobalIsSet(ReplicationSecretFlag.Name) {
+		cfg.ReplicationSecret = ctx.GlobalString(ReplicationSecretFlag.Name)
+	}
 	if ctx.GlobalIsSet(VMEnableDebugFlag.Name) {
 		// TODO(fjl): force-enable this in --dev mode
 		cfg.EnablePreimageRecording = ctx.GlobalBool(VMEnableDebugFlag.Name)
 	}
+	if ctx.GlobalIsSet(VMProfileFlag.Name) {
+		cfg.EnableVMProfiling = ctx.GlobalBool(VMProfileFlag.Name)
+	}
+	if ctx.GlobalIsSet(ParallelExecFlag.Name) {
+		cfg.ParallelExecution = ctx.GlobalInt(ParallelExecFlag.Name)
+	}
+	if ctx.GlobalIsSet(StorageLayoutsFlag.Name) {
+		cfg.StorageLayouts = ctx.GlobalString(StorageLayoutsFlag.Name)
+	}
+	if ctx.GlobalIsSet(BlockPluginsRejectFlag.Name) {
+		cfg.BlockPluginsReject = strings.Split(ctx.GlobalString(BlockPluginsRejectFlag.Name), ",")
+	}
 
 	// Override any default configs for hard coded networks.
-	switch {
-	case ctx.GlobalBool(TestnetFlag.Name):
-		if !ctx.GlobalIsSet(NetworkIdFlag.Name) {
-			cfg.NetworkId = 3
-		}
-		cfg.Genesis = core.DefaultTestnetGenesisBlock()
-	case ctx.GlobalBool(DevInternalFlag.Name):
-		if !ctx.GlobalIsSet(NetworkIdFlag.Name) {
-			cfg.NetworkId = 4
-		}
-		cfg.Genesis = core.DefaultInternalGenesisBlock()
-	case ctx.GlobalBool(PlutoFlag.Name):
+	switch network := selectedNetwork(ctx); {
+	case network != nil:
 		if !ctx.GlobalIsSet(NetworkIdFlag.Name) {
-			cfg.NetworkId = 6
+			cfg.NetworkId = network.NetworkId
 		}
-		cfg.Genesis = core.DefaultPlutoGenesisBlock()
+		cfg.Genesis = network.Genesis()
 	case ctx.GlobalBool(DevModeFlag.Name):
 		cfg.Genesis = core.DevGenesisBlock()
 		if !ctx.GlobalIsSet(GasPriceFlag.Name) {
@@ -1068,6 +1366,34 @@ func RegisterEthStatsService(stack *node.Node, url string) {
 	}
 }
 
+// RegisterFaucetService configures the testnet faucet and adds it to the
+// given node.
+func RegisterFaucetService(stack *node.Node, cfg *faucet.Config) {
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		var ethServ *eth.Ethereum
+		if err := ctx.Service(&ethServ); err != nil {
+			return nil, err
+		}
+		return faucet.New(cfg, ethServ)
+	}); err != nil {
+		Fatalf("Failed to register the faucet service: %v", err)
+	}
+}
+
+// RegisterWalletNotifyService configures the wallet notification bridge and
+// adds it to the given node.
+func RegisterWalletNotifyService(stack *node.Node, cfg *walletnotify.Config) {
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		var ethServ *eth.Ethereum
+		if err := ctx.Service(&ethServ); err != nil {
+			return nil, err
+		}
+		return walletnotify.New(cfg, ethServ)
+	}); err != nil {
+		Fatalf("Failed to register the wallet notification service: %v", err)
+	}
+}
+
 // SetupNetwork configures the system for either the main net or some test network.
 func SetupNetwork(ctx *cli.Context) {
 	// TODO(fjl): move target gas limit into config
@@ -1093,13 +1419,9 @@ func MakeChainDatabase(ctx *cli.Context, stack *node.Node) ethdb.Database {
 
 func MakeGenesis(ctx *cli.Context) *core.Genesis {
 	var genesis *core.Genesis
-	switch {
-	case ctx.GlobalBool(TestnetFlag.Name):
-		genesis = core.DefaultTestnetGenesisBlock()
-	case ctx.GlobalBool(DevInternalFlag.Name):
-		genesis = core.DefaultInternalGenesisBlock()
-	case ctx.GlobalBool(PlutoFlag.Name):
-		genesis = core.DefaultPlutoGenesisBlock()
+	switch network := selectedNetwork(ctx); {
+	case network != nil:
+		genesis = network.Genesis()
 	case ctx.GlobalBool(DevModeFlag.Name):
 		genesis = core.DevGenesisBlock()
 	}
@@ -1115,19 +1437,11 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 	if err != nil {
 		Fatalf("%v", err)
 	}
-	var engine consensus.Engine
-	if config.Clique != nil {
-		engine = clique.New(config.Clique, chainDb)
-	} else {
-		engine = ethash.NewFaker(chainDb)
-		if !ctx.GlobalBool(FakePoWFlag.Name) {
-			engine = ethash.New(
-				stack.ResolvePath(eth.DefaultConfig.EthashCacheDir), eth.DefaultConfig.EthashCachesInMem, eth.DefaultConfig.EthashCachesOnDisk,
-				stack.ResolvePath(eth.DefaultConfig.EthashDatasetDir), eth.DefaultConfig.EthashDatasetsInMem, eth.DefaultConfig.EthashDatasetsOnDisk, chainDb,
-			)
-		}
+	engine := MakeEngine(ctx, stack, config, chainDb)
+	vmcfg := vm.Config{
+		EnablePreimageRecording: ctx.GlobalBool(VMEnableDebugFlag.Name),
+		ParallelWorkers:         ctx.GlobalInt(ParallelExecFlag.Name),
 	}
-	vmcfg := vm.Config{EnablePreimageRecording: ctx.GlobalBool(VMEnableDebugFlag.Name)}
 	chain, err = core.NewBlockChain(chainDb, config, engine, vmcfg)
 	if err != nil {
 		Fatalf("Can't create BlockChain: %v", err)
@@ -1135,6 +1449,20 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 	return chain, chainDb
 }
 
+// MakeEngine creates the consensus engine of a chain from set command line flags.
+func MakeEngine(ctx *cli.Context, stack *node.Node, config *params.ChainConfig, chainDb ethdb.Database) consensus.Engine {
+	if config.Clique != nil {
+		return clique.New(config.Clique, chainDb)
+	}
+	if ctx.GlobalBool(FakePoWFlag.Name) {
+		return ethash.NewFaker(chainDb)
+	}
+	return ethash.New(
+		stack.ResolvePath(eth.DefaultConfig.EthashCacheDir), eth.DefaultConfig.EthashCachesInMem, eth.DefaultConfig.EthashCachesOnDisk,
+		stack.ResolvePath(eth.DefaultConfig.EthashDatasetDir), eth.DefaultConfig.EthashDatasetsInMem, eth.DefaultConfig.EthashDatasetsOnDisk, chainDb,
+	)
+}
+
 // MakeConsolePreloads retrieves the absolute paths for the console JavaScript
 // scripts to preload before starting.
 func MakeConsolePreloads(ctx *cli.Context) []string {
@@ -0,0 +1,37 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package utils
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/core"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// Tests that the network is selected by --network or by the legacy flags.
+func TestSelectedNetwork(t *testing.T) {
+	tests := []struct {
+		args []string
+		want *core.Network
+	}{
+		{nil, nil},
+		{[]string{"--network", "mainnet"}, core.Networks["mainnet"]},
+		{[]string{"--network", "pluto"}, core.Networks["pluto"]},
+		{[]string{"--testnet"}, core.Networks["testnet"]},
+		{[]string{"--internal"}, core.Networks["internal"]},
+	}
+	for _, tt := range tests {
+		set := flag.NewFlagSet("test", flag.ContinueOnError)
+		for _, f := range []cli.Flag{NetworkFlag, TestnetFlag, DevInternalFlag, PlutoFlag} {
+			f.Apply(set)
+		}
+		if err := set.Parse(tt.args); err != nil {
+			t.Fatal(err)
+		}
+		if have := selectedNetwork(cli.NewContext(cli.NewApp(), set, nil)); have != tt.want {
+			t.Errorf("%v: network mismatch: have %v, want %v", tt.args, have, tt.want)
+		}
+	}
+}
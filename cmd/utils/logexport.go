@@ -0,0 +1,267 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package utils
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/common/hexutil"
+	"github.com/wanchain/go-wanchain/core"
+	"github.com/wanchain/go-wanchain/ethdb"
+	"github.com/wanchain/go-wanchain/log"
+)
+
+// logExportBatch is the number of blocks exported between checkpoints.
+var logExportBatch uint64 = 1000
+
+const (
+	LogExportJSON = "json" // Newline delimited JSON records
+	LogExportCSV  = "csv"  // CSV records, with a header line
+)
+
+// Kinds of exported records.
+const (
+	exportedLogKind     = "log"     // Log emitted by a contract
+	exportedPrivacyKind = "privacy" // Stamp used and key images spent by a privacy transaction
+)
+
+// exportedLog is a record of the log export.
+type exportedLog struct {
+	Kind        string          `json:"type"`
+	BlockNumber uint64          `json:"blockNumber"`
+	BlockHash   common.Hash     `json:"blockHash"`
+	TxHash      common.Hash     `json:"transactionHash"`
+	TxIndex     uint            `json:"transactionIndex"`
+	Index       *uint           `json:"logIndex,omitempty"`
+	Address     *common.Address `json:"address,omitempty"`
+	Topics      []common.Hash   `json:"topics,omitempty"`
+	Data        hexutil.Bytes   `json:"data,omitempty"`
+	StampUsed   *hexutil.Big    `json:"stampUsed,omitempty"`
+	KeyImages   []hexutil.Bytes `json:"keyImages,omitempty"`
+}
+
+var exportedLogColumns = []string{"type", "blockNumber", "blockHash", "transactionHash", "transactionIndex", "logIndex", "address", "topics", "data", "stampUsed", "keyImages"}
+
+func (l *exportedLog) csvRecord() []string {
+	record := []string{l.Kind, strconv.FormatUint(l.BlockNumber, 10), l.BlockHash.Hex(), l.TxHash.Hex(), strconv.FormatUint(uint64(l.TxIndex), 10), "", "", "", "", "", ""}
+	if l.Index != nil {
+		record[5] = strconv.FormatUint(uint64(*l.Index), 10)
+	}
+	if l.Address != nil {
+		record[6] = l.Address.Hex()
+	}
+	topics := make([]string, len(l.Topics))
+	for i, topic := range l.Topics {
+		topics[i] = topic.Hex()
+	}
+	record[7] = strings.Join(topics, ";")
+	if len(l.Data) > 0 {
+		record[8] = l.Data.String()
+	}
+	if l.StampUsed != nil {
+		record[9] = l.StampUsed.ToInt().String()
+	}
+	images := make([]string, len(l.KeyImages))
+	for i, image := range l.KeyImages {
+		images[i] = image.String()
+	}
+	record[10] = strings.Join(images, ";")
+	return record
+}
+
+// logExportCheckpoint records the progress of a log export, so an interrupted
+// export resumes where it stopped instead of starting over.
+type logExportCheckpoint struct {
+	First  uint64 `json:"first"`
+	Last   uint64 `json:"last"`
+	Format string `json:"format"`
+	Next   uint64 `json:"next"`   // First block not exported yet
+	Offset int64  `json:"offset"` // Size of the export file up to the next block
+}
+
+func (c *logExportCheckpoint) store(fn string) error {
+	blob, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	// Replace the checkpoint atomically, a torn one would restart the export
+	if err := ioutil.WriteFile(fn+".tmp", blob, 0644); err != nil {
+		return err
+	}
+	return os.Rename(fn+".tmp", fn)
+}
+
+// ExportLogs streams the logs and the privacy events of the canonical blocks
+// first to last to the file fn, as newline delimited JSON or CSV records. The
+// progress is checkpointed to fn.checkpoint, which lets an interrupted export
+// of the same range resume where it stopped.
+func ExportLogs(db ethdb.Database, fn string, first, last uint64, format string) error {
+	// Watch for Ctrl-C while the export is running.
+	// If a signal is received, the export will stop at the next checkpoint.
+	interrupt := make(chan os.Signal, 1)
+	stop := make(chan struct{})
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	defer close(interrupt)
+	go func() {
+		if _, ok := <-interrupt; ok {
+			log.Info("Interrupted during log export, stopping at next checkpoint")
+		}
+		close(stop)
+	}()
+	return exportLogs(db, fn, first, last, format, stop)
+}
+
+func exportLogs(db ethdb.Database, fn string, first, last uint64, format string, stop <-chan struct{}) error {
+	if format != LogExportJSON && format != LogExportCSV {
+		return fmt.Errorf("unknown export format %q", format)
+	}
+	if first > last {
+		return fmt.Errorf("invalid block range %d-%d", first, last)
+	}
+	// Resume from the checkpoint of the same export if any
+	checkpointFn := fn + ".checkpoint"
+	checkpoint := &logExportCheckpoint{First: first, Last: last, Format: format, Next: first}
+	if blob, err := ioutil.ReadFile(checkpointFn); err == nil {
+		var stored logExportCheckpoint
+		if err := json.Unmarshal(blob, &stored); err != nil {
+			return fmt.Errorf("corrupted checkpoint %s: %v", checkpointFn, err)
+		}
+		if stored.First != first || stored.Last != last || stored.Format != format {
+			return fmt.Errorf("checkpoint %s belongs to the export of blocks %d-%d as %s", checkpointFn, stored.First, stored.Last, stored.Format)
+		}
+		checkpoint = &stored
+		log.Info("Resuming log export", "file", fn, "next", checkpoint.Next)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if checkpoint.Next > last {
+		log.Info("Log export already complete", "file", fn)
+		return nil
+	}
+	fh, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	// Drop anything written after the checkpoint, then append
+	if err := fh.Truncate(checkpoint.Offset); err != nil {
+		return err
+	}
+	if _, err := fh.Seek(checkpoint.Offset, io.SeekStart); err != nil {
+		return err
+	}
+	var (
+		buffer = bufio.NewWriter(fh)
+		csvw   *csv.Writer
+		jsonw  *json.Encoder
+	)
+	if format == LogExportCSV {
+		csvw = csv.NewWriter(buffer)
+		if checkpoint.Offset == 0 {
+			csvw.Write(exportedLogColumns)
+		}
+	} else {
+		jsonw = json.NewEncoder(buffer)
+	}
+	write := func(record *exportedLog) error {
+		if csvw != nil {
+			return csvw.Write(record.csvRecord())
+		}
+		return jsonw.Encode(record)
+	}
+	// flush writes the buffered records out and checkpoints the progress
+	flush := func(next uint64) error {
+		if csvw != nil {
+			csvw.Flush()
+			if err := csvw.Error(); err != nil {
+				return err
+			}
+		}
+		if err := buffer.Flush(); err != nil {
+			return err
+		}
+		if err := fh.Sync(); err != nil {
+			return err
+		}
+		offset, err := fh.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		checkpoint.Next, checkpoint.Offset = next, offset
+		return checkpoint.store(checkpointFn)
+	}
+	log.Info("Exporting logs", "file", fn, "first", checkpoint.Next, "last", last, "format", format)
+
+	for number := checkpoint.Next; number <= last; number++ {
+		hash := core.GetCanonicalHash(db, number)
+		if hash == (common.Hash{}) {
+			if err := flush(number); err != nil {
+				return err
+			}
+			return fmt.Errorf("block #%d not found", number)
+		}
+		for i, receipt := range core.GetBlockReceipts(db, hash, number) {
+			for _, l := range receipt.Logs {
+				index, address := l.Index, l.Address
+				record := &exportedLog{
+					Kind:        exportedLogKind,
+					BlockNumber: number,
+					BlockHash:   hash,
+					TxHash:      receipt.TxHash,
+					TxIndex:     uint(i),
+					Index:       &index,
+					Address:     &address,
+					Topics:      l.Topics,
+					Data:        l.Data,
+				}
+				if err := write(record); err != nil {
+					return err
+				}
+			}
+			if receipt.Privacy != nil {
+				record := &exportedLog{
+					Kind:        exportedPrivacyKind,
+					BlockNumber: number,
+					BlockHash:   hash,
+					TxHash:      receipt.TxHash,
+					TxIndex:     uint(i),
+					StampUsed:   (*hexutil.Big)(receipt.Privacy.StampUsed),
+				}
+				for _, image := range receipt.Privacy.KeyImages {
+					record.KeyImages = append(record.KeyImages, image)
+				}
+				if err := write(record); err != nil {
+					return err
+				}
+			}
+		}
+		if (number+1-first)%logExportBatch == 0 || number == last {
+			if err := flush(number + 1); err != nil {
+				return err
+			}
+			log.Info("Exported logs", "number", number)
+			if number == last {
+				break
+			}
+			select {
+			case <-stop:
+				return fmt.Errorf("interrupted at block #%d", number)
+			default:
+			}
+		}
+	}
+	log.Info("Exported logs", "file", fn)
+	return nil
+}
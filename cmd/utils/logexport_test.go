@@ -0,0 +1,128 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/ethdb"
+)
+
+// newLogExportDB creates a database holding blocks with one log receipt and
+// one privacy receipt each.
+func newLogExportDB(t *testing.T, blocks int) ethdb.Database {
+	db, _ := ethdb.NewMemDatabase()
+	for i := 0; i < blocks; i++ {
+		hash := common.BigToHash(big.NewInt(int64(i + 1)))
+		receipts := types.Receipts{
+			{
+				TxHash:            common.Hash{byte(i), 1},
+				CumulativeGasUsed: big.NewInt(21000),
+				GasUsed:           big.NewInt(21000),
+				Logs: []*types.Log{{
+					Address: common.Address{0xaa},
+					Topics:  []common.Hash{{0x01}, {0x02}},
+					Data:    []byte{byte(i)},
+					Index:   0,
+				}},
+			},
+			{
+				TxHash:            common.Hash{byte(i), 2},
+				CumulativeGasUsed: big.NewInt(42000),
+				GasUsed:           big.NewInt(21000),
+				Privacy:           &types.ReceiptPrivacy{StampUsed: big.NewInt(9e15), KeyImages: [][]byte{{0x03, 0x04}}},
+			},
+		}
+		if err := core.WriteCanonicalHash(db, hash, uint64(i)); err != nil {
+			t.Fatal(err)
+		}
+		if err := core.WriteBlockReceipts(db, hash, uint64(i), receipts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+// Tests that logs and privacy events are exported as JSON records, and that an
+// interrupted export resumes from its checkpoint, dropping partial output.
+func TestExportLogsResume(t *testing.T) {
+	defer func(batch uint64) { logExportBatch = batch }(logExportBatch)
+	logExportBatch = 1
+
+	dir, err := ioutil.TempDir("", "logexport")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db := newLogExportDB(t, 4)
+	full := filepath.Join(dir, "full.json")
+	if err := exportLogs(db, full, 0, 3, LogExportJSON, nil); err != nil {
+		t.Fatalf("failed to export logs: %v", err)
+	}
+	want, _ := ioutil.ReadFile(full)
+	lines := strings.Split(strings.TrimSpace(string(want)), "\n")
+	if len(lines) != 8 {
+		t.Fatalf("record count mismatch: have %d, want 8", len(lines))
+	}
+	var record exportedLog
+	if err := json.Unmarshal([]byte(lines[1]), &record); err != nil {
+		t.Fatalf("invalid record: %v", err)
+	}
+	if record.Kind != exportedPrivacyKind || record.StampUsed.ToInt().Int64() != 9e15 || len(record.KeyImages) != 1 {
+		t.Errorf("privacy record mismatch: %s", lines[1])
+	}
+	// Interrupt an export after its first checkpoint and leave junk behind
+	stop := make(chan struct{})
+	close(stop)
+
+	resumed := filepath.Join(dir, "resumed.json")
+	if err := exportLogs(db, resumed, 0, 3, LogExportJSON, stop); err == nil {
+		t.Fatalf("interrupted export succeeded")
+	}
+	fh, _ := os.OpenFile(resumed, os.O_APPEND|os.O_WRONLY, 0644)
+	fh.WriteString(`{"type":"partial`)
+	fh.Close()
+
+	if err := exportLogs(db, resumed, 0, 2, LogExportJSON, nil); err == nil {
+		t.Errorf("checkpoint of another range accepted")
+	}
+	if err := exportLogs(db, resumed, 0, 3, LogExportJSON, nil); err != nil {
+		t.Fatalf("failed to resume export: %v", err)
+	}
+	if have, _ := ioutil.ReadFile(resumed); !bytes.Equal(have, want) {
+		t.Errorf("resumed export mismatch:\nhave %s\nwant %s", have, want)
+	}
+}
+
+// Tests that the CSV export writes a header and one line per record.
+func TestExportLogsCSV(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logexport")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fn := filepath.Join(dir, "logs.csv")
+	if err := exportLogs(newLogExportDB(t, 2), fn, 0, 1, LogExportCSV, nil); err != nil {
+		t.Fatalf("failed to export logs: %v", err)
+	}
+	blob, _ := ioutil.ReadFile(fn)
+	lines := strings.Split(strings.TrimSpace(string(blob)), "\n")
+	if len(lines) != 5 || !strings.HasPrefix(lines[0], "type,blockNumber") {
+		t.Fatalf("csv export mismatch:\n%s", blob)
+	}
+	if !strings.Contains(lines[1], common.Hash{0x01}.Hex()+";"+common.Hash{0x02}.Hex()) {
+		t.Errorf("topics not exported: %s", lines[1])
+	}
+}
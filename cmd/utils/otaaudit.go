@@ -0,0 +1,204 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package utils
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/common/hexutil"
+	"github.com/wanchain/go-wanchain/core"
+	"github.com/wanchain/go-wanchain/core/state"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/core/vm"
+	"github.com/wanchain/go-wanchain/ethdb"
+	"github.com/wanchain/go-wanchain/log"
+)
+
+// Reasons an OTA of a denomination tree is reported as orphan.
+const (
+	OrphanFailedDeposit = "failed-deposit" // Only written by deposits that failed
+	OrphanNoDeposit     = "no-deposit"     // Not written by any deposit transaction
+	OrphanDenomination  = "denomination"   // Deposited to another denomination
+)
+
+// OrphanOTA is an OTA of a denomination tree no successful deposit accounts
+// for, along with the transaction that wrote it when known.
+type OrphanOTA struct {
+	Denomination *hexutil.Big  `json:"denomination"`
+	OTA          hexutil.Bytes `json:"ota"`
+	Reason       string        `json:"reason"`
+	BlockNumber  *uint64       `json:"blockNumber,omitempty"`
+	BlockHash    *common.Hash  `json:"blockHash,omitempty"`
+	TxHash       *common.Hash  `json:"transactionHash,omitempty"`
+	TxIndex      *uint         `json:"transactionIndex,omitempty"`
+}
+
+var orphanOTAColumns = []string{"denomination", "ota", "reason", "blockNumber", "blockHash", "transactionHash", "transactionIndex"}
+
+func (o *OrphanOTA) csvRecord() []string {
+	record := []string{o.Denomination.ToInt().String(), o.OTA.String(), o.Reason, "", "", "", ""}
+	if o.BlockNumber != nil {
+		record[3] = strconv.FormatUint(*o.BlockNumber, 10)
+		record[4] = o.BlockHash.Hex()
+		record[5] = o.TxHash.Hex()
+		record[6] = strconv.FormatUint(uint64(*o.TxIndex), 10)
+	}
+	return record
+}
+
+// otaDeposit is the provenance of a deposit transaction.
+type otaDeposit struct {
+	value  *big.Int
+	number uint64
+	hash   common.Hash
+	txHash common.Hash
+	index  uint
+}
+
+// AuditOrphanOTAs scans the denomination trees of the head state for OTAs that
+// were left behind by failed or partial deposits, and writes them to out as
+// newline delimited JSON or CSV records with the block and transaction that
+// wrote them. OTAs allocated in the genesis or deposited successfully with the
+// same denomination are sound and not reported. Only the given denominations
+// are scanned, all the supported ones if none. The orphan OTAs are returned.
+//
+// Deposits made by contracts rather than by a transaction to the privacy
+// precompiles cannot be traced from the blocks and are reported as no-deposit.
+func AuditOrphanOTAs(db ethdb.Database, out io.Writer, denominations []*big.Int, format string) ([]*OrphanOTA, error) {
+	if format != LogExportJSON && format != LogExportCSV {
+		return nil, fmt.Errorf("unknown report format %q", format)
+	}
+	if len(denominations) == 0 {
+		denominations = append(vm.GetSupportWanCoinOTABalances(), vm.GetSupportStampOTABalances()...)
+	}
+	for _, denomination := range denominations {
+		_, coin := vm.WanCoinValueSet[denomination.Text(16)]
+		_, stamp := vm.StampValueSet[denomination.Text(16)]
+		if !coin && !stamp {
+			return nil, fmt.Errorf("unknown denomination %v", denomination)
+		}
+	}
+	headHash := core.GetHeadBlockHash(db)
+	head := core.GetBlock(db, headHash, core.GetBlockNumber(db, headHash))
+	if head == nil {
+		return nil, fmt.Errorf("head block not found")
+	}
+	genesis := core.GetBlock(db, core.GetCanonicalHash(db, 0), 0)
+	if genesis == nil {
+		return nil, fmt.Errorf("genesis block not found")
+	}
+	statedb, err := state.New(head.Root(), state.NewDatabase(db))
+	if err != nil {
+		return nil, fmt.Errorf("head state not found: %v", err)
+	}
+	genesisState, err := state.New(genesis.Root(), state.NewDatabase(db))
+	if err != nil {
+		return nil, fmt.Errorf("genesis state not found: %v", err)
+	}
+	log.Info("Auditing denomination trees", "number", head.NumberU64(), "hash", head.Hash(), "denominations", len(denominations))
+
+	// Trace the deposits of the canonical chain, keyed by the AX of the OTA
+	succeeded := make(map[common.Hash]*otaDeposit)
+	failed := make(map[common.Hash]*otaDeposit)
+	for number := uint64(1); number <= head.NumberU64(); number++ {
+		hash := core.GetCanonicalHash(db, number)
+		body := core.GetBody(db, hash, number)
+		if body == nil {
+			return nil, fmt.Errorf("block #%d body not found", number)
+		}
+		receipts := core.GetBlockReceipts(db, hash, number)
+		for i, tx := range body.Transactions {
+			ota, value, _, ok := vm.UnpackDeposit(tx.To(), tx.Data())
+			if !ok || i >= len(receipts) {
+				continue
+			}
+			ax, err := vm.GetAXFromWanAddr(ota)
+			if err != nil {
+				continue
+			}
+			deposit := &otaDeposit{value: value, number: number, hash: hash, txHash: tx.Hash(), index: uint(i)}
+			if receipts[i].Status == types.ReceiptStatusSuccessful {
+				succeeded[common.BytesToHash(ax)] = deposit
+			} else if _, ok := failed[common.BytesToHash(ax)]; !ok {
+				failed[common.BytesToHash(ax)] = deposit
+			}
+		}
+		if number%100000 == 0 {
+			log.Info("Tracing OTA deposits", "number", number)
+		}
+	}
+	// Report the OTAs of the trees not accounted for
+	var (
+		orphans []*OrphanOTA
+		csvw    *csv.Writer
+	)
+	if format == LogExportCSV {
+		csvw = csv.NewWriter(out)
+		if err := csvw.Write(orphanOTAColumns); err != nil {
+			return nil, err
+		}
+	}
+	for _, denomination := range denominations {
+		var (
+			tree = vm.OTABalance2ContractAddr(denomination)
+			werr error
+		)
+		err := statedb.ForEachCommittedStorage(tree, nil, func(_, value []byte) bool {
+			ax, err := vm.GetAXFromWanAddr(value)
+			if err != nil {
+				return true
+			}
+			key := common.BytesToHash(ax)
+			if len(genesisState.GetStateByteArray(tree, key)) != 0 {
+				return true
+			}
+			orphan := &OrphanOTA{Denomination: (*hexutil.Big)(denomination), OTA: common.CopyBytes(value)}
+			deposit := succeeded[key]
+			switch {
+			case deposit != nil && deposit.value.Cmp(denomination) == 0:
+				return true
+			case deposit != nil:
+				orphan.Reason = OrphanDenomination
+			case failed[key] != nil:
+				orphan.Reason, deposit = OrphanFailedDeposit, failed[key]
+			default:
+				orphan.Reason = OrphanNoDeposit
+			}
+			if deposit != nil {
+				orphan.BlockNumber, orphan.BlockHash = &deposit.number, &deposit.hash
+				orphan.TxHash, orphan.TxIndex = &deposit.txHash, &deposit.index
+			}
+			orphans = append(orphans, orphan)
+
+			if csvw != nil {
+				werr = csvw.Write(orphan.csvRecord())
+			} else {
+				var blob []byte
+				if blob, werr = json.Marshal(orphan); werr == nil {
+					_, werr = out.Write(append(blob, '\n'))
+				}
+			}
+			return werr == nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if werr != nil {
+			return nil, werr
+		}
+	}
+	if csvw != nil {
+		csvw.Flush()
+		if err := csvw.Error(); err != nil {
+			return nil, err
+		}
+	}
+	log.Info("Audited denomination trees", "orphans", len(orphans))
+	return orphans, nil
+}
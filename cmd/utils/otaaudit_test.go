@@ -0,0 +1,137 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core"
+	"github.com/wanchain/go-wanchain/core/state"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/core/vm"
+	"github.com/wanchain/go-wanchain/ethdb"
+)
+
+// auditOTA returns an OTA wan address with the given AX marker.
+func auditOTA(marker byte) []byte {
+	ota := make([]byte, common.WAddressLength)
+	ota[1], ota[2] = marker, 0x42
+	return ota
+}
+
+// writeAuditBlock writes a canonical block with the given state, transactions
+// and receipts to the database.
+func writeAuditBlock(t *testing.T, db ethdb.Database, number uint64, parent common.Hash, root common.Hash, txs types.Transactions, receipts types.Receipts) *types.Block {
+	header := &types.Header{Number: new(big.Int).SetUint64(number), ParentHash: parent, Root: root, Difficulty: big.NewInt(1), GasLimit: big.NewInt(4712388), GasUsed: new(big.Int), Time: new(big.Int)}
+	block := types.NewBlock(header, txs, nil, receipts)
+	if err := core.WriteBlock(db, block); err != nil {
+		t.Fatal(err)
+	}
+	if err := core.WriteCanonicalHash(db, block.Hash(), number); err != nil {
+		t.Fatal(err)
+	}
+	if err := core.WriteBlockReceipts(db, block.Hash(), number, receipts); err != nil {
+		t.Fatal(err)
+	}
+	if err := core.WriteHeadBlockHash(db, block.Hash()); err != nil {
+		t.Fatal(err)
+	}
+	return block
+}
+
+// Tests that the OTAs of the denomination trees written by failed deposits, by
+// no deposit or by deposits of another denomination are reported with their
+// provenance, while the genesis and successfully deposited ones are not.
+func TestAuditOrphanOTAs(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	coin10, _ := new(big.Int).SetString(vm.Wancoin10, 10)
+	coin20, _ := new(big.Int).SetString(vm.Wancoin20, 10)
+
+	// The genesis allocates an OTA, the head state holds the deposits
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	vm.AddOTAIfNotExist(statedb, coin10, auditOTA(0))
+	genesisRoot, _ := statedb.CommitTo(db, true)
+	genesis := writeAuditBlock(t, db, 0, common.Hash{}, genesisRoot, nil, nil)
+
+	statedb, _ = state.New(genesisRoot, state.NewDatabase(db))
+	vm.AddOTAIfNotExist(statedb, coin10, auditOTA(1)) // deposited
+	vm.AddOTAIfNotExist(statedb, coin10, auditOTA(2)) // failed deposit
+	vm.AddOTAIfNotExist(statedb, coin10, auditOTA(3)) // no deposit
+	vm.AddOTAIfNotExist(statedb, coin10, auditOTA(4)) // deposited as 20
+	headRoot, _ := statedb.CommitTo(db, true)
+
+	var (
+		txs      types.Transactions
+		receipts types.Receipts
+	)
+	for i, deposit := range []struct {
+		ota    byte
+		value  *big.Int
+		status uint
+	}{{1, coin10, types.ReceiptStatusSuccessful}, {2, coin10, types.ReceiptStatusFailed}, {4, coin20, types.ReceiptStatusSuccessful}} {
+		to, data, err := vm.PackBuyCoinNote(auditOTA(deposit.ota), deposit.value)
+		if err != nil {
+			t.Fatalf("failed to pack deposit: %v", err)
+		}
+		tx := types.NewTransaction(uint64(i), to, deposit.value, big.NewInt(200000), big.NewInt(1), data)
+		txs = append(txs, tx)
+		receipts = append(receipts, &types.Receipt{TxHash: tx.Hash(), Status: deposit.status, CumulativeGasUsed: new(big.Int), GasUsed: new(big.Int)})
+	}
+	block := writeAuditBlock(t, db, 1, genesis.Hash(), headRoot, txs, receipts)
+
+	var out bytes.Buffer
+	orphans, err := AuditOrphanOTAs(db, &out, nil, LogExportJSON)
+	if err != nil {
+		t.Fatalf("audit failed: %v", err)
+	}
+	want := map[byte]string{2: OrphanFailedDeposit, 3: OrphanNoDeposit, 4: OrphanDenomination}
+	if len(orphans) != len(want) {
+		t.Fatalf("orphan count mismatch: have %d, want %d", len(orphans), len(want))
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != len(orphans) {
+		t.Fatalf("record count mismatch: have %d, want %d", len(lines), len(orphans))
+	}
+	for i, orphan := range orphans {
+		marker := orphan.OTA[1]
+		if orphan.Reason != want[marker] {
+			t.Errorf("OTA %d: reason mismatch: have %q, want %q", marker, orphan.Reason, want[marker])
+		}
+		if orphan.Denomination.ToInt().Cmp(coin10) != 0 {
+			t.Errorf("OTA %d: denomination mismatch: have %v, want %v", marker, orphan.Denomination, coin10)
+		}
+		switch marker {
+		case 2:
+			if orphan.BlockNumber == nil || *orphan.BlockNumber != 1 || *orphan.BlockHash != block.Hash() || *orphan.TxHash != txs[1].Hash() || *orphan.TxIndex != 1 {
+				t.Errorf("OTA %d: provenance mismatch", marker)
+			}
+		case 3:
+			if orphan.BlockNumber != nil {
+				t.Errorf("OTA %d: unexpected provenance", marker)
+			}
+		}
+		var record OrphanOTA
+		if err := json.Unmarshal([]byte(lines[i]), &record); err != nil {
+			t.Fatalf("record %d: invalid JSON: %v", i, err)
+		}
+		if !bytes.Equal(record.OTA, orphan.OTA) || record.Reason != orphan.Reason {
+			t.Errorf("record %d mismatch: have %+v, want %+v", i, record, orphan)
+		}
+	}
+	// Auditing the 20 wan tree alone reports nothing
+	out.Reset()
+	if orphans, err = AuditOrphanOTAs(db, &out, []*big.Int{coin20}, LogExportCSV); err != nil {
+		t.Fatalf("audit failed: %v", err)
+	}
+	if len(orphans) != 0 || strings.TrimSpace(out.String()) != strings.Join(orphanOTAColumns, ",") {
+		t.Errorf("unexpected report of the 20 wan tree: %q", out.String())
+	}
+	if _, err := AuditOrphanOTAs(db, &out, []*big.Int{big.NewInt(1)}, LogExportJSON); err == nil {
+		t.Errorf("unknown denomination accepted")
+	}
+}
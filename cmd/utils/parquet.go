@@ -0,0 +1,241 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// parquetMagic delimits Parquet files.
+var parquetMagic = []byte("PAR1")
+
+// Parquet physical types, converted types and enums used by the writer
+const (
+	parquetInt64     = 2
+	parquetByteArray = 6
+	parquetUTF8      = 0
+	parquetRequired  = 0
+	parquetPlain     = 0
+	parquetRLE       = 3
+	parquetDataPage  = 0
+	parquetVersion   = 1
+)
+
+// parquetColumn is a column of a Parquet file, holding either int64 or string
+// values.
+type parquetColumn struct {
+	name    string
+	numeric bool
+	data    bytes.Buffer // PLAIN encoded values
+}
+
+// parquetFile is a minimal Parquet writer: a single row group of required
+// columns, each a single uncompressed PLAIN encoded data page. It is enough
+// for the analytic tools to load the exported tables without a dependency.
+type parquetFile struct {
+	columns []*parquetColumn
+	rows    int64
+}
+
+func newParquetFile(columns []tableColumn) *parquetFile {
+	f := &parquetFile{columns: make([]*parquetColumn, len(columns))}
+	for i, column := range columns {
+		f.columns[i] = &parquetColumn{name: column.name, numeric: column.numeric}
+	}
+	return f
+}
+
+// append adds a row, holding an uint64 for the numeric columns and a string
+// for the others.
+func (f *parquetFile) append(row []interface{}) {
+	for i, column := range f.columns {
+		if column.numeric {
+			binary.Write(&column.data, binary.LittleEndian, int64(row[i].(uint64)))
+		} else {
+			s := row[i].(string)
+			binary.Write(&column.data, binary.LittleEndian, uint32(len(s)))
+			column.data.WriteString(s)
+		}
+	}
+	f.rows++
+}
+
+// writeTo writes the file: the magic, the column chunks, the footer and its
+// length, and the magic again.
+func (f *parquetFile) writeTo(w io.Writer) error {
+	var (
+		out    = new(bytes.Buffer)
+		chunks = new(thriftWriter)
+	)
+	out.Write(parquetMagic)
+
+	chunks.listHeader(thriftStruct, len(f.columns))
+	for _, column := range f.columns {
+		offset := int64(out.Len())
+
+		page := new(thriftWriter)
+		page.begin()
+		page.i32Field(1, parquetDataPage)
+		page.i32Field(2, int32(column.data.Len()))
+		page.i32Field(3, int32(column.data.Len()))
+		page.structField(5)
+		page.i32Field(1, int32(f.rows))
+		page.i32Field(2, parquetPlain)
+		page.i32Field(3, parquetRLE)
+		page.i32Field(4, parquetRLE)
+		page.end()
+		page.end()
+		out.Write(page.Bytes())
+		out.Write(column.data.Bytes())
+		size := int64(out.Len()) - offset
+
+		typ := int32(parquetByteArray)
+		if column.numeric {
+			typ = parquetInt64
+		}
+		chunks.begin()
+		chunks.i64Field(2, offset)
+		chunks.structField(3)
+		chunks.i32Field(1, typ)
+		chunks.listField(2, thriftI32, 1)
+		chunks.zigzag(parquetPlain)
+		chunks.listField(3, thriftBinary, 1)
+		chunks.binary(column.name)
+		chunks.i32Field(4, 0) // Uncompressed
+		chunks.i64Field(5, f.rows)
+		chunks.i64Field(6, size)
+		chunks.i64Field(7, size)
+		chunks.i64Field(9, offset)
+		chunks.end()
+		chunks.end()
+	}
+	footer := new(thriftWriter)
+	footer.begin()
+	footer.i32Field(1, parquetVersion)
+	footer.listField(2, thriftStruct, len(f.columns)+1)
+	footer.begin()
+	footer.binaryField(4, "schema")
+	footer.i32Field(5, int32(len(f.columns)))
+	footer.end()
+	for _, column := range f.columns {
+		footer.begin()
+		if column.numeric {
+			footer.i32Field(1, parquetInt64)
+			footer.i32Field(3, parquetRequired)
+			footer.binaryField(4, column.name)
+		} else {
+			footer.i32Field(1, parquetByteArray)
+			footer.i32Field(3, parquetRequired)
+			footer.binaryField(4, column.name)
+			footer.i32Field(6, parquetUTF8)
+		}
+		footer.end()
+	}
+	footer.i64Field(3, f.rows)
+	footer.listField(4, thriftStruct, 1)
+	footer.begin()
+	footer.fieldHeader(1, thriftList)
+	footer.Write(chunks.Bytes())
+	footer.i64Field(2, int64(out.Len())-int64(len(parquetMagic)))
+	footer.i64Field(3, f.rows)
+	footer.end()
+	footer.binaryField(6, "gwan")
+	footer.end()
+
+	out.Write(footer.Bytes())
+	binary.Write(out, binary.LittleEndian, uint32(footer.Len()))
+	out.Write(parquetMagic)
+
+	_, err := out.WriteTo(w)
+	return err
+}
+
+// Thrift compact protocol types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes the Parquet metadata with the Thrift compact protocol.
+// Every struct, nested or list element, is delimited by begin and end, which
+// track the last field id the field headers are delta encoded against.
+type thriftWriter struct {
+	bytes.Buffer
+	last []int16 // Last field id of the open structs
+}
+
+func (w *thriftWriter) varint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	w.Write(buf[:binary.PutUvarint(buf[:], v)])
+}
+
+func (w *thriftWriter) zigzag(v int64) {
+	w.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+// begin opens a struct.
+func (w *thriftWriter) begin() {
+	w.last = append(w.last, 0)
+}
+
+// end closes the current struct.
+func (w *thriftWriter) end() {
+	w.WriteByte(0)
+	w.last = w.last[:len(w.last)-1]
+}
+
+func (w *thriftWriter) fieldHeader(id int16, typ byte) {
+	last := &w.last[len(w.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		w.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		w.WriteByte(typ)
+		w.zigzag(int64(id))
+	}
+	*last = id
+}
+
+func (w *thriftWriter) i32Field(id int16, v int32) {
+	w.fieldHeader(id, thriftI32)
+	w.zigzag(int64(v))
+}
+
+func (w *thriftWriter) i64Field(id int16, v int64) {
+	w.fieldHeader(id, thriftI64)
+	w.zigzag(v)
+}
+
+func (w *thriftWriter) binary(s string) {
+	w.varint(uint64(len(s)))
+	w.WriteString(s)
+}
+
+func (w *thriftWriter) binaryField(id int16, s string) {
+	w.fieldHeader(id, thriftBinary)
+	w.binary(s)
+}
+
+func (w *thriftWriter) listHeader(elem byte, size int) {
+	if size < 15 {
+		w.WriteByte(byte(size)<<4 | elem)
+	} else {
+		w.WriteByte(0xf0 | elem)
+		w.varint(uint64(size))
+	}
+}
+
+func (w *thriftWriter) listField(id int16, elem byte, size int) {
+	w.fieldHeader(id, thriftList)
+	w.listHeader(elem, size)
+}
+
+// structField opens a nested struct field, closed by end.
+func (w *thriftWriter) structField(id int16) {
+	w.fieldHeader(id, thriftStruct)
+	w.begin()
+}
@@ -0,0 +1,133 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package utils
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wanchain/go-wanchain/eth"
+	"github.com/wanchain/go-wanchain/eth/downloader"
+	"github.com/wanchain/go-wanchain/p2p"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// NodeProfile bundles the defaults suiting a kind of deployment. A profile
+// replaces the values of the config file, while the command line flags set
+// explicitly still override the profile.
+//
+// The OTA and key image sets the privacy transactions are verified against are
+// part of the consensus state, not a separate index, so there are no privacy
+// index options to bundle: every profile keeps them complete.
+type NodeProfile struct {
+	Usage string
+
+	SyncMode  downloader.SyncMode
+	Cache     int // Megabytes of memory allocated to internal caching
+	Preimages bool
+
+	MaxPeers        int
+	MaxPendingPeers int
+	LightPeers      int
+
+	AccountSlots uint64
+	GlobalSlots  uint64
+	AccountQueue uint64
+	GlobalQueue  uint64
+	PrivacyTTL   time.Duration // Lifetime of the pooled privacy transactions
+}
+
+// NodeProfiles are the profiles selectable with --profile.
+var NodeProfiles = map[string]*NodeProfile{
+	"light-home": {
+		Usage:           "Home node on a metered or slow connection",
+		SyncMode:        downloader.FastSync,
+		Cache:           64,
+		MaxPeers:        12,
+		MaxPendingPeers: 4,
+		LightPeers:      0,
+		AccountSlots:    8,
+		GlobalSlots:     1024,
+		AccountQueue:    32,
+		GlobalQueue:     256,
+		PrivacyTTL:      30 * time.Minute,
+	},
+	"exchange": {
+		Usage:           "Exchange or service node handling many transactions",
+		SyncMode:        downloader.FullSync,
+		Cache:           1024,
+		MaxPeers:        50,
+		MaxPendingPeers: 16,
+		LightPeers:      0,
+		AccountSlots:    64,
+		GlobalSlots:     16384,
+		AccountQueue:    256,
+		GlobalQueue:     4096,
+		PrivacyTTL:      3 * time.Hour,
+	},
+	"archive": {
+		Usage:           "Archive node serving historical state and preimages",
+		SyncMode:        downloader.FullSync,
+		Cache:           2048,
+		Preimages:       true,
+		MaxPeers:        25,
+		MaxPendingPeers: 8,
+		LightPeers:      20,
+		AccountSlots:    eth.DefaultConfig.TxPool.AccountSlots,
+		GlobalSlots:     eth.DefaultConfig.TxPool.GlobalSlots,
+		AccountQueue:    eth.DefaultConfig.TxPool.AccountQueue,
+		GlobalQueue:     eth.DefaultConfig.TxPool.GlobalQueue,
+		PrivacyTTL:      eth.DefaultConfig.TxPool.PrivacyTTL,
+	},
+}
+
+// nodeProfileNames returns the sorted names of the node profiles.
+func nodeProfileNames() []string {
+	names := make([]string, 0, len(NodeProfiles))
+	for name := range NodeProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// nodeProfile returns the profile selected with --profile, or nil.
+func nodeProfile(ctx *cli.Context) *NodeProfile {
+	name := ctx.GlobalString(ProfileFlag.Name)
+	if name == "" {
+		return nil
+	}
+	profile, ok := NodeProfiles[name]
+	if !ok {
+		Fatalf("Option %q: unknown profile %q (available: %s)", ProfileFlag.Name, name, strings.Join(nodeProfileNames(), ", "))
+	}
+	return profile
+}
+
+// setP2PProfile applies the peer limits of the node profile.
+func setP2PProfile(ctx *cli.Context, cfg *p2p.Config) {
+	if profile := nodeProfile(ctx); profile != nil {
+		cfg.MaxPeers = profile.MaxPeers
+		cfg.MaxPendingPeers = profile.MaxPendingPeers
+	}
+}
+
+// setEthProfile applies the sync, cache and txpool defaults of the node
+// profile.
+func setEthProfile(ctx *cli.Context, cfg *eth.Config) {
+	profile := nodeProfile(ctx)
+	if profile == nil {
+		return
+	}
+	cfg.SyncMode = profile.SyncMode
+	cfg.DatabaseCache = profile.Cache
+	cfg.EnablePreimageRecording = profile.Preimages
+	cfg.LightPeers = profile.LightPeers
+
+	cfg.TxPool.AccountSlots = profile.AccountSlots
+	cfg.TxPool.GlobalSlots = profile.GlobalSlots
+	cfg.TxPool.AccountQueue = profile.AccountQueue
+	cfg.TxPool.GlobalQueue = profile.GlobalQueue
+	cfg.TxPool.PrivacyTTL = profile.PrivacyTTL
+}
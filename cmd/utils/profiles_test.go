@@ -0,0 +1,42 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package utils
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/eth"
+	"github.com/wanchain/go-wanchain/p2p"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// Tests that a node profile replaces the defaults while the flags set
+// explicitly still override it.
+func TestNodeProfile(t *testing.T) {
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	ProfileFlag.Apply(set)
+	MaxPeersFlag.Apply(set)
+	if err := set.Parse([]string{"--profile", "light-home", "--maxpeers", "30"}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := cli.NewContext(cli.NewApp(), set, nil)
+
+	p2pcfg := new(p2p.Config)
+	SetP2PConfig(ctx, p2pcfg)
+	if p2pcfg.MaxPendingPeers != NodeProfiles["light-home"].MaxPendingPeers {
+		t.Errorf("pending peers mismatch: have %d, want %d", p2pcfg.MaxPendingPeers, NodeProfiles["light-home"].MaxPendingPeers)
+	}
+	if p2pcfg.MaxPeers != 30 {
+		t.Errorf("max peers mismatch: have %d, want 30", p2pcfg.MaxPeers)
+	}
+
+	ethcfg := eth.DefaultConfig
+	setEthProfile(ctx, &ethcfg)
+	if ethcfg.DatabaseCache != 64 || ethcfg.TxPool.GlobalSlots != 1024 {
+		t.Errorf("profile not applied: cache %d, global slots %d", ethcfg.DatabaseCache, ethcfg.TxPool.GlobalSlots)
+	}
+	if ethcfg.TxPool.PriceBump != eth.DefaultConfig.TxPool.PriceBump {
+		t.Errorf("unrelated setting changed: price bump %d", ethcfg.TxPool.PriceBump)
+	}
+}
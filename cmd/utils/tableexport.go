@@ -0,0 +1,347 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package utils
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"math/big"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/common/hexutil"
+	"github.com/wanchain/go-wanchain/core"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/core/vm"
+	"github.com/wanchain/go-wanchain/ethdb"
+	"github.com/wanchain/go-wanchain/log"
+)
+
+const (
+	TableExportCSV     = "csv"     // CSV files, with a header line
+	TableExportParquet = "parquet" // Uncompressed Parquet files
+
+	// DefaultTablePartition is the default number of blocks per exported file.
+	DefaultTablePartition = 10000
+)
+
+// Events of the privacy table.
+const (
+	privacyCoinDeposit  = "coinDeposit"  // Coin note bought for an OTA
+	privacyStampDeposit = "stampDeposit" // Privacy transaction stamp bought for an OTA
+	privacySpend        = "spend"        // Stamp used or key image spent by a privacy transaction
+)
+
+// tableColumn is a column of an exported table, holding either uint64 or
+// string values.
+type tableColumn struct {
+	name    string
+	numeric bool
+}
+
+// exportedTable is the schema of an exported table.
+type exportedTable struct {
+	name    string
+	columns []tableColumn
+}
+
+var (
+	blocksTable = &exportedTable{"blocks", []tableColumn{
+		{"number", true}, {"hash", false}, {"parentHash", false}, {"timestamp", true}, {"miner", false},
+		{"difficulty", false}, {"gasLimit", true}, {"gasUsed", true}, {"transactionCount", true}, {"uncleCount", true},
+	}}
+	transactionsTable = &exportedTable{"transactions", []tableColumn{
+		{"blockNumber", true}, {"transactionIndex", true}, {"hash", false}, {"type", true}, {"from", false}, {"to", false},
+		{"value", false}, {"gas", true}, {"gasPrice", false}, {"nonce", true}, {"inputSize", true},
+	}}
+	receiptsTable = &exportedTable{"receipts", []tableColumn{
+		{"blockNumber", true}, {"transactionIndex", true}, {"transactionHash", false}, {"status", true},
+		{"gasUsed", true}, {"cumulativeGasUsed", true}, {"contractAddress", false}, {"logCount", true},
+	}}
+	privacyTable = &exportedTable{"privacy", []tableColumn{
+		{"blockNumber", true}, {"transactionIndex", true}, {"transactionHash", false}, {"event", false},
+		{"ota", false}, {"value", false}, {"keyImage", false},
+	}}
+	exportedTables = []*exportedTable{blocksTable, transactionsTable, receiptsTable, privacyTable}
+)
+
+// tableWriter writes the rows of a table partition to a file.
+type tableWriter interface {
+	write(row []interface{}) error
+	close() error
+}
+
+// csvTable streams the rows as CSV records.
+type csvTable struct {
+	fh  *os.File
+	buf *bufio.Writer
+	w   *csv.Writer
+}
+
+func newCSVTable(fn string, table *exportedTable) (*csvTable, error) {
+	fh, err := os.Create(fn)
+	if err != nil {
+		return nil, err
+	}
+	t := &csvTable{fh: fh, buf: bufio.NewWriter(fh)}
+	t.w = csv.NewWriter(t.buf)
+
+	header := make([]string, len(table.columns))
+	for i, column := range table.columns {
+		header[i] = column.name
+	}
+	t.w.Write(header)
+	return t, nil
+}
+
+func (t *csvTable) write(row []interface{}) error {
+	record := make([]string, len(row))
+	for i, value := range row {
+		if n, ok := value.(uint64); ok {
+			record[i] = strconv.FormatUint(n, 10)
+		} else {
+			record[i] = value.(string)
+		}
+	}
+	return t.w.Write(record)
+}
+
+func (t *csvTable) close() error {
+	defer t.fh.Close()
+
+	t.w.Flush()
+	if err := t.w.Error(); err != nil {
+		return err
+	}
+	if err := t.buf.Flush(); err != nil {
+		return err
+	}
+	return t.fh.Sync()
+}
+
+// parquetTable buffers the rows in columns, written out once complete.
+type parquetTable struct {
+	fn   string
+	file *parquetFile
+}
+
+func (t *parquetTable) write(row []interface{}) error {
+	t.file.append(row)
+	return nil
+}
+
+func (t *parquetTable) close() error {
+	fh, err := os.Create(t.fn)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	buf := bufio.NewWriter(fh)
+	if err := t.file.writeTo(buf); err != nil {
+		return err
+	}
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+	return fh.Sync()
+}
+
+// ExportTables writes the blocks, transactions, receipts and privacy events of
+// the canonical blocks first to last to the directory dir, as one CSV or
+// Parquet file per table and partition of blocks:
+//
+//	<dir>/<table>/<first>-<last>.<format>
+//
+// The partitions are aligned to multiples of their size. Every file is written
+// under a temporary name and renamed once complete, and the complete
+// partitions are skipped, so an interrupted export resumes where it stopped.
+func ExportTables(db ethdb.Database, dir string, first, last, partition uint64, format string) error {
+	// Watch for Ctrl-C while the export is running.
+	// If a signal is received, the export will stop after the current partition.
+	interrupt := make(chan os.Signal, 1)
+	stop := make(chan struct{})
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	defer close(interrupt)
+	go func() {
+		if _, ok := <-interrupt; ok {
+			log.Info("Interrupted during table export, stopping after the current partition")
+		}
+		close(stop)
+	}()
+	return exportTables(db, dir, first, last, partition, format, stop)
+}
+
+func exportTables(db ethdb.Database, dir string, first, last, partition uint64, format string, stop <-chan struct{}) error {
+	if format != TableExportCSV && format != TableExportParquet {
+		return fmt.Errorf("unknown export format %q", format)
+	}
+	if first > last {
+		return fmt.Errorf("invalid block range %d-%d", first, last)
+	}
+	if partition == 0 {
+		return fmt.Errorf("invalid partition size %d", partition)
+	}
+	config, err := core.GetChainConfig(db, core.GetCanonicalHash(db, 0))
+	if err != nil {
+		return fmt.Errorf("failed to load the chain config: %v", err)
+	}
+	for _, table := range exportedTables {
+		if err := os.MkdirAll(filepath.Join(dir, table.name), 0755); err != nil {
+			return err
+		}
+	}
+	log.Info("Exporting tables", "dir", dir, "first", first, "last", last, "partition", partition, "format", format)
+
+	for from := first; from <= last; {
+		to := from - from%partition + partition - 1
+		if to > last {
+			to = last
+		}
+		if err := exportPartition(db, config.ChainId, dir, from, to, format); err != nil {
+			return err
+		}
+		if to == last {
+			break
+		}
+		select {
+		case <-stop:
+			return fmt.Errorf("interrupted at block #%d", to)
+		default:
+		}
+		from = to + 1
+	}
+	log.Info("Exported tables", "dir", dir)
+	return nil
+}
+
+// exportPartition writes the tables of the blocks from to to, unless already
+// exported.
+func exportPartition(db ethdb.Database, chainId *big.Int, dir string, from, to uint64, format string) error {
+	var (
+		name    = fmt.Sprintf("%09d-%09d.%s", from, to, format)
+		files   = make([]string, len(exportedTables))
+		writers = make([]tableWriter, len(exportedTables))
+		done    = true
+	)
+	for i, table := range exportedTables {
+		files[i] = filepath.Join(dir, table.name, name)
+		if _, err := os.Stat(files[i]); err != nil {
+			done = false
+		}
+	}
+	if done {
+		log.Info("Skipping exported partition", "first", from, "last", to)
+		return nil
+	}
+	for i, table := range exportedTables {
+		if format == TableExportCSV {
+			w, err := newCSVTable(files[i]+".tmp", table)
+			if err != nil {
+				return err
+			}
+			defer w.fh.Close()
+			writers[i] = w
+		} else {
+			writers[i] = &parquetTable{fn: files[i] + ".tmp", file: newParquetFile(table.columns)}
+		}
+	}
+	blocks, txs, receipts, privacy := writers[0], writers[1], writers[2], writers[3]
+	signer := types.NewEIP155Signer(chainId)
+
+	for number := from; number <= to; number++ {
+		hash := core.GetCanonicalHash(db, number)
+		if hash == (common.Hash{}) {
+			return fmt.Errorf("block #%d not found", number)
+		}
+		block := core.GetBlock(db, hash, number)
+		if block == nil {
+			return fmt.Errorf("block #%d body not found", number)
+		}
+		header := block.Header()
+		if err := blocks.write([]interface{}{
+			number, hash.Hex(), header.ParentHash.Hex(), header.Time.Uint64(), header.Coinbase.Hex(),
+			header.Difficulty.String(), header.GasLimit.Uint64(), header.GasUsed.Uint64(), uint64(len(block.Transactions())), uint64(len(block.Uncles())),
+		}); err != nil {
+			return err
+		}
+		blockReceipts := core.GetBlockReceipts(db, hash, number)
+		for i, tx := range block.Transactions() {
+			var from, to string
+			if sender, err := types.Sender(signer, tx); err == nil {
+				from = sender.Hex()
+			}
+			if tx.To() != nil {
+				to = tx.To().Hex()
+			}
+			if err := txs.write([]interface{}{
+				number, uint64(i), tx.Hash().Hex(), tx.Txtype(), from, to,
+				tx.Value().String(), tx.Gas().Uint64(), tx.GasPrice().String(), tx.Nonce(), uint64(len(tx.Data())),
+			}); err != nil {
+				return err
+			}
+			if i >= len(blockReceipts) {
+				continue
+			}
+			receipt := blockReceipts[i]
+			var contract string
+			if receipt.ContractAddress != (common.Address{}) {
+				contract = receipt.ContractAddress.Hex()
+			}
+			if err := receipts.write([]interface{}{
+				number, uint64(i), tx.Hash().Hex(), uint64(receipt.Status),
+				receipt.GasUsed.Uint64(), receipt.CumulativeGasUsed.Uint64(), contract, uint64(len(receipt.Logs)),
+			}); err != nil {
+				return err
+			}
+			for _, row := range privacyRows(number, uint64(i), tx, receipt) {
+				if err := privacy.write(row); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for _, w := range writers {
+		if err := w.close(); err != nil {
+			return err
+		}
+	}
+	for _, fn := range files {
+		if err := os.Rename(fn+".tmp", fn); err != nil {
+			return err
+		}
+	}
+	log.Info("Exported partition", "first", from, "last", to)
+	return nil
+}
+
+// privacyRows returns the privacy events of a transaction: the successful OTA
+// deposit it makes, or the stamp it used and the key images it spent.
+func privacyRows(number, index uint64, tx *types.Transaction, receipt *types.Receipt) [][]interface{} {
+	var rows [][]interface{}
+	if ota, value, stamp, ok := vm.UnpackDeposit(tx.To(), tx.Data()); ok && receipt.Status == types.ReceiptStatusSuccessful {
+		event := privacyCoinDeposit
+		if stamp {
+			event = privacyStampDeposit
+		}
+		rows = append(rows, []interface{}{number, index, tx.Hash().Hex(), event, hexutil.Encode(ota), value.String(), ""})
+	}
+	if receipt.Privacy != nil {
+		var stampUsed string
+		if receipt.Privacy.StampUsed != nil {
+			stampUsed = receipt.Privacy.StampUsed.String()
+		}
+		if len(receipt.Privacy.KeyImages) == 0 {
+			rows = append(rows, []interface{}{number, index, tx.Hash().Hex(), privacySpend, "", stampUsed, ""})
+		}
+		for _, image := range receipt.Privacy.KeyImages {
+			rows = append(rows, []interface{}{number, index, tx.Hash().Hex(), privacySpend, "", stampUsed, hexutil.Encode(image)})
+		}
+	}
+	return rows
+}
@@ -0,0 +1,238 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/core/vm"
+	"github.com/wanchain/go-wanchain/crypto"
+	"github.com/wanchain/go-wanchain/ethdb"
+	"github.com/wanchain/go-wanchain/params"
+)
+
+// newTableExportDB creates a database holding a chain of blocks with a coin
+// note deposit and a privacy transaction each.
+func newTableExportDB(t *testing.T, blocks int) ethdb.Database {
+	db, _ := ethdb.NewMemDatabase()
+	key, _ := crypto.GenerateKey()
+	signer := types.NewEIP155Signer(params.TestChainConfig.ChainId)
+	value := new(big.Int).Mul(big.NewInt(10), big.NewInt(params.Wan))
+
+	var parent common.Hash
+	for i := 0; i < blocks; i++ {
+		to, data, err := vm.PackBuyCoinNote(make([]byte, common.WAddressLength), value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		deposit, _ := types.SignTx(types.NewTransaction(uint64(2*i), to, value, big.NewInt(200000), big.NewInt(1), data), signer, key)
+		spend, _ := types.SignTx(types.NewTransaction(uint64(2*i+1), common.Address{0xaa}, new(big.Int), big.NewInt(21000), big.NewInt(1), nil), signer, key)
+		receipts := types.Receipts{
+			{TxHash: deposit.Hash(), Status: types.ReceiptStatusSuccessful, CumulativeGasUsed: big.NewInt(50000), GasUsed: big.NewInt(50000)},
+			{TxHash: spend.Hash(), Status: types.ReceiptStatusSuccessful, CumulativeGasUsed: big.NewInt(71000), GasUsed: big.NewInt(21000),
+				Privacy: &types.ReceiptPrivacy{StampUsed: big.NewInt(9e15), KeyImages: [][]byte{{0x03, 0x04}, {0x05}}}},
+		}
+		header := &types.Header{
+			ParentHash: parent,
+			Number:     big.NewInt(int64(i)),
+			Time:       big.NewInt(int64(1500000000 + i)),
+			Difficulty: big.NewInt(1),
+			GasLimit:   big.NewInt(4712388),
+			GasUsed:    big.NewInt(71000),
+		}
+		block := types.NewBlock(header, types.Transactions{deposit, spend}, nil, receipts)
+		if i == 0 {
+			core.WriteChainConfig(db, block.Hash(), params.TestChainConfig)
+		}
+		if err := core.WriteBlock(db, block); err != nil {
+			t.Fatal(err)
+		}
+		if err := core.WriteCanonicalHash(db, block.Hash(), uint64(i)); err != nil {
+			t.Fatal(err)
+		}
+		if err := core.WriteBlockReceipts(db, block.Hash(), uint64(i), receipts); err != nil {
+			t.Fatal(err)
+		}
+		parent = block.Hash()
+	}
+	return db
+}
+
+// Tests that the tables are exported as CSV files partitioned by block range,
+// and that the exported partitions are skipped when exporting again.
+func TestExportTablesCSV(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tableexport")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db := newTableExportDB(t, 5)
+	if err := exportTables(db, dir, 1, 4, 2, TableExportCSV, nil); err != nil {
+		t.Fatalf("failed to export tables: %v", err)
+	}
+	rows := map[string]int{"blocks": 1, "transactions": 2, "receipts": 2, "privacy": 3}
+	for table, perBlock := range rows {
+		for _, partition := range []struct {
+			name   string
+			blocks int
+		}{{"000000001-000000001.csv", 1}, {"000000002-000000003.csv", 2}, {"000000004-000000004.csv", 1}} {
+			fh, err := os.Open(filepath.Join(dir, table, partition.name))
+			if err != nil {
+				t.Fatalf("missing partition: %v", err)
+			}
+			records, err := csv.NewReader(fh).ReadAll()
+			fh.Close()
+			if err != nil {
+				t.Fatalf("%s/%s: invalid CSV: %v", table, partition.name, err)
+			}
+			if want := 1 + perBlock*partition.blocks; len(records) != want {
+				t.Errorf("%s/%s: record count mismatch: have %d, want %d", table, partition.name, len(records), want)
+			}
+		}
+	}
+	records := readCSV(t, filepath.Join(dir, "privacy", "000000001-000000001.csv"))
+	if event, value := records[1][3], records[1][5]; event != privacyCoinDeposit || value != "10000000000000000000" {
+		t.Errorf("deposit event mismatch: %v", records[1])
+	}
+	if event, image := records[3][3], records[3][6]; event != privacySpend || image != "0x05" {
+		t.Errorf("spend event mismatch: %v", records[3])
+	}
+	if from := readCSV(t, filepath.Join(dir, "transactions", "000000001-000000001.csv"))[1][4]; !common.IsHexAddress(from) {
+		t.Errorf("sender not recovered: %q", from)
+	}
+	// Exported partitions are left untouched
+	stale := []byte("stale")
+	fn := filepath.Join(dir, "blocks", "000000002-000000003.csv")
+	ioutil.WriteFile(fn, stale, 0644)
+	if err := exportTables(db, dir, 1, 4, 2, TableExportCSV, nil); err != nil {
+		t.Fatalf("failed to export tables again: %v", err)
+	}
+	if blob, _ := ioutil.ReadFile(fn); !bytes.Equal(blob, stale) {
+		t.Errorf("exported partition overwritten")
+	}
+}
+
+func readCSV(t *testing.T, fn string) [][]string {
+	fh, err := os.Open(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Close()
+	records, err := csv.NewReader(fh).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+// Tests that the Parquet tables carry the expected metadata and values.
+func TestExportTablesParquet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tableexport")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db := newTableExportDB(t, 3)
+	if err := exportTables(db, dir, 0, 2, 10, TableExportParquet, nil); err != nil {
+		t.Fatalf("failed to export tables: %v", err)
+	}
+	blob, err := ioutil.ReadFile(filepath.Join(dir, "blocks", "000000000-000000002.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(blob, parquetMagic) || !bytes.HasSuffix(blob, parquetMagic) {
+		t.Fatalf("parquet magic missing")
+	}
+	size := int(binary.LittleEndian.Uint32(blob[len(blob)-8:]))
+	footer := readThriftStruct(t, bytes.NewReader(blob[len(blob)-8-size:len(blob)-8]))
+
+	if rows := footer[3].(int64); rows != 3 {
+		t.Errorf("row count mismatch: have %d, want 3", rows)
+	}
+	if schema := footer[2].([]interface{}); len(schema) != len(blocksTable.columns)+1 {
+		t.Errorf("schema length mismatch: have %d, want %d", len(schema), len(blocksTable.columns)+1)
+	}
+	// The number column chunk holds the PLAIN encoded block numbers
+	chunk := footer[4].([]interface{})[0].(map[int16]interface{})[1].([]interface{})[0].(map[int16]interface{})
+	meta := chunk[3].(map[int16]interface{})
+	if path := meta[3].([]interface{})[0].(string); path != "number" {
+		t.Fatalf("first column mismatch: have %q, want number", path)
+	}
+	page := bytes.NewReader(blob[meta[9].(int64):])
+	header := readThriftStruct(t, page)
+	values := make([]int64, header[5].(map[int16]interface{})[1].(int64))
+	if err := binary.Read(page, binary.LittleEndian, values); err != nil {
+		t.Fatal(err)
+	}
+	for i, value := range values {
+		if value != int64(i) {
+			t.Errorf("block number %d mismatch: have %d", i, value)
+		}
+	}
+}
+
+// readThriftStruct decodes a Thrift compact protocol struct into a map of its
+// fields, holding int64, string, list and nested struct values.
+func readThriftStruct(t *testing.T, r *bytes.Reader) map[int16]interface{} {
+	fields := make(map[int16]interface{})
+	var id int16
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			t.Fatalf("truncated struct: %v", err)
+		}
+		if b == 0 {
+			return fields
+		}
+		if delta := int16(b >> 4); delta != 0 {
+			id += delta
+		} else {
+			v, _ := binary.ReadVarint(r)
+			id = int16(v)
+		}
+		fields[id] = readThriftValue(t, r, b&0x0f)
+	}
+}
+
+func readThriftValue(t *testing.T, r *bytes.Reader, typ byte) interface{} {
+	switch typ {
+	case thriftI32, thriftI64:
+		v, err := binary.ReadVarint(r)
+		if err != nil {
+			t.Fatalf("invalid integer: %v", err)
+		}
+		return v
+	case thriftBinary:
+		size, _ := binary.ReadUvarint(r)
+		buf := make([]byte, size)
+		r.Read(buf)
+		return string(buf)
+	case thriftList:
+		b, _ := r.ReadByte()
+		size := uint64(b >> 4)
+		if size == 15 {
+			size, _ = binary.ReadUvarint(r)
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = readThriftValue(t, r, b&0x0f)
+		}
+		return list
+	case thriftStruct:
+		return readThriftStruct(t, r)
+	}
+	t.Fatalf("unexpected thrift type %d", typ)
+	return nil
+}
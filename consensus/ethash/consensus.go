@@ -771,6 +771,9 @@ var (
 // AccumulateRewards credits the coinbase of the given block with the mining
 // reward. The total reward consists of the static block reward and rewards for
 // included uncles. The coinbase of each uncle block is also rewarded.
+//
+// Only the reward schedule of the treasury configuration is currently paid out,
+// split between the coinbase and the treasury.
 // TODO (karalabe): Move the chain maker into this package and make this private!
 func AccumulateRewards(config *params.ChainConfig, state *state.StateDB, header *types.Header, uncles []*types.Header) {
 	// Select the correct block reward based on chain progression
@@ -792,4 +795,14 @@ func AccumulateRewards(config *params.ChainConfig, state *state.StateDB, header
 	//	reward.Add(reward, r)
 	//}
 	//state.AddBalance(header.Coinbase, reward)
+	if config.Treasury == nil {
+		return
+	}
+	coinbase, treasury := config.Treasury.Split(header.Number)
+	if coinbase.Sign() > 0 {
+		state.AddBalance(header.Coinbase, coinbase)
+	}
+	if treasury.Sign() > 0 {
+		state.AddBalance(config.Treasury.Address, treasury)
+	}
 }
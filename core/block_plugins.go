@@ -0,0 +1,124 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package core
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/wanchain/go-wanchain/core/state"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/log"
+)
+
+// BlockPlugin is an additional validator of the imported blocks, e.g. enforcing
+// the policies of a private network or recording statistics. Plugins run once
+// a block passed the consensus validation, before it is written.
+type BlockPlugin interface {
+	// Name identifies the plugin in the configuration and the logs.
+	Name() string
+
+	// ValidateBlock checks a block along with its receipts and its post state.
+	// The state must not be modified.
+	ValidateBlock(block *types.Block, receipts types.Receipts, statedb *state.StateDB) error
+}
+
+// PluginFailureMode is how the failures of a block plugin are handled.
+type PluginFailureMode int
+
+const (
+	PluginWarn   PluginFailureMode = iota // Log the failure and import the block
+	PluginReject                          // Reject the block as invalid
+)
+
+func (mode PluginFailureMode) String() string {
+	switch mode {
+	case PluginWarn:
+		return "warn"
+	case PluginReject:
+		return "reject"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(mode))
+	}
+}
+
+// BlockPluginRegistry holds the block plugins compiled into the node, for the
+// chain to run the ones it is configured with.
+type BlockPluginRegistry struct {
+	lock    sync.RWMutex
+	plugins map[string]BlockPlugin
+}
+
+// BlockPlugins is the registry the plugins register to, typically from init.
+var BlockPlugins = NewBlockPluginRegistry()
+
+// NewBlockPluginRegistry creates an empty plugin registry.
+func NewBlockPluginRegistry() *BlockPluginRegistry {
+	return &BlockPluginRegistry{plugins: make(map[string]BlockPlugin)}
+}
+
+// Register adds a plugin, replacing any previous one of the same name.
+func (r *BlockPluginRegistry) Register(plugin BlockPlugin) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.plugins[plugin.Name()] = plugin
+}
+
+// Plugin returns the registered plugin of the given name, or nil if unknown.
+func (r *BlockPluginRegistry) Plugin(name string) BlockPlugin {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.plugins[name]
+}
+
+// Plugins returns all the registered plugins, sorted by name.
+func (r *BlockPluginRegistry) Plugins() []BlockPlugin {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	plugins := make([]BlockPlugin, 0, len(r.plugins))
+	for _, plugin := range r.plugins {
+		plugins = append(plugins, plugin)
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name() < plugins[j].Name() })
+	return plugins
+}
+
+// blockPlugin is a plugin run by the chain with its failure mode.
+type blockPlugin struct {
+	plugin BlockPlugin
+	mode   PluginFailureMode
+}
+
+// AddBlockPlugin makes the chain run a plugin on the blocks it imports, in the
+// order the plugins are added.
+func (bc *BlockChain) AddBlockPlugin(plugin BlockPlugin, mode PluginFailureMode) {
+	bc.procmu.Lock()
+	defer bc.procmu.Unlock()
+
+	bc.plugins = append(bc.plugins, blockPlugin{plugin, mode})
+	log.Info("Enabled block plugin", "name", plugin.Name(), "mode", mode)
+}
+
+// runBlockPlugins runs the plugins on a block which passed the consensus
+// validation, returning the failure of the first rejecting one.
+func (bc *BlockChain) runBlockPlugins(block *types.Block, receipts types.Receipts, statedb *state.StateDB) error {
+	bc.procmu.RLock()
+	plugins := bc.plugins
+	bc.procmu.RUnlock()
+
+	for _, p := range plugins {
+		err := p.plugin.ValidateBlock(block, receipts, statedb)
+		if err == nil {
+			continue
+		}
+		if p.mode == PluginReject {
+			return fmt.Errorf("block plugin %s: %v", p.plugin.Name(), err)
+		}
+		log.Warn("Block plugin check failed", "plugin", p.plugin.Name(), "number", block.Number(), "hash", block.Hash(), "err", err)
+	}
+	return nil
+}
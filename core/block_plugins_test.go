@@ -0,0 +1,61 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/core/state"
+	"github.com/wanchain/go-wanchain/core/types"
+)
+
+// testBlockPlugin fails the blocks from a given number on, counting the blocks
+// it checked.
+type testBlockPlugin struct {
+	failFrom uint64
+	checked  int
+}
+
+func (p *testBlockPlugin) Name() string { return "test" }
+
+func (p *testBlockPlugin) ValidateBlock(block *types.Block, receipts types.Receipts, statedb *state.StateDB) error {
+	p.checked++
+	if block.NumberU64() >= p.failFrom {
+		return errors.New("policy violated")
+	}
+	return nil
+}
+
+// Tests that rejecting block plugins stop the import at the first failing block
+// while warning ones let it through.
+func TestBlockPlugins(t *testing.T) {
+	for _, mode := range []PluginFailureMode{PluginWarn, PluginReject} {
+		_, blockchain, err, env := newCanonical(0, true)
+		if err != nil {
+			t.Fatalf("%v: failed to create chain: %v", mode, err)
+		}
+		plugin := &testBlockPlugin{failFrom: 3}
+		blockchain.AddBlockPlugin(plugin, mode)
+
+		blocks := env.makeBlockChain(blockchain.Genesis(), 5, canonicalSeed)
+		n, err := blockchain.InsertChain(blocks)
+		switch mode {
+		case PluginWarn:
+			if err != nil {
+				t.Fatalf("%v: failed to insert chain: %v", mode, err)
+			}
+			if plugin.checked != 5 || blockchain.CurrentBlock().NumberU64() != 5 {
+				t.Errorf("%v: have %d blocks checked and head %d, want 5 and 5", mode, plugin.checked, blockchain.CurrentBlock().NumberU64())
+			}
+		case PluginReject:
+			if err == nil || n != 2 {
+				t.Fatalf("%v: failing block accepted: index %d, err %v", mode, n, err)
+			}
+			if head := blockchain.CurrentBlock().NumberU64(); head != 2 {
+				t.Errorf("%v: head mismatch: have %d, want 2", mode, head)
+			}
+		}
+		blockchain.Stop()
+	}
+}
@@ -69,7 +69,7 @@ func (v *BlockValidator) ValidateBody(block *types.Block) error {
 	if hash := types.DeriveSha(block.Transactions()); hash != header.TxHash {
 		return fmt.Errorf("transaction root hash mismatch: have %x, want %x", hash, header.TxHash)
 	}
-	return nil
+	return validatePrivacyCap(v.config, block, v.bc.PrivacyCapActive(header))
 }
 
 // ValidateState validates the various changes that happen after a state
@@ -97,7 +97,7 @@ func (v *BlockValidator) ValidateState(block, parent *types.Block, statedb *stat
 	if root := statedb.IntermediateRoot(true /*v.config.IsEIP158(header.Number)*/); header.Root != root {
 		return fmt.Errorf("invalid merkle root (remote: %x local: %x)", header.Root, root)
 	}
-	return nil
+	return validateKeyImageAcc(v.config, parent.Header(), header, statedb)
 }
 
 // CalcGasLimit computes the gas limit of the next block after parent.
@@ -249,6 +249,7 @@ func (self *ChainEnv) GenerateChain(parent *types.Block, n int, gen func(int, *B
 		}
 
 		ethash.AccumulateRewards(self.config, statedb, h, b.uncles)
+		ApplyKeyImageAcc(self.config, parent.Header(), h, statedb)
 		root, err := statedb.CommitTo(self.db, true)
 		if err != nil {
 			panic(fmt.Sprintf("state write error: %v", err))
@@ -286,6 +287,7 @@ func (self *ChainEnv) GenerateChainMulti(parent *types.Block, n int, gen func(in
 		}
 
 		ethash.AccumulateRewards(self.config, statedb, h, b.uncles)
+		ApplyKeyImageAcc(self.config, parent.Header(), h, statedb)
 		root, err := statedb.CommitTo(self.db, true)
 		if err != nil {
 			panic(fmt.Sprintf("state write error: %v", err))
@@ -326,6 +328,7 @@ func (self *ChainEnv) GenerateChainEx(parent *types.Block, signerSequence []int,
 		}
 
 		ethash.AccumulateRewards(self.config, statedb, h, b.uncles)
+		ApplyKeyImageAcc(self.config, parent.Header(), h, statedb)
 		root, err := statedb.CommitTo(self.db, true)
 		if err != nil {
 			panic(fmt.Sprintf("state write error: %v", err))
@@ -0,0 +1,114 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core/vm"
+)
+
+// ChainSpecVersion is the version of the chain spec format.
+const ChainSpecVersion = 1
+
+// ChainSpec describes everything a client needs to follow a chain the way this
+// node does, for alternative implementations and test harnesses. Its JSON
+// encoding is deterministic:
+//
+//	version      format version, ChainSpecVersion
+//	genesisHash  hash of the genesis block, checked on import
+//	genesis      genesis block as accepted by init, the forks, deployments,
+//	             governance, treasury and vesting being in its config
+//	precompiles  name, address and activationBlock (null if never active) of
+//	             the precompiled contracts, sorted by address
+//	privacy      coin and stamp denominations in ascending order with the
+//	             storage accounts of their OTAs, the privacy storage accounts
+//	             and the ring signature limits and gas prices
+//
+// Precompiles and privacy are derived from the genesis config and the node's
+// constants: importing checks they match rather than applying them.
+type ChainSpec struct {
+	Version     int                       `json:"version"`
+	GenesisHash common.Hash               `json:"genesisHash"`
+	Genesis     *Genesis                  `json:"genesis"`
+	Precompiles []vm.PrecompileActivation `json:"precompiles"`
+	Privacy     *vm.PrivacyParams         `json:"privacy"`
+}
+
+// NewChainSpec creates the spec of the chain starting at a genesis block.
+func NewChainSpec(genesis *Genesis) (*ChainSpec, error) {
+	if genesis.Config == nil {
+		return nil, errGenesisNoConfig
+	}
+	block, _ := genesis.ToBlock()
+	return &ChainSpec{
+		Version:     ChainSpecVersion,
+		GenesisHash: block.Hash(),
+		Genesis:     genesis,
+		Precompiles: vm.PrecompileActivations(genesis.Config),
+		Privacy:     vm.CurrentPrivacyParams(),
+	}, nil
+}
+
+// ImportChainSpec decodes a chain spec, returning its genesis block if this
+// node follows the chain exactly as specified.
+func ImportChainSpec(blob []byte) (*Genesis, error) {
+	spec := new(ChainSpec)
+	if err := json.Unmarshal(blob, spec); err != nil {
+		return nil, err
+	}
+	if err := spec.Check(); err != nil {
+		return nil, err
+	}
+	return spec.Genesis, nil
+}
+
+// Check verifies the spec is consistent with its genesis block and the rules
+// of the node.
+func (s *ChainSpec) Check() error {
+	if s.Version != ChainSpecVersion {
+		return fmt.Errorf("unsupported chain spec version %d, want %d", s.Version, ChainSpecVersion)
+	}
+	if s.Genesis == nil {
+		return errors.New("chain spec without genesis")
+	}
+	want, err := NewChainSpec(s.Genesis)
+	if err != nil {
+		return err
+	}
+	if s.GenesisHash != want.GenesisHash {
+		return fmt.Errorf("genesis hash mismatch: have %x, want %x", s.GenesisHash, want.GenesisHash)
+	}
+	if len(s.Precompiles) != len(want.Precompiles) {
+		return fmt.Errorf("precompile count mismatch: have %d, want %d", len(s.Precompiles), len(want.Precompiles))
+	}
+	for i, have := range s.Precompiles {
+		if have.Name != want.Precompiles[i].Name || have.Address != want.Precompiles[i].Address || !blockNumEqual(have.Block, want.Precompiles[i].Block) {
+			return fmt.Errorf("precompile %d mismatch: have %s at %x from %v, want %s at %x from %v", i,
+				have.Name, have.Address, have.Block, want.Precompiles[i].Name, want.Precompiles[i].Address, want.Precompiles[i].Block)
+		}
+	}
+	// Compare the encodings, normalising the big integers of the denominations
+	have, err := json.Marshal(s.Privacy)
+	if err != nil {
+		return err
+	}
+	wantPrivacy, _ := json.Marshal(want.Privacy)
+	if !bytes.Equal(have, wantPrivacy) {
+		return errors.New("privacy parameters differ from the node's")
+	}
+	return nil
+}
+
+// blockNumEqual reports whether two optional block numbers are equal.
+func blockNumEqual(x, y *big.Int) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return x.Cmp(y) == 0
+}
@@ -0,0 +1,57 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/common/math"
+)
+
+// Tests that chain specs export deterministically, import back to the same
+// genesis and are rejected once they disagree with the node.
+func TestChainSpecRoundTrip(t *testing.T) {
+	spec, err := NewChainSpec(DefaultPPOWTestingGenesisBlock())
+	if err != nil {
+		t.Fatalf("failed to create chain spec: %v", err)
+	}
+	blob, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatalf("failed to encode chain spec: %v", err)
+	}
+	genesis, err := ImportChainSpec(blob)
+	if err != nil {
+		t.Fatalf("failed to import chain spec: %v", err)
+	}
+	if block, _ := genesis.ToBlock(); block.Hash() != spec.GenesisHash {
+		t.Errorf("imported genesis mismatch: have %x, want %x", block.Hash(), spec.GenesisHash)
+	}
+	again, _ := NewChainSpec(genesis)
+	if reblob, _ := json.Marshal(again); !bytes.Equal(reblob, blob) {
+		t.Errorf("export not deterministic:\nhave %s\nwant %s", reblob, blob)
+	}
+
+	tampers := map[string]func(s *ChainSpec){
+		"version":      func(s *ChainSpec) { s.Version++ },
+		"genesis hash": func(s *ChainSpec) { s.GenesisHash[0]++ },
+		"genesis":      func(s *ChainSpec) { s.Genesis.Timestamp++ },
+		"no genesis":   func(s *ChainSpec) { s.Genesis = nil },
+		"precompile":   func(s *ChainSpec) { s.Precompiles[0].Block = big.NewInt(5) },
+		"precompiles":  func(s *ChainSpec) { s.Precompiles = s.Precompiles[1:] },
+		"denomination": func(s *ChainSpec) { s.Privacy.CoinDenominations[0].Value = (*math.HexOrDecimal256)(big.NewInt(1)) },
+		"ring size":    func(s *ChainSpec) { s.Privacy.MinRingSize++ },
+	}
+	for name, tamper := range tampers {
+		tampered := new(ChainSpec)
+		if err := json.Unmarshal(blob, tampered); err != nil {
+			t.Fatalf("failed to decode chain spec: %v", err)
+		}
+		tamper(tampered)
+		if err := tampered.Check(); err == nil {
+			t.Errorf("%s: tampered chain spec accepted", name)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/params"
+)
+
+// Miners signal their support for deployments by setting bits in the extra-data
+// vanity of their blocks: the last 8 bytes of the 32 byte vanity hold a magic
+// marker followed by the big endian signal bits. Blocks without the marker
+// don't signal anything.
+
+const (
+	signalVanity = 32 // Length of the extra-data vanity holding the signal
+	signalOffset = signalVanity - 8
+)
+
+var signalMagic = []byte("WSIG")
+
+// DeploymentState is the activation state of a deployment.
+type DeploymentState int
+
+const (
+	DeploymentDefined  DeploymentState = iota // Signaling hasn't started yet
+	DeploymentStarted                         // Blocks are signaling
+	DeploymentLockedIn                        // Threshold reached, active from the next window on
+	DeploymentActive                          // Protocol change in force
+	DeploymentFailed                          // Timed out before locking in
+)
+
+var deploymentStateNames = []string{"defined", "started", "lockedIn", "active", "failed"}
+
+// String implements the stringer interface.
+func (s DeploymentState) String() string {
+	if int(s) < len(deploymentStateNames) {
+		return deploymentStateNames[s]
+	}
+	return "unknown"
+}
+
+// SignalBits returns the deployment bits signaled by header.
+func SignalBits(header *types.Header) uint32 {
+	if len(header.Extra) < signalVanity || !bytes.Equal(header.Extra[signalOffset:signalOffset+len(signalMagic)], signalMagic) {
+		return 0
+	}
+	return binary.BigEndian.Uint32(header.Extra[signalOffset+len(signalMagic) : signalVanity])
+}
+
+// SetSignalBits makes header signal bits, overwriting the tail of its extra-data
+// vanity. Headers with a short extra-data are left unchanged.
+func SetSignalBits(header *types.Header, bits uint32) {
+	if bits == 0 || len(header.Extra) < signalVanity {
+		return
+	}
+	extra := common.CopyBytes(header.Extra)
+	copy(extra[signalOffset:], signalMagic)
+	binary.BigEndian.PutUint32(extra[signalOffset+len(signalMagic):], bits)
+	header.Extra = extra
+}
+
+func signals(header *types.Header, d *params.Deployment) bool {
+	return d.Bit < 32 && SignalBits(header)&(1<<d.Bit) != 0
+}
+
+// deploymentCacheKey identifies the state of a deployment in the window after
+// a given block.
+type deploymentCacheKey struct {
+	name string
+	hash common.Hash
+}
+
+// DeploymentState returns the state of deployment d in the block after parent.
+// The state only changes on window boundaries, based on the signals of the
+// previous window.
+func (bc *BlockChain) DeploymentState(parent *types.Header, d *params.Deployment) DeploymentState {
+	if d.Window == 0 || d.StartBlock == nil {
+		return DeploymentDefined
+	}
+	// Walk back the window ends until a known state
+	var (
+		ends  []*types.Header
+		state = DeploymentDefined
+	)
+	end := bc.windowEnd(parent, d.Window)
+	for end != nil {
+		if cached, ok := bc.deploymentCache.Get(deploymentCacheKey{d.Name, end.Hash()}); ok {
+			state = cached.(DeploymentState)
+			break
+		}
+		// Windows starting before the start block are all in the defined state
+		if end.Number.Uint64()+1 < d.StartBlock.Uint64() {
+			break
+		}
+		ends = append(ends, end)
+		if end.Number.Uint64() < d.Window {
+			break
+		}
+		end = bc.ancestor(end, end.Number.Uint64()-d.Window)
+	}
+	// Replay the transitions from the oldest window on
+	for i := len(ends) - 1; i >= 0; i-- {
+		next := ends[i].Number.Uint64() + 1
+		switch state {
+		case DeploymentDefined:
+			if next >= d.StartBlock.Uint64() {
+				state = DeploymentStarted
+			}
+			if d.TimeoutBlock != nil && next >= d.TimeoutBlock.Uint64() {
+				state = DeploymentFailed
+			}
+		case DeploymentStarted:
+			if bc.windowSignals(ends[i], d) >= d.Threshold {
+				state = DeploymentLockedIn
+			} else if d.TimeoutBlock != nil && next >= d.TimeoutBlock.Uint64() {
+				state = DeploymentFailed
+			}
+		case DeploymentLockedIn:
+			state = DeploymentActive
+		}
+		bc.deploymentCache.Add(deploymentCacheKey{d.Name, ends[i].Hash()}, state)
+	}
+	return state
+}
+
+// DeploymentActive reports whether the deployment called name is active in the
+// block after parent.
+func (bc *BlockChain) DeploymentActive(parent *types.Header, name string) bool {
+	d := bc.config.Deployment(name)
+	return d != nil && bc.DeploymentState(parent, d) == DeploymentActive
+}
+
+// DeploymentSignals returns the number of blocks signaling deployment d in the
+// window of head, up to and including head.
+func (bc *BlockChain) DeploymentSignals(head *types.Header, d *params.Deployment) uint64 {
+	if d.Window == 0 {
+		return 0
+	}
+	var count uint64
+	for header := head; header != nil; header = bc.GetHeader(header.ParentHash, header.Number.Uint64()-1) {
+		if signals(header, d) {
+			count++
+		}
+		if header.Number.Uint64()%d.Window == 0 {
+			break
+		}
+	}
+	return count
+}
+
+// SignalDeployments returns the signal bits a miner should set in the block
+// after parent: those of the deployments being signaled.
+func (bc *BlockChain) SignalDeployments(parent *types.Header) uint32 {
+	var bits uint32
+	for _, d := range bc.config.Deployments {
+		if d.Bit < 32 && bc.DeploymentState(parent, d) == DeploymentStarted {
+			bits |= 1 << d.Bit
+		}
+	}
+	return bits
+}
+
+// windowEnd returns the last block of the window before the one of the block
+// after parent, nil if that's the first window.
+func (bc *BlockChain) windowEnd(parent *types.Header, window uint64) *types.Header {
+	next := parent.Number.Uint64() + 1
+	start := next - next%window
+	if start == 0 {
+		return nil
+	}
+	return bc.ancestor(parent, start-1)
+}
+
+// windowSignals counts the blocks signaling d in the window ending at end.
+func (bc *BlockChain) windowSignals(end *types.Header, d *params.Deployment) uint64 {
+	var count uint64
+	header := end
+	for i := uint64(0); i < d.Window && header != nil; i++ {
+		if signals(header, d) {
+			count++
+		}
+		if header.Number.Uint64() == 0 {
+			break
+		}
+		header = bc.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	}
+	return count
+}
+
+// ancestor returns the ancestor of header at the given number.
+func (bc *BlockChain) ancestor(header *types.Header, number uint64) *types.Header {
+	for header != nil && header.Number.Uint64() > number {
+		header = bc.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	}
+	return header
+}
@@ -0,0 +1,98 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/consensus/ethash"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/core/vm"
+	"github.com/wanchain/go-wanchain/ethdb"
+	"github.com/wanchain/go-wanchain/params"
+)
+
+func TestDeploymentSignaling(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	genesis := DefaultPPOWTestingGenesisBlock().MustCommit(db)
+
+	config := *params.TestChainConfig
+	config.Deployments = []*params.Deployment{
+		{Name: "signaled", Bit: 1, StartBlock: big.NewInt(4), Window: 4, Threshold: 3},
+		{Name: "ignored", Bit: 2, StartBlock: big.NewInt(4), TimeoutBlock: big.NewInt(12), Window: 4, Threshold: 3},
+	}
+	chain, err := NewBlockChain(db, &config, ethash.NewFaker(db), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+	signaled, ignored := config.Deployments[0], config.Deployments[1]
+
+	// Blocks 4 to 7 are the first signaling window, three of them signal bit 1
+	// and one of them bit 2
+	headers := []*types.Header{genesis.Header()}
+	for i := 1; i <= 16; i++ {
+		header := &types.Header{
+			ParentHash: headers[i-1].Hash(),
+			Number:     big.NewInt(int64(i)),
+			Difficulty: big.NewInt(1),
+			Extra:      make([]byte, 97),
+		}
+		switch i {
+		case 4, 5:
+			SetSignalBits(header, 1<<1)
+		case 6:
+			SetSignalBits(header, 1<<1|1<<2)
+		}
+		if err := WriteHeader(db, header); err != nil {
+			t.Fatalf("failed to write header %d: %v", i, err)
+		}
+		headers = append(headers, header)
+	}
+	if bits := SignalBits(headers[6]); bits != 1<<1|1<<2 {
+		t.Fatalf("signal bits mismatch: have %b, want %b", bits, 1<<1|1<<2)
+	}
+	if SignalBits(headers[7]) != 0 || SignalBits(genesis.Header()) != 0 {
+		t.Fatalf("signal found in non signaling block")
+	}
+
+	tests := []struct {
+		parent            int
+		signaled, ignored DeploymentState
+	}{
+		{0, DeploymentDefined, DeploymentDefined},
+		{2, DeploymentDefined, DeploymentDefined},
+		{3, DeploymentStarted, DeploymentStarted},
+		{6, DeploymentStarted, DeploymentStarted},
+		{7, DeploymentLockedIn, DeploymentStarted},
+		{10, DeploymentLockedIn, DeploymentStarted},
+		{11, DeploymentActive, DeploymentFailed},
+		{16, DeploymentActive, DeploymentFailed},
+	}
+	// Query in reverse order too, exercising both the cold and the cached paths
+	for _, order := range [][]int{{7, 6, 5, 4, 3, 2, 1, 0}, {0, 1, 2, 3, 4, 5, 6, 7}} {
+		chain.deploymentCache.Purge()
+		for _, i := range order {
+			tt := tests[i]
+			if state := chain.DeploymentState(headers[tt.parent], signaled); state != tt.signaled {
+				t.Errorf("after block %d: signaled state mismatch: have %v, want %v", tt.parent, state, tt.signaled)
+			}
+			if state := chain.DeploymentState(headers[tt.parent], ignored); state != tt.ignored {
+				t.Errorf("after block %d: ignored state mismatch: have %v, want %v", tt.parent, state, tt.ignored)
+			}
+		}
+	}
+	if !chain.DeploymentActive(headers[11], "signaled") || chain.DeploymentActive(headers[10], "signaled") {
+		t.Errorf("deployment activation mismatch")
+	}
+	if n := chain.DeploymentSignals(headers[5], signaled); n != 2 {
+		t.Errorf("window signals mismatch: have %d, want 2", n)
+	}
+	if bits := chain.SignalDeployments(headers[5]); bits != 1<<1|1<<2 {
+		t.Errorf("miner signal bits mismatch: have %b, want %b", bits, 1<<1|1<<2)
+	}
+	if bits := chain.SignalDeployments(headers[11]); bits != 0 {
+		t.Errorf("miner signal bits after activation mismatch: have %b, want 0", bits)
+	}
+}
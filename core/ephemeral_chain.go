@@ -0,0 +1,136 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package core
+
+import (
+	"fmt"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/consensus/ethash"
+	"github.com/wanchain/go-wanchain/core/state"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/core/vm"
+	"github.com/wanchain/go-wanchain/ethdb"
+	"github.com/wanchain/go-wanchain/params"
+)
+
+// EphemeralChain is an in-memory chain running the Wanchain EVM. It's meant
+// to be embedded into indexers and analytics tools which need to execute
+// blocks or transactions and inspect the resulting state, without standing
+// up a whole node.
+//
+// Block seals and header consensus rules are not verified, but every block
+// is fully executed and its state root and receipts are validated.
+type EphemeralChain struct {
+	db     *ethdb.MemDatabase
+	config *params.ChainConfig
+	engine *ethash.Ethash
+	chain  *BlockChain
+}
+
+// NewEphemeralChain creates an in-memory chain initialised with the given
+// genesis. If genesis is nil, the main network genesis is used.
+func NewEphemeralChain(genesis *Genesis) (*EphemeralChain, error) {
+	db, err := ethdb.NewMemDatabase()
+	if err != nil {
+		return nil, err
+	}
+	config, _, err := SetupGenesisBlock(db, genesis)
+	if err != nil {
+		return nil, err
+	}
+	engine := ethash.NewFullFaker(db)
+
+	chain, err := NewBlockChain(db, config, engine, vm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	return &EphemeralChain{
+		db:     db,
+		config: config,
+		engine: engine,
+		chain:  chain,
+	}, nil
+}
+
+// Config returns the chain configuration the blocks are executed with.
+func (c *EphemeralChain) Config() *params.ChainConfig {
+	return c.config
+}
+
+// BlockChain returns the underlying block chain, for read access to blocks,
+// receipts and logs.
+func (c *EphemeralChain) BlockChain() *BlockChain {
+	return c.chain
+}
+
+// Database returns the in-memory database holding the chain and its state.
+func (c *EphemeralChain) Database() ethdb.Database {
+	return c.db
+}
+
+// CurrentBlock returns the head of the chain.
+func (c *EphemeralChain) CurrentBlock() *types.Block {
+	return c.chain.CurrentBlock()
+}
+
+// InsertBlocks executes and imports the given blocks, returning the index of
+// the failing block on error.
+func (c *EphemeralChain) InsertBlocks(blocks types.Blocks) (int, error) {
+	return c.chain.InsertChain(blocks)
+}
+
+// ApplyTransactions executes txs on top of the current head, seals them into
+// a new block credited to coinbase and imports it. Any transaction failing
+// consensus checks aborts the whole block.
+func (c *EphemeralChain) ApplyTransactions(coinbase common.Address, txs types.Transactions) (*types.Block, types.Receipts, error) {
+	parent := c.chain.CurrentBlock()
+	statedb, err := c.chain.StateAt(parent.Root())
+	if err != nil {
+		return nil, nil, err
+	}
+	header := makeHeader(c.config, parent, statedb)
+	header.Coinbase = coinbase
+
+	var (
+		gp       = new(GasPool).AddGas(header.GasLimit)
+		receipts = make(types.Receipts, 0, len(txs))
+	)
+	for i, tx := range txs {
+		statedb.Prepare(tx.Hash(), common.Hash{}, i)
+		receipt, _, err := ApplyTransaction(c.config, c.chain, &coinbase, gp, statedb, header, tx, header.GasUsed, vm.Config{})
+		if err != nil {
+			return nil, nil, fmt.Errorf("transaction %d (%x) failed: %v", i, tx.Hash(), err)
+		}
+		receipts = append(receipts, receipt)
+	}
+	ApplyKeyImageAcc(c.config, parent.Header(), header, statedb)
+	block, err := c.engine.Finalize(c.chain, header, statedb, txs, nil, receipts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := c.chain.InsertChain(types.Blocks{block}); err != nil {
+		return nil, nil, err
+	}
+	return block, receipts, nil
+}
+
+// State returns a mutable copy of the state at the head of the chain.
+func (c *EphemeralChain) State() (*state.StateDB, error) {
+	return c.chain.State()
+}
+
+// StateAt returns a mutable copy of the state after the given block.
+func (c *EphemeralChain) StateAt(number uint64) (*state.StateDB, error) {
+	block := c.chain.GetBlockByNumber(number)
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", number)
+	}
+	return c.chain.StateAt(block.Root())
+}
+
+// Stop terminates the chain and releases its memory.
+func (c *EphemeralChain) Stop() {
+	c.chain.Stop()
+	c.db.Close()
+}
@@ -0,0 +1,55 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/crypto"
+)
+
+func TestEphemeralChainApplyTransactions(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender  = crypto.PubkeyToAddress(key.PublicKey)
+		to      = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		funds   = big.NewInt(1000000000000000000)
+		gspec   = DefaultPPOWTestingGenesisBlock()
+		amount  = big.NewInt(1000)
+		gasUsed = big.NewInt(21000)
+	)
+	gspec.Alloc = GenesisAlloc{sender: {Balance: funds}}
+
+	chain, err := NewEphemeralChain(gspec)
+	if err != nil {
+		t.Fatalf("failed to create ephemeral chain: %v", err)
+	}
+	defer chain.Stop()
+
+	signer := types.NewEIP155Signer(chain.Config().ChainId)
+	tx, _ := types.SignTx(types.NewTransaction(0, to, amount, gasUsed, big.NewInt(1), nil), signer, key)
+
+	block, receipts, err := chain.ApplyTransactions(common.Address{}, types.Transactions{tx})
+	if err != nil {
+		t.Fatalf("failed to apply transactions: %v", err)
+	}
+	if block.NumberU64() != 1 || chain.CurrentBlock().Hash() != block.Hash() {
+		t.Fatalf("block not imported: have head #%d", chain.CurrentBlock().NumberU64())
+	}
+	if len(receipts) != 1 || receipts[0].GasUsed.Cmp(gasUsed) != 0 {
+		t.Fatalf("receipt mismatch: have %v", receipts)
+	}
+	statedb, err := chain.StateAt(1)
+	if err != nil {
+		t.Fatalf("failed to retrieve state: %v", err)
+	}
+	if balance := statedb.GetBalance(to); balance.Cmp(amount) != 0 {
+		t.Errorf("recipient balance mismatch: have %v, want %v", balance, amount)
+	}
+	if _, err := chain.StateAt(2); err == nil {
+		t.Errorf("expected error for missing block")
+	}
+}
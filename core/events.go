@@ -21,8 +21,12 @@ import (
 	"github.com/wanchain/go-wanchain/core/types"
 )
 
-// TxPreEvent is posted when a transaction enters the transaction pool.
-type TxPreEvent struct{ Tx *types.Transaction }
+// TxPreEvent is posted when a transaction enters the transaction pool. Local is
+// set if the transaction is sent by a local account.
+type TxPreEvent struct {
+	Tx    *types.Transaction
+	Local bool
+}
 
 // PendingLogsEvent is posted pre mining and notifies of pending logs.
 type PendingLogsEvent struct {
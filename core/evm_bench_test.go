@@ -0,0 +1,247 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package core
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/accounts/abi"
+	"github.com/wanchain/go-wanchain/accounts/keystore"
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/common/hexutil"
+	"github.com/wanchain/go-wanchain/consensus/ethash"
+	"github.com/wanchain/go-wanchain/core/state"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/core/vm"
+	"github.com/wanchain/go-wanchain/crypto"
+	"github.com/wanchain/go-wanchain/ethdb"
+)
+
+var evmProfileDir = flag.String("evmprofile", "", "directory to dump the EVM execution profiles of the workload benchmarks to")
+
+// The workload benchmarks replay synthetic blocks, each made of one kind of
+// transaction the main net carries, through the state processor, so that
+// performance regressions of the interpreter or of the precompiled contracts
+// show up before a release. The blocks are generated, not captured from the
+// main net, so their mix and sizes don't follow the live traffic:
+//
+//   - refund: a block full of ring signed coin note refunds
+//   - erc20:  a block full of token transfers to new holders
+//   - mixed:  value transfers, token transfers, coin note deposits and refunds
+//
+// Run with -evmprofile=<dir> to dump the opcode and precompile profile of
+// every benchmark to <dir>/<benchmark>.json.
+func BenchmarkWorkload_refund(b *testing.B) { benchWorkload(b, "refund") }
+func BenchmarkWorkload_erc20(b *testing.B)  { benchWorkload(b, "erc20") }
+func BenchmarkWorkload_mixed(b *testing.B)  { benchWorkload(b, "mixed") }
+
+const (
+	// Bytecode and ABI of the ethereum.org sample token
+	tokenCode = `60606040526040516107fd3803806107fd83398101604052805160805160a05160c051929391820192909101600160a060020a0333166000908152600360209081526040822086905581548551838052601f6002600019610100600186161502019093169290920482018390047f290decd9548b62a8d60345a988386fc84ba6bc95484008f6362f93160ef3e56390810193919290918801908390106100e857805160ff19168380011785555b506101189291505b8082111561017157600081556001016100b4565b50506002805460ff19168317905550505050610658806101a56000396000f35b828001600101855582156100ac579182015b828111156100ac5782518260005055916020019190600101906100fa565b50508060016000509080519060200190828054600181600116156101000203166002900490600052602060002090601f016020900481019282601f1061017557805160ff19168380011785555b506100c89291506100b4565b5090565b82800160010185558215610165579182015b8281111561016557825182600050559160200191906001019061018756606060405236156100775760e060020a600035046306fdde03811461007f57806323b872dd146100dc578063313ce5671461010e57806370a082311461011a57806395d89b4114610132578063a9059cbb1461018e578063cae9ca51146101bd578063dc3080f21461031c578063dd62ed3e14610341575b610365610002565b61036760008054602060026001831615610100026000190190921691909104601f810182900490910260809081016040526060828152929190828280156104eb5780601f106104c0576101008083540402835291602001916104eb565b6103d5600435602435604435600160a060020a038316600090815260036020526040812054829010156104f357610002565b6103e760025460ff1681565b6103d560043560036020526000908152604090205481565b610367600180546020600282841615610100026000190190921691909104601f810182900490910260809081016040526060828152929190828280156104eb5780601f106104c0576101008083540402835291602001916104eb565b610365600435602435600160a060020a033316600090815260036020526040902054819010156103f157610002565b60806020604435600481810135601f8101849004909302840160405260608381526103d5948235946024803595606494939101919081908382808284375094965050505050505060006000836004600050600033600160a060020a03168152602001908152602001600020600050600087600160a060020a031681526020019081526020016000206000508190555084905080600160a060020a0316638f4ffcb1338630876040518560e060020a0281526004018085600160a060020a0316815260200184815260200183600160a060020a03168152602001806020018281038252838181518152602001915080519060200190808383829060006004602084601f0104600f02600301f150905090810190601f1680156102f25780820380516001836020036101000a031916815260200191505b50955050505050506000604051808303816000876161da5a03f11561000257505050509392505050565b6005602090815260043560009081526040808220909252602435815220546103d59081565b60046020818152903560009081526040808220909252602435815220546103d59081565b005b60405180806020018281038252838181518152602001915080519060200190808383829060006004602084601f0104600f02600301f150905090810190601f1680156103c75780820380516001836020036101000a031916815260200191505b509250505060405180910390f35b60408051918252519081900360200190f35b6060908152602090f35b600160a060020a03821660009081526040902054808201101561041357610002565b806003600050600033600160a060020a03168152602001908152602001600020600082828250540392505081905550806003600050600084600160a060020a0316815260200190815260200160002060008282825054019250508190555081600160a060020a031633600160a060020a03167fddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef836040518082815260200191505060405180910390a35050565b820191906000526020600020905b8154815290600101906020018083116104ce57829003601f168201915b505050505081565b600160a060020a03831681526040812054808301101561051257610002565b600160a060020a0380851680835260046020908152604080852033949094168086529382528085205492855260058252808520938552929052908220548301111561055c57610002565b816003600050600086600160a060020a03168152602001908152602001600020600082828250540392505081905550816003600050600085600160a060020a03168152602001908152602001600020600082828250540192505081905550816005600050600086600160a060020a03168152602001908152602001600020600050600033600160a060020a0316815260200190815260200160002060008282825054019250508190555082600160a060020a031633600160a060020a03167fddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef846040518082815260200191505060405180910390a3939250505056`
+	tokenABI  = `[{"inputs":[{"name":"initialSupply","type":"uint256"},{"name":"tokenName","type":"string"},{"name":"decimalUnits","type":"uint8"},{"name":"tokenSymbol","type":"string"}],"type":"constructor"},{"constant":false,"inputs":[{"name":"_to","type":"address"},{"name":"_value","type":"uint256"}],"name":"transfer","outputs":[],"type":"function"}]`
+
+	workloadRing      = 4  // Ring size of the refunds
+	workloadRefunds   = 16 // Refunds of the refund block
+	workloadTransfers = 40 // Token transfers of the erc20 block
+)
+
+// workloadChain is the chain holding the workload blocks, generated once.
+type workloadChain struct {
+	db     ethdb.Database
+	chain  *BlockChain
+	blocks map[string]*types.Block
+	parent map[string]*types.Block
+}
+
+var (
+	workloads     *workloadChain
+	workloadsErr  error
+	workloadsOnce sync.Once
+)
+
+// otaNote is a coin note deposited to an OTA, refundable by its owner.
+type otaNote struct {
+	key *ecdsa.PrivateKey // OTA private key, used to ring sign the refund
+	ota []byte            // OTA wan address
+}
+
+func benchWorkload(b *testing.B, name string) {
+	workloadsOnce.Do(func() { workloads, workloadsErr = makeWorkloadChain() })
+	if workloadsErr != nil {
+		b.Fatalf("failed to generate the workload blocks: %v", workloadsErr)
+	}
+	block, parent := workloads.blocks[name], workloads.parent[name]
+
+	var cfg vm.Config
+	if *evmProfileDir != "" {
+		cfg.Profiler = vm.NewProfiler()
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		statedb, err := state.New(parent.Root(), state.NewDatabase(workloads.db))
+		if err != nil {
+			b.Fatalf("failed to open the parent state: %v", err)
+		}
+		b.StartTimer()
+		if _, _, _, err := workloads.chain.Processor().Process(block, statedb, cfg); err != nil {
+			b.Fatalf("failed to process the %s block: %v", name, err)
+		}
+	}
+	b.StopTimer()
+
+	if cfg.Profiler != nil {
+		report, _ := json.MarshalIndent(cfg.Profiler.Report(), "", "  ")
+		file := filepath.Join(*evmProfileDir, strings.Replace(b.Name(), "/", "_", -1)+".json")
+		if err := ioutil.WriteFile(file, report, 0644); err != nil {
+			b.Fatalf("failed to dump the profile: %v", err)
+		}
+	}
+}
+
+// makeWorkloadChain generates and imports a chain funding the workloads and
+// holding the workload blocks, checking all their transactions succeed.
+func makeWorkloadChain() (*workloadChain, error) {
+	var (
+		db, _   = ethdb.NewMemDatabase()
+		gspec   = DefaultPPOWTestingGenesisBlock()
+		engine  = ethash.NewFaker(db)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainId)
+		value   = new(big.Int).Mul(big.NewInt(10), big.NewInt(1e18)) // 10 wan coin notes
+		token   common.Address
+		notes   = make([]*otaNote, workloadRefunds+8)
+		senders = ringKeys[1 : 1+len(notes)]
+	)
+	gspec.Alloc = GenesisAlloc{benchRootAddr: {Balance: benchRootFunds}}
+	genesis := gspec.MustCommit(db)
+	chain, err := NewBlockChain(db, gspec.Config, engine, vm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range notes {
+		key, _ := crypto.GenerateKey()
+		r, _ := crypto.GenerateKey()
+		notes[i] = &otaNote{key: key, ota: keystore.GenerateWaddressFromPK(&key.PublicKey, &r.PublicKey)[:]}
+	}
+	tokenAbi, _ := abi.JSON(strings.NewReader(tokenABI))
+	coinAbi, _ := abi.JSON(strings.NewReader(coinSCDefinition))
+
+	var genErr error
+	send := func(gen *BlockGen, key *ecdsa.PrivateKey, to *common.Address, amount *big.Int, gas uint64, data []byte) {
+		nonce := gen.TxNonce(crypto.PubkeyToAddress(key.PublicKey))
+		var tx *types.Transaction
+		if to == nil {
+			tx = types.NewContractCreation(nonce, amount, new(big.Int).SetUint64(gas), nil, data)
+		} else {
+			tx = types.NewTransaction(nonce, *to, amount, new(big.Int).SetUint64(gas), nil, data)
+		}
+		tx, _ = types.SignTx(tx, signer, key)
+		gen.AddTx(tx)
+	}
+	transfer := func(gen *BlockGen, to common.Address) {
+		data, _ := tokenAbi.Pack("transfer", to, big.NewInt(1000))
+		send(gen, benchRootKey, &token, nil, 100000, data)
+	}
+	deposit := func(gen *BlockGen, note *otaNote) {
+		to, data, err := vm.PackBuyCoinNote(note.ota, value)
+		if err != nil {
+			genErr = err
+		}
+		send(gen, benchRootKey, &to, value, 200000, data)
+	}
+	refund := func(gen *BlockGen, i int) {
+		ring := []*ecdsa.PublicKey{&notes[i].key.PublicKey}
+		for j := 1; j < workloadRing; j++ {
+			ring = append(ring, &notes[(i+j)%len(notes)].key.PublicKey)
+		}
+		data, err := ringSignRefund(coinAbi, senders[i], notes[i].key, ring, value)
+		if err != nil {
+			genErr = err
+		}
+		send(gen, senders[i], &wanCoinSCAddr, nil, 250000, data)
+	}
+	blocks, receipts := NewChainEnv(gspec.Config, gspec, engine, chain, db).GenerateChain(genesis, 6, func(i int, gen *BlockGen) {
+		switch i {
+		case 0:
+			// Fund the refund senders and deploy the token
+			for _, key := range senders {
+				addr := crypto.PubkeyToAddress(key.PublicKey)
+				send(gen, benchRootKey, &addr, big.NewInt(1e18), 21000, nil)
+			}
+			args, _ := tokenAbi.Pack("", big.NewInt(1e18), "Token", uint8(0), "TKN")
+			token = crypto.CreateAddress(benchRootAddr, gen.TxNonce(benchRootAddr))
+			send(gen, benchRootKey, nil, nil, 1000000, append(common.FromHex(tokenCode), args...))
+		case 1, 2:
+			// Deposit the coin notes
+			for j := (i - 1) * len(notes) / 2; j < i*len(notes)/2; j++ {
+				deposit(gen, notes[j])
+			}
+		case 3:
+			for j := 0; j < workloadTransfers; j++ {
+				transfer(gen, ringAddrs[100+j])
+			}
+		case 4:
+			for j := 0; j < workloadRefunds; j++ {
+				refund(gen, j)
+			}
+		case 5:
+			for j := 0; j < 8; j++ {
+				to := ringAddrs[200+j]
+				send(gen, benchRootKey, &to, big.NewInt(1), 21000, nil)
+				transfer(gen, ringAddrs[300+j])
+				if j%2 == 0 {
+					refund(gen, workloadRefunds+j)
+					a, _ := crypto.GenerateKey()
+					r, _ := crypto.GenerateKey()
+					deposit(gen, &otaNote{ota: keystore.GenerateWaddressFromPK(&a.PublicKey, &r.PublicKey)[:]})
+				}
+			}
+		}
+	})
+	if genErr != nil {
+		return nil, genErr
+	}
+	for i, block := range receipts {
+		for j, receipt := range block {
+			if receipt.Status != types.ReceiptStatusSuccessful {
+				return nil, fmt.Errorf("block %d: transaction %d failed", i+1, j)
+			}
+		}
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		return nil, err
+	}
+	return &workloadChain{
+		db:     db,
+		chain:  chain,
+		blocks: map[string]*types.Block{"erc20": blocks[3], "refund": blocks[4], "mixed": blocks[5]},
+		parent: map[string]*types.Block{"erc20": blocks[2], "refund": blocks[3], "mixed": blocks[4]},
+	}, nil
+}
+
+// ringSignRefund returns the payload of a transaction from sender refunding a
+// coin note of value, ring signed by the OTA key among the ring.
+func ringSignRefund(coinAbi abi.ABI, sender, key *ecdsa.PrivateKey, ring []*ecdsa.PublicKey, value *big.Int) ([]byte, error) {
+	pubs, image, w, q, err := crypto.RingSign(crypto.PubkeyToAddress(sender.PublicKey).Bytes(), key.D, ring)
+	if err != nil {
+		return nil, err
+	}
+	var members, ws, qs []string
+	for i := range pubs {
+		members = append(members, common.ToHex(crypto.FromECDSAPub(pubs[i])))
+		ws = append(ws, hexutil.EncodeBig(w[i]))
+		qs = append(qs, hexutil.EncodeBig(q[i]))
+	}
+	data := strings.Join([]string{strings.Join(members, "&"), common.ToHex(crypto.FromECDSAPub(image)), strings.Join(ws, "&"), strings.Join(qs, "&")}, "+")
+	return coinAbi.Pack("refundCoin", data, value)
+}
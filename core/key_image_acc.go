@@ -0,0 +1,67 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package core
+
+import (
+	"fmt"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core/state"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/core/vm"
+	"github.com/wanchain/go-wanchain/crypto"
+	"github.com/wanchain/go-wanchain/params"
+)
+
+// The key image accumulator commits every header to the whole history of the
+// spent key images: acc(n) = keccak256(acc(n-1), root(n)), where root(n) is
+// the root of the key image storage after block n and acc(n-1) is zero at the
+// fork block. Nodes can compare a single hash to check they agree on every
+// spend, and light clients can check a key image storage proof (and thus the
+// non-spentness of a key image) against a header.
+
+// KeyImageRoot returns the root of the key image storage of statedb.
+func KeyImageRoot(statedb *state.StateDB) common.Hash {
+	tr := statedb.StorageTrie(vm.OTAImageStorageAddr())
+	if tr == nil {
+		return types.EmptyRootHash
+	}
+	return tr.Hash()
+}
+
+// CalcKeyImageAcc computes the key image accumulator of the child of parent
+// whose post state is statedb.
+func CalcKeyImageAcc(parent *types.Header, statedb *state.StateDB) common.Hash {
+	var prev common.Hash
+	if len(parent.KeyImageAcc) > 0 {
+		prev = parent.KeyImageAcc[0]
+	}
+	root := KeyImageRoot(statedb)
+	return crypto.Keccak256Hash(prev.Bytes(), root.Bytes())
+}
+
+// ApplyKeyImageAcc sets the key image accumulator of header from its post
+// state, if the fork is active.
+func ApplyKeyImageAcc(config *params.ChainConfig, parent, header *types.Header, statedb *state.StateDB) {
+	if config.IsKeyImageAcc(header.Number) {
+		header.KeyImageAcc = []common.Hash{CalcKeyImageAcc(parent, statedb)}
+	}
+}
+
+// validateKeyImageAcc checks the key image accumulator of header against its
+// post state.
+func validateKeyImageAcc(config *params.ChainConfig, parent, header *types.Header, statedb *state.StateDB) error {
+	if !config.IsKeyImageAcc(header.Number) {
+		if len(header.KeyImageAcc) != 0 {
+			return fmt.Errorf("unexpected key image accumulator")
+		}
+		return nil
+	}
+	if len(header.KeyImageAcc) != 1 {
+		return fmt.Errorf("missing key image accumulator")
+	}
+	if acc := CalcKeyImageAcc(parent, statedb); header.KeyImageAcc[0] != acc {
+		return fmt.Errorf("invalid key image accumulator (remote: %x local: %x)", header.KeyImageAcc[0], acc)
+	}
+	return nil
+}
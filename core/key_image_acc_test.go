@@ -0,0 +1,59 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/core/vm"
+	"github.com/wanchain/go-wanchain/crypto"
+)
+
+func TestKeyImageAcc(t *testing.T) {
+	gspec := DefaultPPOWTestingGenesisBlock()
+	config := *gspec.Config
+	config.KeyImageAccBlock = big.NewInt(2)
+	gspec.Config = &config
+
+	chain, err := NewEphemeralChain(gspec)
+	if err != nil {
+		t.Fatalf("failed to create ephemeral chain: %v", err)
+	}
+	defer chain.Stop()
+
+	block1, _, err := chain.ApplyTransactions(common.Address{}, nil)
+	if err != nil {
+		t.Fatalf("failed to apply block 1: %v", err)
+	}
+	if len(block1.Header().KeyImageAcc) != 0 {
+		t.Fatalf("key image accumulator set before the fork")
+	}
+	block2, _, err := chain.ApplyTransactions(common.Address{}, nil)
+	if err != nil {
+		t.Fatalf("failed to apply block 2: %v", err)
+	}
+	want := crypto.Keccak256Hash(common.Hash{}.Bytes(), types.EmptyRootHash.Bytes())
+	if acc := block2.Header().KeyImageAcc; len(acc) != 1 || acc[0] != want {
+		t.Fatalf("key image accumulator mismatch: have %x, want %x", acc, want)
+	}
+
+	// Spending a key image must change the accumulator
+	statedb, err := chain.StateAt(2)
+	if err != nil {
+		t.Fatalf("failed to retrieve state: %v", err)
+	}
+	if err := validateKeyImageAcc(&config, block1.Header(), block2.Header(), statedb); err != nil {
+		t.Fatalf("valid accumulator rejected: %v", err)
+	}
+	vm.AddOTAImage(statedb, crypto.Keccak256([]byte("key image")), big.NewInt(1).Bytes())
+	if err := validateKeyImageAcc(&config, block1.Header(), block2.Header(), statedb); err == nil {
+		t.Fatalf("accumulator accepted for a different key image set")
+	}
+	next := CalcKeyImageAcc(block2.Header(), statedb)
+	if next == want || next == crypto.Keccak256Hash(common.Hash{}.Bytes(), KeyImageRoot(statedb).Bytes()) {
+		t.Errorf("accumulator doesn't chain the parent accumulator")
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/common/hexutil"
+	"github.com/wanchain/go-wanchain/core/state"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/core/vm"
+	"github.com/wanchain/go-wanchain/crypto"
+	"github.com/wanchain/go-wanchain/rlp"
+	"github.com/wanchain/go-wanchain/trie"
+)
+
+var (
+	// ErrKeyImageSpent is returned when proving or verifying the absence of a
+	// key image recorded as spent.
+	ErrKeyImageSpent = errors.New("key image spent")
+
+	// ErrInvalidKeyImageProof is returned if a key image absence proof doesn't
+	// match the state root it is made against.
+	ErrInvalidKeyImageProof = errors.New("invalid key image proof")
+)
+
+// KeyImageProof is a merkle proof that a key image is absent from the spent
+// set of a state: the proof of the spent set account in the state trie, and
+// the proof of the key image slot in its storage trie. The receiver of a raw
+// refund payload checks it against a state root it trusts, e.g. the one of a
+// header verified by a light client, to know the payload is not double spent.
+type KeyImageProof struct {
+	Root         common.Hash     `json:"root"`         // State root the proof is made against
+	AccountProof []hexutil.Bytes `json:"accountProof"` // Proof of the spent set account
+	StorageProof []hexutil.Bytes `json:"storageProof"` // Proof of the key image slot, empty if the account is absent
+}
+
+// ProveKeyImageAbsence creates the proof that a key image is absent from the
+// spent set of statedb, whose root is given. The state must not hold
+// uncommitted changes.
+func ProveKeyImageAbsence(statedb *state.StateDB, root common.Hash, keyImage []byte) (*KeyImageProof, error) {
+	spent, _, err := vm.CheckOTAImageExist(statedb, keyImage)
+	if err != nil {
+		return nil, err
+	}
+	if spent {
+		return nil, ErrKeyImageSpent
+	}
+	addr, slot := vm.OTAImageStorageSlot(keyImage)
+	accountProof, err := statedb.GetProof(addr)
+	if err != nil {
+		return nil, err
+	}
+	storageProof, err := statedb.GetStorageProof(addr, slot)
+	if err != nil {
+		return nil, err
+	}
+	proof := &KeyImageProof{
+		Root:         root,
+		AccountProof: make([]hexutil.Bytes, len(accountProof)),
+		StorageProof: make([]hexutil.Bytes, len(storageProof)),
+	}
+	for i, node := range accountProof {
+		proof.AccountProof[i] = hexutil.Bytes(node)
+	}
+	for i, node := range storageProof {
+		proof.StorageProof[i] = hexutil.Bytes(node)
+	}
+	return proof, nil
+}
+
+// VerifyKeyImageAbsence checks a proof that a key image is absent from the
+// spent set at the state root of the proof. It returns ErrKeyImageSpent if the
+// proof shows the key image spent instead.
+func VerifyKeyImageAbsence(proof *KeyImageProof, keyImage []byte) error {
+	addr, slot := vm.OTAImageStorageSlot(keyImage)
+
+	enc, err := verifyProof(proof.Root, crypto.Keccak256(addr[:]), proof.AccountProof)
+	if err != nil {
+		return fmt.Errorf("%v: account: %v", ErrInvalidKeyImageProof, err)
+	}
+	if enc == nil {
+		// No key image was ever spent
+		return nil
+	}
+	var account state.Account
+	if err := rlp.DecodeBytes(enc, &account); err != nil {
+		return fmt.Errorf("%v: account: %v", ErrInvalidKeyImageProof, err)
+	}
+	value, err := verifyProof(account.Root, crypto.Keccak256(slot[:]), proof.StorageProof)
+	if err != nil {
+		return fmt.Errorf("%v: storage: %v", ErrInvalidKeyImageProof, err)
+	}
+	if len(value) != 0 {
+		return ErrKeyImageSpent
+	}
+	return nil
+}
+
+// verifyProof returns the value proven for key in the trie of the given root,
+// nil if proven absent. Empty tries have no nodes to prove with.
+func verifyProof(root common.Hash, key []byte, nodes []hexutil.Bytes) ([]byte, error) {
+	if root == types.EmptyRootHash && len(nodes) == 0 {
+		return nil, nil
+	}
+	raw := make([]rlp.RawValue, len(nodes))
+	for i, node := range nodes {
+		raw[i] = rlp.RawValue(node)
+	}
+	return trie.VerifyProof(root, key, raw)
+}
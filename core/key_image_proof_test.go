@@ -0,0 +1,60 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package core
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core/state"
+	"github.com/wanchain/go-wanchain/core/vm"
+	"github.com/wanchain/go-wanchain/ethdb"
+)
+
+func TestKeyImageAbsenceProof(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+
+	spent, unspent := []byte("spent key image"), []byte("unspent key image")
+
+	// Nothing spent yet, the absence of the spent set account is proven
+	proof, err := ProveKeyImageAbsence(statedb, statedb.IntermediateRoot(false), unspent)
+	if err != nil {
+		t.Fatalf("failed to prove absence from an empty state: %v", err)
+	}
+	if err := VerifyKeyImageAbsence(proof, unspent); err != nil {
+		t.Errorf("absence from an empty state rejected: %v", err)
+	}
+
+	statedb.SetBalance(common.HexToAddress("0x01"), big.NewInt(1))
+	for i := 0; i < 32; i++ {
+		vm.AddOTAImage(statedb, []byte{byte(i)}, big.NewInt(1).Bytes())
+	}
+	vm.AddOTAImage(statedb, spent, big.NewInt(1).Bytes())
+	root, err := statedb.CommitTo(db, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statedb, _ = state.New(root, state.NewDatabase(db))
+
+	proof, err = ProveKeyImageAbsence(statedb, root, unspent)
+	if err != nil {
+		t.Fatalf("failed to prove absence: %v", err)
+	}
+	if err := VerifyKeyImageAbsence(proof, unspent); err != nil {
+		t.Errorf("absence rejected: %v", err)
+	}
+	if _, err := ProveKeyImageAbsence(statedb, root, spent); err != ErrKeyImageSpent {
+		t.Errorf("spent key image proof error mismatch: have %v, want %v", err, ErrKeyImageSpent)
+	}
+	// The proof doesn't hold for another key image, nor against another root
+	if err := VerifyKeyImageAbsence(proof, spent); err != ErrKeyImageSpent && (err == nil || !strings.HasPrefix(err.Error(), ErrInvalidKeyImageProof.Error())) {
+		t.Errorf("proof accepted for a spent key image: %v", err)
+	}
+	proof.Root = common.HexToHash("0x01")
+	if err := VerifyKeyImageAbsence(proof, unspent); err == nil || !strings.HasPrefix(err.Error(), ErrInvalidKeyImageProof.Error()) {
+		t.Errorf("proof against a wrong root error mismatch: have %v, want %v", err, ErrInvalidKeyImageProof)
+	}
+}
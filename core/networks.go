@@ -0,0 +1,65 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package core
+
+import (
+	"sort"
+
+	"github.com/wanchain/go-wanchain/params"
+)
+
+// Network is the preset of a known network: everything a node needs to join it
+// set together, so it can't end up on the chain of one network while dialing
+// the peers of another. The chain ID and the privacy forks come with the chain
+// config of the genesis.
+type Network struct {
+	Name        string
+	NetworkId   uint64          // Network ID to select the peers with
+	DataDir     string          // Subdirectory of the data directory, empty for the directory itself
+	Genesis     func() *Genesis // Genesis block of the chain
+	Bootnodes   []string        // Discovery v4 bootstrap nodes
+	BootnodesV5 []string        // Discovery v5 bootstrap nodes, nil for the default ones
+}
+
+// Networks is the registry of the known networks, by name.
+var Networks = map[string]*Network{
+	"mainnet": {
+		Name:      "mainnet",
+		NetworkId: 1,
+		Genesis:   DefaultGenesisBlock,
+		Bootnodes: params.MainnetBootnodes,
+	},
+	"testnet": {
+		Name:      "testnet",
+		NetworkId: 3,
+		DataDir:   "testnet",
+		Genesis:   DefaultTestnetGenesisBlock,
+		Bootnodes: params.TestnetBootnodes,
+	},
+	"internal": {
+		Name:        "internal",
+		NetworkId:   4,
+		DataDir:     "internal",
+		Genesis:     DefaultInternalGenesisBlock,
+		Bootnodes:   params.InternalBootnodes,
+		BootnodesV5: params.InternalV5Bootnodes,
+	},
+	"pluto": {
+		Name:        "pluto",
+		NetworkId:   6,
+		DataDir:     "pluto",
+		Genesis:     DefaultPlutoGenesisBlock,
+		Bootnodes:   params.PlutoBootnodes,
+		BootnodesV5: params.PlutoV5ootnodes,
+	},
+}
+
+// NetworkNames returns the sorted names of the known networks.
+func NetworkNames() []string {
+	names := make([]string, 0, len(Networks))
+	for name := range Networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,38 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package core
+
+import (
+	"testing"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/params"
+)
+
+// Tests that the network presets are consistent: the genesis blocks are the
+// enforced ones and their chain IDs are distinct.
+func TestNetworkPresets(t *testing.T) {
+	hashes := map[string]common.Hash{
+		"mainnet": params.MainnetGenesisHash,
+		"testnet": params.TestnetGenesisHash,
+		"pluto":   params.PlutoGenesisHash,
+	}
+	chainIds := make(map[uint64]string)
+	for _, name := range NetworkNames() {
+		network := Networks[name]
+		if network.Name != name {
+			t.Errorf("%s: name mismatch: have %s", name, network.Name)
+		}
+		genesis := network.Genesis()
+		if want, ok := hashes[name]; ok {
+			if block, _ := genesis.ToBlock(); block.Hash() != want {
+				t.Errorf("%s: genesis hash mismatch: have %x, want %x", name, block.Hash(), want)
+			}
+		}
+		id := genesis.Config.ChainId.Uint64()
+		if other, ok := chainIds[id]; ok {
+			t.Errorf("%s: chain ID %d already used by %s", name, id, other)
+		}
+		chainIds[id] = name
+	}
+}
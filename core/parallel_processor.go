@@ -0,0 +1,160 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package core
+
+import (
+	"math/big"
+	"sync"
+	"sync/atomic"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core/state"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/core/vm"
+	"github.com/wanchain/go-wanchain/log"
+	"github.com/wanchain/go-wanchain/metrics"
+)
+
+// The parallel execution of the block transactions is optimistic: every
+// transaction is first executed concurrently on its own copy of the parent
+// state, recording the state it accesses. The results are then merged in block
+// order. A transaction that read state written by a preceding one, failed, or
+// accessed the state in ways that can't be tracked is re-executed on the merged
+// state instead, so the outcome is always that of the serial execution.
+
+const (
+	minParallelTxs   = 4   // Smallest number of transactions executed in parallel
+	maxConflictRatio = 0.5 // Ratio of re-executed transactions above which to back off
+	serialBackoff    = 64  // Number of blocks executed serially after backing off
+)
+
+var (
+	parallelTxMeter       = metrics.NewMeter("chain/parallel/txs")
+	parallelConflictMeter = metrics.NewMeter("chain/parallel/conflicts")
+)
+
+// speculation is the outcome of a transaction executed on the parent state.
+type speculation struct {
+	statedb *state.StateDB
+	access  *state.AccessSet
+	receipt *types.Receipt
+	gas     *big.Int
+	err     error
+}
+
+// parallel reports whether the block should be executed in parallel.
+func (p *StateProcessor) parallel(block *types.Block, cfg vm.Config) bool {
+	if cfg.ParallelWorkers < 2 || cfg.Debug || cfg.Tracer != nil || p.bc == nil {
+		return false
+	}
+	if len(block.Transactions()) < minParallelTxs {
+		return false
+	}
+	for {
+		backoff := atomic.LoadInt32(&p.serialBlocks)
+		if backoff == 0 {
+			return true
+		}
+		if atomic.CompareAndSwapInt32(&p.serialBlocks, backoff, backoff-1) {
+			return false
+		}
+	}
+}
+
+// processParallel applies the transactions of the block to statedb, which must
+// hold the unmodified state of the parent block, executing them in parallel.
+// It returns false if the state doesn't allow it.
+func (p *StateProcessor) processParallel(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, *big.Int, bool, error) {
+	parent := p.bc.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil || statedb.IntermediateRoot(true) != parent.Root {
+		return nil, nil, nil, false, nil
+	}
+	var (
+		txs          = block.Transactions()
+		header       = block.Header()
+		specs        = p.speculate(block, parent.Root, statedb.Database(), cfg)
+		receipts     types.Receipts
+		allLogs      []*types.Log
+		totalUsedGas = big.NewInt(0)
+		gp           = new(GasPool).AddGas(block.GasLimit())
+		written      = make(state.WriteSet)
+		conflicts    int
+	)
+	for i, tx := range txs {
+		statedb.Prepare(tx.Hash(), block.Hash(), i)
+
+		spec := specs[i]
+		if spec.err == nil && !spec.access.Unsafe() && !spec.access.Conflicts(written) && (*big.Int)(gp).Cmp(tx.Gas()) >= 0 {
+			statedb.Merge(spec.statedb, spec.access)
+			for _, l := range spec.receipt.Logs {
+				statedb.AddLog(l)
+			}
+			statedb.Finalise(true)
+
+			gp.SubGas(spec.gas)
+			totalUsedGas.Add(totalUsedGas, spec.gas)
+			spec.receipt.CumulativeGasUsed = new(big.Int).Set(totalUsedGas)
+
+			receipts = append(receipts, spec.receipt)
+			allLogs = append(allLogs, spec.receipt.Logs...)
+			written.Add(spec.access)
+			continue
+		}
+		// The speculation is invalid, execute the transaction serially
+		conflicts++
+
+		statedb.TrackAccess()
+		receipt, _, err := ApplyTransaction(p.config, p.bc, nil, gp, statedb, header, tx, totalUsedGas, cfg)
+		access := statedb.StopTracking()
+		if err != nil {
+			return nil, nil, nil, true, err
+		}
+		receipts = append(receipts, receipt)
+		allLogs = append(allLogs, receipt.Logs...)
+		written.Add(access)
+	}
+	parallelTxMeter.Mark(int64(len(txs)))
+	parallelConflictMeter.Mark(int64(conflicts))
+
+	if float64(conflicts) > maxConflictRatio*float64(len(txs)) {
+		log.Debug("Backing off parallel execution", "number", block.Number(), "txs", len(txs), "conflicts", conflicts)
+		atomic.StoreInt32(&p.serialBlocks, serialBackoff)
+	}
+	return receipts, allLogs, totalUsedGas, true, nil
+}
+
+// speculate executes every transaction of the block on its own copy of the
+// parent state.
+func (p *StateProcessor) speculate(block *types.Block, root common.Hash, db state.Database, cfg vm.Config) []*speculation {
+	var (
+		txs    = block.Transactions()
+		header = block.Header()
+		specs  = make([]*speculation, len(txs))
+		next   = int32(-1)
+		wg     sync.WaitGroup
+	)
+	for w := 0; w < cfg.ParallelWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt32(&next, 1))
+				if i >= len(txs) {
+					return
+				}
+				spec := new(speculation)
+				if spec.statedb, spec.err = state.New(root, db); spec.err == nil {
+					spec.statedb.Prepare(txs[i].Hash(), block.Hash(), i)
+					spec.statedb.TrackAccess()
+
+					gp := new(GasPool).AddGas(block.GasLimit())
+					spec.receipt, spec.gas, spec.err = ApplyTransaction(p.config, p.bc, nil, gp, spec.statedb, header, txs[i], new(big.Int), cfg)
+					spec.access = spec.statedb.StopTracking()
+				}
+				specs[i] = spec
+			}
+		}()
+	}
+	wg.Wait()
+	return specs
+}
@@ -0,0 +1,93 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package core
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/crypto"
+)
+
+// Tests that blocks executed in parallel yield the state and receipts of the
+// serial execution, whether their transactions conflict or not.
+func TestParallelProcess(t *testing.T) {
+	var (
+		gspec    = DefaultPPOWTestingGenesisBlock()
+		keys     = make([]*ecdsa.PrivateKey, 8)
+		counter  = common.HexToAddress("0x2000000000000000000000000000000000000001")
+		logger   = common.HexToAddress("0x2000000000000000000000000000000000000002")
+		coinbase = common.HexToAddress("0x3000000000000000000000000000000000000000")
+		funds    = new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18))
+	)
+	gspec.Alloc = GenesisAlloc{
+		common.Address{1}: {Balance: big.NewInt(1)},
+		// PUSH1 0 SLOAD PUSH1 1 ADD PUSH1 0 SSTORE
+		counter: {Balance: new(big.Int), Code: common.Hex2Bytes("60005460010160005500")},
+		// PUSH1 0 PUSH1 0 LOG0
+		logger: {Balance: new(big.Int), Code: common.Hex2Bytes("60006000a000")},
+	}
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		gspec.Alloc[crypto.PubkeyToAddress(keys[i].PublicKey)] = GenesisAccount{Balance: funds}
+	}
+	serial, err := NewEphemeralChain(gspec)
+	if err != nil {
+		t.Fatalf("failed to create serial chain: %v", err)
+	}
+	defer serial.Stop()
+	parallel, err := NewEphemeralChain(gspec)
+	if err != nil {
+		t.Fatalf("failed to create parallel chain: %v", err)
+	}
+	defer parallel.Stop()
+	parallel.BlockChain().vmConfig.ParallelWorkers = 4
+	processor := parallel.BlockChain().Processor().(*StateProcessor)
+
+	var (
+		signer = types.NewEIP155Signer(gspec.Config.ChainId)
+		nonces = make([]uint64, len(keys))
+	)
+	tx := func(from int, to common.Address, value int64) *types.Transaction {
+		tx, _ := types.SignTx(types.NewTransaction(nonces[from], to, big.NewInt(value), big.NewInt(100000), big.NewInt(1), nil), signer, keys[from])
+		nonces[from]++
+		return tx
+	}
+	tests := []struct {
+		name    string
+		txs     types.Transactions
+		backoff bool
+	}{
+		{"independent", types.Transactions{
+			tx(0, common.Address{1}, 1), tx(1, common.Address{2}, 1), tx(2, common.Address{1}, 2), tx(3, common.Address{1}, 3),
+			tx(4, logger, 0), tx(5, logger, 0), tx(6, counter, 0), tx(7, common.Address{3}, 1),
+		}, false},
+		{"conflicting", types.Transactions{
+			tx(0, common.Address{1}, 1), tx(0, common.Address{4}, 1), tx(1, counter, 0), tx(2, counter, 0),
+			tx(3, logger, 0), tx(4, common.Address{1}, 5), tx(5, crypto.PubkeyToAddress(keys[6].PublicKey), 7), tx(6, common.Address{5}, 1),
+		}, false},
+		{"sequential", types.Transactions{
+			tx(7, common.Address{1}, 1), tx(7, common.Address{1}, 1), tx(7, counter, 0), tx(7, logger, 0), tx(7, common.Address{6}, 1),
+		}, true},
+	}
+	for _, tt := range tests {
+		block, _, err := serial.ApplyTransactions(coinbase, tt.txs)
+		if err != nil {
+			t.Fatalf("%s: failed to apply transactions: %v", tt.name, err)
+		}
+		if _, err := parallel.InsertBlocks(types.Blocks{block}); err != nil {
+			t.Fatalf("%s: parallel execution diverged: %v", tt.name, err)
+		}
+		if backoff := processor.serialBlocks > 0; backoff != tt.backoff {
+			t.Errorf("%s: backoff mismatch: have %v, want %v", tt.name, backoff, tt.backoff)
+		}
+		processor.serialBlocks = 0
+	}
+	statedb, _ := parallel.State()
+	if value := statedb.GetState(counter, common.Hash{}); value != common.BigToHash(big.NewInt(4)) {
+		t.Errorf("counter mismatch: have %x, want 4", value)
+	}
+}
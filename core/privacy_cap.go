@@ -0,0 +1,104 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package core
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/core/vm"
+	"github.com/wanchain/go-wanchain/params"
+)
+
+// ErrPrivacyCapReached is returned if a block includes more privacy transactions
+// than the privacy cap allows.
+var ErrPrivacyCapReached = errors.New("privacy transaction cap reached")
+
+// IsPrivacyTx returns whether the transaction verifies a ring signature: a
+// stamped privacy transaction, or a refund or a revealed refund from the
+// wancoin contract.
+func IsPrivacyTx(tx *types.Transaction) bool {
+	if !types.IsNormalTransaction(tx.Txtype()) {
+		return true
+	}
+	return vm.IsRingSignedCall(tx.To(), tx.Data())
+}
+
+// PrivacyCap tallies the privacy transactions of a block against the privacy
+// cap of the chain configuration. A nil cap, before the fork or the activation
+// of the privacy cap deployment, admits them all.
+type PrivacyCap struct {
+	maxTxs uint64   // Privacy transactions admitted, zero if unbounded
+	maxGas *big.Int // Gas the privacy transactions may claim, nil if unbounded
+	txs    uint64   // Privacy transactions included so far
+	gas    *big.Int // Gas limit of the privacy transactions included so far
+}
+
+// NewPrivacyCap creates the privacy cap of the block with the given header, nil
+// if the block isn't capped. Active is whether the cap applies to the block, as
+// reported by BlockChain.PrivacyCapActive.
+func NewPrivacyCap(config *params.ChainConfig, header *types.Header, active bool) *PrivacyCap {
+	if config.PrivacyCap == nil || !active {
+		return nil
+	}
+	c := &PrivacyCap{maxTxs: config.PrivacyCap.MaxTxs, gas: new(big.Int)}
+	if share := config.PrivacyCap.MaxGasShare; share > 0 && share < 100 {
+		c.maxGas = new(big.Int).Mul(header.GasLimit, new(big.Int).SetUint64(share))
+		c.maxGas.Div(c.maxGas, big.NewInt(100))
+	}
+	return c
+}
+
+// Fits returns whether the transaction can be included without exceeding the
+// cap. Non privacy transactions always fit.
+func (c *PrivacyCap) Fits(tx *types.Transaction) bool {
+	if c == nil || !IsPrivacyTx(tx) {
+		return true
+	}
+	if c.maxTxs > 0 && c.txs >= c.maxTxs {
+		return false
+	}
+	if c.maxGas != nil && new(big.Int).Add(c.gas, tx.Gas()).Cmp(c.maxGas) > 0 {
+		return false
+	}
+	return true
+}
+
+// Add tallies an included transaction.
+func (c *PrivacyCap) Add(tx *types.Transaction) {
+	if c == nil || !IsPrivacyTx(tx) {
+		return
+	}
+	c.txs++
+	c.gas.Add(c.gas, tx.Gas())
+}
+
+// PrivacyCapActive reports whether the privacy cap applies to the block with
+// the given header: from the privacy cap fork on, or once the privacy cap
+// deployment is active.
+func (bc *BlockChain) PrivacyCapActive(header *types.Header) bool {
+	if bc.config.IsPrivacyCap(header.Number) {
+		return true
+	}
+	if header.Number.Sign() == 0 {
+		return false
+	}
+	parent := bc.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	return parent != nil && bc.DeploymentActive(parent, params.PrivacyCapDeployment)
+}
+
+// validatePrivacyCap checks the privacy transactions of a block against the cap.
+func validatePrivacyCap(config *params.ChainConfig, block *types.Block, active bool) error {
+	privacyCap := NewPrivacyCap(config, block.Header(), active)
+	if privacyCap == nil {
+		return nil
+	}
+	for _, tx := range block.Transactions() {
+		if !privacyCap.Fits(tx) {
+			return ErrPrivacyCapReached
+		}
+		privacyCap.Add(tx)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/consensus/ethash"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/core/vm"
+	"github.com/wanchain/go-wanchain/crypto"
+	"github.com/wanchain/go-wanchain/ethdb"
+	"github.com/wanchain/go-wanchain/params"
+)
+
+// Tests that the privacy cap bounds the privacy transactions of a block by count
+// and by gas share, ignoring the other transactions, and only after its fork.
+func TestPrivacyCap(t *testing.T) {
+	to := common.HexToAddress("0x01")
+	privacy := func(gas int64) *types.Transaction {
+		return types.NewOTATransaction(0, to, nil, big.NewInt(gas), big.NewInt(1), nil)
+	}
+	normal := types.NewTransaction(0, to, big.NewInt(0), big.NewInt(1000000), big.NewInt(1), nil)
+
+	// Revealed refunds of the commit/reveal scheme verify a ring signature too
+	coin := common.BytesToAddress([]byte{100})
+	reveal := func(gas int64) *types.Transaction {
+		data := append(crypto.Keccak256([]byte("revealRefundCoin(string,uint256)"))[:4], make([]byte, 64)...)
+		return types.NewTransaction(0, coin, big.NewInt(0), big.NewInt(gas), big.NewInt(1), data)
+	}
+
+	tests := []struct {
+		cap   *params.PrivacyCapConfig
+		fork  *big.Int
+		txs   []*types.Transaction
+		valid bool
+	}{
+		// No cap configured or not forked yet
+		{nil, big.NewInt(0), []*types.Transaction{privacy(1), privacy(1), privacy(1)}, true},
+		{&params.PrivacyCapConfig{MaxTxs: 1}, nil, []*types.Transaction{privacy(1), privacy(1)}, true},
+		{&params.PrivacyCapConfig{MaxTxs: 1}, big.NewInt(2), []*types.Transaction{privacy(1), privacy(1)}, true},
+
+		// Capped by count
+		{&params.PrivacyCapConfig{MaxTxs: 2}, big.NewInt(1), []*types.Transaction{privacy(1), normal, privacy(1), normal}, true},
+		{&params.PrivacyCapConfig{MaxTxs: 2}, big.NewInt(1), []*types.Transaction{privacy(1), privacy(1), privacy(1)}, false},
+		{&params.PrivacyCapConfig{MaxTxs: 2}, big.NewInt(1), []*types.Transaction{reveal(1), reveal(1), reveal(1)}, false},
+		{&params.PrivacyCapConfig{MaxTxs: 2}, big.NewInt(1), []*types.Transaction{privacy(1), reveal(1), reveal(1)}, false},
+
+		// Capped by gas share of the 1000000 gas limit
+		{&params.PrivacyCapConfig{MaxGasShare: 10}, big.NewInt(0), []*types.Transaction{privacy(50000), normal, privacy(50000)}, true},
+		{&params.PrivacyCapConfig{MaxGasShare: 10}, big.NewInt(0), []*types.Transaction{privacy(50000), privacy(50001)}, false},
+		{&params.PrivacyCapConfig{MaxGasShare: 10}, big.NewInt(0), []*types.Transaction{reveal(50000), reveal(50001)}, false},
+		{&params.PrivacyCapConfig{MaxGasShare: 100}, big.NewInt(0), []*types.Transaction{privacy(1000000), privacy(1000000)}, true},
+	}
+	for i, tt := range tests {
+		config := &params.ChainConfig{ChainId: big.NewInt(1), PrivacyCapBlock: tt.fork, PrivacyCap: tt.cap}
+		header := &types.Header{Number: big.NewInt(1), GasLimit: big.NewInt(1000000)}
+
+		err := validatePrivacyCap(config, types.NewBlock(header, tt.txs, nil, nil), config.IsPrivacyCap(header.Number))
+		if tt.valid && err != nil {
+			t.Errorf("test %d: valid block rejected: %v", i, err)
+		}
+		if !tt.valid && err != ErrPrivacyCapReached {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, ErrPrivacyCapReached)
+		}
+	}
+}
+
+// Tests that the privacy cap applies once its deployment is active, without a
+// fork block.
+func TestPrivacyCapDeployment(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	genesis := DefaultPPOWTestingGenesisBlock().MustCommit(db)
+
+	config := *params.TestChainConfig
+	config.PrivacyCapBlock = nil
+	config.Deployments = []*params.Deployment{
+		{Name: params.PrivacyCapDeployment, Bit: 0, StartBlock: big.NewInt(0), Window: 2, Threshold: 2},
+	}
+	chain, err := NewBlockChain(db, &config, ethash.NewFaker(db), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	// Blocks 2 and 3 signal, locking the cap in for blocks 4 and 5 and
+	// activating it from block 6 on
+	headers := []*types.Header{genesis.Header()}
+	for i := 1; i <= 6; i++ {
+		header := &types.Header{
+			ParentHash: headers[i-1].Hash(),
+			Number:     big.NewInt(int64(i)),
+			Difficulty: big.NewInt(1),
+			Extra:      make([]byte, 97),
+		}
+		if i == 2 || i == 3 {
+			SetSignalBits(header, 1)
+		}
+		if err := WriteHeader(db, header); err != nil {
+			t.Fatalf("failed to write header %d: %v", i, err)
+		}
+		headers = append(headers, header)
+	}
+	for i, header := range headers {
+		if active := chain.PrivacyCapActive(header); active != (i >= 6) {
+			t.Errorf("block %d: privacy cap activation mismatch: have %v, want %v", i, active, i >= 6)
+		}
+	}
+}
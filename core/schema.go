@@ -0,0 +1,28 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package core
+
+import (
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/ethdb"
+)
+
+// schemaMigrations upgrade the layout of the chain database, the schema version
+// being the number applied. Migrations are only ever appended; one changing a
+// key layout must also upgrade the data written in the previous layout, e.g.
+// index the existing blocks, so nodes keep their chain across upgrades.
+var schemaMigrations = []ethdb.Migration{}
+
+// SchemaVersion is the layout version of the chain databases written by this
+// version.
+var SchemaVersion = uint64(len(schemaMigrations))
+
+// MigrateDatabase upgrades the chain database to the latest schema version,
+// rolling back a failed migration. New databases are created at the latest
+// version.
+func MigrateDatabase(db ethdb.Database) error {
+	if GetHeadHeaderHash(db) == (common.Hash{}) && ethdb.SchemaVersion(db) == 0 {
+		return ethdb.WriteSchemaVersion(db, uint64(len(schemaMigrations)))
+	}
+	return ethdb.Migrate(db, schemaMigrations)
+}
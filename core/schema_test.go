@@ -0,0 +1,40 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package core
+
+import (
+	"testing"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/ethdb"
+)
+
+// Tests that new databases are created at the latest schema version, while
+// unversioned existing ones are migrated from scratch.
+func TestMigrateDatabase(t *testing.T) {
+	defer func(migrations []ethdb.Migration) { schemaMigrations = migrations }(schemaMigrations)
+
+	var applied int
+	schemaMigrations = []ethdb.Migration{{
+		Name: "test",
+		Migrate: func(db ethdb.Database, progress func(done, total uint64)) error {
+			applied++
+			return nil
+		},
+	}}
+	fresh, _ := ethdb.NewMemDatabase()
+	if err := MigrateDatabase(fresh); err != nil {
+		t.Fatalf("failed to set up new database: %v", err)
+	}
+	if applied != 0 || ethdb.SchemaVersion(fresh) != 1 {
+		t.Errorf("new database migrated: applied %d, version %d", applied, ethdb.SchemaVersion(fresh))
+	}
+	existing, _ := ethdb.NewMemDatabase()
+	WriteHeadHeaderHash(existing, common.Hash{1})
+	if err := MigrateDatabase(existing); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	if applied != 1 || ethdb.SchemaVersion(existing) != 1 {
+		t.Errorf("database not migrated: applied %d, version %d", applied, ethdb.SchemaVersion(existing))
+	}
+}
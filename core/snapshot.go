@@ -0,0 +1,423 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/consensus"
+	"github.com/wanchain/go-wanchain/core/state"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/crypto"
+	"github.com/wanchain/go-wanchain/ethdb"
+	"github.com/wanchain/go-wanchain/log"
+	"github.com/wanchain/go-wanchain/params"
+	"github.com/wanchain/go-wanchain/rlp"
+)
+
+// A snapshot archive is an RLP stream of the SnapshotHeader, the canonical
+// blocks from the genesis to the head along with their receipts and total
+// difficulty, and the nodes of the state of the head (trie nodes and contract
+// code) until the end of the stream. Nothing of the archive is trusted: the
+// genesis must be the one of the node, the headers are verified by the
+// consensus engine, the blocks are linked by their hashes up to the head hash
+// and the nodes are keyed by their hash up to the state root of the head.
+
+const (
+	snapshotMagic   = "wanchain-snapshot"
+	snapshotVersion = 1
+
+	// Depth of the chain behind the head which may still be reorganised while
+	// the snapshot is exported. It is exported from the hashes pinned at start.
+	snapshotReorgDepth = 64
+
+	// Number of blocks of a snapshot verified by the consensus engine at once.
+	snapshotVerifyBatch = 2048
+)
+
+var errSnapshotNotEmpty = errors.New("snapshots can only be imported into an empty database")
+
+// SnapshotHeader describes the chain and state of a snapshot archive.
+type SnapshotHeader struct {
+	Magic   string
+	Version uint64
+	Genesis common.Hash
+	Config  []byte // JSON chain config of the genesis, informative as the importer uses its own
+	Number  uint64
+	Hash    common.Hash
+	Root    common.Hash
+}
+
+// snapshotBlock is a block of a snapshot archive.
+type snapshotBlock struct {
+	Block    *types.Block
+	Receipts []*types.ReceiptForStorage
+	Td       *big.Int
+}
+
+// snapshotNode is a state trie node or a contract code of a snapshot archive.
+type snapshotNode struct {
+	Hash common.Hash
+	Blob []byte
+}
+
+// ExportSnapshot writes a snapshot archive of the current head to w. The chain
+// keeps running meanwhile: the head is pinned at start and its state, being
+// content addressed, is never modified.
+func (bc *BlockChain) ExportSnapshot(w io.Writer) (*SnapshotHeader, error) {
+	head := bc.CurrentBlock()
+	genesis := bc.Genesis()
+
+	config, err := bc.chainDb.Get(append(configPrefix, genesis.Hash().Bytes()...))
+	if err != nil {
+		return nil, fmt.Errorf("chain config not found: %v", err)
+	}
+	header := &SnapshotHeader{
+		Magic:   snapshotMagic,
+		Version: snapshotVersion,
+		Genesis: genesis.Hash(),
+		Config:  config,
+		Number:  head.NumberU64(),
+		Hash:    head.Hash(),
+		Root:    head.Root(),
+	}
+	if err := rlp.Encode(w, header); err != nil {
+		return nil, err
+	}
+	log.Info("Exporting snapshot", "number", header.Number, "hash", header.Hash, "root", header.Root)
+
+	// Pin the hashes of the recent blocks, the older ones can't be reorganised
+	var (
+		recent = make([]common.Hash, 0, snapshotReorgDepth)
+		hash   = head.Hash()
+	)
+	for i := 0; i < snapshotReorgDepth && uint64(i) <= header.Number; i++ {
+		recent = append(recent, hash)
+		hash = bc.GetHeaderByHash(hash).ParentHash
+	}
+	var (
+		parent = common.Hash{}
+		start  = time.Now()
+		logged = time.Now()
+	)
+	for number := uint64(0); number <= header.Number; number++ {
+		hash := GetCanonicalHash(bc.chainDb, number)
+		if offset := header.Number - number; offset < uint64(len(recent)) {
+			hash = recent[offset]
+		}
+		block := bc.GetBlock(hash, number)
+		if block == nil {
+			return nil, fmt.Errorf("block #%d [%x…] not found", number, hash[:4])
+		}
+		if block.ParentHash() != parent {
+			return nil, fmt.Errorf("chain reorganised during export at block #%d", number)
+		}
+		parent = block.Hash()
+
+		receipts := GetBlockReceipts(bc.chainDb, hash, number)
+		item := &snapshotBlock{Block: block, Receipts: make([]*types.ReceiptForStorage, len(receipts)), Td: bc.GetTd(hash, number)}
+		for i, receipt := range receipts {
+			item.Receipts[i] = (*types.ReceiptForStorage)(receipt)
+		}
+		if err := rlp.Encode(w, item); err != nil {
+			return nil, err
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Exporting snapshot blocks", "number", number, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	// Export the state of the head
+	statedb, err := state.New(header.Root, state.NewDatabase(bc.chainDb))
+	if err != nil {
+		return nil, err
+	}
+	var nodes uint64
+	it := state.NewNodeIterator(statedb)
+	for it.Next() {
+		if it.Hash == (common.Hash{}) {
+			continue // Embedded in its parent
+		}
+		blob, err := bc.chainDb.Get(it.Hash.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("state node %x: %v", it.Hash, err)
+		}
+		if err := rlp.Encode(w, &snapshotNode{Hash: it.Hash, Blob: blob}); err != nil {
+			return nil, err
+		}
+		if nodes++; time.Since(logged) > 8*time.Second {
+			log.Info("Exporting snapshot state", "nodes", nodes, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if it.Error != nil {
+		return nil, it.Error
+	}
+	log.Info("Exported snapshot", "number", header.Number, "nodes", nodes, "elapsed", common.PrettyDuration(time.Since(start)))
+	return header, nil
+}
+
+// ImportSnapshot bootstraps an empty database from a snapshot archive of the
+// chain of genesis, verifying the chain and state of the archive with engine.
+// The head is only set once everything is verified, an interrupted import
+// leaves no usable chain behind.
+func ImportSnapshot(db ethdb.Database, genesis *Genesis, engine consensus.Engine, r io.Reader) (*SnapshotHeader, error) {
+	if GetHeadHeaderHash(db) != (common.Hash{}) {
+		return nil, errSnapshotNotEmpty
+	}
+	stream := rlp.NewStream(r, 0)
+
+	header := new(SnapshotHeader)
+	if err := stream.Decode(header); err != nil {
+		return nil, fmt.Errorf("invalid snapshot header: %v", err)
+	}
+	if header.Magic != snapshotMagic || header.Version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot %q version %d", header.Magic, header.Version)
+	}
+	if genesis.Config == nil {
+		return nil, errGenesisNoConfig
+	}
+	if block, _ := genesis.ToBlock(); header.Genesis != block.Hash() {
+		return nil, fmt.Errorf("snapshot of another chain: genesis %x, want %x", header.Genesis, block.Hash())
+	}
+	log.Info("Importing snapshot", "number", header.Number, "hash", header.Hash, "root", header.Root)
+
+	var (
+		batch   = db.NewBatch()
+		chain   = &snapshotChain{db: db, config: genesis.Config}
+		pending []*snapshotBlock
+		parent  = common.Hash{}
+		td      = new(big.Int)
+		start   = time.Now()
+		logged  = time.Now()
+	)
+	flush := func(force bool) error {
+		if force || batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch = db.NewBatch()
+		}
+		return nil
+	}
+	for number := uint64(0); number <= header.Number; number++ {
+		item := new(snapshotBlock)
+		if err := stream.Decode(item); err != nil {
+			return nil, fmt.Errorf("block #%d: %v", number, err)
+		}
+		block, receipts := item.Block, make(types.Receipts, len(item.Receipts))
+		for i, receipt := range item.Receipts {
+			receipts[i] = (*types.Receipt)(receipt)
+		}
+		if err := verifySnapshotBlock(header, block, receipts, number, parent); err != nil {
+			return nil, err
+		}
+		if td.Add(td, block.Difficulty()); item.Td == nil || item.Td.Cmp(td) != 0 {
+			return nil, fmt.Errorf("block #%d: total difficulty mismatch: have %v, want %v", number, item.Td, td)
+		}
+		parent = block.Hash()
+
+		// Verify the headers with the engine in batches, their parents being
+		// read back from the database
+		if pending = append(pending, item); len(pending) < snapshotVerifyBatch && number < header.Number {
+			continue
+		}
+		if err := verifySnapshotHeaders(chain, engine, pending); err != nil {
+			return nil, err
+		}
+		for _, item := range pending {
+			if err := writeSnapshotBlock(batch, item); err != nil {
+				return nil, err
+			}
+		}
+		if err := flush(true); err != nil {
+			return nil, err
+		}
+		pending = pending[:0]
+
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Importing snapshot blocks", "number", number, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	// Import the state nodes, checking them against their hash
+	var nodes uint64
+	for {
+		node := new(snapshotNode)
+		if err := stream.Decode(node); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("state node %d: %v", nodes, err)
+		}
+		if crypto.Keccak256Hash(node.Blob) != node.Hash {
+			return nil, fmt.Errorf("state node %x: hash mismatch", node.Hash)
+		}
+		if err := batch.Put(node.Hash.Bytes(), node.Blob); err != nil {
+			return nil, err
+		}
+		if err := flush(false); err != nil {
+			return nil, err
+		}
+		if nodes++; time.Since(logged) > 8*time.Second {
+			log.Info("Importing snapshot state", "nodes", nodes, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := WriteChainConfig(db, header.Genesis, genesis.Config); err != nil {
+		return nil, err
+	}
+	if err := flush(true); err != nil {
+		return nil, err
+	}
+	// Verify the state before making the chain usable
+	if err := VerifySnapshot(db, header); err != nil {
+		return nil, err
+	}
+	WriteBlockChainVersion(db, BlockChainVersion)
+	if err := ethdb.WriteSchemaVersion(db, uint64(len(schemaMigrations))); err != nil {
+		return nil, err
+	}
+	for _, write := range []func(ethdb.Putter, common.Hash) error{WriteHeadHeaderHash, WriteHeadFastBlockHash, WriteHeadBlockHash} {
+		if err := write(db, header.Hash); err != nil {
+			return nil, err
+		}
+	}
+	log.Info("Imported snapshot", "number", header.Number, "nodes", nodes, "elapsed", common.PrettyDuration(time.Since(start)))
+	return header, nil
+}
+
+// verifySnapshotHeaders checks the headers of a batch of snapshot blocks with
+// the consensus engine, the genesis being checked by its hash.
+func verifySnapshotHeaders(chain consensus.ChainReader, engine consensus.Engine, blocks []*snapshotBlock) error {
+	headers := make([]*types.Header, 0, len(blocks))
+	for _, item := range blocks {
+		if item.Block.NumberU64() > 0 {
+			headers = append(headers, item.Block.Header())
+		}
+	}
+	seals := make([]bool, len(headers))
+	for i := range seals {
+		seals[i] = true
+	}
+	abort, results := engine.VerifyHeaders(chain, headers, seals)
+	defer close(abort)
+
+	for _, header := range headers {
+		if err := <-results; err != nil {
+			return fmt.Errorf("block #%d: %v", header.Number, err)
+		}
+	}
+	return nil
+}
+
+// writeSnapshotBlock writes a verified snapshot block as a canonical block.
+func writeSnapshotBlock(batch ethdb.Batch, item *snapshotBlock) error {
+	block, number := item.Block, item.Block.NumberU64()
+	receipts := make(types.Receipts, len(item.Receipts))
+	for i, receipt := range item.Receipts {
+		receipts[i] = (*types.Receipt)(receipt)
+	}
+	if err := WriteTd(batch, block.Hash(), number, item.Td); err != nil {
+		return err
+	}
+	if err := WriteBlock(batch, block); err != nil {
+		return err
+	}
+	if err := WriteBlockReceipts(batch, block.Hash(), number, receipts); err != nil {
+		return err
+	}
+	if err := WriteCanonicalHash(batch, block.Hash(), number); err != nil {
+		return err
+	}
+	return WriteTxLookupEntries(batch, block)
+}
+
+// snapshotChain is the chain of a snapshot being imported, as written so far,
+// for the consensus engine to verify the next headers against.
+type snapshotChain struct {
+	db     ethdb.Database
+	config *params.ChainConfig
+}
+
+func (c *snapshotChain) Config() *params.ChainConfig { return c.config }
+
+func (c *snapshotChain) CurrentHeader() *types.Header { return nil }
+
+func (c *snapshotChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	return GetHeader(c.db, hash, number)
+}
+
+func (c *snapshotChain) GetHeaderByNumber(number uint64) *types.Header {
+	return GetHeader(c.db, GetCanonicalHash(c.db, number), number)
+}
+
+func (c *snapshotChain) GetHeaderByHash(hash common.Hash) *types.Header {
+	return GetHeader(c.db, hash, GetBlockNumber(c.db, hash))
+}
+
+func (c *snapshotChain) GetBlock(hash common.Hash, number uint64) *types.Block {
+	return GetBlock(c.db, hash, number)
+}
+
+// verifySnapshotBlock checks a block of a snapshot links to its parent and
+// matches its receipts, and that the first and last ones match the header.
+func verifySnapshotBlock(header *SnapshotHeader, block *types.Block, receipts types.Receipts, number uint64, parent common.Hash) error {
+	switch {
+	case block.NumberU64() != number:
+		return fmt.Errorf("block #%d: number mismatch: have %d", number, block.NumberU64())
+	case block.ParentHash() != parent:
+		return fmt.Errorf("block #%d: parent mismatch: have %x, want %x", number, block.ParentHash(), parent)
+	case number == 0 && block.Hash() != header.Genesis:
+		return fmt.Errorf("genesis mismatch: have %x, want %x", block.Hash(), header.Genesis)
+	case number == header.Number && block.Hash() != header.Hash:
+		return fmt.Errorf("head mismatch: have %x, want %x", block.Hash(), header.Hash)
+	case number == header.Number && block.Root() != header.Root:
+		return fmt.Errorf("state root mismatch: have %x, want %x", block.Root(), header.Root)
+	}
+	if hash := types.CalcUncleHash(block.Uncles()); hash != block.UncleHash() {
+		return fmt.Errorf("block #%d: uncle hash mismatch: have %x, want %x", number, hash, block.UncleHash())
+	}
+	if hash := types.DeriveSha(block.Transactions()); hash != block.TxHash() {
+		return fmt.Errorf("block #%d: transaction root mismatch: have %x, want %x", number, hash, block.TxHash())
+	}
+	if hash := types.DeriveSha(receipts); hash != block.ReceiptHash() {
+		return fmt.Errorf("block #%d: receipt root mismatch: have %x, want %x", number, hash, block.ReceiptHash())
+	}
+	return nil
+}
+
+// VerifySnapshot checks that the state of a snapshot is complete: every trie
+// node and contract code reachable from the state root, the OTA and key image
+// storage included, is present. It then checks the key image storage against
+// the key image accumulator of the head.
+func VerifySnapshot(db ethdb.Database, header *SnapshotHeader) error {
+	statedb, err := state.New(header.Root, state.NewDatabase(db))
+	if err != nil {
+		return fmt.Errorf("state root %x: %v", header.Root, err)
+	}
+	it := state.NewNodeIterator(statedb)
+	for it.Next() {
+	}
+	if it.Error != nil {
+		return fmt.Errorf("incomplete state: %v", it.Error)
+	}
+	head := GetHeader(db, header.Hash, header.Number)
+	if head == nil {
+		return fmt.Errorf("head #%d [%x…] not found", header.Number, header.Hash[:4])
+	}
+	if len(head.KeyImageAcc) > 0 && header.Number > 0 {
+		parent := GetHeader(db, head.ParentHash, header.Number-1)
+		if parent == nil {
+			return fmt.Errorf("head parent [%x…] not found", head.ParentHash[:4])
+		}
+		if acc := CalcKeyImageAcc(parent, statedb); acc != head.KeyImageAcc[0] {
+			return fmt.Errorf("key image accumulator mismatch: have %x, want %x", acc, head.KeyImageAcc[0])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package core
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/consensus"
+	"github.com/wanchain/go-wanchain/consensus/ethash"
+	"github.com/wanchain/go-wanchain/core/state"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/crypto"
+	"github.com/wanchain/go-wanchain/ethdb"
+)
+
+// rejectingEngine is a consensus engine rejecting the headers from a given
+// number on.
+type rejectingEngine struct {
+	consensus.Engine
+	from uint64
+}
+
+func (e rejectingEngine) VerifyHeaders(chain consensus.ChainReader, headers []*types.Header, seals []bool) (chan<- struct{}, <-chan error) {
+	abort, results := make(chan struct{}), make(chan error, len(headers))
+	for _, header := range headers {
+		if header.Number.Uint64() >= e.from {
+			results <- errors.New("invalid seal")
+		} else {
+			results <- nil
+		}
+	}
+	return abort, results
+}
+
+// Tests that a snapshot exported from a chain bootstraps a database holding
+// the same chain and state, and that damaged snapshots are rejected.
+func TestSnapshotExportImport(t *testing.T) {
+	var (
+		gspec   = DefaultPPOWTestingGenesisBlock()
+		key, _  = crypto.GenerateKey()
+		sender  = crypto.PubkeyToAddress(key.PublicKey)
+		counter = common.HexToAddress("0x2000000000000000000000000000000000000001")
+	)
+	gspec.Alloc = GenesisAlloc{
+		sender: {Balance: new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18))},
+		// PUSH1 0 SLOAD PUSH1 1 ADD PUSH1 0 SSTORE
+		counter: {Balance: new(big.Int), Code: common.Hex2Bytes("60005460010160005500")},
+	}
+	chain, err := NewEphemeralChain(gspec)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	signer := types.NewEIP155Signer(gspec.Config.ChainId)
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		tx, _ := types.SignTx(types.NewTransaction(nonce, counter, big.NewInt(1), big.NewInt(100000), big.NewInt(1), nil), signer, key)
+		if _, _, err := chain.ApplyTransactions(common.Address{0x03}, types.Transactions{tx}); err != nil {
+			t.Fatalf("failed to apply transaction: %v", err)
+		}
+	}
+	archive := new(bytes.Buffer)
+	header, err := chain.BlockChain().ExportSnapshot(archive)
+	if err != nil {
+		t.Fatalf("failed to export snapshot: %v", err)
+	}
+	if header.Number != 3 || header.Hash != chain.CurrentBlock().Hash() {
+		t.Fatalf("snapshot head mismatch: have #%d %x", header.Number, header.Hash)
+	}
+	// Bootstrap a database from the snapshot and check its chain and state
+	db, _ := ethdb.NewMemDatabase()
+	if _, err := ImportSnapshot(db, gspec, ethash.NewFullFaker(db), bytes.NewReader(archive.Bytes())); err != nil {
+		t.Fatalf("failed to import snapshot: %v", err)
+	}
+	if head := GetHeadBlockHash(db); head != header.Hash {
+		t.Errorf("head mismatch: have %x, want %x", head, header.Hash)
+	}
+	if receipts := GetBlockReceipts(db, header.Hash, header.Number); len(receipts) != 1 {
+		t.Errorf("receipts not imported: %d", len(receipts))
+	}
+	statedb, err := state.New(header.Root, state.NewDatabase(db))
+	if err != nil {
+		t.Fatalf("failed to open imported state: %v", err)
+	}
+	if value := statedb.GetState(counter, common.Hash{}); value != common.BigToHash(big.NewInt(3)) {
+		t.Errorf("counter mismatch: have %x", value)
+	}
+	if config, err := GetChainConfig(db, header.Genesis); err != nil || config.ChainId.Cmp(gspec.Config.ChainId) != 0 {
+		t.Errorf("chain config not imported: %v", err)
+	}
+	if _, err := ImportSnapshot(db, gspec, ethash.NewFullFaker(db), bytes.NewReader(archive.Bytes())); err != errSnapshotNotEmpty {
+		t.Errorf("import into used database error mismatch: have %v", err)
+	}
+	// Truncated and corrupted snapshots must be rejected
+	for name, blob := range map[string][]byte{
+		"truncated": archive.Bytes()[:archive.Len()-64],
+		"corrupted": append(append([]byte{}, archive.Bytes()[:archive.Len()-2]...), archive.Bytes()[archive.Len()-1]^0xff, archive.Bytes()[archive.Len()-1]),
+	} {
+		db, _ := ethdb.NewMemDatabase()
+		if _, err := ImportSnapshot(db, gspec, ethash.NewFullFaker(db), bytes.NewReader(blob)); err == nil {
+			t.Errorf("%s snapshot imported", name)
+		}
+		if head := GetHeadBlockHash(db); head != (common.Hash{}) {
+			t.Errorf("%s snapshot left a head", name)
+		}
+	}
+	// Snapshots of another chain or with headers failing the consensus rules
+	// must be rejected too
+	for name, test := range map[string]struct {
+		genesis *Genesis
+		engine  func(ethdb.Database) consensus.Engine
+	}{
+		"foreign": {DefaultGenesisBlock(), func(db ethdb.Database) consensus.Engine { return ethash.NewFullFaker(db) }},
+		"unsealed": {gspec, func(db ethdb.Database) consensus.Engine {
+			return rejectingEngine{Engine: ethash.NewFullFaker(db), from: 2}
+		}},
+	} {
+		db, _ := ethdb.NewMemDatabase()
+		if _, err := ImportSnapshot(db, test.genesis, test.engine(db), bytes.NewReader(archive.Bytes())); err == nil {
+			t.Errorf("%s snapshot imported", name)
+		}
+		if head := GetHeadBlockHash(db); head != (common.Hash{}) {
+			t.Errorf("%s snapshot left a head", name)
+		}
+	}
+}
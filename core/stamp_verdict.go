@@ -0,0 +1,74 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package core
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/common/hexutil"
+	"github.com/wanchain/go-wanchain/core/state"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/crypto"
+	"github.com/wanchain/go-wanchain/params"
+	"github.com/wanchain/go-wanchain/rlp"
+)
+
+// stampVerdictPrefix separates the stamp verdict signatures from the other uses
+// of the node keys.
+var stampVerdictPrefix = []byte("wanchain stamp verdict")
+
+// StampVerdict is the outcome of the verification of a privacy transaction
+// stamp payload by a full node, against the state of a block, signed by the
+// node key. Light wallets check the stamp of a refund before broadcasting it,
+// trusting the node they asked, identified by its key, and the state root.
+type StampVerdict struct {
+	Number    hexutil.Uint64 `json:"number"`           // Block whose state the payload is verified against
+	Root      common.Hash    `json:"root"`             // State root of the block
+	Payload   common.Hash    `json:"payload"`          // Hash of the verified payload, see StampPayloadHash
+	Valid     bool           `json:"valid"`            // Whether the stamp is valid
+	Reason    string         `json:"reason,omitempty"` // Verification error of an invalid stamp
+	Signature hexutil.Bytes  `json:"signature"`        // Signature of the verdict by the node key
+}
+
+// StampPayloadHash returns the hash identifying a stamp payload: the sender, the
+// data and the gas price of the privacy transaction.
+func StampPayloadHash(from common.Address, data []byte, gasPrice *big.Int) common.Hash {
+	blob, _ := rlp.EncodeToBytes([]interface{}{from, data, gasPrice})
+	return crypto.Keccak256Hash(blob)
+}
+
+// VerifyStamp checks the stamp payload of a privacy transaction against the
+// state of the given block, with the rules of the next one as the transaction
+// pool does, and returns the verdict signed by key.
+func VerifyStamp(config *params.ChainConfig, statedb *state.StateDB, header *types.Header, from common.Address, data []byte, gasPrice *big.Int, key *ecdsa.PrivateKey) (*StampVerdict, error) {
+	verdict := &StampVerdict{
+		Number:  hexutil.Uint64(header.Number.Uint64()),
+		Root:    header.Root,
+		Payload: StampPayloadHash(from, data, gasPrice),
+		Valid:   true,
+	}
+	intrGas := IntrinsicGas(data, false, true)
+	if err := ValidPrivacyTx(statedb, config.Rules(new(big.Int).Add(header.Number, common.Big1)), from.Bytes(), data, gasPrice, intrGas, new(big.Int), header.GasLimit); err != nil {
+		verdict.Valid, verdict.Reason = false, err.Error()
+	}
+	sig, err := crypto.Sign(verdict.sigHash(), key)
+	if err != nil {
+		return nil, err
+	}
+	verdict.Signature = sig
+	return verdict, nil
+}
+
+// sigHash returns the hash signed by the node key.
+func (v *StampVerdict) sigHash() []byte {
+	blob, _ := rlp.EncodeToBytes([]interface{}{uint64(v.Number), v.Root, v.Payload, v.Valid, v.Reason})
+	return crypto.Keccak256(stampVerdictPrefix, blob)
+}
+
+// Signer returns the public key of the node which signed the verdict, to be
+// checked against the key of the node asked.
+func (v *StampVerdict) Signer() (*ecdsa.PublicKey, error) {
+	return crypto.SigToPub(v.sigHash(), v.Signature)
+}
@@ -0,0 +1,50 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core/state"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/crypto"
+	"github.com/wanchain/go-wanchain/ethdb"
+	"github.com/wanchain/go-wanchain/params"
+)
+
+// Tests that stamp verdicts report the invalid stamps, are signed by the node
+// key and don't verify once tampered with.
+func TestStampVerdict(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	header := &types.Header{Number: big.NewInt(7), Root: common.Hash{0x01}, GasLimit: big.NewInt(4712388)}
+	key, _ := crypto.GenerateKey()
+
+	from, data, price := common.Address{0xaa}, []byte{0x01, 0x02}, big.NewInt(1)
+	verdict, err := VerifyStamp(params.TestChainConfig, statedb, header, from, data, price, key)
+	if err != nil {
+		t.Fatalf("failed to verify stamp: %v", err)
+	}
+	if verdict.Valid || verdict.Reason == "" {
+		t.Fatalf("invalid stamp accepted: %+v", verdict)
+	}
+	if verdict.Number != 7 || verdict.Root != header.Root || verdict.Payload != StampPayloadHash(from, data, price) {
+		t.Fatalf("verdict mismatch: %+v", verdict)
+	}
+	node := crypto.PubkeyToAddress(key.PublicKey)
+	signer, err := verdict.Signer()
+	if err != nil || crypto.PubkeyToAddress(*signer) != node {
+		t.Fatalf("verdict signer mismatch: %v", err)
+	}
+	// A verdict turned valid is no longer signed by the node key
+	verdict.Valid, verdict.Reason = true, ""
+	if signer, err := verdict.Signer(); err == nil && crypto.PubkeyToAddress(*signer) == node {
+		t.Fatalf("tampered verdict signed by the node key")
+	}
+	// A zero gas price is rejected as the pool does
+	if verdict, _ := VerifyStamp(params.TestChainConfig, statedb, header, from, data, new(big.Int), key); verdict.Valid {
+		t.Fatalf("stamp with a zero gas price accepted")
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package state
+
+import (
+	"math/big"
+
+	"github.com/wanchain/go-wanchain/common"
+)
+
+// accessKind is the part of an account a state access refers to.
+type accessKind byte
+
+const (
+	accessAccount accessKind = iota // Existence of the account
+	accessBalance
+	accessNonce
+	accessCode
+	accessSlot // Storage slot, holding a word or a byte array
+)
+
+type accessKey struct {
+	addr common.Address
+	kind accessKind
+	slot common.Hash
+}
+
+// AccessSet records the state accessed by the transaction applied to a
+// StateDB, so that transactions executed concurrently on the same parent state
+// can be checked for conflicts and merged.
+//
+// Written state is also recorded as read, as the writes of a reverted call are
+// undone to the value read. Balances that are only credited are recorded
+// apart: credits commute, so crediting the same account (e.g. the coinbase or
+// the privacy contracts) doesn't make transactions conflict.
+type AccessSet struct {
+	reads   map[accessKey]struct{}
+	writes  map[accessKey]bool          // Written state, mapped to whether slots hold byte arrays
+	credits map[common.Address]*big.Int // Balances before the credits, for accounts only credited
+	unsafe  bool                        // Set if accounts were destructed, reset or iterated
+}
+
+func newAccessSet() *AccessSet {
+	return &AccessSet{
+		reads:   make(map[accessKey]struct{}),
+		writes:  make(map[accessKey]bool),
+		credits: make(map[common.Address]*big.Int),
+	}
+}
+
+// Unsafe reports whether the transaction accessed the state in ways the set
+// can't track, e.g. by destructing an account. Such transactions must be
+// applied serially.
+func (a *AccessSet) Unsafe() bool {
+	return a.unsafe
+}
+
+// Conflicts reports whether the transaction read any of the written state.
+func (a *AccessSet) Conflicts(written WriteSet) bool {
+	for key := range a.reads {
+		if _, ok := written[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *AccessSet) read(addr common.Address, kind accessKind) {
+	if a != nil {
+		a.reads[accessKey{addr: addr, kind: kind}] = struct{}{}
+	}
+}
+
+func (a *AccessSet) readSlot(addr common.Address, slot common.Hash) {
+	if a != nil {
+		a.reads[accessKey{addr: addr, kind: accessSlot, slot: slot}] = struct{}{}
+	}
+}
+
+func (a *AccessSet) write(addr common.Address, kind accessKind) {
+	if a != nil {
+		key := accessKey{addr: addr, kind: kind}
+		a.reads[key] = struct{}{}
+		a.writes[key] = false
+		if kind == accessBalance {
+			delete(a.credits, addr)
+		}
+	}
+}
+
+func (a *AccessSet) writeSlot(addr common.Address, slot common.Hash, byteArray bool) {
+	if a != nil {
+		key := accessKey{addr: addr, kind: accessSlot, slot: slot}
+		a.reads[key] = struct{}{}
+		a.writes[key] = byteArray
+	}
+}
+
+// create records the creation of an account by a credit, which doesn't depend
+// on whether the account existed.
+func (a *AccessSet) create(addr common.Address) {
+	if a != nil {
+		a.writes[accessKey{addr: addr, kind: accessAccount}] = false
+	}
+}
+
+// credit records a credit to the balance of addr, given its balance before.
+func (a *AccessSet) credit(addr common.Address, balance *big.Int) {
+	if a == nil {
+		return
+	}
+	if _, ok := a.writes[accessKey{addr: addr, kind: accessBalance}]; ok {
+		return
+	}
+	if _, ok := a.credits[addr]; !ok {
+		a.credits[addr] = new(big.Int).Set(balance)
+	}
+}
+
+func (a *AccessSet) markUnsafe() {
+	if a != nil {
+		a.unsafe = true
+	}
+}
+
+// WriteSet is the state written by a sequence of transactions.
+type WriteSet map[accessKey]struct{}
+
+// Add adds the state written, or credited, by a transaction.
+func (w WriteSet) Add(a *AccessSet) {
+	for key := range a.writes {
+		w[key] = struct{}{}
+	}
+	for addr := range a.credits {
+		w[accessKey{addr: addr, kind: accessBalance}] = struct{}{}
+	}
+}
+
+// TrackAccess starts recording the state accessed through the StateDB.
+func (self *StateDB) TrackAccess() {
+	self.access = newAccessSet()
+}
+
+// StopTracking stops recording the state accesses and returns those recorded
+// since TrackAccess.
+func (self *StateDB) StopTracking() *AccessSet {
+	access := self.access
+	self.access = nil
+	return access
+}
+
+// Merge applies the state changes of a transaction applied to src, as recorded
+// by access, and its preimages. The state of src must descend from the same
+// parent state as the StateDB, and the transaction must not conflict with the
+// changes made to the StateDB since.
+func (self *StateDB) Merge(src *StateDB, access *AccessSet) {
+	// Create the accounts first, as creation resets the other fields
+	for key := range access.writes {
+		if key.kind == accessAccount && src.Exist(key.addr) && !self.Exist(key.addr) {
+			self.CreateAccount(key.addr)
+		}
+	}
+	for key, byteArray := range access.writes {
+		switch key.kind {
+		case accessBalance:
+			self.SetBalance(key.addr, src.GetBalance(key.addr))
+		case accessNonce:
+			self.SetNonce(key.addr, src.GetNonce(key.addr))
+		case accessCode:
+			self.SetCode(key.addr, src.GetCode(key.addr))
+		case accessSlot:
+			if byteArray {
+				self.SetStateByteArray(key.addr, key.slot, src.GetStateByteArray(key.addr, key.slot))
+			} else {
+				self.SetState(key.addr, key.slot, src.GetState(key.addr, key.slot))
+			}
+		}
+	}
+	for addr, balance := range access.credits {
+		self.AddBalance(addr, new(big.Int).Sub(src.GetBalance(addr), balance))
+	}
+	for hash, preimage := range src.preimages {
+		self.AddPreimage(hash, preimage)
+	}
+}
@@ -77,6 +77,9 @@ type StateDB struct {
 	validRevisions []revision
 	nextRevisionId int
 
+	// State accessed by the transaction being applied, nil unless tracking.
+	access *AccessSet
+
 	lock sync.Mutex
 }
 
@@ -108,6 +111,11 @@ func (self *StateDB) Error() error {
 	return self.dbErr
 }
 
+// Database returns the database the state is read from.
+func (self *StateDB) Database() Database {
+	return self.db
+}
+
 // Reset clears out all emphemeral state objects from the state db, but keeps
 // the underlying state trie to avoid reloading data for the next operations.
 func (self *StateDB) Reset(root common.Hash) error {
@@ -180,12 +188,16 @@ func (self *StateDB) Exist(addr common.Address) bool {
 // Empty returns whether the state object is either non-existent
 // or empty according to the EIP161 specification (balance = nonce = code = 0)
 func (self *StateDB) Empty(addr common.Address) bool {
+	self.access.read(addr, accessBalance)
+	self.access.read(addr, accessNonce)
+	self.access.read(addr, accessCode)
 	so := self.getStateObject(addr)
 	return so == nil || so.empty()
 }
 
 // Retrieve the balance from the given address or 0 if object not found
 func (self *StateDB) GetBalance(addr common.Address) *big.Int {
+	self.access.read(addr, accessBalance)
 	stateObject := self.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.Balance()
@@ -194,6 +206,7 @@ func (self *StateDB) GetBalance(addr common.Address) *big.Int {
 }
 
 func (self *StateDB) GetNonce(addr common.Address) uint64 {
+	self.access.read(addr, accessNonce)
 	stateObject := self.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.Nonce()
@@ -203,6 +216,7 @@ func (self *StateDB) GetNonce(addr common.Address) uint64 {
 }
 
 func (self *StateDB) GetCode(addr common.Address) []byte {
+	self.access.read(addr, accessCode)
 	stateObject := self.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.Code(self.db)
@@ -211,6 +225,7 @@ func (self *StateDB) GetCode(addr common.Address) []byte {
 }
 
 func (self *StateDB) GetCodeSize(addr common.Address) int {
+	self.access.read(addr, accessCode)
 	stateObject := self.getStateObject(addr)
 	if stateObject == nil {
 		return 0
@@ -226,6 +241,7 @@ func (self *StateDB) GetCodeSize(addr common.Address) int {
 }
 
 func (self *StateDB) GetCodeHash(addr common.Address) common.Hash {
+	self.access.read(addr, accessCode)
 	stateObject := self.getStateObject(addr)
 	if stateObject == nil {
 		return common.Hash{}
@@ -234,6 +250,7 @@ func (self *StateDB) GetCodeHash(addr common.Address) common.Hash {
 }
 
 func (self *StateDB) GetState(a common.Address, b common.Hash) common.Hash {
+	self.access.readSlot(a, b)
 	stateObject := self.getStateObject(a)
 	if stateObject != nil {
 		return stateObject.GetState(self.db, b)
@@ -242,6 +259,7 @@ func (self *StateDB) GetState(a common.Address, b common.Hash) common.Hash {
 }
 
 func (self *StateDB) GetStateByteArray(a common.Address, b common.Hash) []byte {
+	self.access.readSlot(a, b)
 	stateObject := self.getStateObject(a)
 	if stateObject != nil {
 		return stateObject.GetStateByteArray(self.db, b)
@@ -260,6 +278,36 @@ func (self *StateDB) StorageTrie(a common.Address) Trie {
 	return cpy.updateTrie(self.db)
 }
 
+// prover is a trie able to construct merkle proofs.
+type prover interface {
+	Prove(key []byte) []rlp.RawValue
+}
+
+// GetProof returns the merkle proof of the account at addr in the state trie,
+// or of its absence. The state must not hold uncommitted changes.
+func (self *StateDB) GetProof(addr common.Address) ([]rlp.RawValue, error) {
+	tr, ok := self.trie.(prover)
+	if !ok {
+		return nil, fmt.Errorf("state trie %T can't construct proofs", self.trie)
+	}
+	return tr.Prove(addr[:]), nil
+}
+
+// GetStorageProof returns the merkle proof of the storage slot key of the
+// account at addr in its storage trie, or of its absence. It returns nil for
+// non-existent accounts, whose absence is proven by GetProof.
+func (self *StateDB) GetStorageProof(addr common.Address, key common.Hash) ([]rlp.RawValue, error) {
+	st := self.StorageTrie(addr)
+	if st == nil {
+		return nil, nil
+	}
+	tr, ok := st.(prover)
+	if !ok {
+		return nil, fmt.Errorf("storage trie %T can't construct proofs", st)
+	}
+	return tr.Prove(key[:]), nil
+}
+
 func (self *StateDB) HasSuicided(addr common.Address) bool {
 	stateObject := self.getStateObject(addr)
 	if stateObject != nil {
@@ -274,16 +322,25 @@ func (self *StateDB) HasSuicided(addr common.Address) bool {
 
 // AddBalance adds amount to the account associated with addr
 func (self *StateDB) AddBalance(addr common.Address, amount *big.Int) {
-	stateObject := self.GetOrNewStateObject(addr)
-	if stateObject != nil {
-		stateObject.AddBalance(amount)
+	// Credits commute, only track the account as credited
+	access := self.access
+	self.access = nil
+	defer func() { self.access = access }()
+
+	stateObject := self.getStateObject(addr)
+	if stateObject == nil {
+		access.create(addr)
+		stateObject, _ = self.createObject(addr)
 	}
+	access.credit(addr, stateObject.Balance())
+	stateObject.AddBalance(amount)
 }
 
 // SubBalance subtracts amount from the account associated with addr
 func (self *StateDB) SubBalance(addr common.Address, amount *big.Int) {
 	stateObject := self.GetOrNewStateObject(addr)
 	if stateObject != nil {
+		self.access.write(addr, accessBalance)
 		stateObject.SubBalance(amount)
 	}
 }
@@ -291,6 +348,7 @@ func (self *StateDB) SubBalance(addr common.Address, amount *big.Int) {
 func (self *StateDB) SetBalance(addr common.Address, amount *big.Int) {
 	stateObject := self.GetOrNewStateObject(addr)
 	if stateObject != nil {
+		self.access.write(addr, accessBalance)
 		stateObject.SetBalance(amount)
 	}
 }
@@ -298,6 +356,7 @@ func (self *StateDB) SetBalance(addr common.Address, amount *big.Int) {
 func (self *StateDB) SetNonce(addr common.Address, nonce uint64) {
 	stateObject := self.GetOrNewStateObject(addr)
 	if stateObject != nil {
+		self.access.write(addr, accessNonce)
 		stateObject.SetNonce(nonce)
 	}
 }
@@ -305,6 +364,7 @@ func (self *StateDB) SetNonce(addr common.Address, nonce uint64) {
 func (self *StateDB) SetCode(addr common.Address, code []byte) {
 	stateObject := self.GetOrNewStateObject(addr)
 	if stateObject != nil {
+		self.access.write(addr, accessCode)
 		stateObject.SetCode(crypto.Keccak256Hash(code), code)
 	}
 }
@@ -312,6 +372,7 @@ func (self *StateDB) SetCode(addr common.Address, code []byte) {
 func (self *StateDB) SetState(addr common.Address, key common.Hash, value common.Hash) {
 	stateObject := self.GetOrNewStateObject(addr)
 	if stateObject != nil {
+		self.access.writeSlot(addr, key, false)
 		stateObject.SetState(self.db, key, value)
 	}
 }
@@ -319,6 +380,7 @@ func (self *StateDB) SetState(addr common.Address, key common.Hash, value common
 func (self *StateDB) SetStateByteArray(addr common.Address, key common.Hash, value []byte) {
 	stateObject := self.GetOrNewStateObject(addr)
 	if stateObject != nil {
+		self.access.writeSlot(addr, key, true)
 		stateObject.SetStateByteArray(self.db, key, value)
 	}
 }
@@ -329,6 +391,7 @@ func (self *StateDB) SetStateByteArray(addr common.Address, key common.Hash, val
 // The account's state object is still available until the state is committed,
 // getStateObject will return a non-nil account after Suicide.
 func (self *StateDB) Suicide(addr common.Address) bool {
+	self.access.markUnsafe()
 	stateObject := self.getStateObject(addr)
 	if stateObject == nil {
 		return false
@@ -367,6 +430,8 @@ func (self *StateDB) deleteStateObject(stateObject *stateObject) {
 
 // Retrieve a state object given my the address. Returns nil if not found.
 func (self *StateDB) getStateObject(addr common.Address) (stateObject *stateObject) {
+	self.access.read(addr, accessAccount)
+
 	// Prefer 'live' objects.
 	if obj := self.stateObjects[addr]; obj != nil {
 		if obj.deleted {
@@ -415,6 +480,12 @@ func (self *StateDB) MarkStateObjectDirty(addr common.Address) {
 // the given address, it is overwritten and returned as the second return value.
 func (self *StateDB) createObject(addr common.Address) (newobj, prev *stateObject) {
 	prev = self.getStateObject(addr)
+	if prev != nil {
+		self.access.markUnsafe() // The storage of the account is reset
+	}
+	for _, kind := range []accessKind{accessAccount, accessBalance, accessNonce, accessCode} {
+		self.access.write(addr, kind)
+	}
 	newobj = newObject(self, addr, Account{}, self.MarkStateObjectDirty)
 	newobj.setNonce(0) // sets the object to dirty
 	if prev == nil {
@@ -444,6 +515,7 @@ func (self *StateDB) CreateAccount(addr common.Address) {
 }
 
 func (db *StateDB) ForEachStorage(addr common.Address, cb func(key, value common.Hash) bool) {
+	db.access.markUnsafe()
 	so := db.getStateObject(addr)
 	if so == nil {
 		return
@@ -466,6 +538,7 @@ func (db *StateDB) ForEachStorage(addr common.Address, cb func(key, value common
 
 // cb is callback function. cb return true indicating like to continue, return false indicating stop
 func (db *StateDB) ForEachStorageByteArray(addr common.Address, cb func(key common.Hash, value []byte) bool) {
+	db.access.markUnsafe()
 	so := db.getStateObject(addr)
 	if so == nil {
 		return
@@ -490,6 +563,26 @@ func (db *StateDB) ForEachStorageByteArray(addr common.Address, cb func(key comm
 	}
 }
 
+// ForEachCommittedStorage iterates the committed storage of an account in trie
+// order, from the slot of the given hashed key on, calling cb with the hashed
+// key and the raw value of every slot until it returns false. Passing the
+// hashed key an iteration stopped at resumes it. Uncommitted changes are not
+// iterated.
+func (db *StateDB) ForEachCommittedStorage(addr common.Address, start []byte, cb func(hashKey, value []byte) bool) error {
+	db.access.markUnsafe()
+	so := db.getStateObject(addr)
+	if so == nil {
+		return nil
+	}
+	it := trie.NewIterator(so.getTrie(db.db).NodeIterator(start))
+	for it.Next() {
+		if !cb(it.Key, it.Value) {
+			return nil
+		}
+	}
+	return it.Err
+}
+
 // Copy creates a deep, independent copy of the state.
 // Snapshots of the copied state cannot be applied to the copy.
 func (self *StateDB) Copy() *StateDB {
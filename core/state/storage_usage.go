@@ -0,0 +1,97 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package state
+
+import (
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/crypto"
+	"github.com/wanchain/go-wanchain/rlp"
+	"github.com/wanchain/go-wanchain/trie"
+)
+
+// emptyStorageRoot is the root hash of an account without any storage.
+var emptyStorageRoot = crypto.Keccak256Hash(nil)
+
+// StorageUsage summarises the storage footprint attributed to a single account.
+type StorageUsage struct {
+	Slots      uint64 `json:"slots"`      // Number of non-empty storage slots
+	KeyBytes   uint64 `json:"keyBytes"`   // Bytes occupied by the slot keys
+	ValueBytes uint64 `json:"valueBytes"` // Bytes occupied by the decoded slot values
+}
+
+// Total returns the total number of bytes attributed to the account.
+func (u StorageUsage) Total() uint64 {
+	return u.KeyBytes + u.ValueBytes
+}
+
+// add accounts for a single storage slot.
+func (u *StorageUsage) add(value []byte, byteArray bool) {
+	u.Slots++
+	u.KeyBytes += common.HashLength
+
+	// Byte array slots (used by the privacy contracts) are stored raw in the
+	// trie, ordinary slots are RLP encoded words with leading zeroes trimmed.
+	if byteArray {
+		u.ValueBytes += uint64(len(value))
+		return
+	}
+	_, content, _, err := rlp.Split(value)
+	if err != nil {
+		u.ValueBytes += uint64(len(value))
+		return
+	}
+	u.ValueBytes += uint64(len(content))
+}
+
+// StorageUsage walks the committed storage trie of addr and returns the space
+// it occupies. If byteArray is set, the slot values are measured as raw byte
+// arrays instead of RLP encoded words.
+func (self *StateDB) StorageUsage(addr common.Address, byteArray bool) StorageUsage {
+	var usage StorageUsage
+
+	so := self.getStateObject(addr)
+	if so == nil {
+		return usage
+	}
+	it := trie.NewIterator(so.getTrie(self.db).NodeIterator(nil))
+	for it.Next() {
+		usage.add(it.Value, byteArray)
+	}
+	return usage
+}
+
+// ForEachStorageUsage iterates over every account with a non-empty storage
+// trie and reports its storage usage to cb. The isByteArray callback decides
+// for each account whether its slots hold raw byte arrays. Iteration stops as
+// soon as cb returns false.
+func (self *StateDB) ForEachStorageUsage(isByteArray func(addr common.Address) bool, cb func(addr common.Address, usage StorageUsage) bool) error {
+	it := trie.NewIterator(self.trie.NodeIterator(nil))
+	for it.Next() {
+		var data Account
+		if err := rlp.DecodeBytes(it.Value, &data); err != nil {
+			return err
+		}
+		if data.Root == emptyStorageRoot || data.Root == (common.Hash{}) {
+			continue
+		}
+		addr := common.BytesToAddress(self.trie.GetKey(it.Key))
+
+		var usage StorageUsage
+		storageTrie, err := self.db.OpenStorageTrie(crypto.Keccak256Hash(addr[:]), data.Root)
+		if err != nil {
+			return err
+		}
+		byteArray := isByteArray != nil && isByteArray(addr)
+		storageIt := trie.NewIterator(storageTrie.NodeIterator(nil))
+		for storageIt.Next() {
+			usage.add(storageIt.Value, byteArray)
+		}
+		if storageIt.Err != nil {
+			return storageIt.Err
+		}
+		if !cb(addr, usage) {
+			return nil
+		}
+	}
+	return it.Err
+}
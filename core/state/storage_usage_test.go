@@ -0,0 +1,50 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package state
+
+import (
+	"testing"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/ethdb"
+)
+
+func TestStorageUsage(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	state, _ := New(common.Hash{}, NewDatabase(db))
+
+	word := common.BytesToAddress([]byte{0x01})
+	array := common.BytesToAddress([]byte{0x02})
+
+	state.SetState(word, common.BytesToHash([]byte{1}), common.BytesToHash([]byte{0xff}))
+	state.SetState(word, common.BytesToHash([]byte{2}), common.BytesToHash([]byte{0x01, 0x02, 0x03}))
+	state.SetStateByteArray(array, common.BytesToHash([]byte{1}), make([]byte, 66))
+
+	root, err := state.CommitTo(db, false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	state, _ = New(root, NewDatabase(db))
+
+	if usage := state.StorageUsage(word, false); usage != (StorageUsage{Slots: 2, KeyBytes: 64, ValueBytes: 4}) {
+		t.Errorf("word storage usage mismatch: have %+v", usage)
+	}
+	if usage := state.StorageUsage(array, true); usage != (StorageUsage{Slots: 1, KeyBytes: 32, ValueBytes: 66}) {
+		t.Errorf("byte array storage usage mismatch: have %+v", usage)
+	}
+
+	seen := make(map[common.Address]StorageUsage)
+	err = state.ForEachStorageUsage(func(addr common.Address) bool { return addr == array }, func(addr common.Address, usage StorageUsage) bool {
+		seen[addr] = usage
+		return true
+	})
+	if err != nil {
+		t.Fatalf("failed to iterate storage usage: %v", err)
+	}
+	if len(seen) != 2 {
+		t.Fatalf("reported contract count mismatch: have %d, want 2", len(seen))
+	}
+	if seen[word].Total() != 68 || seen[array].Total() != 98 {
+		t.Errorf("total usage mismatch: have %d/%d, want 68/98", seen[word].Total(), seen[array].Total())
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package core
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core/state"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/crypto"
+	"github.com/wanchain/go-wanchain/ethdb"
+	"github.com/wanchain/go-wanchain/rlp"
+	"github.com/wanchain/go-wanchain/trie"
+)
+
+var emptyCodeHash = crypto.Keccak256(nil)
+
+// StateDiff returns the hashes of the trie nodes and contract codes of the
+// state root missing from the state parent: what a database holding parent
+// needs to also hold root. Walking root, it fails if any of them is missing
+// from db, so it also checks the completeness of a state received as a diff.
+func StateDiff(db ethdb.Database, parent, root common.Hash) ([]common.Hash, error) {
+	prevTrie, err := trie.New(parent, db)
+	if err != nil {
+		return nil, err
+	}
+	nextTrie, err := trie.New(root, db)
+	if err != nil {
+		return nil, err
+	}
+	var hashes []common.Hash
+
+	diff, _ := trie.NewDifferenceIterator(prevTrie.NodeIterator(nil), nextTrie.NodeIterator(nil))
+	for diff.Next(true) {
+		if hash := diff.Hash(); hash != (common.Hash{}) {
+			hashes = append(hashes, hash)
+		}
+		if !diff.Leaf() {
+			continue
+		}
+		// Walk the storage and code of the changed accounts too
+		var account, prev state.Account
+		if err := rlp.DecodeBytes(diff.LeafBlob(), &account); err != nil {
+			return nil, err
+		}
+		prev.Root, prev.CodeHash = types.EmptyRootHash, emptyCodeHash
+		if blob, err := prevTrie.TryGet(diff.LeafKey()); err != nil {
+			return nil, err
+		} else if len(blob) > 0 {
+			if err := rlp.DecodeBytes(blob, &prev); err != nil {
+				return nil, err
+			}
+		}
+		if account.Root != prev.Root {
+			storage, err := trieDiff(db, prev.Root, account.Root)
+			if err != nil {
+				return nil, err
+			}
+			for storage.Next(true) {
+				if hash := storage.Hash(); hash != (common.Hash{}) {
+					hashes = append(hashes, hash)
+				}
+			}
+			if storage.Error() != nil {
+				return nil, storage.Error()
+			}
+		}
+		if !bytes.Equal(account.CodeHash, prev.CodeHash) && !bytes.Equal(account.CodeHash, emptyCodeHash) {
+			hash := common.BytesToHash(account.CodeHash)
+			if ok, _ := db.Has(hash.Bytes()); !ok {
+				return nil, fmt.Errorf("missing code %x", hash)
+			}
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes, diff.Error()
+}
+
+// trieDiff iterates the nodes of the trie root missing from the trie parent.
+func trieDiff(db ethdb.Database, parent, root common.Hash) (trie.NodeIterator, error) {
+	prev, err := trie.New(parent, db)
+	if err != nil {
+		return nil, err
+	}
+	next, err := trie.New(root, db)
+	if err != nil {
+		return nil, err
+	}
+	diff, _ := trie.NewDifferenceIterator(prev.NodeIterator(nil), next.NodeIterator(nil))
+	return diff, nil
+}
@@ -38,6 +38,8 @@ type StateProcessor struct {
 	config *params.ChainConfig // Chain configuration options
 	bc     *BlockChain         // Canonical block chain
 	engine consensus.Engine    // Consensus engine used for block rewards
+
+	serialBlocks int32 // Number of blocks to execute serially before retrying in parallel
 }
 
 // NewStateProcessor initialises a new StateProcessor.
@@ -68,6 +70,16 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 	//if p.config.DAOForkSupport && p.config.DAOForkBlock != nil && p.config.DAOForkBlock.Cmp(block.Number()) == 0 {
 	//	misc.ApplyDAOHardFork(statedb)
 	//}
+	if p.parallel(block, cfg) {
+		receipts, allLogs, usedGas, ok, err := p.processParallel(block, statedb, cfg)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if ok {
+			p.engine.Finalize(p.bc, header, statedb, block.Transactions(), block.Uncles(), receipts)
+			return receipts, allLogs, usedGas, nil
+		}
+	}
 	// Iterate over and process the individual transactions
 	for i, tx := range block.Transactions() {
 		statedb.Prepare(tx.Hash(), block.Hash(), i)
@@ -89,7 +101,8 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 // for the transaction, gas used and an error if the transaction failed,
 // indicating the block was invalid.
 func ApplyTransaction(config *params.ChainConfig, bc *BlockChain, author *common.Address, gp *GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *big.Int, cfg vm.Config) (*types.Receipt, *big.Int, error) {
-	msg, err := tx.AsMessage(types.MakeSigner(config, header.Number))
+	signer := types.MakeSigner(config, header.Number)
+	msg, err := tx.AsMessage(signer)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -98,8 +111,17 @@ func ApplyTransaction(config *params.ChainConfig, bc *BlockChain, author *common
 	// Create a new environment which holds all relevant information
 	// about the transaction and calling mechanisms.
 	vmenv := vm.NewEVM(context, statedb, config, cfg)
-	// Apply the transaction to the current state (included in the env)
-	_, gas, failed, err := ApplyMessage(vmenv, msg, gp)
+	var verifyGas uint64
+	if config.IsAccountAbstraction(header.Number) {
+		if verifyGas, err = VerifyAccountTx(vmenv, signer, msg.From(), tx); err != nil {
+			return nil, nil, err
+		}
+	}
+	// Apply the transaction to the current state (included in the env),
+	// charging the account verification along the intrinsic gas
+	st := NewStateTransition(vmenv, msg, gp)
+	st.verifyGas = verifyGas
+	_, _, gas, failed, err := st.TransitionDb()
 	if err != nil {
 		return nil, nil, err
 	}
@@ -131,6 +153,10 @@ func ApplyTransaction(config *params.ChainConfig, bc *BlockChain, author *common
 	receipt := types.NewReceipt(root, failed, usedGas)
 	receipt.TxHash = tx.Hash()
 	receipt.GasUsed = new(big.Int).Set(gas)
+	if config.IsPrivacyReceipt(header.Number) {
+		stampUsed, keyImages := vmenv.PrivacyUsage()
+		receipt.Privacy = &types.ReceiptPrivacy{StampUsed: stampUsed, KeyImages: keyImages}
+	}
 	// if the transaction created a contract, store the creation address in the receipt.
 	if msg.To() == nil {
 		receipt.ContractAddress = crypto.CreateAddress(vmenv.Context.Origin, tx.Nonce())
@@ -142,3 +168,29 @@ func ApplyTransaction(config *params.ChainConfig, bc *BlockChain, author *common
 
 	return receipt, gas, err
 }
+
+// VerifyAccountTx consults the verification contract registered by the sender
+// of a transaction, if any, returning the gas it used. The ring signature of
+// a privacy transaction only authorises spending its stamp, not acting as the
+// sender, so accounts with a verifier can't send privacy transactions.
+//
+// The verification runs within the gas the transaction buys beyond its
+// intrinsic gas, capped at AccountVerifyGas, and is charged to the sender.
+func VerifyAccountTx(evm *vm.EVM, signer types.Signer, from common.Address, tx *types.Transaction) (uint64, error) {
+	verifier, ok := vm.GetAccountVerifier(evm.StateDB, from)
+	if !ok {
+		return 0, nil
+	}
+	if !types.IsNormalTransaction(tx.Txtype()) {
+		return 0, vm.ErrVerifiedPrivacyTx
+	}
+	intrGas := IntrinsicGas(tx.Data(), tx.To() == nil, true)
+	if tx.Gas().Cmp(intrGas) <= 0 {
+		return 0, ErrIntrinsicGas
+	}
+	gas := params.AccountVerifyGas
+	if left := new(big.Int).Sub(tx.Gas(), intrGas); left.Cmp(new(big.Int).SetUint64(gas)) < 0 {
+		gas = left.Uint64()
+	}
+	return vm.VerifyAccountSignature(evm, verifier, from, signer.Hash(tx), tx.Data(), gas)
+}
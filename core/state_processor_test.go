@@ -0,0 +1,132 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package core
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/accounts/abi"
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/core/vm"
+	"github.com/wanchain/go-wanchain/crypto"
+)
+
+// Tests that transactions of an account with a registered verification
+// contract are only accepted once the fork is active and the verifier agrees.
+func TestAccountVerifierTx(t *testing.T) {
+	var (
+		key, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender   = crypto.PubkeyToAddress(key.PublicKey)
+		accept   = common.HexToAddress("0x2000000000000000000000000000000000000001")
+		reject   = common.HexToAddress("0x2000000000000000000000000000000000000002")
+		registry = common.BytesToAddress([]byte{150})
+		gspec    = DefaultPPOWTestingGenesisBlock()
+		config   = *gspec.Config
+	)
+	config.AccountAbstractionBlock = big.NewInt(0)
+	gspec.Config = &config
+	gspec.Alloc = GenesisAlloc{
+		sender: {Balance: big.NewInt(1000000000000000000)},
+		// PUSH1 <answer> PUSH1 0 MSTORE PUSH1 32 PUSH1 0 RETURN
+		accept: {Balance: new(big.Int), Code: common.Hex2Bytes("600160005260206000f3")},
+		reject: {Balance: new(big.Int), Code: common.Hex2Bytes("600060005260206000f3")},
+	}
+	chain, err := NewEphemeralChain(gspec)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	registryAbi, _ := abi.JSON(strings.NewReader(`[{"type":"function","inputs":[{"name":"Verifier","type":"address"}],"name":"setVerifier","outputs":[]}]`))
+	signer := types.NewEIP155Signer(config.ChainId)
+	nonce := uint64(0)
+	var receipts types.Receipts
+	send := func(to common.Address, data []byte) (err error) {
+		tx, _ := types.SignTx(types.NewTransaction(nonce, to, new(big.Int), big.NewInt(300000), big.NewInt(1), data), signer, key)
+		if _, receipts, err = chain.ApplyTransactions(common.Address{}, types.Transactions{tx}); err == nil {
+			nonce++
+		}
+		return err
+	}
+	register := func(verifier common.Address) error {
+		data, _ := registryAbi.Pack("setVerifier", verifier)
+		return send(registry, data)
+	}
+
+	if err := register(accept); err != nil {
+		t.Fatalf("failed to register accepting verifier: %v", err)
+	}
+	statedb, _ := chain.State()
+	if verifier, ok := vm.GetAccountVerifier(statedb, sender); !ok || verifier != accept {
+		t.Fatalf("registered verifier mismatch: have %x", verifier)
+	}
+	if err := send(common.Address{1}, nil); err != nil {
+		t.Fatalf("accepted transaction failed: %v", err)
+	}
+	// The verification is charged along the intrinsic gas
+	if used := receipts[0].GasUsed.Uint64(); used <= 21000 {
+		t.Errorf("verification not charged: have %d gas used", used)
+	}
+	// Privacy transactions can't bypass the verifier
+	tx, _ := types.SignTx(types.NewOTATransaction(nonce, common.Address{1}, new(big.Int), big.NewInt(300000), big.NewInt(1), nil), signer, key)
+	if _, _, err := chain.ApplyTransactions(common.Address{}, types.Transactions{tx}); err == nil || !strings.Contains(err.Error(), vm.ErrVerifiedPrivacyTx.Error()) {
+		t.Fatalf("privacy transaction error mismatch: have %v, want %v", err, vm.ErrVerifiedPrivacyTx)
+	}
+	if err := register(reject); err != nil {
+		t.Fatalf("failed to register rejecting verifier: %v", err)
+	}
+	if err := send(common.Address{1}, nil); err == nil || !strings.Contains(err.Error(), vm.ErrAccountVerification.Error()) {
+		t.Fatalf("rejected transaction error mismatch: have %v, want %v", err, vm.ErrAccountVerification)
+	}
+	// Removing the verifier isn't possible any more, the account is locked
+	data, _ := registryAbi.Pack("setVerifier", common.Address{})
+	if err := send(registry, data); err == nil {
+		t.Fatalf("verifier removal accepted despite rejecting verifier")
+	}
+}
+
+// Tests that before the fork the verifier registry is a plain account, which
+// neither registers verifiers nor burns the gas of its callers.
+func TestAccountVerifierInactive(t *testing.T) {
+	var (
+		key, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender   = crypto.PubkeyToAddress(key.PublicKey)
+		registry = common.BytesToAddress([]byte{150})
+		gspec    = DefaultPPOWTestingGenesisBlock()
+		config   = *gspec.Config
+	)
+	config.AccountAbstractionBlock = nil
+	gspec.Config = &config
+	gspec.Alloc = GenesisAlloc{sender: {Balance: big.NewInt(1000000000000000000)}}
+
+	chain, err := NewEphemeralChain(gspec)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	registryAbi, _ := abi.JSON(strings.NewReader(`[{"type":"function","inputs":[{"name":"Verifier","type":"address"}],"name":"setVerifier","outputs":[]}]`))
+	data, _ := registryAbi.Pack("setVerifier", common.Address{1})
+	signer := types.NewEIP155Signer(config.ChainId)
+	tx, _ := types.SignTx(types.NewTransaction(0, registry, big.NewInt(1), big.NewInt(300000), big.NewInt(1), data), signer, key)
+	_, receipts, err := chain.ApplyTransactions(common.Address{}, types.Transactions{tx})
+	if err != nil {
+		t.Fatalf("failed to apply transaction: %v", err)
+	}
+	if receipts[0].Status != types.ReceiptStatusSuccessful {
+		t.Errorf("transfer to the registry failed")
+	}
+	if used, intrinsic := receipts[0].GasUsed, IntrinsicGas(data, false, true); used.Cmp(intrinsic) != 0 {
+		t.Errorf("gas used mismatch: have %v, want %v", used, intrinsic)
+	}
+	statedb, _ := chain.State()
+	if _, ok := vm.GetAccountVerifier(statedb, sender); ok {
+		t.Errorf("verifier registered before the fork")
+	}
+	if balance := statedb.GetBalance(registry); balance.Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("registry balance mismatch: have %v, want 1", balance)
+	}
+}
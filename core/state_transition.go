@@ -66,6 +66,7 @@ type StateTransition struct {
 	data       []byte
 	state      vm.StateDB
 	evm        *vm.EVM
+	verifyGas  uint64 // Gas used by the verification contract of the sender
 }
 
 // Message represents a message sent to a contract.
@@ -246,12 +247,21 @@ func (st *StateTransition) TransitionDb() (ret []byte, requiredGas, usedGas *big
 
 	var stampTotalGas uint64
 	if !types.IsNormalTransaction(st.msg.TxType()) {
-		pureCallData, totalUseableGas, evmUseableGas, err := PreProcessPrivacyTx(st.evm.StateDB,
+		info, err := preProcessPrivacyTx(st.evm.StateDB, st.evm.ChainConfig().Rules(st.evm.BlockNumber),
 			sender.Address().Bytes(),
 			st.data, st.gasPrice, st.value)
 		if err != nil {
 			return nil, nil, nil, false, err
 		}
+		var (
+			pureCallData    []byte
+			totalUseableGas uint64
+			evmUseableGas   uint64
+		)
+		if info != nil {
+			pureCallData, totalUseableGas, evmUseableGas = info.CallData, info.StampTotalGas, info.GasLeftSubRingSign
+			st.evm.RecordStamp(crypto.FromECDSAPub(info.KeyImage), info.StampBalance)
+		}
 
 		stampTotalGas = totalUseableGas
 		st.gas = evmUseableGas
@@ -267,6 +277,9 @@ func (st *StateTransition) TransitionDb() (ret []byte, requiredGas, usedGas *big
 	if err = st.useGas(intrinsicGas.Uint64()); err != nil {
 		return nil, nil, nil, false, err
 	}
+	if err = st.useGas(st.verifyGas); err != nil {
+		return nil, nil, nil, false, err
+	}
 
 	log.Trace("subed intrinsic gas", "gas pool left", st.gp.String())
 
@@ -361,7 +374,7 @@ type PrivacyTxInfo struct {
 	GasLeftSubRingSign uint64
 }
 
-func FetchPrivacyTxInfo(stateDB vm.StateDB, hashInput []byte, in []byte, gasPrice *big.Int) (info *PrivacyTxInfo, err error) {
+func FetchPrivacyTxInfo(stateDB vm.StateDB, rules params.Rules, hashInput []byte, in []byte, gasPrice *big.Int) (info *PrivacyTxInfo, err error) {
 	if len(in) < 4 {
 		return nil, vm.ErrInvalidRingSigned
 	}
@@ -376,7 +389,11 @@ func FetchPrivacyTxInfo(stateDB vm.StateDB, hashInput []byte, in []byte, gasPric
 		return
 	}
 
-	ringSignInfo, err := vm.FetchRingSignInfo(stateDB, hashInput, TxDataWithRing.RingSignedData)
+	if rules.IsRingLimit && uint64(len(TxDataWithRing.RingSignedData)) > params.MaxRingSignedDataSize {
+		return nil, vm.ErrRingSignedDataTooLarge
+	}
+
+	ringSignInfo, err := vm.FetchRingSignInfo(stateDB, rules, hashInput, TxDataWithRing.RingSignedData)
 	if err != nil {
 		return
 	}
@@ -391,7 +408,7 @@ func FetchPrivacyTxInfo(stateDB vm.StateDB, hashInput []byte, in []byte, gasPric
 	ringSigDiffRequiredGas := params.RequiredGasPerMixPub * (uint64(mixLen))
 
 	// ringsign compute gas + ota image key store setting gas
-	preSubGas := ringSigDiffRequiredGas + params.SstoreSetGas
+	preSubGas := vm.PrivacyGas(stateDB, rules, ringSigDiffRequiredGas+params.SstoreSetGas)
 	if StampTotalGas < preSubGas {
 		return nil, vm.ErrOutOfGas
 	}
@@ -411,7 +428,47 @@ func FetchPrivacyTxInfo(stateDB vm.StateDB, hashInput []byte, in []byte, gasPric
 	return
 }
 
-func ValidPrivacyTx(stateDB vm.StateDB, hashInput []byte, in []byte, gasPrice *big.Int,
+// RingSignedDataOf returns the ring signed data carried by tx, either as the
+// stamp of a privacy transaction or as the payload of a wancoin refund.
+// ok is false if tx doesn't carry a ring signature.
+func RingSignedDataOf(tx *types.Transaction) (data string, ok bool) {
+	if types.IsNormalTransaction(tx.Txtype()) {
+		return vm.RefundRingSignedData(tx.To(), tx.Data())
+	}
+
+	in := tx.Data()
+	if len(in) < 4 {
+		return "", false
+	}
+
+	var TxDataWithRing struct {
+		RingSignedData string
+		CxtCallParams  []byte
+	}
+	if err := utilAbi.Unpack(&TxDataWithRing, "combine", in[4:]); err != nil {
+		return "", false
+	}
+
+	return TxDataWithRing.RingSignedData, true
+}
+
+// RingSizeOf returns the number of ring members referenced by tx, or zero if
+// it doesn't carry a well formed ring signature.
+func RingSizeOf(tx *types.Transaction) int {
+	data, ok := RingSignedDataOf(tx)
+	if !ok {
+		return 0
+	}
+
+	err, publicKeys, _, _, _ := vm.DecodeRingSignOut(data)
+	if err != nil {
+		return 0
+	}
+
+	return len(publicKeys)
+}
+
+func ValidPrivacyTx(stateDB vm.StateDB, rules params.Rules, hashInput []byte, in []byte, gasPrice *big.Int,
 	intrGas *big.Int, txValue *big.Int, gasLimit *big.Int) error {
 	if intrGas == nil || intrGas.BitLen() > 64 {
 		return vm.ErrOutOfGas
@@ -425,7 +482,7 @@ func ValidPrivacyTx(stateDB vm.StateDB, hashInput []byte, in []byte, gasPrice *b
 		return vm.ErrInvalidGasPrice
 	}
 
-	info, err := FetchPrivacyTxInfo(stateDB, hashInput, in, gasPrice)
+	info, err := FetchPrivacyTxInfo(stateDB, rules, hashInput, in, gasPrice)
 	if err != nil {
 		return err
 	}
@@ -449,23 +506,33 @@ func ValidPrivacyTx(stateDB vm.StateDB, hashInput []byte, in []byte, gasPrice *b
 	return nil
 }
 
-func PreProcessPrivacyTx(stateDB vm.StateDB, hashInput []byte, in []byte, gasPrice *big.Int, txValue *big.Int) (callData []byte, totalUseableGas uint64, evmUseableGas uint64, err error) {
+func PreProcessPrivacyTx(stateDB vm.StateDB, rules params.Rules, hashInput []byte, in []byte, gasPrice *big.Int, txValue *big.Int) (callData []byte, totalUseableGas uint64, evmUseableGas uint64, err error) {
+	info, err := preProcessPrivacyTx(stateDB, rules, hashInput, in, gasPrice, txValue)
+	if err != nil || info == nil {
+		return nil, 0, 0, err
+	}
+	return info.CallData, info.StampTotalGas, info.GasLeftSubRingSign, nil
+}
+
+// preProcessPrivacyTx spends the stamp of a privacy transaction, returning
+// its info. A nil info with a nil error means the stamp is already spent.
+func preProcessPrivacyTx(stateDB vm.StateDB, rules params.Rules, hashInput []byte, in []byte, gasPrice *big.Int, txValue *big.Int) (*PrivacyTxInfo, error) {
 	if txValue.Sign() != 0 {
-		return nil, 0, 0, vm.ErrInvalidPrivacyValue
+		return nil, vm.ErrInvalidPrivacyValue
 	}
 
-	info, err := FetchPrivacyTxInfo(stateDB, hashInput, in, gasPrice)
+	info, err := FetchPrivacyTxInfo(stateDB, rules, hashInput, in, gasPrice)
 	if err != nil {
-		return nil, 0, 0, err
+		return nil, err
 	}
 
 	kix := crypto.FromECDSAPub(info.KeyImage)
 	exist, _, err := vm.CheckOTAImageExist(stateDB, kix)
 	if err != nil || exist {
-		return nil, 0, 0, err
+		return nil, err
 	}
 
 	vm.AddOTAImage(stateDB, kix, info.StampBalance.Bytes())
 
-	return info.CallData, info.StampTotalGas, info.GasLeftSubRingSign, nil
+	return info, nil
 }
@@ -0,0 +1,61 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package core
+
+import (
+	"sort"
+	"time"
+)
+
+// inclusionSamples is the number of recent inclusion delays kept per kind of
+// transaction.
+const inclusionSamples = 1024
+
+// delayWindow is a ring buffer of the most recent inclusion delays.
+type delayWindow struct {
+	samples []time.Duration
+	next    int
+}
+
+func (w *delayWindow) add(delay time.Duration) {
+	if len(w.samples) < inclusionSamples {
+		w.samples = append(w.samples, delay)
+		return
+	}
+	w.samples[w.next] = delay
+	w.next = (w.next + 1) % inclusionSamples
+}
+
+// sorted returns a sorted copy of the samples.
+func (w *delayWindow) sorted() []time.Duration {
+	samples := make([]time.Duration, len(w.samples))
+	copy(samples, w.samples)
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	return samples
+}
+
+// inclusionDelays tracks the time the transactions spent in the pool until
+// included in the chain, apart for the privacy transactions which wait for
+// their ring members and stamps.
+type inclusionDelays struct {
+	normal  delayWindow
+	privacy delayWindow
+}
+
+func (d *inclusionDelays) add(privacy bool, delay time.Duration) {
+	if privacy {
+		d.privacy.add(delay)
+	} else {
+		d.normal.add(delay)
+	}
+}
+
+// DelayPercentile returns the p-th percentile (0-100) of the sorted delays, or
+// zero if there is none.
+func DelayPercentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted)-1) * p / 100)
+	return sorted[i]
+}
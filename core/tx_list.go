@@ -27,6 +27,7 @@ import (
 	"github.com/wanchain/go-wanchain/core/types"
 	"github.com/wanchain/go-wanchain/core/vm"
 	"github.com/wanchain/go-wanchain/log"
+	"github.com/wanchain/go-wanchain/params"
 )
 
 // nonceHeap is a heap.Interface implementation over 64bit unsigned integers for
@@ -316,7 +317,7 @@ func (l *txList) Filter(costLimit, gasLimit *big.Int) (types.Transactions, types
 }
 
 // InvalidPrivacyTx remove invalidate privacy transactions
-func (l *txList) InvalidPrivacyTx(stateDB vm.StateDB, signer types.Signer, gasLimit *big.Int) types.Transactions {
+func (l *txList) InvalidPrivacyTx(stateDB vm.StateDB, rules params.Rules, signer types.Signer, gasLimit *big.Int) types.Transactions {
 	removed := l.txs.Filter(func(tx *types.Transaction) bool {
 		if types.IsNormalTransaction(tx.Txtype()) {
 			return false
@@ -328,7 +329,7 @@ func (l *txList) InvalidPrivacyTx(stateDB vm.StateDB, signer types.Signer, gasLi
 		}
 
 		intrGas := IntrinsicGas(tx.Data(), tx.To() == nil, true)
-		err = ValidPrivacyTx(stateDB, from.Bytes(), tx.Data(), tx.GasPrice(), intrGas, tx.Value(), gasLimit)
+		err = ValidPrivacyTx(stateDB, rules, from.Bytes(), tx.Data(), tx.GasPrice(), intrGas, tx.Value(), gasLimit)
 
 		return err != nil
 	})
@@ -0,0 +1,163 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/log"
+)
+
+// ErrBlacklisted is returned if a transaction is sent from or to an address
+// blacklisted by the local node policy.
+var ErrBlacklisted = errors.New("address blacklisted by node policy")
+
+// TxPolicy is a node local policy filtering the transactions accepted into the
+// pool. It isn't part of consensus: blocks including filtered transactions are
+// still valid.
+type TxPolicy interface {
+	// Check returns an error if the transaction sent by from isn't allowed.
+	Check(from common.Address, tx *types.Transaction) error
+}
+
+// AddressBlacklist is a transaction policy rejecting the transactions from or
+// to a list of addresses, loaded from a file holding one hex address per line.
+// Empty lines and lines starting with # are ignored. The file is reloaded when
+// it changes, and every rejection is appended to an optional audit log.
+type AddressBlacklist struct {
+	path     string
+	auditLog string
+
+	lock     sync.RWMutex
+	listed   map[common.Address]bool
+	modified time.Time
+}
+
+// auditEntry is a line of the blacklist audit log.
+type auditEntry struct {
+	Time    time.Time       `json:"time"`
+	Hash    common.Hash     `json:"hash"`
+	From    common.Address  `json:"from"`
+	To      *common.Address `json:"to"`
+	Matched common.Address  `json:"matched"`
+}
+
+// NewAddressBlacklist loads the blacklist at path, logging the rejections to
+// the auditLog file if not empty.
+func NewAddressBlacklist(path, auditLog string) (*AddressBlacklist, error) {
+	list := &AddressBlacklist{path: path, auditLog: auditLog}
+	if err := list.load(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Check implements TxPolicy, rejecting the transactions from or to a listed
+// address.
+func (list *AddressBlacklist) Check(from common.Address, tx *types.Transaction) error {
+	list.lock.RLock()
+	matched, listed := from, list.listed[from]
+	if !listed && tx.To() != nil {
+		matched, listed = *tx.To(), list.listed[*tx.To()]
+	}
+	list.lock.RUnlock()
+
+	if !listed {
+		return nil
+	}
+	log.Warn("Rejected blacklisted transaction", "hash", tx.Hash(), "from", from, "to", tx.To(), "matched", matched)
+	if err := list.audit(&auditEntry{time.Now().UTC(), tx.Hash(), from, tx.To(), matched}); err != nil {
+		log.Error("Failed to write blacklist audit log", "err", err)
+	}
+	return ErrBlacklisted
+}
+
+// Contains reports whether addr is blacklisted.
+func (list *AddressBlacklist) Contains(addr common.Address) bool {
+	list.lock.RLock()
+	defer list.lock.RUnlock()
+
+	return list.listed[addr]
+}
+
+// Reload reloads the blacklist file if it was modified since the last load.
+func (list *AddressBlacklist) Reload() (bool, error) {
+	info, err := os.Stat(list.path)
+	if err != nil {
+		return false, err
+	}
+	list.lock.RLock()
+	unchanged := info.ModTime().Equal(list.modified)
+	list.lock.RUnlock()
+
+	if unchanged {
+		return false, nil
+	}
+	return true, list.load()
+}
+
+// load parses the blacklist file, replacing the current list.
+func (list *AddressBlacklist) load() error {
+	file, err := os.Open(list.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	listed := make(map[common.Address]bool)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		if !common.IsHexAddress(entry) {
+			return fmt.Errorf("invalid address on line %d of %s: %q", line, list.path, entry)
+		}
+		listed[common.HexToAddress(entry)] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	list.lock.Lock()
+	list.listed, list.modified = listed, info.ModTime()
+	list.lock.Unlock()
+
+	log.Info("Loaded transaction blacklist", "path", list.path, "addresses", len(listed))
+	return nil
+}
+
+// audit appends an entry to the audit log.
+func (list *AddressBlacklist) audit(entry *auditEntry) error {
+	if list.auditLog == "" {
+		return nil
+	}
+	blob, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	list.lock.Lock()
+	defer list.lock.Unlock()
+
+	file, err := os.OpenFile(list.auditLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(blob, '\n'))
+	return err
+}
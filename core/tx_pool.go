@@ -83,6 +83,10 @@ var (
 
 	// ErrInvalidTxType is returned if input transaction's type is unknown.
 	ErrInvalidTxType = errors.New("invalid transaction type")
+
+	// ErrUnknownTxType is returned if a transaction of a type unknown to the node
+	// is submitted while relaying those is disabled, or submitted locally.
+	ErrUnknownTxType = errors.New("unknown transaction type, not relayed")
 )
 
 var (
@@ -106,6 +110,8 @@ var (
 	// General tx metrics
 	invalidTxCounter     = metrics.NewCounter("txpool/invalid")
 	underpricedTxCounter = metrics.NewCounter("txpool/underpriced")
+	expiredTxCounter     = metrics.NewCounter("txpool/expired")
+	unknownTxCounter     = metrics.NewCounter("txpool/unknown") // Retained for relay despite an unknown type
 )
 
 // blockChain provides the state of blockchain and current gas limit to do
@@ -133,6 +139,15 @@ type TxPoolConfig struct {
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
 
 	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
+
+	TTL        time.Duration // Maximum amount of time any transaction stays in the pool (0 = unlimited)
+	PrivacyTTL time.Duration // Maximum amount of time privacy transactions stay in the pool (0 = TTL)
+
+	Blacklist      string `toml:",omitempty"` // File listing the addresses whose transactions are rejected (empty = disabled)
+	BlacklistAudit string `toml:",omitempty"` // File logging the rejected transactions (empty = disabled)
+
+	RelayUnknown bool   // Whether to retain the remote transactions of unknown types for relay
+	UnknownSlots uint64 // Maximum number of unknown type transactions retained for relay
 }
 
 // DefaultTxPoolConfig contains the default configurations for the transaction
@@ -150,6 +165,8 @@ var DefaultTxPoolConfig = TxPoolConfig{
 	GlobalQueue:  1024,
 
 	Lifetime: 3 * time.Hour,
+
+	UnknownSlots: 1024,
 }
 
 // sanitize checks the provided user configurations and changes anything that's
@@ -168,6 +185,10 @@ func (config *TxPoolConfig) sanitize() TxPoolConfig {
 		log.Warn("Sanitizing invalid txpool price bump", "provided", conf.PriceBump, "updated", DefaultTxPoolConfig.PriceBump)
 		conf.PriceBump = DefaultTxPoolConfig.PriceBump
 	}
+	if conf.RelayUnknown && conf.UnknownSlots < 1 {
+		log.Warn("Sanitizing invalid txpool unknown slots", "provided", conf.UnknownSlots, "updated", DefaultTxPoolConfig.UnknownSlots)
+		conf.UnknownSlots = DefaultTxPoolConfig.UnknownSlots
+	}
 	return conf
 }
 
@@ -196,12 +217,16 @@ type TxPool struct {
 
 	locals  *accountSet // Set of local transaction to exepmt from evicion rules
 	journal *txJournal  // Journal of local transaction to back up to disk
+	policy  TxPolicy    // Node local policy filtering the transactions, nil if none
 
 	pending map[common.Address]*txList         // All currently processable transactions
 	queue   map[common.Address]*txList         // Queued but non-processable transactions
 	beats   map[common.Address]time.Time       // Last heartbeat from each known account
 	all     map[common.Hash]*types.Transaction // All transactions to allow lookups
+	added   map[common.Hash]time.Time          // Arrival time of the transactions, for expiry
 	priced  *txPricedList                      // All transactions sorted by price
+	delays  inclusionDelays                    // Recent delays from arrival to inclusion
+	unknown *unknownTxSet                      // Transactions of unknown types retained for relay
 
 	wg sync.WaitGroup // for shutdown sync
 
@@ -224,13 +249,24 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		queue:       make(map[common.Address]*txList),
 		beats:       make(map[common.Address]time.Time),
 		all:         make(map[common.Hash]*types.Transaction),
+		added:       make(map[common.Hash]time.Time),
 		chainHeadCh: make(chan ChainHeadEvent, chainHeadChanSize),
 		gasPrice:    new(big.Int).SetUint64(config.PriceLimit),
 	}
 	pool.locals = newAccountSet(pool.signer)
 	pool.priced = newTxPricedList(&pool.all)
+	pool.unknown = newUnknownTxSet(int(config.UnknownSlots))
 	pool.reset(nil, chain.CurrentBlock().Header())
 
+	// Refuse to run without the configured blacklist rather than silently ignore it
+	if config.Blacklist != "" {
+		list, err := NewAddressBlacklist(config.Blacklist, config.BlacklistAudit)
+		if err != nil {
+			log.Crit("Failed to load transaction blacklist", "err", err)
+		}
+		pool.policy = list
+	}
+
 	// If local transactions and journaling is enabled, load from disk
 	if !config.NoLocals && config.Journal != "" {
 		pool.journal = newTxJournal(config.Journal)
@@ -321,6 +357,17 @@ func (pool *TxPool) loop() {
 					}
 				}
 			}
+			pool.expire()
+			if dropped := pool.unknown.expire(pool.config.Lifetime); dropped > 0 {
+				log.Debug("Dropped expired unknown type transactions", "count", dropped)
+			}
+			if list, ok := pool.policy.(*AddressBlacklist); ok {
+				if reloaded, err := list.Reload(); err != nil {
+					log.Warn("Failed to reload transaction blacklist", "err", err)
+				} else if reloaded {
+					pool.enforcePolicy()
+				}
+			}
 			pool.mu.Unlock()
 
 		// Handle local transaction journal rotation
@@ -336,6 +383,70 @@ func (pool *TxPool) loop() {
 	}
 }
 
+// expire drops the transactions which outlived their time to live, local ones
+// included: time sensitive transactions, like privacy ones whose ring members
+// get stale, are better dropped than executed late. The journal is rewritten
+// if local transactions expired, for them not to be loaded back on restart.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) expire() {
+	locals := false
+	for hash, added := range pool.added {
+		tx := pool.all[hash]
+		if tx == nil {
+			// Replaced or dropped since, forget it
+			delete(pool.added, hash)
+			continue
+		}
+		if ttl := pool.ttl(tx); ttl > 0 && time.Since(added) > ttl {
+			log.Debug("Dropping expired transaction", "hash", hash, "type", tx.Txtype(), "age", common.PrettyDuration(time.Since(added)))
+			expiredTxCounter.Inc(1)
+			locals = locals || pool.locals.containsTx(tx)
+			pool.removeTx(hash)
+		}
+	}
+	if locals && pool.journal != nil {
+		if err := pool.journal.rotate(pool.local()); err != nil {
+			log.Warn("Failed to rotate local tx journal", "err", err)
+		}
+	}
+}
+
+// SetPolicy sets the node local policy filtering the transactions accepted into
+// the pool, dropping the pooled transactions it rejects. A nil policy accepts
+// all transactions.
+func (pool *TxPool) SetPolicy(policy TxPolicy) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.policy = policy
+	pool.enforcePolicy()
+}
+
+// enforcePolicy drops the pooled transactions rejected by the policy.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) enforcePolicy() {
+	if pool.policy == nil {
+		return
+	}
+	for hash, tx := range pool.all {
+		from, _ := types.Sender(pool.signer, tx) // already validated
+		if err := pool.policy.Check(from, tx); err != nil {
+			pool.removeTx(hash)
+		}
+	}
+}
+
+// ttl returns the maximum amount of time tx can stay in the pool, zero if it
+// never expires.
+func (pool *TxPool) ttl(tx *types.Transaction) time.Duration {
+	if tx.Txtype() == types.PRIVACY_TX && pool.config.PrivacyTTL > 0 {
+		return pool.config.PrivacyTTL
+	}
+	return pool.config.TTL
+}
+
 // lockedReset is a wrapper around reset to allow calling it in a thread safe
 // manner. This method is only ever used in the tester!
 func (pool *TxPool) lockedReset(oldHead, newHead *types.Header) {
@@ -501,6 +612,16 @@ func (pool *TxPool) stats() (int, int) {
 	return pending, queued
 }
 
+// InclusionDelays retrieves the recent delays from the arrival of the pending
+// transactions to their inclusion in the chain, sorted, apart for the privacy
+// transactions.
+func (pool *TxPool) InclusionDelays() (normal []time.Duration, privacy []time.Duration) {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	return pool.delays.normal.sorted(), pool.delays.privacy.sorted()
+}
+
 // Content retrieves the data content of the transaction pool, returning all the
 // pending as well as queued transactions, grouped by account and sorted by nonce.
 func (pool *TxPool) Content() (map[common.Address]types.Transactions, map[common.Address]types.Transactions) {
@@ -574,6 +695,12 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if err != nil {
 		return ErrInvalidSender
 	}
+	// Apply the node local policy, if any
+	if pool.policy != nil {
+		if err := pool.policy.Check(from, tx); err != nil {
+			return err
+		}
+	}
 	// Drop non-local transactions under our own minimal accepted gas price
 	local = local || pool.locals.contains(from) // account may be local even if the transaction arrived from the network
 	if !local && pool.gasPrice.Cmp(tx.GasPrice()) > 0 {
@@ -596,16 +723,22 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 		}
 
 	} else {
-		err := ValidPrivacyTx(pool.currentState, from.Bytes(), tx.Data(), tx.GasPrice(), intrGas, tx.Value(), pool.currentMaxGas)
+		err := ValidPrivacyTx(pool.currentState, pool.rules(), from.Bytes(), tx.Data(), tx.GasPrice(), intrGas, tx.Value(), pool.currentMaxGas)
 		if err != nil {
 			return err
 		}
 	}
 
+	// Consult the verification contract of the sender, if it registered one
+	if err := pool.verifyAccount(from, tx); err != nil {
+		return err
+	}
+
 	// Check precompile contracts transactions validation
 	if tx.To() != nil {
-		if p := vm.PrecompiledContractsByzantium[*tx.To()]; p != nil {
-			if err = p.ValidTx(pool.currentState, pool.signer, tx); err != nil {
+		number := new(big.Int).Add(pool.chain.CurrentBlock().Number(), common.Big1)
+		if p := vm.PrecompiledContractsFor(pool.chainconfig, number)[*tx.To()]; p != nil {
+			if err = p.ValidTx(pool.currentState, pool.chainconfig.Rules(number), pool.signer, tx); err != nil {
 				return err
 			}
 		}
@@ -614,6 +747,37 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	return nil
 }
 
+// rules returns the chain rules of the next block, the pending transactions are
+// validated with.
+func (pool *TxPool) rules() params.Rules {
+	return pool.chainconfig.Rules(new(big.Int).Add(pool.chain.CurrentBlock().Number(), common.Big1))
+}
+
+// verifyAccount runs the account verification contract of from against tx,
+// as it would be executed in the next block.
+func (pool *TxPool) verifyAccount(from common.Address, tx *types.Transaction) error {
+	head := pool.chain.CurrentBlock().Header()
+	number := new(big.Int).Add(head.Number, common.Big1)
+	if !pool.chainconfig.IsAccountAbstraction(number) {
+		return nil
+	}
+	context := vm.Context{
+		CanTransfer: CanTransfer,
+		Transfer:    Transfer,
+		GetHash:     func(uint64) common.Hash { return common.Hash{} },
+		Origin:      from,
+		Coinbase:    head.Coinbase,
+		BlockNumber: number,
+		Time:        big.NewInt(time.Now().Unix()),
+		Difficulty:  new(big.Int).Set(head.Difficulty),
+		GasLimit:    new(big.Int).Set(pool.currentMaxGas),
+		GasPrice:    new(big.Int).Set(tx.GasPrice()),
+	}
+	evm := vm.NewEVM(context, pool.currentState, pool.chainconfig, vm.Config{})
+	_, err := VerifyAccountTx(evm, pool.signer, from, tx)
+	return err
+}
+
 // add validates a transaction and inserts it into the non-executable queue for
 // later pending promotion and execution. If the transaction is a replacement for
 // an already pending or queued one, it overwrites the previous and returns this
@@ -625,10 +789,20 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 	// If the transaction is already known, discard it
 	hash := tx.Hash()
-	if pool.all[hash] != nil {
+	if pool.all[hash] != nil || pool.unknown.get(hash) != nil {
 		log.Trace("Discarding already known transaction", "hash", hash)
 		return false, fmt.Errorf("known transaction: %x", hash)
 	}
+	// Transactions of unknown types are at most retained for relay, reporting a
+	// replacement so no promotion is attempted
+	if !types.IsValidTransactionType(tx.Txtype()) {
+		if err := pool.addUnknown(tx, local); err != nil {
+			log.Trace("Discarding unknown type transaction", "hash", hash, "type", tx.Txtype(), "err", err)
+			invalidTxCounter.Inc(1)
+			return false, err
+		}
+		return true, nil
+	}
 	// If the transaction fails basic validation, discard it
 	if err := pool.validateTx(tx, local); err != nil {
 		log.Trace("Discarding invalid transaction", "hash", hash, "err", err)
@@ -667,6 +841,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 			pendingReplaceCounter.Inc(1)
 		}
 		pool.all[tx.Hash()] = tx
+		pool.added[tx.Hash()] = time.Now()
 		pool.priced.Put(tx)
 		pool.journalTx(from, tx)
 
@@ -688,6 +863,35 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 	return replace, nil
 }
 
+// addUnknown retains a remote transaction of an unknown type for relay, if
+// enabled, after the checks not depending on the type: its size, signature and
+// the node local policy. The peers accepting its type are then sent it.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) addUnknown(tx *types.Transaction, local bool) error {
+	if local || !pool.config.RelayUnknown {
+		return fmt.Errorf("%v: %d", ErrUnknownTxType, tx.Txtype())
+	}
+	if tx.Size() > 32*1024 {
+		return ErrOversizedData
+	}
+	from, err := types.Sender(pool.signer, tx)
+	if err != nil {
+		return ErrInvalidSender
+	}
+	if pool.policy != nil {
+		if err := pool.policy.Check(from, tx); err != nil {
+			return err
+		}
+	}
+	pool.unknown.add(tx)
+	unknownTxCounter.Inc(1)
+	log.Trace("Retained unknown type transaction for relay", "hash", tx.Hash(), "type", tx.Txtype(), "from", from)
+
+	go pool.txFeed.Send(TxPreEvent{Tx: tx})
+	return nil
+}
+
 // enqueueTx inserts a new transaction into the non-executable transaction queue.
 //
 // Note, this method assumes the pool lock is held!
@@ -710,6 +914,9 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction) (bool, er
 		queuedReplaceCounter.Inc(1)
 	}
 	pool.all[hash] = tx
+	if _, ok := pool.added[hash]; !ok {
+		pool.added[hash] = time.Now()
+	}
 	pool.priced.Put(tx)
 	return old != nil, nil
 }
@@ -760,7 +967,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 	// Set the potentially new pending nonce and notify any subsystems of the new tx
 	pool.beats[addr] = time.Now()
 	pool.pendingState.SetNonce(addr, tx.Nonce()+1)
-	go pool.txFeed.Send(TxPreEvent{tx})
+	go pool.txFeed.Send(TxPreEvent{Tx: tx, Local: pool.locals.contains(addr)})
 }
 
 // AddLocal enqueues a single transaction into the pool if it is valid, marking
@@ -847,7 +1054,10 @@ func (pool *TxPool) Get(hash common.Hash) *types.Transaction {
 	pool.mu.RLock()
 	defer pool.mu.RUnlock()
 
-	return pool.all[hash]
+	if tx := pool.all[hash]; tx != nil {
+		return tx
+	}
+	return pool.unknown.get(hash)
 }
 
 // removeTx removes a single transaction from the queue, moving all subsequent
@@ -862,6 +1072,7 @@ func (pool *TxPool) removeTx(hash common.Hash) {
 
 	// Remove it from the list of known transactions
 	delete(pool.all, hash)
+	delete(pool.added, hash)
 	pool.priced.Removed()
 
 	// Remove the transaction from the pending lists and reset the account nonce
@@ -930,7 +1141,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 		}
 
 		// Remove all invalid privacy transactions
-		invalidPrivacy := list.InvalidPrivacyTx(pool.currentState, pool.signer, pool.currentMaxGas)
+		invalidPrivacy := list.InvalidPrivacyTx(pool.currentState, pool.rules(), pool.signer, pool.currentMaxGas)
 		for _, tx := range invalidPrivacy {
 			hash := tx.Hash()
 			log.Trace("Removed invalid privacy transaction", "hash", hash)
@@ -1085,6 +1296,10 @@ func (pool *TxPool) demoteUnexecutables() {
 		for _, tx := range list.Forward(nonce) {
 			hash := tx.Hash()
 			log.Trace("Removed old pending transaction", "hash", hash)
+			if added, ok := pool.added[hash]; ok {
+				pool.delays.add(!types.IsNormalTransaction(tx.Txtype()), time.Since(added))
+				delete(pool.added, hash)
+			}
 			delete(pool.all, hash)
 			pool.priced.Removed()
 		}
@@ -1106,7 +1321,7 @@ func (pool *TxPool) demoteUnexecutables() {
 		}
 
 		// Remove all invalid privacy transactions
-		invalidPrivacy := list.InvalidPrivacyTx(pool.currentState, pool.signer, pool.currentMaxGas)
+		invalidPrivacy := list.InvalidPrivacyTx(pool.currentState, pool.rules(), pool.signer, pool.currentMaxGas)
 		for _, tx := range invalidPrivacy {
 			hash := tx.Hash()
 			log.Trace("Removed invalid privacy transaction", "hash", hash)
@@ -24,6 +24,8 @@ import (
 	"math/big"
 	"math/rand"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -37,6 +39,7 @@ import (
 	"github.com/wanchain/go-wanchain/ethdb"
 	"github.com/wanchain/go-wanchain/event"
 	"github.com/wanchain/go-wanchain/params"
+	"github.com/wanchain/go-wanchain/rlp"
 )
 
 // testTxPoolConfig is a transaction pool configuration without stateful disk
@@ -782,6 +785,244 @@ func testTransactionQueueTimeLimiting(t *testing.T, nolocals bool) {
 	}
 }
 
+// Tests that transactions outliving their time to live are dropped, whether
+// executable or not and local or not, and that expired local ones are removed
+// from the journal.
+func TestTransactionTTL(t *testing.T) {
+	// Reduce the eviction interval to a testable amount
+	defer func(old time.Duration) { evictionInterval = old }(evictionInterval)
+	evictionInterval = 100 * time.Millisecond
+
+	file, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary journal: %v", err)
+	}
+	journal := file.Name()
+	defer os.Remove(journal)
+	file.Close()
+	os.Remove(journal)
+
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	blockchain := &testBlockChain{statedb, big.NewInt(1000000), new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.TTL = 300 * time.Millisecond
+	config.Journal = journal
+	config.Rejournal = time.Hour
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+
+	local, _ := crypto.GenerateKey()
+	remote, _ := crypto.GenerateKey()
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(local.PublicKey), big.NewInt(1000000000))
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(remote.PublicKey), big.NewInt(1000000000))
+
+	// One pending and one queued transaction per account
+	for _, key := range []*ecdsa.PrivateKey{local, remote} {
+		add := pool.AddRemote
+		if key == local {
+			add = pool.AddLocal
+		}
+		for _, nonce := range []uint64{0, 2} {
+			if err := add(transaction(nonce, big.NewInt(100000), key)); err != nil {
+				t.Fatalf("failed to add transaction: %v", err)
+			}
+		}
+	}
+	if pending, queued := pool.Stats(); pending != 2 || queued != 2 {
+		t.Fatalf("pool size mismatch: have %d/%d, want 2/2", pending, queued)
+	}
+	time.Sleep(2 * config.TTL)
+
+	if pending, queued := pool.Stats(); pending != 0 || queued != 0 {
+		t.Fatalf("expired transactions not dropped: have %d/%d, want 0/0", pending, queued)
+	}
+	pool.mu.RLock()
+	if len(pool.added) != 0 {
+		t.Errorf("arrival times leaked: %d", len(pool.added))
+	}
+	pool.mu.RUnlock()
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+	pool.Stop()
+
+	// Restart the pool and check the expired locals aren't loaded back
+	pool = NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	if pending, queued := pool.Stats(); pending != 0 || queued != 0 {
+		t.Fatalf("expired transactions journaled: have %d/%d, want 0/0", pending, queued)
+	}
+}
+
+// Tests that the delays from the arrival of the transactions to their
+// inclusion are recorded when the chain includes them.
+func TestTransactionInclusionDelays(t *testing.T) {
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	pool.currentState.AddBalance(from, big.NewInt(1000000000))
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		if err := pool.AddRemote(transaction(nonce, big.NewInt(100000), key)); err != nil {
+			t.Fatalf("failed to add transaction: %v", err)
+		}
+	}
+	// Include the first two transactions in the chain
+	pool.currentState.SetNonce(from, 2)
+	pool.demoteUnexecutables()
+
+	normal, privacy := pool.InclusionDelays()
+	if len(normal) != 2 || len(privacy) != 0 {
+		t.Fatalf("inclusion delays mismatch: have %d/%d, want 2/0", len(normal), len(privacy))
+	}
+	if normal[0] > normal[1] || DelayPercentile(normal, 100) != normal[1] {
+		t.Errorf("inclusion delays not sorted: %v", normal)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// unknownTypeTransaction creates a signed transaction of the given type, not
+// constructible through the types package for the types it doesn't know.
+func unknownTypeTransaction(txType, nonce uint64, key *ecdsa.PrivateKey) *types.Transaction {
+	blob, _ := rlp.EncodeToBytes([]interface{}{txType, nonce, big.NewInt(1), big.NewInt(21000), common.Address{}, big.NewInt(100), []byte{}, big.NewInt(0), big.NewInt(0), big.NewInt(0)})
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(blob, tx); err != nil {
+		panic(err)
+	}
+	tx, _ = types.SignTx(tx, types.NewEIP155Signer(params.TestChainConfig.ChainId), key)
+	return tx
+}
+
+// Tests that the transactions of unknown types are rejected with a clear error
+// by default, and retained opaquely for relay if enabled: announced to the
+// subscribers but never pending, and dropped above the limit or once expired.
+func TestTransactionUnknownType(t *testing.T) {
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	if err := pool.AddRemote(unknownTypeTransaction(9, 0, key)); err == nil || !strings.HasPrefix(err.Error(), ErrUnknownTxType.Error()) {
+		t.Fatalf("unknown type transaction error mismatch: have %v, want %v", err, ErrUnknownTxType)
+	}
+
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	blockchain := &testBlockChain{statedb, big.NewInt(1000000), new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.RelayUnknown = true
+	config.UnknownSlots = 2
+
+	pool = NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	events := make(chan TxPreEvent, 4)
+	sub := pool.txFeed.Subscribe(events)
+	defer sub.Unsubscribe()
+
+	if err := pool.AddLocal(unknownTypeTransaction(9, 0, key)); err == nil {
+		t.Fatalf("local unknown type transaction accepted")
+	}
+	txs := []*types.Transaction{unknownTypeTransaction(9, 0, key), unknownTypeTransaction(9, 1, key), unknownTypeTransaction(10, 2, key)}
+	for i, tx := range txs {
+		if err := pool.AddRemote(tx); err != nil {
+			t.Fatalf("tx %d: failed to retain unknown type transaction: %v", i, err)
+		}
+	}
+	for i := range txs {
+		select {
+		case <-events:
+		case <-time.After(time.Second):
+			t.Fatalf("unknown type transaction %d not announced", i)
+		}
+	}
+	if pending, queued := pool.Stats(); pending != 0 || queued != 0 {
+		t.Fatalf("unknown type transactions pooled: pending %d, queued %d", pending, queued)
+	}
+	if pool.Get(txs[0].Hash()) != nil || pool.Get(txs[1].Hash()) == nil || pool.Get(txs[2].Hash()) == nil {
+		t.Fatalf("oldest unknown type transaction not dropped above the limit")
+	}
+	if err := pool.AddRemote(txs[2]); err == nil {
+		t.Fatalf("known unknown type transaction accepted again")
+	}
+	pool.mu.Lock()
+	pool.unknown.added[txs[1].Hash()] = time.Now().Add(-2 * config.Lifetime)
+	dropped := pool.unknown.expire(config.Lifetime)
+	pool.mu.Unlock()
+
+	if dropped != 1 || pool.Get(txs[1].Hash()) != nil || pool.Get(txs[2].Hash()) == nil {
+		t.Fatalf("expiry mismatch: dropped %d", dropped)
+	}
+}
+
+// Tests that the blacklist policy rejects the transactions from and to listed
+// addresses, audits them and drops the pooled ones once listed.
+func TestTransactionBlacklist(t *testing.T) {
+	dir, err := ioutil.TempDir("", "txpolicy")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	pool.currentState.AddBalance(sender, big.NewInt(1000000000))
+
+	listed := common.HexToAddress("0x0b")
+	path, audit := filepath.Join(dir, "blacklist"), filepath.Join(dir, "audit")
+	if err := ioutil.WriteFile(path, []byte("# sanctioned\n"+listed.Hex()+"\n"), 0600); err != nil {
+		t.Fatalf("failed to write blacklist: %v", err)
+	}
+	list, err := NewAddressBlacklist(path, audit)
+	if err != nil {
+		t.Fatalf("failed to load blacklist: %v", err)
+	}
+	// A pooled transaction from a newly listed sender is dropped
+	if err := pool.AddRemote(transaction(0, big.NewInt(100000), key)); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	if err := ioutil.WriteFile(path, []byte(listed.Hex()+"\n"+sender.Hex()+"\n"), 0600); err != nil {
+		t.Fatalf("failed to write blacklist: %v", err)
+	}
+	if _, err := list.Reload(); err != nil {
+		t.Fatalf("failed to reload blacklist: %v", err)
+	}
+	pool.SetPolicy(list)
+	if pending, queued := pool.Stats(); pending != 0 || queued != 0 {
+		t.Fatalf("blacklisted transaction not dropped: %d/%d", pending, queued)
+	}
+	if err := pool.AddLocal(transaction(0, big.NewInt(100000), key)); err != ErrBlacklisted {
+		t.Fatalf("blacklisted sender error mismatch: have %v, want %v", err, ErrBlacklisted)
+	}
+	// Transactions to a listed address are rejected too
+	other, _ := crypto.GenerateKey()
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(other.PublicKey), big.NewInt(1000000000))
+	tx, _ := types.SignTx(types.NewTransaction(0, listed, big.NewInt(1), big.NewInt(100000), big.NewInt(1), nil), types.HomesteadSigner{}, other)
+	if err := pool.AddRemote(tx); err != ErrBlacklisted {
+		t.Fatalf("blacklisted recipient error mismatch: have %v, want %v", err, ErrBlacklisted)
+	}
+	blob, err := ioutil.ReadFile(audit)
+	if err != nil {
+		t.Fatalf("failed to read audit log: %v", err)
+	}
+	if lines := strings.Count(string(blob), "\n"); lines != 3 {
+		t.Errorf("audit log entry count mismatch: have %d, want 3", lines)
+	}
+	if !strings.Contains(string(blob), strings.ToLower(tx.Hash().Hex())) {
+		t.Errorf("audit log misses rejected transaction %x", tx.Hash())
+	}
+	pool.SetPolicy(nil)
+	if err := pool.AddRemote(tx); err != nil {
+		t.Errorf("failed to add transaction without policy: %v", err)
+	}
+}
+
 // Tests that even if the transaction count belonging to a single account goes
 // above some threshold, as long as the transactions are executable, they are
 // accepted.
@@ -1579,7 +1820,7 @@ func TestStampVerifySuccess(t *testing.T) {
 
 	dbMockRetVal, _ = new(big.Int).SetString(WanStamp0dot1, 10)
 
-	_, _, _, err := PreProcessPrivacyTx(st.evm.StateDB, sender.Bytes(), st.data, st.gasPrice, common.Big0)
+	_, _, _, err := PreProcessPrivacyTx(st.evm.StateDB, params.TestRules, sender.Bytes(), st.data, st.gasPrice, common.Big0)
 	if err != nil {
 		t.Error(err)
 		return
@@ -1604,7 +1845,7 @@ func TestStampVerifyFailWrongSender(t *testing.T) {
 
 	dbMockRetVal, _ = new(big.Int).SetString(WanStamp0dot1, 10)
 
-	_, _, _, err := PreProcessPrivacyTx(st.evm.StateDB, sender.Bytes(), st.data, st.gasPrice, common.Big0)
+	_, _, _, err := PreProcessPrivacyTx(st.evm.StateDB, params.TestRules, sender.Bytes(), st.data, st.gasPrice, common.Big0)
 	if err == nil {
 		t.Error(err)
 		return
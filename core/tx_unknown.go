@@ -0,0 +1,79 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package core
+
+import (
+	"time"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core/types"
+)
+
+// unknownTxSet retains the transactions of types unknown to the node, opaquely:
+// they are neither checked against the state nor executed, only relayed to the
+// peers accepting their types. A network partially upgraded to a new
+// transaction type so keeps propagating it through the nodes not upgraded yet.
+//
+// Note, the set is not thread safe, it is guarded by the pool lock.
+type unknownTxSet struct {
+	limit int                                // Maximum number of retained transactions
+	txs   map[common.Hash]*types.Transaction // Retained transactions
+	added map[common.Hash]time.Time          // Arrival time of the transactions
+	order []common.Hash                      // Transaction hashes in arrival order
+}
+
+func newUnknownTxSet(limit int) *unknownTxSet {
+	return &unknownTxSet{
+		limit: limit,
+		txs:   make(map[common.Hash]*types.Transaction),
+		added: make(map[common.Hash]time.Time),
+	}
+}
+
+// get returns a retained transaction, nil if unknown.
+func (s *unknownTxSet) get(hash common.Hash) *types.Transaction {
+	return s.txs[hash]
+}
+
+// len returns the number of retained transactions.
+func (s *unknownTxSet) len() int {
+	return len(s.txs)
+}
+
+// add retains a transaction, dropping the oldest ones above the limit.
+func (s *unknownTxSet) add(tx *types.Transaction) {
+	hash := tx.Hash()
+	s.txs[hash] = tx
+	s.added[hash] = time.Now()
+	s.order = append(s.order, hash)
+
+	for len(s.txs) > s.limit {
+		s.remove(s.order[0])
+	}
+}
+
+// remove forgets a transaction.
+func (s *unknownTxSet) remove(hash common.Hash) {
+	delete(s.txs, hash)
+	delete(s.added, hash)
+	for i, h := range s.order {
+		if h == hash {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// expire drops the transactions retained for longer than lifetime, returning
+// their number.
+func (s *unknownTxSet) expire(lifetime time.Duration) int {
+	var dropped int
+	for len(s.order) > 0 && time.Since(s.added[s.order[0]]) > lifetime {
+		hash := s.order[0]
+		delete(s.txs, hash)
+		delete(s.added, hash)
+		s.order = s.order[1:]
+		dropped++
+	}
+	return dropped
+}
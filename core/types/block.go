@@ -83,6 +83,11 @@ type Header struct {
 	Extra       []byte         `json:"extraData"        gencodec:"required"`
 	MixDigest   common.Hash    `json:"mixHash"          gencodec:"required"`
 	Nonce       BlockNonce     `json:"nonce"            gencodec:"required"`
+
+	// Key image accumulator, only present from the key image accumulator fork
+	// on. It holds a single hash, kept in a tail list to leave the encoding of
+	// older headers unchanged.
+	KeyImageAcc []common.Hash `json:"keyImageAcc,omitempty" rlp:"tail"`
 }
 
 // field type overrides for gencodec
@@ -104,7 +109,7 @@ func (h *Header) Hash() common.Hash {
 
 // HashNoNonce returns the hash which is used as input for the proof-of-work search.
 func (h *Header) HashNoNonce() common.Hash {
-	return rlpHash([]interface{}{
+	fields := []interface{}{
 		h.ParentHash,
 		h.UncleHash,
 		h.Coinbase,
@@ -118,7 +123,11 @@ func (h *Header) HashNoNonce() common.Hash {
 		h.GasUsed,
 		h.Time,
 		h.Extra,
-	})
+	}
+	if len(h.KeyImageAcc) > 0 {
+		fields = append(fields, h.KeyImageAcc)
+	}
+	return rlpHash(fields)
 }
 
 func rlpHash(x interface{}) (h common.Hash) {
@@ -253,6 +262,10 @@ func CopyHeader(h *Header) *Header {
 		cpy.Extra = make([]byte, len(h.Extra))
 		copy(cpy.Extra, h.Extra)
 	}
+	if len(h.KeyImageAcc) > 0 {
+		cpy.KeyImageAcc = make([]common.Hash, len(h.KeyImageAcc))
+		copy(cpy.KeyImageAcc, h.KeyImageAcc)
+	}
 	return &cpy
 }
 
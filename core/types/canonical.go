@@ -0,0 +1,168 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/wanchain/go-wanchain/common"
+)
+
+var (
+	// ErrHashMismatch is returned if a hash carried by a JSON encoding doesn't
+	// match the one recomputed from the decoded fields.
+	ErrHashMismatch = errors.New("hash mismatch")
+
+	// ErrRootMismatch is returned if a root of a JSON encoded header doesn't
+	// match the one recomputed from the decoded block content.
+	ErrRootMismatch = errors.New("root mismatch")
+)
+
+// CanonicalJSON returns the canonical JSON encoding of a value: its regular
+// encoding, following the hex conventions of the core types, with the object
+// keys sorted and without insignificant whitespace. The encodings of equal
+// values are byte for byte identical, so they can be hashed or diffed.
+func CanonicalJSON(v interface{}) ([]byte, error) {
+	enc, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return canonicalize(enc)
+}
+
+// canonicalize re-encodes a JSON document with sorted object keys, keeping
+// the numbers verbatim.
+func canonicalize(enc []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(enc))
+	dec.UseNumber()
+
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	out := json.NewEncoder(buf)
+	out.SetEscapeHTML(false)
+	if err := out.Encode(doc); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}), nil
+}
+
+// canonicalBlock is the JSON encoding of a block.
+type canonicalBlock struct {
+	Header       *Header        `json:"header"`
+	Transactions []*Transaction `json:"transactions"`
+	Uncles       []*Header      `json:"uncles"`
+}
+
+// CanonicalBlockJSON returns the canonical JSON encoding of a block: its header,
+// transactions and uncles.
+func CanonicalBlockJSON(b *Block) ([]byte, error) {
+	block := &canonicalBlock{
+		Header:       b.Header(),
+		Transactions: b.Transactions(),
+		Uncles:       b.Uncles(),
+	}
+	if block.Transactions == nil {
+		block.Transactions = Transactions{}
+	}
+	if block.Uncles == nil {
+		block.Uncles = []*Header{}
+	}
+	return CanonicalJSON(block)
+}
+
+// TransactionFromJSON decodes a JSON encoded transaction, checking its hash if
+// the encoding carries one.
+func TransactionFromJSON(enc []byte) (*Transaction, error) {
+	tx := new(Transaction)
+	if err := json.Unmarshal(enc, tx); err != nil {
+		return nil, err
+	}
+	if tx.data.Hash != nil && *tx.data.Hash != tx.Hash() {
+		return nil, fmt.Errorf("transaction %v: have %x, recomputed %x", ErrHashMismatch, *tx.data.Hash, tx.Hash())
+	}
+	return tx, nil
+}
+
+// HeaderFromJSON decodes a JSON encoded header, checking its hash if the
+// encoding carries one.
+func HeaderFromJSON(enc []byte) (*Header, error) {
+	header := new(Header)
+	if err := json.Unmarshal(enc, header); err != nil {
+		return nil, err
+	}
+	var carried struct {
+		Hash *common.Hash `json:"hash"`
+	}
+	if err := json.Unmarshal(enc, &carried); err != nil {
+		return nil, err
+	}
+	if carried.Hash != nil && *carried.Hash != header.Hash() {
+		return nil, fmt.Errorf("header %v: have %x, recomputed %x", ErrHashMismatch, *carried.Hash, header.Hash())
+	}
+	return header, nil
+}
+
+// BlockFromJSON decodes a block encoded by CanonicalBlockJSON, checking the
+// hashes carried by the encoding along with the transaction root and the uncle
+// hash of the header.
+func BlockFromJSON(enc []byte) (*Block, error) {
+	var raw struct {
+		Header       json.RawMessage   `json:"header"`
+		Transactions []json.RawMessage `json:"transactions"`
+		Uncles       []json.RawMessage `json:"uncles"`
+	}
+	if err := json.Unmarshal(enc, &raw); err != nil {
+		return nil, err
+	}
+	if raw.Header == nil {
+		return nil, errors.New("missing block header")
+	}
+	header, err := HeaderFromJSON(raw.Header)
+	if err != nil {
+		return nil, err
+	}
+	txs := make(Transactions, len(raw.Transactions))
+	for i, rawTx := range raw.Transactions {
+		if txs[i], err = TransactionFromJSON(rawTx); err != nil {
+			return nil, fmt.Errorf("transaction %d: %v", i, err)
+		}
+	}
+	uncles := make([]*Header, len(raw.Uncles))
+	for i, rawUncle := range raw.Uncles {
+		if uncles[i], err = HeaderFromJSON(rawUncle); err != nil {
+			return nil, fmt.Errorf("uncle %d: %v", i, err)
+		}
+	}
+	if root := DeriveSha(txs); root != header.TxHash {
+		return nil, fmt.Errorf("transactions %v: have %x, recomputed %x", ErrRootMismatch, header.TxHash, root)
+	}
+	if hash := CalcUncleHash(uncles); hash != header.UncleHash {
+		return nil, fmt.Errorf("uncles %v: have %x, recomputed %x", ErrHashMismatch, header.UncleHash, hash)
+	}
+	return NewBlockWithHeader(header).WithBody(txs, uncles), nil
+}
+
+// ReceiptsFromJSON decodes the JSON encoded receipts of a block, checking the
+// logs bloom of every receipt, and returns them along with their recomputed
+// root, to be compared against the receipt root of the block header.
+func ReceiptsFromJSON(enc []byte) (Receipts, common.Hash, error) {
+	var receipts Receipts
+	if err := json.Unmarshal(enc, &receipts); err != nil {
+		return nil, common.Hash{}, err
+	}
+	for i, receipt := range receipts {
+		if receipt == nil {
+			return nil, common.Hash{}, fmt.Errorf("receipt %d: missing", i)
+		}
+		if bloom := CreateBloom(Receipts{receipt}); bloom != receipt.Bloom {
+			return nil, common.Hash{}, fmt.Errorf("receipt %d: logs bloom mismatch", i)
+		}
+	}
+	return receipts, DeriveSha(receipts), nil
+}
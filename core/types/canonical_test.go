@@ -0,0 +1,94 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package types
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/common"
+)
+
+func TestCanonicalJSON(t *testing.T) {
+	enc, err := CanonicalJSON(map[string]interface{}{
+		"b": []int{2, 1},
+		"a": map[string]string{"y": "<1>", "x": "0x01"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"a":{"x":"0x01","y":"<1>"},"b":[2,1]}`; string(enc) != want {
+		t.Errorf("encoding mismatch: have %s, want %s", enc, want)
+	}
+	// The encoding of the struct types is canonicalized too
+	enc, err = CanonicalJSON(rightvrsTx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(enc), `{"Txtype":"0x1","gas":"0x30d40","gasPrice":"0x1","hash":`) {
+		t.Errorf("transaction encoding not canonical: %s", enc)
+	}
+	if again, _ := canonicalize(enc); !bytes.Equal(again, enc) {
+		t.Errorf("canonicalization not idempotent: have %s, want %s", again, enc)
+	}
+}
+
+func TestBlockJSONRoundTrip(t *testing.T) {
+	txs := Transactions{rightvrsTx}
+	receipt := NewReceipt(nil, false, big.NewInt(21000))
+	receipt.GasUsed = big.NewInt(21000)
+	receipt.Logs = []*Log{{Address: common.HexToAddress("0x01"), Topics: []common.Hash{{0x02}}, Data: []byte{0x03}}}
+	receipt.Bloom = CreateBloom(Receipts{receipt})
+	receipt.Privacy = &ReceiptPrivacy{StampUsed: big.NewInt(1000), KeyImages: [][]byte{{0x04, 0x01}}}
+	receipts := Receipts{receipt}
+
+	header := &Header{
+		Number:     big.NewInt(7),
+		Difficulty: big.NewInt(131072),
+		GasLimit:   big.NewInt(4712388),
+		GasUsed:    big.NewInt(21000),
+		Time:       big.NewInt(1500000000),
+		Extra:      []byte("wanchain"),
+	}
+	uncle := &Header{Number: big.NewInt(6), Difficulty: big.NewInt(1), GasLimit: big.NewInt(1), GasUsed: new(big.Int), Time: new(big.Int)}
+	block := NewBlock(header, txs, []*Header{uncle}, receipts)
+
+	enc, err := CanonicalBlockJSON(block)
+	if err != nil {
+		t.Fatalf("failed to encode block: %v", err)
+	}
+	dec, err := BlockFromJSON(enc)
+	if err != nil {
+		t.Fatalf("failed to decode block: %v", err)
+	}
+	if dec.Hash() != block.Hash() {
+		t.Errorf("block hash mismatch: have %x, want %x", dec.Hash(), block.Hash())
+	}
+	if again, _ := CanonicalBlockJSON(dec); !bytes.Equal(again, enc) {
+		t.Errorf("re-encoding mismatch:\nhave %s\nwant %s", again, enc)
+	}
+	// Tampering with the content is detected
+	tampered := strings.Replace(string(enc), `"input":"0x5544"`, `"input":"0x5545"`, 1)
+	if _, err := BlockFromJSON([]byte(tampered)); err == nil || !strings.Contains(err.Error(), ErrHashMismatch.Error()) {
+		t.Errorf("tampered transaction error mismatch: have %v, want %v", err, ErrHashMismatch)
+	}
+	tampered = strings.Replace(string(enc), `"extraData":"0x77616e636861696e"`, `"extraData":"0x"`, 1)
+	if _, err := BlockFromJSON([]byte(tampered)); err == nil || !strings.Contains(err.Error(), ErrHashMismatch.Error()) {
+		t.Errorf("tampered header error mismatch: have %v, want %v", err, ErrHashMismatch)
+	}
+
+	// The receipts root is recomputed from their encoding
+	enc, err = CanonicalJSON(receipts)
+	if err != nil {
+		t.Fatalf("failed to encode receipts: %v", err)
+	}
+	_, root, err := ReceiptsFromJSON(enc)
+	if err != nil {
+		t.Fatalf("failed to decode receipts: %v", err)
+	}
+	if root != block.ReceiptHash() {
+		t.Errorf("receipts root mismatch: have %x, want %x", root, block.ReceiptHash())
+	}
+}
@@ -28,6 +28,7 @@ func (h Header) MarshalJSON() ([]byte, error) {
 		Extra       hexutil.Bytes  `json:"extraData"        gencodec:"required"`
 		MixDigest   common.Hash    `json:"mixHash"          gencodec:"required"`
 		Nonce       BlockNonce     `json:"nonce"            gencodec:"required"`
+		KeyImageAcc []common.Hash  `json:"keyImageAcc,omitempty" rlp:"tail"`
 		Hash        common.Hash    `json:"hash"`
 	}
 	var enc Header
@@ -46,6 +47,7 @@ func (h Header) MarshalJSON() ([]byte, error) {
 	enc.Extra = h.Extra
 	enc.MixDigest = h.MixDigest
 	enc.Nonce = h.Nonce
+	enc.KeyImageAcc = h.KeyImageAcc
 	enc.Hash = h.Hash()
 	return json.Marshal(&enc)
 }
@@ -67,6 +69,7 @@ func (h *Header) UnmarshalJSON(input []byte) error {
 		Extra       hexutil.Bytes   `json:"extraData"        gencodec:"required"`
 		MixDigest   *common.Hash    `json:"mixHash"          gencodec:"required"`
 		Nonce       *BlockNonce     `json:"nonce"            gencodec:"required"`
+		KeyImageAcc []common.Hash   `json:"keyImageAcc,omitempty" rlp:"tail"`
 	}
 	var dec Header
 	if err := json.Unmarshal(input, &dec); err != nil {
@@ -132,5 +135,8 @@ func (h *Header) UnmarshalJSON(input []byte) error {
 		return errors.New("missing required field 'nonce' for Header")
 	}
 	h.Nonce = *dec.Nonce
+	if dec.KeyImageAcc != nil {
+		h.KeyImageAcc = dec.KeyImageAcc
+	}
 	return nil
 }
@@ -55,6 +55,10 @@ type Log struct {
 	// The Removed field is true if this log was reverted due to a chain reorganisation.
 	// You must pay attention to this field if you receive logs through a filter query.
 	Removed bool `json:"removed"`
+
+	// The Pending field is true if this log was produced by the speculative execution
+	// of the pending block, the transaction isn't included yet.
+	Pending bool `json:"pending,omitempty"`
 }
 
 type logMarshaling struct {
@@ -28,6 +28,7 @@ import (
 )
 
 //go:generate gencodec -type Receipt -field-override receiptMarshaling -out gen_receipt_json.go
+//go:generate gencodec -type ReceiptPrivacy -field-override receiptPrivacyMarshaling -out gen_receipt_privacy_json.go
 
 var (
 	receiptStatusFailedRLP     = []byte{}
@@ -51,6 +52,9 @@ type Receipt struct {
 	Bloom             Bloom    `json:"logsBloom"         gencodec:"required"`
 	Logs              []*Log   `json:"logs"              gencodec:"required"`
 
+	// Privacy consumption, only set from the privacy receipt fork on
+	Privacy *ReceiptPrivacy `json:"privacy,omitempty"`
+
 	// Implementation fields (don't reorder!)
 	TxHash          common.Hash    `json:"transactionHash" gencodec:"required"`
 	ContractAddress common.Address `json:"contractAddress"`
@@ -64,12 +68,25 @@ type receiptMarshaling struct {
 	GasUsed           *hexutil.Big
 }
 
-// receiptRLP is the consensus encoding of a receipt.
+// ReceiptPrivacy records the privacy resources consumed by a transaction.
+type ReceiptPrivacy struct {
+	StampUsed *big.Int `json:"stampUsed" gencodec:"required"` // Value of the stamp consumed by a privacy transaction
+	KeyImages [][]byte `json:"keyImages" gencodec:"required"` // Key images of the OTAs spent by the transaction
+}
+
+type receiptPrivacyMarshaling struct {
+	StampUsed *hexutil.Big
+	KeyImages []hexutil.Bytes
+}
+
+// receiptRLP is the consensus encoding of a receipt. Privacy is empty before
+// the privacy receipt fork, keeping the legacy encoding.
 type receiptRLP struct {
 	PostStateOrStatus []byte
 	CumulativeGasUsed *big.Int
 	Bloom             Bloom
 	Logs              []*Log
+	Privacy           []*ReceiptPrivacy `rlp:"tail"`
 }
 
 type receiptStorageRLP struct {
@@ -80,6 +97,7 @@ type receiptStorageRLP struct {
 	ContractAddress   common.Address
 	Logs              []*LogForStorage
 	GasUsed           *big.Int
+	Privacy           []*ReceiptPrivacy `rlp:"tail"`
 }
 
 // NewReceipt creates a barebone transaction receipt, copying the init fields.
@@ -96,7 +114,7 @@ func NewReceipt(root []byte, failed bool, cumulativeGasUsed *big.Int) *Receipt {
 // EncodeRLP implements rlp.Encoder, and flattens the consensus fields of a receipt
 // into an RLP stream. If no post state is present, byzantium fork is assumed.
 func (r *Receipt) EncodeRLP(w io.Writer) error {
-	return rlp.Encode(w, &receiptRLP{r.statusEncoding(), r.CumulativeGasUsed, r.Bloom, r.Logs})
+	return rlp.Encode(w, &receiptRLP{r.statusEncoding(), r.CumulativeGasUsed, r.Bloom, r.Logs, r.privacyEncoding()})
 }
 
 // DecodeRLP implements rlp.Decoder, and loads the consensus fields of a receipt
@@ -110,7 +128,7 @@ func (r *Receipt) DecodeRLP(s *rlp.Stream) error {
 		return err
 	}
 	r.CumulativeGasUsed, r.Bloom, r.Logs = dec.CumulativeGasUsed, dec.Bloom, dec.Logs
-	return nil
+	return r.setPrivacy(dec.Privacy)
 }
 
 func (r *Receipt) setStatus(postStateOrStatus []byte) error {
@@ -137,6 +155,25 @@ func (r *Receipt) statusEncoding() []byte {
 	return r.PostState
 }
 
+func (r *Receipt) setPrivacy(privacy []*ReceiptPrivacy) error {
+	switch len(privacy) {
+	case 0:
+		r.Privacy = nil
+	case 1:
+		r.Privacy = privacy[0]
+	default:
+		return fmt.Errorf("invalid receipt privacy fields: %d", len(privacy))
+	}
+	return nil
+}
+
+func (r *Receipt) privacyEncoding() []*ReceiptPrivacy {
+	if r.Privacy == nil {
+		return nil
+	}
+	return []*ReceiptPrivacy{r.Privacy}
+}
+
 // String implements the Stringer interface.
 func (r *Receipt) String() string {
 	if len(r.PostState) == 0 {
@@ -160,6 +197,7 @@ func (r *ReceiptForStorage) EncodeRLP(w io.Writer) error {
 		ContractAddress:   r.ContractAddress,
 		Logs:              make([]*LogForStorage, len(r.Logs)),
 		GasUsed:           r.GasUsed,
+		Privacy:           (*Receipt)(r).privacyEncoding(),
 	}
 	for i, log := range r.Logs {
 		enc.Logs[i] = (*LogForStorage)(log)
@@ -185,7 +223,7 @@ func (r *ReceiptForStorage) DecodeRLP(s *rlp.Stream) error {
 	}
 	// Assign the implementation fields
 	r.TxHash, r.ContractAddress, r.GasUsed = dec.TxHash, dec.ContractAddress, dec.GasUsed
-	return nil
+	return (*Receipt)(r).setPrivacy(dec.Privacy)
 }
 
 // Receipts is a wrapper around a Receipt array to implement DerivableList.
@@ -0,0 +1,71 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package types
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/rlp"
+)
+
+func TestReceiptPrivacyEncoding(t *testing.T) {
+	legacy := NewReceipt(nil, false, big.NewInt(21000))
+	legacy.Logs = []*Log{}
+
+	private := NewReceipt(nil, false, big.NewInt(21000))
+	private.Logs = []*Log{}
+	private.Privacy = &ReceiptPrivacy{
+		StampUsed: big.NewInt(1000),
+		KeyImages: [][]byte{common.FromHex("0x0401"), common.FromHex("0x0402")},
+	}
+
+	// Receipts without privacy fields must keep the legacy encoding
+	enc, err := rlp.EncodeToBytes(legacy)
+	if err != nil {
+		t.Fatalf("failed to encode legacy receipt: %v", err)
+	}
+	want, _ := rlp.EncodeToBytes(&struct {
+		PostStateOrStatus []byte
+		CumulativeGasUsed *big.Int
+		Bloom             Bloom
+		Logs              []*Log
+	}{receiptStatusSuccessfulRLP, legacy.CumulativeGasUsed, legacy.Bloom, legacy.Logs})
+	if !bytes.Equal(enc, want) {
+		t.Errorf("legacy encoding mismatch: have %x, want %x", enc, want)
+	}
+
+	for i, receipt := range []*Receipt{legacy, private} {
+		enc, err := rlp.EncodeToBytes(receipt)
+		if err != nil {
+			t.Fatalf("test %d: failed to encode receipt: %v", i, err)
+		}
+		var dec Receipt
+		if err := rlp.DecodeBytes(enc, &dec); err != nil {
+			t.Fatalf("test %d: failed to decode receipt: %v", i, err)
+		}
+		if !reflect.DeepEqual(dec.Privacy, receipt.Privacy) {
+			t.Errorf("test %d: privacy mismatch: have %v, want %v", i, dec.Privacy, receipt.Privacy)
+		}
+
+		enc, err = rlp.EncodeToBytes((*ReceiptForStorage)(receipt))
+		if err != nil {
+			t.Fatalf("test %d: failed to encode stored receipt: %v", i, err)
+		}
+		var stored ReceiptForStorage
+		if err := rlp.DecodeBytes(enc, &stored); err != nil {
+			t.Fatalf("test %d: failed to decode stored receipt: %v", i, err)
+		}
+		if !reflect.DeepEqual(stored.Privacy, receipt.Privacy) {
+			t.Errorf("test %d: stored privacy mismatch: have %v, want %v", i, stored.Privacy, receipt.Privacy)
+		}
+	}
+
+	// The privacy fields are committed to by the receipt trie
+	if DeriveSha(Receipts{legacy}) == DeriveSha(Receipts{private}) {
+		t.Errorf("receipt root doesn't commit to privacy fields")
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package vm
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/wanchain/go-wanchain/accounts/abi"
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/params"
+)
+
+// Account abstraction lets an account register a verification contract which
+// is consulted, in addition to the sender signature, before any of its
+// transactions is accepted. The contract implements
+//
+//	function verify(bytes32 hash, address account, bytes payload) constant returns (bool)
+//
+// and receives the signing hash and payload of the transaction, allowing
+// accounts to be guarded by ring signatures, Schnorr keys or any other scheme.
+var (
+	accountVerifierSCDefinition = `[{"constant":false,"type":"function","inputs":[{"name":"Verifier","type":"address"}],"name":"setVerifier","outputs":[{"name":"Verifier","type":"address"}]}]`
+	verifierDefinition          = `[{"constant":true,"type":"function","inputs":[{"name":"hash","type":"bytes32"},{"name":"account","type":"address"},{"name":"payload","type":"bytes"}],"name":"verify","outputs":[{"name":"","type":"bool"}]}]`
+
+	accountVerifierAbi, errAccountVerifierSCInit = abi.JSON(strings.NewReader(accountVerifierSCDefinition))
+	verifierAbi, errVerifierInit                 = abi.JSON(strings.NewReader(verifierDefinition))
+	setVerifierId                                [4]byte
+
+	ErrAccountAbstractionInactive = errors.New("account abstraction is not active")
+	ErrInvalidVerifier            = errors.New("verifier is not a contract")
+	ErrAccountVerification        = errors.New("rejected by account verifier")
+	ErrVerifiedPrivacyTx          = errors.New("privacy transactions can't be sent from an account with a verifier")
+)
+
+func init() {
+	if errAccountVerifierSCInit != nil || errVerifierInit != nil {
+		panic("err in account verifier sc initialize")
+	}
+	copy(setVerifierId[:], accountVerifierAbi.Methods["setVerifier"].Id())
+}
+
+// GetAccountVerifier returns the verification contract registered by account.
+func GetAccountVerifier(stateDB StateDB, account common.Address) (common.Address, bool) {
+	verifier := stateDB.GetState(accountVerifierPrecompileAddr, account.Hash())
+	if verifier == (common.Hash{}) {
+		return common.Address{}, false
+	}
+	return common.BytesToAddress(verifier.Bytes()), true
+}
+
+// VerifyAccountSignature runs the verification contract of account against
+// the signing hash and payload of a transaction, within the given gas
+// allowance, returning the gas it used. Any failure or negative answer
+// rejects it.
+func VerifyAccountSignature(evm *EVM, verifier, account common.Address, hash common.Hash, payload []byte, gas uint64) (uint64, error) {
+	input, err := verifierAbi.Pack("verify", hash, account, payload)
+	if err != nil {
+		return 0, err
+	}
+	ret, left, err := evm.StaticCall(AccountRef(account), verifier, input, gas)
+	if err != nil {
+		return gas - left, ErrAccountVerification
+	}
+	var ok bool
+	if err := verifierAbi.Unpack(&ok, "verify", ret); err != nil || !ok {
+		return gas - left, ErrAccountVerification
+	}
+	return gas - left, nil
+}
+
+// accountVerifierSC keeps the registry of account verification contracts.
+// The caller of setVerifier registers the verifier for itself, the zero
+// address removes it.
+type accountVerifierSC struct{}
+
+func (c *accountVerifierSC) RequiredGas(input []byte) uint64 {
+	return params.SstoreSetGas
+}
+
+func (c *accountVerifierSC) Run(in []byte, contract *Contract, evm *EVM) ([]byte, error) {
+	if !evm.ChainConfig().IsAccountAbstraction(evm.BlockNumber) {
+		return nil, ErrAccountAbstractionInactive
+	}
+	if evm.interpreter.readOnly {
+		return nil, errWriteProtection
+	}
+	verifier, err := c.validSetVerifierReq(evm.StateDB, in)
+	if err != nil {
+		return nil, err
+	}
+	evm.StateDB.SetState(accountVerifierPrecompileAddr, contract.CallerAddress.Hash(), verifier.Hash())
+	return []byte{1}, nil
+}
+
+func (c *accountVerifierSC) ValidTx(stateDB StateDB, rules params.Rules, signer types.Signer, tx *types.Transaction) error {
+	if stateDB == nil || signer == nil || tx == nil {
+		return errParameters
+	}
+	_, err := c.validSetVerifierReq(stateDB, tx.Data())
+	return err
+}
+
+func (c *accountVerifierSC) validSetVerifierReq(stateDB StateDB, in []byte) (common.Address, error) {
+	if len(in) < 4 {
+		return common.Address{}, errParameters
+	}
+	var methodId [4]byte
+	copy(methodId[:], in[:4])
+	if methodId != setVerifierId {
+		return common.Address{}, errMethodId
+	}
+
+	var verifier common.Address
+	if err := accountVerifierAbi.Unpack(&verifier, "setVerifier", in[4:]); err != nil {
+		return common.Address{}, errParameters
+	}
+	if verifier != (common.Address{}) && stateDB.GetCodeSize(verifier) == 0 {
+		return common.Address{}, ErrInvalidVerifier
+	}
+	return verifier, nil
+}
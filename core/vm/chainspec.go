@@ -0,0 +1,98 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package vm
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/common/math"
+	"github.com/wanchain/go-wanchain/params"
+)
+
+// PrecompileActivation is a precompiled contract with the block it behaves as
+// specified from, nil if it never does.
+type PrecompileActivation struct {
+	Name    string         `json:"name"`
+	Address common.Address `json:"address"`
+	Block   *big.Int       `json:"activationBlock"`
+}
+
+// PrecompileActivations returns the precompiled contracts of a chain, sorted by
+// address: the ones of PrecompiledContractsFor, the contracts gated by a fork
+// being inactive before it.
+func PrecompileActivations(config *params.ChainConfig) []PrecompileActivation {
+	activations := make([]PrecompileActivation, 0, len(PrecompiledContractsByzantium)+len(forkPrecompiles))
+	for addr := range PrecompiledContractsByzantium {
+		activations = append(activations, PrecompileActivation{precompileNames[addr], addr, new(big.Int)})
+	}
+	for addr, p := range forkPrecompiles {
+		var block *big.Int
+		if fork := p.fork(config); fork != nil {
+			block = new(big.Int).Set(fork)
+		}
+		activations = append(activations, PrecompileActivation{p.name, addr, block})
+	}
+	sort.Slice(activations, func(i, j int) bool {
+		return bytes.Compare(activations[i].Address[:], activations[j].Address[:]) < 0
+	})
+	return activations
+}
+
+// Denomination is a value of the privacy coins or stamps, with the storage
+// account of the OTAs holding it.
+type Denomination struct {
+	Value   *math.HexOrDecimal256 `json:"value"`
+	Storage common.Address        `json:"storage"`
+}
+
+// PrivacyParams are the constants of the privacy protocol. The ring size and
+// stamp values may be raised by governance, the values are their defaults.
+type PrivacyParams struct {
+	CoinDenominations  []Denomination `json:"coinDenominations"`
+	StampDenominations []Denomination `json:"stampDenominations"`
+
+	OTABalanceStorage  common.Address `json:"otaBalanceStorage"`
+	KeyImageStorage    common.Address `json:"keyImageStorage"`
+	RingMembersStorage common.Address `json:"ringMembersStorage"`
+
+	MinRingSize                  uint64 `json:"minRingSize"`
+	RequiredGasPerMixPub         uint64 `json:"requiredGasPerMixPub"`
+	MaxRingSignedDataSize        uint64 `json:"maxRingSignedDataSize"`
+	MaxChunkedRingSignedDataSize uint64 `json:"maxChunkedRingSignedDataSize"`
+	RingMembersStoreGasPerWord   uint64 `json:"ringMembersStoreGasPerWord"`
+	MaxPrecompileReturnSize      uint64 `json:"maxPrecompileReturnSize"`
+	PrecompileReturnWordGas      uint64 `json:"precompileReturnWordGas"`
+}
+
+// CurrentPrivacyParams returns the privacy constants of the node.
+func CurrentPrivacyParams() *PrivacyParams {
+	return &PrivacyParams{
+		CoinDenominations:            denominationsOf(WanCoinValueSet),
+		StampDenominations:           denominationsOf(StampValueSet),
+		OTABalanceStorage:            otaBalanceStorageAddr,
+		KeyImageStorage:              otaImageStorageAddr,
+		RingMembersStorage:           ringMembersStorageAddr,
+		MinRingSize:                  params.MinRingSize,
+		RequiredGasPerMixPub:         params.RequiredGasPerMixPub,
+		MaxRingSignedDataSize:        params.MaxRingSignedDataSize,
+		MaxChunkedRingSignedDataSize: params.MaxChunkedRingSignedDataSize,
+		RingMembersStoreGasPerWord:   params.RingMembersStoreGasPerWord,
+		MaxPrecompileReturnSize:      params.MaxPrecompileReturnSize,
+		PrecompileReturnWordGas:      params.PrecompileReturnWordGas,
+	}
+}
+
+// denominationsOf returns the denominations of a value set in ascending order.
+func denominationsOf(set map[string]string) []Denomination {
+	var denominations []Denomination
+	for _, value := range sortedDenominations(set) {
+		denominations = append(denominations, Denomination{
+			Value:   (*math.HexOrDecimal256)(value),
+			Storage: common.HexToAddress(value.String()),
+		})
+	}
+	return denominations
+}
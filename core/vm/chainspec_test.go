@@ -0,0 +1,53 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package vm
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/params"
+)
+
+// Tests that the precompile activations list every precompiled contract once,
+// gated by the forks of the chain.
+func TestPrecompileActivations(t *testing.T) {
+	config := &params.ChainConfig{GovernanceBlock: big.NewInt(10)}
+	activations := PrecompileActivations(config)
+	if want := len(PrecompiledContractsByzantium) + len(forkPrecompiles); len(activations) != want {
+		t.Fatalf("activation count mismatch: have %d, want %d", len(activations), want)
+	}
+	for i, activation := range activations {
+		if activation.Name == "" {
+			t.Errorf("precompile %x: no name", activation.Address)
+		}
+		if i > 0 && bytes.Compare(activations[i-1].Address[:], activation.Address[:]) >= 0 {
+			t.Errorf("%s: not sorted by address", activation.Name)
+		}
+		// The activation block must match the contracts actually selected
+		if activation.Block != nil {
+			if PrecompiledContractsFor(config, activation.Block)[activation.Address] == nil {
+				t.Errorf("%s: inactive at its activation block %v", activation.Name, activation.Block)
+			}
+			if activation.Block.Sign() > 0 && PrecompiledContractsFor(config, new(big.Int).Sub(activation.Block, big.NewInt(1)))[activation.Address] != nil {
+				t.Errorf("%s: active before its activation block %v", activation.Name, activation.Block)
+			}
+		} else if PrecompiledContractsFor(config, big.NewInt(1<<40))[activation.Address] != nil {
+			t.Errorf("%s: active without activation block", activation.Name)
+		}
+		if _, ok := forkPrecompiles[activation.Address]; !ok && PrecompiledContractsByzantium[activation.Address] == nil {
+			t.Errorf("%s: not a precompiled contract: %x", activation.Name, activation.Address)
+		}
+		switch activation.Address {
+		case governancePrecompileAddr:
+			if activation.Block == nil || activation.Block.Cmp(config.GovernanceBlock) != 0 {
+				t.Errorf("governance activation mismatch: have %v, want %v", activation.Block, config.GovernanceBlock)
+			}
+		case vestingPrecompileAddr, accountVerifierPrecompileAddr:
+			if activation.Block != nil {
+				t.Errorf("%s: active without its fork from %v", activation.Name, activation.Block)
+			}
+		}
+	}
+}
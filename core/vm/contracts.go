@@ -24,6 +24,7 @@ import (
 
 	"crypto/ecdsa"
 	"strings"
+	"time"
 
 	"github.com/wanchain/go-wanchain/accounts/abi"
 	"github.com/wanchain/go-wanchain/common"
@@ -40,10 +41,34 @@ import (
 // RunPrecompiledContract runs and evaluates the output of a precompiled contract.
 func RunPrecompiledContract(p PrecompiledContract, input []byte, contract *Contract, evm *EVM) (ret []byte, err error) {
 	gas := p.RequiredGas(input)
-	if contract.UseGas(gas) {
-		return p.Run(input, contract, evm)
+	switch p.(type) {
+	case *wanCoinSC, *wanchainStampSC:
+		gas = PrivacyGas(evm.StateDB, evm.chainRules, gas)
 	}
-	return nil, ErrOutOfGas
+	if !contract.UseGas(gas) {
+		return nil, ErrOutOfGas
+	}
+	if profiler := evm.vmConfig.Profiler; profiler != nil {
+		start := time.Now()
+		defer func() { profiler.addPrecompile(contract.Address(), input, time.Since(start), gas) }()
+	}
+	ret, err = p.Run(input, contract, evm)
+	if err != nil || !evm.ChainConfig().IsReturnLimit(evm.BlockNumber) {
+		return ret, err
+	}
+	// The output of the Ethereum precompiles is bounded by their input, the
+	// others may read arbitrary amounts of state
+	switch p.(type) {
+	case *ecrecover, *sha256hash, *ripemd160hash, *dataCopy, *bigModExp, *bn256Add, *bn256ScalarMul, *bn256Pairing:
+		return ret, nil
+	}
+	if uint64(len(ret)) > params.MaxPrecompileReturnSize {
+		return nil, ErrReturnDataTooLarge
+	}
+	if !contract.UseGas(toWordSize(uint64(len(ret))) * params.PrecompileReturnWordGas) {
+		return nil, ErrOutOfGas
+	}
+	return ret, nil
 }
 
 // ECRECOVER implemented as a native contract.
@@ -79,7 +104,7 @@ func (c *ecrecover) Run(input []byte, contract *Contract, evm *EVM) ([]byte, err
 	return common.LeftPadBytes(crypto.Keccak256(pubKey[1:])[12:], 32), nil
 }
 
-func (c *ecrecover) ValidTx(stateDB StateDB, signer types.Signer, tx *types.Transaction) error {
+func (c *ecrecover) ValidTx(stateDB StateDB, rules params.Rules, signer types.Signer, tx *types.Transaction) error {
 	return nil
 }
 
@@ -98,7 +123,7 @@ func (c *sha256hash) Run(input []byte, contract *Contract, evm *EVM) ([]byte, er
 	return h[:], nil
 }
 
-func (c *sha256hash) ValidTx(stateDB StateDB, signer types.Signer, tx *types.Transaction) error {
+func (c *sha256hash) ValidTx(stateDB StateDB, rules params.Rules, signer types.Signer, tx *types.Transaction) error {
 	return nil
 }
 
@@ -118,7 +143,7 @@ func (c *ripemd160hash) Run(input []byte, contract *Contract, evm *EVM) ([]byte,
 	return common.LeftPadBytes(ripemd.Sum(nil), 32), nil
 }
 
-func (c *ripemd160hash) ValidTx(stateDB StateDB, signer types.Signer, tx *types.Transaction) error {
+func (c *ripemd160hash) ValidTx(stateDB StateDB, rules params.Rules, signer types.Signer, tx *types.Transaction) error {
 	return nil
 }
 
@@ -136,7 +161,7 @@ func (c *dataCopy) Run(in []byte, contract *Contract, evm *EVM) ([]byte, error)
 	return in, nil
 }
 
-func (c *dataCopy) ValidTx(stateDB StateDB, signer types.Signer, tx *types.Transaction) error {
+func (c *dataCopy) ValidTx(stateDB StateDB, rules params.Rules, signer types.Signer, tx *types.Transaction) error {
 	return nil
 }
 
@@ -245,7 +270,7 @@ func (c *bigModExp) Run(input []byte, contract *Contract, evm *EVM) ([]byte, err
 	return common.LeftPadBytes(base.Exp(base, exp, mod).Bytes(), int(modLen)), nil
 }
 
-func (c *bigModExp) ValidTx(stateDB StateDB, signer types.Signer, tx *types.Transaction) error {
+func (c *bigModExp) ValidTx(stateDB StateDB, rules params.Rules, signer types.Signer, tx *types.Transaction) error {
 	return nil
 }
 
@@ -260,6 +285,8 @@ var (
 
 	// invalid ring signed info
 	ErrInvalidRingSigned = errors.New("invalid ring signed info")
+
+	ErrRingSignedDataTooLarge = errors.New("ring signed data too large")
 )
 
 // newCurvePoint unmarshals a binary blob into a bn256 elliptic curve point,
@@ -313,7 +340,7 @@ func (c *bn256Add) Run(input []byte, contract *Contract, evm *EVM) ([]byte, erro
 	return res.Marshal(), nil
 }
 
-func (c *bn256Add) ValidTx(stateDB StateDB, signer types.Signer, tx *types.Transaction) error {
+func (c *bn256Add) ValidTx(stateDB StateDB, rules params.Rules, signer types.Signer, tx *types.Transaction) error {
 	return nil
 }
 
@@ -335,7 +362,7 @@ func (c *bn256ScalarMul) Run(input []byte, contract *Contract, evm *EVM) ([]byte
 	return res.Marshal(), nil
 }
 
-func (c *bn256ScalarMul) ValidTx(stateDB StateDB, signer types.Signer, tx *types.Transaction) error {
+func (c *bn256ScalarMul) ValidTx(stateDB StateDB, rules params.Rules, signer types.Signer, tx *types.Transaction) error {
 	return nil
 }
 
@@ -387,7 +414,7 @@ func (c *bn256Pairing) Run(input []byte, contract *Contract, evm *EVM) ([]byte,
 	return false32Byte, nil
 }
 
-func (c *bn256Pairing) ValidTx(stateDB StateDB, signer types.Signer, tx *types.Transaction) error {
+func (c *bn256Pairing) ValidTx(stateDB StateDB, rules params.Rules, signer types.Signer, tx *types.Transaction) error {
 	return nil
 }
 
@@ -395,12 +422,13 @@ func (c *bn256Pairing) ValidTx(stateDB StateDB, signer types.Signer, tx *types.T
 
 var (
 	coinSCDefinition = `
-	[{"constant": false,"type": "function","stateMutability": "nonpayable","inputs": [{"name": "OtaAddr","type":"string"},{"name": "Value","type": "uint256"}],"name": "buyCoinNote","outputs": [{"name": "OtaAddr","type":"string"},{"name": "Value","type": "uint256"}]},{"constant": false,"type": "function","inputs": [{"name":"RingSignedData","type": "string"},{"name": "Value","type": "uint256"}],"name": "refundCoin","outputs": [{"name": "RingSignedData","type": "string"},{"name": "Value","type": "uint256"}]},{"constant": false,"type": "function","stateMutability": "nonpayable","inputs": [],"name": "getCoins","outputs": [{"name":"Value","type": "uint256"}]}]`
+	[{"constant": false,"type": "function","stateMutability": "nonpayable","inputs": [{"name": "OtaAddr","type":"string"},{"name": "Value","type": "uint256"}],"name": "buyCoinNote","outputs": [{"name": "OtaAddr","type":"string"},{"name": "Value","type": "uint256"}]},{"constant": false,"type": "function","inputs": [{"name":"RingSignedData","type": "string"},{"name": "Value","type": "uint256"}],"name": "refundCoin","outputs": [{"name": "RingSignedData","type": "string"},{"name": "Value","type": "uint256"}]},{"constant": false,"type": "function","stateMutability": "nonpayable","inputs": [],"name": "getCoins","outputs": [{"name":"Value","type": "uint256"}]},{"constant": false,"type": "function","inputs": [{"name": "RingMembers","type": "string"}],"name": "commitRingMembers","outputs": [{"name": "RingMembers","type": "string"}]},{"constant": false,"type": "function","inputs": [{"name": "RingSignature","type": "string"},{"name": "Value","type": "uint256"}],"name": "revealRefundCoin","outputs": [{"name": "RingSignature","type": "string"},{"name": "Value","type": "uint256"}]}]`
 
 	stampSCDefinition = `[{"constant": false,"type": "function","stateMutability": "nonpayable","inputs": [{"name":"OtaAddr","type": "string"},{"name": "Value","type": "uint256"}],"name": "buyStamp","outputs": [{"name": "OtaAddr","type": "string"},{"name": "Value","type": "uint256"}]},{"constant": false,"type": "function","inputs": [{"name": "RingSignedData","type": "string"},{"name": "Value","type": "uint256"}],"name": "refundCoin","outputs": [{"name": "RingSignedData","type": "string"},{"name": "Value","type": "uint256"}]},{"constant": false,"type": "function","stateMutability": "nonpayable","inputs": [],"name": "getCoins","outputs": [{"name": "Value","type": "uint256"}]}]`
 
-	coinAbi, errCoinSCInit               = abi.JSON(strings.NewReader(coinSCDefinition))
-	buyIdArr, refundIdArr, getCoinsIdArr [4]byte
+	coinAbi, errCoinSCInit                    = abi.JSON(strings.NewReader(coinSCDefinition))
+	buyIdArr, refundIdArr, getCoinsIdArr      [4]byte
+	commitRingMembersIdArr, revealRefundIdArr [4]byte
 
 	stampAbi, errStampSCInit = abi.JSON(strings.NewReader(stampSCDefinition))
 	stBuyId                  [4]byte
@@ -425,6 +453,8 @@ var (
 
 	ErrOTAReused = errors.New("OTA is reused")
 
+	ErrRingTooSmall = errors.New("ring signature has too few members")
+
 	StampValueSet   = make(map[string]string, 5)
 	WanCoinValueSet = make(map[string]string, 10)
 )
@@ -465,6 +495,8 @@ func init() {
 	copy(buyIdArr[:], coinAbi.Methods["buyCoinNote"].Id())
 	copy(refundIdArr[:], coinAbi.Methods["refundCoin"].Id())
 	copy(getCoinsIdArr[:], coinAbi.Methods["getCoins"].Id())
+	copy(commitRingMembersIdArr[:], coinAbi.Methods["commitRingMembers"].Id())
+	copy(revealRefundIdArr[:], coinAbi.Methods["revealRefundCoin"].Id())
 
 	copy(stBuyId[:], stampAbi.Methods["buyStamp"].Id())
 
@@ -553,7 +585,7 @@ func (c *wanchainStampSC) Run(in []byte, contract *Contract, env *EVM) ([]byte,
 	return nil, errMethodId
 }
 
-func (c *wanchainStampSC) ValidTx(stateDB StateDB, signer types.Signer, tx *types.Transaction) error {
+func (c *wanchainStampSC) ValidTx(stateDB StateDB, rules params.Rules, signer types.Signer, tx *types.Transaction) error {
 	if stateDB == nil || signer == nil || tx == nil {
 		return errParameters
 	}
@@ -566,14 +598,14 @@ func (c *wanchainStampSC) ValidTx(stateDB StateDB, signer types.Signer, tx *type
 	var methodId [4]byte
 	copy(methodId[:], payload[:4])
 	if methodId == stBuyId {
-		_, err := c.ValidBuyStampReq(stateDB, payload[4:], tx.Value())
+		_, err := c.ValidBuyStampReq(stateDB, rules, payload[4:], tx.Value())
 		return err
 	}
 
 	return errParameters
 }
 
-func (c *wanchainStampSC) ValidBuyStampReq(stateDB StateDB, payload []byte, value *big.Int) (otaAddr []byte, err error) {
+func (c *wanchainStampSC) ValidBuyStampReq(stateDB StateDB, rules params.Rules, payload []byte, value *big.Int) (otaAddr []byte, err error) {
 	if stateDB == nil || len(payload) == 0 || value == nil {
 		return nil, errors.New("unknown error")
 	}
@@ -593,7 +625,7 @@ func (c *wanchainStampSC) ValidBuyStampReq(stateDB StateDB, payload []byte, valu
 	}
 
 	_, ok := StampValueSet[StampInput.Value.Text(16)]
-	if !ok {
+	if !ok || StampInput.Value.Cmp(MinStampValue(stateDB, rules)) < 0 {
 		return nil, errStampValue
 	}
 
@@ -616,7 +648,7 @@ func (c *wanchainStampSC) ValidBuyStampReq(stateDB StateDB, payload []byte, valu
 }
 
 func (c *wanchainStampSC) buyStamp(in []byte, contract *Contract, evm *EVM) ([]byte, error) {
-	wanAddr, err := c.ValidBuyStampReq(evm.StateDB, in, contract.value)
+	wanAddr, err := c.ValidBuyStampReq(evm.StateDB, evm.chainRules, in, contract.value)
 	if err != nil {
 		return nil, err
 	}
@@ -672,6 +704,10 @@ func (c *wanCoinSC) RequiredGas(input []byte) uint64 {
 		// ringsign compute gas + ota image key store setting gas
 		return ringSigDiffRequiredGas + params.SstoreSetGas
 
+	} else if methodIdArr == commitRingMembersIdArr {
+		return commitRingMembersGas(input[4:])
+	} else if methodIdArr == revealRefundIdArr {
+		return revealRefundGas(input[4:])
 	} else {
 		// ota balance store gas + ota wanaddr store gas
 		return params.SstoreSetGas * 2
@@ -691,12 +727,16 @@ func (c *wanCoinSC) Run(in []byte, contract *Contract, evm *EVM) ([]byte, error)
 		return c.buyCoin(in[4:], contract, evm)
 	} else if methodIdArr == refundIdArr {
 		return c.refund(in[4:], contract, evm)
+	} else if methodIdArr == commitRingMembersIdArr && evm.chainRules.IsRingLimit {
+		return c.commitRingMembers(in[4:], contract, evm)
+	} else if methodIdArr == revealRefundIdArr && evm.chainRules.IsRingLimit {
+		return c.revealRefund(in[4:], contract, evm)
 	}
 
 	return nil, errMethodId
 }
 
-func (c *wanCoinSC) ValidTx(stateDB StateDB, signer types.Signer, tx *types.Transaction) error {
+func (c *wanCoinSC) ValidTx(stateDB StateDB, rules params.Rules, signer types.Signer, tx *types.Transaction) error {
 	if stateDB == nil || signer == nil || tx == nil {
 		return errParameters
 	}
@@ -719,7 +759,20 @@ func (c *wanCoinSC) ValidTx(stateDB StateDB, signer types.Signer, tx *types.Tran
 			return err
 		}
 
-		_, _, err = c.ValidRefundReq(stateDB, payload[4:], from.Bytes())
+		_, _, err = c.ValidRefundReq(stateDB, rules, payload[4:], from.Bytes())
+		return err
+
+	} else if methodIdArr == commitRingMembersIdArr && rules.IsRingLimit {
+		_, err := c.ValidCommitRingMembersReq(payload[4:])
+		return err
+
+	} else if methodIdArr == revealRefundIdArr && rules.IsRingLimit {
+		from, err := types.Sender(signer, tx)
+		if err != nil {
+			return err
+		}
+
+		_, _, err = c.ValidRevealRefundReq(stateDB, rules, payload[4:], from)
 		return err
 	}
 
@@ -799,7 +852,7 @@ func (c *wanCoinSC) buyCoin(in []byte, contract *Contract, evm *EVM) ([]byte, er
 	}
 }
 
-func (c *wanCoinSC) ValidRefundReq(stateDB StateDB, payload []byte, from []byte) (image []byte, value *big.Int, err error) {
+func (c *wanCoinSC) ValidRefundReq(stateDB StateDB, rules params.Rules, payload []byte, from []byte) (image []byte, value *big.Int, err error) {
 	if stateDB == nil || len(payload) == 0 || len(from) == 0 {
 		return nil, nil, errors.New("unknown error")
 	}
@@ -814,12 +867,22 @@ func (c *wanCoinSC) ValidRefundReq(stateDB StateDB, payload []byte, from []byte)
 		return nil, nil, errRefundCoin
 	}
 
-	ringSignInfo, err := FetchRingSignInfo(stateDB, from, RefundStruct.RingSignedData)
+	if rules.IsRingLimit && uint64(len(RefundStruct.RingSignedData)) > params.MaxRingSignedDataSize {
+		return nil, nil, ErrRingSignedDataTooLarge
+	}
+
+	return validRefund(stateDB, rules, from, RefundStruct.RingSignedData, RefundStruct.Value)
+}
+
+// validRefund checks the refund of value to from, authorized by the ring
+// signed data.
+func validRefund(stateDB StateDB, rules params.Rules, from []byte, ringSignedData string, value *big.Int) (image []byte, refund *big.Int, err error) {
+	ringSignInfo, err := FetchRingSignInfo(stateDB, rules, from, ringSignedData)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	if ringSignInfo.OTABalance.Cmp(RefundStruct.Value) != 0 {
+	if ringSignInfo.OTABalance.Cmp(value) != 0 {
 		return nil, nil, ErrMismatchedValue
 	}
 
@@ -833,12 +896,12 @@ func (c *wanCoinSC) ValidRefundReq(stateDB StateDB, payload []byte, from []byte)
 		return nil, nil, ErrOTAReused
 	}
 
-	return kix, RefundStruct.Value, nil
+	return kix, value, nil
 
 }
 
 func (c *wanCoinSC) refund(all []byte, contract *Contract, evm *EVM) ([]byte, error) {
-	kix, value, err := c.ValidRefundReq(evm.StateDB, all, contract.CallerAddress.Bytes())
+	kix, value, err := c.ValidRefundReq(evm.StateDB, evm.chainRules, all, contract.CallerAddress.Bytes())
 	if err != nil {
 		return nil, err
 	}
@@ -847,6 +910,7 @@ func (c *wanCoinSC) refund(all []byte, contract *Contract, evm *EVM) ([]byte, er
 	if err != nil {
 		return nil, err
 	}
+	evm.recordKeyImage(kix)
 
 	addrSrc := contract.CallerAddress
 	evm.StateDB.AddBalance(addrSrc, value)
@@ -854,6 +918,45 @@ func (c *wanCoinSC) refund(all []byte, contract *Contract, evm *EVM) ([]byte, er
 
 }
 
+// RefundRingSignedData extracts the ring signed data carried by a refundCoin
+// call to the wancoin contract. ok is false if the call is not a refund.
+func RefundRingSignedData(to *common.Address, payload []byte) (data string, ok bool) {
+	if to == nil || *to != wanCoinPrecompileAddr || len(payload) < 4 {
+		return "", false
+	}
+
+	var methodIdArr [4]byte
+	copy(methodIdArr[:], payload[:4])
+	if methodIdArr != refundIdArr {
+		return "", false
+	}
+
+	var RefundStruct struct {
+		RingSignedData string
+		Value          *big.Int
+	}
+	if err := coinAbi.Unpack(&RefundStruct, "refundCoin", payload[4:]); err != nil {
+		return "", false
+	}
+
+	return RefundStruct.RingSignedData, true
+}
+
+// IsRingSignedCall reports whether a call to the given address with payload
+// verifies a ring signature: a refundCoin or a revealRefundCoin call to the
+// wancoin contract.
+func IsRingSignedCall(to *common.Address, payload []byte) bool {
+	if _, ok := RefundRingSignedData(to, payload); ok {
+		return true
+	}
+	if to == nil || *to != wanCoinPrecompileAddr || len(payload) < 4 {
+		return false
+	}
+	var methodIdArr [4]byte
+	copy(methodIdArr[:], payload[:4])
+	return methodIdArr == revealRefundIdArr
+}
+
 func DecodeRingSignOut(s string) (error, []*ecdsa.PublicKey, *ecdsa.PublicKey, []*big.Int, []*big.Int) {
 	ss := strings.Split(s, "+")
 	if len(ss) < 4 {
@@ -919,11 +1022,15 @@ type RingSignInfo struct {
 	OTABalance *big.Int
 }
 
-func FetchRingSignInfo(stateDB StateDB, hashInput []byte, ringSignedStr string) (info *RingSignInfo, err error) {
+func FetchRingSignInfo(stateDB StateDB, rules params.Rules, hashInput []byte, ringSignedStr string) (info *RingSignInfo, err error) {
 	if stateDB == nil || hashInput == nil {
 		return nil, errParameters
 	}
 
+	if rules.IsRingLimit && uint64(len(ringSignedStr)) > params.MaxChunkedRingSignedDataSize {
+		return nil, ErrRingSignedDataTooLarge
+	}
+
 	infoTmp := new(RingSignInfo)
 
 	err, infoTmp.PublicKeys, infoTmp.KeyImage, infoTmp.W_Random, infoTmp.Q_Random = DecodeRingSignOut(ringSignedStr)
@@ -931,6 +1038,10 @@ func FetchRingSignInfo(stateDB StateDB, hashInput []byte, ringSignedStr string)
 		return nil, err
 	}
 
+	if rules.IsGovernance && uint64(len(infoTmp.PublicKeys)) < MinRingSize(stateDB, rules) {
+		return nil, ErrRingTooSmall
+	}
+
 	otaAXs := make([][]byte, 0, len(infoTmp.PublicKeys))
 	for i := 0; i < len(infoTmp.PublicKeys); i++ {
 		pkBytes := crypto.FromECDSAPub(infoTmp.PublicKeys[i])
@@ -27,4 +27,5 @@ var (
 	ErrContractAddressCollision = errors.New("contract address collision")
 	ErrInvalidGasPrice          = errors.New("invalid gas price")
 	ErrInvalidPrivacyValue          = errors.New("invalid privacy transaction value")
+	ErrReturnDataTooLarge       = errors.New("precompile return data too large")
 )
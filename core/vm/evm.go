@@ -42,13 +42,7 @@ type (
 // run runs the given contract and takes care of running precompiles with a fallback to the byte code interpreter.
 func run(evm *EVM, snapshot int, contract *Contract, input []byte) ([]byte, error) {
 	if contract.CodeAddr != nil {
-		//precompiles := PrecompiledContractsHomestead
-
-		//if evm.ChainConfig().IsByzantium(evm.BlockNumber) {
-		precompiles := PrecompiledContractsByzantium
-		//}
-
-		if p := precompiles[*contract.CodeAddr]; p != nil {
+		if p := evm.precompiles[*contract.CodeAddr]; p != nil {
 			return RunPrecompiledContract(p, input, contract, evm)
 		}
 	}
@@ -99,6 +93,8 @@ type EVM struct {
 	chainConfig *params.ChainConfig
 	// chain rules contains the chain rules for the current epoch
 	chainRules params.Rules
+	// precompiled contracts active at the block being executed
+	precompiles map[common.Address]PrecompiledContract
 	// virtual machine configuration options used to initialise the
 	// evm.
 	vmConfig Config
@@ -108,6 +104,9 @@ type EVM struct {
 	// abort is used to abort the EVM calling operations
 	// NOTE: must be set atomically
 	abort int32
+	// privacy resources consumed by the transaction being executed
+	stampUsed      *big.Int
+	spentKeyImages [][]byte
 }
 
 // NewEVM retutrns a new EVM . The returned EVM is not thread safe and should
@@ -119,6 +118,7 @@ func NewEVM(ctx Context, statedb StateDB, chainConfig *params.ChainConfig, vmCon
 		vmConfig:    vmConfig,
 		chainConfig: chainConfig,
 		chainRules:  chainConfig.Rules(ctx.BlockNumber),
+		precompiles: PrecompiledContractsFor(chainConfig, ctx.BlockNumber),
 	}
 
 	evm.interpreter = NewInterpreter(evm, vmConfig)
@@ -154,17 +154,8 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 		snapshot = evm.StateDB.Snapshot()
 	)
 
-	var precompiles map[common.Address]PrecompiledContract
 	if !evm.StateDB.Exist(addr) {
-
-		//precompiles = PrecompiledContractsHomestead
-		//if evm.ChainConfig().IsByzantium(evm.BlockNumber) {
-
-		precompiles = PrecompiledContractsByzantium
-
-		//}
-
-		if precompiles[addr] == nil /*&& evm.ChainConfig().IsEIP158(evm.BlockNumber)*/ && value.Sign() == 0 {
+		if evm.precompiles[addr] == nil /*&& evm.ChainConfig().IsEIP158(evm.BlockNumber)*/ && value.Sign() == 0 {
 			return nil, gas, nil
 		}
 
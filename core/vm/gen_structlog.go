@@ -21,6 +21,7 @@ func (s StructLog) MarshalJSON() ([]byte, error) {
 		MemorySize int                         `json:"memSize"`
 		Stack      []*math.HexOrDecimal256     `json:"stack"`
 		Storage    map[common.Hash]common.Hash `json:"-"`
+		Contract   common.Address              `json:"-"`
 		Depth      int                         `json:"depth"`
 		Err        error                       `json:"error"`
 		OpName     string                      `json:"opName"`
@@ -39,6 +40,7 @@ func (s StructLog) MarshalJSON() ([]byte, error) {
 		}
 	}
 	enc.Storage = s.Storage
+	enc.Contract = s.Contract
 	enc.Depth = s.Depth
 	enc.Err = s.Err
 	enc.OpName = s.OpName()
@@ -55,6 +57,7 @@ func (s *StructLog) UnmarshalJSON(input []byte) error {
 		MemorySize *int                        `json:"memSize"`
 		Stack      []*math.HexOrDecimal256     `json:"stack"`
 		Storage    map[common.Hash]common.Hash `json:"-"`
+		Contract   *common.Address             `json:"-"`
 		Depth      *int                        `json:"depth"`
 		Err        *error                      `json:"error"`
 	}
@@ -89,6 +92,9 @@ func (s *StructLog) UnmarshalJSON(input []byte) error {
 	if dec.Storage != nil {
 		s.Storage = dec.Storage
 	}
+	if dec.Contract != nil {
+		s.Contract = *dec.Contract
+	}
 	if dec.Depth != nil {
 		s.Depth = *dec.Depth
 	}
@@ -0,0 +1,339 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package vm
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/wanchain/go-wanchain/accounts/abi"
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/common/math"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/crypto"
+	"github.com/wanchain/go-wanchain/params"
+)
+
+// Governed runtime parameters. An unset parameter takes its protocol default.
+const (
+	GovMinRingSize       = iota // Minimum number of members of a ring signature
+	GovMinStampValue            // Minimum value of the stamps bought, in wei
+	GovPrivacyGasPercent        // Percentage applied to the gas of the privacy precompiles
+	govParamCount
+)
+
+const maxPrivacyGasPercent = 1000
+
+var (
+	governanceSCDefinition = `[{"constant":false,"type":"function","inputs":[{"name":"Param","type":"uint256"},{"name":"Value","type":"uint256"}],"name":"propose","outputs":[{"name":"Param","type":"uint256"},{"name":"Value","type":"uint256"}]},{"constant":false,"type":"function","inputs":[{"name":"Id","type":"uint256"}],"name":"approve","outputs":[{"name":"Id","type":"uint256"}]},{"constant":false,"type":"function","inputs":[{"name":"Id","type":"uint256"}],"name":"execute","outputs":[{"name":"Id","type":"uint256"}]},{"constant":true,"type":"function","inputs":[{"name":"Param","type":"uint256"}],"name":"getParam","outputs":[{"name":"Param","type":"uint256"}]},{"constant":true,"type":"function","inputs":[{"name":"Id","type":"uint256"}],"name":"proposal","outputs":[{"name":"Id","type":"uint256"}]}]`
+
+	governanceAbi, errGovernanceSCInit                                     = abi.JSON(strings.NewReader(governanceSCDefinition))
+	govProposeId, govApproveId, govExecuteId, govGetParamId, govProposalId [4]byte
+
+	ErrGovernanceInactive  = errors.New("governance is not active")
+	ErrNotGovernanceSigner = errors.New("caller is not a governance signer")
+	ErrUnknownProposal     = errors.New("unknown governance proposal")
+	ErrProposalNotReady    = errors.New("governance proposal is not executable yet")
+	ErrInvalidGovParam     = errors.New("invalid governed parameter or value")
+)
+
+// Proposal fields, stored under keccak256("proposal", id, field)
+const (
+	proposalParam = iota
+	proposalValue
+	proposalApprovals // Bitmap of the indices of the approving signers
+	proposalEta       // Block the proposal can be executed at, zero until approved
+	proposalExecuted
+)
+
+func init() {
+	if errGovernanceSCInit != nil {
+		panic("err in governance sc initialize")
+	}
+	copy(govProposeId[:], governanceAbi.Methods["propose"].Id())
+	copy(govApproveId[:], governanceAbi.Methods["approve"].Id())
+	copy(govExecuteId[:], governanceAbi.Methods["execute"].Id())
+	copy(govGetParamId[:], governanceAbi.Methods["getParam"].Id())
+	copy(govProposalId[:], governanceAbi.Methods["proposal"].Id())
+}
+
+// governanceSC (GOVERNANCE) lets the signers configured in the chain config
+// change the governed runtime parameters:
+//
+//	function propose(uint256 param, uint256 value) returns (uint256 id)
+//	function approve(uint256 id)
+//	function execute(uint256 id)
+//	function getParam(uint256 param) constant returns (uint256 value)
+//	function proposal(uint256 id) constant returns (uint256 param, uint256 value, uint256 approvals, uint256 eta, bool executed)
+//
+// Like for the other privacy contracts, the ABI definition mirrors the inputs
+// as outputs for decoding, the return values are encoded by hand. A proposal
+// counts as approved by its proposer. Once approved by the threshold of signers
+// it is timelocked, and anyone can execute it after the timelock expired. The
+// parameters are read from the contract storage when executing the privacy
+// transactions, from the governance fork on.
+type governanceSC struct{}
+
+func (c *governanceSC) RequiredGas(input []byte) uint64 {
+	if len(input) < 4 {
+		return 0
+	}
+	var methodId [4]byte
+	copy(methodId[:], input[:4])
+
+	switch methodId {
+	case govProposeId:
+		return params.SstoreSetGas * 4
+	case govApproveId, govExecuteId:
+		return params.SstoreSetGas
+	}
+	return params.WanParamsGas
+}
+
+func (c *governanceSC) Run(in []byte, contract *Contract, evm *EVM) ([]byte, error) {
+	config := evm.ChainConfig()
+	if !config.IsGovernance(evm.BlockNumber) || config.Governance == nil {
+		return nil, ErrGovernanceInactive
+	}
+	if len(in) < 4 {
+		return nil, errParameters
+	}
+	var methodId [4]byte
+	copy(methodId[:], in[:4])
+
+	switch methodId {
+	case govGetParamId:
+		var param *big.Int
+		if err := governanceAbi.Unpack(&param, "getParam", in[4:]); err != nil || !param.IsUint64() || param.Uint64() >= govParamCount {
+			return nil, ErrInvalidGovParam
+		}
+		return math.PaddedBigBytes(governedParam(evm.StateDB, param.Uint64()), 32), nil
+
+	case govProposalId:
+		id, err := c.proposalId(evm.StateDB, "proposal", in[4:])
+		if err != nil {
+			return nil, err
+		}
+		out := make([]byte, 0, 5*32)
+		for field := proposalParam; field <= proposalExecuted; field++ {
+			out = append(out, proposalField(evm.StateDB, id, field).Bytes()...)
+		}
+		return out, nil
+	}
+
+	if evm.interpreter.readOnly {
+		return nil, errWriteProtection
+	}
+	switch methodId {
+	case govProposeId:
+		return c.propose(in[4:], contract, evm)
+	case govApproveId:
+		return c.approve(in[4:], contract, evm)
+	case govExecuteId:
+		return c.execute(in[4:], evm)
+	}
+	return nil, errMethodId
+}
+
+func (c *governanceSC) ValidTx(stateDB StateDB, rules params.Rules, signer types.Signer, tx *types.Transaction) error {
+	if len(tx.Data()) < 4 {
+		return errParameters
+	}
+	var methodId [4]byte
+	copy(methodId[:], tx.Data()[:4])
+
+	switch methodId {
+	case govProposeId, govApproveId, govExecuteId, govGetParamId, govProposalId:
+		return nil
+	}
+	return errMethodId
+}
+
+func (c *governanceSC) propose(payload []byte, contract *Contract, evm *EVM) ([]byte, error) {
+	index, err := signerIndex(evm.ChainConfig().Governance, contract.CallerAddress)
+	if err != nil {
+		return nil, err
+	}
+	var req struct {
+		Param *big.Int
+		Value *big.Int
+	}
+	if err := governanceAbi.Unpack(&req, "propose", payload); err != nil || req.Param == nil || req.Value == nil {
+		return nil, errParameters
+	}
+	if !validGovernedValue(req.Param, req.Value) {
+		return nil, ErrInvalidGovParam
+	}
+	count := evm.StateDB.GetState(governancePrecompileAddr, proposalCountKey).Big()
+	id := new(big.Int).Add(count, common.Big1)
+	evm.StateDB.SetState(governancePrecompileAddr, proposalCountKey, common.BigToHash(id))
+
+	setProposalField(evm.StateDB, id, proposalParam, req.Param)
+	setProposalField(evm.StateDB, id, proposalValue, req.Value)
+	c.addApproval(evm, id, index)
+
+	return math.PaddedBigBytes(id, 32), nil
+}
+
+func (c *governanceSC) approve(payload []byte, contract *Contract, evm *EVM) ([]byte, error) {
+	index, err := signerIndex(evm.ChainConfig().Governance, contract.CallerAddress)
+	if err != nil {
+		return nil, err
+	}
+	id, err := c.proposalId(evm.StateDB, "approve", payload)
+	if err != nil {
+		return nil, err
+	}
+	if proposalField(evm.StateDB, id, proposalExecuted).Sign() != 0 {
+		return nil, ErrUnknownProposal
+	}
+	c.addApproval(evm, id, index)
+	return []byte{1}, nil
+}
+
+func (c *governanceSC) execute(payload []byte, evm *EVM) ([]byte, error) {
+	id, err := c.proposalId(evm.StateDB, "execute", payload)
+	if err != nil {
+		return nil, err
+	}
+	eta := proposalField(evm.StateDB, id, proposalEta)
+	if eta.Sign() == 0 || evm.BlockNumber.Cmp(eta) < 0 || proposalField(evm.StateDB, id, proposalExecuted).Sign() != 0 {
+		return nil, ErrProposalNotReady
+	}
+	param := proposalField(evm.StateDB, id, proposalParam)
+	evm.StateDB.SetState(governancePrecompileAddr, governedParamKey(param.Uint64()), common.BigToHash(proposalField(evm.StateDB, id, proposalValue)))
+	setProposalField(evm.StateDB, id, proposalExecuted, common.Big1)
+
+	return []byte{1}, nil
+}
+
+// addApproval records the approval of the signer at index, timelocking the
+// proposal once enough signers approved it.
+func (c *governanceSC) addApproval(evm *EVM, id *big.Int, index int) {
+	approvals := proposalField(evm.StateDB, id, proposalApprovals)
+	approvals.SetBit(approvals, index, 1)
+	setProposalField(evm.StateDB, id, proposalApprovals, approvals)
+
+	config := evm.ChainConfig().Governance
+	if proposalField(evm.StateDB, id, proposalEta).Sign() == 0 && uint64(popCount(approvals)) >= config.Threshold {
+		eta := new(big.Int).Add(evm.BlockNumber, new(big.Int).SetUint64(config.Timelock))
+		setProposalField(evm.StateDB, id, proposalEta, eta)
+	}
+}
+
+// proposalId decodes the proposal id argument of method, checking that the
+// proposal exists.
+func (c *governanceSC) proposalId(stateDB StateDB, method string, payload []byte) (*big.Int, error) {
+	var id *big.Int
+	if err := governanceAbi.Unpack(&id, method, payload); err != nil || id == nil {
+		return nil, errParameters
+	}
+	count := stateDB.GetState(governancePrecompileAddr, proposalCountKey).Big()
+	if id.Sign() <= 0 || id.Cmp(count) > 0 {
+		return nil, ErrUnknownProposal
+	}
+	return id, nil
+}
+
+var proposalCountKey = crypto.Keccak256Hash([]byte("proposals"))
+
+func governedParamKey(param uint64) common.Hash {
+	return crypto.Keccak256Hash([]byte("param"), new(big.Int).SetUint64(param).Bytes())
+}
+
+func proposalKey(id *big.Int, field int) common.Hash {
+	return crypto.Keccak256Hash([]byte("proposal"), common.BigToHash(id).Bytes(), []byte{byte(field)})
+}
+
+func proposalField(stateDB StateDB, id *big.Int, field int) *big.Int {
+	return stateDB.GetState(governancePrecompileAddr, proposalKey(id, field)).Big()
+}
+
+func setProposalField(stateDB StateDB, id *big.Int, field int, value *big.Int) {
+	stateDB.SetState(governancePrecompileAddr, proposalKey(id, field), common.BigToHash(value))
+}
+
+func signerIndex(config *params.GovernanceConfig, addr common.Address) (int, error) {
+	for i, signer := range config.Signers {
+		if signer == addr && i < 256 {
+			return i, nil
+		}
+	}
+	return 0, ErrNotGovernanceSigner
+}
+
+func popCount(x *big.Int) int {
+	count := 0
+	for i := 0; i < x.BitLen(); i++ {
+		count += int(x.Bit(i))
+	}
+	return count
+}
+
+// validGovernedValue checks the value proposed for a governed parameter. Zero
+// restores the protocol default.
+func validGovernedValue(param, value *big.Int) bool {
+	if !param.IsUint64() || param.Uint64() >= govParamCount || value.BitLen() > 256 {
+		return false
+	}
+	switch param.Uint64() {
+	case GovMinRingSize:
+		return value.Cmp(new(big.Int).SetUint64(params.GetOTAMixSetMaxSize)) < 0
+	case GovMinStampValue:
+		values := sortedDenominations(StampValueSet)
+		return value.Cmp(values[len(values)-1]) <= 0
+	case GovPrivacyGasPercent:
+		return value.Cmp(big.NewInt(maxPrivacyGasPercent)) <= 0
+	}
+	return true
+}
+
+// governedParam returns the value of a governed parameter, or its default if
+// it isn't set.
+func governedParam(stateDB StateDB, param uint64) *big.Int {
+	if value := stateDB.GetState(governancePrecompileAddr, governedParamKey(param)).Big(); value.Sign() != 0 {
+		return value
+	}
+	switch param {
+	case GovMinRingSize:
+		return new(big.Int).SetUint64(params.MinRingSize)
+	case GovPrivacyGasPercent:
+		return big.NewInt(100)
+	}
+	return new(big.Int)
+}
+
+// MinRingSize returns the minimum number of members of a ring signature. It's
+// only enforced from the governance fork on.
+func MinRingSize(stateDB StateDB, rules params.Rules) uint64 {
+	if !rules.IsGovernance {
+		return params.MinRingSize
+	}
+	return governedParam(stateDB, GovMinRingSize).Uint64()
+}
+
+// MinStampValue returns the minimum value of the stamps that can be bought.
+func MinStampValue(stateDB StateDB, rules params.Rules) *big.Int {
+	if !rules.IsGovernance {
+		return new(big.Int)
+	}
+	return governedParam(stateDB, GovMinStampValue)
+}
+
+// PrivacyGas applies the governed privacy gas percentage to gas.
+func PrivacyGas(stateDB StateDB, rules params.Rules, gas uint64) uint64 {
+	if !rules.IsGovernance {
+		return gas
+	}
+	percent := governedParam(stateDB, GovPrivacyGasPercent).Uint64()
+	if percent == 100 {
+		return gas
+	}
+	scaled := new(big.Int).Mul(new(big.Int).SetUint64(gas), new(big.Int).SetUint64(percent))
+	scaled.Div(scaled, big.NewInt(100))
+	if !scaled.IsUint64() {
+		return math.MaxUint64
+	}
+	return scaled.Uint64()
+}
@@ -0,0 +1,119 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core/state"
+	"github.com/wanchain/go-wanchain/ethdb"
+	"github.com/wanchain/go-wanchain/params"
+)
+
+func TestGovernanceTimelock(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+
+	signers := []common.Address{common.HexToAddress("0x01"), common.HexToAddress("0x02"), common.HexToAddress("0x03")}
+	config := *params.TestChainConfig
+	config.GovernanceBlock = big.NewInt(0)
+	config.Governance = &params.GovernanceConfig{Signers: signers, Threshold: 2, Timelock: 10}
+
+	evm := NewEVM(Context{BlockNumber: big.NewInt(100)}, statedb, &config, Config{})
+	c := &governanceSC{}
+	call := func(caller common.Address, method string, args ...interface{}) ([]byte, error) {
+		input, err := governanceAbi.Pack(method, args...)
+		if err != nil {
+			t.Fatalf("failed to pack %s: %v", method, err)
+		}
+		contract := NewContract(AccountRef(caller), AccountRef(governancePrecompileAddr), big.NewInt(0), c.RequiredGas(input))
+		return c.Run(input, contract, evm)
+	}
+	param, value := big.NewInt(GovMinRingSize), big.NewInt(5)
+	rules := config.Rules(evm.BlockNumber)
+
+	if _, err := call(common.HexToAddress("0x04"), "propose", param, value); err != ErrNotGovernanceSigner {
+		t.Fatalf("non signer proposal error mismatch: have %v, want %v", err, ErrNotGovernanceSigner)
+	}
+	if _, err := call(signers[0], "propose", big.NewInt(govParamCount), value); err != ErrInvalidGovParam {
+		t.Fatalf("unknown parameter error mismatch: have %v, want %v", err, ErrInvalidGovParam)
+	}
+	if _, err := call(signers[0], "propose", param, new(big.Int).SetUint64(params.GetOTAMixSetMaxSize)); err != ErrInvalidGovParam {
+		t.Fatalf("unreachable ring size error mismatch: have %v, want %v", err, ErrInvalidGovParam)
+	}
+	if _, err := call(signers[0], "propose", big.NewInt(GovMinStampValue), new(big.Int).Exp(big.NewInt(10), big.NewInt(20), nil)); err != ErrInvalidGovParam {
+		t.Fatalf("unreachable stamp value error mismatch: have %v, want %v", err, ErrInvalidGovParam)
+	}
+	out, err := call(signers[0], "propose", param, value)
+	if err != nil {
+		t.Fatalf("failed to propose: %v", err)
+	}
+	id := new(big.Int).SetBytes(out)
+	if id.Cmp(common.Big1) != 0 {
+		t.Fatalf("proposal id mismatch: have %v, want 1", id)
+	}
+	// A single approval doesn't reach the threshold
+	if _, err := call(signers[2], "execute", id); err != ErrProposalNotReady {
+		t.Fatalf("unapproved execution error mismatch: have %v, want %v", err, ErrProposalNotReady)
+	}
+	if _, err := call(signers[1], "approve", id); err != nil {
+		t.Fatalf("failed to approve: %v", err)
+	}
+	evm.BlockNumber = big.NewInt(109)
+	if _, err := call(signers[2], "execute", id); err != ErrProposalNotReady {
+		t.Fatalf("timelocked execution error mismatch: have %v, want %v", err, ErrProposalNotReady)
+	}
+	if MinRingSize(statedb, rules) != params.MinRingSize {
+		t.Fatalf("parameter changed before execution")
+	}
+	evm.BlockNumber = big.NewInt(110)
+	if _, err := call(common.HexToAddress("0x04"), "execute", id); err != nil {
+		t.Fatalf("failed to execute: %v", err)
+	}
+	if size := MinRingSize(statedb, rules); size != 5 {
+		t.Errorf("min ring size mismatch: have %d, want 5", size)
+	}
+	if out, _ := call(signers[0], "getParam", param); new(big.Int).SetBytes(out).Cmp(value) != 0 {
+		t.Errorf("getParam mismatch: have %x, want %v", out, value)
+	}
+	if _, err := call(signers[2], "execute", id); err != ErrProposalNotReady {
+		t.Errorf("second execution error mismatch: have %v, want %v", err, ErrProposalNotReady)
+	}
+	if _, err := call(signers[0], "approve", big.NewInt(2)); err != ErrUnknownProposal {
+		t.Errorf("unknown proposal error mismatch: have %v, want %v", err, ErrUnknownProposal)
+	}
+
+	// Governed parameters apply to the privacy contracts
+	statedb.SetState(governancePrecompileAddr, governedParamKey(GovPrivacyGasPercent), common.BigToHash(big.NewInt(150)))
+	if gas := PrivacyGas(statedb, rules, 1000); gas != 1500 {
+		t.Errorf("privacy gas mismatch: have %d, want 1500", gas)
+	}
+	min, _ := new(big.Int).SetString(WanStampdot03, 10)
+	statedb.SetState(governancePrecompileAddr, governedParamKey(GovMinStampValue), common.BigToHash(min))
+	if values := stampDenominations(statedb, rules); len(values) != 5 || values[0].Cmp(min) != 0 {
+		t.Errorf("stamp denominations mismatch: %v", values)
+	}
+	stamp, _ := new(big.Int).SetString(WanStampdot009, 10)
+	payload, _ := stampAbi.Pack("buyStamp", "0x00", stamp)
+	if _, err := (&wanchainStampSC{}).ValidBuyStampReq(statedb, rules, payload[4:], stamp); err != errStampValue {
+		t.Errorf("stamp below minimum error mismatch: have %v, want %v", err, errStampValue)
+	}
+
+	// The contract is inactive and the parameters are not enforced before the fork
+	config.GovernanceBlock = big.NewInt(1000)
+	rules = config.Rules(evm.BlockNumber)
+	if size := MinRingSize(statedb, rules); size != params.MinRingSize {
+		t.Errorf("pre-fork min ring size mismatch: have %d, want %d", size, params.MinRingSize)
+	}
+	if gas := PrivacyGas(statedb, rules, 1000); gas != 1000 {
+		t.Errorf("pre-fork privacy gas mismatch: have %d, want 1000", gas)
+	}
+	if _, err := (&wanchainStampSC{}).ValidBuyStampReq(statedb, rules, payload[4:], stamp); err == errStampValue {
+		t.Errorf("pre-fork stamp below minimum rejected")
+	}
+	if _, err := call(signers[0], "getParam", param); err != ErrGovernanceInactive {
+		t.Errorf("pre-fork error mismatch: have %v, want %v", err, ErrGovernanceInactive)
+	}
+}
@@ -19,6 +19,7 @@ package vm
 import (
 	"fmt"
 	"sync/atomic"
+	"time"
 
 	"github.com/wanchain/go-wanchain/common"
 	"github.com/wanchain/go-wanchain/common/math"
@@ -43,6 +44,12 @@ type Config struct {
 	DisableGasMetering bool
 	// Enable recording of SHA3/keccak preimages
 	EnablePreimageRecording bool
+	// Profiler aggregates the execution time of the opcodes and
+	// precompiled contracts, nil disables profiling
+	Profiler *Profiler
+	// ParallelWorkers is the number of workers executing the transactions of
+	// the imported blocks concurrently (experimental), 0 or 1 disables it
+	ParallelWorkers int
 	// JumpTable contains the EVM instruction table. This
 	// may be left uninitialised and will be set to the default
 	// table.
@@ -224,7 +231,14 @@ func (in *Interpreter) Run(snapshot int, contract *Contract, input []byte) (ret
 		}
 
 		// execute the operation
+		var start time.Time
+		if in.cfg.Profiler != nil {
+			start = time.Now()
+		}
 		res, err := operation.execute(&pc, in.evm, contract, mem, stack)
+		if in.cfg.Profiler != nil {
+			in.cfg.Profiler.addOp(op, time.Since(start), cost)
+		}
 		// verifyPool is a build flag. Pool verification makes sure the integrity
 		// of the integer pool by comparing values to a default value.
 		if verifyPool {
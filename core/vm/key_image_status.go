@@ -0,0 +1,91 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package vm
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/wanchain/go-wanchain/accounts/abi"
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/common/math"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/params"
+)
+
+var (
+	keyImageSCDefinition = `[{"constant":true,"type":"function","inputs":[{"name":"KeyImage","type":"bytes"}],"name":"keyImageStatus","outputs":[{"name":"Spent","type":"bool"},{"name":"Value","type":"uint256"}]}]`
+
+	keyImageAbi, errKeyImageSCInit = abi.JSON(strings.NewReader(keyImageSCDefinition))
+	keyImageStatusId               [4]byte
+)
+
+func init() {
+	if errKeyImageSCInit != nil {
+		panic("err in key image sc initialize")
+	}
+	copy(keyImageStatusId[:], keyImageAbi.Methods["keyImageStatus"].Id())
+}
+
+// keyImageSC lets contracts check whether a key image was spent, e.g. to
+// release escrowed funds once a given private payment has been executed:
+//
+//	function keyImageStatus(bytes keyImage) constant returns (bool spent, uint256 value)
+//
+// The key image is the uncompressed 65 byte point included in the ring
+// signature, value is the OTA balance it spent. The lookup is charged as a
+// storage read.
+type keyImageSC struct{}
+
+func (c *keyImageSC) RequiredGas(input []byte) uint64 {
+	return params.KeyImageGas
+}
+
+func (c *keyImageSC) Run(in []byte, contract *Contract, evm *EVM) ([]byte, error) {
+	if len(in) < 4 {
+		return nil, errParameters
+	}
+
+	var methodId [4]byte
+	copy(methodId[:], in[:4])
+	if methodId != keyImageStatusId {
+		return nil, errMethodId
+	}
+
+	keyImage, err := unpackBytesArg(in[4:])
+	if err != nil || len(keyImage) != 65 {
+		return nil, errParameters
+	}
+	spent, value, err := CheckOTAImageExist(evm.StateDB, keyImage)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]byte, 64)
+	if spent {
+		ret[31] = 1
+		copy(ret[32:], math.PaddedBigBytes(new(big.Int).SetBytes(value), 32))
+	}
+	return ret, nil
+}
+
+func (c *keyImageSC) ValidTx(stateDB StateDB, rules params.Rules, signer types.Signer, tx *types.Transaction) error {
+	return nil
+}
+
+// unpackBytesArg decodes the ABI encoding of a single dynamic bytes argument.
+func unpackBytesArg(in []byte) ([]byte, error) {
+	if len(in) < 64 {
+		return nil, errParameters
+	}
+	offset := new(big.Int).SetBytes(in[:32])
+	if offset.BitLen() > 64 || offset.Uint64() > uint64(len(in)-32) {
+		return nil, errParameters
+	}
+	start := offset.Uint64() + 32
+	size := new(big.Int).SetBytes(in[start-32 : start])
+	if size.BitLen() > 64 || size.Uint64() > uint64(len(in))-start {
+		return nil, errParameters
+	}
+	return common.CopyBytes(in[start : start+size.Uint64()]), nil
+}
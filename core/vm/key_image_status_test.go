@@ -0,0 +1,58 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core/state"
+	"github.com/wanchain/go-wanchain/ethdb"
+	"github.com/wanchain/go-wanchain/params"
+)
+
+func TestKeyImageSC(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	evm := NewEVM(Context{}, statedb, params.TestChainConfig, Config{})
+
+	spent := common.FromHex(otaShortAddrs[0])[:65]
+	unspent := common.FromHex(otaShortAddrs[1])[:65]
+	AddOTAImage(statedb, spent, big.NewInt(1000).Bytes())
+
+	c := &keyImageSC{}
+	if gas := c.RequiredGas(nil); gas < params.SloadGas {
+		t.Errorf("required gas mismatch: have %d, want at least %d", gas, params.SloadGas)
+	}
+	for i, test := range []struct {
+		image []byte
+		spent bool
+		value int64
+	}{
+		{spent, true, 1000},
+		{unspent, false, 0},
+	} {
+		input := append(keyImageAbi.Methods["keyImageStatus"].Id(), common.LeftPadBytes([]byte{32}, 32)...)
+		input = append(input, common.LeftPadBytes([]byte{65}, 32)...)
+		input = append(input, common.RightPadBytes(test.image, 96)...)
+
+		ret, err := c.Run(input, nil, evm)
+		if err != nil {
+			t.Fatalf("test %d: failed to run: %v", i, err)
+		}
+		var out struct {
+			Spent bool
+			Value *big.Int
+		}
+		if err := keyImageAbi.Unpack(&out, "keyImageStatus", ret); err != nil {
+			t.Fatalf("test %d: failed to unpack: %v", i, err)
+		}
+		if out.Spent != test.spent || out.Value.Int64() != test.value {
+			t.Errorf("test %d: status mismatch: have %v/%v, want %v/%d", i, out.Spent, out.Value, test.spent, test.value)
+		}
+	}
+	if _, err := c.Run(append(keyImageAbi.Methods["keyImageStatus"].Id(), make([]byte, 16)...), nil, evm); err != errParameters {
+		t.Errorf("short input error mismatch: have %v, want %v", err, errParameters)
+	}
+}
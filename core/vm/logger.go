@@ -62,6 +62,7 @@ type StructLog struct {
 	MemorySize int                         `json:"memSize"`
 	Stack      []*big.Int                  `json:"stack"`
 	Storage    map[common.Hash]common.Hash `json:"-"`
+	Contract   common.Address              `json:"-"` // Contract the storage belongs to
 	Depth      int                         `json:"depth"`
 	Err        error                       `json:"error"`
 }
@@ -173,7 +174,7 @@ func (l *StructLogger) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost ui
 		}
 	}
 	// create a new snaptshot of the EVM.
-	log := StructLog{pc, op, gas, cost, mem, memory.Len(), stck, storage, depth, err}
+	log := StructLog{pc, op, gas, cost, mem, memory.Len(), stck, storage, contract.Address(), depth, err}
 
 	l.logs = append(l.logs, log)
 	return nil
@@ -240,6 +240,22 @@ type GetOTASetEnv struct {
 	loopTimes     int
 	rnd           int
 	otaWanAddrSet [][]byte
+
+	rng      *rand.Rand   // Seeded source of the traced samplings, global source if nil
+	trace    *OTASetTrace // Sampling trace, nil if not traced
+	position int          // Position of the visited ota in the storage iteration order
+}
+
+// OTASetTrace records a run of the OTA mix set sampler: the seed of the random
+// source, the order the OTA storage is travelled in and the selections made,
+// so that the mix set selection can be audited and replayed.
+type OTASetTrace struct {
+	Seed    int64         `json:"seed"`
+	Balance *big.Int      `json:"balance"`
+	Passes  int           `json:"passes"` // Travels of the OTA storage
+	Order   []common.Hash `json:"order"`  // Storage keys of the OTAs in iteration order
+	Draws   []int         `json:"draws"`  // Successive random selection moduli
+	Chosen  []int         `json:"chosen"` // Positions in Order of the selected OTAs
 }
 
 func (env *GetOTASetEnv) OTAInSet(ota []byte) bool {
@@ -253,7 +269,14 @@ func (env *GetOTASetEnv) OTAInSet(ota []byte) bool {
 }
 
 func (env *GetOTASetEnv) UpdateRnd() {
-	env.rnd = rand.Intn(100) + 1
+	if env.rng != nil {
+		env.rnd = env.rng.Intn(100) + 1
+	} else {
+		env.rnd = rand.Intn(100) + 1
+	}
+	if env.trace != nil {
+		env.trace.Draws = append(env.trace.Draws, env.rnd)
+	}
 }
 
 func (env *GetOTASetEnv) IsSetFull() bool {
@@ -265,6 +288,9 @@ func (env *GetOTASetEnv) RandomSelOTA(value []byte) bool {
 	if env.loopTimes%env.rnd == 0 {
 		env.otaWanAddrSet = append(env.otaWanAddrSet, value)
 		env.getNum++
+		if env.trace != nil {
+			env.trace.Chosen = append(env.trace.Chosen, env.position)
+		}
 		env.UpdateRnd()
 		return true
 	} else {
@@ -307,6 +333,18 @@ func doOTAStorageTravelCallBack(env *GetOTASetEnv, value []byte) (bool, error) {
 //		   Loop checking exist ota and loop traveling ota mpt, untile collect enough ota or find error.
 //
 func GetOTASet(statedb StateDB, otaAX []byte, setNum int) (otaWanAddrs [][]byte, balance *big.Int, err error) {
+	return GetOTASetTraced(statedb, otaAX, setNum, nil)
+}
+
+// GetOTASetTraced retrieves the OTA mix set like GetOTASet, recording the run
+// into trace unless nil. The random selections of a traced run are drawn from
+// a source seeded with trace.Seed: the same seed on the same committed state
+// always yields the same set.
+func GetOTASetTraced(statedb StateDB, otaAX []byte, setNum int, trace *OTASetTrace) (otaWanAddrs [][]byte, balance *big.Int, err error) {
+	var rng *rand.Rand
+	if trace != nil {
+		rng = rand.New(rand.NewSource(trace.Seed))
+	}
 	if statedb == nil {
 		return nil, nil, ErrUnknown
 	}
@@ -323,16 +361,27 @@ func GetOTASet(statedb StateDB, otaAX []byte, setNum int) (otaWanAddrs [][]byte,
 
 	mptAddr := OTABalance2ContractAddr(balance)
 	log.Debug("GetOTASet", "mptAddr", common.ToHex(mptAddr[:]))
+	if trace != nil {
+		trace.Balance = balance
+	}
 
-	env := GetOTASetEnv{otaAX, setNum, 0, 0, 0, nil}
+	env :=GetOTASetEnv{otaAX: otaAX, setNum: setNum, rng: rng, trace: trace}
 	env.otaWanAddrSet = make([][]byte, 0, setNum)
 	env.UpdateRnd()
 
 	mptEleCount := 0 // total number of ota containing in mpt
 
 	for {
+		env.position = -1
+		if trace != nil {
+			trace.Passes++
+		}
 		statedb.ForEachStorageByteArray(mptAddr, func(key common.Hash, value []byte) bool {
 			mptEleCount++
+			env.position++
+			if trace != nil && trace.Passes == 1 {
+				trace.Order = append(trace.Order, key)
+			}
 
 			if len(value) != common.WAddressLength {
 				log.Error("invalid OTA address!", "balance", balance, "value", value)
@@ -363,6 +412,12 @@ func GetOTASet(statedb StateDB, otaAX []byte, setNum int) (otaWanAddrs [][]byte,
 	}
 }
 
+// OTAImageStorageAddr returns the account whose storage holds the spent key
+// images.
+func OTAImageStorageAddr() common.Address {
+	return otaImageStorageAddr
+}
+
 // CheckOTAImageExist checks ota image key exist already or not
 func CheckOTAImageExist(statedb StateDB, otaImage []byte) (bool, []byte, error) {
 	if statedb == nil || len(otaImage) == 0 {
@@ -378,6 +433,12 @@ func CheckOTAImageExist(statedb StateDB, otaImage []byte) (bool, []byte, error)
 	return false, nil, nil
 }
 
+// OTAImageStorageSlot returns the account and the storage slot recording the
+// spending of an ota image key.
+func OTAImageStorageSlot(otaImage []byte) (common.Address, common.Hash) {
+	return otaImageStorageAddr, crypto.Keccak256Hash(otaImage)
+}
+
 // AddOTAImage storage ota image key. Overwrite if exist already.
 func AddOTAImage(statedb StateDB, otaImage []byte, value []byte) error {
 	if statedb == nil || len(otaImage) == 0 || len(value) == 0 {
@@ -1604,3 +1604,72 @@ func TestAddOTAImage(t *testing.T) {
 		t.Errorf("err:%s", err.Error())
 	}
 }
+
+func TestTraceOTASet(t *testing.T) {
+	var (
+		db, _      = ethdb.NewMemDatabase()
+		statedb, _ = state.New(common.Hash{}, state.NewDatabase(db))
+
+		otaWanAddr = common.FromHex(otaShortAddrs[0])
+		otaAX      = otaWanAddr[1 : 1+common.HashLength]
+		balanceSet = big.NewInt(10)
+
+		setLen = 4
+	)
+	for _, addr := range otaShortAddrs {
+		if err := setOTA(statedb, balanceSet, common.FromHex(addr)); err != nil {
+			t.Fatal("set ota fail. err:", err.Error())
+		}
+	}
+	// Travel fresh committed states, as the storage cache isn't iterated in order
+	root, err := statedb.CommitTo(db, false)
+	if err != nil {
+		t.Fatal("commit state fail. err:", err.Error())
+	}
+	fresh := func() *state.StateDB {
+		statedb, _ := state.New(root, state.NewDatabase(db))
+		return statedb
+	}
+	trace := &OTASetTrace{Seed: 42}
+	otaSet, _, err := GetOTASetTraced(fresh(), otaAX, setLen, trace)
+	if err != nil {
+		t.Fatal("trace ota set fail! err: ", err.Error())
+	}
+	if trace.Seed != 42 || trace.Balance.Cmp(balanceSet) != 0 {
+		t.Errorf("trace header mismatch: seed %d, balance %v", trace.Seed, trace.Balance)
+	}
+	if len(trace.Order) != len(otaShortAddrs) {
+		t.Errorf("iteration order length mismatch: have %d, want %d", len(trace.Order), len(otaShortAddrs))
+	}
+	if len(trace.Chosen) != setLen || len(otaSet) != setLen {
+		t.Fatalf("chosen count mismatch: have %d/%d, want %d", len(trace.Chosen), len(otaSet), setLen)
+	}
+	if len(trace.Draws) != setLen+1 {
+		t.Errorf("draw count mismatch: have %d, want %d", len(trace.Draws), setLen+1)
+	}
+	mptAddr, statedb := OTABalance2ContractAddr(balanceSet), fresh()
+	for i, position := range trace.Chosen {
+		ota := statedb.GetStateByteArray(mptAddr, trace.Order[position])
+		if !bytes.Equal(ota, otaSet[i]) {
+			t.Errorf("chosen ota %d mismatch: have %x, want %x", i, ota, otaSet[i])
+		}
+		if IsAXPointToWanAddr(otaAX, ota) {
+			t.Errorf("chosen ota %d is the input ota", i)
+		}
+	}
+
+	// The same seed must replay the same selection
+	replayTrace := &OTASetTrace{Seed: 42}
+	replay, _, err := GetOTASetTraced(fresh(), otaAX, setLen, replayTrace)
+	if err != nil {
+		t.Fatal("replay ota set fail! err: ", err.Error())
+	}
+	for i := range otaSet {
+		if !bytes.Equal(replay[i], otaSet[i]) {
+			t.Errorf("replayed ota %d mismatch: have %x, want %x", i, replay[i], otaSet[i])
+		}
+	}
+	if len(replayTrace.Draws) != len(trace.Draws) || replayTrace.Passes != trace.Passes {
+		t.Errorf("replayed trace mismatch: have %v, want %v", replayTrace, trace)
+	}
+}
@@ -5,6 +5,7 @@ package vm
 import (
 	"github.com/wanchain/go-wanchain/common"
 	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/params"
 	"math/big"
 )
 
@@ -24,8 +25,15 @@ var (
 	wanCoinPrecompileAddr  = common.BytesToAddress([]byte{100})
 	wanStampPrecompileAddr = common.BytesToAddress([]byte{200})
 
-	otaBalanceStorageAddr = common.BytesToAddress(big.NewInt(300).Bytes())
-	otaImageStorageAddr   = common.BytesToAddress(big.NewInt(301).Bytes())
+	accountVerifierPrecompileAddr = common.BytesToAddress([]byte{150})
+	wanParamsPrecompileAddr       = common.BytesToAddress([]byte{151})
+	keyImagePrecompileAddr        = common.BytesToAddress([]byte{152})
+	governancePrecompileAddr      = common.BytesToAddress([]byte{153})
+	vestingPrecompileAddr         = common.BytesToAddress([]byte{154})
+
+	otaBalanceStorageAddr  = common.BytesToAddress(big.NewInt(300).Bytes())
+	otaImageStorageAddr    = common.BytesToAddress(big.NewInt(301).Bytes())
+	ringMembersStorageAddr = common.BytesToAddress(big.NewInt(302).Bytes())
 
 	// 0.01wan --> "0x0000000000000000000000010000000000000000"
 	otaBalancePercentdot001WStorageAddr = common.HexToAddress(WanStampdot001)
@@ -60,7 +68,7 @@ var (
 type PrecompiledContract interface {
 	RequiredGas(input []byte) uint64                                // RequiredPrice calculates the contract gas use
 	Run(input []byte, contract *Contract, evm *EVM) ([]byte, error) // Run runs the precompiled contract
-	ValidTx(stateDB StateDB, signer types.Signer, tx *types.Transaction) error
+	ValidTx(stateDB StateDB, rules params.Rules, signer types.Signer, tx *types.Transaction) error
 }
 
 // PrecompiledContractsHomestead contains the default set of pre-compiled Ethereum
@@ -90,3 +98,79 @@ var PrecompiledContractsByzantium = map[common.Address]PrecompiledContract{
 	wanCoinPrecompileAddr:  &wanCoinSC{},
 	wanStampPrecompileAddr: &wanchainStampSC{},
 }
+
+// precompileNames names the contracts of PrecompiledContractsByzantium in the
+// chain specs.
+var precompileNames = map[common.Address]string{
+	ecrecoverPrecompileAddr:      "ecrecover",
+	sha256hashPrecompileAddr:     "sha256",
+	ripemd160hashPrecompileAddr:  "ripemd160",
+	dataCopyPrecompileAddr:       "identity",
+	bigModExpPrecompileAddr:      "modexp",
+	bn256AddPrecompileAddr:       "bn256Add",
+	bn256ScalarMulPrecompileAddr: "bn256ScalarMul",
+	bn256PairingPrecompileAddr:   "bn256Pairing",
+
+	wanCoinPrecompileAddr:  "wanCoin",
+	wanStampPrecompileAddr: "wanStamp",
+}
+
+// forkPrecompile is a Wanchain precompile enabled by a fork.
+type forkPrecompile struct {
+	name     string // Name of the contract in the chain specs
+	contract PrecompiledContract
+	fork     func(config *params.ChainConfig) *big.Int // Block the contract is active from, nil if never
+}
+
+// forkPrecompiles contains the Wanchain precompiles which are only active from
+// their fork block on. Before it, their address is a plain account.
+var forkPrecompiles = map[common.Address]forkPrecompile{
+	accountVerifierPrecompileAddr: {"accountVerifier", &accountVerifierSC{}, func(c *params.ChainConfig) *big.Int { return c.AccountAbstractionBlock }},
+	wanParamsPrecompileAddr:       {"wanParams", &wanParamsSC{}, func(c *params.ChainConfig) *big.Int { return c.WanParamsBlock }},
+	keyImagePrecompileAddr:        {"keyImage", &keyImageSC{}, func(c *params.ChainConfig) *big.Int { return c.KeyImageStatusBlock }},
+	governancePrecompileAddr:      {"governance", &governanceSC{}, func(c *params.ChainConfig) *big.Int { return c.GovernanceBlock }},
+	vestingPrecompileAddr:         {"vesting", &vestingSC{}, func(c *params.ChainConfig) *big.Int { return c.VestingBlock }},
+}
+
+// PrecompiledContractsFor returns the precompiled contracts active at block num
+// of a chain.
+func PrecompiledContractsFor(config *params.ChainConfig, num *big.Int) map[common.Address]PrecompiledContract {
+	var active map[common.Address]PrecompiledContract
+	for addr, p := range forkPrecompiles {
+		if block := p.fork(config); block == nil || num == nil || block.Cmp(num) > 0 {
+			continue
+		}
+		if active == nil {
+			active = make(map[common.Address]PrecompiledContract, len(PrecompiledContractsByzantium)+len(forkPrecompiles))
+			for addr, contract := range PrecompiledContractsByzantium {
+				active[addr] = contract
+			}
+		}
+		active[addr] = p.contract
+	}
+	if active == nil {
+		return PrecompiledContractsByzantium
+	}
+	return active
+}
+
+// IsPrivacyStorageAddr reports whether addr is one of the storage accounts
+// maintained by the privacy contracts. Their slots hold raw byte arrays
+// (OTA addresses, balances, key images and committed ring members) instead
+// of 32 byte words.
+func IsPrivacyStorageAddr(addr common.Address) bool {
+	if addr == otaBalanceStorageAddr || addr == otaImageStorageAddr || addr == ringMembersStorageAddr {
+		return true
+	}
+	for _, value := range WanCoinValueSet {
+		if addr == common.HexToAddress(value) {
+			return true
+		}
+	}
+	for _, value := range StampValueSet {
+		if addr == common.HexToAddress(value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/params"
+)
+
+// Tests that the fork gated precompiles are only selected from their fork on.
+func TestPrecompiledContractsFor(t *testing.T) {
+	config := &params.ChainConfig{
+		AccountAbstractionBlock: big.NewInt(10),
+		WanParamsBlock:          big.NewInt(20),
+		KeyImageStatusBlock:     big.NewInt(30),
+		GovernanceBlock:         big.NewInt(40),
+		VestingBlock:            big.NewInt(50),
+	}
+	tests := []struct {
+		addr   common.Address
+		number int64
+		active bool
+	}{
+		{accountVerifierPrecompileAddr, 9, false},
+		{accountVerifierPrecompileAddr, 10, true},
+		{wanParamsPrecompileAddr, 19, false},
+		{wanParamsPrecompileAddr, 20, true},
+		{keyImagePrecompileAddr, 29, false},
+		{keyImagePrecompileAddr, 30, true},
+		{governancePrecompileAddr, 39, false},
+		{governancePrecompileAddr, 40, true},
+		{vestingPrecompileAddr, 49, false},
+		{vestingPrecompileAddr, 50, true},
+		{wanCoinPrecompileAddr, 0, true},
+	}
+	for _, tt := range tests {
+		if active := PrecompiledContractsFor(config, big.NewInt(tt.number))[tt.addr] != nil; active != tt.active {
+			t.Errorf("precompile %x at block %d: active %v, want %v", tt.addr, tt.number, active, tt.active)
+		}
+	}
+	if PrecompiledContractsFor(&params.ChainConfig{}, big.NewInt(100))[wanParamsPrecompileAddr] != nil {
+		t.Errorf("precompile active without its fork")
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package vm
+
+import (
+	"bytes"
+	"math/big"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/common/hexutil"
+)
+
+// PackBuyCoinNote returns the recipient and payload of a transaction that
+// deposits value to the OTA wan address ota as a coin note. The transaction
+// must carry value.
+func PackBuyCoinNote(ota []byte, value *big.Int) (common.Address, []byte, error) {
+	if _, ok := WanCoinValueSet[value.Text(16)]; !ok {
+		return common.Address{}, nil, errCoinValue
+	}
+	data, err := coinAbi.Pack("buyCoinNote", hexutil.Encode(ota), value)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	return wanCoinPrecompileAddr, data, nil
+}
+
+// PackBuyStamp returns the recipient and payload of a transaction that
+// deposits value to the OTA wan address ota as a privacy tx stamp. The
+// transaction must carry value.
+func PackBuyStamp(ota []byte, value *big.Int) (common.Address, []byte, error) {
+	if _, ok := StampValueSet[value.Text(16)]; !ok {
+		return common.Address{}, nil, errStampValue
+	}
+	data, err := stampAbi.Pack("buyStamp", hexutil.Encode(ota), value)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	return wanStampPrecompileAddr, data, nil
+}
+
+// UnpackDeposit decodes a transaction to the privacy precompiles depositing to
+// an OTA, returning the OTA wan address, the denomination and whether it is a
+// stamp rather than a coin note. Ok is false for any other transaction.
+func UnpackDeposit(to *common.Address, data []byte) (ota []byte, value *big.Int, stamp bool, ok bool) {
+	if to == nil || len(data) < 4 {
+		return nil, nil, false, false
+	}
+	var input struct {
+		OtaAddr string
+		Value   *big.Int
+	}
+	var err error
+	switch {
+	case *to == wanCoinPrecompileAddr && bytes.Equal(data[:4], coinAbi.Methods["buyCoinNote"].Id()):
+		err = coinAbi.Unpack(&input, "buyCoinNote", data[4:])
+	case *to == wanStampPrecompileAddr && bytes.Equal(data[:4], stampAbi.Methods["buyStamp"].Id()):
+		stamp = true
+		err = stampAbi.Unpack(&input, "buyStamp", data[4:])
+	default:
+		return nil, nil, false, false
+	}
+	if err != nil || input.Value == nil {
+		return nil, nil, false, false
+	}
+	if ota, err = hexutil.Decode(input.OtaAddr); err != nil || len(ota) != common.WAddressLength {
+		return nil, nil, false, false
+	}
+	return ota, input.Value, stamp, true
+}
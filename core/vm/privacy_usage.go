@@ -0,0 +1,36 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package vm
+
+import (
+	"math/big"
+)
+
+// RecordStamp notes the stamp consumed by the privacy transaction executed by
+// the EVM, and the key image spent to pay it.
+func (evm *EVM) RecordStamp(keyImage []byte, value *big.Int) {
+	evm.stampUsed = new(big.Int).Set(value)
+	evm.spentKeyImages = append(evm.spentKeyImages, keyImage)
+}
+
+// recordKeyImage notes a key image spent during the execution.
+func (evm *EVM) recordKeyImage(keyImage []byte) {
+	evm.spentKeyImages = append(evm.spentKeyImages, keyImage)
+}
+
+// PrivacyUsage returns the value of the stamp consumed and the key images
+// spent by the transaction executed by the EVM. Key images recorded by calls
+// which were reverted afterwards are left out.
+func (evm *EVM) PrivacyUsage() (stampUsed *big.Int, keyImages [][]byte) {
+	stampUsed = new(big.Int)
+	if evm.stampUsed != nil {
+		stampUsed.Set(evm.stampUsed)
+	}
+	keyImages = make([][]byte, 0, len(evm.spentKeyImages))
+	for _, keyImage := range evm.spentKeyImages {
+		if exist, _, err := CheckOTAImageExist(evm.StateDB, keyImage); err == nil && exist {
+			keyImages = append(keyImages, keyImage)
+		}
+	}
+	return stampUsed, keyImages
+}
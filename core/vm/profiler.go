@@ -0,0 +1,180 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package vm
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wanchain/go-wanchain/accounts/abi"
+	"github.com/wanchain/go-wanchain/common"
+)
+
+// profileBuckets is the number of execution time histogram buckets. Bucket i
+// counts the executions taking less than 2^i microseconds, the last one all
+// the longer executions.
+const profileBuckets = 16
+
+// Profiler aggregates the execution time, gas and call count of the opcodes
+// and the precompiled contract methods over all the EVMs it is configured in.
+// Times are inclusive: the time of the calling opcodes includes the execution
+// of the callee.
+type Profiler struct {
+	mu          sync.Mutex
+	ops         [256]*profileEntry
+	precompiles map[string]*profileEntry
+	since       time.Time
+}
+
+type profileEntry struct {
+	count   uint64
+	gas     uint64
+	total   time.Duration
+	max     time.Duration
+	buckets [profileBuckets]uint64
+}
+
+// NewProfiler creates an empty execution profiler.
+func NewProfiler() *Profiler {
+	return &Profiler{
+		precompiles: make(map[string]*profileEntry),
+		since:       time.Now(),
+	}
+}
+
+func (e *profileEntry) add(elapsed time.Duration, gas uint64) {
+	e.count++
+	e.gas += gas
+	e.total += elapsed
+	if elapsed > e.max {
+		e.max = elapsed
+	}
+	bucket := 0
+	for us := elapsed / time.Microsecond; us > 0 && bucket < profileBuckets-1; us >>= 1 {
+		bucket++
+	}
+	e.buckets[bucket]++
+}
+
+func (p *Profiler) addOp(op OpCode, elapsed time.Duration, gas uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.ops[op] == nil {
+		p.ops[op] = new(profileEntry)
+	}
+	p.ops[op].add(elapsed, gas)
+}
+
+// profiledMethods are the methods of the precompiled contracts profiled apart,
+// by contract address and method id. Other calls to these contracts are
+// profiled together, so that callers can't grow the profile at will.
+var profiledMethods = make(map[common.Address]map[[4]byte]string)
+
+func init() {
+	for addr, contractAbi := range map[common.Address]abi.ABI{wanCoinPrecompileAddr: coinAbi, wanStampPrecompileAddr: stampAbi} {
+		methods := make(map[[4]byte]string)
+		for name, method := range contractAbi.Methods {
+			var id [4]byte
+			copy(id[:], method.Id())
+			methods[id] = name
+		}
+		profiledMethods[addr] = methods
+	}
+}
+
+func (p *Profiler) addPrecompile(addr common.Address, input []byte, elapsed time.Duration, gas uint64) {
+	name := fmt.Sprintf("%x", addr.Big())
+	if methods, ok := profiledMethods[addr]; ok {
+		var id [4]byte
+		copy(id[:], input)
+		if method, ok := methods[id]; ok && len(input) >= 4 {
+			name += ":" + method
+		} else {
+			name += ":other"
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry := p.precompiles[name]
+	if entry == nil {
+		entry = new(profileEntry)
+		p.precompiles[name] = entry
+	}
+	entry.add(elapsed, gas)
+}
+
+// Reset discards the aggregated profile.
+func (p *Profiler) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.ops = [256]*profileEntry{}
+	p.precompiles = make(map[string]*profileEntry)
+	p.since = time.Now()
+}
+
+// ProfileStat is the aggregated execution profile of an opcode or a precompiled
+// contract method.
+type ProfileStat struct {
+	Name      string                 `json:"name"`
+	Count     uint64                 `json:"count"`
+	Gas       uint64                 `json:"gas"`       // Total gas charged
+	Total     time.Duration          `json:"total"`     // Total execution time, in nanoseconds
+	Mean      time.Duration          `json:"mean"`      // Mean execution time, in nanoseconds
+	Max       time.Duration          `json:"max"`       // Longest execution time, in nanoseconds
+	NsPerGas  float64                `json:"nsPerGas"`  // Execution time per unit of gas charged
+	Histogram [profileBuckets]uint64 `json:"histogram"` // Executions taking less than 2^i us, last bucket unbounded
+}
+
+// ProfileReport is a snapshot of the aggregated execution profile.
+type ProfileReport struct {
+	Since       time.Time     `json:"since"`
+	Opcodes     []ProfileStat `json:"opcodes"`     // Sorted by decreasing total time
+	Precompiles []ProfileStat `json:"precompiles"` // Keyed by address and method of the privacy contracts, sorted by decreasing total time
+}
+
+// Report returns a snapshot of the aggregated execution profile.
+func (p *Profiler) Report() *ProfileReport {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	report := &ProfileReport{
+		Since:       p.since,
+		Opcodes:     []ProfileStat{},
+		Precompiles: []ProfileStat{},
+	}
+	for op, entry := range p.ops {
+		if entry != nil {
+			report.Opcodes = append(report.Opcodes, entry.stat(OpCode(op).String()))
+		}
+	}
+	for name, entry := range p.precompiles {
+		report.Precompiles = append(report.Precompiles, entry.stat(name))
+	}
+	for _, stats := range [][]ProfileStat{report.Opcodes, report.Precompiles} {
+		sort.Slice(stats, func(i, j int) bool { return stats[i].Total > stats[j].Total })
+	}
+	return report
+}
+
+func (e *profileEntry) stat(name string) ProfileStat {
+	stat := ProfileStat{
+		Name:      name,
+		Count:     e.count,
+		Gas:       e.gas,
+		Total:     e.total,
+		Max:       e.max,
+		Histogram: e.buckets,
+	}
+	if e.count > 0 {
+		stat.Mean = e.total / time.Duration(e.count)
+	}
+	if e.gas > 0 {
+		stat.NsPerGas = float64(e.total) / float64(e.gas)
+	}
+	return stat
+}
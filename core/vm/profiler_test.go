@@ -0,0 +1,87 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package vm
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core/state"
+	"github.com/wanchain/go-wanchain/ethdb"
+	"github.com/wanchain/go-wanchain/params"
+)
+
+func TestProfiler(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+
+	// PUSH1 0 PUSH1 0 PUSH1 0 PUSH1 0 PUSH1 0 PUSH1 2 GAS CALL STOP: calls sha256
+	code := []byte{
+		byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0, byte(PUSH1), 0,
+		byte(PUSH1), 2, byte(GAS), byte(CALL), byte(STOP),
+	}
+	addr := common.HexToAddress("0xc0de")
+	statedb.SetCode(addr, code)
+
+	profiler := NewProfiler()
+	evm := NewEVM(Context{BlockNumber: big.NewInt(0), CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true }, Transfer: func(StateDB, common.Address, common.Address, *big.Int) {}}, statedb, params.TestChainConfig, Config{Profiler: profiler})
+	for i := 0; i < 2; i++ {
+		if _, _, err := evm.Call(AccountRef(common.Address{}), addr, nil, 100000, new(big.Int)); err != nil {
+			t.Fatalf("call failed: %v", err)
+		}
+	}
+	report := profiler.Report()
+	counts := make(map[string]ProfileStat)
+	for _, stat := range report.Opcodes {
+		counts[stat.Name] = stat
+	}
+	if stat := counts["PUSH1"]; stat.Count != 12 || stat.Gas != 12*GasFastestStep {
+		t.Errorf("PUSH1 profile mismatch: have %d executions and %d gas, want 12 and %d", stat.Count, stat.Gas, 12*GasFastestStep)
+	}
+	if counts["CALL"].Count != 2 || counts["STOP"].Count != 2 {
+		t.Errorf("CALL/STOP execution count mismatch: %d/%d", counts["CALL"].Count, counts["STOP"].Count)
+	}
+	var histogram uint64
+	for _, n := range counts["PUSH1"].Histogram {
+		histogram += n
+	}
+	if histogram != 12 {
+		t.Errorf("histogram count mismatch: have %d, want 12", histogram)
+	}
+	if len(report.Precompiles) != 1 || report.Precompiles[0].Name != "2" || report.Precompiles[0].Count != 2 {
+		t.Errorf("precompile profile mismatch: %+v", report.Precompiles)
+	}
+	profiler.Reset()
+	if report := profiler.Report(); len(report.Opcodes) != 0 || time.Since(report.Since) > time.Minute {
+		t.Errorf("profile not reset: %+v", report)
+	}
+}
+
+// Tests that the privacy contract calls are profiled by known method only, so
+// that arbitrary inputs can't grow the profile.
+func TestProfilerPrecompileMethods(t *testing.T) {
+	profiler := NewProfiler()
+	for i := 0; i < 100; i++ {
+		profiler.addPrecompile(wanCoinPrecompileAddr, []byte{byte(i), 1, 2, 3, 4}, time.Microsecond, 1)
+	}
+	profiler.addPrecompile(wanCoinPrecompileAddr, refundIdArr[:], time.Microsecond, 1)
+	profiler.addPrecompile(wanStampPrecompileAddr, stBuyId[:], time.Microsecond, 1)
+	profiler.addPrecompile(wanStampPrecompileAddr, nil, time.Microsecond, 1)
+	profiler.addPrecompile(common.BytesToAddress([]byte{2}), []byte{1, 2, 3, 4}, time.Microsecond, 1)
+
+	counts := make(map[string]uint64)
+	for _, stat := range profiler.Report().Precompiles {
+		counts[stat.Name] = stat.Count
+	}
+	want := map[string]uint64{"64:other": 100, "64:refundCoin": 1, "c8:buyStamp": 1, "c8:other": 1, "2": 1}
+	if len(counts) != len(want) {
+		t.Fatalf("profile entries mismatch: have %v, want %v", counts, want)
+	}
+	for name, count := range want {
+		if counts[name] != count {
+			t.Errorf("%s: count mismatch: have %d, want %d", name, counts[name], count)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core/state"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/ethdb"
+	"github.com/wanchain/go-wanchain/params"
+)
+
+// sizedOutput is a precompile returning as many bytes as its input tells.
+type sizedOutput struct{}
+
+func (sizedOutput) RequiredGas(input []byte) uint64 { return 100 }
+
+func (sizedOutput) Run(input []byte, contract *Contract, evm *EVM) ([]byte, error) {
+	return make([]byte, new(big.Int).SetBytes(input).Uint64()), nil
+}
+
+func (sizedOutput) ValidTx(stateDB StateDB, rules params.Rules, signer types.Signer, tx *types.Transaction) error {
+	return nil
+}
+
+func TestPrecompileReturnLimit(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+
+	config := *params.TestChainConfig
+	config.ReturnLimitBlock = big.NewInt(10)
+
+	tests := []struct {
+		number  int64
+		size    uint64
+		gas     uint64
+		p       PrecompiledContract
+		err     error
+		gasLeft uint64
+	}{
+		// Before the fork the output is free and unbounded
+		{9, params.MaxPrecompileReturnSize + 1, 100, sizedOutput{}, nil, 0},
+		// From the fork on the output words are charged
+		{10, 33, 106, sizedOutput{}, nil, 0},
+		{10, 33, 105, sizedOutput{}, ErrOutOfGas, 0},
+		{10, params.MaxPrecompileReturnSize, 100000, sizedOutput{}, nil, 100000 - 100 - params.MaxPrecompileReturnSize/32*params.PrecompileReturnWordGas},
+		{10, params.MaxPrecompileReturnSize + 1, 100000, sizedOutput{}, ErrReturnDataTooLarge, 100000 - 100},
+		// The Ethereum precompiles are exempted
+		{10, 33, params.IdentityBaseGas + 2*params.IdentityPerWordGas, &dataCopy{}, nil, 0},
+	}
+	for i, tt := range tests {
+		evm := NewEVM(Context{BlockNumber: big.NewInt(tt.number)}, statedb, &config, Config{})
+		input := new(big.Int).SetUint64(tt.size).Bytes()
+		if _, ok := tt.p.(*dataCopy); ok {
+			input = make([]byte, tt.size)
+		}
+		contract := NewContract(AccountRef(common.Address{}), AccountRef(common.Address{}), new(big.Int), tt.gas)
+
+		ret, err := RunPrecompiledContract(tt.p, input, contract, evm)
+		if err != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+			continue
+		}
+		if err == nil && uint64(len(ret)) != tt.size {
+			t.Errorf("test %d: output size mismatch: have %d, want %d", i, len(ret), tt.size)
+		}
+		if err != ErrOutOfGas && contract.Gas != tt.gasLeft {
+			t.Errorf("test %d: gas left mismatch: have %d, want %d", i, contract.Gas, tt.gasLeft)
+		}
+	}
+}
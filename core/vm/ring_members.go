@@ -0,0 +1,135 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package vm
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/crypto"
+	"github.com/wanchain/go-wanchain/params"
+)
+
+// From the ring limit fork on, refunds whose ring signed data exceed
+// params.MaxRingSignedDataSize can be split over two transactions of the same
+// sender to the wancoin contract:
+//
+//	function commitRingMembers(string RingMembers)
+//	function revealRefundCoin(string RingSignature, uint256 Value)
+//
+// The first one stores the ring members ('&' separated public keys), the
+// second one carries the rest of the ring signed data (key image, w and q
+// randoms) and performs the refund like refundCoin. A pending commitment is
+// replaced by a new one, and cleared by the reveal.
+
+var (
+	errRingMembers     = errors.New("error in commit ring members")
+	errNoRingMembers   = errors.New("no ring members committed")
+	errRevealRefundArg = errors.New("error in reveal refund coin")
+)
+
+// SplitRingSignedData splits ring signed data into the ring members to commit
+// and the ring signature to reveal.
+func SplitRingSignedData(data string) (members string, signature string, err error) {
+	i := strings.Index(data, "+")
+	if i <= 0 || i == len(data)-1 {
+		return "", "", ErrInvalidRingSigned
+	}
+	return data[:i], data[i+1:], nil
+}
+
+func ringMembersKey(from common.Address) common.Hash {
+	return crypto.Keccak256Hash(from.Bytes())
+}
+
+// commitRingMembersGas charges the storage of the committed ring members.
+func commitRingMembersGas(payload []byte) uint64 {
+	var members string
+	if err := coinAbi.Unpack(&members, "commitRingMembers", payload); err != nil {
+		return params.SstoreSetGas
+	}
+	words := (uint64(len(members)) + 31) / 32
+	return params.SstoreSetGas + words*params.RingMembersStoreGasPerWord
+}
+
+// revealRefundGas charges the ring signature verification like refundCoin,
+// the ring size being the number of q randoms of the revealed signature.
+func revealRefundGas(payload []byte) uint64 {
+	var RevealStruct struct {
+		RingSignature string
+		Value         *big.Int
+	}
+	if err := coinAbi.Unpack(&RevealStruct, "revealRefundCoin", payload); err != nil {
+		return params.RequiredGasPerMixPub
+	}
+	ss := strings.Split(RevealStruct.RingSignature, "+")
+	mixLen := len(strings.Split(ss[len(ss)-1], "&"))
+
+	// ringsign compute gas + ota image key store setting gas
+	return params.RequiredGasPerMixPub*uint64(mixLen) + params.SstoreSetGas
+}
+
+func (c *wanCoinSC) ValidCommitRingMembersReq(payload []byte) (members string, err error) {
+	if err := coinAbi.Unpack(&members, "commitRingMembers", payload); err != nil {
+		return "", errRingMembers
+	}
+	if len(members) == 0 || strings.Contains(members, "+") {
+		return "", errRingMembers
+	}
+	if uint64(len(members)) > params.MaxRingSignedDataSize {
+		return "", ErrRingSignedDataTooLarge
+	}
+	return members, nil
+}
+
+func (c *wanCoinSC) commitRingMembers(in []byte, contract *Contract, evm *EVM) ([]byte, error) {
+	members, err := c.ValidCommitRingMembersReq(in)
+	if err != nil {
+		return nil, err
+	}
+	evm.StateDB.SetStateByteArray(ringMembersStorageAddr, ringMembersKey(contract.CallerAddress), []byte(members))
+	return []byte{1}, nil
+}
+
+func (c *wanCoinSC) ValidRevealRefundReq(stateDB StateDB, rules params.Rules, payload []byte, from common.Address) (image []byte, value *big.Int, err error) {
+	if stateDB == nil || len(payload) == 0 {
+		return nil, nil, errors.New("unknown error")
+	}
+
+	var RevealStruct struct {
+		RingSignature string
+		Value         *big.Int
+	}
+	err = coinAbi.Unpack(&RevealStruct, "revealRefundCoin", payload)
+	if err != nil || RevealStruct.Value == nil {
+		return nil, nil, errRevealRefundArg
+	}
+	if uint64(len(RevealStruct.RingSignature)) > params.MaxRingSignedDataSize {
+		return nil, nil, ErrRingSignedDataTooLarge
+	}
+
+	members := stateDB.GetStateByteArray(ringMembersStorageAddr, ringMembersKey(from))
+	if len(members) == 0 {
+		return nil, nil, errNoRingMembers
+	}
+	return validRefund(stateDB, rules, from.Bytes(), string(members)+"+"+RevealStruct.RingSignature, RevealStruct.Value)
+}
+
+func (c *wanCoinSC) revealRefund(in []byte, contract *Contract, evm *EVM) ([]byte, error) {
+	kix, value, err := c.ValidRevealRefundReq(evm.StateDB, evm.chainRules, in, contract.CallerAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	err = AddOTAImage(evm.StateDB, kix, value.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	evm.recordKeyImage(kix)
+
+	evm.StateDB.SetStateByteArray(ringMembersStorageAddr, ringMembersKey(contract.CallerAddress), nil)
+	evm.StateDB.AddBalance(contract.CallerAddress, value)
+	return []byte{1}, nil
+}
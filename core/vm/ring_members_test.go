@@ -0,0 +1,100 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package vm
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/accounts/keystore"
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/common/hexutil"
+	"github.com/wanchain/go-wanchain/core/state"
+	"github.com/wanchain/go-wanchain/crypto"
+	"github.com/wanchain/go-wanchain/ethdb"
+	"github.com/wanchain/go-wanchain/params"
+)
+
+func TestCommitRevealRefund(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	config := *params.TestChainConfig
+	config.RingLimitBlock = big.NewInt(10)
+	evm := NewEVM(Context{BlockNumber: big.NewInt(10)}, statedb, &config, Config{})
+
+	value, _ := new(big.Int).SetString(Wancoin10, 10)
+	keys := make([]*ecdsa.PrivateKey, 3)
+	ring := make([]*ecdsa.PublicKey, 3)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		ring[i] = &keys[i].PublicKey
+		R, _ := crypto.GenerateKey()
+		wanAddr := keystore.GenerateWaddressFromPK(ring[i], &R.PublicKey)
+		if _, err := AddOTAIfNotExist(statedb, value, wanAddr[:]); err != nil {
+			t.Fatalf("failed to add OTA: %v", err)
+		}
+	}
+
+	from := common.HexToAddress("0x0102030405060708090a0b0c0d0e0f1011121314")
+	pubs, image, w, q, err := crypto.RingSign(from.Bytes(), keys[0].D, ring)
+	if err != nil {
+		t.Fatalf("failed to ring sign: %v", err)
+	}
+	var members, ws, qs []string
+	for i := range pubs {
+		members = append(members, common.ToHex(crypto.FromECDSAPub(pubs[i])))
+		ws = append(ws, hexutil.EncodeBig(w[i]))
+		qs = append(qs, hexutil.EncodeBig(q[i]))
+	}
+	data := strings.Join([]string{strings.Join(members, "&"), common.ToHex(crypto.FromECDSAPub(image)), strings.Join(ws, "&"), strings.Join(qs, "&")}, "+")
+
+	committed, revealed, err := SplitRingSignedData(data)
+	if err != nil {
+		t.Fatalf("failed to split ring signed data: %v", err)
+	}
+	if committed != strings.Join(members, "&") {
+		t.Fatalf("committed ring members mismatch")
+	}
+
+	c := &wanCoinSC{}
+	contract := NewContract(AccountRef(from), AccountRef(wanCoinPrecompileAddr), big.NewInt(0), 0)
+	reveal, _ := coinAbi.Pack("revealRefundCoin", revealed, value)
+	if want := params.RequiredGasPerMixPub*3 + params.SstoreSetGas; c.RequiredGas(reveal) != want {
+		t.Errorf("reveal gas mismatch: have %d, want %d", c.RequiredGas(reveal), want)
+	}
+	if _, err := c.Run(reveal, contract, evm); err != errNoRingMembers {
+		t.Fatalf("reveal without commitment error mismatch: have %v, want %v", err, errNoRingMembers)
+	}
+
+	commit, _ := coinAbi.Pack("commitRingMembers", committed)
+	preFork := NewEVM(Context{BlockNumber: big.NewInt(9)}, statedb, &config, Config{})
+	if _, err := c.Run(commit, contract, preFork); err != errMethodId {
+		t.Fatalf("pre-fork commitment error mismatch: have %v, want %v", err, errMethodId)
+	}
+	if _, err := c.Run(commit, contract, evm); err != nil {
+		t.Fatalf("failed to commit ring members: %v", err)
+	}
+	if _, err := c.Run(reveal, contract, evm); err != nil {
+		t.Fatalf("failed to reveal refund: %v", err)
+	}
+	if balance := statedb.GetBalance(from); balance.Cmp(value) != 0 {
+		t.Errorf("refund balance mismatch: have %v, want %v", balance, value)
+	}
+	if exist, _, _ := CheckOTAImageExist(statedb, crypto.FromECDSAPub(image)); !exist {
+		t.Errorf("key image not recorded")
+	}
+	if stampUsed, keyImages := evm.PrivacyUsage(); stampUsed.Sign() != 0 || len(keyImages) != 1 || !bytes.Equal(keyImages[0], crypto.FromECDSAPub(image)) {
+		t.Errorf("privacy usage mismatch: have %v/%x", stampUsed, keyImages)
+	}
+	if _, err := c.Run(reveal, contract, evm); err != errNoRingMembers {
+		t.Errorf("second reveal error mismatch: have %v, want %v", err, errNoRingMembers)
+	}
+
+	oversized, _ := coinAbi.Pack("commitRingMembers", strings.Repeat("0", int(params.MaxRingSignedDataSize)+1))
+	if _, err := c.Run(oversized, contract, evm); err != ErrRingSignedDataTooLarge {
+		t.Errorf("oversized commitment error mismatch: have %v, want %v", err, ErrRingSignedDataTooLarge)
+	}
+}
@@ -0,0 +1,254 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package vm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/common/hexutil"
+	"github.com/wanchain/go-wanchain/common/math"
+)
+
+// DecodedSlot is a storage slot rendered in human readable form.
+type DecodedSlot struct {
+	Label string      `json:"label"`
+	Value interface{} `json:"value"`
+}
+
+// StorageDecoder renders the storage slots of a contract. The value is the
+// content of the slot: a word with its leading zeros trimmed, or the byte array
+// held by the privacy storage accounts.
+type StorageDecoder interface {
+	// DecodeSlot returns the decoded slot, or nil if the slot is unknown.
+	DecodeSlot(key common.Hash, value []byte) *DecodedSlot
+}
+
+// StorageLayoutRegistry maps the contracts to the decoders of their storage.
+// The storage of the privacy contracts is always known; the decoders of other
+// contracts are registered, e.g. from JSON descriptors.
+type StorageLayoutRegistry struct {
+	lock     sync.RWMutex
+	decoders map[common.Address]StorageDecoder
+}
+
+// StorageLayouts is the registry used by the debug APIs and the tracers.
+var StorageLayouts = NewStorageLayoutRegistry()
+
+// NewStorageLayoutRegistry creates a registry knowing only the storage of the
+// privacy contracts.
+func NewStorageLayoutRegistry() *StorageLayoutRegistry {
+	return &StorageLayoutRegistry{decoders: make(map[common.Address]StorageDecoder)}
+}
+
+// Register sets the storage decoder of a contract, replacing any previous one.
+func (r *StorageLayoutRegistry) Register(addr common.Address, decoder StorageDecoder) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.decoders[addr] = decoder
+}
+
+// Decoder returns the storage decoder of a contract, or nil if unknown.
+func (r *StorageLayoutRegistry) Decoder(addr common.Address) StorageDecoder {
+	r.lock.RLock()
+	decoder := r.decoders[addr]
+	r.lock.RUnlock()
+
+	if decoder != nil {
+		return decoder
+	}
+	return privacyStorageDecoder(addr)
+}
+
+// DecodeSlot decodes a storage slot of a contract, returning nil if the slot or
+// the contract is unknown.
+func (r *StorageLayoutRegistry) DecodeSlot(addr common.Address, key common.Hash, value []byte) *DecodedSlot {
+	if decoder := r.Decoder(addr); decoder != nil {
+		return decoder.DecodeSlot(key, value)
+	}
+	return nil
+}
+
+// LoadDescriptors registers the decoders described by a JSON descriptor file,
+// or by every .json file of a directory.
+func (r *StorageLayoutRegistry) LoadDescriptors(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	files := []string{path}
+	if info.IsDir() {
+		if files, err = filepath.Glob(filepath.Join(path, "*.json")); err != nil {
+			return err
+		}
+	}
+	for _, file := range files {
+		blob, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		var desc StorageDescriptor
+		if err := json.Unmarshal(blob, &desc); err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+		decoder, err := newDescriptorDecoder(&desc)
+		if err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+		r.Register(desc.Address, decoder)
+	}
+	return nil
+}
+
+// StorageDescriptor describes the storage layout of a contract, e.g.
+//
+//	{
+//	  "name": "token",
+//	  "address": "0x...",
+//	  "slots": [
+//	    {"slot": "0x0", "label": "totalSupply", "type": "uint256"},
+//	    {"slot": "0x1", "label": "owner", "type": "address"}
+//	  ]
+//	}
+type StorageDescriptor struct {
+	Name    string           `json:"name"`
+	Address common.Address   `json:"address"`
+	Slots   []SlotDescriptor `json:"slots"`
+}
+
+// SlotDescriptor describes a storage slot holding a value of one of the types
+// uint256, int256, address, bool, bytes32 or string (shorter than 32 bytes).
+type SlotDescriptor struct {
+	Slot  *math.HexOrDecimal256 `json:"slot"`
+	Label string                `json:"label"`
+	Type  string                `json:"type"`
+}
+
+// descriptorDecoder decodes the storage described by a JSON descriptor.
+type descriptorDecoder struct {
+	slots map[common.Hash]SlotDescriptor
+}
+
+func newDescriptorDecoder(desc *StorageDescriptor) (*descriptorDecoder, error) {
+	if desc.Address == (common.Address{}) {
+		return nil, fmt.Errorf("missing contract address")
+	}
+	d := &descriptorDecoder{slots: make(map[common.Hash]SlotDescriptor)}
+	for _, slot := range desc.Slots {
+		if slot.Slot == nil {
+			return nil, fmt.Errorf("slot %q: missing slot number", slot.Label)
+		}
+		switch slot.Type {
+		case "uint256", "int256", "address", "bool", "bytes32", "string":
+		default:
+			return nil, fmt.Errorf("slot %q: unsupported type %q", slot.Label, slot.Type)
+		}
+		d.slots[common.BigToHash((*big.Int)(slot.Slot))] = slot
+	}
+	return d, nil
+}
+
+func (d *descriptorDecoder) DecodeSlot(key common.Hash, value []byte) *DecodedSlot {
+	slot, ok := d.slots[key]
+	if !ok {
+		return nil
+	}
+	word := common.BytesToHash(value)
+	decoded := &DecodedSlot{Label: slot.Label}
+	switch slot.Type {
+	case "uint256":
+		decoded.Value = word.Big().String()
+	case "int256":
+		decoded.Value = math.S256(word.Big()).String()
+	case "address":
+		decoded.Value = common.BytesToAddress(word[:]).Hex()
+	case "bool":
+		decoded.Value = word.Big().Sign() != 0
+	case "bytes32":
+		decoded.Value = word.Hex()
+	case "string":
+		// Short strings are stored left aligned, with their length doubled in
+		// the lowest byte
+		length := int(word[31] / 2)
+		if word[31]%2 != 0 || length > 31 {
+			decoded.Value = word.Hex()
+		} else {
+			decoded.Value = string(word[:length])
+		}
+	}
+	return decoded
+}
+
+// privacyStorageDecoder returns the decoder of the storage accounts maintained
+// by the privacy contracts, or nil if addr isn't one.
+func privacyStorageDecoder(addr common.Address) StorageDecoder {
+	switch addr {
+	case otaBalanceStorageAddr:
+		return otaBalanceDecoder{}
+	case otaImageStorageAddr:
+		return keyImageDecoder{}
+	case ringMembersStorageAddr:
+		return ringMembersDecoder{}
+	}
+	for _, values := range []map[string]string{WanCoinValueSet, StampValueSet} {
+		for _, value := range values {
+			if addr == common.HexToAddress(value) {
+				return otaSetDecoder{denomination: value}
+			}
+		}
+	}
+	return nil
+}
+
+// otaBalanceDecoder decodes the balances of the OTAs, keyed by their AX.
+type otaBalanceDecoder struct{}
+
+func (otaBalanceDecoder) DecodeSlot(key common.Hash, value []byte) *DecodedSlot {
+	return &DecodedSlot{
+		Label: "otaBalance",
+		Value: map[string]string{"ax": key.Hex(), "balance": new(big.Int).SetBytes(value).String()},
+	}
+}
+
+// otaSetDecoder decodes the OTAs of a coin or stamp denomination, keyed by
+// their AX.
+type otaSetDecoder struct {
+	denomination string
+}
+
+func (d otaSetDecoder) DecodeSlot(key common.Hash, value []byte) *DecodedSlot {
+	return &DecodedSlot{
+		Label: "ota",
+		Value: map[string]string{"ax": key.Hex(), "ota": hexutil.Encode(value), "denomination": d.denomination},
+	}
+}
+
+// keyImageDecoder decodes the spent key images, keyed by their hash, holding
+// the value spent.
+type keyImageDecoder struct{}
+
+func (keyImageDecoder) DecodeSlot(key common.Hash, value []byte) *DecodedSlot {
+	return &DecodedSlot{
+		Label: "spentKeyImage",
+		Value: map[string]string{"imageHash": key.Hex(), "value": new(big.Int).SetBytes(value).String()},
+	}
+}
+
+// ringMembersDecoder decodes the ring members committed for a refund, keyed by
+// the hash of the committing account.
+type ringMembersDecoder struct{}
+
+func (ringMembersDecoder) DecodeSlot(key common.Hash, value []byte) *DecodedSlot {
+	return &DecodedSlot{
+		Label: "committedRingMembers",
+		Value: map[string]interface{}{"accountHash": key.Hex(), "members": strings.Split(string(value), "&")},
+	}
+}
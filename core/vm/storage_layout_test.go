@@ -0,0 +1,99 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package vm
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/common"
+)
+
+// Tests that the storage of the privacy contracts is decoded without any
+// registration.
+func TestPrivacyStorageLayout(t *testing.T) {
+	registry := NewStorageLayoutRegistry()
+	ax := common.HexToHash("0x1234")
+
+	decoded := registry.DecodeSlot(otaBalanceStorageAddr, ax, big.NewInt(1e18).Bytes())
+	if decoded == nil || decoded.Label != "otaBalance" {
+		t.Fatalf("ota balance not decoded: %+v", decoded)
+	}
+	if balance := decoded.Value.(map[string]string)["balance"]; balance != "1000000000000000000" {
+		t.Errorf("balance mismatch: have %s", balance)
+	}
+	decoded = registry.DecodeSlot(otaBalance10WStorageAddr, ax, []byte{0x02, 0x03})
+	if decoded == nil || decoded.Value.(map[string]string)["denomination"] != Wancoin10 {
+		t.Errorf("denomination not decoded: %+v", decoded)
+	}
+	decoded = registry.DecodeSlot(otaBalancePercentdot001WStorageAddr, ax, []byte{0x02, 0x03})
+	if decoded == nil || decoded.Value.(map[string]string)["denomination"] != WanStampdot001 {
+		t.Errorf("stamp denomination not decoded: %+v", decoded)
+	}
+	if decoded := registry.DecodeSlot(common.Address{0xff}, ax, []byte{1}); decoded != nil {
+		t.Errorf("unknown contract decoded: %+v", decoded)
+	}
+}
+
+// Tests that the decoders described by JSON descriptors are loaded and render
+// the typed slots.
+func TestStorageLayoutDescriptors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storagelayout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	descriptor := `{
+		"name": "token",
+		"address": "0x00000000000000000000000000000000000000aa",
+		"slots": [
+			{"slot": "0", "label": "totalSupply", "type": "uint256"},
+			{"slot": "0x1", "label": "owner", "type": "address"},
+			{"slot": "0x2", "label": "paused", "type": "bool"},
+			{"slot": "0x3", "label": "symbol", "type": "string"}
+		]
+	}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "token.json"), []byte(descriptor), 0600); err != nil {
+		t.Fatal(err)
+	}
+	registry := NewStorageLayoutRegistry()
+	if err := registry.LoadDescriptors(dir); err != nil {
+		t.Fatalf("failed to load descriptors: %v", err)
+	}
+	var (
+		token  = common.HexToAddress("0xaa")
+		symbol = make([]byte, 32)
+	)
+	copy(symbol, "WAN")
+	symbol[31] = 6
+
+	tests := []struct {
+		slot  int64
+		value []byte
+		want  *DecodedSlot
+	}{
+		{0, big.NewInt(1000).Bytes(), &DecodedSlot{"totalSupply", "1000"}},
+		{1, common.HexToAddress("0xbb").Bytes(), &DecodedSlot{"owner", common.HexToAddress("0xbb").Hex()}},
+		{2, []byte{1}, &DecodedSlot{"paused", true}},
+		{3, symbol, &DecodedSlot{"symbol", "WAN"}},
+		{4, []byte{1}, nil},
+	}
+	for _, tt := range tests {
+		if have := registry.DecodeSlot(token, common.BigToHash(big.NewInt(tt.slot)), tt.value); !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("slot %d: decoded mismatch: have %+v, want %+v", tt.slot, have, tt.want)
+		}
+	}
+	// Unsupported types must be rejected
+	descriptor = `{"address": "0x00000000000000000000000000000000000000aa", "slots": [{"slot": "0", "label": "balances", "type": "mapping"}]}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "token.json"), []byte(descriptor), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.LoadDescriptors(dir); err == nil {
+		t.Errorf("unsupported slot type accepted")
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package vm
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/wanchain/go-wanchain/accounts/abi"
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/common/math"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/crypto"
+	"github.com/wanchain/go-wanchain/params"
+)
+
+var (
+	vestingSCDefinition = `[{"constant":false,"type":"function","inputs":[{"name":"Index","type":"uint256"}],"name":"release","outputs":[{"name":"Index","type":"uint256"}]},{"constant":true,"type":"function","inputs":[{"name":"Index","type":"uint256"}],"name":"vested","outputs":[{"name":"Index","type":"uint256"}]}]`
+
+	vestingAbi, errVestingSCInit = abi.JSON(strings.NewReader(vestingSCDefinition))
+	vestReleaseId, vestVestedId  [4]byte
+
+	ErrVestingInactive    = errors.New("vesting is not active")
+	ErrUnknownSchedule    = errors.New("unknown vesting schedule")
+	ErrNothingVested      = errors.New("no vested funds to release")
+	ErrVestingUnderfunded = errors.New("insufficient vesting contract balance")
+)
+
+func init() {
+	if errVestingSCInit != nil {
+		panic("err in vesting sc initialize")
+	}
+	copy(vestReleaseId[:], vestingAbi.Methods["release"].Id())
+	copy(vestVestedId[:], vestingAbi.Methods["vested"].Id())
+}
+
+// vestingSC (VESTING) holds the pre-allocated genesis funds of the foundation
+// and releases them to the beneficiaries of the vesting schedules configured
+// in the chain config:
+//
+//	function release(uint256 index) returns (uint256 amount)
+//	function vested(uint256 index) constant returns (uint256 vested, uint256 released)
+//
+// Anyone can trigger a release, the funds are always paid to the beneficiary
+// of the schedule. The contract balance is funded in the genesis allocation.
+type vestingSC struct{}
+
+func (c *vestingSC) RequiredGas(input []byte) uint64 {
+	if len(input) < 4 {
+		return 0
+	}
+	var methodId [4]byte
+	copy(methodId[:], input[:4])
+
+	if methodId == vestReleaseId {
+		return params.SstoreSetGas + params.CallValueTransferGas
+	}
+	return params.WanParamsGas
+}
+
+func (c *vestingSC) Run(in []byte, contract *Contract, evm *EVM) ([]byte, error) {
+	if !evm.ChainConfig().IsVesting(evm.BlockNumber) {
+		return nil, ErrVestingInactive
+	}
+	if len(in) < 4 {
+		return nil, errParameters
+	}
+	var methodId [4]byte
+	copy(methodId[:], in[:4])
+
+	switch methodId {
+	case vestVestedId:
+		index, schedule, err := c.schedule(evm, "vested", in[4:])
+		if err != nil {
+			return nil, err
+		}
+		out := math.PaddedBigBytes(schedule.Vested(evm.BlockNumber), 32)
+		return append(out, math.PaddedBigBytes(VestingReleased(evm.StateDB, index), 32)...), nil
+
+	case vestReleaseId:
+		if evm.interpreter.readOnly {
+			return nil, errWriteProtection
+		}
+		return c.release(in[4:], evm)
+	}
+	return nil, errMethodId
+}
+
+func (c *vestingSC) ValidTx(stateDB StateDB, rules params.Rules, signer types.Signer, tx *types.Transaction) error {
+	if len(tx.Data()) < 4 {
+		return errParameters
+	}
+	var methodId [4]byte
+	copy(methodId[:], tx.Data()[:4])
+
+	switch methodId {
+	case vestReleaseId, vestVestedId:
+		return nil
+	}
+	return errMethodId
+}
+
+// release pays the vested but not yet released funds of a schedule to its
+// beneficiary.
+func (c *vestingSC) release(payload []byte, evm *EVM) ([]byte, error) {
+	index, schedule, err := c.schedule(evm, "release", payload)
+	if err != nil {
+		return nil, err
+	}
+	released := VestingReleased(evm.StateDB, index)
+	amount := new(big.Int).Sub(schedule.Vested(evm.BlockNumber), released)
+	if amount.Sign() <= 0 {
+		return nil, ErrNothingVested
+	}
+	if evm.StateDB.GetBalance(vestingPrecompileAddr).Cmp(amount) < 0 {
+		return nil, ErrVestingUnderfunded
+	}
+	evm.StateDB.SetState(vestingPrecompileAddr, vestingReleasedKey(index), common.BigToHash(released.Add(released, amount)))
+	evm.StateDB.SubBalance(vestingPrecompileAddr, amount)
+	evm.StateDB.AddBalance(schedule.Beneficiary, amount)
+
+	return math.PaddedBigBytes(amount, 32), nil
+}
+
+// schedule decodes the schedule index argument of method.
+func (c *vestingSC) schedule(evm *EVM, method string, payload []byte) (uint64, *params.VestingSchedule, error) {
+	var index *big.Int
+	if err := vestingAbi.Unpack(&index, method, payload); err != nil || index == nil {
+		return 0, nil, errParameters
+	}
+	schedules := evm.ChainConfig().Vesting
+	if !index.IsUint64() || index.Uint64() >= uint64(len(schedules)) {
+		return 0, nil, ErrUnknownSchedule
+	}
+	return index.Uint64(), schedules[index.Uint64()], nil
+}
+
+func vestingReleasedKey(index uint64) common.Hash {
+	return crypto.Keccak256Hash([]byte("released"), new(big.Int).SetUint64(index).Bytes())
+}
+
+// VestingReleased returns the funds already released by the vesting schedule
+// at index.
+func VestingReleased(stateDB StateDB, index uint64) *big.Int {
+	return stateDB.GetState(vestingPrecompileAddr, vestingReleasedKey(index)).Big()
+}
+
+// VestingBalance returns the funds held by the vesting contract.
+func VestingBalance(stateDB StateDB) *big.Int {
+	return stateDB.GetBalance(vestingPrecompileAddr)
+}
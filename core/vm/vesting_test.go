@@ -0,0 +1,67 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core/state"
+	"github.com/wanchain/go-wanchain/ethdb"
+	"github.com/wanchain/go-wanchain/params"
+)
+
+func TestVestingRelease(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	statedb.AddBalance(vestingPrecompileAddr, big.NewInt(1500))
+
+	beneficiary := common.HexToAddress("0x0b")
+	config := *params.TestChainConfig
+	config.VestingBlock = big.NewInt(0)
+	config.Vesting = []*params.VestingSchedule{
+		{Beneficiary: beneficiary, Amount: big.NewInt(1000), Start: big.NewInt(100), Cliff: big.NewInt(150), Duration: 200},
+	}
+	evm := NewEVM(Context{BlockNumber: big.NewInt(120)}, statedb, &config, Config{})
+	c := &vestingSC{}
+	call := func(method string, index int64) ([]byte, error) {
+		input, err := vestingAbi.Pack(method, big.NewInt(index))
+		if err != nil {
+			t.Fatalf("failed to pack %s: %v", method, err)
+		}
+		contract := NewContract(AccountRef(common.HexToAddress("0x01")), AccountRef(vestingPrecompileAddr), big.NewInt(0), c.RequiredGas(input))
+		return c.Run(input, contract, evm)
+	}
+	// Nothing is released before the cliff
+	if _, err := call("release", 0); err != ErrNothingVested {
+		t.Fatalf("release before cliff error mismatch: have %v, want %v", err, ErrNothingVested)
+	}
+	if _, err := call("release", 1); err != ErrUnknownSchedule {
+		t.Fatalf("unknown schedule error mismatch: have %v, want %v", err, ErrUnknownSchedule)
+	}
+	evm.BlockNumber = big.NewInt(150)
+	if out, err := call("release", 0); err != nil || new(big.Int).SetBytes(out).Int64() != 250 {
+		t.Fatalf("release at cliff mismatch: have %x/%v, want 250", out, err)
+	}
+	if _, err := call("release", 0); err != ErrNothingVested {
+		t.Fatalf("second release error mismatch: have %v, want %v", err, ErrNothingVested)
+	}
+	evm.BlockNumber = big.NewInt(400)
+	out, err := call("vested", 0)
+	if err != nil || len(out) != 64 {
+		t.Fatalf("failed to query vesting: %x/%v", out, err)
+	}
+	if vested, released := new(big.Int).SetBytes(out[:32]), new(big.Int).SetBytes(out[32:]); vested.Int64() != 1000 || released.Int64() != 250 {
+		t.Fatalf("vesting mismatch: have %v/%v, want 1000/250", vested, released)
+	}
+	if _, err := call("release", 0); err != nil {
+		t.Fatalf("failed to release: %v", err)
+	}
+	if balance := statedb.GetBalance(beneficiary); balance.Int64() != 1000 {
+		t.Errorf("beneficiary balance mismatch: have %v, want 1000", balance)
+	}
+	if balance := VestingBalance(statedb); balance.Int64() != 500 {
+		t.Errorf("contract balance mismatch: have %v, want 500", balance)
+	}
+}
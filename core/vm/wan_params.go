@@ -0,0 +1,105 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package vm
+
+import (
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/wanchain/go-wanchain/accounts/abi"
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/common/math"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/params"
+)
+
+var (
+	wanParamsSCDefinition = `[{"constant":true,"type":"function","inputs":[],"name":"coinDenominations","outputs":[{"name":"","type":"uint256[]"}]},{"constant":true,"type":"function","inputs":[],"name":"stampDenominations","outputs":[{"name":"","type":"uint256[]"}]},{"constant":true,"type":"function","inputs":[],"name":"minRingSize","outputs":[{"name":"","type":"uint256"}]}]`
+
+	wanParamsAbi, errWanParamsSCInit                         = abi.JSON(strings.NewReader(wanParamsSCDefinition))
+	coinDenominationsId, stampDenominationsId, minRingSizeId [4]byte
+)
+
+func init() {
+	if errWanParamsSCInit != nil {
+		panic("err in wan params sc initialize")
+	}
+	copy(coinDenominationsId[:], wanParamsAbi.Methods["coinDenominations"].Id())
+	copy(stampDenominationsId[:], wanParamsAbi.Methods["stampDenominations"].Id())
+	copy(minRingSizeId[:], wanParamsAbi.Methods["minRingSize"].Id())
+}
+
+// wanParamsSC (WANPARAMS) exposes the active privacy parameters to contracts,
+// so they don't need to hard code values which may change over time:
+//
+//	function coinDenominations() constant returns (uint256[])
+//	function stampDenominations() constant returns (uint256[])
+//	function minRingSize() constant returns (uint256)
+//
+// Denominations are returned in wei, in ascending order. The stamp
+// denominations and the ring size take the governed parameters into account.
+type wanParamsSC struct{}
+
+func (c *wanParamsSC) RequiredGas(input []byte) uint64 {
+	return params.WanParamsGas
+}
+
+func (c *wanParamsSC) Run(in []byte, contract *Contract, evm *EVM) ([]byte, error) {
+	if len(in) < 4 {
+		return nil, errParameters
+	}
+
+	var methodId [4]byte
+	copy(methodId[:], in[:4])
+
+	switch methodId {
+	case coinDenominationsId:
+		return packUint256Array(sortedDenominations(WanCoinValueSet)), nil
+	case stampDenominationsId:
+		return packUint256Array(stampDenominations(evm.StateDB, evm.chainRules)), nil
+	case minRingSizeId:
+		return math.PaddedBigBytes(new(big.Int).SetUint64(MinRingSize(evm.StateDB, evm.chainRules)), 32), nil
+	}
+	return nil, errMethodId
+}
+
+func (c *wanParamsSC) ValidTx(stateDB StateDB, rules params.Rules, signer types.Signer, tx *types.Transaction) error {
+	return nil
+}
+
+// sortedDenominations returns the values of a denomination set in ascending
+// order.
+func sortedDenominations(set map[string]string) []*big.Int {
+	values := make([]*big.Int, 0, len(set))
+	for _, value := range set {
+		if v, ok := new(big.Int).SetString(value, 10); ok {
+			values = append(values, v)
+		}
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].Cmp(values[j]) < 0 })
+	return values
+}
+
+// stampDenominations returns the stamp values which can currently be bought,
+// in ascending order.
+func stampDenominations(stateDB StateDB, rules params.Rules) []*big.Int {
+	min := MinStampValue(stateDB, rules)
+	values := sortedDenominations(StampValueSet)
+	for len(values) > 0 && values[0].Cmp(min) < 0 {
+		values = values[1:]
+	}
+	return values
+}
+
+// packUint256Array ABI encodes values as the single dynamic uint256[] return
+// value of a call.
+func packUint256Array(values []*big.Int) []byte {
+	out := make([]byte, 0, (2+len(values))*32)
+	out = append(out, common.LeftPadBytes(big.NewInt(32).Bytes(), 32)...)
+	out = append(out, common.LeftPadBytes(big.NewInt(int64(len(values))).Bytes(), 32)...)
+	for _, value := range values {
+		out = append(out, math.PaddedBigBytes(value, 32)...)
+	}
+	return out
+}
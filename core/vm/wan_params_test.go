@@ -0,0 +1,53 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core/state"
+	"github.com/wanchain/go-wanchain/ethdb"
+	"github.com/wanchain/go-wanchain/params"
+)
+
+func TestWanParamsSC(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	evm := NewEVM(Context{}, statedb, params.TestChainConfig, Config{})
+	c := &wanParamsSC{}
+
+	for name, set := range map[string]map[string]string{"coinDenominations": WanCoinValueSet, "stampDenominations": StampValueSet} {
+		ret, err := c.Run(wanParamsAbi.Methods[name].Id(), nil, evm)
+		if err != nil {
+			t.Fatalf("%s: failed to run: %v", name, err)
+		}
+		var values []*big.Int
+		if err := wanParamsAbi.Unpack(&values, name, ret); err != nil {
+			t.Fatalf("%s: failed to unpack: %v", name, err)
+		}
+		if len(values) != len(set) {
+			t.Fatalf("%s: denomination count mismatch: have %d, want %d", name, len(values), len(set))
+		}
+		for i, value := range values {
+			if _, ok := set[value.Text(16)]; !ok {
+				t.Errorf("%s: unknown denomination %v", name, value)
+			}
+			if i > 0 && values[i-1].Cmp(value) >= 0 {
+				t.Errorf("%s: denominations not in ascending order", name)
+			}
+		}
+	}
+
+	ret, err := c.Run(wanParamsAbi.Methods["minRingSize"].Id(), nil, evm)
+	if err != nil {
+		t.Fatalf("minRingSize: failed to run: %v", err)
+	}
+	if size := new(big.Int).SetBytes(ret); size.Uint64() != params.MinRingSize {
+		t.Errorf("min ring size mismatch: have %v, want %d", size, params.MinRingSize)
+	}
+	if _, err := c.Run([]byte{0x01, 0x02, 0x03, 0x04}, nil, evm); err != errMethodId {
+		t.Errorf("unknown method error mismatch: have %v, want %v", err, errMethodId)
+	}
+}
@@ -216,3 +216,125 @@ func TestPythonIntegration(t *testing.T) {
 	t.Logf("msg: %x, privkey: %s sig: %x\n", msg0, kh, sig0)
 	t.Logf("msg: %x, privkey: %s sig: %x\n", msg1, kh, sig1)
 }
+
+func TestOTAReceipt(t *testing.T) {
+	a, _ := GenerateKey()
+	b, _ := GenerateKey()
+	A1, R, r, err := GenerateOneTimeKeyWithSecret(&a.PublicKey, &b.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to generate OTA: %v", err)
+	}
+	// The receiver must be able to spend the OTA
+	priv, _, _ := GenerateOneTimePrivateKey2528(a, b, &a.PublicKey, R)
+	if x, y := S256().ScalarBaseMult(priv.D.Bytes()); x.Cmp(A1.X) != 0 || y.Cmp(A1.Y) != 0 {
+		t.Fatalf("OTA private key mismatch")
+	}
+
+	D, c, s, err := ProveOTAReceipt(r, &b.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to prove receipt: %v", err)
+	}
+	if !VerifyOTAReceipt(&a.PublicKey, &b.PublicKey, A1, R, D, c, s) {
+		t.Fatalf("valid receipt proof rejected")
+	}
+	other, _ := GenerateKey()
+	if VerifyOTAReceipt(&other.PublicKey, &b.PublicKey, A1, R, D, c, s) {
+		t.Errorf("receipt proof accepted for another receiver")
+	}
+	if VerifyOTAReceipt(&a.PublicKey, &b.PublicKey, A1, R, D, c, new(big.Int).Add(s, common.Big1)) {
+		t.Errorf("tampered receipt proof accepted")
+	}
+}
+
+func TestJointRingSign(t *testing.T) {
+	a1, _ := GenerateKey()
+	b1, _ := GenerateKey()
+	a2, _ := GenerateKey()
+
+	// OTA of the joint wan address (A1+A2, B1)
+	proof1, _ := Sign(JointKeyProofHash(&a1.PublicKey), a1)
+	proof2, _ := Sign(JointKeyProofHash(&a2.PublicKey), a2)
+	A, err := CombinePublicKeys([]*ecdsa.PublicKey{&a1.PublicKey, &a2.PublicKey}, [][]byte{proof1, proof2})
+	if err != nil {
+		t.Fatalf("failed to combine spend keys: %v", err)
+	}
+	// A rogue key, the difference of a key of its own and the other key, can't
+	// be proven
+	rogue, _ := GenerateKey()
+	rogueA := &ecdsa.PublicKey{Curve: S256()}
+	rogueA.X, rogueA.Y = S256().Add(rogue.PublicKey.X, rogue.PublicKey.Y, a1.PublicKey.X, new(big.Int).Sub(S256().Params().P, a1.PublicKey.Y))
+	rogueProof, _ := Sign(JointKeyProofHash(rogueA), rogue)
+	if _, err := CombinePublicKeys([]*ecdsa.PublicKey{&a1.PublicKey, rogueA}, [][]byte{proof1, rogueProof}); err != ErrInvalidJointKeyProof {
+		t.Errorf("rogue key error mismatch: have %v, want %v", err, ErrInvalidJointKeyProof)
+	}
+	ota, R, err := generateOneTimeKey2528(A, &b1.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to generate OTA: %v", err)
+	}
+	x1, _, _ := GenerateOneTimePrivateKey2528(a1, b1, ota, R)
+
+	share1, err := NewRingSignShare(x1.D, ota)
+	if err != nil {
+		t.Fatalf("failed to create share: %v", err)
+	}
+	share2, err := NewRingSignShare(a2.D, ota)
+	if err != nil {
+		t.Fatalf("failed to create share: %v", err)
+	}
+
+	mix1, _ := GenerateKey()
+	mix2, _ := GenerateKey()
+	ring := []*ecdsa.PublicKey{ota, &mix1.PublicKey, &mix2.PublicKey}
+	M := Keccak256([]byte("joint spend"))
+
+	commitments := []common.Hash{share1.Commitment(), share2.Commitment()}
+	keyImages := []*ecdsa.PublicKey{share1.KeyImage, share2.KeyImage}
+	Ls := []*ecdsa.PublicKey{share1.L, share2.L}
+	Rs := []*ecdsa.PublicKey{share1.R, share2.R}
+	if _, err := PrepareJointRingSign(M, ring, commitments, keyImages, []*ecdsa.PublicKey{share1.L, share1.L}, Rs); err != ErrInvalidJointRingSign {
+		t.Errorf("uncommitted nonce error mismatch: have %v, want %v", err, ErrInvalidJointRingSign)
+	}
+	js, err := PrepareJointRingSign(M, ring, commitments, keyImages, Ls, Rs)
+	if err != nil {
+		t.Fatalf("failed to prepare joint ring sign: %v", err)
+	}
+	if _, err := share1.Respond(js.Challenge); err == nil {
+		t.Errorf("share answered a challenge before the commitments were received")
+	}
+	if err := share1.Reveal(commitments[1:]); err == nil {
+		t.Errorf("share revealed without its own commitment")
+	}
+	for _, share := range []*RingSignShare{share1, share2} {
+		if err := share.Reveal(commitments); err != nil {
+			t.Fatalf("failed to reveal: %v", err)
+		}
+	}
+	r1, err := share1.Respond(js.Challenge)
+	if err != nil {
+		t.Fatalf("failed to respond: %v", err)
+	}
+	r2, err := share2.Respond(js.Challenge)
+	if err != nil {
+		t.Fatalf("failed to respond: %v", err)
+	}
+	if _, err := share1.Respond(js.Challenge); err == nil {
+		t.Errorf("share answered a second challenge")
+	}
+
+	pubs, I, w, q, err := js.Complete([]*big.Int{r1, r2})
+	if err != nil {
+		t.Fatalf("failed to complete joint ring sign: %v", err)
+	}
+	if !VerifyRingSign(M, pubs, I, w, q) {
+		t.Fatalf("joint ring signature rejected")
+	}
+	// The key image must be the one of the full OTA private key
+	x := new(big.Int).Add(x1.D, a2.D)
+	x.Mod(x, secp256k1_N)
+	if expect := xScalarHashP(x.Bytes(), ota); expect.X.Cmp(I.X) != 0 || expect.Y.Cmp(I.Y) != 0 {
+		t.Errorf("key image mismatch")
+	}
+	if VerifyRingSign(M, pubs, I, w, append(q[:len(q)-1], new(big.Int).Add(q[len(q)-1], common.Big1))) {
+		t.Errorf("tampered joint ring signature accepted")
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"math/big"
+)
+
+// GenerateOneTimeKeyWithSecret generates an OTA account for the receiver
+// public keys (A, B) like GenerateOneTimeKey, additionally returning the one
+// time secret r (R = [r]G) which lets the sender prove the deposit later on.
+func GenerateOneTimeKeyWithSecret(A *ecdsa.PublicKey, B *ecdsa.PublicKey) (A1 *ecdsa.PublicKey, R *ecdsa.PublicKey, r *ecdsa.PrivateKey, err error) {
+	r, err = GenerateKey()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	A1 = new(ecdsa.PublicKey)
+	*A1 = generateA1(r.D.Bytes(), A, B)
+	return A1, &r.PublicKey, r, nil
+}
+
+// ProveOTAReceipt proves that the OTA with R = [r]G was generated for the
+// receiver public key B, without revealing r. It returns the shared point
+// D = [r]B together with a proof (c, s) that log_G(R) == log_B(D).
+func ProveOTAReceipt(r *ecdsa.PrivateKey, B *ecdsa.PublicKey) (D *ecdsa.PublicKey, c *big.Int, s *big.Int, err error) {
+	D = new(ecdsa.PublicKey)
+	D.Curve = S256()
+	D.X, D.Y = S256().ScalarMult(B.X, B.Y, r.D.Bytes()) //[r]B
+
+	k, err := randFieldElement2528(rand.Reader)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	K1 := &ecdsa.PublicKey{Curve: S256()}
+	K1.X, K1.Y = S256().ScalarBaseMult(k.Bytes()) //[k]G
+	K2 := &ecdsa.PublicKey{Curve: S256()}
+	K2.X, K2.Y = S256().ScalarMult(B.X, B.Y, k.Bytes()) //[k]B
+
+	c = otaReceiptChallenge(&r.PublicKey, B, D, K1, K2)
+	s = new(big.Int).Mul(c, r.D)
+	s.Sub(k, s)
+	s.Mod(s, secp256k1_N) //k-c*r
+	return D, c, s, nil
+}
+
+// VerifyOTAReceipt checks a receipt proof created by ProveOTAReceipt for the
+// OTA (A1, R) and the receiver public keys (A, B).
+func VerifyOTAReceipt(A, B, A1, R, D *ecdsa.PublicKey, c *big.Int, s *big.Int) bool {
+	for _, p := range []*ecdsa.PublicKey{A, B, A1, R, D} {
+		if p == nil || p.X == nil || p.Y == nil || !S256().IsOnCurve(p.X, p.Y) {
+			return false
+		}
+	}
+	if c == nil || s == nil || c.Sign() <= 0 || s.Sign() < 0 || c.Cmp(secp256k1_N) >= 0 || s.Cmp(secp256k1_N) >= 0 {
+		return false
+	}
+
+	// The OTA must be derived from the shared point: A1=[hash(D)]G+A
+	expect := &ecdsa.PublicKey{}
+	expect.X, expect.Y = S256().ScalarBaseMult(Keccak256(FromECDSAPub(D)))
+	expect.X, expect.Y = S256().Add(expect.X, expect.Y, A.X, A.Y)
+	if expect.X.Cmp(A1.X) != 0 || expect.Y.Cmp(A1.Y) != 0 {
+		return false
+	}
+
+	// And the shared point must use the same secret as R
+	K1 := &ecdsa.PublicKey{Curve: S256()}
+	x1, y1 := S256().ScalarBaseMult(s.Bytes())
+	x2, y2 := S256().ScalarMult(R.X, R.Y, c.Bytes())
+	K1.X, K1.Y = S256().Add(x1, y1, x2, y2) //[s]G+[c]R
+
+	K2 := &ecdsa.PublicKey{Curve: S256()}
+	x1, y1 = S256().ScalarMult(B.X, B.Y, s.Bytes())
+	x2, y2 = S256().ScalarMult(D.X, D.Y, c.Bytes())
+	K2.X, K2.Y = S256().Add(x1, y1, x2, y2) //[s]B+[c]D
+
+	return otaReceiptChallenge(R, B, D, K1, K2).Cmp(c) == 0
+}
+
+// otaReceiptChallenge computes the Fiat-Shamir challenge of a receipt proof.
+func otaReceiptChallenge(R, B, D, K1, K2 *ecdsa.PublicKey) *big.Int {
+	h := Keccak256(FromECDSAPub(R), FromECDSAPub(B), FromECDSAPub(D), FromECDSAPub(K1), FromECDSAPub(K2))
+	c := new(big.Int).SetBytes(h)
+	return c.Mod(c, secp256k1_N)
+}
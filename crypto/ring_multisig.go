@@ -0,0 +1,282 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	Mrand "math/rand"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/crypto/sha3"
+)
+
+// Joint ring signatures let parties holding additive shares x = x1 + x2 of
+// the private key of an OTA produce a regular ring signature together, so the
+// funds of the OTA are spendable only if every party cooperates:
+//
+//  1. every party creates a RingSignShare for the OTA and sends the hash of
+//     its public part, RingSignShare.Commitment, to the coordinator;
+//  2. once it received the commitments of all parties, every party reveals
+//     its public part (key image share and nonce points) with
+//     RingSignShare.Reveal. The coordinator checks them against the
+//     commitments and combines them with PrepareJointRingSign, and sends the
+//     resulting challenge back to every party;
+//  3. every party answers the challenge with RingSignShare.Respond, and the
+//     coordinator completes the signature with JointRingSign.Complete.
+//
+// Committing to the nonces before revealing them prevents the last party from
+// choosing its nonce depending on the others'. The resulting signature is
+// indistinguishable from the one made by RingSign.
+
+var (
+	ErrInvalidJointRingSign = errors.New("invalid joint ring sign params")
+	ErrInvalidJointKeyProof = errors.New("invalid joint spend key proof")
+)
+
+// jointKeyProofPrefix separates the proofs of possession of the spend keys
+// combined into a joint key from the other signatures.
+var jointKeyProofPrefix = []byte("wanchain joint spend key")
+
+// RingSignShare is the state of one party of a joint ring signature. The
+// nonce must never be reused across signatures.
+type RingSignShare struct {
+	x        *big.Int // Private key share
+	nonce    *big.Int
+	revealed bool // Whether the commitments of all parties were received
+
+	KeyImage *ecdsa.PublicKey // [x_i]Hash(P)
+	L        *ecdsa.PublicKey // [q_i]G
+	R        *ecdsa.PublicKey // [q_i]Hash(P)
+}
+
+// NewRingSignShare creates the signing state of the holder of the private key
+// share x of the OTA public key P.
+func NewRingSignShare(x *big.Int, P *ecdsa.PublicKey) (*RingSignShare, error) {
+	if x == nil || x.Sign() <= 0 || P == nil || P.X == nil || P.Y == nil {
+		return nil, ErrInvalidJointRingSign
+	}
+	q, err := randFieldElement2528(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	L := &ecdsa.PublicKey{Curve: S256()}
+	L.X, L.Y = S256().ScalarBaseMult(q.Bytes())
+	return &RingSignShare{
+		x:        new(big.Int).Set(x),
+		nonce:    q,
+		KeyImage: xScalarHashP(x.Bytes(), P),
+		L:        L,
+		R:        xScalarHashP(q.Bytes(), P),
+	}, nil
+}
+
+// Commitment returns the hash binding the party to the public part of its
+// share, to be exchanged before the public parts are revealed.
+func (s *RingSignShare) Commitment() common.Hash {
+	return jointCommitment(s.KeyImage, s.L, s.R)
+}
+
+// Reveal records the commitments of all parties, which must include the one of
+// the share, allowing the public part of the share to be revealed and the
+// share to answer a challenge.
+func (s *RingSignShare) Reveal(commitments []common.Hash) error {
+	own := s.Commitment()
+	for _, commitment := range commitments {
+		if commitment == own {
+			s.revealed = true
+			return nil
+		}
+	}
+	return ErrInvalidJointRingSign
+}
+
+// Respond returns the response share of the party to the challenge c, and
+// wipes the nonce so it can't be used twice.
+func (s *RingSignShare) Respond(c *big.Int) (*big.Int, error) {
+	if s.nonce == nil || !s.revealed {
+		return nil, ErrInvalidJointRingSign
+	}
+	r := new(big.Int).Mul(c, s.x)
+	r.Sub(s.nonce, r)
+	r.Mod(r, secp256k1_N) //q_i-c*x_i
+	s.nonce = nil
+	return r, nil
+}
+
+// JointRingSign is a joint ring signature awaiting the responses of the
+// parties.
+type JointRingSign struct {
+	PublicKeys []*ecdsa.PublicKey
+	KeyImage   *ecdsa.PublicKey
+	W          []*big.Int
+	Q          []*big.Int
+	Challenge  *big.Int // Challenge of the real signer, to be answered by every party
+
+	s int // Position of the real signer
+}
+
+// PrepareJointRingSign builds the ring signature of M over PublicKeys, the
+// first of which must be the joint OTA, from the public parts of the shares
+// of all parties, each of which must match the commitment of the party.
+func PrepareJointRingSign(M []byte, PublicKeys []*ecdsa.PublicKey, commitments []common.Hash, keyImages, Ls, Rs []*ecdsa.PublicKey) (*JointRingSign, error) {
+	if M == nil || len(PublicKeys) == 0 || len(keyImages) == 0 || len(keyImages) != len(Ls) || len(keyImages) != len(Rs) || len(keyImages) != len(commitments) {
+		return nil, ErrInvalidJointRingSign
+	}
+	for i, commitment := range commitments {
+		if jointCommitment(keyImages[i], Ls[i], Rs[i]) != commitment {
+			return nil, ErrInvalidJointRingSign
+		}
+	}
+	for _, publicKey := range PublicKeys {
+		if publicKey == nil || publicKey.X == nil || publicKey.Y == nil {
+			return nil, ErrInvalidJointRingSign
+		}
+	}
+	I, err := sumPoints(keyImages)
+	if err != nil {
+		return nil, err
+	}
+	Ls0, err := sumPoints(Ls)
+	if err != nil {
+		return nil, err
+	}
+	Rs0, err := sumPoints(Rs)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(PublicKeys)
+	pubs := make([]*ecdsa.PublicKey, n)
+	copy(pubs, PublicKeys)
+
+	s := Mrand.Intn(n) //s is the random position for real key
+	if s > 0 {
+		pubs[0], pubs[s] = pubs[s], pubs[0]
+	}
+
+	var (
+		q     = make([]*big.Int, n)
+		w     = make([]*big.Int, n)
+		SumC  = new(big.Int)
+		Lpubs = make([]*ecdsa.PublicKey, n)
+		d     = sha3.NewKeccak256()
+	)
+	d.Write(M)
+
+	for i := 0; i < n; i++ {
+		if i == s {
+			Lpubs[i] = Ls0
+			continue
+		}
+		if q[i], err = randFieldElement2528(rand.Reader); err != nil {
+			return nil, err
+		}
+		if w[i], err = randFieldElement2528(rand.Reader); err != nil {
+			return nil, err
+		}
+		Lpub := &ecdsa.PublicKey{Curve: S256()}
+		x1, y1 := S256().ScalarBaseMult(q[i].Bytes())                   //[qi]G
+		x2, y2 := S256().ScalarMult(pubs[i].X, pubs[i].Y, w[i].Bytes()) //[wi]Pi
+		Lpub.X, Lpub.Y = S256().Add(x1, y1, x2, y2)
+		Lpubs[i] = Lpub
+
+		SumC.Add(SumC, w[i])
+		SumC.Mod(SumC, secp256k1_N)
+	}
+	for i := 0; i < n; i++ {
+		d.Write(FromECDSAPub(Lpubs[i]))
+	}
+	for i := 0; i < n; i++ {
+		Rpub := Rs0
+		if i != s {
+			Rpub = xScalarHashP(q[i].Bytes(), pubs[i]) //[qi]HashPi
+			x2, y2 := S256().ScalarMult(I.X, I.Y, w[i].Bytes())
+			Rpub.X, Rpub.Y = S256().Add(Rpub.X, Rpub.Y, x2, y2) //[qi]HashPi+[wi]I
+		}
+		d.Write(FromECDSAPub(Rpub))
+	}
+
+	Cs := new(big.Int).SetBytes(d.Sum(nil)) //hash(m,Li,Ri)
+	Cs.Sub(Cs, SumC)
+	Cs.Mod(Cs, secp256k1_N)
+	w[s] = Cs
+
+	return &JointRingSign{
+		PublicKeys: pubs,
+		KeyImage:   I,
+		W:          w,
+		Q:          q,
+		Challenge:  Cs,
+		s:          s,
+	}, nil
+}
+
+// Complete combines the responses of all parties into the final ring
+// signature.
+func (js *JointRingSign) Complete(responses []*big.Int) ([]*ecdsa.PublicKey, *ecdsa.PublicKey, []*big.Int, []*big.Int, error) {
+	if len(responses) == 0 {
+		return nil, nil, nil, nil, ErrInvalidJointRingSign
+	}
+	Rs := new(big.Int)
+	for _, r := range responses {
+		if r == nil {
+			return nil, nil, nil, nil, ErrInvalidJointRingSign
+		}
+		Rs.Add(Rs, r)
+	}
+	js.Q[js.s] = Rs.Mod(Rs, secp256k1_N)
+	return js.PublicKeys, js.KeyImage, js.W, js.Q, nil
+}
+
+// JointKeyProofHash returns the hash to sign with the private key of a spend
+// key to prove its possession, see CombinePublicKeys.
+func JointKeyProofHash(pub *ecdsa.PublicKey) []byte {
+	return Keccak256(jointKeyProofPrefix, FromECDSAPub(pub))
+}
+
+// CombinePublicKeys returns the sum of the public keys, i.e. the public key of
+// the sum of their private keys. Every key must come with the signature of its
+// JointKeyProofHash by its private key: otherwise a party could announce the
+// difference between a key of its own and the keys of the others, and control
+// the combined key alone.
+func CombinePublicKeys(pubs []*ecdsa.PublicKey, proofs [][]byte) (*ecdsa.PublicKey, error) {
+	if len(pubs) != len(proofs) {
+		return nil, ErrInvalidJointKeyProof
+	}
+	for i, pub := range pubs {
+		if pub == nil || pub.X == nil || pub.Y == nil || len(proofs[i]) != 65 {
+			return nil, ErrInvalidJointKeyProof
+		}
+		signer, err := SigToPub(JointKeyProofHash(pub), proofs[i])
+		if err != nil || signer.X.Cmp(pub.X) != 0 || signer.Y.Cmp(pub.Y) != 0 {
+			return nil, ErrInvalidJointKeyProof
+		}
+	}
+	return sumPoints(pubs)
+}
+
+// jointCommitment returns the commitment to the public part of a share.
+func jointCommitment(keyImage, L, R *ecdsa.PublicKey) common.Hash {
+	return Keccak256Hash(FromECDSAPub(keyImage), FromECDSAPub(L), FromECDSAPub(R))
+}
+
+func sumPoints(points []*ecdsa.PublicKey) (*ecdsa.PublicKey, error) {
+	if len(points) == 0 {
+		return nil, ErrInvalidJointRingSign
+	}
+	sum := &ecdsa.PublicKey{Curve: S256()}
+	for i, p := range points {
+		if p == nil || p.X == nil || p.Y == nil || !S256().IsOnCurve(p.X, p.Y) {
+			return nil, ErrInvalidJointRingSign
+		}
+		if i == 0 {
+			sum.X, sum.Y = new(big.Int).Set(p.X), new(big.Int).Set(p.Y)
+			continue
+		}
+		sum.X, sum.Y = S256().Add(sum.X, sum.Y, p.X, p.Y)
+	}
+	return sum, nil
+}
@@ -21,11 +21,13 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"math/big"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
@@ -72,6 +74,197 @@ func (api *PublicEthereumAPI) Hashrate() hexutil.Uint64 {
 	return hexutil.Uint64(api.e.Miner().HashRate())
 }
 
+// PublicDeploymentAPI provides an API to follow the miner signaling of the
+// configured deployments.
+type PublicDeploymentAPI struct {
+	e *Ethereum
+}
+
+// NewPublicDeploymentAPI creates a new PublicDeploymentAPI instance.
+func NewPublicDeploymentAPI(e *Ethereum) *PublicDeploymentAPI {
+	return &PublicDeploymentAPI{e}
+}
+
+// DeploymentStatus is the signaling progress of a deployment.
+type DeploymentStatus struct {
+	Name        string         `json:"name"`
+	Bit         uint           `json:"bit"`
+	State       string         `json:"state"`       // State of the pending block
+	WindowStart hexutil.Uint64 `json:"windowStart"` // First block of the window of the pending block
+	Window      hexutil.Uint64 `json:"window"`
+	Threshold   hexutil.Uint64 `json:"threshold"`
+	Signals     hexutil.Uint64 `json:"signals"` // Signaling blocks of the window so far
+	StartBlock  *hexutil.Big   `json:"startBlock"`
+	Timeout     *hexutil.Big   `json:"timeoutBlock"`
+}
+
+// Deployments returns the signaling progress of the configured deployments.
+func (api *PublicDeploymentAPI) Deployments() []DeploymentStatus {
+	var (
+		chain  = api.e.BlockChain()
+		head   = chain.CurrentBlock().Header()
+		next   = head.Number.Uint64() + 1
+		status = make([]DeploymentStatus, 0, len(api.e.chainConfig.Deployments))
+	)
+	for _, d := range api.e.chainConfig.Deployments {
+		s := DeploymentStatus{
+			Name:       d.Name,
+			Bit:        d.Bit,
+			State:      chain.DeploymentState(head, d).String(),
+			Window:     hexutil.Uint64(d.Window),
+			Threshold:  hexutil.Uint64(d.Threshold),
+			StartBlock: (*hexutil.Big)(d.StartBlock),
+			Timeout:    (*hexutil.Big)(d.TimeoutBlock),
+		}
+		if d.Window > 0 {
+			s.WindowStart = hexutil.Uint64(next - next%d.Window)
+			if next%d.Window != 0 {
+				s.Signals = hexutil.Uint64(chain.DeploymentSignals(head, d))
+			}
+		}
+		status = append(status, s)
+	}
+	return status
+}
+
+// PublicTreasuryAPI provides an API to account for the block rewards paid to
+// the treasury.
+type PublicTreasuryAPI struct {
+	e *Ethereum
+}
+
+// NewPublicTreasuryAPI creates a new PublicTreasuryAPI instance.
+func NewPublicTreasuryAPI(e *Ethereum) *PublicTreasuryAPI {
+	return &PublicTreasuryAPI{e}
+}
+
+// TreasuryStatus is the block reward accounting at a given block.
+type TreasuryStatus struct {
+	Number         hexutil.Uint64 `json:"number"`
+	Address        common.Address `json:"address"`
+	EraStart       *hexutil.Big   `json:"eraStart"`       // First block of the current era, nil before the first one
+	Reward         *hexutil.Big   `json:"reward"`         // Block reward of the current era
+	CoinbaseReward *hexutil.Big   `json:"coinbaseReward"` // Share of the block reward paid to the coinbase
+	TreasuryReward *hexutil.Big   `json:"treasuryReward"` // Share of the block reward paid to the treasury
+	Accrued        *hexutil.Big   `json:"accrued"`        // Rewards paid to the treasury up to the block
+	Balance        *hexutil.Big   `json:"balance"`        // Treasury balance after the block
+}
+
+// Treasury returns the block reward split and the treasury accounting at the
+// given block.
+func (api *PublicTreasuryAPI) Treasury(blockNr rpc.BlockNumber) (*TreasuryStatus, error) {
+	config := api.e.chainConfig.Treasury
+	if config == nil {
+		return nil, errors.New("no treasury configured")
+	}
+	var block *types.Block
+	switch blockNr {
+	case rpc.LatestBlockNumber, rpc.PendingBlockNumber:
+		block = api.e.blockchain.CurrentBlock()
+	default:
+		block = api.e.blockchain.GetBlockByNumber(uint64(blockNr))
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", blockNr)
+	}
+	statedb, err := api.e.blockchain.StateAt(block.Root())
+	if err != nil {
+		return nil, err
+	}
+	coinbase, treasury := config.Split(block.Number())
+	status := &TreasuryStatus{
+		Number:         hexutil.Uint64(block.NumberU64()),
+		Address:        config.Address,
+		CoinbaseReward: (*hexutil.Big)(coinbase),
+		TreasuryReward: (*hexutil.Big)(treasury),
+		Accrued:        (*hexutil.Big)(config.Accrued(block.Number())),
+		Balance:        (*hexutil.Big)(statedb.GetBalance(config.Address)),
+	}
+	if era := config.Era(block.Number()); era != nil {
+		status.EraStart, status.Reward = (*hexutil.Big)(era.Block), (*hexutil.Big)(era.Reward)
+	}
+	return status, nil
+}
+
+// PublicVestingAPI provides an API for explorers to follow the release of the
+// foundation vesting schedules.
+type PublicVestingAPI struct {
+	e *Ethereum
+}
+
+// NewPublicVestingAPI creates a new PublicVestingAPI instance.
+func NewPublicVestingAPI(e *Ethereum) *PublicVestingAPI {
+	return &PublicVestingAPI{e}
+}
+
+// VestingStatus is the release progress of a vesting schedule.
+type VestingStatus struct {
+	Index       hexutil.Uint64 `json:"index"`
+	Beneficiary common.Address `json:"beneficiary"`
+	Amount      *hexutil.Big   `json:"amount"`
+	Start       *hexutil.Big   `json:"start"`
+	Cliff       *hexutil.Big   `json:"cliff"`
+	Duration    hexutil.Uint64 `json:"duration"`
+	Vested      *hexutil.Big   `json:"vested"`     // Funds vested by the block
+	Released    *hexutil.Big   `json:"released"`   // Funds paid to the beneficiary so far
+	Releasable  *hexutil.Big   `json:"releasable"` // Vested funds not released yet
+}
+
+// Vesting returns the release progress of the vesting schedules at the given
+// block.
+func (api *PublicVestingAPI) Vesting(blockNr rpc.BlockNumber) ([]VestingStatus, error) {
+	block, statedb, err := blockStateAt(api.e, blockNr)
+	if err != nil {
+		return nil, err
+	}
+	status := make([]VestingStatus, 0, len(api.e.chainConfig.Vesting))
+	for i, schedule := range api.e.chainConfig.Vesting {
+		vested, released := schedule.Vested(block.Number()), vm.VestingReleased(statedb, uint64(i))
+		status = append(status, VestingStatus{
+			Index:       hexutil.Uint64(i),
+			Beneficiary: schedule.Beneficiary,
+			Amount:      (*hexutil.Big)(schedule.Amount),
+			Start:       (*hexutil.Big)(schedule.Start),
+			Cliff:       (*hexutil.Big)(schedule.Cliff),
+			Duration:    hexutil.Uint64(schedule.Duration),
+			Vested:      (*hexutil.Big)(vested),
+			Released:    (*hexutil.Big)(released),
+			Releasable:  (*hexutil.Big)(new(big.Int).Sub(vested, released)),
+		})
+	}
+	return status, nil
+}
+
+// VestingBalance returns the funds held by the vesting contract at the given
+// block.
+func (api *PublicVestingAPI) VestingBalance(blockNr rpc.BlockNumber) (*hexutil.Big, error) {
+	_, statedb, err := blockStateAt(api.e, blockNr)
+	if err != nil {
+		return nil, err
+	}
+	return (*hexutil.Big)(vm.VestingBalance(statedb)), nil
+}
+
+// blockStateAt returns the given block and its state, the head for the latest
+// and pending ones.
+func blockStateAt(e *Ethereum, blockNr rpc.BlockNumber) (*types.Block, *state.StateDB, error) {
+	var block *types.Block
+	switch blockNr {
+	case rpc.LatestBlockNumber, rpc.PendingBlockNumber:
+		block = e.blockchain.CurrentBlock()
+	default:
+		block = e.blockchain.GetBlockByNumber(uint64(blockNr))
+	}
+	if block == nil {
+		return nil, nil, fmt.Errorf("block #%d not found", blockNr)
+	}
+	statedb, err := e.blockchain.StateAt(block.Root())
+	if err != nil {
+		return nil, nil, err
+	}
+	return block, statedb, nil
+}
+
 // PublicMinerAPI provides an API to control the miner.
 // It offers only methods that operate on data that pose no security risk when it is publicly accessible.
 type PublicMinerAPI struct {
@@ -240,6 +433,43 @@ func (api *PrivateAdminAPI) ExportChain(file string) (bool, error) {
 	return true, nil
 }
 
+// ExportSnapshot exports the chain and the state of the current head into a
+// snapshot archive, a fresh node can be bootstrapped from with the
+// snapshot-import command. The node keeps running meanwhile.
+func (api *PrivateAdminAPI) ExportSnapshot(file string) (*core.SnapshotHeader, error) {
+	// Export into a temporary file, for a partial archive never to be mistaken
+	// for a complete one
+	out, err := os.OpenFile(file+".tmp", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file + ".tmp")
+	defer out.Close()
+
+	var writer io.Writer = out
+	var zipped *gzip.Writer
+	if strings.HasSuffix(file, ".gz") {
+		zipped = gzip.NewWriter(writer)
+		writer = zipped
+	}
+	header, err := api.eth.BlockChain().ExportSnapshot(writer)
+	if err != nil {
+		return nil, err
+	}
+	if zipped != nil {
+		if err := zipped.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := out.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(file+".tmp", file); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
 func hasAllBlocks(chain *core.BlockChain, bs []*types.Block) bool {
 	for _, b := range bs {
 		if !chain.HasBlock(b.Hash(), b.NumberU64()) {
@@ -313,12 +543,104 @@ func NewPublicDebugAPI(eth *Ethereum) *PublicDebugAPI {
 
 // DumpBlock retrieves the entire state of the database at a given block.
 func (api *PublicDebugAPI) DumpBlock(blockNr rpc.BlockNumber) (state.Dump, error) {
+	stateDb, err := api.stateAt(blockNr)
+	if err != nil {
+		return state.Dump{}, err
+	}
+	return stateDb.RawDump(), nil
+}
+
+const (
+	defaultStorageDumpSize = 100  // Storage slots dumped per page unless requested otherwise
+	maxStorageDumpSize     = 1000 // Maximum number of storage slots dumped per page
+)
+
+var errInvalidStorageCursor = errors.New("invalid storage cursor")
+
+// AccountDump is the state of an account, with a page of its storage slots
+// also rendered by the storage layout decoders of the contract when known.
+type AccountDump struct {
+	Balance        string                     `json:"balance"`
+	Nonce          uint64                     `json:"nonce"`
+	CodeHash       string                     `json:"codeHash"`
+	Code           string                     `json:"code"`
+	Storage        map[string]string          `json:"storage"`
+	DecodedStorage map[string]*vm.DecodedSlot `json:"decodedStorage"`
+	Next           hexutil.Bytes              `json:"next"` // Cursor of the next storage page, null on the last page
+}
+
+// DumpAccount retrieves the state of an account at a given block, rendering
+// its storage in human readable form if its layout is known. The storage is
+// dumped by pages of up to limit slots in storage trie order, starting from
+// the cursor returned with the previous page, or from the first slot without.
+func (api *PublicDebugAPI) DumpAccount(address common.Address, blockNr rpc.BlockNumber, cursor *hexutil.Bytes, limit *int) (*AccountDump, error) {
+	var start []byte
+	if cursor != nil {
+		if len(*cursor) != common.HashLength {
+			return nil, errInvalidStorageCursor
+		}
+		start = *cursor
+	}
+	size := defaultStorageDumpSize
+	if limit != nil {
+		size = *limit
+	}
+	if size < 1 || size > maxStorageDumpSize {
+		size = maxStorageDumpSize
+	}
+	stateDb, err := api.stateAt(blockNr)
+	if err != nil {
+		return nil, err
+	}
+	return dumpAccount(stateDb, address, start, size)
+}
+
+// dumpAccount dumps an account with up to size storage slots, from the start
+// cursor on.
+func dumpAccount(stateDb *state.StateDB, address common.Address, start []byte, size int) (*AccountDump, error) {
+	if !stateDb.Exist(address) {
+		return nil, fmt.Errorf("account %x not found", address)
+	}
+	dump := &AccountDump{
+		Balance:        stateDb.GetBalance(address).String(),
+		Nonce:          stateDb.GetNonce(address),
+		CodeHash:       common.Bytes2Hex(stateDb.GetCodeHash(address).Bytes()),
+		Code:           common.Bytes2Hex(stateDb.GetCode(address)),
+		Storage:        make(map[string]string),
+		DecodedStorage: make(map[string]*vm.DecodedSlot),
+	}
+	// The privacy storage accounts hold raw byte arrays, others RLP encoded words
+	byteArrays := vm.IsPrivacyStorageAddr(address)
+
+	st := stateDb.StorageTrie(address)
+	it := trie.NewIterator(st.NodeIterator(start))
+	for it.Next() {
+		if len(dump.Storage) == size {
+			dump.Next = common.CopyBytes(it.Key)
+			break
+		}
+		key, value := common.BytesToHash(st.GetKey(it.Key)), it.Value
+		dump.Storage[common.Bytes2Hex(key[:])] = common.Bytes2Hex(value)
+		if !byteArrays {
+			if _, content, _, err := rlp.Split(value); err == nil {
+				value = content
+			}
+		}
+		if decoded := vm.StorageLayouts.DecodeSlot(address, key, value); decoded != nil {
+			dump.DecodedStorage[common.Bytes2Hex(key[:])] = decoded
+		}
+	}
+	return dump, it.Err
+}
+
+// stateAt retrieves the state of the database at a given block.
+func (api *PublicDebugAPI) stateAt(blockNr rpc.BlockNumber) (*state.StateDB, error) {
 	if blockNr == rpc.PendingBlockNumber {
 		// If we're dumping the pending state, we need to request
 		// both the pending block as well as the pending state from
 		// the miner and operate on those
 		_, stateDb := api.eth.miner.Pending()
-		return stateDb.RawDump(), nil
+		return stateDb, nil
 	}
 	var block *types.Block
 	if blockNr == rpc.LatestBlockNumber {
@@ -327,13 +649,82 @@ func (api *PublicDebugAPI) DumpBlock(blockNr rpc.BlockNumber) (state.Dump, error
 		block = api.eth.blockchain.GetBlockByNumber(uint64(blockNr))
 	}
 	if block == nil {
-		return state.Dump{}, fmt.Errorf("block #%d not found", blockNr)
+		return nil, fmt.Errorf("block #%d not found", blockNr)
+	}
+	return api.eth.BlockChain().StateAt(block.Root())
+}
+
+// ChainStats returns rolling statistics over the most recently imported
+// blocks: import throughput, privacy transaction share, average ring size
+// and the estimated daily database growth.
+func (api *PublicDebugAPI) ChainStats() ChainStats {
+	return api.eth.chainStats.stats()
+}
+
+// ContractStorageUsage is the storage footprint of a single contract.
+type ContractStorageUsage struct {
+	Address common.Address `json:"address"`
+	Privacy bool           `json:"privacy"`
+	state.StorageUsage
+	TotalBytes uint64 `json:"totalBytes"`
+}
+
+// StorageUsageReport lists the storage attributed to each contract at a
+// given block, largest consumers first.
+type StorageUsageReport struct {
+	Root       common.Hash            `json:"root"`
+	Number     uint64                 `json:"number"`
+	Contracts  []ContractStorageUsage `json:"contracts"`
+	TotalSlots uint64                 `json:"totalSlots"`
+	TotalBytes uint64                 `json:"totalBytes"`
+}
+
+// StorageUsageReport walks the state at the given block and reports the bytes
+// of storage attributed to each contract. The privacy contracts' byte array
+// slots are measured by their raw length. If limit is positive, only the
+// largest limit contracts are listed, the totals always cover every contract.
+func (api *PublicDebugAPI) StorageUsageReport(blockNr rpc.BlockNumber, limit *int) (*StorageUsageReport, error) {
+	var block *types.Block
+	if blockNr == rpc.LatestBlockNumber || blockNr == rpc.PendingBlockNumber {
+		block = api.eth.blockchain.CurrentBlock()
+	} else {
+		block = api.eth.blockchain.GetBlockByNumber(uint64(blockNr))
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", blockNr)
 	}
 	stateDb, err := api.eth.BlockChain().StateAt(block.Root())
 	if err != nil {
-		return state.Dump{}, err
+		return nil, err
 	}
-	return stateDb.RawDump(), nil
+
+	report := &StorageUsageReport{
+		Root:      block.Root(),
+		Number:    block.NumberU64(),
+		Contracts: make([]ContractStorageUsage, 0),
+	}
+	err = stateDb.ForEachStorageUsage(vm.IsPrivacyStorageAddr, func(addr common.Address, usage state.StorageUsage) bool {
+		report.Contracts = append(report.Contracts, ContractStorageUsage{
+			Address:      addr,
+			Privacy:      vm.IsPrivacyStorageAddr(addr),
+			StorageUsage: usage,
+			TotalBytes:   usage.Total(),
+		})
+		report.TotalSlots += usage.Slots
+		report.TotalBytes += usage.Total()
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(report.Contracts, func(i, j int) bool {
+		return report.Contracts[i].TotalBytes > report.Contracts[j].TotalBytes
+	})
+	if limit != nil && *limit > 0 && len(report.Contracts) > *limit {
+		report.Contracts = report.Contracts[:*limit]
+	}
+	return report, nil
 }
 
 // PrivateDebugAPI is the collection of Ethereum full node APIs exposed over
@@ -587,6 +978,24 @@ func (api *PrivateDebugAPI) Preimage(ctx context.Context, hash common.Hash) (hex
 	return db.Get(hash.Bytes())
 }
 
+// VmProfile returns the execution time profile of the opcodes and precompiled
+// contract methods aggregated over the blocks imported since the last reset.
+func (api *PrivateDebugAPI) VmProfile() (*vm.ProfileReport, error) {
+	if api.eth.vmProfiler == nil {
+		return nil, errors.New("VM profiling disabled, enable it with --vmprofile")
+	}
+	return api.eth.vmProfiler.Report(), nil
+}
+
+// ResetVmProfile discards the aggregated execution time profile.
+func (api *PrivateDebugAPI) ResetVmProfile() error {
+	if api.eth.vmProfiler == nil {
+		return errors.New("VM profiling disabled, enable it with --vmprofile")
+	}
+	api.eth.vmProfiler.Reset()
+	return nil
+}
+
 // GetBadBLocks returns a list of the last 'bad blocks' that the client has seen on the network
 // and returns them as a JSON list of block-hashes
 func (api *PrivateDebugAPI) GetBadBlocks(ctx context.Context) ([]core.BadBlockArgs, error) {
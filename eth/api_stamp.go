@@ -0,0 +1,52 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package eth
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/common/hexutil"
+	"github.com/wanchain/go-wanchain/core"
+	"github.com/wanchain/go-wanchain/rpc"
+)
+
+// errNoNodeKey is returned when verifying a stamp on a node without a key to
+// sign the verdict with.
+var errNoNodeKey = errors.New("no node key to sign the verdict")
+
+// PublicStampAPI verifies privacy transaction stamps on behalf of the light
+// wallets, which can't check them without the state.
+type PublicStampAPI struct {
+	e *Ethereum
+}
+
+// NewPublicStampAPI creates a new PublicStampAPI instance.
+func NewPublicStampAPI(e *Ethereum) *PublicStampAPI {
+	return &PublicStampAPI{e}
+}
+
+// StampArgs is the stamp payload of a privacy transaction to verify.
+type StampArgs struct {
+	From     common.Address `json:"from"`
+	Data     hexutil.Bytes  `json:"data"`
+	GasPrice *hexutil.Big   `json:"gasPrice"`
+}
+
+// VerifyStamp verifies the stamp payload of a privacy transaction against the
+// state of the given block, returning the verdict signed by the node key. The
+// signer is checked against the node identity, e.g. from admin.nodeInfo.
+func (api *PublicStampAPI) VerifyStamp(args StampArgs, blockNr rpc.BlockNumber) (*core.StampVerdict, error) {
+	if api.e.nodeKey == nil {
+		return nil, errNoNodeKey
+	}
+	if args.GasPrice == nil {
+		return nil, errors.New("missing gas price")
+	}
+	block, statedb, err := blockStateAt(api.e, blockNr)
+	if err != nil {
+		return nil, err
+	}
+	return core.VerifyStamp(api.e.chainConfig, statedb, block.Header(), args.From, args.Data, (*big.Int)(args.GasPrice), api.e.nodeKey)
+}
@@ -86,3 +86,53 @@ func TestStorageRangeAt(t *testing.T) {
 		}
 	}
 }
+
+// Tests that the storage of an account is dumped by pages, every slot being
+// dumped once following the cursors.
+func TestDumpAccountPages(t *testing.T) {
+	var (
+		db, _    = ethdb.NewMemDatabase()
+		state, _ = state.New(common.Hash{}, state.NewDatabase(db))
+		addr     = common.Address{0x01}
+	)
+	for i := byte(1); i <= 5; i++ {
+		state.SetState(addr, common.Hash{i}, common.Hash{0x10 + i})
+	}
+	seen := make(map[string]string)
+	var (
+		cursor []byte
+		pages  int
+	)
+	for {
+		dump, err := dumpAccount(state, addr, cursor, 2)
+		if err != nil {
+			t.Fatalf("page %d: failed to dump: %v", pages, err)
+		}
+		pages++
+		if dump.Next != nil && len(dump.Storage) != 2 {
+			t.Errorf("page %d: slot count mismatch: have %d, want 2", pages, len(dump.Storage))
+		}
+		for key, value := range dump.Storage {
+			if _, ok := seen[key]; ok {
+				t.Errorf("slot %s dumped twice", key)
+			}
+			seen[key] = value
+		}
+		if dump.Next == nil {
+			break
+		}
+		cursor = dump.Next
+	}
+	if pages != 3 || len(seen) != 5 {
+		t.Fatalf("dump mismatch: have %d slots in %d pages, want 5 in 3", len(seen), pages)
+	}
+	for i := byte(1); i <= 5; i++ {
+		key, value := common.Hash{i}, common.Hash{0x10 + i}
+		if have, want := seen[common.Bytes2Hex(key[:])], "a0"+common.Bytes2Hex(value[:]); have != want {
+			t.Errorf("slot %x value mismatch: have %s, want %s", key, have, want)
+		}
+	}
+	if _, err := dumpAccount(state, common.Address{0x02}, nil, 2); err == nil {
+		t.Errorf("missing account dumped")
+	}
+}
@@ -18,10 +18,12 @@
 package eth
 
 import (
+	"crypto/ecdsa"
 	"errors"
 	"fmt"
 	"math/big"
 	"runtime"
+	"strings"
 	"sync"
 	"sync/atomic"
 
@@ -77,9 +79,13 @@ type Ethereum struct {
 	eventMux       *event.TypeMux
 	engine         consensus.Engine
 	accountManager *accounts.Manager
+	nodeKey        *ecdsa.PrivateKey // Key of the node signing the stamp verdicts, nil if none
 
 	bloomRequests chan chan *bloombits.Retrieval // Channel receiving bloom data retrieval requests
 	bloomIndexer  *core.ChainIndexer             // Bloom indexer operating during block imports
+	chainStats    *chainStats                    // Rolling import statistics
+	replica       *replicaFollower               // Hot standby follower, nil unless replicating
+	vmProfiler    *vm.Profiler                   // Execution profile of the imported blocks, nil unless profiling
 
 	ApiBackend *EthApiBackend
 
@@ -123,6 +129,7 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		chainConfig:    chainConfig,
 		eventMux:       ctx.EventMux,
 		accountManager: ctx.AccountManager,
+		nodeKey:        ctx.NodeKey(),
 		engine:         CreateConsensusEngine(ctx, config, chainConfig, chainDb),
 		shutdownChan:   make(chan bool),
 		stopDbUpgrade:  stopDbUpgrade,
@@ -143,11 +150,26 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		core.WriteBlockChainVersion(chainDb, core.BlockChainVersion)
 	}
 
-	vmConfig := vm.Config{EnablePreimageRecording: config.EnablePreimageRecording}
+	if config.StorageLayouts != "" {
+		if err := vm.StorageLayouts.LoadDescriptors(config.StorageLayouts); err != nil {
+			return nil, fmt.Errorf("failed to load storage layouts: %v", err)
+		}
+	}
+	vmConfig := vm.Config{
+		EnablePreimageRecording: config.EnablePreimageRecording,
+		ParallelWorkers:         config.ParallelExecution,
+	}
+	if config.EnableVMProfiling {
+		eth.vmProfiler = vm.NewProfiler()
+		vmConfig.Profiler = eth.vmProfiler
+	}
 	eth.blockchain, err = core.NewBlockChain(chainDb, eth.chainConfig, eth.engine, vmConfig)
 	if err != nil {
 		return nil, err
 	}
+	if err := addBlockPlugins(eth.blockchain, config.BlockPluginsReject); err != nil {
+		return nil, err
+	}
 	// Rewind the chain in case of an incompatible config upgrade.
 	if compat, ok := genesisErr.(*params.ConfigCompatError); ok {
 		log.Warn("Rewinding chain to upgrade configuration", "err", compat)
@@ -155,6 +177,7 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		core.WriteChainConfig(chainDb, genesisHash, chainConfig)
 	}
 	eth.bloomIndexer.Start(eth.blockchain.CurrentHeader(), eth.blockchain.SubscribeChainEvent)
+	eth.chainStats = newChainStats(chainDb, eth.blockchain.SubscribeChainEvent)
 
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = ctx.ResolvePath(config.TxPool.Journal)
@@ -164,8 +187,16 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	if eth.protocolManager, err = NewProtocolManager(eth.chainConfig, config.SyncMode, config.NetworkId, eth.eventMux, eth.txPool, eth.engine, eth.blockchain, chainDb); err != nil {
 		return nil, err
 	}
+	eth.protocolManager.relayUnknownTxs = config.TxPool.RelayUnknown
+	eth.protocolManager.stemLocalTxs = config.Dandelion
+
+	// Schedule the compactions of the chain database around the sync imports
+	if ldb, ok := chainDb.(*ethdb.LDBDatabase); ok {
+		ldb.ScheduleCompactions(ethdb.DefaultCompactionConfig, eth.protocolManager.downloader.ImportBacklog)
+	}
 	eth.miner = miner.New(eth, eth.chainConfig, eth.EventMux(), eth.engine)
 	eth.miner.SetExtra(makeExtraData(config.ExtraData))
+	eth.miner.SetMaxClockDrift(config.MinerMaxClockDrift)
 
 	eth.ApiBackend = &EthApiBackend{eth, nil}
 	gpoParams := config.GPO
@@ -194,6 +225,29 @@ func makeExtraData(extra []byte) []byte {
 	return extra
 }
 
+// addBlockPlugins makes the chain run the registered block plugins, the ones
+// named in reject rejecting the blocks failing their checks.
+func addBlockPlugins(chain *core.BlockChain, reject []string) error {
+	rejecting := make(map[string]bool)
+	for _, name := range reject {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if core.BlockPlugins.Plugin(name) == nil {
+			return fmt.Errorf("unknown block plugin %q", name)
+		}
+		rejecting[name] = true
+	}
+	for _, plugin := range core.BlockPlugins.Plugins() {
+		mode := core.PluginWarn
+		if rejecting[plugin.Name()] {
+			mode = core.PluginReject
+		}
+		chain.AddBlockPlugin(plugin, mode)
+	}
+	return nil
+}
+
 // CreateDB creates the chain database.
 func CreateDB(ctx *node.ServiceContext, config *Config, name string) (ethdb.Database, error) {
 	db, err := ctx.OpenDatabase(name, config.DatabaseCache, config.DatabaseHandles)
@@ -203,6 +257,11 @@ func CreateDB(ctx *node.ServiceContext, config *Config, name string) (ethdb.Data
 	if db, ok := db.(*ethdb.LDBDatabase); ok {
 		db.Meter("eth/db/chaindata/")
 	}
+	// Upgrade the layout of databases written by older versions
+	if err := core.MigrateDatabase(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate %s database: %v", name, err)
+	}
 	return db, nil
 }
 
@@ -258,6 +317,26 @@ func (s *Ethereum) APIs() []rpc.API {
 			Version:   "1.0",
 			Service:   NewPublicMinerAPI(s),
 			Public:    true,
+		}, {
+			Namespace: "wan",
+			Version:   "1.0",
+			Service:   NewPublicDeploymentAPI(s),
+			Public:    true,
+		}, {
+			Namespace: "wan",
+			Version:   "1.0",
+			Service:   NewPublicTreasuryAPI(s),
+			Public:    true,
+		}, {
+			Namespace: "wan",
+			Version:   "1.0",
+			Service:   NewPublicVestingAPI(s),
+			Public:    true,
+		}, {
+			Namespace: "wan",
+			Version:   "1.0",
+			Service:   NewPublicStampAPI(s),
+			Public:    true,
 		}, {
 			Namespace: "eth",
 			Version:   "1.0",
@@ -286,6 +365,10 @@ func (s *Ethereum) APIs() []rpc.API {
 			Namespace: "debug",
 			Version:   "1.0",
 			Service:   NewPrivateDebugAPI(s.chainConfig, s),
+		}, {
+			Namespace: "replica",
+			Version:   "1.0",
+			Service:   NewPrivateReplicaAPI(s),
 		}, {
 			Namespace: "net",
 			Version:   "1.0",
@@ -404,6 +487,11 @@ func (s *Ethereum) Start(srvr *p2p.Server) error {
 	if s.lesServer != nil {
 		s.lesServer.Start(srvr)
 	}
+	// Start tailing the primary if running as a hot standby
+	if s.config.ReplicationPrimary != "" {
+		s.replica = newReplicaFollower(s.config.ReplicationPrimary, s.config.ReplicationSecret, srvr.Self().ID.String(), s.blockchain, s.chainDb)
+		s.replica.start()
+	}
 	return nil
 }
 
@@ -413,7 +501,11 @@ func (s *Ethereum) Stop() error {
 	if s.stopDbUpgrade != nil {
 		s.stopDbUpgrade()
 	}
+	if s.replica != nil {
+		s.replica.stop()
+	}
 	s.bloomIndexer.Close()
+	s.chainStats.stop()
 	s.blockchain.Stop()
 	s.protocolManager.Stop()
 	if s.lesServer != nil {
@@ -0,0 +1,198 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package eth
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/ethdb"
+	"github.com/wanchain/go-wanchain/event"
+)
+
+const (
+	// chainStatsWindow is the number of most recently imported blocks the
+	// rolling statistics are computed over.
+	chainStatsWindow = 1024
+
+	// chainStatsSizeInterval is the number of blocks between two samples of
+	// the database size, used to estimate the state growth.
+	chainStatsSizeInterval = 64
+)
+
+// blockSample is the per block data retained for the rolling statistics.
+type blockSample struct {
+	number    uint64
+	time      uint64    // Block timestamp
+	imported  time.Time // Local import time
+	gasUsed   uint64
+	txs       int
+	privacy   int // Number of transactions carrying a ring signature
+	ringSizes int // Sum of the ring sizes of those transactions
+	dbSize    int64
+}
+
+// ChainStats is the snapshot of the rolling statistics returned over RPC.
+type ChainStats struct {
+	Blocks          int     `json:"blocks"`
+	FirstBlock      uint64  `json:"firstBlock"`
+	LastBlock       uint64  `json:"lastBlock"`
+	BlocksPerSecond float64 `json:"blocksPerSecond"`
+	GasPerSecond    float64 `json:"gasPerSecond"`
+	TxsPerSecond    float64 `json:"txsPerSecond"`
+	PrivacyTxShare  float64 `json:"privacyTxShare"`
+	AverageRingSize float64 `json:"averageRingSize"`
+	StateGrowthDay  float64 `json:"stateGrowthPerDay"`
+}
+
+// chainStats incrementally maintains rolling import statistics over the last
+// chainStatsWindow blocks, so that dashboards don't need to crawl the chain.
+type chainStats struct {
+	db ethdb.Database
+
+	samples []blockSample // Ring buffer of the most recent samples
+	next    int           // Index of the next sample to overwrite
+	dbSize  int64         // Most recently sampled database size
+	lock    sync.RWMutex
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newChainStats creates a statistics tracker and starts consuming the chain
+// events delivered by subscribe.
+func newChainStats(db ethdb.Database, subscribe func(ch chan<- core.ChainEvent) event.Subscription) *chainStats {
+	s := &chainStats{
+		db:      db,
+		samples: make([]blockSample, 0, chainStatsWindow),
+		quit:    make(chan struct{}),
+	}
+	events := make(chan core.ChainEvent, 64)
+	sub := subscribe(events)
+
+	s.wg.Add(1)
+	go s.loop(events, sub)
+	return s
+}
+
+func (s *chainStats) loop(events chan core.ChainEvent, sub event.Subscription) {
+	defer s.wg.Done()
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case ev := <-events:
+			s.add(ev.Block, time.Now())
+		case <-sub.Err():
+			return
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// stop terminates the event loop.
+func (s *chainStats) stop() {
+	close(s.quit)
+	s.wg.Wait()
+}
+
+// add records a newly imported block.
+func (s *chainStats) add(block *types.Block, imported time.Time) {
+	sample := blockSample{
+		number:   block.NumberU64(),
+		time:     block.Time().Uint64(),
+		imported: imported,
+		gasUsed:  block.GasUsed().Uint64(),
+		txs:      len(block.Transactions()),
+	}
+	for _, tx := range block.Transactions() {
+		if size := core.RingSizeOf(tx); size > 0 {
+			sample.privacy++
+			sample.ringSizes += size
+		}
+	}
+	if sample.number%chainStatsSizeInterval == 0 {
+		if size, ok := databaseSize(s.db); ok {
+			s.lock.Lock()
+			s.dbSize = size
+			s.lock.Unlock()
+		}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	sample.dbSize = s.dbSize
+	if len(s.samples) < chainStatsWindow {
+		s.samples = append(s.samples, sample)
+		return
+	}
+	s.samples[s.next] = sample
+	s.next = (s.next + 1) % chainStatsWindow
+}
+
+// stats computes the statistics over the retained window.
+func (s *chainStats) stats() ChainStats {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	var stats ChainStats
+	if len(s.samples) == 0 {
+		return stats
+	}
+	first := s.samples[s.next%len(s.samples)]
+	last := s.samples[(s.next+len(s.samples)-1)%len(s.samples)]
+
+	var (
+		gas     uint64
+		txs     int
+		privacy int
+		rings   int
+	)
+	for _, sample := range s.samples {
+		gas += sample.gasUsed
+		txs += sample.txs
+		privacy += sample.privacy
+		rings += sample.ringSizes
+	}
+	stats.Blocks = len(s.samples)
+	stats.FirstBlock, stats.LastBlock = first.number, last.number
+
+	if elapsed := last.imported.Sub(first.imported).Seconds(); elapsed > 0 {
+		// The first block's content was imported before the window started
+		stats.BlocksPerSecond = float64(len(s.samples)-1) / elapsed
+		stats.GasPerSecond = float64(gas-first.gasUsed) / elapsed
+		stats.TxsPerSecond = float64(txs-first.txs) / elapsed
+	}
+	if txs > 0 {
+		stats.PrivacyTxShare = float64(privacy) / float64(txs)
+	}
+	if privacy > 0 {
+		stats.AverageRingSize = float64(rings) / float64(privacy)
+	}
+	if last.time > first.time && first.dbSize > 0 {
+		stats.StateGrowthDay = float64(last.dbSize-first.dbSize) / float64(last.time-first.time) * 86400
+	}
+	return stats
+}
+
+// databaseSize returns the approximate on disk size of the database, if the
+// backing store supports reporting it.
+func databaseSize(db ethdb.Database) (int64, bool) {
+	ldb, ok := db.(*ethdb.LDBDatabase)
+	if !ok {
+		return 0, false
+	}
+	limit := bytes.Repeat([]byte{0xff}, common.HashLength+1)
+	sizes, err := ldb.LDB().SizeOf([]util.Range{{Start: nil, Limit: limit}})
+	if err != nil {
+		return 0, false
+	}
+	return sizes.Sum(), true
+}
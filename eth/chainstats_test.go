@@ -0,0 +1,42 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package eth
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core/types"
+)
+
+func TestChainStatsWindow(t *testing.T) {
+	s := &chainStats{samples: make([]blockSample, 0, chainStatsWindow)}
+
+	start := time.Now()
+	for i := 0; i < chainStatsWindow+10; i++ {
+		header := &types.Header{
+			Number:  big.NewInt(int64(i)),
+			Time:    big.NewInt(int64(i * 10)),
+			GasUsed: big.NewInt(21000),
+		}
+		tx := types.NewTransaction(uint64(i), common.Address{}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil)
+		block := types.NewBlock(header, []*types.Transaction{tx}, nil, nil)
+		s.add(block, start.Add(time.Duration(i)*time.Second))
+	}
+
+	stats := s.stats()
+	if stats.Blocks != chainStatsWindow {
+		t.Fatalf("window size mismatch: have %d, want %d", stats.Blocks, chainStatsWindow)
+	}
+	if stats.FirstBlock != 10 || stats.LastBlock != chainStatsWindow+9 {
+		t.Errorf("window bounds mismatch: have %d-%d", stats.FirstBlock, stats.LastBlock)
+	}
+	if stats.BlocksPerSecond != 1 || stats.TxsPerSecond != 1 || stats.GasPerSecond != 21000 {
+		t.Errorf("throughput mismatch: have %+v", stats)
+	}
+	if stats.PrivacyTxShare != 0 || stats.AverageRingSize != 0 {
+		t.Errorf("unexpected privacy stats: have %+v", stats)
+	}
+}
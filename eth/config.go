@@ -22,6 +22,7 @@ import (
 	"os/user"
 	"path/filepath"
 	"runtime"
+	"time"
 
 	"github.com/wanchain/go-wanchain/common"
 	"github.com/wanchain/go-wanchain/common/hexutil"
@@ -76,6 +77,10 @@ type Config struct {
 	NetworkId uint64 // Network ID to use for selecting peers to connect to
 	SyncMode  downloader.SyncMode
 
+	// Relay the local privacy transactions along a Dandelion stem before
+	// broadcasting them, hiding the node they originate from
+	Dandelion bool `toml:",omitempty"`
+
 	// Light client options
 	LightServ  int `toml:",omitempty"` // Maximum percentage of time allowed for serving LES requests
 	LightPeers int `toml:",omitempty"` // Maximum number of LES client peers
@@ -91,6 +96,9 @@ type Config struct {
 	ExtraData    []byte         `toml:",omitempty"`
 	GasPrice     *big.Int
 
+	// Clock drift above which the miner refuses to seal, 0 if unlimited
+	MinerMaxClockDrift time.Duration `toml:",omitempty"`
+
 	// Ethash options
 	EthashCacheDir       string
 	EthashCachesInMem    int
@@ -108,6 +116,23 @@ type Config struct {
 	// Enables tracking of SHA3 preimages in the VM
 	EnablePreimageRecording bool
 
+	// Enables the execution time profiling of the opcodes and precompiles
+	EnableVMProfiling bool
+
+	// Number of workers executing the block transactions in parallel (experimental)
+	ParallelExecution int
+
+	// JSON descriptor file or directory of the contract storage layouts to decode
+	StorageLayouts string `toml:",omitempty"`
+
+	// Block plugins rejecting the blocks failing their checks, the other
+	// registered plugins only warn about them
+	BlockPluginsReject []string `toml:",omitempty"`
+
+	// Hot standby replication options
+	ReplicationPrimary string `toml:",omitempty"` // RPC endpoint of the primary to follow
+	ReplicationSecret  string `toml:",omitempty"` // Secret authenticating replication requests
+
 	// Miscellaneous options
 	DocRoot   string `toml:"-"`
 	PowFake   bool   `toml:"-"`
@@ -0,0 +1,171 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package eth
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/log"
+)
+
+// The locally submitted privacy transactions are relayed Dandelion style: along
+// a stem, each hop passing them to a single peer, before being fluffed, that is
+// broadcast as usual, by a random hop. An observer of the broadcast only learns
+// the fluffing node, not the one the refund originates from.
+const (
+	stemFluffProb = 0.1              // Probability of a hop fluffing a stem transaction
+	stemEpoch     = 10 * time.Minute // Time a stem peer is relayed to before picking another
+	stemEmbargo   = 30 * time.Second // Minimum time a hop waits for the fluff before fluffing itself
+	maxStemTxs    = 1024             // Maximum number of transactions held in stem phase
+)
+
+// stemTx is a transaction in stem phase, held until seen broadcast.
+type stemTx struct {
+	tx      *types.Transaction
+	local   bool      // Whether the transaction was submitted locally, already pooled
+	embargo time.Time // Time to fluff the transaction at if not seen broadcast
+}
+
+// stemRelay tracks the stem peer and the transactions in stem phase.
+type stemRelay struct {
+	peer  *peer     // Peer the stem transactions are relayed to
+	epoch time.Time // Time the stem peer is replaced at
+	txs   map[common.Hash]*stemTx
+	lock  sync.Mutex
+}
+
+func newStemRelay() *stemRelay {
+	return &stemRelay{txs: make(map[common.Hash]*stemTx)}
+}
+
+// stemmed returns whether the transaction is in stem phase, not to be announced.
+func (s *stemRelay) stemmed(hash common.Hash) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.txs[hash] != nil
+}
+
+// fluffed drops the transactions seen broadcast from the stem phase.
+func (s *stemRelay) fluffed(txs []*types.Transaction) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, tx := range txs {
+		delete(s.txs, tx.Hash())
+	}
+}
+
+// expired drops and returns the transactions whose embargo is over.
+func (s *stemRelay) expired(now time.Time) []*stemTx {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var expired []*stemTx
+	for hash, stx := range s.txs {
+		if now.After(stx.embargo) {
+			expired = append(expired, stx)
+			delete(s.txs, hash)
+		}
+	}
+	return expired
+}
+
+// stemTx relays a transaction to the stem peer, or to another random peer if
+// from, the peer it came from, is the stem one. It returns false if no peer can
+// continue the stem, the transaction then being fluffed.
+func (pm *ProtocolManager) stemTx(tx *types.Transaction, from *peer, local bool) bool {
+	hash := tx.Hash()
+
+	pm.stem.lock.Lock()
+	if pm.stem.txs[hash] != nil {
+		pm.stem.lock.Unlock()
+		return true
+	}
+	if len(pm.stem.txs) >= maxStemTxs {
+		pm.stem.lock.Unlock()
+		return false
+	}
+	// Pick a new stem peer if the epoch is over or the peer gone
+	var candidates []*peer
+	for _, p := range pm.peers.PeersWithoutTx(hash) {
+		if p.version >= wan65 && p.SupportsTx(tx) {
+			candidates = append(candidates, p)
+		}
+	}
+	relay := pm.stem.peer
+	if relay == nil || time.Now().After(pm.stem.epoch) || pm.peers.Peer(relay.id) == nil {
+		relay = nil
+		if len(candidates) > 0 {
+			relay = candidates[rand.Intn(len(candidates))]
+		}
+		pm.stem.peer, pm.stem.epoch = relay, time.Now().Add(stemEpoch)
+	}
+	// Never return a transaction to the peer it came from
+	if relay != nil && (relay == from || relay.knownTxs.Has(hash)) {
+		relay = nil
+		if len(candidates) > 0 {
+			relay = candidates[rand.Intn(len(candidates))]
+		}
+	}
+	if relay == nil {
+		pm.stem.lock.Unlock()
+		return false
+	}
+	embargo := stemEmbargo + time.Duration(rand.Int63n(int64(stemEmbargo)))
+	pm.stem.txs[hash] = &stemTx{tx: tx, local: local, embargo: time.Now().Add(embargo)}
+	pm.stem.lock.Unlock()
+
+	log.Trace("Relaying transaction along stem", "hash", hash, "peer", relay.id)
+	relay.SendStemTransactions(types.Transactions{tx})
+	return true
+}
+
+// handleStemTx continues the stem of a transaction received from a peer, or
+// fluffs it.
+func (pm *ProtocolManager) handleStemTx(p *peer, tx *types.Transaction) {
+	if pm.stem.stemmed(tx.Hash()) {
+		return
+	}
+	// Drop transactions not even signed properly, don't fluff on behalf of spammers
+	if _, err := types.Sender(types.MakeSigner(pm.chainconfig, pm.blockchain.CurrentBlock().Number()), tx); err != nil {
+		return
+	}
+	if !core.IsPrivacyTx(tx) || rand.Float64() < stemFluffProb || !pm.stemTx(tx, p, false) {
+		pm.fluff(tx, false)
+	}
+}
+
+// fluff ends the stem phase of a transaction, broadcasting it.
+func (pm *ProtocolManager) fluff(tx *types.Transaction, local bool) {
+	log.Trace("Fluffing stem transaction", "hash", tx.Hash())
+	if local {
+		pm.BroadcastTx(tx.Hash(), tx)
+		return
+	}
+	// Pooling the transaction broadcasts it
+	pm.txpool.AddRemotes([]*types.Transaction{tx})
+}
+
+// stemLoop fluffs the stem transactions not seen broadcast within their embargo,
+// the stem having failed to deliver them.
+func (pm *ProtocolManager) stemLoop() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			for _, stx := range pm.stem.expired(now) {
+				pm.fluff(stx.tx, stx.local)
+			}
+		case <-pm.quitSync:
+			return
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package eth
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/eth/downloader"
+	"github.com/wanchain/go-wanchain/p2p"
+)
+
+// collectMsgs forwards the codes of the messages a test peer receives.
+func collectMsgs(p *testPeer, codes chan<- uint64) {
+	for {
+		msg, err := p.app.ReadMsg()
+		if err != nil {
+			return
+		}
+		msg.Discard()
+		codes <- msg.Code
+	}
+}
+
+// Tests that the local privacy transactions are relayed to a single stem peer,
+// not broadcast, until their embargo is over, while the other transactions are
+// broadcast right away.
+func TestDandelionStem(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	pm.stemLocalTxs = true
+	defer pm.Stop()
+
+	const peers = 3
+	codes := make(chan uint64, 16)
+	for i := 0; i < peers; i++ {
+		p, _ := newTestPeer(fmt.Sprintf("peer #%d", i), wan65, pm, true)
+		defer p.close()
+
+		go collectMsgs(p, codes)
+	}
+	for start := time.Now(); pm.peers.Len() < peers && time.Since(start) < time.Second; {
+		time.Sleep(10 * time.Millisecond)
+	}
+	// expect returns the number of peers receiving a message of the given code
+	expect := func(code uint64) int {
+		var n int
+		timeout := time.After(500 * time.Millisecond)
+		for {
+			select {
+			case have := <-codes:
+				if have != code {
+					t.Fatalf("message code mismatch: have %d, want %d", have, code)
+				}
+				n++
+			case <-timeout:
+				return n
+			}
+		}
+	}
+	privacy, _ := types.SignTx(types.NewOTATransaction(0, common.Address{1}, nil, big.NewInt(200000), big.NewInt(1), nil), types.HomesteadSigner{}, testBankKey)
+	plain := newTestTransaction(testAccount, 0, 0)
+
+	pool := pm.txpool.(*testTxPool)
+	pool.txFeed.Send(core.TxPreEvent{Tx: privacy, Local: true})
+	if n := expect(StemTxMsg); n != 1 {
+		t.Fatalf("stem peers mismatch: have %d, want 1", n)
+	}
+	if !pm.stem.stemmed(privacy.Hash()) {
+		t.Fatalf("transaction not in stem phase")
+	}
+	pool.txFeed.Send(core.TxPreEvent{Tx: plain, Local: true})
+	if n := expect(TxMsg); n != peers {
+		t.Fatalf("plain transaction recipients mismatch: have %d, want %d", n, peers)
+	}
+	// Once the embargo is over, the stem transaction is broadcast to every peer
+	for _, stx := range pm.stem.expired(time.Now().Add(2 * stemEmbargo)) {
+		pm.fluff(stx.tx, stx.local)
+	}
+	if n := expect(TxMsg); n != peers {
+		t.Fatalf("fluffed transaction recipients mismatch: have %d, want %d", n, peers)
+	}
+	if pm.stem.stemmed(privacy.Hash()) {
+		t.Fatalf("transaction still in stem phase")
+	}
+}
+
+// Tests that the transactions received along a stem are fluffed if they are not
+// privacy ones, and dropped from the stem phase once seen broadcast.
+func TestDandelionRelay(t *testing.T) {
+	added := make(chan []*types.Transaction, 1)
+	pm := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, added)
+	pm.acceptTxs = 1
+	defer pm.Stop()
+
+	p, _ := newTestPeer("peer", wan65, pm, true)
+	defer p.close()
+
+	tx := newTestTransaction(testAccount, 0, 0)
+	if err := p2p.Send(p.app, StemTxMsg, []interface{}{tx}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	select {
+	case txs := <-added:
+		if len(txs) != 1 || txs[0].Hash() != tx.Hash() {
+			t.Fatalf("fluffed transactions mismatch: %v", txs)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("plain stem transaction not fluffed")
+	}
+	pm.stem.txs[tx.Hash()] = &stemTx{tx: tx, embargo: time.Now().Add(stemEmbargo)}
+	pm.stem.fluffed([]*types.Transaction{tx})
+	if pm.stem.stemmed(tx.Hash()) {
+		t.Fatalf("broadcast transaction still in stem phase")
+	}
+}
@@ -268,6 +268,15 @@ func (d *Downloader) Synchronising() bool {
 	return atomic.LoadInt32(&d.synchronising) > 0
 }
 
+// ImportBacklog returns the number of downloaded blocks waiting to be imported,
+// zero if not synchronising.
+func (d *Downloader) ImportBacklog() int {
+	if !d.Synchronising() {
+		return 0
+	}
+	return d.queue.Backlog()
+}
+
 // RegisterPeer injects a new download peer into the set of block source to be
 // used for fetching hashes and blocks from.
 func (d *Downloader) RegisterPeer(id string, version int, peer Peer) error {
@@ -175,6 +175,14 @@ func (q *queue) PendingReceipts() int {
 	return q.receiptTaskQueue.Size()
 }
 
+// Backlog retrieves the number of downloaded blocks waiting to be imported.
+func (q *queue) Backlog() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return q.countProcessableItems()
+}
+
 // InFlightHeaders retrieves whether there are header fetch requests currently
 // in flight.
 func (q *queue) InFlightHeaders() bool {
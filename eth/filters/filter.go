@@ -40,6 +40,7 @@ type Backend interface {
 	SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription
 	SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription
 	SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription
+	PendingLogs() []*types.Log
 
 	BloomStatus() (uint64, uint64)
 	ServiceFilter(ctx context.Context, session *bloombits.MatcherSession)
@@ -94,7 +95,12 @@ func New(backend Backend, begin, end int64, addresses []common.Address, topics [
 
 // Logs searches the blockchain for matching log entries, returning all from the
 // first block that contains matches, updating the start of the filter accordingly.
+// A range ending at the pending block also returns the matching logs of the
+// pending block, flagged as pending.
 func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
+	if f.begin == rpc.PendingBlockNumber.Int64() {
+		return f.pendingLogs(), nil
+	}
 	// Figure out the limits of the filter range
 	header, _ := f.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
 	if header == nil {
@@ -102,11 +108,11 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	}
 	head := header.Number.Uint64()
 
-	if f.begin == -1 {
+	if f.begin == rpc.LatestBlockNumber.Int64() {
 		f.begin = int64(head)
 	}
 	end := uint64(f.end)
-	if f.end == -1 {
+	if f.end == rpc.LatestBlockNumber.Int64() || f.end == rpc.PendingBlockNumber.Int64() {
 		end = head
 	}
 	// Gather all indexed logs, and finish with non indexed ones
@@ -127,7 +133,15 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	}
 	rest, err := f.unindexedLogs(ctx, end)
 	logs = append(logs, rest...)
-	return logs, err
+	if err != nil || f.end != rpc.PendingBlockNumber.Int64() {
+		return logs, err
+	}
+	return append(logs, f.pendingLogs()...), nil
+}
+
+// pendingLogs returns the logs of the pending block matching the filter criteria.
+func (f *Filter) pendingLogs() []*types.Log {
+	return filterLogs(f.backend.PendingLogs(), nil, nil, f.addresses, f.topics)
 }
 
 // indexedLogs returns the logs matching the filter criteria based on the bloom
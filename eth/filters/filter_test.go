@@ -57,7 +57,7 @@ func BenchmarkFilters(b *testing.B) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, nil}
 		key1, _    = crypto.HexToECDSA("f1572f76b75b40a7da72d6f2ee7fda3d1189c2d28f0a2f096347055abe344d7f")
 		addr1      = crypto.PubkeyToAddress(key1.PublicKey)
 		addr2      = common.BytesToAddress([]byte("jeff"))
@@ -133,7 +133,7 @@ func TestFilters(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, nil}
 		key1, _    = crypto.HexToECDSA("f1572f76b75b40a7da72d6f2ee7fda3d1189c2d28f0a2f096347055abe344d7f")
 		addr       = crypto.PubkeyToAddress(key1.PublicKey)
 
@@ -264,4 +264,33 @@ func TestFilters(t *testing.T) {
 	if len(logs) != 0 {
 		t.Error("expected 0 log, got", len(logs))
 	}
+
+	// The pending logs are only returned for ranges ending at the pending block
+	backend.pendingLogs = []*types.Log{
+		{Address: addr, Topics: []common.Hash{hash1}, BlockNumber: 1000, Pending: true},
+		{Address: failAddr, Topics: []common.Hash{hash1}, BlockNumber: 1000, Pending: true},
+	}
+	filter = New(backend, 990, -1, []common.Address{addr}, [][]common.Hash{{hash1, hash3}})
+	logs, _ = filter.Logs(context.Background())
+	if len(logs) != 1 {
+		t.Error("expected 1 log, got", len(logs))
+	}
+
+	filter = New(backend, 990, -2, []common.Address{addr}, [][]common.Hash{{hash1, hash3}})
+	logs, _ = filter.Logs(context.Background())
+	if len(logs) != 2 {
+		t.Fatal("expected 2 log, got", len(logs))
+	}
+	if logs[0].Pending || logs[0].Topics[0] != hash3 {
+		t.Errorf("expected mined log[0] with topic %x, got pending %v topic %x", hash3, logs[0].Pending, logs[0].Topics[0])
+	}
+	if !logs[1].Pending || logs[1].Topics[0] != hash1 {
+		t.Errorf("expected pending log[1] with topic %x, got pending %v topic %x", hash1, logs[1].Pending, logs[1].Topics[0])
+	}
+
+	filter = New(backend, -2, -2, nil, [][]common.Hash{{hash1}})
+	logs, _ = filter.Logs(context.Background())
+	if len(logs) != 2 {
+		t.Error("expected 2 log, got", len(logs))
+	}
 }
@@ -4,6 +4,7 @@ package eth
 
 import (
 	"math/big"
+	"time"
 
 	"github.com/wanchain/go-wanchain/common"
 	"github.com/wanchain/go-wanchain/common/hexutil"
@@ -17,6 +18,7 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		Genesis                 *core.Genesis `toml:",omitempty"`
 		NetworkId               uint64
 		SyncMode                downloader.SyncMode
+		Dandelion               bool `toml:",omitempty"`
 		LightServ               int  `toml:",omitempty"`
 		LightPeers              int  `toml:",omitempty"`
 		MaxPeers                int  `toml:"-"`
@@ -27,6 +29,7 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		MinerThreads            int            `toml:",omitempty"`
 		ExtraData               hexutil.Bytes  `toml:",omitempty"`
 		GasPrice                *big.Int
+		MinerMaxClockDrift      time.Duration `toml:",omitempty"`
 		EthashCacheDir          string
 		EthashCachesInMem       int
 		EthashCachesOnDisk      int
@@ -36,15 +39,22 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		TxPool                  core.TxPoolConfig
 		GPO                     gasprice.Config
 		EnablePreimageRecording bool
-		DocRoot                 string `toml:"-"`
-		PowFake                 bool   `toml:"-"`
-		PowTest                 bool   `toml:"-"`
-		PowShared               bool   `toml:"-"`
+		EnableVMProfiling       bool
+		ParallelExecution       int
+		StorageLayouts          string   `toml:",omitempty"`
+		BlockPluginsReject      []string `toml:",omitempty"`
+		ReplicationPrimary      string   `toml:",omitempty"`
+		ReplicationSecret       string   `toml:",omitempty"`
+		DocRoot                 string   `toml:"-"`
+		PowFake                 bool     `toml:"-"`
+		PowTest                 bool     `toml:"-"`
+		PowShared               bool     `toml:"-"`
 	}
 	var enc Config
 	enc.Genesis = c.Genesis
 	enc.NetworkId = c.NetworkId
 	enc.SyncMode = c.SyncMode
+	enc.Dandelion = c.Dandelion
 	enc.LightServ = c.LightServ
 	enc.LightPeers = c.LightPeers
 	enc.SkipBcVersionCheck = c.SkipBcVersionCheck
@@ -54,6 +64,7 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.MinerThreads = c.MinerThreads
 	enc.ExtraData = c.ExtraData
 	enc.GasPrice = c.GasPrice
+	enc.MinerMaxClockDrift = c.MinerMaxClockDrift
 	enc.EthashCacheDir = c.EthashCacheDir
 	enc.EthashCachesInMem = c.EthashCachesInMem
 	enc.EthashCachesOnDisk = c.EthashCachesOnDisk
@@ -63,6 +74,12 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.TxPool = c.TxPool
 	enc.GPO = c.GPO
 	enc.EnablePreimageRecording = c.EnablePreimageRecording
+	enc.EnableVMProfiling = c.EnableVMProfiling
+	enc.ParallelExecution = c.ParallelExecution
+	enc.StorageLayouts = c.StorageLayouts
+	enc.BlockPluginsReject = c.BlockPluginsReject
+	enc.ReplicationPrimary = c.ReplicationPrimary
+	enc.ReplicationSecret = c.ReplicationSecret
 	enc.DocRoot = c.DocRoot
 	enc.PowFake = c.PowFake
 	enc.PowTest = c.PowTest
@@ -75,6 +92,7 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		Genesis                 *core.Genesis `toml:",omitempty"`
 		NetworkId               *uint64
 		SyncMode                *downloader.SyncMode
+		Dandelion               *bool `toml:",omitempty"`
 		LightServ               *int  `toml:",omitempty"`
 		LightPeers              *int  `toml:",omitempty"`
 		MaxPeers                *int  `toml:"-"`
@@ -85,6 +103,7 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		MinerThreads            *int            `toml:",omitempty"`
 		ExtraData               hexutil.Bytes   `toml:",omitempty"`
 		GasPrice                *big.Int
+		MinerMaxClockDrift      *time.Duration `toml:",omitempty"`
 		EthashCacheDir          *string
 		EthashCachesInMem       *int
 		EthashCachesOnDisk      *int
@@ -94,10 +113,16 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		TxPool                  *core.TxPoolConfig
 		GPO                     *gasprice.Config
 		EnablePreimageRecording *bool
-		DocRoot                 *string `toml:"-"`
-		PowFake                 *bool   `toml:"-"`
-		PowTest                 *bool   `toml:"-"`
-		PowShared               *bool   `toml:"-"`
+		EnableVMProfiling       *bool
+		ParallelExecution       *int
+		StorageLayouts          *string  `toml:",omitempty"`
+		BlockPluginsReject      []string `toml:",omitempty"`
+		ReplicationPrimary      *string  `toml:",omitempty"`
+		ReplicationSecret       *string  `toml:",omitempty"`
+		DocRoot                 *string  `toml:"-"`
+		PowFake                 *bool    `toml:"-"`
+		PowTest                 *bool    `toml:"-"`
+		PowShared               *bool    `toml:"-"`
 	}
 	var dec Config
 	if err := unmarshal(&dec); err != nil {
@@ -112,6 +137,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.SyncMode != nil {
 		c.SyncMode = *dec.SyncMode
 	}
+	if dec.Dandelion != nil {
+		c.Dandelion = *dec.Dandelion
+	}
 	if dec.LightServ != nil {
 		c.LightServ = *dec.LightServ
 	}
@@ -139,6 +167,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.GasPrice != nil {
 		c.GasPrice = dec.GasPrice
 	}
+	if dec.MinerMaxClockDrift != nil {
+		c.MinerMaxClockDrift = *dec.MinerMaxClockDrift
+	}
 	if dec.EthashCacheDir != nil {
 		c.EthashCacheDir = *dec.EthashCacheDir
 	}
@@ -166,6 +197,24 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.EnablePreimageRecording != nil {
 		c.EnablePreimageRecording = *dec.EnablePreimageRecording
 	}
+	if dec.EnableVMProfiling != nil {
+		c.EnableVMProfiling = *dec.EnableVMProfiling
+	}
+	if dec.ParallelExecution != nil {
+		c.ParallelExecution = *dec.ParallelExecution
+	}
+	if dec.StorageLayouts != nil {
+		c.StorageLayouts = *dec.StorageLayouts
+	}
+	if dec.BlockPluginsReject != nil {
+		c.BlockPluginsReject = dec.BlockPluginsReject
+	}
+	if dec.ReplicationPrimary != nil {
+		c.ReplicationPrimary = *dec.ReplicationPrimary
+	}
+	if dec.ReplicationSecret != nil {
+		c.ReplicationSecret = *dec.ReplicationSecret
+	}
 	if dec.DocRoot != nil {
 		c.DocRoot = *dec.DocRoot
 	}
@@ -74,6 +74,11 @@ type ProtocolManager struct {
 	chainconfig *params.ChainConfig
 	maxPeers    int
 
+	relayUnknownTxs bool // Whether to relay the transactions of unknown types to the peers announcing them
+	stemLocalTxs    bool // Whether to relay the local privacy transactions along a Dandelion stem
+
+	stem *stemRelay // Stem peer and transactions in stem phase
+
 	downloader *downloader.Downloader
 	fetcher    *fetcher.Fetcher
 	peers      *peerSet
@@ -112,6 +117,7 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		noMorePeers: make(chan struct{}),
 		txsyncCh:    make(chan *txsync),
 		quitSync:    make(chan struct{}),
+		stem:        newStemRelay(),
 	}
 	// Figure out whether to allow fast sync or not
 	if mode == downloader.FastSync && blockchain.CurrentBlock().NumberU64() > 0 {
@@ -216,6 +222,7 @@ func (pm *ProtocolManager) Start(maxPeers int) {
 	// start sync handlers
 	go pm.syncer()
 	go pm.txsyncLoop()
+	go pm.stemLoop()
 }
 
 func (pm *ProtocolManager) Stop() {
@@ -257,7 +264,7 @@ func (pm *ProtocolManager) handle(p *peer) error {
 
 	// Execute the Ethereum handshake
 	td, head, genesis := pm.blockchain.Status()
-	if err := p.Handshake(pm.networkId, td, head, genesis); err != nil {
+	if err := p.Handshake(pm.networkId, td, head, genesis, pm.relayUnknownTxs); err != nil {
 		p.Log().Debug("Wanchain handshake failed", "err", err)
 		return err
 	}
@@ -667,8 +674,26 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			}
 			p.MarkTransaction(tx.Hash())
 		}
+		pm.stem.fluffed(txs)
 		pm.txpool.AddRemotes(txs)
 
+	case p.version >= wan65 && msg.Code == StemTxMsg:
+		// Stem transactions arrived, relay them further along the stem or fluff them
+		if atomic.LoadUint32(&pm.acceptTxs) == 0 {
+			break
+		}
+		var txs []*types.Transaction
+		if err := msg.Decode(&txs); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		for i, tx := range txs {
+			if tx == nil {
+				return errResp(ErrDecode, "transaction %d is nil", i)
+			}
+			p.MarkTransaction(tx.Hash())
+			pm.handleStemTx(p, tx)
+		}
+
 	default:
 		return errResp(ErrInvalidMsgCode, "%v", msg.Code)
 	}
@@ -714,10 +739,16 @@ func (pm *ProtocolManager) BroadcastTx(hash common.Hash, tx *types.Transaction)
 	// Broadcast transaction to a batch of peers not knowing about it
 	peers := pm.peers.PeersWithoutTx(hash)
 	//FIXME include this again: peers = peers[:int(math.Sqrt(float64(len(peers))))]
+	sent := 0
 	for _, peer := range peers {
+		// Skip the peers which would drop the transaction type
+		if !peer.SupportsTx(tx) {
+			continue
+		}
 		peer.SendTransactions(types.Transactions{tx})
+		sent++
 	}
-	log.Trace("Broadcast transaction", "hash", hash, "recipients", len(peers))
+	log.Trace("Broadcast transaction", "hash", hash, "recipients", sent)
 }
 
 // Mined broadcast loop
@@ -736,6 +767,10 @@ func (self *ProtocolManager) txBroadcastLoop() {
 	for {
 		select {
 		case event := <-self.txCh:
+			// Local privacy transactions start along a stem if possible
+			if event.Local && self.stemLocalTxs && core.IsPrivacyTx(event.Tx) && self.stemTx(event.Tx, nil, true) {
+				continue
+			}
 			self.BroadcastTx(event.Tx.Hash(), event.Tx)
 
 		// Err() channel will be closed when unsubscribing.
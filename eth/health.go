@@ -0,0 +1,77 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package eth
+
+import (
+	"time"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core"
+	"github.com/wanchain/go-wanchain/node"
+)
+
+// HealthChecks implements node.HealthReporter, checking the sync status, the
+// age of the last imported block, the lag of the privacy state behind the
+// headers and the database.
+func (s *Ethereum) HealthChecks(config *node.HealthConfig) []node.HealthCheck {
+	var (
+		checks = make([]node.HealthCheck, 0, 4)
+		head   = s.blockchain.CurrentBlock()
+		header = s.blockchain.CurrentHeader()
+	)
+	// The node is synced once within the configured lag of the best peer
+	progress := s.protocolManager.downloader.Progress()
+	syncing := s.protocolManager.downloader.Synchronising()
+	checks = append(checks, node.HealthCheck{
+		Name:  "sync",
+		Live:  true,
+		Ready: progress.HighestBlock <= progress.CurrentBlock+config.MaxLag,
+		Detail: map[string]interface{}{
+			"syncing": syncing,
+			"current": progress.CurrentBlock,
+			"highest": progress.HighestBlock,
+		},
+	})
+	// A stale head means the node stopped importing, however synced it claims to be
+	age := time.Since(time.Unix(head.Time().Int64(), 0))
+	if age < 0 {
+		age = 0
+	}
+	checks = append(checks, node.HealthCheck{
+		Name:  "head",
+		Live:  true,
+		Ready: config.MaxHeadAge == 0 || age <= config.MaxHeadAge,
+		Detail: map[string]interface{}{
+			"number": head.NumberU64(),
+			"hash":   head.Hash(),
+			"age":    uint64(age / time.Second),
+		},
+	})
+	// The OTA and key image sets live in the state, only available up to the head
+	// block, which trails the headers while fast syncing
+	var lag uint64
+	if header.Number.Uint64() > head.NumberU64() {
+		lag = header.Number.Uint64() - head.NumberU64()
+	}
+	privacy := node.HealthCheck{
+		Name:   "privacyIndex",
+		Live:   true,
+		Ready:  lag <= config.MaxLag,
+		Detail: map[string]interface{}{"lag": lag},
+	}
+	if !s.blockchain.HasBlockAndState(head.Hash()) {
+		privacy.Ready, privacy.Error = false, "head state missing"
+	}
+	checks = append(checks, privacy)
+
+	// The database must serve the head pointer it persisted
+	database := node.HealthCheck{Name: "database", Live: true, Ready: true}
+	if hash := core.GetHeadBlockHash(s.chainDb); hash == (common.Hash{}) {
+		database.Live, database.Ready, database.Error = false, false, "head block pointer unreadable"
+	} else if core.GetCanonicalHash(s.chainDb, head.NumberU64()) != head.Hash() {
+		database.Live, database.Ready, database.Error = false, false, "canonical chain unreadable"
+	}
+	checks = append(checks, database)
+
+	return checks
+}
@@ -176,6 +176,23 @@ func newTestPeer(name string, version int, pm *ProtocolManager, shake bool) (*te
 // handshake simulates a trivial handshake that expects the same state from the
 // remote side as we are simulating locally.
 func (p *testPeer) handshake(t *testing.T, td *big.Int, head common.Hash, genesis common.Hash) {
+	if p.version >= wan64 {
+		msg := &statusData64{
+			ProtocolVersion: uint32(p.version),
+			NetworkId:       DefaultConfig.NetworkId,
+			TD:              td,
+			CurrentBlock:    head,
+			GenesisBlock:    genesis,
+			TxTypes:         RelayTxTypes,
+		}
+		if err := p2p.ExpectMsg(p.app, StatusMsg, msg); err != nil {
+			t.Fatalf("status recv: %v", err)
+		}
+		if err := p2p.Send(p.app, StatusMsg, msg); err != nil {
+			t.Fatalf("status send: %v", err)
+		}
+		return
+	}
 	msg := &statusData{
 		ProtocolVersion: uint32(p.version),
 		NetworkId:       DefaultConfig.NetworkId,
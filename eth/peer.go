@@ -20,6 +20,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"sort"
 	"sync"
 	"time"
 
@@ -48,6 +49,7 @@ type PeerInfo struct {
 	Version    int      `json:"version"`    // Ethereum protocol version negotiated
 	Difficulty *big.Int `json:"difficulty"` // Total difficulty of the peer's blockchain
 	Head       string   `json:"head"`       // SHA3 hash of the peer's best owned block
+	TxTypes    []uint64 `json:"txTypes"`    // Transaction types relayed to the peer
 }
 
 type peer struct {
@@ -65,6 +67,8 @@ type peer struct {
 
 	knownTxs    *set.Set // Set of transaction hashes known to be known by this peer
 	knownBlocks *set.Set // Set of block hashes known to be known by this peer
+
+	txTypes map[uint64]bool // Transaction types the peer accepts
 }
 
 func newPeer(version int, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
@@ -77,6 +81,7 @@ func newPeer(version int, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
 		id:          fmt.Sprintf("%x", id[:8]),
 		knownTxs:    set.New(),
 		knownBlocks: set.New(),
+		txTypes:     make(map[uint64]bool),
 	}
 }
 
@@ -88,6 +93,49 @@ func (p *peer) Info() *PeerInfo {
 		Version:    p.version,
 		Difficulty: td,
 		Head:       hash.Hex(),
+		TxTypes:    p.TxTypes(),
+	}
+}
+
+// TxTypes returns the sorted transaction types relayed to the peer.
+func (p *peer) TxTypes() []uint64 {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	txTypes := make([]uint64, 0, len(p.txTypes))
+	for txType := range p.txTypes {
+		txTypes = append(txTypes, txType)
+	}
+	sort.Slice(txTypes, func(i, j int) bool { return txTypes[i] < txTypes[j] })
+	return txTypes
+}
+
+// SupportsTx reports whether tx can be relayed to the peer, as it accepts its
+// transaction type. Peers silently dropping a transaction would leave it marked
+// as known, never receiving it again from anyone.
+func (p *peer) SupportsTx(tx *types.Transaction) bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.txTypes[tx.Txtype()]
+}
+
+// setTxTypes sets the transaction types relayed to the peer, keeping those
+// accepted locally only, and those unknown locally if relayUnknown is set.
+func (p *peer) setTxTypes(announced []uint64, relayUnknown bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for _, txType := range announced {
+		if relayUnknown && !types.IsValidTransactionType(txType) {
+			p.txTypes[txType] = true
+			continue
+		}
+		for _, local := range RelayTxTypes {
+			if txType == local {
+				p.txTypes[txType] = true
+			}
+		}
 	}
 }
 
@@ -139,6 +187,12 @@ func (p *peer) SendTransactions(txs types.Transactions) error {
 	return p2p.Send(p.rw, TxMsg, txs)
 }
 
+// SendStemTransactions relays transactions in stem phase to the peer. They are
+// not marked known, the peer being sent them again once fluffed.
+func (p *peer) SendStemTransactions(txs types.Transactions) error {
+	return p2p.Send(p.rw, StemTxMsg, txs)
+}
+
 // SendNewBlockHashes announces the availability of a number of blocks through
 // a hash notification.
 func (p *peer) SendNewBlockHashes(hashes []common.Hash, numbers []uint64) error {
@@ -229,13 +283,25 @@ func (p *peer) RequestReceipts(hashes []common.Hash) error {
 }
 
 // Handshake executes the eth protocol handshake, negotiating version number,
-// network IDs, difficulties, head and genesis blocks.
-func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis common.Hash) error {
+// network IDs, difficulties, head and genesis blocks, and the transaction types
+// relayed, unknown ones included if relayUnknown is set.
+func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis common.Hash, relayUnknown bool) error {
 	// Send out own handshake in a new thread
 	errc := make(chan error, 2)
-	var status statusData // safe to read after two values have been received from errc
+	var status statusData64 // safe to read after two values have been received from errc
 
 	go func() {
+		if p.version >= wan64 {
+			errc <- p2p.Send(p.rw, StatusMsg, &statusData64{
+				ProtocolVersion: uint32(p.version),
+				NetworkId:       network,
+				TD:              td,
+				CurrentBlock:    head,
+				GenesisBlock:    genesis,
+				TxTypes:         RelayTxTypes,
+			})
+			return
+		}
 		errc <- p2p.Send(p.rw, StatusMsg, &statusData{
 			ProtocolVersion: uint32(p.version),
 			NetworkId:       network,
@@ -260,10 +326,11 @@ func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis
 		}
 	}
 	p.td, p.head = status.TD, status.CurrentBlock
+	p.setTxTypes(status.TxTypes, relayUnknown)
 	return nil
 }
 
-func (p *peer) readStatus(network uint64, status *statusData, genesis common.Hash) (err error) {
+func (p *peer) readStatus(network uint64, status *statusData64, genesis common.Hash) (err error) {
 	msg, err := p.rw.ReadMsg()
 	if err != nil {
 		return err
@@ -274,9 +341,18 @@ func (p *peer) readStatus(network uint64, status *statusData, genesis common.Has
 	if msg.Size > ProtocolMaxMsgSize {
 		return errResp(ErrMsgTooLarge, "%v > %v", msg.Size, ProtocolMaxMsgSize)
 	}
-	// Decode the handshake and make sure everything matches
-	if err := msg.Decode(&status); err != nil {
-		return errResp(ErrDecode, "msg %v: %v", msg, err)
+	// Decode the handshake and make sure everything matches. Peers before wan64
+	// don't announce their transaction types, but know the legacy ones
+	if p.version >= wan64 {
+		if err := msg.Decode(status); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+	} else {
+		var legacy statusData
+		if err := msg.Decode(&legacy); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		*status = statusData64{legacy.ProtocolVersion, legacy.NetworkId, legacy.TD, legacy.CurrentBlock, legacy.GenesisBlock, legacyTxTypes}
 	}
 	if status.GenesisBlock != genesis {
 		return errResp(ErrGenesisBlockMismatch, "%x (!= %x)", status.GenesisBlock[:8], genesis[:8])
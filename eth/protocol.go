@@ -32,16 +32,25 @@ import (
 const (
 	eth62 = 62
 	eth63 = 63
+	wan64 = 64 // eth63 negotiating the relayed transaction types
+	wan65 = 65 // wan64 relaying privacy transactions along Dandelion stems
 )
 
 // Official short name of the protocol used during capability negotiation.
 var ProtocolName = "wan"
 
 // Supported versions of the eth protocol (first is primary).
-var ProtocolVersions = []uint{eth63, eth62}
+var ProtocolVersions = []uint{wan65, wan64, eth63, eth62}
 
 // Number of implemented message corresponding to different protocol versions.
-var ProtocolLengths = []uint64{17, 8}
+var ProtocolLengths = []uint64{18, 17, 17, 8}
+
+// RelayTxTypes are the transaction types accepted from and relayed to peers.
+// Peers announce theirs during the handshake from wan64 on, older peers are
+// only relayed the legacy types, which they know about.
+var RelayTxTypes = []uint64{types.NORMAL_TX, types.PRIVACY_TX}
+
+var legacyTxTypes = []uint64{types.NORMAL_TX, types.PRIVACY_TX}
 
 const ProtocolMaxMsgSize = 10 * 1024 * 1024 // Maximum cap on the size of a protocol message
 
@@ -62,6 +71,9 @@ const (
 	NodeDataMsg    = 0x0e
 	GetReceiptsMsg = 0x0f
 	ReceiptsMsg    = 0x10
+
+	// Protocol messages belonging to wan65
+	StemTxMsg = 0x11
 )
 
 type errCode int
@@ -117,6 +129,17 @@ type statusData struct {
 	GenesisBlock    common.Hash
 }
 
+// statusData64 is the network packet for the status message from wan64 on,
+// extended with the transaction types the peer accepts.
+type statusData64 struct {
+	ProtocolVersion uint32
+	NetworkId       uint64
+	TD              *big.Int
+	CurrentBlock    common.Hash
+	GenesisBlock    common.Hash
+	TxTypes         []uint64
+}
+
 // newBlockHashesData is the network packet for the block announcements.
 type newBlockHashesData []struct {
 	Hash   common.Hash // Hash of one particular block being announced
@@ -18,6 +18,7 @@ package eth
 
 import (
 	"fmt"
+	"reflect"
 	"sync"
 	"testing"
 	"time"
@@ -91,6 +92,8 @@ func testStatusMsgErrors(t *testing.T, protocol int) {
 // This test checks that received transactions are added to the local pool.
 func TestRecvTransactions62(t *testing.T) { testRecvTransactions(t, 62) }
 func TestRecvTransactions63(t *testing.T) { testRecvTransactions(t, 63) }
+func TestRecvTransactions64(t *testing.T) { testRecvTransactions(t, 64) }
+func TestRecvTransactions65(t *testing.T) { testRecvTransactions(t, 65) }
 
 func testRecvTransactions(t *testing.T, protocol int) {
 	txAdded := make(chan []*types.Transaction)
@@ -119,6 +122,8 @@ func testRecvTransactions(t *testing.T, protocol int) {
 // This test checks that pending transactions are sent.
 func TestSendTransactions62(t *testing.T) { testSendTransactions(t, 62) }
 func TestSendTransactions63(t *testing.T) { testSendTransactions(t, 63) }
+func TestSendTransactions64(t *testing.T) { testSendTransactions(t, 64) }
+func TestSendTransactions65(t *testing.T) { testSendTransactions(t, 65) }
 
 func testSendTransactions(t *testing.T, protocol int) {
 	pm := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
@@ -174,6 +179,52 @@ func testSendTransactions(t *testing.T, protocol int) {
 	wg.Wait()
 }
 
+// Tests that the transaction types relayed to a peer are those announced in its
+// handshake and accepted locally, legacy peers getting the legacy types.
+func TestTxTypeNegotiation(t *testing.T) {
+	pm := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	td, head, genesis := pm.blockchain.Status()
+	defer pm.Stop()
+
+	tests := []struct {
+		version      int
+		announced    []uint64
+		relayUnknown bool
+		want         []uint64
+	}{
+		{eth63, nil, false, legacyTxTypes},
+		{wan64, []uint64{types.NORMAL_TX, types.PRIVACY_TX}, false, []uint64{types.NORMAL_TX, types.PRIVACY_TX}},
+		{wan64, []uint64{types.NORMAL_TX, 99}, false, []uint64{types.NORMAL_TX}},
+		{wan64, []uint64{types.NORMAL_TX, 99}, true, []uint64{types.NORMAL_TX, 99}},
+	}
+	for i, tt := range tests {
+		pm.relayUnknownTxs = tt.relayUnknown
+		p, _ := newTestPeer("peer", tt.version, pm, false)
+		if tt.version >= wan64 {
+			go p2p.Send(p.app, StatusMsg, &statusData64{uint32(tt.version), DefaultConfig.NetworkId, td, head, genesis, tt.announced})
+		} else {
+			go p2p.Send(p.app, StatusMsg, &statusData{uint32(tt.version), DefaultConfig.NetworkId, td, head, genesis})
+		}
+		if msg, err := p.app.ReadMsg(); err != nil || msg.Code != StatusMsg {
+			t.Fatalf("test %d: status recv: %v", i, err)
+		} else {
+			msg.Discard()
+		}
+		// Wait for the peer to be registered after the handshake
+		for start := time.Now(); pm.peers.Peer(p.peer.id) == nil && time.Since(start) < time.Second; {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if have := p.peer.TxTypes(); !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("test %d: tx types mismatch: have %v, want %v", i, have, tt.want)
+		}
+		privacy := types.NewOTATransaction(0, common.Address{}, nil, nil, nil, nil)
+		if supported := p.peer.SupportsTx(privacy); supported != (len(tt.want) > 1 && tt.want[1] == types.PRIVACY_TX) {
+			t.Errorf("test %d: privacy tx support mismatch: have %v", i, supported)
+		}
+		p.close()
+	}
+}
+
 // Tests that the custom union field encoder and decoder works correctly.
 func TestGetBlockHeadersDataEncodeDecode(t *testing.T) {
 	// Create a "random" hash for testing
@@ -0,0 +1,355 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package eth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/wanchain/go-wanchain/common/hexutil"
+	"github.com/wanchain/go-wanchain/core"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/crypto"
+	"github.com/wanchain/go-wanchain/ethdb"
+	"github.com/wanchain/go-wanchain/log"
+	"github.com/wanchain/go-wanchain/rlp"
+	"github.com/wanchain/go-wanchain/rpc"
+)
+
+const (
+	// replicaMaxBlocks is the maximum number of blocks served by the primary
+	// in a single replication request.
+	replicaMaxBlocks = 256
+
+	// replicaMaxBytes is the size of the blocks, receipts and state diffs
+	// above which the primary stops adding blocks to a batch.
+	replicaMaxBytes = 8 * 1024 * 1024
+
+	// replicaTokenLifetime is the maximum validity of a replication token.
+	replicaTokenLifetime = 30 * time.Second
+
+	// replicaPollInterval is the time the follower waits before polling the
+	// primary again once it caught up with its head.
+	replicaPollInterval = time.Second
+
+	// replicaRetryInterval is the time the follower waits before reconnecting
+	// after a failure talking to the primary.
+	replicaRetryInterval = 10 * time.Second
+)
+
+var (
+	errReplicationDisabled = errors.New("replication is disabled on this node")
+	errReplicaUnauthorized = errors.New("invalid replication token")
+	errReplicaTokenExpired = errors.New("replication token expired")
+)
+
+// replicaToken authenticates the request of follower for count blocks starting
+// at from, valid until the unix time expiry, using the secret shared between
+// the primary and its followers.
+func replicaToken(secret string, follower string, from uint64, count int, expiry uint64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "wanchain-replica:%s:%d:%d:%d", follower, from, count, expiry)
+	return hexutil.Encode(mac.Sum(nil))
+}
+
+// checkReplicaToken verifies the token of a replication request received at
+// now. Tokens expiring further than their lifetime away are rejected too.
+func checkReplicaToken(secret string, follower string, from uint64, count int, expiry uint64, token string, now time.Time) error {
+	if !hmac.Equal([]byte(token), []byte(replicaToken(secret, follower, from, count, expiry))) {
+		return errReplicaUnauthorized
+	}
+	if expiry < uint64(now.Unix()) || expiry > uint64(now.Add(replicaTokenLifetime).Unix()) {
+		return errReplicaTokenExpired
+	}
+	return nil
+}
+
+// ReplicaBlock is a canonical block exported by a primary, with its receipts
+// and the state it adds to the state of its parent.
+type ReplicaBlock struct {
+	Block    hexutil.Bytes   `json:"block"`    // RLP encoded block
+	Receipts hexutil.Bytes   `json:"receipts"` // RLP encoded receipts, in storage format
+	State    []hexutil.Bytes `json:"state"`    // Trie nodes and codes missing from the parent state
+}
+
+// ReplicaBatch is a run of consecutive canonical blocks exported by a primary.
+type ReplicaBatch struct {
+	Head   hexutil.Uint64  `json:"head"`   // Current head number of the primary
+	Blocks []*ReplicaBlock `json:"blocks"` // Blocks in ascending order
+}
+
+// PrivateReplicaAPI exports the canonical chain of a primary node to hot
+// standby followers. Every request must carry a short lived token derived
+// from the replication secret configured on both sides.
+type PrivateReplicaAPI struct {
+	eth *Ethereum
+}
+
+// NewPrivateReplicaAPI creates the replication API of the primary node.
+func NewPrivateReplicaAPI(eth *Ethereum) *PrivateReplicaAPI {
+	return &PrivateReplicaAPI{eth: eth}
+}
+
+// Blocks returns up to count canonical blocks starting at from along with
+// their receipts and state diffs, together with the current head of the
+// primary. Follower is the node ID of the requesting follower.
+func (api *PrivateReplicaAPI) Blocks(follower string, from hexutil.Uint64, count int, expiry hexutil.Uint64, token string) (*ReplicaBatch, error) {
+	secret := api.eth.config.ReplicationSecret
+	if secret == "" {
+		return nil, errReplicationDisabled
+	}
+	if err := checkReplicaToken(secret, follower, uint64(from), count, uint64(expiry), token, time.Now()); err != nil {
+		return nil, err
+	}
+	log.Debug("Serving replication batch", "follower", follower, "from", uint64(from), "count", count)
+	return replicaBatch(api.eth.BlockChain(), api.eth.ChainDb(), uint64(from), count)
+}
+
+// replicaBatch exports up to count canonical blocks of chain, stored in db,
+// starting at from.
+func replicaBatch(chain *core.BlockChain, db ethdb.Database, from uint64, count int) (*ReplicaBatch, error) {
+	if count <= 0 || count > replicaMaxBlocks {
+		count = replicaMaxBlocks
+	}
+	head := chain.CurrentBlock().NumberU64()
+	batch := &ReplicaBatch{Head: hexutil.Uint64(head)}
+
+	size := 0
+	for number := from; number <= head && len(batch.Blocks) < count && size < replicaMaxBytes; number++ {
+		block := chain.GetBlockByNumber(number)
+		if block == nil || number == 0 {
+			break
+		}
+		parent := chain.GetBlock(block.ParentHash(), number-1)
+		if parent == nil {
+			break
+		}
+		item := new(ReplicaBlock)
+
+		enc, err := rlp.EncodeToBytes(block)
+		if err != nil {
+			return nil, err
+		}
+		item.Block = enc
+
+		receipts := core.GetBlockReceipts(db, block.Hash(), number)
+		storage := make([]*types.ReceiptForStorage, len(receipts))
+		for i, receipt := range receipts {
+			storage[i] = (*types.ReceiptForStorage)(receipt)
+		}
+		if item.Receipts, err = rlp.EncodeToBytes(storage); err != nil {
+			return nil, err
+		}
+		size += len(item.Block) + len(item.Receipts)
+
+		hashes, err := core.StateDiff(db, parent.Root(), block.Root())
+		if err != nil {
+			return nil, err
+		}
+		for _, hash := range hashes {
+			blob, err := db.Get(hash.Bytes())
+			if err != nil {
+				return nil, fmt.Errorf("state node %x: %v", hash, err)
+			}
+			item.State = append(item.State, blob)
+			size += len(blob)
+		}
+		batch.Blocks = append(batch.Blocks, item)
+	}
+	return batch, nil
+}
+
+// replicaFollower tails the canonical chain of a primary node and imports its
+// blocks with the state they produced, without executing them, keeping the
+// node ready to take over on failover.
+type replicaFollower struct {
+	primary string // RPC endpoint of the primary
+	secret  string // Shared replication secret
+	id      string // Node ID of the follower, bound to its tokens
+	chain   *core.BlockChain
+	db      ethdb.Database
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newReplicaFollower creates a follower with the given node ID replicating
+// from the given primary into chain, stored in db.
+func newReplicaFollower(primary, secret, id string, chain *core.BlockChain, db ethdb.Database) *replicaFollower {
+	return &replicaFollower{
+		primary: primary,
+		secret:  secret,
+		id:      id,
+		chain:   chain,
+		db:      db,
+		quit:    make(chan struct{}),
+	}
+}
+
+// start launches the replication loop.
+func (f *replicaFollower) start() {
+	f.wg.Add(1)
+	go f.loop()
+}
+
+// stop terminates the replication loop.
+func (f *replicaFollower) stop() {
+	close(f.quit)
+	f.wg.Wait()
+}
+
+func (f *replicaFollower) loop() {
+	defer f.wg.Done()
+
+	log.Info("Starting hot standby replication", "primary", f.primary)
+	for {
+		client, err := rpc.Dial(f.primary)
+		if err == nil {
+			err = f.follow(client)
+			client.Close()
+		}
+		if err == nil {
+			return
+		}
+		log.Warn("Replication from primary failed", "primary", f.primary, "err", err)
+
+		select {
+		case <-time.After(replicaRetryInterval):
+		case <-f.quit:
+			return
+		}
+	}
+}
+
+// follow keeps importing batches from the primary until the follower is
+// stopped (returning nil) or a failure occurs.
+func (f *replicaFollower) follow(client *rpc.Client) error {
+	for {
+		caughtUp, err := f.sync(client)
+		if err != nil {
+			return err
+		}
+		wait := time.Duration(0)
+		if caughtUp {
+			wait = replicaPollInterval
+		}
+		select {
+		case <-time.After(wait):
+		case <-f.quit:
+			return nil
+		}
+	}
+}
+
+// sync fetches and imports a single batch of blocks, reporting whether the
+// follower reached the head of the primary.
+func (f *replicaFollower) sync(client *rpc.Client) (bool, error) {
+	from := f.chain.CurrentBlock().NumberU64() + 1
+
+	ctx, cancel := context.WithTimeout(context.Background(), replicaRetryInterval)
+	defer cancel()
+
+	var batch ReplicaBatch
+	expiry := uint64(time.Now().Add(replicaRetryInterval).Unix())
+	token := replicaToken(f.secret, f.id, from, replicaMaxBlocks, expiry)
+	if err := client.CallContext(ctx, &batch, "replica_blocks", f.id, hexutil.Uint64(from), replicaMaxBlocks, hexutil.Uint64(expiry), token); err != nil {
+		return false, err
+	}
+	if len(batch.Blocks) == 0 {
+		return true, nil
+	}
+	last, err := applyReplicaBatch(f.chain, f.db, &batch)
+	if err != nil || last == 0 {
+		return false, err
+	}
+	log.Debug("Replicated blocks from primary", "count", len(batch.Blocks), "number", last, "lag", uint64(batch.Head)-last)
+	return last >= uint64(batch.Head), nil
+}
+
+// applyReplicaBatch imports the blocks of a batch into chain, stored in db,
+// along with their receipts and state, returning the number of the last one.
+// The headers are verified by the consensus engine and the bodies, receipts
+// and states are checked against them, but the transactions aren't executed.
+// If the primary reorganised away from the local head, the head is rewound by
+// one block and 0 is returned, for the next batches to connect to the local
+// chain again.
+func applyReplicaBatch(chain *core.BlockChain, db ethdb.Database, batch *ReplicaBatch) (uint64, error) {
+	head := chain.CurrentBlock()
+	for i, item := range batch.Blocks {
+		block := new(types.Block)
+		if err := rlp.DecodeBytes(item.Block, block); err != nil {
+			return 0, err
+		}
+		var storage []*types.ReceiptForStorage
+		if err := rlp.DecodeBytes(item.Receipts, &storage); err != nil {
+			return 0, err
+		}
+		if block.ParentHash() != head.Hash() {
+			if i > 0 {
+				return 0, fmt.Errorf("replica batch not contiguous at block #%d", block.NumberU64())
+			}
+			if head.NumberU64() == 0 {
+				return 0, errors.New("primary runs a different genesis")
+			}
+			log.Warn("Primary reorganised, rewinding replica", "number", head.NumberU64())
+			chain.SetHead(head.NumberU64() - 1)
+			return 0, nil
+		}
+		if err := chain.Engine().VerifyHeader(chain, block.Header(), true); err != nil {
+			return 0, err
+		}
+		if err := chain.Validator().ValidateBody(block); err != nil {
+			return 0, err
+		}
+		receipts := make(types.Receipts, len(storage))
+		for i, receipt := range storage {
+			receipts[i] = (*types.Receipt)(receipt)
+		}
+		if hash := types.DeriveSha(receipts); hash != block.ReceiptHash() {
+			return 0, fmt.Errorf("block #%d: receipt root mismatch: have %x, want %x", block.NumberU64(), hash, block.ReceiptHash())
+		}
+		// Store the state diff, then check it completes the parent state into
+		// the state of the block
+		dbBatch := db.NewBatch()
+		for _, blob := range item.State {
+			if err := dbBatch.Put(crypto.Keccak256(blob), blob); err != nil {
+				return 0, err
+			}
+		}
+		if err := dbBatch.Write(); err != nil {
+			return 0, err
+		}
+		if _, err := core.StateDiff(db, head.Root(), block.Root()); err != nil {
+			return 0, fmt.Errorf("block #%d: incomplete state: %v", block.NumberU64(), err)
+		}
+		statedb, err := chain.StateAt(block.Root())
+		if err != nil {
+			return 0, err
+		}
+		status, err := chain.WriteBlockAndState(block, receipts, statedb)
+		if err != nil {
+			return 0, err
+		}
+		// Fill in the derived log fields and notify the local subscribers
+		var logs []*types.Log
+		for i, receipt := range receipts {
+			for _, l := range receipt.Logs {
+				l.BlockNumber, l.BlockHash = block.NumberU64(), block.Hash()
+				l.TxHash, l.TxIndex = block.Transactions()[i].Hash(), uint(i)
+				logs = append(logs, l)
+			}
+		}
+		events := []interface{}{core.ChainEvent{Block: block, Hash: block.Hash(), Logs: logs}}
+		if status == core.CanonStatTy {
+			events = append(events, core.ChainHeadEvent{Block: block})
+		}
+		chain.PostChainEvents(events, logs)
+		head = block
+	}
+	return head.NumberU64(), nil
+}
@@ -0,0 +1,163 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package eth
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/consensus/ethash"
+	"github.com/wanchain/go-wanchain/core"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/core/vm"
+	"github.com/wanchain/go-wanchain/crypto"
+	"github.com/wanchain/go-wanchain/ethdb"
+	"github.com/wanchain/go-wanchain/rlp"
+)
+
+func TestReplicaTokenBinding(t *testing.T) {
+	token := replicaToken("secret", "node", 100, 16, 1000)
+	if token != replicaToken("secret", "node", 100, 16, 1000) {
+		t.Fatalf("token not deterministic")
+	}
+	if token == replicaToken("other", "node", 100, 16, 1000) {
+		t.Errorf("token not bound to the secret")
+	}
+	if token == replicaToken("secret", "other", 100, 16, 1000) {
+		t.Errorf("token not bound to the follower")
+	}
+	if token == replicaToken("secret", "node", 101, 16, 1000) || token == replicaToken("secret", "node", 100, 17, 1000) {
+		t.Errorf("token not bound to the requested range")
+	}
+	if token == replicaToken("secret", "node", 100, 16, 1001) {
+		t.Errorf("token not bound to the expiry")
+	}
+}
+
+func TestCheckReplicaToken(t *testing.T) {
+	now := time.Unix(1000, 0)
+	tests := []struct {
+		secret, follower string
+		expiry           uint64
+		want             error
+	}{
+		{"secret", "node", 1010, nil},
+		{"secret", "node", 1000, nil},
+		{"other", "node", 1010, errReplicaUnauthorized},
+		{"secret", "other", 1010, errReplicaUnauthorized},
+		{"secret", "node", 999, errReplicaTokenExpired},
+		{"secret", "node", 1000 + uint64(replicaTokenLifetime/time.Second) + 1, errReplicaTokenExpired},
+	}
+	for i, tt := range tests {
+		token := replicaToken(tt.secret, tt.follower, 100, 16, tt.expiry)
+		if err := checkReplicaToken("secret", "node", 100, 16, tt.expiry, token, now); err != tt.want {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.want)
+		}
+	}
+	// Tokens issued for another range don't authorise this one
+	token := replicaToken("secret", "node", 100, 16, 1010)
+	if err := checkReplicaToken("secret", "node", 0, 256, 1010, token, now); err != errReplicaUnauthorized {
+		t.Errorf("range mismatch: have %v, want %v", err, errReplicaUnauthorized)
+	}
+}
+
+// Tests that the batches of a primary hold its blocks, receipts and state
+// diffs, that a follower imports them into the same chain and state, and that
+// batches missing part of the state are rejected.
+func TestReplicaBatch(t *testing.T) {
+	var (
+		gspec   = core.DefaultPPOWTestingGenesisBlock()
+		key, _  = crypto.GenerateKey()
+		sender  = crypto.PubkeyToAddress(key.PublicKey)
+		counter = common.HexToAddress("0x2000000000000000000000000000000000000001")
+	)
+	gspec.Alloc = core.GenesisAlloc{
+		sender: {Balance: new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18))},
+		// PUSH1 0 SLOAD PUSH1 1 ADD PUSH1 0 SSTORE
+		counter: {Balance: new(big.Int), Code: common.Hex2Bytes("60005460010160005500")},
+	}
+	primary, err := core.NewEphemeralChain(gspec)
+	if err != nil {
+		t.Fatalf("failed to create primary: %v", err)
+	}
+	defer primary.Stop()
+
+	signer := types.NewEIP155Signer(gspec.Config.ChainId)
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		tx, _ := types.SignTx(types.NewTransaction(nonce, counter, big.NewInt(1), big.NewInt(100000), big.NewInt(1), nil), signer, key)
+		if _, _, err := primary.ApplyTransactions(common.Address{0x03}, types.Transactions{tx}); err != nil {
+			t.Fatalf("failed to apply transaction: %v", err)
+		}
+	}
+	batch, err := replicaBatch(primary.BlockChain(), primary.Database(), 1, 0)
+	if err != nil {
+		t.Fatalf("failed to export batch: %v", err)
+	}
+	if batch.Head != 3 || len(batch.Blocks) != 3 {
+		t.Fatalf("batch mismatch: have head %d and %d blocks, want 3 and 3", batch.Head, len(batch.Blocks))
+	}
+	for i, item := range batch.Blocks {
+		block := new(types.Block)
+		if err := rlp.DecodeBytes(item.Block, block); err != nil {
+			t.Fatalf("block %d: failed to decode: %v", i, err)
+		}
+		if block.Hash() != primary.BlockChain().GetBlockByNumber(uint64(i+1)).Hash() {
+			t.Errorf("block %d: hash mismatch", i)
+		}
+		var receipts []*types.ReceiptForStorage
+		if err := rlp.DecodeBytes(item.Receipts, &receipts); err != nil {
+			t.Fatalf("block %d: failed to decode receipts: %v", i, err)
+		}
+		if len(receipts) != 1 {
+			t.Errorf("block %d: have %d receipts, want 1", i, len(receipts))
+		}
+		if len(item.State) == 0 {
+			t.Errorf("block %d: no state diff", i)
+		}
+	}
+	// Import the batch into a follower, first with a state node missing
+	newFollower := func() (*core.BlockChain, ethdb.Database) {
+		db, _ := ethdb.NewMemDatabase()
+		gspec.MustCommit(db)
+		chain, err := core.NewBlockChain(db, gspec.Config, ethash.NewFullFaker(db), vm.Config{})
+		if err != nil {
+			t.Fatalf("failed to create follower: %v", err)
+		}
+		return chain, db
+	}
+	damaged := &ReplicaBatch{Head: batch.Head}
+	for _, item := range batch.Blocks {
+		copied := *item
+		damaged.Blocks = append(damaged.Blocks, &copied)
+	}
+	damaged.Blocks[1].State = damaged.Blocks[1].State[1:]
+
+	follower, db := newFollower()
+	if _, err := applyReplicaBatch(follower, db, damaged); err == nil || !strings.Contains(err.Error(), "incomplete state") {
+		t.Errorf("damaged batch: have error %v, want incomplete state", err)
+	}
+	if head := follower.CurrentBlock().NumberU64(); head != 1 {
+		t.Errorf("damaged batch: head mismatch: have %d, want 1", head)
+	}
+	follower.Stop()
+
+	follower, db = newFollower()
+	defer follower.Stop()
+	number, err := applyReplicaBatch(follower, db, batch)
+	if err != nil {
+		t.Fatalf("failed to apply batch: %v", err)
+	}
+	if number != 3 || follower.CurrentBlock().Hash() != primary.CurrentBlock().Hash() {
+		t.Fatalf("follower head mismatch: have #%d %x, want #3 %x", number, follower.CurrentBlock().Hash(), primary.CurrentBlock().Hash())
+	}
+	statedb, err := follower.State()
+	if err != nil {
+		t.Fatalf("failed to open follower state: %v", err)
+	}
+	if value := statedb.GetState(counter, common.Hash{}); value != common.BigToHash(big.NewInt(3)) {
+		t.Errorf("counter mismatch: have %x, want 3", value)
+	}
+}
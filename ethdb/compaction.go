@@ -0,0 +1,160 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package ethdb
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"github.com/wanchain/go-wanchain/metrics"
+
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+// CompactionConfig are the parameters of the compaction scheduler.
+//
+// LevelDB compacts its level 0 tables on its own once enough accumulate, and
+// stalls the writes while it lags behind. During a sync, that happens in the
+// middle of large imports at unpredictable times. The scheduler compacts the
+// level 0 tables early, one key range slice at a time, while the import
+// backlog and the disk throughput leave room for it, so the own compactions
+// of LevelDB have less to catch up with.
+type CompactionConfig struct {
+	Interval      time.Duration // Interval between the scheduling decisions
+	Level0Trigger int           // Level 0 tables from which compactions are scheduled
+	Level0Urgent  int           // Level 0 tables from which compactions are scheduled regardless of the load
+	MaxBacklog    int           // Blocks waiting for import above which compactions are deferred
+	MaxThroughput float64       // Compaction throughput (MB/s) above which compactions are deferred
+	Slices        int           // Key range slices compacted one at a time
+}
+
+// DefaultCompactionConfig contains the default compaction scheduler settings,
+// scheduling compactions before LevelDB slows the writes down.
+var DefaultCompactionConfig = CompactionConfig{
+	Interval:      3 * time.Second,
+	Level0Trigger: 6,
+	Level0Urgent:  10,
+	MaxBacklog:    256,
+	MaxThroughput: 32,
+	Slices:        16,
+}
+
+// schedule decides whether to compact, given the number of level 0 tables, the
+// import backlog and the compaction throughput.
+func (c *CompactionConfig) schedule(level0, backlog int, throughput float64) bool {
+	switch {
+	case level0 < c.Level0Trigger:
+		return false
+	case level0 >= c.Level0Urgent:
+		return true
+	}
+	return backlog <= c.MaxBacklog && throughput <= c.MaxThroughput
+}
+
+// slice returns the key range of the i-th slice of the key space, split on the
+// first key byte.
+func (c *CompactionConfig) slice(i int) util.Range {
+	var r util.Range
+	if i > 0 {
+		r.Start = []byte{byte(i * 256 / c.Slices)}
+	}
+	if i < c.Slices-1 {
+		r.Limit = []byte{byte((i + 1) * 256 / c.Slices)}
+	}
+	return r
+}
+
+// ScheduleCompactions starts the compaction scheduler, reading the import
+// backlog from the given function. It stops when the database is closed.
+func (db *LDBDatabase) ScheduleCompactions(config CompactionConfig, backlog func() int) {
+	if config.Slices < 1 || config.Slices > 256 {
+		config.Slices = DefaultCompactionConfig.Slices
+	}
+	db.quitLock.Lock()
+	defer db.quitLock.Unlock()
+
+	if db.compactQuit != nil {
+		return
+	}
+	db.compactQuit = make(chan chan struct{})
+	go db.compactLoop(config, backlog)
+
+	db.log.Info("Compaction scheduler started", "level0", config.Level0Trigger, "backlog", config.MaxBacklog, "throughput", config.MaxThroughput)
+}
+
+func (db *LDBDatabase) compactLoop(config CompactionConfig, backlog func() int) {
+	var (
+		level0Gauge     gometrics.Gauge
+		backlogGauge    gometrics.Gauge
+		scheduledTimer  gometrics.Timer
+		deferredMeter   gometrics.Meter
+		slice           int
+		wait            = config.Interval
+		last, lastStats = time.Now(), [3]float64{}
+	)
+	if metrics.Enabled {
+		level0Gauge = metrics.NewGauge(db.metricsPrefix + "compact/level0")
+		backlogGauge = metrics.NewGauge(db.metricsPrefix + "compact/backlog")
+		scheduledTimer = metrics.NewTimer(db.metricsPrefix + "compact/scheduled")
+		deferredMeter = metrics.NewMeter(db.metricsPrefix + "compact/deferred")
+	}
+	if stats, err := db.compactionStats(); err == nil {
+		lastStats = stats
+	}
+	for {
+		select {
+		case done := <-db.compactQuit:
+			close(done)
+			return
+		case <-time.After(wait):
+		}
+		wait = config.Interval
+
+		value, err := db.db.GetProperty("leveldb.num-files-at-level0")
+		if err != nil {
+			db.log.Error("Failed to read level 0 tables, compaction scheduler stopped", "err", err)
+			close(<-db.compactQuit)
+			return
+		}
+		level0, _ := strconv.Atoi(value)
+		stats, err := db.compactionStats()
+		if err != nil {
+			db.log.Error("Failed to read compaction stats, compaction scheduler stopped", "err", err)
+			close(<-db.compactQuit)
+			return
+		}
+		throughput := (stats[1] + stats[2] - lastStats[1] - lastStats[2]) / time.Since(last).Seconds()
+		last, lastStats = time.Now(), stats
+		pending := backlog()
+
+		if level0Gauge != nil {
+			level0Gauge.Update(int64(level0))
+			backlogGauge.Update(int64(pending))
+		}
+		if !config.schedule(level0, pending, throughput) {
+			if level0 >= config.Level0Trigger {
+				db.log.Debug("Deferred compaction", "level0", level0, "backlog", pending, "throughput", throughput)
+				if deferredMeter != nil {
+					deferredMeter.Mark(1)
+				}
+			}
+			continue
+		}
+		// Compact a slice, then leave the disk alone for as long as it took
+		start := time.Now()
+		if err := db.db.CompactRange(config.slice(slice)); err != nil {
+			db.log.Warn("Scheduled compaction failed", "slice", slice, "err", err)
+		}
+		elapsed := time.Since(start)
+		if scheduledTimer != nil {
+			scheduledTimer.Update(elapsed)
+		}
+		db.log.Debug("Scheduled compaction", "slice", slice, "level0", level0, "backlog", pending, "elapsed", elapsed)
+
+		slice = (slice + 1) % config.Slices
+		if elapsed > wait {
+			wait = elapsed
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package ethdb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+// Tests that compactions are scheduled once enough level 0 tables accumulate,
+// deferred while the import backlog or the disk throughput is high, unless the
+// level 0 tables are about to stall the writes.
+func TestCompactionSchedule(t *testing.T) {
+	config := DefaultCompactionConfig
+	tests := []struct {
+		level0     int
+		backlog    int
+		throughput float64
+		compact    bool
+	}{
+		{0, 0, 0, false},
+		{config.Level0Trigger - 1, 0, 0, false},
+		{config.Level0Trigger, 0, 0, true},
+		{config.Level0Trigger, config.MaxBacklog, config.MaxThroughput, true},
+		{config.Level0Trigger, config.MaxBacklog + 1, 0, false},
+		{config.Level0Trigger, 0, config.MaxThroughput + 1, false},
+		{config.Level0Urgent, config.MaxBacklog + 1, config.MaxThroughput + 1, true},
+	}
+	for i, tt := range tests {
+		if compact := config.schedule(tt.level0, tt.backlog, tt.throughput); compact != tt.compact {
+			t.Errorf("test %d: schedule mismatch: have %v, want %v", i, compact, tt.compact)
+		}
+	}
+}
+
+// Tests that the key range slices cover the whole key space without overlaps.
+func TestCompactionSlices(t *testing.T) {
+	for _, slices := range []int{1, 3, 16, 256} {
+		config := CompactionConfig{Slices: slices}
+
+		var limit []byte
+		for i := 0; i < slices; i++ {
+			r := config.slice(i)
+			if !bytes.Equal(r.Start, limit) {
+				t.Fatalf("%d slices: slice %d start mismatch: have %x, want %x", slices, i, r.Start, limit)
+			}
+			if r.Limit != nil && bytes.Compare(r.Limit, r.Start) <= 0 {
+				t.Fatalf("%d slices: slice %d empty: %x-%x", slices, i, r.Start, r.Limit)
+			}
+			limit = r.Limit
+		}
+		if limit != nil {
+			t.Errorf("%d slices: last slice limited to %x", slices, limit)
+		}
+	}
+}
+
+// Tests that the compaction scheduler runs against a live database and stops
+// when the database is closed.
+func TestCompactionScheduler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethdb-compaction")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewLDBDatabase(dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	polled := make(chan struct{}, 1)
+	config := CompactionConfig{Interval: 10 * time.Millisecond, Slices: 4}
+	db.ScheduleCompactions(config, func() int {
+		select {
+		case polled <- struct{}{}:
+		default:
+		}
+		return 0
+	})
+	for i := 0; i < 100; i++ {
+		db.Put([]byte{byte(i)}, []byte{byte(i)})
+	}
+	select {
+	case <-polled:
+	case <-time.After(time.Second):
+		t.Fatalf("compaction scheduler not running")
+	}
+	closed := make(chan struct{})
+	go func() {
+		db.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("database close blocked by the compaction scheduler")
+	}
+}
@@ -17,6 +17,8 @@
 package ethdb
 
 import (
+	goerrors "errors"
+	"fmt"
 	"strconv"
 	"strings"
 	"sync"
@@ -49,8 +51,11 @@ type LDBDatabase struct {
 	compReadMeter  gometrics.Meter // Meter for measuring the data read during compaction
 	compWriteMeter gometrics.Meter // Meter for measuring the data written during compaction
 
-	quitLock sync.Mutex      // Mutex protecting the quit channel access
-	quitChan chan chan error // Quit channel to stop the metrics collection before closing the database
+	quitLock    sync.Mutex         // Mutex protecting the quit channel access
+	quitChan    chan chan error    // Quit channel to stop the metrics collection before closing the database
+	compactQuit chan chan struct{} // Quit channel to stop the compaction scheduler before closing the database
+
+	metricsPrefix string // Prefix of the metrics, set by Meter
 
 	log log.Logger // Contextual logger tracking the database path
 }
@@ -154,6 +159,11 @@ func (db *LDBDatabase) Close() {
 	db.quitLock.Lock()
 	defer db.quitLock.Unlock()
 
+	if db.compactQuit != nil {
+		done := make(chan struct{})
+		db.compactQuit <- done
+		<-done
+	}
 	if db.quitChan != nil {
 		errc := make(chan error)
 		db.quitChan <- errc
@@ -180,6 +190,7 @@ func (db *LDBDatabase) Meter(prefix string) {
 		return
 	}
 	// Initialize all the metrics collector at the requested prefix
+	db.metricsPrefix = prefix
 	db.getTimer = metrics.NewTimer(prefix + "user/gets")
 	db.putTimer = metrics.NewTimer(prefix + "user/puts")
 	db.delTimer = metrics.NewTimer(prefix + "user/dels")
@@ -211,47 +222,18 @@ func (db *LDBDatabase) Meter(prefix string) {
 //      3   |        570 |    1113.18458 |       0.00000 |       0.00000 |       0.00000
 func (db *LDBDatabase) meter(refresh time.Duration) {
 	// Create the counters to store current and previous values
-	counters := make([][]float64, 2)
-	for i := 0; i < 2; i++ {
-		counters[i] = make([]float64, 3)
-	}
+	counters := make([][3]float64, 2)
+
 	// Iterate ad infinitum and collect the stats
 	for i := 1; ; i++ {
 		// Retrieve the database stats
-		stats, err := db.db.GetProperty("leveldb.stats")
+		stats, err := db.compactionStats()
 		if err != nil {
 			db.log.Error("Failed to read database stats", "err", err)
 			return
 		}
-		// Find the compaction table, skip the header
-		lines := strings.Split(stats, "\n")
-		for len(lines) > 0 && strings.TrimSpace(lines[0]) != "Compactions" {
-			lines = lines[1:]
-		}
-		if len(lines) <= 3 {
-			db.log.Error("Compaction table not found")
-			return
-		}
-		lines = lines[3:]
+		counters[i%2] = stats
 
-		// Iterate over all the table rows, and accumulate the entries
-		for j := 0; j < len(counters[i%2]); j++ {
-			counters[i%2][j] = 0
-		}
-		for _, line := range lines {
-			parts := strings.Split(line, "|")
-			if len(parts) != 6 {
-				break
-			}
-			for idx, counter := range parts[3:] {
-				value, err := strconv.ParseFloat(strings.TrimSpace(counter), 64)
-				if err != nil {
-					db.log.Error("Compaction entry parsing failed", "err", err)
-					return
-				}
-				counters[i%2][idx] += value
-			}
-		}
 		// Update all the requested meters
 		if db.compTimeMeter != nil {
 			db.compTimeMeter.Mark(int64((counters[i%2][0] - counters[(i-1)%2][0]) * 1000 * 1000 * 1000))
@@ -275,6 +257,42 @@ func (db *LDBDatabase) meter(refresh time.Duration) {
 	}
 }
 
+// compactionStats returns the total time (seconds), data read and data written
+// (MB) of the compactions since the database was opened.
+func (db *LDBDatabase) compactionStats() ([3]float64, error) {
+	var totals [3]float64
+
+	stats, err := db.db.GetProperty("leveldb.stats")
+	if err != nil {
+		return totals, err
+	}
+	// Find the compaction table, skip the header
+	lines := strings.Split(stats, "\n")
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) != "Compactions" {
+		lines = lines[1:]
+	}
+	if len(lines) <= 3 {
+		return totals, goerrors.New("compaction table not found")
+	}
+	lines = lines[3:]
+
+	// Iterate over all the table rows, and accumulate the entries
+	for _, line := range lines {
+		parts := strings.Split(line, "|")
+		if len(parts) != 6 {
+			break
+		}
+		for idx, counter := range parts[3:] {
+			value, err := strconv.ParseFloat(strings.TrimSpace(counter), 64)
+			if err != nil {
+				return totals, fmt.Errorf("compaction entry parsing failed: %v", err)
+			}
+			totals[idx] += value
+		}
+	}
+	return totals, nil
+}
+
 func (db *LDBDatabase) NewBatch() Batch {
 	return &ldbBatch{db: db.db, b: new(leveldb.Batch)}
 }
@@ -0,0 +1,281 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package ethdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/wanchain/go-wanchain/log"
+)
+
+var (
+	schemaVersionKey = []byte("SchemaVersion") // Number of the migrations applied to the database
+
+	// The undo journal of a running migration: the entries restore the keys it
+	// changed to their previous value, the touched keys tell which already are.
+	journalCountKey      = []byte("migration-journal-count")  // Number of undo entries (uint64 big endian)
+	journalCommitKey     = []byte("migration-journal-commit") // Present if the migration completed
+	journalEntryPrefix   = []byte("migration-journal-e")      // journalEntryPrefix + seq (uint64 big endian) -> undo entry
+	journalTouchedPrefix = []byte("migration-journal-t")      // journalTouchedPrefix + key -> empty
+)
+
+// ErrSchemaTooNew is returned if the database was upgraded by a newer version,
+// whose layout this one doesn't know.
+var ErrSchemaTooNew = errors.New("database schema is newer than supported")
+
+// Migration upgrades the layout of the database from the previous schema
+// version to the next one. It may report its progress as it goes.
+type Migration struct {
+	Name    string
+	Migrate func(db Database, progress func(done, total uint64)) error
+}
+
+// SchemaVersion returns the schema version of the database, 0 if unversioned.
+func SchemaVersion(db Database) uint64 {
+	data, _ := db.Get(schemaVersionKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// WriteSchemaVersion sets the schema version of the database.
+func WriteSchemaVersion(db Putter, version uint64) error {
+	return db.Put(schemaVersionKey, encodeUint64(version))
+}
+
+// Migrate applies the migrations the database is missing, in order, updating
+// its schema version after each. Every migration is journaled: one failing,
+// or interrupted by a crash, is rolled back, leaving the database at the
+// previous version.
+func Migrate(db Database, migrations []Migration) error {
+	if err := recoverJournal(db); err != nil {
+		return err
+	}
+	version := SchemaVersion(db)
+	if version > uint64(len(migrations)) {
+		return fmt.Errorf("%v: version %d, supported %d", ErrSchemaTooNew, version, len(migrations))
+	}
+	for ; version < uint64(len(migrations)); version++ {
+		migration := migrations[version]
+		log.Warn("Migrating database schema", "version", version+1, "migration", migration.Name)
+
+		var (
+			start   = time.Now()
+			logged  = time.Now()
+			journal = &journalDB{db: db}
+		)
+		progress := func(done, total uint64) {
+			if time.Since(logged) > 8*time.Second {
+				log.Info("Migrating database schema", "migration", migration.Name, "done", done, "total", total, "elapsed", time.Since(start))
+				logged = time.Now()
+			}
+		}
+		if err := migration.Migrate(journal, progress); err != nil {
+			log.Error("Database migration failed, rolling back", "migration", migration.Name, "err", err)
+			if rerr := rollbackJournal(db); rerr != nil {
+				return fmt.Errorf("migration %q failed: %v, rollback failed: %v", migration.Name, err, rerr)
+			}
+			return fmt.Errorf("migration %q failed: %v", migration.Name, err)
+		}
+		if err := commitJournal(db, version+1); err != nil {
+			return err
+		}
+		log.Info("Migrated database schema", "version", version+1, "migration", migration.Name, "elapsed", time.Since(start))
+	}
+	return nil
+}
+
+// recoverJournal finishes the journal left by a crashed migration: the
+// changes of a completed migration are kept, others are rolled back.
+func recoverJournal(db Database) error {
+	if ok, _ := db.Has(journalCountKey); !ok {
+		return nil
+	}
+	if ok, _ := db.Has(journalCommitKey); ok {
+		return clearJournal(db)
+	}
+	log.Warn("Rolling back interrupted database migration")
+	return rollbackJournal(db)
+}
+
+// commitJournal sets the schema version reached by a migration and discards
+// its journal.
+func commitJournal(db Database, version uint64) error {
+	batch := db.NewBatch()
+	batch.Put(schemaVersionKey, encodeUint64(version))
+	batch.Put(journalCommitKey, nil)
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	return clearJournal(db)
+}
+
+// rollbackJournal restores the keys changed by a migration, newest first, and
+// discards its journal.
+func rollbackJournal(db Database) error {
+	count := journalCount(db)
+	for seq := count; seq > 0; seq-- {
+		entry, err := db.Get(append(journalEntryPrefix, encodeUint64(seq-1)...))
+		if err != nil {
+			continue // Allocated by a batch never written
+		}
+		key, value, existed, err := decodeUndoEntry(entry)
+		if err != nil {
+			return err
+		}
+		if existed {
+			err = db.Put(key, value)
+		} else {
+			err = db.Delete(key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return clearJournal(db)
+}
+
+// clearJournal deletes the undo journal, its count last so an interrupted
+// clearing resumes on restart.
+func clearJournal(db Database) error {
+	for seq := journalCount(db); seq > 0; seq-- {
+		entryKey := append(journalEntryPrefix, encodeUint64(seq-1)...)
+		if entry, err := db.Get(entryKey); err == nil {
+			if key, _, _, err := decodeUndoEntry(entry); err == nil {
+				db.Delete(append(journalTouchedPrefix, key...))
+			}
+			db.Delete(entryKey)
+		}
+	}
+	if err := db.Delete(journalCommitKey); err != nil {
+		return err
+	}
+	return db.Delete(journalCountKey)
+}
+
+func journalCount(db Database) uint64 {
+	data, _ := db.Get(journalCountKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// journalDB is the database handed to a migration, journaling the previous
+// value of every key before its first change.
+type journalDB struct {
+	db   Database
+	lock sync.Mutex
+	seq  uint64 // Number of undo entries allocated
+}
+
+// undo appends to the batch the undo entry of a key not journaled yet.
+func (j *journalDB) undo(batch Batch, key []byte) error {
+	if ok, _ := j.db.Has(append(journalTouchedPrefix, key...)); ok {
+		return nil
+	}
+	value, err := j.db.Get(key)
+	existed := err == nil
+
+	j.lock.Lock()
+	seq := j.seq
+	j.seq++
+	j.lock.Unlock()
+
+	batch.Put(append(journalEntryPrefix, encodeUint64(seq)...), encodeUndoEntry(key, value, existed))
+	return batch.Put(append(journalTouchedPrefix, key...), nil)
+}
+
+// write writes a batch holding undo entries along with the journal count. The
+// writes are serialized for the count never to go back.
+func (j *journalDB) write(batch Batch) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	batch.Put(journalCountKey, encodeUint64(j.seq))
+	return batch.Write()
+}
+
+func (j *journalDB) Put(key []byte, value []byte) error {
+	batch := j.NewBatch()
+	if err := batch.Put(key, value); err != nil {
+		return err
+	}
+	return batch.Write()
+}
+
+func (j *journalDB) Get(key []byte) ([]byte, error) { return j.db.Get(key) }
+func (j *journalDB) Has(key []byte) (bool, error)   { return j.db.Has(key) }
+
+func (j *journalDB) Delete(key []byte) error {
+	batch := j.db.NewBatch()
+	if err := j.undo(batch, key); err != nil {
+		return err
+	}
+	// The deletion can't join the batch, the journal is written first
+	if err := j.write(batch); err != nil {
+		return err
+	}
+	return j.db.Delete(key)
+}
+
+// Close is a noop, the database is closed by its owner.
+func (j *journalDB) Close() {}
+
+func (j *journalDB) NewBatch() Batch {
+	return &journalBatch{journal: j, batch: j.db.NewBatch(), touched: make(map[string]struct{})}
+}
+
+// journalBatch writes the undo entries of its keys along with them.
+type journalBatch struct {
+	journal *journalDB
+	batch   Batch
+	touched map[string]struct{} // Keys journaled by the batch, not written yet
+}
+
+func (b *journalBatch) Put(key, value []byte) error {
+	if _, ok := b.touched[string(key)]; !ok {
+		if err := b.journal.undo(b.batch, key); err != nil {
+			return err
+		}
+		b.touched[string(key)] = struct{}{}
+	}
+	return b.batch.Put(key, value)
+}
+
+func (b *journalBatch) ValueSize() int { return b.batch.ValueSize() }
+
+func (b *journalBatch) Write() error {
+	b.touched = make(map[string]struct{})
+	return b.journal.write(b.batch)
+}
+
+func encodeUint64(n uint64) []byte {
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, n)
+	return enc
+}
+
+// encodeUndoEntry encodes the previous state of a key: a byte telling whether
+// it existed, the length of the key (uint32 big endian), the key and the value.
+func encodeUndoEntry(key, value []byte, existed bool) []byte {
+	entry := make([]byte, 5, 5+len(key)+len(value))
+	if existed {
+		entry[0] = 1
+	}
+	binary.BigEndian.PutUint32(entry[1:], uint32(len(key)))
+	return append(append(entry, key...), value...)
+}
+
+func decodeUndoEntry(entry []byte) (key, value []byte, existed bool, err error) {
+	if len(entry) < 5 || uint64(len(entry)-5) < uint64(binary.BigEndian.Uint32(entry[1:])) {
+		return nil, nil, false, errors.New("corrupted migration journal")
+	}
+	size := binary.BigEndian.Uint32(entry[1:])
+	return entry[5 : 5+size], entry[5+size:], entry[0] == 1, nil
+}
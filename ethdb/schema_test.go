@@ -0,0 +1,108 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package ethdb
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// testMigration rewrites the value of the key a, deletes b and creates c, then
+// fails if requested.
+func testMigration(fail bool) Migration {
+	return Migration{
+		Name: "test",
+		Migrate: func(db Database, progress func(done, total uint64)) error {
+			batch := db.NewBatch()
+			batch.Put([]byte("a"), []byte("new"))
+			batch.Put([]byte("a"), []byte("newer"))
+			batch.Put([]byte("c"), []byte("created"))
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			progress(1, 2)
+			if err := db.Delete([]byte("b")); err != nil {
+				return err
+			}
+			progress(2, 2)
+			if fail {
+				return errors.New("boom")
+			}
+			return nil
+		},
+	}
+}
+
+func newMigrationTestDB(t *testing.T) *MemDatabase {
+	db, _ := NewMemDatabase()
+	db.Put([]byte("a"), []byte("old"))
+	db.Put([]byte("b"), []byte("deleted"))
+	return db
+}
+
+// checkOriginal checks that the database holds the data before the migration,
+// and no journal.
+func checkOriginal(t *testing.T, db *MemDatabase) {
+	if value, _ := db.Get([]byte("a")); !bytes.Equal(value, []byte("old")) {
+		t.Errorf("a not restored: %q", value)
+	}
+	if value, _ := db.Get([]byte("b")); !bytes.Equal(value, []byte("deleted")) {
+		t.Errorf("b not restored: %q", value)
+	}
+	if ok, _ := db.Has([]byte("c")); ok {
+		t.Errorf("c not removed")
+	}
+	if keys := db.Keys(); len(keys) != 2 {
+		t.Errorf("journal left behind: %d keys", len(keys))
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	db := newMigrationTestDB(t)
+	if err := Migrate(db, []Migration{testMigration(false)}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	if version := SchemaVersion(db); version != 1 {
+		t.Errorf("schema version mismatch: have %d, want 1", version)
+	}
+	if value, _ := db.Get([]byte("a")); !bytes.Equal(value, []byte("newer")) {
+		t.Errorf("a not migrated: %q", value)
+	}
+	if ok, _ := db.Has([]byte("b")); ok {
+		t.Errorf("b not deleted")
+	}
+	if keys := db.Keys(); len(keys) != 3 {
+		t.Errorf("journal left behind: %d keys", len(keys))
+	}
+	// Migrated databases must be left alone, newer ones rejected
+	if err := Migrate(db, []Migration{testMigration(true)}); err != nil {
+		t.Errorf("migration applied twice: %v", err)
+	}
+	if err := Migrate(db, nil); err == nil {
+		t.Errorf("newer schema accepted")
+	}
+}
+
+func TestMigrateRollback(t *testing.T) {
+	db := newMigrationTestDB(t)
+	if err := Migrate(db, []Migration{testMigration(true)}); err == nil {
+		t.Fatalf("failed migration succeeded")
+	}
+	if version := SchemaVersion(db); version != 0 {
+		t.Errorf("schema version mismatch: have %d, want 0", version)
+	}
+	checkOriginal(t, db)
+}
+
+// Tests that a migration interrupted by a crash is rolled back on restart.
+func TestMigrateRecovery(t *testing.T) {
+	db := newMigrationTestDB(t)
+	if err := testMigration(false).Migrate(&journalDB{db: db}, func(uint64, uint64) {}); err != nil {
+		t.Fatal(err)
+	}
+	if err := recoverJournal(db); err != nil {
+		t.Fatalf("failed to recover: %v", err)
+	}
+	checkOriginal(t, db)
+}
@@ -0,0 +1,376 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+// Package faucet implements a rate limited testnet faucet running inside a
+// node, dispensing plain WAN as well as OTA coin note and stamp deposits.
+package faucet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/wanchain/go-wanchain/accounts"
+	"github.com/wanchain/go-wanchain/accounts/keystore"
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/common/hexutil"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/core/vm"
+	"github.com/wanchain/go-wanchain/crypto"
+	"github.com/wanchain/go-wanchain/eth"
+	"github.com/wanchain/go-wanchain/log"
+	"github.com/wanchain/go-wanchain/p2p"
+	"github.com/wanchain/go-wanchain/params"
+	"github.com/wanchain/go-wanchain/rpc"
+)
+
+const (
+	// Request kinds served by the faucet
+	KindWan   = "wan"   // Plain transfer to an account
+	KindCoin  = "coin"  // Coin note deposit to a fresh OTA of a wan address
+	KindStamp = "stamp" // Stamp deposit to a fresh OTA of a wan address
+
+	transferGas = 21000
+	depositGas  = 200000
+
+	captchaVerifyURL = "https://www.google.com/recaptcha/api/siteverify"
+	maxRequestSize   = 4096
+
+	// Timeouts guarding the public endpoint and the captcha verification
+	readTimeout    = 10 * time.Second
+	writeTimeout   = 30 * time.Second
+	idleTimeout    = 2 * time.Minute
+	captchaTimeout = 10 * time.Second
+)
+
+var (
+	errMainnet        = errors.New("faucet refuses to run on the main network")
+	errUnauthorized   = errors.New("missing or invalid API key or captcha")
+	errRateLimited    = errors.New("funds requested too recently")
+	errUnknownKind    = errors.New("unknown funding kind")
+	errInvalidAddress = errors.New("invalid recipient address")
+)
+
+// captchaClient is the HTTP client verifying captcha responses.
+var captchaClient = &http.Client{Timeout: captchaTimeout}
+
+// Config are the configuration parameters of the faucet.
+type Config struct {
+	Addr          string         // Listening address of the faucet endpoint, empty disables the faucet
+	Account       common.Address // Unlocked account the funds are sent from
+	Amount        *big.Int       // WAN sent on plain transfers
+	CoinValue     *big.Int       // Denomination of the coin note deposits
+	StampValue    *big.Int       // Denomination of the stamp deposits
+	Period        time.Duration  // Minimum time between two fundings of the same client or recipient
+	APIKeys       []string       `toml:",omitempty"` // Keys authorizing requests without captcha
+	CaptchaSecret string         `toml:",omitempty"` // Recaptcha secret verifying the other requests
+}
+
+// DefaultConfig contains the default faucet settings.
+var DefaultConfig = Config{
+	Amount:     new(big.Int).Mul(big.NewInt(10), big.NewInt(params.Wan)),
+	CoinValue:  new(big.Int).Mul(big.NewInt(10), big.NewInt(params.Wan)),
+	StampValue: big.NewInt(90000000000000000), // 0.09 WAN
+	Period:     24 * time.Hour,
+}
+
+// request is a funding request. Plain transfers are sent to Address, OTA
+// deposits to a fresh OTA of WAddress.
+type request struct {
+	Kind     string         `json:"kind"`
+	Address  common.Address `json:"address"`
+	WAddress hexutil.Bytes  `json:"waddress"`
+	Captcha  string         `json:"captcha"`
+}
+
+// response is the outcome of a funding request.
+type response struct {
+	Tx    common.Hash   `json:"tx,omitempty"`
+	OTA   hexutil.Bytes `json:"ota,omitempty"`
+	Error string        `json:"error,omitempty"`
+}
+
+// Service is the faucet, running as a node service.
+type Service struct {
+	config  Config
+	eth     *eth.Ethereum
+	apiKeys map[string]bool
+
+	send    func(to common.Address, value *big.Int, gas uint64, data []byte) (common.Hash, error)
+	captcha func(response, remoteIP string) error
+
+	lock    sync.Mutex   // Serializes nonce assignment
+	limiter *limiter     // Tracks the last funding of the clients and recipients
+	server  *http.Server // Faucet endpoint
+}
+
+// New creates a faucet funding requests from the given Ethereum service.
+func New(config *Config, ethServ *eth.Ethereum) (*Service, error) {
+	if ethServ.BlockChain().Config().ChainId.Cmp(params.MainnetChainConfig.ChainId) == 0 {
+		return nil, errMainnet
+	}
+	f := newService(config)
+	f.eth = ethServ
+	f.send = f.sendTx
+	return f, nil
+}
+
+func newService(config *Config) *Service {
+	f := &Service{
+		config:  *config,
+		apiKeys: make(map[string]bool),
+		limiter: newLimiter(config.Period),
+	}
+	for _, key := range config.APIKeys {
+		f.apiKeys[key] = true
+	}
+	f.captcha = f.verifyCaptcha
+	return f
+}
+
+// Protocols implements node.Service, returning the P2P network protocols used
+// by the faucet (nil as it doesn't use the devp2p overlay network).
+func (f *Service) Protocols() []p2p.Protocol { return nil }
+
+// APIs implements node.Service, returning the RPC API endpoints provided by the
+// faucet (nil as it serves its own HTTP endpoint).
+func (f *Service) APIs() []rpc.API { return nil }
+
+// Start implements node.Service, starting up the faucet endpoint.
+func (f *Service) Start(server *p2p.Server) error {
+	listener, err := net.Listen("tcp", f.config.Addr)
+	if err != nil {
+		return err
+	}
+	f.server = &http.Server{
+		Handler:      f,
+		ReadTimeout:  readTimeout,
+		WriteTimeout: writeTimeout,
+		IdleTimeout:  idleTimeout,
+	}
+	go f.server.Serve(listener)
+
+	log.Info("Faucet started", "addr", listener.Addr(), "account", f.config.Account)
+	return nil
+}
+
+// Stop implements node.Service, terminating the faucet endpoint.
+func (f *Service) Stop() error {
+	if f.server != nil {
+		f.server.Close()
+	}
+	log.Info("Faucet stopped")
+	return nil
+}
+
+// ServeHTTP handles the funding requests.
+func (f *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req request
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&req); err != nil {
+		reply(w, http.StatusBadRequest, &response{Error: err.Error()})
+		return
+	}
+	client, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		client = r.RemoteAddr
+	}
+	// API key holders are limited per key, the others per IP after passing the captcha
+	if key := r.Header.Get("X-Api-Key"); key != "" {
+		if !f.apiKeys[key] {
+			reply(w, http.StatusForbidden, &response{Error: errUnauthorized.Error()})
+			return
+		}
+		client = "key:" + key
+	} else if err := f.captcha(req.Captcha, client); err != nil {
+		log.Debug("Faucet captcha rejected", "client", client, "err", err)
+		reply(w, http.StatusForbidden, &response{Error: errUnauthorized.Error()})
+		return
+	}
+	res, err := f.fund(client, &req)
+	if err == errRateLimited {
+		reply(w, http.StatusTooManyRequests, &response{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		reply(w, http.StatusBadRequest, &response{Error: err.Error()})
+		return
+	}
+	reply(w, http.StatusOK, res)
+}
+
+// fund serves an authorized funding request of client.
+func (f *Service) fund(client string, req *request) (*response, error) {
+	var (
+		recipient string
+		to        common.Address
+		value     *big.Int
+		gas       uint64
+		data      []byte
+		ota       []byte
+	)
+	switch req.Kind {
+	case KindWan, "":
+		if req.Address == (common.Address{}) {
+			return nil, errInvalidAddress
+		}
+		recipient, to, value, gas = req.Address.Hex(), req.Address, f.config.Amount, transferGas
+
+	case KindCoin, KindStamp:
+		A, B, err := keystore.GeneratePKPairFromWAddress(req.WAddress)
+		if err != nil {
+			return nil, errInvalidAddress
+		}
+		A1, R, _, err := crypto.GenerateOneTimeKeyWithSecret(A, B)
+		if err != nil {
+			return nil, err
+		}
+		ota = keystore.GenerateWaddressFromPK(A1, R)[:]
+		if req.Kind == KindCoin {
+			value = f.config.CoinValue
+			to, data, err = vm.PackBuyCoinNote(ota, value)
+		} else {
+			value = f.config.StampValue
+			to, data, err = vm.PackBuyStamp(ota, value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		recipient, gas = req.WAddress.String(), depositGas
+
+	default:
+		return nil, errUnknownKind
+	}
+	if !f.limiter.allow(client, recipient) {
+		return nil, errRateLimited
+	}
+	hash, err := f.send(to, value, gas, data)
+	if err != nil {
+		f.limiter.revoke(client, recipient)
+		return nil, err
+	}
+	log.Info("Faucet funds sent", "client", client, "kind", req.Kind, "recipient", recipient, "value", value, "tx", hash)
+	return &response{Tx: hash, OTA: ota}, nil
+}
+
+// sendTx signs a transaction with the faucet account and submits it to the
+// transaction pool.
+func (f *Service) sendTx(to common.Address, value *big.Int, gas uint64, data []byte) (common.Hash, error) {
+	account := accounts.Account{Address: f.config.Account}
+	wallet, err := f.eth.AccountManager().Find(account)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	price, err := f.eth.ApiBackend.SuggestPrice(context.Background())
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	nonce := f.eth.TxPool().State().GetNonce(account.Address)
+	tx := types.NewTransaction(nonce, to, value, new(big.Int).SetUint64(gas), price, data)
+	signed, err := wallet.SignTx(account, tx, f.eth.BlockChain().Config().ChainId)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if err := f.eth.TxPool().AddLocal(signed); err != nil {
+		return common.Hash{}, err
+	}
+	return signed.Hash(), nil
+}
+
+// verifyCaptcha checks a recaptcha response with the captcha service. All
+// requests are rejected if no captcha secret is configured.
+func (f *Service) verifyCaptcha(captcha, remoteIP string) error {
+	if f.config.CaptchaSecret == "" || captcha == "" {
+		return errUnauthorized
+	}
+	form := url.Values{}
+	form.Add("secret", f.config.CaptchaSecret)
+	form.Add("response", captcha)
+	form.Add("remoteip", remoteIP)
+
+	res, err := captchaClient.PostForm(captchaVerifyURL, form)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	var result struct {
+		Success bool            `json:"success"`
+		Errors  json.RawMessage `json:"error-codes"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return err
+	}
+	if !result.Success {
+		return fmt.Errorf("captcha verification failed: %s", result.Errors)
+	}
+	return nil
+}
+
+func reply(w http.ResponseWriter, status int, res *response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(res)
+}
+
+// limiter grants each client and each recipient one funding per period.
+type limiter struct {
+	period time.Duration
+	now    func() time.Time
+
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+func newLimiter(period time.Duration) *limiter {
+	return &limiter{
+		period: period,
+		now:    time.Now,
+		last:   make(map[string]time.Time),
+	}
+}
+
+// allow records a funding of recipient by client, if neither was funded
+// during the last period.
+func (l *limiter) allow(client, recipient string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for id, last := range l.last {
+		if now.Sub(last) >= l.period {
+			delete(l.last, id)
+		}
+	}
+	client, recipient = "client:"+client, "recipient:"+recipient
+	if _, ok := l.last[client]; ok {
+		return false
+	}
+	if _, ok := l.last[recipient]; ok {
+		return false
+	}
+	l.last[client], l.last[recipient] = now, now
+	return true
+}
+
+// revoke forgets a funding which failed to be sent.
+func (l *limiter) revoke(client, recipient string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.last, "client:"+client)
+	delete(l.last, "recipient:"+recipient)
+}
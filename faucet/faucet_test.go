@@ -0,0 +1,145 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package faucet
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/wanchain/go-wanchain/accounts/keystore"
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/common/hexutil"
+	"github.com/wanchain/go-wanchain/core/vm"
+	"github.com/wanchain/go-wanchain/crypto"
+)
+
+type sentTx struct {
+	to    common.Address
+	value *big.Int
+	data  []byte
+}
+
+func newTestFaucet(t *testing.T) (*Service, *[]sentTx) {
+	config := DefaultConfig
+	config.APIKeys = []string{"secret"}
+
+	f := newService(&config)
+	sent := new([]sentTx)
+	f.send = func(to common.Address, value *big.Int, gas uint64, data []byte) (common.Hash, error) {
+		*sent = append(*sent, sentTx{to, value, data})
+		return common.BytesToHash([]byte{byte(len(*sent))}), nil
+	}
+	f.captcha = func(response, remoteIP string) error {
+		if response != "human" {
+			return errors.New("robot")
+		}
+		return nil
+	}
+	return f, sent
+}
+
+func post(f *Service, remote, apiKey string, req *request) (int, *response) {
+	body, _ := json.Marshal(req)
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	r.RemoteAddr = remote
+	if apiKey != "" {
+		r.Header.Set("X-Api-Key", apiKey)
+	}
+	w := httptest.NewRecorder()
+	f.ServeHTTP(w, r)
+
+	res := new(response)
+	json.NewDecoder(w.Body).Decode(res)
+	return w.Code, res
+}
+
+func TestFaucetAuthorization(t *testing.T) {
+	f, sent := newTestFaucet(t)
+	req := &request{Kind: KindWan, Address: common.HexToAddress("0x01")}
+
+	if code, _ := post(f, "1.2.3.4:5", "", req); code != http.StatusForbidden {
+		t.Errorf("request without captcha: have status %d, want %d", code, http.StatusForbidden)
+	}
+	if code, _ := post(f, "1.2.3.4:5", "wrong", req); code != http.StatusForbidden {
+		t.Errorf("request with invalid API key: have status %d, want %d", code, http.StatusForbidden)
+	}
+	req.Captcha = "human"
+	if code, res := post(f, "1.2.3.4:5", "", req); code != http.StatusOK || res.Tx == (common.Hash{}) {
+		t.Fatalf("captcha request failed: status %d, error %q", code, res.Error)
+	}
+	if len(*sent) != 1 || (*sent)[0].to != req.Address || (*sent)[0].value.Cmp(DefaultConfig.Amount) != 0 {
+		t.Errorf("sent transaction mismatch: %v", *sent)
+	}
+}
+
+func TestFaucetRateLimit(t *testing.T) {
+	f, sent := newTestFaucet(t)
+	now := time.Unix(0, 0)
+	f.limiter.now = func() time.Time { return now }
+
+	first := &request{Kind: KindWan, Address: common.HexToAddress("0x01"), Captcha: "human"}
+	second := &request{Kind: KindWan, Address: common.HexToAddress("0x02"), Captcha: "human"}
+
+	if code, _ := post(f, "1.2.3.4:5", "", first); code != http.StatusOK {
+		t.Fatalf("first request: have status %d, want %d", code, http.StatusOK)
+	}
+	// Neither the client nor the recipient can be funded again within the period
+	if code, _ := post(f, "1.2.3.4:6", "", second); code != http.StatusTooManyRequests {
+		t.Errorf("same client: have status %d, want %d", code, http.StatusTooManyRequests)
+	}
+	if code, _ := post(f, "5.6.7.8:5", "", first); code != http.StatusTooManyRequests {
+		t.Errorf("same recipient: have status %d, want %d", code, http.StatusTooManyRequests)
+	}
+	// API key holders are limited per key rather than per IP
+	if code, _ := post(f, "1.2.3.4:5", "secret", second); code != http.StatusOK {
+		t.Errorf("API key request: have status %d, want %d", code, http.StatusOK)
+	}
+	now = now.Add(DefaultConfig.Period)
+	if code, _ := post(f, "1.2.3.4:5", "", first); code != http.StatusOK {
+		t.Errorf("request after period: have status %d, want %d", code, http.StatusOK)
+	}
+	if len(*sent) != 3 {
+		t.Errorf("sent transaction count mismatch: have %d, want 3", len(*sent))
+	}
+}
+
+func TestFaucetOTADeposits(t *testing.T) {
+	f, sent := newTestFaucet(t)
+
+	a, _ := crypto.GenerateKey()
+	b, _ := crypto.GenerateKey()
+	wAddr := keystore.GenerateWaddressFromPK(&a.PublicKey, &b.PublicKey)[:]
+
+	for i, kind := range []string{KindCoin, KindStamp} {
+		// Reset the limiter, as the recipient wan address is rate limited too
+		f.limiter = newLimiter(f.config.Period)
+		code, res := post(f, "1.2.3.4:5", "secret", &request{Kind: kind, WAddress: wAddr})
+		if code != http.StatusOK {
+			t.Fatalf("%s deposit failed: status %d, error %q", kind, code, res.Error)
+		}
+		if len(res.OTA) != common.WAddressLength || bytes.Equal(res.OTA, wAddr) {
+			t.Fatalf("%s deposit OTA invalid: %x", kind, res.OTA)
+		}
+		var (
+			to   common.Address
+			data []byte
+		)
+		if kind == KindCoin {
+			to, data, _ = vm.PackBuyCoinNote(res.OTA, f.config.CoinValue)
+		} else {
+			to, data, _ = vm.PackBuyStamp(res.OTA, f.config.StampValue)
+		}
+		if tx := (*sent)[i]; tx.to != to || !bytes.Equal(tx.data, data) {
+			t.Errorf("%s deposit transaction mismatch: have %x/%s, want %x/%s", kind, tx.to, hexutil.Encode(tx.data), to, hexutil.Encode(data))
+		}
+	}
+	if code, _ := post(f, "1.2.3.4:5", "secret", &request{Kind: KindCoin, WAddress: wAddr[1:]}); code != http.StatusBadRequest {
+		t.Errorf("invalid wan address: have status %d, want %d", code, http.StatusBadRequest)
+	}
+}
@@ -21,7 +21,9 @@
 package debug
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"os/user"
@@ -33,6 +35,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/wanchain/go-wanchain/common/hexutil"
 	"github.com/wanchain/go-wanchain/log"
 )
 
@@ -169,6 +172,71 @@ func (*HandlerT) WriteMemProfile(file string) error {
 	return writeProfile("heap", file)
 }
 
+// MutexProfile turns on mutex profiling for nsec seconds and writes profile
+// data to file. It uses a profile fraction of 1 for most accurate information.
+// If a different fraction is desired, set the fraction and write the profile
+// manually.
+func (*HandlerT) MutexProfile(file string, nsec uint) error {
+	runtime.SetMutexProfileFraction(1)
+	time.Sleep(time.Duration(nsec) * time.Second)
+	defer runtime.SetMutexProfileFraction(0)
+	return writeProfile("mutex", file)
+}
+
+// SetMutexProfileFraction sets the rate of mutex contention events reported,
+// on average 1/rate of them. rate 0 disables mutex profiling.
+func (*HandlerT) SetMutexProfileFraction(rate int) {
+	runtime.SetMutexProfileFraction(rate)
+}
+
+// WriteMutexProfile writes a mutex contention profile to the given file.
+func (*HandlerT) WriteMutexProfile(file string) error {
+	return writeProfile("mutex", file)
+}
+
+// WriteGoroutineProfile writes the stacks of all goroutines to the given file,
+// as a profile.
+func (*HandlerT) WriteGoroutineProfile(file string) error {
+	return writeProfile("goroutine", file)
+}
+
+// CpuProfileData turns on CPU profiling for nsec seconds and returns the
+// profile data, for the profiles of remote nodes to be collected over RPC.
+func (h *HandlerT) CpuProfileData(nsec uint) (hexutil.Bytes, error) {
+	h.mu.Lock()
+	if h.cpuW != nil {
+		h.mu.Unlock()
+		return nil, errors.New("CPU profiling already in progress")
+	}
+	buf := new(bytes.Buffer)
+	err := pprof.StartCPUProfile(buf)
+	h.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	log.Info("CPU profiling started", "dump", "rpc")
+	time.Sleep(time.Duration(nsec) * time.Second)
+	pprof.StopCPUProfile()
+	log.Info("Done collecting CPU profile", "size", buf.Len())
+
+	return buf.Bytes(), nil
+}
+
+// ProfileData returns the data of the named profile, one of heap, block,
+// mutex, goroutine or threadcreate, for the profiles of remote nodes to be
+// collected over RPC.
+func (*HandlerT) ProfileData(name string) (hexutil.Bytes, error) {
+	p := pprof.Lookup(name)
+	if p == nil {
+		return nil, fmt.Errorf("unknown profile %q", name)
+	}
+	buf := new(bytes.Buffer)
+	if err := p.WriteTo(buf, 0); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
 // Stacks returns a printed representation of the stacks of all goroutines.
 func (*HandlerT) Stacks() string {
 	buf := make([]byte, 1024*1024)
@@ -32,6 +32,7 @@ import (
 	"github.com/syndtr/goleveldb/leveldb/util"
 	"github.com/wanchain/go-wanchain/accounts"
 	"github.com/wanchain/go-wanchain/accounts/keystore"
+	"github.com/wanchain/go-wanchain/accounts/typeddata"
 	"github.com/wanchain/go-wanchain/common"
 	"github.com/wanchain/go-wanchain/common/hexutil"
 	"github.com/wanchain/go-wanchain/common/math"
@@ -65,6 +66,8 @@ var (
 	ErrReqTooManyOTAMix                 = errors.New("Require too many OTA mix address")
 	ErrInvalidOTAMixNum                 = errors.New("Invalid required OTA mix address number")
 	ErrInvalidInput                     = errors.New("Invalid input")
+	ErrSignerAuditDisabled              = errors.New("Signer audit log disabled")
+	ErrAccountExists                    = errors.New("Account already exists in a keystore")
 )
 
 // PublicEthereumAPI provides an API to access Ethereum related information.
@@ -91,6 +94,16 @@ func (s *PublicEthereumAPI) GasPrice(ctx context.Context) (*big.Int, error) {
 	return s.b.SuggestPrice(ctx)
 }
 
+// MaxPriorityFeePerGas returns a suggestion for the tip paid to the miner. The
+// chain has no base fee, the whole gas price goes to the miner.
+func (s *PublicEthereumAPI) MaxPriorityFeePerGas(ctx context.Context) (*hexutil.Big, error) {
+	price, err := s.b.SuggestPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return (*hexutil.Big)(price), nil
+}
+
 // ProtocolVersion returns the current Ethereum protocol version this node supports
 func (s *PublicEthereumAPI) ProtocolVersion() hexutil.Uint {
 	return hexutil.Uint(s.b.ProtocolVersion())
@@ -230,6 +243,7 @@ type PrivateAccountAPI struct {
 	am        *accounts.Manager
 	nonceLock *AddrLocker
 	b         Backend
+	joint     *jointSigner
 }
 
 // NewPrivateAccountAPI create a new PrivateAccountAPI.
@@ -238,6 +252,7 @@ func NewPrivateAccountAPI(b Backend, nonceLock *AddrLocker) *PrivateAccountAPI {
 		am:        b.AccountManager(),
 		nonceLock: nonceLock,
 		b:         b,
+		joint:     newJointSigner(),
 	}
 }
 
@@ -322,9 +337,16 @@ func (s *PrivateAccountAPI) NewAccount(password string) (common.Address, error)
 	return common.Address{}, err
 }
 
-// fetchKeystore retrives the encrypted keystore from the account manager.
+// fetchKeystore retrives the encrypted keystore new keys are created in from
+// the account manager.
 func fetchKeystore(am *accounts.Manager) *keystore.KeyStore {
-	return am.Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+	return keystore.PrimaryKeyStore(am)
+}
+
+// fetchAccountKeystore retrieves the keystore holding addr, defaulting to the
+// primary keystore if none does.
+func fetchAccountKeystore(am *accounts.Manager, addr common.Address) *keystore.KeyStore {
+	return keystore.AccountKeyStore(am, addr)
 }
 
 // ImportRawKey stores the given hex encoded ECDSA key into the key directory,
@@ -357,6 +379,11 @@ func (s *PrivateAccountAPI) ImportRawKey(privkey0, privkey1 string, password str
 		return common.Address{}, nil
 	}
 
+	// Don't duplicate the keys of the other keystores, read-only ones included
+	addr := crypto.PubkeyToAddress(sk0.PublicKey)
+	if ks := fetchAccountKeystore(s.am, addr); ks.HasAddress(addr) {
+		return common.Address{}, ErrAccountExists
+	}
 	acc, err := fetchKeystore(s.am).ImportECDSA(sk0, sk1, password)
 	return acc.Address, err
 }
@@ -374,12 +401,12 @@ func (s *PrivateAccountAPI) UnlockAccount(addr common.Address, password string,
 	} else {
 		d = time.Duration(*duration) * time.Second
 	}
-	err := fetchKeystore(s.am).TimedUnlock(accounts.Account{Address: addr}, password, d)
+	err := fetchAccountKeystore(s.am, addr).TimedUnlock(accounts.Account{Address: addr}, password, d)
 	return err == nil, err
 }
 
 func (s *PrivateAccountAPI) UpdateAccount(addr common.Address, oldPassword string, newPassword string) error {
-	keystore := fetchKeystore(s.am)
+	keystore := fetchAccountKeystore(s.am, addr)
 	if keystore == nil {
 		return errors.New("invalid keystore!")
 	}
@@ -388,9 +415,28 @@ func (s *PrivateAccountAPI) UpdateAccount(addr common.Address, oldPassword strin
 	return keystore.Update(account, oldPassword, newPassword)
 }
 
+// AuditLog exports up to count entries of the signer audit log, starting at
+// sequence number from. All the remaining entries are exported if count is 0.
+func (s *PrivateAccountAPI) AuditLog(from hexutil.Uint64, count hexutil.Uint64) ([]*keystore.AuditEntry, error) {
+	auditLog := fetchKeystore(s.am).AuditLog()
+	if auditLog == nil {
+		return nil, ErrSignerAuditDisabled
+	}
+	return auditLog.Export(uint64(from), uint64(count))
+}
+
+// VerifyAuditLog checks the hash chain of the signer audit log.
+func (s *PrivateAccountAPI) VerifyAuditLog() (*keystore.AuditVerification, error) {
+	auditLog := fetchKeystore(s.am).AuditLog()
+	if auditLog == nil {
+		return nil, ErrSignerAuditDisabled
+	}
+	return auditLog.Verify()
+}
+
 // LockAccount will lock the account associated with the given address when it's unlocked.
 func (s *PrivateAccountAPI) LockAccount(addr common.Address) bool {
-	return fetchKeystore(s.am).Lock(addr) == nil
+	return fetchAccountKeystore(s.am, addr).Lock(addr) == nil
 }
 
 // SendTransaction will create a transaction from the given arguments and
@@ -608,6 +654,42 @@ func (s *PrivateAccountAPI) Sign(ctx context.Context, data hexutil.Bytes, addr c
 	return signature, nil
 }
 
+// typedDataHash validates the typed data, ensures its domain is bound to the
+// chain of the node and returns the EIP-712 hash to sign.
+func typedDataHash(b Backend, data *typeddata.TypedData) ([]byte, error) {
+	if err := data.CheckChain(b.ChainConfig().ChainId); err != nil {
+		return nil, err
+	}
+	hash, err := data.Hash()
+	if err != nil {
+		return nil, err
+	}
+	return hash.Bytes(), nil
+}
+
+// SignTypedData calculates an ECDSA signature of the EIP-712 hash of the typed
+// data, whose domain must be bound to the chain id of the node.
+//
+// The key used to calculate the signature is decrypted with the given password.
+func (s *PrivateAccountAPI) SignTypedData(ctx context.Context, data typeddata.TypedData, addr common.Address, passwd string) (hexutil.Bytes, error) {
+	hash, err := typedDataHash(s.b, &data)
+	if err != nil {
+		return nil, err
+	}
+	account := accounts.Account{Address: addr}
+
+	wallet, err := s.b.AccountManager().Find(account)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := wallet.SignHashWithPassphrase(account, passwd, hash)
+	if err != nil {
+		return nil, err
+	}
+	signature[64] += 27 // Transform V from 0/1 to 27/28 according to the yellow paper
+	return signature, nil
+}
+
 // EcRecover returns the address for the account that was used to create the signature.
 // Note, this function is compatible with eth_sign and personal_sign. As such it recovers
 // the address of:
@@ -940,6 +1022,8 @@ type StructLogRes struct {
 	Stack   []string          `json:"stack"`
 	Memory  []string          `json:"memory"`
 	Storage map[string]string `json:"storage"`
+
+	DecodedStorage map[string]*vm.DecodedSlot `json:"decodedStorage,omitempty"`
 }
 
 // formatLogs formats EVM returned structured logs for json output
@@ -967,6 +1051,13 @@ func FormatLogs(structLogs []vm.StructLog) []StructLogRes {
 
 		for i, storageValue := range trace.Storage {
 			formattedStructLogs[index].Storage[fmt.Sprintf("%x", i)] = fmt.Sprintf("%x", storageValue)
+
+			if decoded := vm.StorageLayouts.DecodeSlot(trace.Contract, i, storageValue[:]); decoded != nil {
+				if formattedStructLogs[index].DecodedStorage == nil {
+					formattedStructLogs[index].DecodedStorage = make(map[string]*vm.DecodedSlot)
+				}
+				formattedStructLogs[index].DecodedStorage[fmt.Sprintf("%x", i)] = decoded
+			}
 		}
 	}
 	return formattedStructLogs
@@ -997,6 +1088,9 @@ func (s *PublicBlockChainAPI) rpcOutputBlock(b *types.Block, inclTx bool, fullTx
 		"transactionsRoot": head.TxHash,
 		"receiptsRoot":     head.ReceiptHash,
 	}
+	if len(head.KeyImageAcc) > 0 {
+		fields["keyImageAcc"] = head.KeyImageAcc[0]
+	}
 
 	if inclTx {
 		formatTx := func(tx *types.Transaction) (interface{}, error) {
@@ -1257,6 +1351,15 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(hash common.Hash) (map[
 	if receipt.ContractAddress != (common.Address{}) {
 		fields["contractAddress"] = receipt.ContractAddress
 	}
+	// Privacy consumption is only recorded from the privacy receipt fork on
+	if receipt.Privacy != nil {
+		keyImages := make([]hexutil.Bytes, len(receipt.Privacy.KeyImages))
+		for i, keyImage := range receipt.Privacy.KeyImages {
+			keyImages[i] = keyImage
+		}
+		fields["stampUsed"] = (*hexutil.Big)(receipt.Privacy.StampUsed)
+		fields["keyImages"] = keyImages
+	}
 	return fields, nil
 }
 
@@ -1382,38 +1485,52 @@ func (s *PublicTransactionPoolAPI) SendTransaction(ctx context.Context, args Sen
 	return submitTransaction(ctx, s.b, signed)
 }
 
-func (s *PublicTransactionPoolAPI) GetOTAMixSet(ctx context.Context, otaAddr string, setLen int) ([]string, error) {
+// otaMixSetAX validates the parameters of an OTA mix set request, returning
+// the AX of the OTA address.
+func otaMixSetAX(otaAddr string, setLen int) ([]byte, error) {
 	if setLen <= 0 {
-		return []string{}, ErrInvalidOTAMixNum
+		return nil, ErrInvalidOTAMixNum
 	}
 
 	if uint64(setLen) > params.GetOTAMixSetMaxSize {
-		return []string{}, ErrReqTooManyOTAMix
+		return nil, ErrReqTooManyOTAMix
 	}
 
 	if !hexutil.Has0xPrefix(otaAddr) {
-		return []string{}, ErrInvalidOTAAddr
+		return nil, ErrInvalidOTAAddr
 	}
 
 	orgOtaAddr := common.FromHex(otaAddr)
 	if len(orgOtaAddr) < common.HashLength {
-		return []string{}, ErrInvalidOTAAddr
-	}
-
-	state, _, err := s.b.StateAndHeaderByNumber(ctx, rpc.BlockNumber(-1))
-	if state == nil || err != nil {
-		return nil, err
+		return nil, ErrInvalidOTAAddr
 	}
 
 	otaAX := orgOtaAddr[:common.HashLength]
 	if len(orgOtaAddr) == common.WAddressLength {
 		otaAX, _ = vm.GetAXFromWanAddr(orgOtaAddr)
 	}
+	return otaAX, nil
+}
+
+func (s *PublicTransactionPoolAPI) GetOTAMixSet(ctx context.Context, otaAddr string, setLen int) ([]string, error) {
+	otaAX, err := otaMixSetAX(otaAddr, setLen)
+	if err != nil {
+		return []string{}, err
+	}
+
+	state, header, err := s.b.StateAndHeaderByNumber(ctx, rpc.BlockNumber(-1))
+	if state == nil || err != nil {
+		return nil, err
+	}
 
-	otaByteSet, _, err := vm.GetOTASet(state, otaAX, setLen)
+	trace := otaSetTraces.trace()
+	otaByteSet, _, err := vm.GetOTASetTraced(state, otaAX, setLen, trace)
 	if err != nil {
 		return nil, err
 	}
+	if trace != nil {
+		otaSetTraces.record(trace, header.Number.Uint64(), otaByteSet)
+	}
 
 	ret := make([]string, 0, setLen)
 	for _, otaByte := range otaByteSet {
@@ -1475,6 +1592,44 @@ func (s *PublicTransactionPoolAPI) ComputeOTAPPKeys(ctx context.Context, address
 
 }
 
+// SignWithOTA calculates an ECDSA signature for:
+// keccack256("\x19Ethereum Signed Message:\n" + len(message) + message)
+// with the private key of an OTA received by the account addr, proving the
+// control of the OTA without spending it. The V value will be 27 or 28.
+//
+// The account associated with addr must be unlocked.
+func (s *PublicTransactionPoolAPI) SignWithOTA(ctx context.Context, addr common.Address, ota string, data hexutil.Bytes) (hexutil.Bytes, error) {
+	otaBytes, err := hexutil.Decode(ota)
+	if err != nil || len(otaBytes) != common.WAddressLength {
+		return nil, ErrInvalidOTAAddr
+	}
+	account := accounts.Account{Address: addr}
+	signature, err := fetchAccountKeystore(s.b.AccountManager(), addr).SignHashWithOTA(account, otaBytes, signHash(data))
+	if err != nil {
+		return nil, err
+	}
+	signature[64] += 27 // Transform V from 0/1 to 27/28 according to the yellow paper
+	return signature, nil
+}
+
+// VerifyOTASignature reports whether sig, as produced by wan_signWithOTA, is a
+// signature of the message made with the private key of the OTA.
+func (s *PublicTransactionPoolAPI) VerifyOTASignature(ctx context.Context, ota string, data, sig hexutil.Bytes) (bool, error) {
+	otaBytes, err := hexutil.Decode(ota)
+	if err != nil || len(otaBytes) != common.WAddressLength {
+		return false, ErrInvalidOTAAddr
+	}
+	if len(sig) != 65 {
+		return false, fmt.Errorf("signature must be 65 bytes long")
+	}
+	if sig[64] != 27 && sig[64] != 28 {
+		return false, fmt.Errorf("invalid Ethereum signature (V is not 27 or 28)")
+	}
+	sig[64] -= 27 // Transform yellow paper V from 27/28 to 0/1
+
+	return keystore.VerifyOTASignature(otaBytes, signHash(data), sig)
+}
+
 // SendRawTransaction will add the signed transaction to the transaction pool.
 // The sender is responsible for signing the transaction and using the correct nonce.
 func (s *PublicTransactionPoolAPI) SendRawTransaction(ctx context.Context, encodedTx hexutil.Bytes) (common.Hash, error) {
@@ -1510,6 +1665,28 @@ func (s *PublicTransactionPoolAPI) Sign(addr common.Address, data hexutil.Bytes)
 	return signature, err
 }
 
+// SignTypedData calculates an ECDSA signature of the EIP-712 hash of the typed
+// data, whose domain must be bound to the chain id of the node.
+//
+// The account associated with addr must be unlocked.
+func (s *PublicTransactionPoolAPI) SignTypedData(addr common.Address, data typeddata.TypedData) (hexutil.Bytes, error) {
+	hash, err := typedDataHash(s.b, &data)
+	if err != nil {
+		return nil, err
+	}
+	account := accounts.Account{Address: addr}
+
+	wallet, err := s.b.AccountManager().Find(account)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := wallet.SignHash(account, hash)
+	if err == nil {
+		signature[64] += 27 // Transform V from 0/1 to 27/28 according to the yellow paper
+	}
+	return signature, err
+}
+
 // SignTransactionResult represents a RLP encoded signed transaction.
 type SignTransactionResult struct {
 	Raw hexutil.Bytes      `json:"raw"`
@@ -1700,6 +1877,47 @@ func (api *PrivateDebugAPI) SetHead(number hexutil.Uint64) {
 	api.b.SetHead(uint64(number))
 }
 
+// RecordOtaSets turns on or off the recording of the mix set samplings served
+// by wan_getOTAMixSet. Turning it off drops the recorded samplings.
+func (api *PrivateDebugAPI) RecordOtaSets(enable bool) {
+	otaSetTraces.enable(enable)
+}
+
+// OtaSetTraces returns the recorded mix set samplings served on the state of
+// the given block, at most the latest 1024 samplings being kept.
+func (api *PrivateDebugAPI) OtaSetTraces(ctx context.Context, blockNr rpc.BlockNumber) ([]*OTASetTraceResult, error) {
+	header, err := api.b.HeaderByNumber(ctx, blockNr)
+	if header == nil || err != nil {
+		return nil, err
+	}
+	return otaSetTraces.at(header.Number.Uint64()), nil
+}
+
+// TraceOtaSet runs the OTA mix set sampler of wan_getOTAMixSet on the state of
+// the given block with a random source seeded with seed, or a fresh seed if
+// nil, and returns the selected set along with the seed, the storage iteration
+// order and the selections made. Replaying a recorded sampling with its seed
+// on its block yields the same set.
+func (api *PrivateDebugAPI) TraceOtaSet(ctx context.Context, otaAddr string, setLen int, blockNr rpc.BlockNumber, seed *int64) (*OTASetTraceResult, error) {
+	otaAX, err := otaMixSetAX(otaAddr, setLen)
+	if err != nil {
+		return nil, err
+	}
+	state, header, err := api.b.StateAndHeaderByNumber(ctx, blockNr)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	trace := &vm.OTASetTrace{Seed: time.Now().UnixNano()}
+	if seed != nil {
+		trace.Seed = *seed
+	}
+	otaByteSet, _, err := vm.GetOTASetTraced(state, otaAX, setLen, trace)
+	if err != nil {
+		return nil, err
+	}
+	return newOTASetTraceResult(trace, header.Number.Uint64(), otaByteSet), nil
+}
+
 // PublicNetAPI offers network related RPC methods
 type PublicNetAPI struct {
 	net            *p2p.Server
@@ -0,0 +1,98 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package ethapi
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/wanchain/go-wanchain/common/hexutil"
+	"github.com/wanchain/go-wanchain/core"
+	"github.com/wanchain/go-wanchain/core/types"
+)
+
+// Congestion levels, by the number of blocks needed to include the pending
+// transactions.
+const (
+	congestionLow      = "low"      // Pending transactions fit in the next block
+	congestionModerate = "moderate" // Pending transactions fit in a few blocks
+	congestionHigh     = "high"
+
+	moderateBacklog = 1.0 // Blocks of pending gas above which the congestion is moderate
+	highBacklog     = 3.0 // Blocks of pending gas above which the congestion is high
+)
+
+// InclusionDelay is the distribution of the recent delays, in seconds, from
+// the arrival of transactions in the pool to their inclusion in the chain.
+type InclusionDelay struct {
+	Samples int     `json:"samples"`
+	P50     float64 `json:"p50"`
+	P90     float64 `json:"p90"`
+	P99     float64 `json:"p99"`
+}
+
+func newInclusionDelay(sorted []time.Duration) *InclusionDelay {
+	return &InclusionDelay{
+		Samples: len(sorted),
+		P50:     core.DelayPercentile(sorted, 50).Seconds(),
+		P90:     core.DelayPercentile(sorted, 90).Seconds(),
+		P99:     core.DelayPercentile(sorted, 99).Seconds(),
+	}
+}
+
+// FeeSuggestion is a gas price suggestion along with the congestion of the
+// network, for wallets to tell the expected confirmation time.
+type FeeSuggestion struct {
+	GasPrice              *hexutil.Big    `json:"gasPrice"`
+	Congestion            string          `json:"congestion"`
+	Backlog               float64         `json:"backlog"` // Blocks of gas needed to include the pending transactions
+	Pending               int             `json:"pending"`
+	PendingPrivacy        int             `json:"pendingPrivacy"`
+	Queued                int             `json:"queued"`
+	InclusionDelay        *InclusionDelay `json:"inclusionDelay"`
+	PrivacyInclusionDelay *InclusionDelay `json:"privacyInclusionDelay"`
+}
+
+// FeeSuggestion returns a gas price suggestion along with the congestion of
+// the network: the depth of the pending pool and the recent inclusion delays,
+// tracked apart for the privacy transactions.
+func (s *PublicEthereumAPI) FeeSuggestion(ctx context.Context) (*FeeSuggestion, error) {
+	price, err := s.b.SuggestPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := s.b.GetPoolTransactions()
+	if err != nil {
+		return nil, err
+	}
+	_, queued := s.b.Stats()
+	normal, privacy := s.b.InclusionDelays()
+
+	suggestion := &FeeSuggestion{
+		GasPrice:              (*hexutil.Big)(price),
+		Pending:               len(pending),
+		Queued:                queued,
+		InclusionDelay:        newInclusionDelay(normal),
+		PrivacyInclusionDelay: newInclusionDelay(privacy),
+	}
+	gas := new(big.Int)
+	for _, tx := range pending {
+		gas.Add(gas, tx.Gas())
+		if !types.IsNormalTransaction(tx.Txtype()) {
+			suggestion.PendingPrivacy++
+		}
+	}
+	if limit := s.b.CurrentBlock().GasLimit(); limit.Sign() > 0 {
+		suggestion.Backlog, _ = new(big.Rat).SetFrac(gas, limit).Float64()
+	}
+	switch {
+	case suggestion.Backlog > highBacklog:
+		suggestion.Congestion = congestionHigh
+	case suggestion.Backlog > moderateBacklog:
+		suggestion.Congestion = congestionModerate
+	default:
+		suggestion.Congestion = congestionLow
+	}
+	return suggestion, nil
+}
@@ -0,0 +1,289 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package ethapi
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/wanchain/go-wanchain/accounts"
+	"github.com/wanchain/go-wanchain/accounts/keystore"
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/common/hexutil"
+	"github.com/wanchain/go-wanchain/crypto"
+)
+
+// Joint private accounts split the spend key of a wan address between two
+// parties: the joint wan address is (A1+A2, B1), so the private key of every
+// OTA it receives is hash([b1]R)+a1+a2 and can only be used if both parties
+// cooperate. Every party proves the possession of its spend key with
+// personal_jointWanAddressProof when the joint wan address is generated. The
+// ring signature of a spend is produced in three rounds:
+//
+//  1. both parties call personal_jointRingSignCommit and hand the resulting
+//     commitment to the coordinator, which hands all commitments back;
+//  2. both parties reveal their nonces with personal_jointRingSignReveal, and
+//     the coordinator calls personal_jointRingSignChallenge;
+//  3. both parties answer the challenge with personal_jointRingSignRespond,
+//     and the coordinator calls personal_jointRingSignComplete.
+//
+// The commitments of a session must never be reused: a party answers a
+// single challenge per commitment.
+
+var (
+	ErrUnknownJointSession = errors.New("unknown joint ring sign session")
+	ErrJointRingSignFail   = errors.New("joint ring signature doesn't verify")
+)
+
+// JointRingSignCommitment is the commitment of one party to the public part of
+// its share in a joint ring signature.
+type JointRingSignCommitment struct {
+	Session    common.Hash `json:"session"`
+	Commitment common.Hash `json:"commitment"`
+}
+
+// JointRingSignReveal is the public part of the share of one party in a joint
+// ring signature.
+type JointRingSignReveal struct {
+	KeyImage hexutil.Bytes `json:"keyImage"`
+	L        hexutil.Bytes `json:"l"`
+	R        hexutil.Bytes `json:"r"`
+}
+
+// JointRingSignChallenge is the challenge the parties of a joint ring
+// signature must answer.
+type JointRingSignChallenge struct {
+	Session   common.Hash  `json:"session"`
+	Challenge *hexutil.Big `json:"challenge"`
+}
+
+// jointSigner keeps track of the joint ring signatures in progress.
+type jointSigner struct {
+	mu         sync.Mutex
+	shares     map[common.Hash]*crypto.RingSignShare // Own shares awaiting a challenge
+	signatures map[common.Hash]*jointSignature       // Coordinated signatures awaiting responses
+}
+
+type jointSignature struct {
+	msg  []byte
+	sign *crypto.JointRingSign
+}
+
+func newJointSigner() *jointSigner {
+	return &jointSigner{
+		shares:     make(map[common.Hash]*crypto.RingSignShare),
+		signatures: make(map[common.Hash]*jointSignature),
+	}
+}
+
+func newJointSession() (common.Hash, error) {
+	var session common.Hash
+	if _, err := rand.Read(session[:]); err != nil {
+		return common.Hash{}, err
+	}
+	return session, nil
+}
+
+// JointWanAddressProof proves the possession of the spend key of the unlocked
+// account addr, for its wan address to be combined into a joint one.
+func (s *PrivateAccountAPI) JointWanAddressProof(ctx context.Context, addr common.Address) (hexutil.Bytes, error) {
+	account := accounts.Account{Address: addr}
+	ks := fetchAccountKeystore(s.am, addr)
+	wAddr, err := ks.GetWanAddress(account)
+	if err != nil {
+		return nil, err
+	}
+	A, _, err := keystore.GeneratePKPairFromWAddress(wAddr[:])
+	if err != nil {
+		return nil, ErrFailToGeneratePKPairFromWAddress
+	}
+	return ks.SignHash(account, crypto.JointKeyProofHash(A))
+}
+
+// GenerateJointWanAddress combines the wan addresses of the parties of a joint
+// private account, each with the proof of possession of its spend key. The
+// view key of the first wan address is used to detect the OTAs of the joint
+// account.
+func (s *PublicTransactionPoolAPI) GenerateJointWanAddress(ctx context.Context, wAddrs []string, proofs []hexutil.Bytes) (string, error) {
+	if len(wAddrs) < 2 {
+		return "", ErrInvalidWAddress
+	}
+	if len(proofs) != len(wAddrs) {
+		return "", crypto.ErrInvalidJointKeyProof
+	}
+	spendKeys := make([]*ecdsa.PublicKey, 0, len(wAddrs))
+	spendProofs := make([][]byte, 0, len(proofs))
+	var viewKey *ecdsa.PublicKey
+	for i, wAddr := range wAddrs {
+		wAddrBytes, err := hexutil.Decode(wAddr)
+		if err != nil || len(wAddrBytes) != common.WAddressLength {
+			return "", ErrInvalidWAddress
+		}
+		A, B, err := keystore.GeneratePKPairFromWAddress(wAddrBytes)
+		if err != nil {
+			return "", ErrFailToGeneratePKPairFromWAddress
+		}
+		if viewKey == nil {
+			viewKey = B
+		}
+		spendKeys = append(spendKeys, A)
+		spendProofs = append(spendProofs, proofs[i])
+	}
+	A, err := crypto.CombinePublicKeys(spendKeys, spendProofs)
+	if err != nil {
+		return "", err
+	}
+	joint := keystore.GenerateWaddressFromPK(A, viewKey)
+	return hexutil.Encode(joint[:]), nil
+}
+
+// JointRingSignCommit starts the participation of the unlocked account addr in
+// the joint ring signature of ota, returning the commitment to its nonces. The
+// holder of the view key of the joint wan address must set viewKey.
+func (s *PrivateAccountAPI) JointRingSignCommit(ctx context.Context, addr common.Address, ota string, viewKey bool) (*JointRingSignCommitment, error) {
+	otaBytes, err := hexutil.Decode(ota)
+	if err != nil || len(otaBytes) != common.WAddressLength {
+		return nil, ErrInvalidOTAAddr
+	}
+	share, err := fetchAccountKeystore(s.am, addr).NewJointRingSignShare(accounts.Account{Address: addr}, otaBytes, viewKey)
+	if err != nil {
+		return nil, err
+	}
+	session, err := newJointSession()
+	if err != nil {
+		return nil, err
+	}
+
+	s.joint.mu.Lock()
+	s.joint.shares[session] = share
+	s.joint.mu.Unlock()
+
+	return &JointRingSignCommitment{Session: session, Commitment: share.Commitment()}, nil
+}
+
+// JointRingSignReveal reveals the nonces committed in session, once given the
+// commitments of all parties.
+func (s *PrivateAccountAPI) JointRingSignReveal(ctx context.Context, session common.Hash, commitments []common.Hash) (*JointRingSignReveal, error) {
+	s.joint.mu.Lock()
+	defer s.joint.mu.Unlock()
+
+	share, ok := s.joint.shares[session]
+	if !ok {
+		return nil, ErrUnknownJointSession
+	}
+	if err := share.Reveal(commitments); err != nil {
+		return nil, err
+	}
+	return &JointRingSignReveal{
+		KeyImage: crypto.FromECDSAPub(share.KeyImage),
+		L:        crypto.FromECDSAPub(share.L),
+		R:        crypto.FromECDSAPub(share.R),
+	}, nil
+}
+
+// JointRingSignChallenge combines the revealed nonces of all parties, checked
+// against their commitments, into the ring signature of hashMsg by ota, mixed
+// with the OTAs mixWanAddresses ('+' separated), and returns the challenge the
+// parties must answer.
+func (s *PrivateAccountAPI) JointRingSignChallenge(ctx context.Context, hashMsg hexutil.Bytes, ota string, mixWanAddresses string, commitments []common.Hash, reveals []JointRingSignReveal) (*JointRingSignChallenge, error) {
+	if len(reveals) == 0 || len(reveals) != len(commitments) {
+		return nil, crypto.ErrInvalidJointRingSign
+	}
+	otaBytes, err := hexutil.Decode(ota)
+	if err != nil || len(otaBytes) != common.WAddressLength {
+		return nil, ErrInvalidOTAAddr
+	}
+	otaPub, _, err := keystore.GeneratePKPairFromWAddress(otaBytes)
+	if err != nil {
+		return nil, ErrInvalidOTAAddr
+	}
+
+	publicKeys := []*ecdsa.PublicKey{otaPub}
+	for _, strWanAddr := range strings.Split(mixWanAddresses, "+") {
+		pubBytes, err := hexutil.Decode(strWanAddr)
+		if err != nil || len(pubBytes) != common.WAddressLength {
+			return nil, ErrInvalidWAddress
+		}
+		publicKeyA, _, err := keystore.GeneratePKPairFromWAddress(pubBytes)
+		if err != nil {
+			return nil, ErrFailToGeneratePKPairFromWAddress
+		}
+		publicKeys = append(publicKeys, publicKeyA)
+	}
+
+	var keyImages, Ls, Rs []*ecdsa.PublicKey
+	for _, reveal := range reveals {
+		keyImages = append(keyImages, crypto.ToECDSAPub(reveal.KeyImage))
+		Ls = append(Ls, crypto.ToECDSAPub(reveal.L))
+		Rs = append(Rs, crypto.ToECDSAPub(reveal.R))
+	}
+	sign, err := crypto.PrepareJointRingSign(hashMsg, publicKeys, commitments, keyImages, Ls, Rs)
+	if err != nil {
+		return nil, err
+	}
+	session, err := newJointSession()
+	if err != nil {
+		return nil, err
+	}
+
+	s.joint.mu.Lock()
+	s.joint.signatures[session] = &jointSignature{msg: hashMsg, sign: sign}
+	s.joint.mu.Unlock()
+
+	return &JointRingSignChallenge{Session: session, Challenge: (*hexutil.Big)(sign.Challenge)}, nil
+}
+
+// JointRingSignRespond answers the challenge of a joint ring signature with the
+// share committed in session. The session is closed afterwards.
+func (s *PrivateAccountAPI) JointRingSignRespond(ctx context.Context, session common.Hash, challenge *hexutil.Big) (*hexutil.Big, error) {
+	if challenge == nil {
+		return nil, crypto.ErrInvalidJointRingSign
+	}
+	s.joint.mu.Lock()
+	share, ok := s.joint.shares[session]
+	delete(s.joint.shares, session)
+	s.joint.mu.Unlock()
+
+	if !ok {
+		return nil, ErrUnknownJointSession
+	}
+	response, err := share.Respond(challenge.ToInt())
+	if err != nil {
+		return nil, err
+	}
+	return (*hexutil.Big)(response), nil
+}
+
+// JointRingSignComplete combines the responses of all parties into the ring
+// signature of the coordinated session, in the format of genRingSignData.
+func (s *PrivateAccountAPI) JointRingSignComplete(ctx context.Context, session common.Hash, responses []*hexutil.Big) (string, error) {
+	s.joint.mu.Lock()
+	signature, ok := s.joint.signatures[session]
+	delete(s.joint.signatures, session)
+	s.joint.mu.Unlock()
+
+	if !ok {
+		return "", ErrUnknownJointSession
+	}
+	return completeJointRingSign(signature, responses)
+}
+
+func completeJointRingSign(signature *jointSignature, responses []*hexutil.Big) (string, error) {
+	rs := make([]*big.Int, 0, len(responses))
+	for _, response := range responses {
+		rs = append(rs, response.ToInt())
+	}
+	pubs, image, w, q, err := signature.sign.Complete(rs)
+	if err != nil {
+		return "", err
+	}
+	if !crypto.VerifyRingSign(signature.msg, pubs, image, w, q) {
+		return "", ErrJointRingSignFail
+	}
+	return encodeRingSignOut(pubs, image, w, q)
+}
@@ -0,0 +1,43 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package ethapi
+
+import (
+	"context"
+	"errors"
+
+	"github.com/wanchain/go-wanchain/common/hexutil"
+	"github.com/wanchain/go-wanchain/core"
+	"github.com/wanchain/go-wanchain/rpc"
+)
+
+var ErrInvalidKeyImage = errors.New("invalid key image")
+
+// ProveKeyImageAbsence returns a merkle proof that the key image, the 65 byte
+// point carried by a ring signature, is not spent at the given block. The
+// proof is checked against the state root of a trusted header with
+// core.VerifyKeyImageAbsence, or with wan_checkKeyImageAbsence.
+func (s *PublicBlockChainAPI) ProveKeyImageAbsence(ctx context.Context, keyImage hexutil.Bytes, blockNr rpc.BlockNumber) (*core.KeyImageProof, error) {
+	if len(keyImage) != 65 {
+		return nil, ErrInvalidKeyImage
+	}
+	state, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	return core.ProveKeyImageAbsence(state, header.Root, keyImage)
+}
+
+// CheckKeyImageAbsence verifies a proof generated by wan_proveKeyImageAbsence,
+// returning whether it proves the key image unspent at its state root. The
+// root still has to be checked against a trusted header.
+func (s *PublicBlockChainAPI) CheckKeyImageAbsence(ctx context.Context, keyImage hexutil.Bytes, proof core.KeyImageProof) (bool, error) {
+	switch err := core.VerifyKeyImageAbsence(&proof, keyImage); err {
+	case nil:
+		return true, nil
+	case core.ErrKeyImageSpent:
+		return false, nil
+	default:
+		return false, err
+	}
+}
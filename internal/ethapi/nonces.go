@@ -0,0 +1,229 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package ethapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/common/hexutil"
+	"github.com/wanchain/go-wanchain/core"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/params"
+	"github.com/wanchain/go-wanchain/rlp"
+	"github.com/wanchain/go-wanchain/rpc"
+)
+
+// Reasons of the repair transactions.
+const (
+	repairGap   = "gap"   // Fills a missing nonce
+	repairStuck = "stuck" // Replaces an underpriced transaction
+)
+
+// ErrNonceGapsTooLarge is returned when repairing nonce gaps would take more
+// transactions than the pool queues for an account.
+var ErrNonceGapsTooLarge = errors.New("nonce gaps too large to fill")
+
+// NonceGap is a range of missing nonces, preventing the queued transactions
+// above it from being executed.
+type NonceGap struct {
+	From hexutil.Uint64 `json:"from"`
+	To   hexutil.Uint64 `json:"to"` // Inclusive
+}
+
+// StuckTransaction is a pending transaction priced below the suggested gas
+// price, which delays the transactions of higher nonces.
+type StuckTransaction struct {
+	Hash     common.Hash    `json:"hash"`
+	Nonce    hexutil.Uint64 `json:"nonce"`
+	GasPrice *hexutil.Big   `json:"gasPrice"`
+	Blocking int            `json:"blocking"` // Transactions of higher nonces waiting on it
+}
+
+// NonceReport is the analysis of the pooled transactions of an account.
+type NonceReport struct {
+	Address   common.Address      `json:"address"`
+	Nonce     hexutil.Uint64      `json:"nonce"`     // Nonce of the account in the latest state
+	NextNonce hexutil.Uint64      `json:"nextNonce"` // Nonce following the executable transactions
+	Pending   int                 `json:"pending"`
+	Queued    int                 `json:"queued"`
+	Gaps      []NonceGap          `json:"gaps"`
+	Stuck     []*StuckTransaction `json:"stuck"`
+}
+
+// analyzeNonces detects the nonce gaps and the underpriced transactions among
+// the pooled transactions of an account whose state nonce is given.
+func analyzeNonces(addr common.Address, nonce uint64, pending, queued types.Transactions, minPrice *big.Int) *NonceReport {
+	report := &NonceReport{
+		Address:   addr,
+		Nonce:     hexutil.Uint64(nonce),
+		NextNonce: hexutil.Uint64(nonce),
+		Pending:   len(pending),
+		Queued:    len(queued),
+		Gaps:      []NonceGap{},
+		Stuck:     []*StuckTransaction{},
+	}
+	txs := make(types.Transactions, 0, len(pending)+len(queued))
+	txs = append(append(txs, pending...), queued...)
+	sort.Sort(types.TxByNonce(txs))
+
+	next := nonce
+	for i, tx := range txs {
+		if tx.Nonce() < next {
+			continue
+		}
+		if tx.Nonce() > next {
+			report.Gaps = append(report.Gaps, NonceGap{From: hexutil.Uint64(next), To: hexutil.Uint64(tx.Nonce() - 1)})
+		} else if len(report.Gaps) == 0 {
+			report.NextNonce = hexutil.Uint64(tx.Nonce() + 1)
+		}
+		next = tx.Nonce() + 1
+
+		// Only the executable transactions can hold back the later ones
+		if len(report.Gaps) == 0 && i < len(txs)-1 && tx.GasPrice().Cmp(minPrice) < 0 {
+			report.Stuck = append(report.Stuck, &StuckTransaction{
+				Hash:     tx.Hash(),
+				Nonce:    hexutil.Uint64(tx.Nonce()),
+				GasPrice: (*hexutil.Big)(tx.GasPrice()),
+				Blocking: len(txs) - 1 - i,
+			})
+		}
+	}
+	return report
+}
+
+// nonceReport analyzes the pooled transactions of an account against the
+// latest state and the suggested gas price.
+func nonceReport(ctx context.Context, b Backend, addr common.Address) (*NonceReport, map[uint64]*types.Transaction, *big.Int, error) {
+	state, _, err := b.StateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if state == nil || err != nil {
+		return nil, nil, nil, err
+	}
+	nonce := state.GetNonce(addr)
+	if err := state.Error(); err != nil {
+		return nil, nil, nil, err
+	}
+	price, err := b.SuggestPrice(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	pending, queued := b.TxPoolContent()
+
+	pooled := make(map[uint64]*types.Transaction)
+	for _, tx := range pending[addr] {
+		pooled[tx.Nonce()] = tx
+	}
+	for _, tx := range queued[addr] {
+		pooled[tx.Nonce()] = tx
+	}
+	return analyzeNonces(addr, nonce, pending[addr], queued[addr], price), pooled, price, nil
+}
+
+// NonceGaps analyzes the pooled transactions of an account, reporting the nonce
+// gaps holding back its queued transactions and the underpriced transactions
+// delaying the later ones.
+func (s *PublicTxPoolAPI) NonceGaps(ctx context.Context, addr common.Address) (*NonceReport, error) {
+	report, _, _, err := nonceReport(ctx, s.b, addr)
+	return report, err
+}
+
+// RepairTransaction is a transaction signed to repair the nonces of an account.
+type RepairTransaction struct {
+	Reason string             `json:"reason"`
+	Nonce  hexutil.Uint64     `json:"nonce"`
+	Raw    hexutil.Bytes      `json:"raw"`
+	Tx     *types.Transaction `json:"tx"`
+	Sent   bool               `json:"sent"`
+}
+
+// RepairResult is the analysis of the pooled transactions of an account along
+// with the transactions repairing them.
+type RepairResult struct {
+	Report       *NonceReport         `json:"report"`
+	Transactions []*RepairTransaction `json:"transactions"`
+}
+
+// RepairNonces signs the transactions repairing the nonces of a managed account:
+// a zero value transfer to itself for every missing nonce, and a replacement
+// of every underpriced pending transaction, priced at gasPrice (defaulting to
+// the suggested price) and at least the price bump required by the pool. Privacy
+// transactions can't be re-signed, so they are replaced by transfers to self.
+// The transactions are submitted only if send is set.
+func (s *PublicTransactionPoolAPI) RepairNonces(ctx context.Context, addr common.Address, gasPrice *hexutil.Big, send bool) (*RepairResult, error) {
+	if send {
+		s.nonceLock.LockAddr(addr)
+		defer s.nonceLock.UnlockAddr(addr)
+	}
+	report, pooled, price, err := nonceReport(ctx, s.b, addr)
+	if err != nil {
+		return nil, err
+	}
+	if gasPrice != nil {
+		price = (*big.Int)(gasPrice)
+	}
+	if err := checkGapSpan(report.Gaps, core.DefaultTxPoolConfig.AccountQueue); err != nil {
+		return nil, err
+	}
+	// Replace the underpriced transactions first, they precede any gap
+	var unsigned []*types.Transaction
+	for _, stuck := range report.Stuck {
+		tx := pooled[uint64(stuck.Nonce)]
+		bumped := new(big.Int).Mul(tx.GasPrice(), big.NewInt(100+int64(core.DefaultTxPoolConfig.PriceBump)))
+		bumped.Div(bumped, big.NewInt(100))
+		if bumped.Cmp(price) < 0 {
+			bumped.Set(price)
+		}
+		switch {
+		case !types.IsNormalTransaction(tx.Txtype()):
+			unsigned = append(unsigned, types.NewTransaction(tx.Nonce(), addr, new(big.Int), new(big.Int).SetUint64(params.TxGas), bumped, nil))
+		case tx.To() == nil:
+			unsigned = append(unsigned, types.NewContractCreation(tx.Nonce(), tx.Value(), tx.Gas(), bumped, tx.Data()))
+		default:
+			unsigned = append(unsigned, types.NewTransaction(tx.Nonce(), *tx.To(), tx.Value(), tx.Gas(), bumped, tx.Data()))
+		}
+	}
+	for _, gap := range report.Gaps {
+		for nonce := uint64(gap.From); nonce <= uint64(gap.To); nonce++ {
+			unsigned = append(unsigned, types.NewTransaction(nonce, addr, new(big.Int), new(big.Int).SetUint64(params.TxGas), price, nil))
+		}
+	}
+	result := &RepairResult{Report: report, Transactions: make([]*RepairTransaction, 0, len(unsigned))}
+	for i, tx := range unsigned {
+		signed, err := s.sign(addr, tx)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := rlp.EncodeToBytes(signed)
+		if err != nil {
+			return nil, err
+		}
+		repair := &RepairTransaction{Reason: repairStuck, Nonce: hexutil.Uint64(tx.Nonce()), Raw: raw, Tx: signed}
+		if i >= len(report.Stuck) {
+			repair.Reason = repairGap
+		}
+		if send {
+			if err := s.b.SendTx(ctx, signed); err != nil {
+				return nil, fmt.Errorf("failed to send repair of nonce %d: %v", tx.Nonce(), err)
+			}
+			repair.Sent = true
+		}
+		result.Transactions = append(result.Transactions, repair)
+	}
+	return result, nil
+}
+
+// checkGapSpan fails if filling the gaps takes more than limit transactions.
+func checkGapSpan(gaps []NonceGap, limit uint64) error {
+	var span uint64
+	for _, gap := range gaps {
+		if n := uint64(gap.To - gap.From); n >= limit || span+n+1 > limit {
+			return ErrNonceGapsTooLarge
+		}
+		span += uint64(gap.To-gap.From) + 1
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package ethapi
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core/types"
+)
+
+func nonceTestTx(nonce uint64, price int64) *types.Transaction {
+	return types.NewTransaction(nonce, common.Address{}, big.NewInt(1), big.NewInt(21000), big.NewInt(price), nil)
+}
+
+// Tests that nonce gaps and the underpriced transactions holding back later
+// ones are detected.
+func TestAnalyzeNonces(t *testing.T) {
+	addr := common.Address{0x01}
+	pending := types.Transactions{nonceTestTx(5, 10), nonceTestTx(6, 1), nonceTestTx(7, 10)}
+	queued := types.Transactions{nonceTestTx(10, 1), nonceTestTx(9, 10), nonceTestTx(13, 10)}
+
+	report := analyzeNonces(addr, 5, pending, queued, big.NewInt(5))
+	if report.NextNonce != 8 || report.Pending != 3 || report.Queued != 3 {
+		t.Errorf("report mismatch: next %d, pending %d, queued %d", report.NextNonce, report.Pending, report.Queued)
+	}
+	if len(report.Gaps) != 2 || report.Gaps[0] != (NonceGap{8, 8}) || report.Gaps[1] != (NonceGap{11, 12}) {
+		t.Errorf("gaps mismatch: %v", report.Gaps)
+	}
+	if len(report.Stuck) != 1 || report.Stuck[0].Nonce != 6 || report.Stuck[0].Blocking != 4 {
+		t.Fatalf("stuck transactions mismatch: %v", report.Stuck)
+	}
+	// The last transaction holds back nothing, even if underpriced
+	report = analyzeNonces(addr, 5, types.Transactions{nonceTestTx(5, 1)}, nil, big.NewInt(5))
+	if len(report.Gaps) != 0 || len(report.Stuck) != 0 || report.NextNonce != 6 {
+		t.Errorf("report mismatch: %+v", report)
+	}
+}
+
+// Tests that repairing gaps is refused above the limit, huge gaps included.
+func TestCheckGapSpan(t *testing.T) {
+	tests := []struct {
+		gaps []NonceGap
+		err  error
+	}{
+		{nil, nil},
+		{[]NonceGap{{8, 8}, {11, 12}}, nil},
+		{[]NonceGap{{0, 63}}, nil},
+		{[]NonceGap{{0, 64}}, ErrNonceGapsTooLarge},
+		{[]NonceGap{{0, 31}, {40, 72}}, ErrNonceGapsTooLarge},
+		{[]NonceGap{{0, math.MaxUint64}}, ErrNonceGapsTooLarge},
+	}
+	for i, tt := range tests {
+		if err := checkGapSpan(tt.gaps, 64); err != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+}
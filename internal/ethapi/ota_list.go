@@ -0,0 +1,90 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package ethapi
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/common/hexutil"
+	"github.com/wanchain/go-wanchain/core/state"
+	"github.com/wanchain/go-wanchain/core/vm"
+	"github.com/wanchain/go-wanchain/rpc"
+)
+
+const (
+	defaultOTAPageSize = 100  // OTAs listed per page unless requested otherwise
+	maxOTAPageSize     = 1000 // Maximum number of OTAs listed per page
+)
+
+var (
+	ErrUnknownDenomination = errors.New("unknown OTA denomination")
+	ErrInvalidOTACursor    = errors.New("invalid OTA cursor")
+)
+
+// DenominationOTAs is a page of the OTAs of a denomination.
+type DenominationOTAs struct {
+	Denomination *hexutil.Big    `json:"denomination"`
+	OTAs         []hexutil.Bytes `json:"otas"` // OTA wan addresses, in storage trie order
+	Next         hexutil.Bytes   `json:"next"` // Cursor of the next page, null on the last page
+}
+
+// ListDenominationOTAs lists the OTAs of a coin or stamp denomination at the
+// given block, the anonymity set the ring signatures of the denomination draw
+// their mix sets from. The OTAs are listed by pages of up to limit OTAs in
+// storage trie order, starting from the cursor returned with the previous
+// page, or from the first one without.
+func (s *PublicBlockChainAPI) ListDenominationOTAs(ctx context.Context, denomination hexutil.Big, blockNr rpc.BlockNumber, cursor *hexutil.Bytes, limit *int) (*DenominationOTAs, error) {
+	if !isOTADenomination((*big.Int)(&denomination)) {
+		return nil, ErrUnknownDenomination
+	}
+	var start []byte
+	if cursor != nil {
+		if len(*cursor) != common.HashLength {
+			return nil, ErrInvalidOTACursor
+		}
+		start = *cursor
+	}
+	size := defaultOTAPageSize
+	if limit != nil {
+		size = *limit
+	}
+	if size < 1 || size > maxOTAPageSize {
+		size = maxOTAPageSize
+	}
+	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	return listOTAs(state, (*big.Int)(&denomination), start, size)
+}
+
+// listOTAs lists up to size OTAs of a denomination, from the start cursor on.
+func listOTAs(statedb *state.StateDB, denomination *big.Int, start []byte, size int) (*DenominationOTAs, error) {
+	page := &DenominationOTAs{Denomination: (*hexutil.Big)(denomination), OTAs: []hexutil.Bytes{}}
+	err := statedb.ForEachCommittedStorage(vm.OTABalance2ContractAddr(denomination), start, func(key, value []byte) bool {
+		if len(page.OTAs) == size {
+			page.Next = common.CopyBytes(key)
+			return false
+		}
+		page.OTAs = append(page.OTAs, common.CopyBytes(value))
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// isOTADenomination reports whether value is a supported coin or stamp
+// denomination.
+func isOTADenomination(value *big.Int) bool {
+	for _, supported := range append(vm.GetSupportWanCoinOTABalances(), vm.GetSupportStampOTABalances()...) {
+		if supported.Cmp(value) == 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package ethapi
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core/state"
+	"github.com/wanchain/go-wanchain/core/vm"
+	"github.com/wanchain/go-wanchain/ethdb"
+)
+
+// Tests that the OTAs of a denomination are listed by pages, each resuming at
+// the cursor of the previous one, without gaps nor duplicates.
+func TestListOTAs(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+
+	denomination, _ := new(big.Int).SetString(vm.Wancoin10, 10)
+	other, _ := new(big.Int).SetString(vm.Wancoin20, 10)
+	for i := 0; i < 25; i++ {
+		ota := make([]byte, common.WAddressLength)
+		ota[1], ota[2] = byte(i), 0x10
+		if _, err := vm.AddOTAIfNotExist(statedb, denomination, ota); err != nil {
+			t.Fatalf("failed to add OTA: %v", err)
+		}
+		ota = make([]byte, common.WAddressLength)
+		ota[1], ota[2] = byte(i), 0x20
+		if _, err := vm.AddOTAIfNotExist(statedb, other, ota); err != nil {
+			t.Fatalf("failed to add OTA: %v", err)
+		}
+	}
+	root, _ := statedb.CommitTo(db, true)
+	statedb, _ = state.New(root, state.NewDatabase(db))
+
+	var (
+		seen   = make(map[string]bool)
+		cursor []byte
+		pages  int
+	)
+	for {
+		page, err := listOTAs(statedb, denomination, cursor, 10)
+		if err != nil {
+			t.Fatalf("failed to list OTAs: %v", err)
+		}
+		pages++
+		for _, ota := range page.OTAs {
+			if ota[2] != 0x10 {
+				t.Fatalf("OTA of another denomination listed: %x", ota)
+			}
+			if seen[string(ota)] {
+				t.Fatalf("OTA listed twice: %x", ota)
+			}
+			seen[string(ota)] = true
+		}
+		if page.Next == nil {
+			break
+		}
+		if bytes.Equal(page.Next, cursor) {
+			t.Fatalf("cursor not advancing")
+		}
+		cursor = page.Next
+	}
+	if len(seen) != 25 || pages != 3 {
+		t.Fatalf("listing mismatch: have %d OTAs in %d pages, want 25 in 3", len(seen), pages)
+	}
+}
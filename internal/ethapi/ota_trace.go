@@ -0,0 +1,91 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package ethapi
+
+import (
+	"sync"
+	"time"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core/vm"
+)
+
+// maxOTASetTraces is the number of mix set samplings kept by the recorder,
+// the oldest ones being dropped first.
+const maxOTASetTraces = 1024
+
+// otaSetTraces records the mix set samplings served by wan_getOTAMixSet while
+// enabled with debug_recordOtaSets.
+var otaSetTraces = new(otaSetRecorder)
+
+// OTASetTraceResult is the outcome of a traced OTA mix set sampling.
+type OTASetTraceResult struct {
+	*vm.OTASetTrace
+	Block uint64   `json:"block"` // Number of the block whose state was sampled
+	Set   []string `json:"set"`
+}
+
+// otaSetRecorder keeps the latest traced mix set samplings.
+type otaSetRecorder struct {
+	mu      sync.Mutex
+	enabled bool
+	traces  []*OTASetTraceResult
+}
+
+// enable turns recording on or off, dropping the recorded traces when off.
+func (r *otaSetRecorder) enable(on bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.enabled = on
+	if !on {
+		r.traces = nil
+	}
+}
+
+// trace returns a fresh trace to sample with, or nil if recording is off.
+func (r *otaSetRecorder) trace() *vm.OTASetTrace {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.enabled {
+		return nil
+	}
+	return &vm.OTASetTrace{Seed: time.Now().UnixNano()}
+}
+
+// record keeps a sampling of the state of the given block.
+func (r *otaSetRecorder) record(trace *vm.OTASetTrace, block uint64, set [][]byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.enabled {
+		return
+	}
+	if len(r.traces) >= maxOTASetTraces {
+		r.traces = append(r.traces[:0], r.traces[1:]...)
+	}
+	r.traces = append(r.traces, newOTASetTraceResult(trace, block, set))
+}
+
+// at returns the samplings recorded on the state of the given block.
+func (r *otaSetRecorder) at(block uint64) []*OTASetTraceResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	traces := make([]*OTASetTraceResult, 0)
+	for _, trace := range r.traces {
+		if trace.Block == block {
+			traces = append(traces, trace)
+		}
+	}
+	return traces
+}
+
+func newOTASetTraceResult(trace *vm.OTASetTrace, block uint64, set [][]byte) *OTASetTraceResult {
+	result := &OTASetTraceResult{OTASetTrace: trace, Block: block, Set: make([]string, 0, len(set))}
+	for _, ota := range set {
+		result.Set = append(result.Set, common.ToHex(ota))
+	}
+	return result
+}
@@ -0,0 +1,42 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package ethapi
+
+import (
+	"testing"
+)
+
+func TestOTASetRecorder(t *testing.T) {
+	recorder := new(otaSetRecorder)
+	if trace := recorder.trace(); trace != nil {
+		t.Fatalf("trace handed out while recording is off")
+	}
+	recorder.enable(true)
+
+	set := [][]byte{{0x01}, {0x02}}
+	for i := 0; i < maxOTASetTraces+2; i++ {
+		trace := recorder.trace()
+		if trace == nil {
+			t.Fatalf("no trace handed out while recording")
+		}
+		recorder.record(trace, uint64(i%3), set)
+	}
+	if len(recorder.traces) != maxOTASetTraces {
+		t.Fatalf("recorded count mismatch: have %d, want %d", len(recorder.traces), maxOTASetTraces)
+	}
+	// The oldest samplings of block 0 and 1 have been dropped
+	if have, want := len(recorder.at(0)), (maxOTASetTraces+2)/3-1; have != want {
+		t.Errorf("block 0 count mismatch: have %d, want %d", have, want)
+	}
+	traces := recorder.at(2)
+	if len(traces) == 0 || traces[0].Block != 2 || len(traces[0].Set) != 2 || traces[0].Set[1] != "0x02" {
+		t.Errorf("block 2 trace mismatch: have %v", traces)
+	}
+	// Samplings handed out before recording stopped aren't kept
+	trace := recorder.trace()
+	recorder.enable(false)
+	recorder.record(trace, 2, set)
+	if len(recorder.traces) != 0 {
+		t.Errorf("traces kept after recording stopped: %d", len(recorder.traces))
+	}
+}
@@ -0,0 +1,267 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package ethapi
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/wanchain/go-wanchain/accounts"
+	"github.com/wanchain/go-wanchain/accounts/keystore"
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/common/hexutil"
+	"github.com/wanchain/go-wanchain/common/math"
+	"github.com/wanchain/go-wanchain/core/vm"
+	"github.com/wanchain/go-wanchain/crypto"
+	"github.com/wanchain/go-wanchain/ethdb"
+	"github.com/wanchain/go-wanchain/rpc"
+)
+
+// A receipt proof lets the sender of an OTA deposit prove to a third party
+// that it funded a given OTA for a given wan address, without revealing the
+// one-time secret of the OTA nor any of its other activity. It requires the
+// secret to be retained by the node when the OTA is generated: it is stored
+// encrypted to an account of the node, which must be unlocked to prove.
+
+const (
+	receiptProofLength    = 65 + 2*common.HashLength // Shared point, challenge and response
+	retainedTxKeyLength   = common.HashLength + common.WAddressLength
+	maxRetainedTxKeyCount = 4096 // Number of one-time secrets retained at once at most
+)
+
+var (
+	// otaTxKeyPrefix + OTA wan address -> account address + sealed one-time
+	// secret and receiver wan address
+	otaTxKeyPrefix = []byte("ota-txkey-")
+
+	// otaTxKeyCountKey -> number of retained one-time secrets
+	otaTxKeyCountKey = []byte("ota-txkey-count")
+
+	// txKeyLock serialises the updates of the retained secrets and their count
+	txKeyLock sync.Mutex
+
+	ErrNoTxKey             = errors.New("no one-time secret retained for OTA")
+	ErrTooManyTxKeys       = errors.New("too many one-time secrets retained")
+	ErrInvalidReceiptProof = errors.New("invalid receipt proof")
+)
+
+// ReceiptProofResult is the outcome of a receipt proof verification.
+type ReceiptProofResult struct {
+	Valid bool         `json:"valid"` // Whether the OTA was generated for the wan address
+	Value *hexutil.Big `json:"value"` // Balance deposited to the OTA, nil if not on chain
+}
+
+// retainTxKey stores the one-time secret of an OTA generated for wAddr, sealed
+// to the wan address ownerWAddr of the account owner.
+func retainTxKey(db ethdb.Database, ota []byte, r *ecdsa.PrivateKey, wAddr []byte, owner common.Address, ownerWAddr []byte) error {
+	txKeyLock.Lock()
+	defer txKeyLock.Unlock()
+
+	var count uint64
+	if blob, err := db.Get(otaTxKeyCountKey); err == nil {
+		count = new(big.Int).SetBytes(blob).Uint64()
+	}
+	if count >= maxRetainedTxKeyCount {
+		return ErrTooManyTxKeys
+	}
+	sealed, err := keystore.SealToWAddress(ownerWAddr, append(math.PaddedBigBytes(r.D, common.HashLength), wAddr...))
+	if err != nil {
+		return err
+	}
+	batch := db.NewBatch()
+	batch.Put(append(otaTxKeyPrefix, ota...), append(owner.Bytes(), sealed...))
+	batch.Put(otaTxKeyCountKey, new(big.Int).SetUint64(count+1).Bytes())
+	return batch.Write()
+}
+
+// forgetTxKey drops the retained secret of an OTA, freeing its slot.
+func forgetTxKey(db ethdb.Database, ota []byte) error {
+	txKeyLock.Lock()
+	defer txKeyLock.Unlock()
+
+	key := append(otaTxKeyPrefix, ota...)
+	if ok, _ := db.Has(key); !ok {
+		return ErrNoTxKey
+	}
+	// Batches can't delete, drop the secret first to never undercount
+	if err := db.Delete(key); err != nil {
+		return err
+	}
+	var count uint64
+	if blob, err := db.Get(otaTxKeyCountKey); err == nil {
+		count = new(big.Int).SetBytes(blob).Uint64()
+	}
+	if count > 0 {
+		count--
+	}
+	return db.Put(otaTxKeyCountKey, new(big.Int).SetUint64(count).Bytes())
+}
+
+// retainedTxKey retrieves the account an OTA secret is sealed to, along with
+// the sealed secret.
+func retainedTxKey(db ethdb.Database, ota []byte) (common.Address, []byte, error) {
+	value, err := db.Get(append(otaTxKeyPrefix, ota...))
+	if err != nil || len(value) <= common.AddressLength {
+		return common.Address{}, nil, ErrNoTxKey
+	}
+	return common.BytesToAddress(value[:common.AddressLength]), value[common.AddressLength:], nil
+}
+
+// openTxKey splits a decrypted retained secret into the one-time secret of an
+// OTA and the wan address it was generated for.
+func openTxKey(plaintext []byte) (*ecdsa.PrivateKey, []byte, error) {
+	if len(plaintext) != retainedTxKeyLength {
+		return nil, nil, ErrNoTxKey
+	}
+	r, err := crypto.ToECDSA(plaintext[:common.HashLength])
+	if err != nil {
+		return nil, nil, err
+	}
+	return r, plaintext[common.HashLength:], nil
+}
+
+// generateRetainedOneTimeAddress generates an OTA for wAddr and retains its
+// one-time secret sealed to the account owner of wan address ownerWAddr.
+func generateRetainedOneTimeAddress(db ethdb.Database, wAddr []byte, owner common.Address, ownerWAddr []byte) (string, error) {
+	A, B, err := keystore.GeneratePKPairFromWAddress(wAddr)
+	if err != nil {
+		return "", ErrFailToGeneratePKPairFromWAddress
+	}
+	A1, R, r, err := crypto.GenerateOneTimeKeyWithSecret(A, B)
+	if err != nil {
+		return "", err
+	}
+	ota := keystore.GenerateWaddressFromPK(A1, R)
+	if err := retainTxKey(db, ota[:], r, wAddr, owner, ownerWAddr); err != nil {
+		return "", err
+	}
+	return hexutil.Encode(ota[:]), nil
+}
+
+// GenerateRetainedOneTimeAddress returns a One-Time-Address for a given
+// WanAddress and retains its one-time secret, encrypted to the given account,
+// so that the deposit can later be proven with personal_proveReceipt.
+func (s *PrivateAccountAPI) GenerateRetainedOneTimeAddress(ctx context.Context, wAddr string, account common.Address) (string, error) {
+	wAddrBytes, err := hexutil.Decode(wAddr)
+	if err != nil || len(wAddrBytes) != common.WAddressLength {
+		return "", ErrInvalidWAddress
+	}
+	ks := fetchAccountKeystore(s.am, account)
+	if !ks.HasAddress(account) {
+		return "", accounts.ErrUnknownAccount
+	}
+	ownerWAddr, err := ks.GetWanAddress(accounts.Account{Address: account})
+	if err != nil {
+		return "", err
+	}
+	return generateRetainedOneTimeAddress(s.b.ChainDb(), wAddrBytes, account, ownerWAddr[:])
+}
+
+// ProveReceipt creates a receipt proof for an OTA generated by this node with
+// a retained one-time secret, decrypted with the unlocked account it is sealed
+// to.
+func (s *PrivateAccountAPI) ProveReceipt(ctx context.Context, ota string) (string, error) {
+	otaBytes, err := hexutil.Decode(ota)
+	if err != nil || len(otaBytes) != common.WAddressLength {
+		return "", ErrInvalidOTAAddr
+	}
+	owner, sealed, err := retainedTxKey(s.b.ChainDb(), otaBytes)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := fetchAccountKeystore(s.am, owner).DecryptWithAccount(accounts.Account{Address: owner}, sealed)
+	if err != nil {
+		return "", err
+	}
+	r, wAddr, err := openTxKey(plaintext)
+	if err != nil {
+		return "", err
+	}
+	proof, err := proveReceipt(r, wAddr)
+	if err != nil {
+		return "", err
+	}
+	return hexutil.Encode(proof), nil
+}
+
+// ForgetReceipt drops the one-time secret retained for an OTA generated by this
+// node, once its deposit no longer needs to be proven. Retained secrets are
+// capped, forgetting them frees room for new ones.
+func (s *PrivateAccountAPI) ForgetReceipt(ctx context.Context, ota string) error {
+	otaBytes, err := hexutil.Decode(ota)
+	if err != nil || len(otaBytes) != common.WAddressLength {
+		return ErrInvalidOTAAddr
+	}
+	return forgetTxKey(s.b.ChainDb(), otaBytes)
+}
+
+// proveReceipt creates a receipt proof of an OTA from its one-time secret r
+// and the wan address wAddr it was generated for.
+func proveReceipt(r *ecdsa.PrivateKey, wAddr []byte) ([]byte, error) {
+	_, B, err := keystore.GeneratePKPairFromWAddress(wAddr)
+	if err != nil {
+		return nil, ErrFailToGeneratePKPairFromWAddress
+	}
+	D, c, sig, err := crypto.ProveOTAReceipt(r, B)
+	if err != nil {
+		return nil, err
+	}
+	proof := append(crypto.FromECDSAPub(D), math.PaddedBigBytes(c, common.HashLength)...)
+	return append(proof, math.PaddedBigBytes(sig, common.HashLength)...), nil
+}
+
+// CheckReceiptProof verifies that ota was generated for the wan address wAddr
+// by the creator of proof, and reports the balance deposited to it.
+func (s *PublicTransactionPoolAPI) CheckReceiptProof(ctx context.Context, ota string, wAddr string, proof hexutil.Bytes) (*ReceiptProofResult, error) {
+	otaBytes, err := hexutil.Decode(ota)
+	if err != nil || len(otaBytes) != common.WAddressLength {
+		return nil, ErrInvalidOTAAddr
+	}
+	wAddrBytes, err := hexutil.Decode(wAddr)
+	if err != nil || len(wAddrBytes) != common.WAddressLength {
+		return nil, ErrInvalidWAddress
+	}
+	valid, err := verifyReceiptProof(otaBytes, wAddrBytes, proof)
+	if err != nil {
+		return nil, err
+	}
+	result := &ReceiptProofResult{Valid: valid}
+	if !valid {
+		return result, nil
+	}
+
+	state, _, err := s.b.StateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	otaAX, _ := vm.GetAXFromWanAddr(otaBytes)
+	if exist, balance, err := vm.CheckOTAExist(state, otaAX); err == nil && exist {
+		result.Value = (*hexutil.Big)(balance)
+	}
+	return result, nil
+}
+
+// verifyReceiptProof checks a receipt proof of ota for the wan address wAddr.
+func verifyReceiptProof(ota []byte, wAddr []byte, proof []byte) (bool, error) {
+	if len(proof) != receiptProofLength {
+		return false, ErrInvalidReceiptProof
+	}
+	A1, R, err := keystore.GeneratePKPairFromWAddress(ota)
+	if err != nil {
+		return false, ErrInvalidOTAAddr
+	}
+	A, B, err := keystore.GeneratePKPairFromWAddress(wAddr)
+	if err != nil {
+		return false, ErrFailToGeneratePKPairFromWAddress
+	}
+	D := crypto.ToECDSAPub(proof[:65])
+	if D == nil || D.X == nil {
+		return false, ErrInvalidReceiptProof
+	}
+	c := new(big.Int).SetBytes(proof[65 : 65+common.HashLength])
+	sig := new(big.Int).SetBytes(proof[65+common.HashLength:])
+	return crypto.VerifyOTAReceipt(A, B, A1, R, D, c, sig), nil
+}
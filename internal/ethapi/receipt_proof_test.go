@@ -0,0 +1,105 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package ethapi
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/accounts/keystore"
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/common/hexutil"
+	"github.com/wanchain/go-wanchain/crypto"
+	"github.com/wanchain/go-wanchain/crypto/ecies"
+	"github.com/wanchain/go-wanchain/ethdb"
+)
+
+func TestReceiptProof(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+
+	newWAddr := func() ([]byte, []byte) {
+		a, _ := crypto.GenerateKey()
+		b, _ := crypto.GenerateKey()
+		return keystore.GenerateWaddressFromPK(&a.PublicKey, &b.PublicKey)[:], crypto.FromECDSA(a)
+	}
+	receiver, _ := newWAddr()
+	other, _ := newWAddr()
+	ownerWAddr, ownerKey := newWAddr()
+	owner := common.HexToAddress("0x0000000000000000000000000000000000000001")
+
+	ota, err := generateRetainedOneTimeAddress(db, receiver, owner, ownerWAddr)
+	if err != nil {
+		t.Fatalf("failed to generate OTA: %v", err)
+	}
+	otaBytes, _ := hexutil.Decode(ota)
+
+	// The secret is only retained sealed to the owner
+	account, sealed, err := retainedTxKey(db, otaBytes)
+	if err != nil || account != owner {
+		t.Fatalf("retained secret mismatch: have account %x (%v), want %x", account, err, owner)
+	}
+	if _, _, err := openTxKey(sealed); err != ErrNoTxKey {
+		t.Errorf("sealed secret opened: %v", err)
+	}
+	priv, _ := crypto.ToECDSA(ownerKey)
+	plaintext, err := ecies.ImportECDSA(priv).Decrypt(nil, sealed, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to decrypt secret: %v", err)
+	}
+	r, wAddr, err := openTxKey(plaintext)
+	if err != nil {
+		t.Fatalf("failed to open secret: %v", err)
+	}
+
+	proof, err := proveReceipt(r, wAddr)
+	if err != nil {
+		t.Fatalf("failed to prove receipt: %v", err)
+	}
+	if valid, err := verifyReceiptProof(otaBytes, receiver, proof); !valid || err != nil {
+		t.Fatalf("valid receipt proof rejected: %v", err)
+	}
+	if valid, _ := verifyReceiptProof(otaBytes, other, proof); valid {
+		t.Errorf("receipt proof accepted for another receiver")
+	}
+	if _, err := verifyReceiptProof(otaBytes, receiver, proof[1:]); err != ErrInvalidReceiptProof {
+		t.Errorf("truncated proof error mismatch: have %v, want %v", err, ErrInvalidReceiptProof)
+	}
+
+	// OTAs whose secret wasn't retained can't be proven
+	otherDb, _ := ethdb.NewMemDatabase()
+	unknown, _ := generateRetainedOneTimeAddress(otherDb, receiver, owner, ownerWAddr)
+	if _, _, err := retainedTxKey(db, hexutil.MustDecode(unknown)); err != ErrNoTxKey {
+		t.Errorf("unknown OTA error mismatch: have %v, want %v", err, ErrNoTxKey)
+	}
+}
+
+// Tests that the number of retained secrets is capped and that forgetting one
+// makes room for another.
+func TestRetainedTxKeyLimit(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	a, _ := crypto.GenerateKey()
+	b, _ := crypto.GenerateKey()
+	wAddr := keystore.GenerateWaddressFromPK(&a.PublicKey, &b.PublicKey)[:]
+
+	db.Put(otaTxKeyCountKey, new(big.Int).SetUint64(maxRetainedTxKeyCount-1).Bytes())
+	ota, err := generateRetainedOneTimeAddress(db, wAddr, common.Address{}, wAddr)
+	if err != nil {
+		t.Fatalf("failed to retain the last secret: %v", err)
+	}
+	if _, err := generateRetainedOneTimeAddress(db, wAddr, common.Address{}, wAddr); err != ErrTooManyTxKeys {
+		t.Errorf("secret beyond the limit error mismatch: have %v, want %v", err, ErrTooManyTxKeys)
+	}
+	// Forgetting a secret frees its slot
+	if err := forgetTxKey(db, hexutil.MustDecode(ota)); err != nil {
+		t.Fatalf("failed to forget the secret: %v", err)
+	}
+	if _, _, err := retainedTxKey(db, hexutil.MustDecode(ota)); err != ErrNoTxKey {
+		t.Errorf("forgotten secret error mismatch: have %v, want %v", err, ErrNoTxKey)
+	}
+	if err := forgetTxKey(db, hexutil.MustDecode(ota)); err != ErrNoTxKey {
+		t.Errorf("forgetting twice error mismatch: have %v, want %v", err, ErrNoTxKey)
+	}
+	if _, err := generateRetainedOneTimeAddress(db, wAddr, common.Address{}, wAddr); err != nil {
+		t.Errorf("failed to retain a secret after forgetting one: %v", err)
+	}
+}
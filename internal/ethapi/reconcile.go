@@ -0,0 +1,246 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package ethapi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/common/hexutil"
+	"github.com/wanchain/go-wanchain/core"
+	"github.com/wanchain/go-wanchain/core/state"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/core/vm"
+	"github.com/wanchain/go-wanchain/crypto"
+	"github.com/wanchain/go-wanchain/ethdb"
+	"github.com/wanchain/go-wanchain/rpc"
+)
+
+// maxReconcileEntries is the maximum number of deposits and spends reconciled
+// by a single request.
+const maxReconcileEntries = 10000
+
+// Reconciliation statuses of the expected deposits and spends.
+const (
+	ReconcileConfirmed  = "confirmed"   // On chain, in the canonical block reported
+	ReconcileMissing    = "missing"     // Not on chain
+	ReconcileDoubleSeen = "double-seen" // Expected more than once
+	ReconcileReorged    = "reorged"     // Not on chain anymore, its block was reorged out
+)
+
+var ErrTooManyReconcileEntries = errors.New("too many deposits and spends to reconcile")
+
+// ExpectedDeposit is an OTA deposit an exchange credited. The transaction and
+// the block it was seen in are optional, but only reconciled when given.
+type ExpectedDeposit struct {
+	OTA       hexutil.Bytes `json:"ota"`
+	Value     *hexutil.Big  `json:"value"`
+	TxHash    *common.Hash  `json:"txHash"`
+	BlockHash *common.Hash  `json:"blockHash"`
+}
+
+// ExpectedSpend is a key image spend an exchange debited. The transaction and
+// the block it was seen in are optional, but only reconciled when given.
+type ExpectedSpend struct {
+	KeyImage  hexutil.Bytes `json:"keyImage"`
+	TxHash    *common.Hash  `json:"txHash"`
+	BlockHash *common.Hash  `json:"blockHash"`
+}
+
+// ReconcileArgs are the deposits and spends to reconcile against the chain.
+type ReconcileArgs struct {
+	Deposits []ExpectedDeposit `json:"deposits"`
+	Spends   []ExpectedSpend   `json:"spends"`
+}
+
+// ReconciledEntry is the reconciliation of an expected deposit or spend, in the
+// order of the request. The transaction and block are the canonical ones when
+// confirmed and known, the expected ones otherwise.
+type ReconciledEntry struct {
+	ID          hexutil.Bytes   `json:"id"` // OTA of a deposit, key image of a spend
+	Status      string          `json:"status"`
+	Reason      string          `json:"reason,omitempty"`
+	TxHash      *common.Hash    `json:"txHash,omitempty"`
+	BlockHash   *common.Hash    `json:"blockHash,omitempty"`
+	BlockNumber *hexutil.Uint64 `json:"blockNumber,omitempty"`
+}
+
+// ReconcileReport is the reconciliation of a set of deposits and spends at a
+// block, with the number of entries of every status.
+type ReconcileReport struct {
+	BlockNumber hexutil.Uint64     `json:"blockNumber"`
+	BlockHash   common.Hash        `json:"blockHash"`
+	Deposits    []*ReconciledEntry `json:"deposits"`
+	Spends      []*ReconciledEntry `json:"spends"`
+	Totals      map[string]int     `json:"totals"`
+}
+
+// Reconcile cross-checks the OTA deposits and key image spends an exchange
+// recorded against the chain at the given block, the latest one if omitted.
+// Every entry is reported as:
+//
+//	confirmed    the OTA holds the expected value, or the key image is spent,
+//	             by the expected transaction if any, in a canonical block
+//	double-seen  the OTA, key image or transaction was expected before
+//	reorged      not confirmed, and the expected block is not canonical
+//	missing      not confirmed for any other reason
+func (s *PublicBlockChainAPI) Reconcile(ctx context.Context, args ReconcileArgs, blockNr *rpc.BlockNumber) (*ReconcileReport, error) {
+	if len(args.Deposits)+len(args.Spends) > maxReconcileEntries {
+		return nil, ErrTooManyReconcileEntries
+	}
+	number := rpc.LatestBlockNumber
+	if blockNr != nil {
+		number = *blockNr
+	}
+	state, header, err := s.b.StateAndHeaderByNumber(ctx, number)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	return reconcile(s.b.ChainDb(), state, header, args), nil
+}
+
+// reconciler checks expected entries against a state and the canonical chain
+// up to its block.
+type reconciler struct {
+	db     ethdb.Database
+	state  *state.StateDB
+	header *types.Header
+	seen   map[string]bool // IDs and transaction hashes already reconciled
+}
+
+// reconcile reconciles the deposits and spends against the state of header.
+func reconcile(db ethdb.Database, statedb *state.StateDB, header *types.Header, args ReconcileArgs) *ReconcileReport {
+	r := &reconciler{db: db, state: statedb, header: header, seen: make(map[string]bool)}
+	report := &ReconcileReport{
+		BlockNumber: hexutil.Uint64(header.Number.Uint64()),
+		BlockHash:   header.Hash(),
+		Deposits:    make([]*ReconciledEntry, 0, len(args.Deposits)),
+		Spends:      make([]*ReconciledEntry, 0, len(args.Spends)),
+		Totals:      map[string]int{ReconcileConfirmed: 0, ReconcileMissing: 0, ReconcileDoubleSeen: 0, ReconcileReorged: 0},
+	}
+	for _, deposit := range args.Deposits {
+		entry := r.deposit(deposit)
+		report.Deposits = append(report.Deposits, entry)
+		report.Totals[entry.Status]++
+	}
+	for _, spend := range args.Spends {
+		entry := r.spend(spend)
+		report.Spends = append(report.Spends, entry)
+		report.Totals[entry.Status]++
+	}
+	return report
+}
+
+// doubleSeen reports whether the entry or its transaction was reconciled
+// before, marking them seen.
+func (r *reconciler) doubleSeen(kind string, id []byte, txHash *common.Hash) bool {
+	keys := []string{kind + string(id)}
+	if txHash != nil {
+		keys = append(keys, string(txHash.Bytes()))
+	}
+	seen := false
+	for _, key := range keys {
+		seen = seen || r.seen[key]
+		r.seen[key] = true
+	}
+	return seen
+}
+
+// canonicalTx looks up a transaction in the canonical chain up to the block
+// reconciled at, returning its block hash, number and index.
+func (r *reconciler) canonicalTx(hash common.Hash) (*types.Transaction, common.Hash, uint64, uint64) {
+	tx, blockHash, number, index := core.GetTransaction(r.db, hash)
+	if tx == nil || number > r.header.Number.Uint64() || core.GetCanonicalHash(r.db, number) != blockHash {
+		return nil, common.Hash{}, 0, 0
+	}
+	return tx, blockHash, number, index
+}
+
+// unconfirmed reports an entry that is not on chain, as reorged if its expected
+// block is not canonical.
+func (r *reconciler) unconfirmed(entry *ReconciledEntry, reason string) *ReconciledEntry {
+	entry.Status, entry.Reason = ReconcileMissing, reason
+	if entry.BlockHash == nil {
+		return entry
+	}
+	number := core.GetBlockNumber(r.db, *entry.BlockHash)
+	if number == ^uint64(0) {
+		entry.Status, entry.Reason = ReconcileReorged, "unknown block"
+		return entry
+	}
+	entry.BlockNumber = (*hexutil.Uint64)(&number)
+	if number <= r.header.Number.Uint64() && core.GetCanonicalHash(r.db, number) != *entry.BlockHash {
+		entry.Status, entry.Reason = ReconcileReorged, "block not canonical"
+	}
+	return entry
+}
+
+// confirmed reports an entry on chain, in the canonical block of its
+// transaction if known.
+func (r *reconciler) confirmed(entry *ReconciledEntry, blockHash common.Hash, number uint64) *ReconciledEntry {
+	entry.Status, entry.Reason = ReconcileConfirmed, ""
+	if entry.TxHash != nil {
+		entry.BlockHash, entry.BlockNumber = &blockHash, (*hexutil.Uint64)(&number)
+	}
+	return entry
+}
+
+func (r *reconciler) deposit(expected ExpectedDeposit) *ReconciledEntry {
+	entry := &ReconciledEntry{ID: expected.OTA, TxHash: expected.TxHash, BlockHash: expected.BlockHash}
+	if r.doubleSeen("deposit", expected.OTA, expected.TxHash) {
+		entry.Status = ReconcileDoubleSeen
+		return entry
+	}
+	ax, err := vm.GetAXFromWanAddr(expected.OTA)
+	if err != nil {
+		return r.unconfirmed(entry, "invalid OTA")
+	}
+	exist, balance, err := vm.CheckOTAExist(r.state, ax)
+	if err != nil || !exist {
+		return r.unconfirmed(entry, "OTA not deposited")
+	}
+	if expected.Value != nil && balance.Cmp(expected.Value.ToInt()) != 0 {
+		return r.unconfirmed(entry, "OTA deposited with value "+balance.String())
+	}
+	if expected.TxHash == nil {
+		return r.confirmed(entry, common.Hash{}, 0)
+	}
+	tx, blockHash, number, index := r.canonicalTx(*expected.TxHash)
+	if tx == nil {
+		return r.unconfirmed(entry, "transaction not canonical")
+	}
+	if ota, _, _, ok := vm.UnpackDeposit(tx.To(), tx.Data()); !ok || !bytes.Equal(ota, expected.OTA) {
+		return r.unconfirmed(entry, "transaction doesn't deposit to the OTA")
+	}
+	if receipts := core.GetBlockReceipts(r.db, blockHash, number); index >= uint64(len(receipts)) || receipts[index].Status != types.ReceiptStatusSuccessful {
+		return r.unconfirmed(entry, "transaction failed")
+	}
+	return r.confirmed(entry, blockHash, number)
+}
+
+func (r *reconciler) spend(expected ExpectedSpend) *ReconciledEntry {
+	entry := &ReconciledEntry{ID: expected.KeyImage, TxHash: expected.TxHash, BlockHash: expected.BlockHash}
+	if r.doubleSeen("spend", expected.KeyImage, expected.TxHash) {
+		entry.Status = ReconcileDoubleSeen
+		return entry
+	}
+	if len(expected.KeyImage) != 65 {
+		return r.unconfirmed(entry, "invalid key image")
+	}
+	if spent, _, err := vm.CheckOTAImageExist(r.state, expected.KeyImage); err != nil || !spent {
+		return r.unconfirmed(entry, "key image not spent")
+	}
+	if expected.TxHash == nil {
+		return r.confirmed(entry, common.Hash{}, 0)
+	}
+	tx, blockHash, number, _ := r.canonicalTx(*expected.TxHash)
+	if tx == nil {
+		return r.unconfirmed(entry, "transaction not canonical")
+	}
+	if _, keyImage, err := ringOf(tx); err != nil || !bytes.Equal(crypto.FromECDSAPub(keyImage), expected.KeyImage) {
+		return r.unconfirmed(entry, "transaction doesn't spend the key image")
+	}
+	return r.confirmed(entry, blockHash, number)
+}
@@ -0,0 +1,126 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package ethapi
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/common/hexutil"
+	"github.com/wanchain/go-wanchain/core"
+	"github.com/wanchain/go-wanchain/core/state"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/core/vm"
+	"github.com/wanchain/go-wanchain/crypto"
+	"github.com/wanchain/go-wanchain/ethdb"
+)
+
+// reconcileOTA returns an OTA wan address with the given AX marker.
+func reconcileOTA(marker byte) hexutil.Bytes {
+	ota := make([]byte, common.WAddressLength)
+	ota[1], ota[2] = marker, 0x42
+	return ota
+}
+
+// Tests that expected deposits and spends are reconciled as confirmed, missing,
+// double-seen or reorged against the chain.
+func TestReconcile(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	coin10, _ := new(big.Int).SetString(vm.Wancoin10, 10)
+
+	// Block 1 deposits the first OTA and spends a key image
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	spendTx := newRingSignedTx(t, keys)
+	_, image, err := ringOf(spendTx)
+	if err != nil {
+		t.Fatalf("failed to decode the ring signature: %v", err)
+	}
+	keyImage := crypto.FromECDSAPub(image)
+
+	to, data, err := vm.PackBuyCoinNote(reconcileOTA(1), coin10)
+	if err != nil {
+		t.Fatalf("failed to pack deposit: %v", err)
+	}
+	depositTx := types.NewTransaction(0, to, coin10, big.NewInt(200000), big.NewInt(1), data)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	vm.AddOTAIfNotExist(statedb, coin10, reconcileOTA(1))
+	vm.AddOTAIfNotExist(statedb, coin10, reconcileOTA(2))
+	vm.AddOTAImage(statedb, keyImage, coin10.Bytes())
+	root, _ := statedb.CommitTo(db, true)
+	statedb, _ = state.New(root, state.NewDatabase(db))
+
+	genesis := types.NewBlock(&types.Header{Number: big.NewInt(0)}, nil, nil, nil)
+	receipts := types.Receipts{
+		{TxHash: depositTx.Hash(), Status: types.ReceiptStatusSuccessful, CumulativeGasUsed: new(big.Int), GasUsed: new(big.Int)},
+		{TxHash: spendTx.Hash(), Status: types.ReceiptStatusSuccessful, CumulativeGasUsed: new(big.Int), GasUsed: new(big.Int)},
+	}
+	block := types.NewBlock(&types.Header{Number: big.NewInt(1), ParentHash: genesis.Hash(), Root: root}, types.Transactions{depositTx, spendTx}, nil, receipts)
+	side := types.NewBlock(&types.Header{Number: big.NewInt(1), ParentHash: genesis.Hash(), Extra: []byte("side")}, nil, nil, nil)
+	for _, b := range []*types.Block{genesis, block, side} {
+		if err := core.WriteBlock(db, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	core.WriteCanonicalHash(db, genesis.Hash(), 0)
+	core.WriteCanonicalHash(db, block.Hash(), 1)
+	core.WriteBlockReceipts(db, block.Hash(), 1, receipts)
+	core.WriteTxLookupEntries(db, block)
+
+	var (
+		depositHash = depositTx.Hash()
+		spendHash   = spendTx.Hash()
+		sideHash    = side.Hash()
+		unknownHash = common.Hash{0xff}
+	)
+	args := ReconcileArgs{
+		Deposits: []ExpectedDeposit{
+			{OTA: reconcileOTA(1), Value: (*hexutil.Big)(coin10), TxHash: &depositHash, BlockHash: &sideHash},
+			{OTA: reconcileOTA(1)},
+			{OTA: reconcileOTA(2), Value: (*hexutil.Big)(coin10)},
+			{OTA: reconcileOTA(2), Value: (*hexutil.Big)(coin10)},
+			{OTA: reconcileOTA(3), BlockHash: &sideHash},
+			{OTA: reconcileOTA(4), BlockHash: &unknownHash},
+			{OTA: reconcileOTA(5)},
+		},
+		Spends: []ExpectedSpend{
+			{KeyImage: keyImage, TxHash: &spendHash},
+			{KeyImage: append([]byte{0x04}, make([]byte, 64)...)},
+			{KeyImage: crypto.FromECDSAPub(&keys[1].PublicKey), TxHash: &depositHash},
+		},
+	}
+	report := reconcile(db, statedb, block.Header(), args)
+
+	wantDeposits := []string{ReconcileConfirmed, ReconcileDoubleSeen, ReconcileConfirmed, ReconcileDoubleSeen, ReconcileReorged, ReconcileReorged, ReconcileMissing}
+	for i, entry := range report.Deposits {
+		if entry.Status != wantDeposits[i] {
+			t.Errorf("deposit %d: status mismatch: have %q (%s), want %q", i, entry.Status, entry.Reason, wantDeposits[i])
+		}
+	}
+	wantSpends := []string{ReconcileConfirmed, ReconcileMissing, ReconcileDoubleSeen}
+	for i, entry := range report.Spends {
+		if entry.Status != wantSpends[i] {
+			t.Errorf("spend %d: status mismatch: have %q (%s), want %q", i, entry.Status, entry.Reason, wantSpends[i])
+		}
+	}
+	// Confirmed transactions are reported in their canonical block
+	for _, entry := range []*ReconciledEntry{report.Deposits[0], report.Spends[0]} {
+		if entry.BlockHash == nil || *entry.BlockHash != block.Hash() || entry.BlockNumber == nil || *entry.BlockNumber != 1 {
+			t.Errorf("confirmed entry %x not reported in the canonical block", entry.ID)
+		}
+	}
+	want := map[string]int{ReconcileConfirmed: 3, ReconcileDoubleSeen: 3, ReconcileReorged: 2, ReconcileMissing: 2}
+	for status, count := range want {
+		if report.Totals[status] != count {
+			t.Errorf("%s total mismatch: have %d, want %d", status, report.Totals[status], count)
+		}
+	}
+	if report.BlockHash != block.Hash() {
+		t.Errorf("block hash mismatch: have %x, want %x", report.BlockHash, block.Hash())
+	}
+}
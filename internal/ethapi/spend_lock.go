@@ -0,0 +1,270 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package ethapi
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/common/hexutil"
+	"github.com/wanchain/go-wanchain/core/vm"
+	"github.com/wanchain/go-wanchain/rpc"
+)
+
+// Spend locks let wallet instances sharing a node reserve an OTA (or the key
+// image of an OTA) while they compose a refund, so that two of them don't
+// spend the same note concurrently, and the nonce of an account they send from
+// so that they don't sign conflicting transactions. Locks are node-local
+// advisory leases: they expire on their own and aren't enforced on submitted
+// transactions. The holder of a lock can re-acquire it with its token, e.g. to
+// renew the lease while replacing its pending refund with a higher priced one.
+
+const (
+	defaultSpendLockTTL = 5 * time.Minute
+	maxSpendLockTTL     = time.Hour
+	spendLockTokenLen   = 16
+
+	// maxSpendLocks is the maximum number of live locks held on the node.
+	maxSpendLocks = 4096
+)
+
+var (
+	ErrInvalidSpendLockId = errors.New("spend lock id must be an OTA wan address or a key image")
+	ErrSpendLocked        = errors.New("OTA is locked by another wallet")
+	ErrSpendLockNotHeld   = errors.New("spend lock not held")
+	ErrKeyImageSpent      = errors.New("key image already spent")
+	ErrTooManySpendLocks  = errors.New("too many spend locks")
+)
+
+// SpendLock describes a reserved OTA, key image or account nonce. The id of a
+// nonce lock is the account followed by the big endian nonce.
+type SpendLock struct {
+	Id      hexutil.Bytes `json:"id"`
+	Expires int64         `json:"expires"` // Unix time the lease ends at
+}
+
+// NonceLock is a nonce reserved by LockNonce.
+type NonceLock struct {
+	Nonce hexutil.Uint64 `json:"nonce"`
+	Token string         `json:"token"`
+}
+
+type spendLock struct {
+	token   string
+	expires time.Time
+}
+
+// spendLocker keeps the spend locks of the node.
+type spendLocker struct {
+	mu    sync.Mutex
+	locks map[string]*spendLock
+	now   func() time.Time
+}
+
+func newSpendLocker() *spendLocker {
+	return &spendLocker{
+		locks: make(map[string]*spendLock),
+		now:   time.Now,
+	}
+}
+
+// nonceLockId returns the lock id of the nonce of an account.
+func nonceLockId(account common.Address, nonce uint64) []byte {
+	id := make([]byte, common.AddressLength+8)
+	copy(id, account[:])
+	binary.BigEndian.PutUint64(id[common.AddressLength:], nonce)
+	return id
+}
+
+// prune drops the expired locks. The caller must hold the mutex.
+func (l *spendLocker) prune(now time.Time) {
+	for id, held := range l.locks {
+		if !held.expires.After(now) {
+			delete(l.locks, id)
+		}
+	}
+}
+
+// acquire reserves id for ttl. If token is not empty and holds the lock, the
+// lease is renewed. It returns the token of the lock.
+func (l *spendLocker) acquire(id []byte, token string, ttl time.Duration) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.prune(now)
+	return l.take(id, token, ttl, now)
+}
+
+// acquireNonce reserves the lowest nonce of account from start on which isn't
+// held by another token, returning it with the token of its lock.
+func (l *spendLocker) acquireNonce(account common.Address, start uint64, token string, ttl time.Duration) (uint64, string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.prune(now)
+	for nonce := start; ; nonce++ {
+		token, err := l.take(nonceLockId(account, nonce), token, ttl, now)
+		if err != ErrSpendLocked {
+			return nonce, token, err
+		}
+	}
+}
+
+// take reserves id for ttl, the expired locks being pruned. The caller must
+// hold the mutex.
+func (l *spendLocker) take(id []byte, token string, ttl time.Duration, now time.Time) (string, error) {
+	held, ok := l.locks[string(id)]
+	if ok && held.token != token {
+		return "", ErrSpendLocked
+	}
+	if !ok && len(l.locks) >= maxSpendLocks {
+		return "", ErrTooManySpendLocks
+	}
+	if token == "" {
+		buf := make([]byte, spendLockTokenLen)
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		token = hexutil.Encode(buf)
+	}
+	l.locks[string(id)] = &spendLock{token: token, expires: now.Add(ttl)}
+	return token, nil
+}
+
+// release releases the lock of id if token holds it.
+func (l *spendLocker) release(id []byte, token string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	held, ok := l.locks[string(id)]
+	if !ok || held.token != token || !held.expires.After(l.now()) {
+		return ErrSpendLockNotHeld
+	}
+	delete(l.locks, string(id))
+	return nil
+}
+
+// list returns the live locks, dropping the expired ones.
+func (l *spendLocker) list() []SpendLock {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.prune(now)
+
+	locks := make([]SpendLock, 0, len(l.locks))
+	for id, held := range l.locks {
+		locks = append(locks, SpendLock{Id: hexutil.Bytes(id), Expires: held.expires.Unix()})
+	}
+	sort.Slice(locks, func(i, j int) bool { return string(locks[i].Id) < string(locks[j].Id) })
+	return locks
+}
+
+// spendLockTTL returns the lease requested in seconds, bounded.
+func spendLockTTL(ttl *uint64) time.Duration {
+	lease := defaultSpendLockTTL
+	if ttl != nil {
+		lease = time.Duration(*ttl) * time.Second
+	}
+	if lease <= 0 {
+		lease = defaultSpendLockTTL
+	} else if lease > maxSpendLockTTL {
+		lease = maxSpendLockTTL
+	}
+	return lease
+}
+
+// PrivateSpendLockAPI provides the spend locking service to the wallets of
+// the node.
+type PrivateSpendLockAPI struct {
+	b     Backend
+	locks *spendLocker
+}
+
+// NewPrivateSpendLockAPI creates a new spend locking service.
+func NewPrivateSpendLockAPI(b Backend) *PrivateSpendLockAPI {
+	return &PrivateSpendLockAPI{b, newSpendLocker()}
+}
+
+// LockSpend reserves an OTA wan address or a key image for ttl seconds (5
+// minutes by default). Passing the token of a held lock renews it. It returns
+// the token needed to renew or release the lock.
+func (s *PrivateSpendLockAPI) LockSpend(ctx context.Context, id hexutil.Bytes, token *string, ttl *uint64) (string, error) {
+	switch len(id) {
+	case common.WAddressLength:
+	case 65:
+		state, _, err := s.b.StateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+		if state == nil || err != nil {
+			return "", err
+		}
+		if spent, _, err := vm.CheckOTAImageExist(state, id); err != nil {
+			return "", err
+		} else if spent {
+			return "", ErrKeyImageSpent
+		}
+	default:
+		return "", ErrInvalidSpendLockId
+	}
+	var held string
+	if token != nil {
+		held = *token
+	}
+	return s.locks.acquire(id, held, spendLockTTL(ttl))
+}
+
+// UnlockSpend releases a lock acquired with LockSpend.
+func (s *PrivateSpendLockAPI) UnlockSpend(id hexutil.Bytes, token string) (bool, error) {
+	if err := s.locks.release(id, token); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// LockNonce reserves a nonce of account for ttl seconds (5 minutes by default).
+// Without a nonce, it reserves the lowest one from the pending nonce of the
+// pool on that isn't locked by another wallet. Passing the nonce and token of
+// a held lock renews it, for the holder to replace its pending transaction.
+func (s *PrivateSpendLockAPI) LockNonce(ctx context.Context, account common.Address, nonce *hexutil.Uint64, token *string, ttl *uint64) (*NonceLock, error) {
+	var held string
+	if token != nil {
+		held = *token
+	}
+	if nonce != nil {
+		held, err := s.locks.acquire(nonceLockId(account, uint64(*nonce)), held, spendLockTTL(ttl))
+		if err != nil {
+			return nil, err
+		}
+		return &NonceLock{Nonce: *nonce, Token: held}, nil
+	}
+	start, err := s.b.GetPoolNonce(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	next, held, err := s.locks.acquireNonce(account, start, held, spendLockTTL(ttl))
+	if err != nil {
+		return nil, err
+	}
+	return &NonceLock{Nonce: hexutil.Uint64(next), Token: held}, nil
+}
+
+// UnlockNonce releases a lock acquired with LockNonce.
+func (s *PrivateSpendLockAPI) UnlockNonce(account common.Address, nonce hexutil.Uint64, token string) (bool, error) {
+	if err := s.locks.release(nonceLockId(account, uint64(nonce)), token); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SpendLocks returns the OTAs, key images and nonces currently reserved on the
+// node.
+func (s *PrivateSpendLockAPI) SpendLocks() []SpendLock {
+	return s.locks.list()
+}
@@ -0,0 +1,111 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package ethapi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wanchain/go-wanchain/common"
+)
+
+func TestSpendLocker(t *testing.T) {
+	now := time.Unix(1000, 0)
+	locker := newSpendLocker()
+	locker.now = func() time.Time { return now }
+	id := []byte("ota")
+
+	token, err := locker.acquire(id, "", time.Minute)
+	if err != nil {
+		t.Fatalf("failed to lock: %v", err)
+	}
+	if _, err := locker.acquire(id, "", time.Minute); err != ErrSpendLocked {
+		t.Fatalf("second lock error mismatch: have %v, want %v", err, ErrSpendLocked)
+	}
+	if err := locker.release(id, "0x00"); err != ErrSpendLockNotHeld {
+		t.Fatalf("foreign unlock error mismatch: have %v, want %v", err, ErrSpendLockNotHeld)
+	}
+	// The holder can renew its lease, e.g. to replace its pending refund
+	now = now.Add(50 * time.Second)
+	if renewed, err := locker.acquire(id, token, time.Minute); err != nil || renewed != token {
+		t.Fatalf("failed to renew lock: %v", err)
+	}
+	now = now.Add(50 * time.Second)
+	if locks := locker.list(); len(locks) != 1 || locks[0].Expires != now.Add(10*time.Second).Unix() {
+		t.Fatalf("lock list mismatch: %v", locks)
+	}
+
+	// Expired leases can be taken over by other wallets
+	now = now.Add(time.Minute)
+	if len(locker.list()) != 0 {
+		t.Fatalf("expired lock listed")
+	}
+	other, err := locker.acquire(id, "", time.Minute)
+	if err != nil || other == token {
+		t.Fatalf("failed to take over expired lock: %v", err)
+	}
+	if err := locker.release(id, token); err != ErrSpendLockNotHeld {
+		t.Errorf("stale unlock error mismatch: have %v, want %v", err, ErrSpendLockNotHeld)
+	}
+	if err := locker.release(id, other); err != nil {
+		t.Errorf("failed to unlock: %v", err)
+	}
+}
+
+// Tests that the wallets locking the nonces of an account get distinct ones,
+// and that holders renew theirs.
+func TestSpendLockerNonces(t *testing.T) {
+	now := time.Unix(1000, 0)
+	locker := newSpendLocker()
+	locker.now = func() time.Time { return now }
+	account := common.Address{0x01}
+
+	first, token, err := locker.acquireNonce(account, 5, "", time.Minute)
+	if err != nil || first != 5 {
+		t.Fatalf("first lock mismatch: have nonce %d, error %v, want 5", first, err)
+	}
+	second, other, err := locker.acquireNonce(account, 5, "", time.Minute)
+	if err != nil || second != 6 || other == token {
+		t.Fatalf("second lock mismatch: have nonce %d, error %v, want 6", second, err)
+	}
+	if renewed, err := locker.acquire(nonceLockId(account, 5), token, time.Minute); err != nil || renewed != token {
+		t.Fatalf("failed to renew nonce lock: %v", err)
+	}
+	if _, err := locker.acquire(nonceLockId(account, 6), token, time.Minute); err != ErrSpendLocked {
+		t.Fatalf("foreign renewal error mismatch: have %v, want %v", err, ErrSpendLocked)
+	}
+	// Other accounts aren't affected, and expired nonces are handed out again
+	if nonce, _, err := locker.acquireNonce(common.Address{0x02}, 5, "", time.Minute); err != nil || nonce != 5 {
+		t.Fatalf("other account mismatch: have nonce %d, error %v, want 5", nonce, err)
+	}
+	if err := locker.release(nonceLockId(account, 5), token); err != nil {
+		t.Fatalf("failed to unlock nonce: %v", err)
+	}
+	if nonce, _, err := locker.acquireNonce(account, 5, "", time.Minute); err != nil || nonce != 5 {
+		t.Fatalf("released nonce mismatch: have nonce %d, error %v, want 5", nonce, err)
+	}
+}
+
+// Tests that the number of live locks is capped, expired ones being pruned to
+// make room for new ones.
+func TestSpendLockerLimit(t *testing.T) {
+	now := time.Unix(1000, 0)
+	locker := newSpendLocker()
+	locker.now = func() time.Time { return now }
+
+	for nonce := uint64(0); nonce < maxSpendLocks; nonce++ {
+		if _, err := locker.acquire(nonceLockId(common.Address{}, nonce), "", time.Minute); err != nil {
+			t.Fatalf("lock %d: %v", nonce, err)
+		}
+	}
+	if _, err := locker.acquire([]byte("ota"), "", time.Minute); err != ErrTooManySpendLocks {
+		t.Fatalf("limit error mismatch: have %v, want %v", err, ErrTooManySpendLocks)
+	}
+	now = now.Add(time.Minute)
+	if _, err := locker.acquire([]byte("ota"), "", time.Minute); err != nil {
+		t.Fatalf("failed to lock after expiry: %v", err)
+	}
+	if n := len(locker.locks); n != 1 {
+		t.Errorf("expired locks not pruned: have %d locks, want 1", n)
+	}
+}
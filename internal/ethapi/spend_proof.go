@@ -0,0 +1,139 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package ethapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"errors"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/common/hexutil"
+	"github.com/wanchain/go-wanchain/core"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/core/vm"
+	"github.com/wanchain/go-wanchain/crypto"
+)
+
+// A spend proof shows that the holder of one of the OTAs of a ring performed a
+// given spend (privacy transaction or wancoin refund), without revealing which
+// OTA it is. It is a ring signature over the proven transaction hash and a
+// challenge message, made with the same ring and therefore carrying the same
+// key image as the spend itself.
+
+var (
+	ErrUnknownTransaction = errors.New("unknown transaction")
+	ErrNotRingSigned      = errors.New("transaction carries no ring signature")
+	ErrNotRingMember      = errors.New("private key doesn't belong to the ring of the spend")
+)
+
+// spendProofHash is the message signed by a spend proof.
+func spendProofHash(txHash common.Hash, message []byte) []byte {
+	return crypto.Keccak256([]byte("wanchain spend proof"), txHash.Bytes(), message)
+}
+
+// ringOf returns the ring members and key image of a ring signed transaction.
+func ringOf(tx *types.Transaction) ([]*ecdsa.PublicKey, *ecdsa.PublicKey, error) {
+	data, ok := core.RingSignedDataOf(tx)
+	if !ok {
+		return nil, nil, ErrNotRingSigned
+	}
+	err, publicKeys, keyImage, _, _ := vm.DecodeRingSignOut(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return publicKeys, keyImage, nil
+}
+
+// genSpendProof creates the spend proof of tx for the OTA private key.
+func genSpendProof(tx *types.Transaction, message []byte, privateKey *ecdsa.PrivateKey) (string, error) {
+	publicKeys, keyImage, err := ringOf(tx)
+	if err != nil {
+		return "", err
+	}
+
+	// The signer's public key must come first for the ring signature
+	own := crypto.FromECDSAPub(&privateKey.PublicKey)
+	ring := make([]*ecdsa.PublicKey, 0, len(publicKeys))
+	for _, pub := range publicKeys {
+		if bytes.Equal(crypto.FromECDSAPub(pub), own) {
+			ring = append([]*ecdsa.PublicKey{pub}, ring...)
+		} else {
+			ring = append(ring, pub)
+		}
+	}
+	if len(ring) == 0 || !bytes.Equal(crypto.FromECDSAPub(ring[0]), own) {
+		return "", ErrNotRingMember
+	}
+
+	pubs, image, w, q, err := crypto.RingSign(spendProofHash(tx.Hash(), message), privateKey.D, ring)
+	if err != nil {
+		return "", err
+	}
+	if !bytes.Equal(crypto.FromECDSAPub(image), crypto.FromECDSAPub(keyImage)) {
+		return "", ErrNotRingMember
+	}
+	return encodeRingSignOut(pubs, image, w, q)
+}
+
+// verifySpendProof checks a spend proof of tx against the challenge message.
+func verifySpendProof(tx *types.Transaction, message []byte, proof string) (bool, error) {
+	publicKeys, keyImage, err := ringOf(tx)
+	if err != nil {
+		return false, err
+	}
+	err, proofKeys, proofImage, w, q := vm.DecodeRingSignOut(proof)
+	if err != nil {
+		return false, err
+	}
+
+	// The proof must be made by the same ring, and by the same member
+	if !bytes.Equal(crypto.FromECDSAPub(proofImage), crypto.FromECDSAPub(keyImage)) {
+		return false, nil
+	}
+	if len(proofKeys) != len(publicKeys) {
+		return false, nil
+	}
+	members := make(map[string]int, len(publicKeys))
+	for _, pub := range publicKeys {
+		members[string(crypto.FromECDSAPub(pub))]++
+	}
+	for _, pub := range proofKeys {
+		key := string(crypto.FromECDSAPub(pub))
+		if members[key] == 0 {
+			return false, nil
+		}
+		members[key]--
+	}
+	return crypto.VerifyRingSign(spendProofHash(tx.Hash(), message), proofKeys, proofImage, w, q), nil
+}
+
+// GenSpendProof generates a proof that the holder of the given OTA private key
+// performed the ring signed transaction txHash. The message is a challenge
+// chosen by the verifier to prevent proofs from being replayed.
+func (s *PrivateAccountAPI) GenSpendProof(ctx context.Context, txHash common.Hash, message hexutil.Bytes, privateKey string) (string, error) {
+	if !hexutil.Has0xPrefix(privateKey) {
+		return "", ErrInvalidPrivateKey
+	}
+	key, err := crypto.HexToECDSA(privateKey[2:])
+	if err != nil {
+		return "", ErrInvalidPrivateKey
+	}
+
+	tx, _, _, _ := core.GetTransaction(s.b.ChainDb(), txHash)
+	if tx == nil {
+		return "", ErrUnknownTransaction
+	}
+	return genSpendProof(tx, message, key)
+}
+
+// CheckSpendProof verifies a spend proof generated by personal_genSpendProof
+// for the transaction txHash and the challenge message.
+func (s *PublicTransactionPoolAPI) CheckSpendProof(ctx context.Context, txHash common.Hash, message hexutil.Bytes, proof string) (bool, error) {
+	tx, _, _, _ := core.GetTransaction(s.b.ChainDb(), txHash)
+	if tx == nil {
+		return false, ErrUnknownTransaction
+	}
+	return verifySpendProof(tx, message, proof)
+}
@@ -0,0 +1,67 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package ethapi
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/crypto"
+)
+
+// newRingSignedTx creates a privacy transaction ring signed by the first key.
+func newRingSignedTx(t *testing.T, keys []*ecdsa.PrivateKey) *types.Transaction {
+	ring := make([]*ecdsa.PublicKey, len(keys))
+	for i, key := range keys {
+		ring[i] = &key.PublicKey
+	}
+	pubs, image, w, q, err := crypto.RingSign(crypto.Keccak256([]byte("spend")), keys[0].D, ring)
+	if err != nil {
+		t.Fatalf("failed to ring sign: %v", err)
+	}
+	ringSigned, _ := encodeRingSignOut(pubs, image, w, q)
+	data, err := core.TokenAbi.Pack("combine", ringSigned, []byte{})
+	if err != nil {
+		t.Fatalf("failed to pack privacy payload: %v", err)
+	}
+	return types.NewOTATransaction(0, common.Address{1}, new(big.Int), big.NewInt(100000), big.NewInt(1), data)
+}
+
+func TestSpendProof(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	tx := newRingSignedTx(t, keys)
+	message := []byte("invoice #42")
+
+	proof, err := genSpendProof(tx, message, keys[0])
+	if err != nil {
+		t.Fatalf("failed to generate spend proof: %v", err)
+	}
+	if ok, err := verifySpendProof(tx, message, proof); !ok || err != nil {
+		t.Fatalf("valid spend proof rejected: %v", err)
+	}
+	if ok, _ := verifySpendProof(tx, []byte("invoice #43"), proof); ok {
+		t.Errorf("spend proof accepted for another message")
+	}
+
+	// Other ring members can sign, but not with the key image of the spend
+	if _, err := genSpendProof(tx, message, keys[1]); err != ErrNotRingMember {
+		t.Errorf("spend proof by another member: have %v, want %v", err, ErrNotRingMember)
+	}
+	outsider, _ := crypto.GenerateKey()
+	if _, err := genSpendProof(tx, message, outsider); err != ErrNotRingMember {
+		t.Errorf("spend proof by an outsider: have %v, want %v", err, ErrNotRingMember)
+	}
+
+	// Proofs of other spends don't carry over
+	other := newRingSignedTx(t, keys)
+	if ok, _ := verifySpendProof(other, message, proof); ok {
+		t.Errorf("spend proof accepted for another transaction")
+	}
+}
@@ -30,6 +30,7 @@ var Modules = map[string]string{
 	"shh":        Shh_JS,
 	"swarmfs":    SWARMFS_JS,
 	"txpool":     TxPool_JS,
+	"wan":        Wan_JS,
 }
 
 const Chequebook_JS = `
@@ -129,6 +130,12 @@ web3._extend({
 			params: 1,
 			inputFormatter: [null]
 		}),
+		new web3._extend.Method({
+			name: 'exportSnapshot',
+			call: 'admin_exportSnapshot',
+			params: 1,
+			inputFormatter: [null]
+		}),
 		new web3._extend.Method({
 			name: 'importChain',
 			call: 'admin_importChain',
@@ -196,6 +203,23 @@ web3._extend({
 			call: 'debug_setHead',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'traceOtaSet',
+			call: 'debug_traceOtaSet',
+			params: 4,
+			inputFormatter: [null, null, web3._extend.formatters.inputBlockNumberFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'recordOtaSets',
+			call: 'debug_recordOtaSets',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'otaSetTraces',
+			call: 'debug_otaSetTraces',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
 		new web3._extend.Method({
 			name: 'traceBlock',
 			call: 'debug_traceBlock',
@@ -226,6 +250,12 @@ web3._extend({
 			call: 'debug_dumpBlock',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'dumpAccount',
+			call: 'debug_dumpAccount',
+			params: 4,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputBlockNumberFormatter, null, null]
+		}),
 		new web3._extend.Method({
 			name: 'chaindbProperty',
 			call: 'debug_chaindbProperty',
@@ -332,6 +362,36 @@ web3._extend({
 			call: 'debug_writeMemProfile',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'mutexProfile',
+			call: 'debug_mutexProfile',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'setMutexProfileFraction',
+			call: 'debug_setMutexProfileFraction',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'writeMutexProfile',
+			call: 'debug_writeMutexProfile',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'writeGoroutineProfile',
+			call: 'debug_writeGoroutineProfile',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'cpuProfileData',
+			call: 'debug_cpuProfileData',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'profileData',
+			call: 'debug_profileData',
+			params: 1
+		}),
 		new web3._extend.Method({
 			name: 'traceTransaction',
 			call: 'debug_traceTransaction',
@@ -344,6 +404,16 @@ web3._extend({
 			params: 1,
 			inputFormatter: [null]
 		}),
+		new web3._extend.Method({
+			name: 'vmProfile',
+			call: 'debug_vmProfile',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'resetVmProfile',
+			call: 'debug_resetVmProfile',
+			params: 0
+		}),
 		new web3._extend.Method({
 			name: 'getBadBlocks',
 			call: 'debug_getBadBlocks',
@@ -354,6 +424,17 @@ web3._extend({
 			call: 'debug_storageRangeAt',
 			params: 5,
 		}),
+		new web3._extend.Method({
+			name: 'chainStats',
+			call: 'debug_chainStats',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'storageUsageReport',
+			call: 'debug_storageUsageReport',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, null]
+		}),
 	],
 	properties: []
 });
@@ -369,12 +450,24 @@ web3._extend({
 			params: 2,
 			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null]
 		}),
+		new web3._extend.Method({
+			name: 'signTypedData',
+			call: 'eth_signTypedData',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null]
+		}),
 		new web3._extend.Method({
 			name: 'resend',
 			call: 'eth_resend',
 			params: 3,
 			inputFormatter: [web3._extend.formatters.inputTransactionFormatter, web3._extend.utils.fromDecimal, web3._extend.utils.fromDecimal]
 		}),
+		new web3._extend.Method({
+			name: 'repairNonces',
+			call: 'eth_repairNonces',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.utils.fromDecimal, null]
+		}),
 		new web3._extend.Method({
 			name: 'signTransaction',
 			call: 'eth_signTransaction',
@@ -402,6 +495,15 @@ web3._extend({
 		}),
 	],
 	properties: [
+		new web3._extend.Property({
+			name: 'maxPriorityFeePerGas',
+			getter: 'eth_maxPriorityFeePerGas',
+			outputFormatter: web3._extend.utils.toBigNumber
+		}),
+		new web3._extend.Property({
+			name: 'feeSuggestion',
+			getter: 'eth_feeSuggestion'
+		}),
 		new web3._extend.Property({
 			name: 'pendingTransactions',
 			getter: 'eth_pendingTransactions',
@@ -486,6 +588,12 @@ web3._extend({
 			params: 3,
 			inputFormatter: [null, web3._extend.formatters.inputAddressFormatter, null]
 		}),
+		new web3._extend.Method({
+			name: 'signTypedData',
+			call: 'personal_signTypedData',
+			params: 3,
+			inputFormatter: [null, web3._extend.formatters.inputAddressFormatter, null]
+		}),
 		new web3._extend.Method({
 			name: 'ecRecover',
 			call: 'personal_ecRecover',
@@ -501,12 +609,102 @@ web3._extend({
 			call: 'personal_deriveAccount',
 			params: 3
 		}),
+		new web3._extend.Method({
+			name: 'genSpendProof',
+			call: 'personal_genSpendProof',
+			params: 3
+		}),
+		new web3._extend.Method({
+			name: 'generateRetainedOneTimeAddress',
+			call: 'personal_generateRetainedOneTimeAddress',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputAddressFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'proveReceipt',
+			call: 'personal_proveReceipt',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'forgetReceipt',
+			call: 'personal_forgetReceipt',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'jointWanAddressProof',
+			call: 'personal_jointWanAddressProof',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'jointRingSignCommit',
+			call: 'personal_jointRingSignCommit',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, null]
+		}),
+		new web3._extend.Method({
+			name: 'jointRingSignReveal',
+			call: 'personal_jointRingSignReveal',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'jointRingSignChallenge',
+			call: 'personal_jointRingSignChallenge',
+			params: 5
+		}),
+		new web3._extend.Method({
+			name: 'jointRingSignRespond',
+			call: 'personal_jointRingSignRespond',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'jointRingSignComplete',
+			call: 'personal_jointRingSignComplete',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'auditLog',
+			call: 'personal_auditLog',
+			params: 2,
+			inputFormatter: [web3._extend.utils.fromDecimal, web3._extend.utils.fromDecimal]
+		}),
+		new web3._extend.Method({
+			name: 'verifyAuditLog',
+			call: 'personal_verifyAuditLog',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'lockSpend',
+			call: 'personal_lockSpend',
+			params: 3
+		}),
+		new web3._extend.Method({
+			name: 'unlockSpend',
+			call: 'personal_unlockSpend',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'lockNonce',
+			call: 'personal_lockNonce',
+			params: 4,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, null, null]
+		}),
+		new web3._extend.Method({
+			name: 'unlockNonce',
+			call: 'personal_unlockNonce',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, null]
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
 			name: 'listWallets',
 			getter: 'personal_listWallets'
 		}),
+		new web3._extend.Property({
+			name: 'spendLocks',
+			getter: 'personal_spendLocks'
+		}),
 	]
 })
 `
@@ -571,7 +769,14 @@ web3._extend({
 const TxPool_JS = `
 web3._extend({
 	property: 'txpool',
-	methods: [],
+	methods: [
+		new web3._extend.Method({
+			name: 'nonceGaps',
+			call: 'txpool_nonceGaps',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+		}),
+	],
 	properties:
 	[
 		new web3._extend.Property({
@@ -594,3 +799,90 @@ web3._extend({
 	]
 });
 `
+
+const Wan_JS = `
+web3._extend({
+	property: 'wan',
+	methods: [
+		new web3._extend.Method({
+			name: 'checkSpendProof',
+			call: 'wan_checkSpendProof',
+			params: 3
+		}),
+		new web3._extend.Method({
+			name: 'listDenominationOTAs',
+			call: 'wan_listDenominationOTAs',
+			params: 4,
+			inputFormatter: [web3._extend.utils.toHex, web3._extend.formatters.inputBlockNumberFormatter, null, null]
+		}),
+		new web3._extend.Method({
+			name: 'reconcile',
+			call: 'wan_reconcile',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputDefaultBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'proveKeyImageAbsence',
+			call: 'wan_proveKeyImageAbsence',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'checkKeyImageAbsence',
+			call: 'wan_checkKeyImageAbsence',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'checkReceiptProof',
+			call: 'wan_checkReceiptProof',
+			params: 3
+		}),
+		new web3._extend.Method({
+			name: 'generateJointWanAddress',
+			call: 'wan_generateJointWanAddress',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'signWithOTA',
+			call: 'wan_signWithOTA',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, null]
+		}),
+		new web3._extend.Method({
+			name: 'verifyOTASignature',
+			call: 'wan_verifyOTASignature',
+			params: 3
+		}),
+		new web3._extend.Method({
+			name: 'treasury',
+			call: 'wan_treasury',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'verifyStamp',
+			call: 'wan_verifyStamp',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'vesting',
+			call: 'wan_vesting',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'vestingBalance',
+			call: 'wan_vestingBalance',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+	],
+	properties: [
+		new web3._extend.Property({
+			name: 'deployments',
+			getter: 'wan_deployments'
+		}),
+	]
+});
+`
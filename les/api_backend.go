@@ -19,6 +19,7 @@ package les
 import (
 	"context"
 	"math/big"
+	"time"
 
 	"github.com/wanchain/go-wanchain/accounts"
 	"github.com/wanchain/go-wanchain/common"
@@ -121,6 +122,10 @@ func (b *LesApiBackend) Stats() (pending int, queued int) {
 	return b.eth.txPool.Stats(), 0
 }
 
+func (b *LesApiBackend) InclusionDelays() (normal []time.Duration, privacy []time.Duration) {
+	return nil, nil
+}
+
 func (b *LesApiBackend) TxPoolContent() (map[common.Address]types.Transactions, map[common.Address]types.Transactions) {
 	return b.eth.txPool.Content()
 }
@@ -173,6 +178,11 @@ func (b *LesApiBackend) AccountManager() *accounts.Manager {
 	return b.eth.accountManager
 }
 
+// PendingLogs returns nothing, light clients don't have a pending block.
+func (b *LesApiBackend) PendingLogs() []*types.Log {
+	return nil
+}
+
 func (b *LesApiBackend) BloomStatus() (uint64, uint64) {
 	return params.BloomBitsBlocks, 0
 }
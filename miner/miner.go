@@ -20,6 +20,7 @@ package miner
 import (
 	"fmt"
 	"sync/atomic"
+	"time"
 
 	"github.com/wanchain/go-wanchain/accounts"
 	"github.com/wanchain/go-wanchain/common"
@@ -163,6 +164,12 @@ func (self *Miner) SetExtra(extra []byte) error {
 	return nil
 }
 
+// SetMaxClockDrift sets the drift of the local clock above which the miner
+// refuses to seal blocks, 0 meaning unlimited.
+func (self *Miner) SetMaxClockDrift(drift time.Duration) {
+	self.worker.setMaxClockDrift(drift)
+}
+
 // Pending returns the currently pending block and associated state.
 func (self *Miner) Pending() (*types.Block, *state.StateDB) {
 	return self.worker.pending()
@@ -177,6 +184,13 @@ func (self *Miner) PendingBlock() *types.Block {
 	return self.worker.pendingBlock()
 }
 
+// PendingLogs returns the logs produced by the transactions of the pending
+// block, flagged as pending. They are speculative, the transactions may end up
+// in another block or not at all.
+func (self *Miner) PendingLogs() []*types.Log {
+	return self.worker.pendingLogs()
+}
+
 func (self *Miner) SetEtherbase(addr common.Address) {
 	self.coinbase = addr
 	self.worker.setEtherbase(addr)
@@ -34,6 +34,7 @@ import (
 	"github.com/wanchain/go-wanchain/ethdb"
 	"github.com/wanchain/go-wanchain/event"
 	"github.com/wanchain/go-wanchain/log"
+	"github.com/wanchain/go-wanchain/p2p/discover"
 	"github.com/wanchain/go-wanchain/params"
 	set "gopkg.in/fatih/set.v0"
 )
@@ -113,6 +114,7 @@ type worker struct {
 
 	coinbase common.Address
 	extra    []byte
+	maxDrift time.Duration // Clock drift above which sealing is refused, 0 if unlimited
 
 	currentMu sync.Mutex
 	current   *Work
@@ -175,6 +177,24 @@ func (self *worker) setExtra(extra []byte) {
 	self.extra = extra
 }
 
+func (self *worker) setMaxClockDrift(drift time.Duration) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.maxDrift = drift
+}
+
+// clockDrifting reports whether the drift of the local clock measured against
+// NTP exceeds the limit allowed for sealing. The estimate from the peers alone
+// never vetoes sealing, as remote nodes could skew it. The caller must hold
+// the lock.
+func (self *worker) clockDrifting() (time.Duration, bool) {
+	if self.maxDrift == 0 {
+		return 0, false
+	}
+	drift, known := discover.NTPClockDrift()
+	return drift, known && (drift > self.maxDrift || drift < -self.maxDrift)
+}
+
 func (self *worker) pending() (*types.Block, *state.StateDB) {
 	self.currentMu.Lock()
 	defer self.currentMu.Unlock()
@@ -205,6 +225,24 @@ func (self *worker) pendingBlock() *types.Block {
 	return self.current.Block
 }
 
+// pendingLogs returns copies of the logs produced by the transactions of the
+// pending block, flagged as pending.
+func (self *worker) pendingLogs() []*types.Log {
+	self.currentMu.Lock()
+	defer self.currentMu.Unlock()
+
+	var logs []*types.Log
+	for _, receipt := range self.current.receipts {
+		for _, l := range receipt.Logs {
+			cpy := new(types.Log)
+			*cpy = *l
+			cpy.Pending = true
+			logs = append(logs, cpy)
+		}
+	}
+	return logs
+}
+
 func (self *worker) start() {
 	self.mu.Lock()
 	defer self.mu.Unlock()
@@ -440,6 +478,9 @@ func (self *worker) commitNewWork() {
 		log.Error("Failed to prepare header for mining", "err", err)
 		return
 	}
+	// Signal the deployments in their signaling window
+	core.SetSignalBits(header, self.chain.SignalDeployments(parent.Header()))
+
 	// If we are care about TheDAO hard-fork check whether to override the extra-data or not
 	//if daoBlock := self.config.DAOForkBlock; daoBlock != nil {
 	//	// Check whether the block is among the fork extra-override range
@@ -496,6 +537,7 @@ func (self *worker) commitNewWork() {
 	//	delete(self.possibleUncles, hash)
 	//}
 	uncles := []*types.Header{}
+	core.ApplyKeyImageAcc(self.config, parent.Header(), header, work.state)
 	// Create the new block to seal with the consensus engine
 	if work.Block, err = self.engine.Finalize(self.chain, header, work.state, work.txs, uncles, work.receipts); err != nil {
 		log.Error("Failed to finalize block for sealing", "err", err)
@@ -503,6 +545,11 @@ func (self *worker) commitNewWork() {
 	}
 	// We only care about logging if we're actually mining.
 	if atomic.LoadInt32(&self.mining) == 1 {
+		// Sealed blocks would be mistimed, keep the pending block only
+		if drift, drifting := self.clockDrifting(); drifting {
+			log.Warn("Refusing to seal with drifting clock", "number", work.Block.Number(), "drift", drift, "limit", self.maxDrift)
+			return
+		}
 		log.Info("Commit new mining work", "number", work.Block.Number(), "txs", work.tcount, "uncles", len(uncles), "elapsed", common.PrettyDuration(time.Since(tstart)))
 		self.unconfirmed.Shift(work.Block.NumberU64() - 1)
 	}
@@ -526,6 +573,7 @@ func (self *worker) commitUncle(work *Work, uncle *types.Header) error {
 
 func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsByPriceAndNonce, bc *core.BlockChain, coinbase common.Address) {
 	gp := new(core.GasPool).AddGas(env.header.GasLimit)
+	privacyCap := core.NewPrivacyCap(env.config, env.header, bc.PrivacyCapActive(env.header))
 
 	var coalescedLogs []*types.Log
 
@@ -548,6 +596,13 @@ func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsB
 		//	txs.Pop()
 		//	continue
 		//}
+		// Skip the account if its privacy transaction would exceed the block cap,
+		// leaving room for the transactions of the other accounts
+		if !privacyCap.Fits(tx) {
+			log.Trace("Privacy transaction cap reached for current block", "sender", from)
+			txs.Pop()
+			continue
+		}
 		// Start executing the transaction
 		env.state.Prepare(tx.Hash(), common.Hash{}, env.tcount)
 
@@ -571,6 +626,7 @@ func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsB
 		case nil:
 			// Everything ok, collect the logs and shift in the next transaction from the same account
 			coalescedLogs = append(coalescedLogs, logs...)
+			privacyCap.Add(tx)
 			env.tcount++
 			txs.Shift()
 
@@ -590,6 +646,7 @@ func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsB
 		for i, l := range coalescedLogs {
 			cpy[i] = new(types.Log)
 			*cpy[i] = *l
+			cpy[i].Pending = true
 		}
 		go func(logs []*types.Log, tcount int) {
 			if len(logs) > 0 {
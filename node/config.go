@@ -24,9 +24,11 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/wanchain/go-wanchain/accounts"
 	"github.com/wanchain/go-wanchain/accounts/keystore"
+	"github.com/wanchain/go-wanchain/accounts/kms"
 	"github.com/wanchain/go-wanchain/accounts/usbwallet"
 	"github.com/wanchain/go-wanchain/common"
 	"github.com/wanchain/go-wanchain/crypto"
@@ -37,10 +39,13 @@ import (
 
 const (
 	datadirPrivateKey      = "nodekey"            // Path within the datadir to the node's private key
+	datadirKeyLinks        = "nodekey-links.json" // Path within the datadir to the links of the node key rotations
 	datadirDefaultKeyStore = "keystore"           // Path within the datadir to the keystore
 	datadirStaticNodes     = "static-nodes.json"  // Path within the datadir to the static node list
 	datadirTrustedNodes    = "trusted-nodes.json" // Path within the datadir to the trusted node list
 	datadirNodeDatabase    = "nodes"              // Path within the datadir to store the node infos
+	datadirPeerDatabase    = "peers"              // Path within the datadir to store the peer dial history
+	datadirScryptParams    = "scrypt.json"        // Path within the datadir to the tuned scrypt parameters
 )
 
 // Config represents a small collection of configuration values to fine tune the
@@ -78,10 +83,37 @@ type Config struct {
 	// is created by New and destroyed when the node is stopped.
 	KeyStoreDir string `toml:",omitempty"`
 
+	// KeyStores are additional key directories, e.g. a read-only shared one
+	// holding watch-only keys. New keys are always created in KeyStoreDir.
+	KeyStores []KeyStoreConfig `toml:",omitempty"`
+
 	// UseLightweightKDF lowers the memory and CPU requirements of the key store
 	// scrypt KDF at the expense of security.
 	UseLightweightKDF bool `toml:",omitempty"`
 
+	// ScryptTarget is the unlock latency the key store scrypt KDF parameters are
+	// tuned for, benchmarking the host on first run. The tuned parameters are
+	// never weaker than the standard ones, or the lightweight ones if
+	// UseLightweightKDF is set. Zero uses the standard or lightweight parameters.
+	ScryptTarget time.Duration `toml:",omitempty"`
+
+	// KeyCacheSize is the number of decrypted keys cached for KeyCacheTimeout,
+	// sparing the KDF when a key is repeatedly decrypted. Zero disables the cache.
+	KeyCacheSize    int           `toml:",omitempty"`
+	KeyCacheTimeout time.Duration `toml:",omitempty"`
+
+	// SignerAuditLog is the file recording the signing operations of the key
+	// stores into a hash-chained log. Relative paths are resolved relative to
+	// DataDir. An empty path disables the log.
+	SignerAuditLog string `toml:",omitempty"`
+
+	// KMSKeys are keys held by external key management services, signing with
+	// the services configured in the environment. See package accounts/kms.
+	KMSKeys []kms.KeyConfig `toml:",omitempty"`
+
+	// Health configures the /health and /ready endpoints.
+	Health HealthConfig
+
 	// NoUSB disables hardware wallet monitoring and connectivity.
 	NoUSB bool `toml:",omitempty"`
 
@@ -137,6 +169,15 @@ type Config struct {
 	WSExposeAll bool `toml:",omitempty"`
 }
 
+// KeyStoreConfig is an additional key directory along with the operations
+// allowed on its keys. Relative directories are resolved relative to the
+// current directory.
+type KeyStoreConfig struct {
+	Dir      string
+	ReadOnly bool `toml:",omitempty"` // Forbid creating, importing, updating and deleting keys
+	NoUnlock bool `toml:",omitempty"` // Forbid unlocking keys, signing requires the passphrase
+}
+
 // IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
 // account the set data folders as well as the designated platform we're currently
 // running on.
@@ -170,6 +211,14 @@ func (c *Config) NodeDB() string {
 	return c.resolvePath(datadirNodeDatabase)
 }
 
+// PeerDB returns the path to the peer dial quality database.
+func (c *Config) PeerDB() string {
+	if c.DataDir == "" {
+		return "" // ephemeral
+	}
+	return c.resolvePath(datadirPeerDatabase)
+}
+
 // DefaultIPCEndpoint returns the IPC path used by default.
 func DefaultIPCEndpoint(clientIdentifier string) string {
 	if clientIdentifier == "" {
@@ -394,9 +443,62 @@ func makeAccountManager(conf *Config) (*accounts.Manager, string, error) {
 	if err := os.MkdirAll(keydir, 0700); err != nil {
 		return nil, "", err
 	}
+	if conf.ScryptTarget > 0 {
+		var path string
+		if conf.DataDir != "" {
+			path = filepath.Join(conf.DataDir, datadirScryptParams)
+		}
+		params, err := keystore.LoadOrTuneScrypt(path, conf.ScryptTarget, conf.UseLightweightKDF)
+		if err != nil {
+			return nil, "", err
+		}
+		scryptN, scryptP = params.N, params.P
+	}
 	// Assemble the account manager and supported backends
-	backends := []accounts.Backend{
-		keystore.NewKeyStore(keydir, scryptN, scryptP),
+	keystores := []*keystore.KeyStore{keystore.NewKeyStore(keydir, scryptN, scryptP)}
+	for _, extra := range conf.KeyStores {
+		dir, err := filepath.Abs(extra.Dir)
+		if err != nil {
+			return nil, "", err
+		}
+		if dir == keydir {
+			return nil, "", fmt.Errorf("keystore %s configured twice", dir)
+		}
+		if !extra.ReadOnly {
+			if err := os.MkdirAll(dir, 0700); err != nil {
+				return nil, "", err
+			}
+		}
+		policy := keystore.Policy{ReadOnly: extra.ReadOnly, NoUnlock: extra.NoUnlock}
+		keystores = append(keystores, keystore.NewKeyStoreWithPolicy(dir, scryptN, scryptP, policy))
+	}
+	var auditLog *keystore.AuditLog
+	if path := conf.SignerAuditLog; path != "" {
+		if !filepath.IsAbs(path) && conf.DataDir != "" {
+			path = filepath.Join(conf.DataDir, path)
+		}
+		if auditLog, err = keystore.OpenAuditLog(path); err != nil {
+			return nil, "", err
+		}
+	}
+	backends := make([]accounts.Backend, 0, len(keystores))
+	for _, ks := range keystores {
+		if auditLog != nil {
+			ks.SetAuditLog(auditLog)
+		}
+		if conf.KeyCacheSize > 0 {
+			if err := ks.EnableKeyCache(conf.KeyCacheSize, conf.KeyCacheTimeout); err != nil {
+				return nil, "", err
+			}
+		}
+		backends = append(backends, ks)
+	}
+	if len(conf.KMSKeys) > 0 {
+		kmsBackend, err := kms.NewBackend(conf.KMSKeys, kms.NewProviders())
+		if err != nil {
+			return nil, "", err
+		}
+		backends = append(backends, kmsBackend)
 	}
 	if !conf.NoUSB {
 		// Start a USB hub for Ledger hardware wallets
@@ -21,6 +21,7 @@ import (
 	"os/user"
 	"path/filepath"
 	"runtime"
+	"time"
 
 	"github.com/wanchain/go-wanchain/p2p"
 	"github.com/wanchain/go-wanchain/p2p/nat"
@@ -35,11 +36,17 @@ const (
 
 // DefaultConfig contains reasonable default settings.
 var DefaultConfig = Config{
-	DataDir:     DefaultDataDir(),
-	HTTPPort:    DefaultHTTPPort,
-	HTTPModules: []string{"net", "web3"},
-	WSPort:      DefaultWSPort,
-	WSModules:   []string{"net", "web3"},
+	DataDir:         DefaultDataDir(),
+	KeyCacheTimeout: 5 * time.Minute,
+	HTTPPort:        DefaultHTTPPort,
+	HTTPModules:     []string{"net", "web3"},
+	WSPort:          DefaultWSPort,
+	WSModules:       []string{"net", "web3"},
+	Health: HealthConfig{
+		MinPeers:   1,
+		MaxHeadAge: 5 * time.Minute,
+		MaxLag:     32,
+	},
 	P2P: p2p.Config{
 		ListenAddr:      ":17717",
 		DiscoveryV5Addr: ":17718",
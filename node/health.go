@@ -0,0 +1,133 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/wanchain/go-wanchain/log"
+)
+
+// HealthConfig configures the health endpoint and the thresholds the node is
+// considered ready within.
+type HealthConfig struct {
+	// Addr is the interface and port of the /health and /ready HTTP endpoints, for
+	// container orchestrator probes and load balancer health checks. An empty
+	// address disables them.
+	Addr string `toml:",omitempty"`
+
+	MinPeers   int           // Peers needed to be ready
+	MaxHeadAge time.Duration // Age of the last imported block above which the node isn't ready
+	MaxLag     uint64        // Blocks the node or its indexes may lag behind and still be ready
+}
+
+// HealthCheck is the outcome of a check of the node health.
+//
+// A check failing liveness means the node is broken and should be restarted, a
+// check failing readiness only that it shouldn't be served requests yet, e.g.
+// while syncing.
+type HealthCheck struct {
+	Name   string      `json:"name"`
+	Live   bool        `json:"live"`
+	Ready  bool        `json:"ready"`
+	Detail interface{} `json:"detail,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// HealthReporter is implemented by the services contributing health checks.
+type HealthReporter interface {
+	HealthChecks(config *HealthConfig) []HealthCheck
+}
+
+// HealthStatus is the reply of the health endpoints.
+type HealthStatus struct {
+	Live   bool          `json:"live"`
+	Ready  bool          `json:"ready"`
+	Checks []HealthCheck `json:"checks"`
+}
+
+// Health runs the health checks of the node and of its services.
+func (n *Node) Health() *HealthStatus {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	status := &HealthStatus{Live: true, Ready: true}
+	if n.server == nil {
+		status.Live, status.Ready = false, false
+		status.Checks = append(status.Checks, HealthCheck{Name: "node", Error: ErrNodeStopped.Error()})
+		return status
+	}
+	config := &n.config.Health
+
+	peers := n.server.PeerCount()
+	status.Checks = append(status.Checks, HealthCheck{
+		Name:   "peers",
+		Live:   true,
+		Ready:  peers >= config.MinPeers,
+		Detail: peers,
+	})
+	for _, service := range n.services {
+		if reporter, ok := service.(HealthReporter); ok {
+			status.Checks = append(status.Checks, reporter.HealthChecks(config)...)
+		}
+	}
+	for _, check := range status.Checks {
+		status.Live = status.Live && check.Live
+		status.Ready = status.Ready && check.Live && check.Ready
+	}
+	return status
+}
+
+// healthHandler serves the /health liveness and /ready readiness endpoints,
+// replying 200 if the node passes the checks and 503 otherwise, along with the
+// details of the checks.
+func (n *Node) healthHandler() http.Handler {
+	mux := http.NewServeMux()
+	serve := func(ready bool) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			status := n.Health()
+
+			code := http.StatusOK
+			if (ready && !status.Ready) || (!ready && !status.Live) {
+				code = http.StatusServiceUnavailable
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.WriteHeader(code)
+			json.NewEncoder(w).Encode(status)
+		}
+	}
+	mux.Handle("/health", serve(false))
+	mux.Handle("/ready", serve(true))
+	return mux
+}
+
+// startHealth starts the health endpoints, if configured.
+func (n *Node) startHealth(endpoint string) error {
+	if endpoint == "" {
+		return nil
+	}
+	listener, err := net.Listen("tcp", endpoint)
+	if err != nil {
+		return err
+	}
+	go http.Serve(listener, n.healthHandler())
+	log.Info(fmt.Sprintf("Health endpoint opened: http://%s/health", listener.Addr()))
+
+	n.healthListener = listener
+	return nil
+}
+
+// stopHealth terminates the health endpoints.
+func (n *Node) stopHealth() {
+	if n.healthListener != nil {
+		n.healthListener.Close()
+		n.healthListener = nil
+
+		log.Info("Health endpoint closed")
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package node
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// healthService is a service reporting a configurable health check.
+type healthService struct {
+	NoopService
+	check HealthCheck
+}
+
+func (s *healthService) HealthChecks(config *HealthConfig) []HealthCheck {
+	return []HealthCheck{s.check}
+}
+
+// Tests that the liveness and readiness endpoints reflect the checks of the node
+// and of its services.
+func TestHealthEndpoints(t *testing.T) {
+	config := testNodeConfig()
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	service := &healthService{check: HealthCheck{Name: "test", Live: true, Ready: true}}
+	if err := stack.Register(func(*ServiceContext) (Service, error) { return service, nil }); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+	server := httptest.NewServer(stack.healthHandler())
+	defer server.Close()
+
+	probe := func(path string) (int, *HealthStatus) {
+		res, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("failed to probe %s: %v", path, err)
+		}
+		defer res.Body.Close()
+
+		status := new(HealthStatus)
+		if err := json.NewDecoder(res.Body).Decode(status); err != nil {
+			t.Fatalf("failed to decode %s reply: %v", path, err)
+		}
+		return res.StatusCode, status
+	}
+	// A stopped node is neither live nor ready
+	if code, _ := probe("/health"); code != http.StatusServiceUnavailable {
+		t.Errorf("stopped node liveness mismatch: have %d, want %d", code, http.StatusServiceUnavailable)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	defer stack.Stop()
+
+	tests := []struct {
+		minPeers    int
+		live, ready bool
+		health      int
+		readiness   int
+	}{
+		{0, true, true, http.StatusOK, http.StatusOK},
+		{1, true, true, http.StatusOK, http.StatusServiceUnavailable}, // no peers
+		{0, true, false, http.StatusOK, http.StatusServiceUnavailable},
+		{0, false, true, http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+	}
+	for i, tt := range tests {
+		stack.config.Health.MinPeers = tt.minPeers
+		service.check.Live, service.check.Ready = tt.live, tt.ready
+
+		if code, status := probe("/health"); code != tt.health || status.Live != (code == http.StatusOK) {
+			t.Errorf("test %d: liveness mismatch: have %d (%+v), want %d", i, code, status, tt.health)
+		}
+		code, status := probe("/ready")
+		if code != tt.readiness || status.Ready != (code == http.StatusOK) {
+			t.Errorf("test %d: readiness mismatch: have %d (%+v), want %d", i, code, status, tt.readiness)
+		}
+		if len(status.Checks) != 2 || status.Checks[0].Name != "peers" || status.Checks[1].Name != "test" {
+			t.Errorf("test %d: checks mismatch: %+v", i, status.Checks)
+		}
+	}
+}
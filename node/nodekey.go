@@ -0,0 +1,120 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/crypto"
+	"github.com/wanchain/go-wanchain/log"
+	"github.com/wanchain/go-wanchain/p2p/discover"
+)
+
+// NodeKeyLinks returns the links of the rotations of the node key stored in
+// the data folder, nil if the key was never rotated or the links don't lead
+// to the current key.
+func (c *Config) NodeKeyLinks() []*discover.KeyLink {
+	if c.DataDir == "" || c.P2P.PrivateKey != nil {
+		return nil
+	}
+	links, err := c.loadKeyLinks()
+	if err != nil {
+		log.Error("Failed to load node key links", "err", err)
+		return nil
+	}
+	if len(links) == 0 {
+		return nil
+	}
+	key, err := crypto.LoadECDSA(c.resolvePath(datadirPrivateKey))
+	if err != nil {
+		return nil
+	}
+	links = trimKeyLinks(links, discover.PubkeyID(&key.PublicKey))
+
+	if last, err := discover.VerifyKeyLinks(links); err != nil || last != discover.PubkeyID(&key.PublicKey) {
+		log.Error("Node key links don't lead to the node key, not announced", "err", err)
+		return nil
+	}
+	return links
+}
+
+// RotateNodeKey replaces the node key stored in the data folder with a newly
+// generated one, recording the link from the old key to the new one. The node
+// must not be running.
+func (c *Config) RotateNodeKey() (*discover.KeyLink, error) {
+	if c.P2P.PrivateKey != nil {
+		return nil, errors.New("node key configured manually")
+	}
+	if c.DataDir == "" {
+		return nil, errors.New("no data directory holding the node key")
+	}
+	keyfile := c.resolvePath(datadirPrivateKey)
+	old, err := crypto.LoadECDSA(keyfile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load node key: %v", err)
+	}
+	links, err := c.loadKeyLinks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load node key links: %v", err)
+	}
+	id := discover.PubkeyID(&old.PublicKey)
+	if links = trimKeyLinks(links, id); len(links) > 0 && links[len(links)-1].New != id {
+		return nil, fmt.Errorf("node key %x not the last linked one", id[:8])
+	}
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	link, err := discover.NewKeyLink(uint64(len(links)+1), uint64(time.Now().Unix()), old, key)
+	if err != nil {
+		return nil, err
+	}
+	// Record the link before replacing the key: a link to a key never saved is
+	// dropped on the next rotation, a key without its link breaks the chain
+	blob, err := json.MarshalIndent(append(links, link), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	linkfile := c.resolvePath(datadirKeyLinks)
+	if err := ioutil.WriteFile(linkfile+".tmp", blob, 0600); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(linkfile+".tmp", linkfile); err != nil {
+		return nil, err
+	}
+	if err := crypto.SaveECDSA(keyfile+".tmp", key); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(keyfile+".tmp", keyfile); err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+// loadKeyLinks loads the node key links from the data folder, if any.
+func (c *Config) loadKeyLinks() ([]*discover.KeyLink, error) {
+	path := c.resolvePath(datadirKeyLinks)
+	if !common.FileExist(path) {
+		return nil, nil
+	}
+	var links []*discover.KeyLink
+	if err := common.LoadJSON(path, &links); err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+// trimKeyLinks drops the last link if it starts from the current key, left by
+// a rotation interrupted before the new key was saved.
+func trimKeyLinks(links []*discover.KeyLink, current discover.NodeID) []*discover.KeyLink {
+	if n := len(links); n > 0 && links[n-1].New != current && links[n-1].Old == current {
+		return links[:n-1]
+	}
+	return links
+}
@@ -0,0 +1,68 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package node
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/crypto"
+	"github.com/wanchain/go-wanchain/p2p/discover"
+)
+
+// Tests that rotating the node key replaces it, chaining the links from the
+// first node identity to the current one, and that the link of a rotation
+// interrupted before the new key was saved is dropped.
+func TestNodeKeyRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "node-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary data directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := &Config{Name: "unit-test", DataDir: dir}
+	first := discover.PubkeyID(&config.NodeKey().PublicKey)
+	if links := config.NodeKeyLinks(); links != nil {
+		t.Fatalf("links of a key never rotated: %v", links)
+	}
+	for i := 1; i <= 2; i++ {
+		old := discover.PubkeyID(&config.NodeKey().PublicKey)
+		link, err := config.RotateNodeKey()
+		if err != nil {
+			t.Fatalf("rotation %d: failed to rotate node key: %v", i, err)
+		}
+		current := discover.PubkeyID(&config.NodeKey().PublicKey)
+		if link.Seq != uint64(i) || link.Old != old || link.New != current {
+			t.Fatalf("rotation %d: link mismatch: %+v", i, link)
+		}
+	}
+	links := config.NodeKeyLinks()
+	if len(links) != 2 || links[0].Old != first || links[1].New != discover.PubkeyID(&config.NodeKey().PublicKey) {
+		t.Fatalf("links mismatch: %v", links)
+	}
+	// Restore the key replaced by a rotation, as if it was interrupted
+	keyfile := config.resolvePath(datadirPrivateKey)
+	blob, _ := ioutil.ReadFile(keyfile)
+	if _, err := config.RotateNodeKey(); err != nil {
+		t.Fatalf("failed to rotate node key: %v", err)
+	}
+	if err := ioutil.WriteFile(keyfile, blob, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if links := config.NodeKeyLinks(); len(links) != 2 {
+		t.Fatalf("interrupted rotation link announced: have %d links, want 2", len(links))
+	}
+	link, err := config.RotateNodeKey()
+	if err != nil {
+		t.Fatalf("failed to rotate node key after an interrupted rotation: %v", err)
+	}
+	if link.Seq != 3 || len(config.NodeKeyLinks()) != 3 {
+		t.Fatalf("rotation after an interrupted one mismatch: %+v", link)
+	}
+	// A manually configured key is not rotated
+	config.P2P.PrivateKey, _ = crypto.GenerateKey()
+	if _, err := config.RotateNodeKey(); err == nil {
+		t.Fatalf("manually configured node key rotated")
+	}
+}
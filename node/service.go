@@ -17,6 +17,7 @@
 package node
 
 import (
+	"crypto/ecdsa"
 	"reflect"
 
 	"github.com/wanchain/go-wanchain/accounts"
@@ -31,6 +32,7 @@ import (
 // as well as utility methods to operate on the service environment.
 type ServiceContext struct {
 	config         *Config
+	nodeKey        *ecdsa.PrivateKey        // Private key of the node, identifying it to its peers
 	services       map[reflect.Type]Service // Index of the already constructed services
 	EventMux       *event.TypeMux           // Event multiplexer used for decoupled notifications
 	AccountManager *accounts.Manager        // Account manager created by the node.
@@ -57,6 +59,12 @@ func (ctx *ServiceContext) ResolvePath(path string) string {
 	return ctx.config.resolvePath(path)
 }
 
+// NodeKey returns the private key of the node, for the services to sign the
+// data vouched for by the node. It is nil for nodes without a p2p server.
+func (ctx *ServiceContext) NodeKey() *ecdsa.PrivateKey {
+	return ctx.nodeKey
+}
+
 // Service retrieves a currently running service registered of a specific type.
 func (ctx *ServiceContext) Service(service interface{}) error {
 	element := reflect.ValueOf(service).Elem()
@@ -38,6 +38,10 @@ const (
 	// once every few seconds.
 	lookupInterval = 4 * time.Second
 
+	// The best known peers are reloaded from the peer database
+	// once every few minutes.
+	knownPeersInterval = 5 * time.Minute
+
 	// If no peers are found for this amount of time, the initial bootnodes are
 	// attempted to be connected.
 	fallbackInterval = 20 * time.Second
@@ -80,6 +84,10 @@ type dialstate struct {
 	static        map[discover.NodeID]*dialTask
 	hist          *dialHistory
 
+	pdb       *peerDB          // dial quality history, nil if disabled
+	known     []*discover.Node // best known peers not yet tried
+	lastKnown time.Time        // time when the known peers were last loaded
+
 	start     time.Time        // time when the dialer was first used
 	bootnodes []*discover.Node // default dials when there are no peers
 }
@@ -212,6 +220,21 @@ func (s *dialstate) newTasks(nRunning int, peers map[discover.NodeID]*Peer, now
 			needDynDials--
 		}
 	}
+	// Redial the best peers known from past sessions first, so the node
+	// reconverges to good peers quickly after a restart.
+	if s.pdb != nil && needDynDials > 0 {
+		if len(s.known) == 0 && now.Sub(s.lastKnown) > knownPeersInterval {
+			s.known = s.pdb.best(s.maxDynDials, now)
+			s.lastKnown = now
+		}
+		i := 0
+		for ; i < len(s.known) && needDynDials > 0; i++ {
+			if addDial(dynDialedConn, s.known[i]) {
+				needDynDials--
+			}
+		}
+		s.known = s.known[i:]
+	}
 	// Use random nodes from the table for half of the necessary
 	// dynamic dials.
 	randomCandidates := needDynDials / 2
@@ -279,6 +302,9 @@ func (s *dialstate) taskDone(t task, now time.Time) {
 	case *dialTask:
 		s.hist.add(t.dest.ID, now.Add(dialHistoryExpiration))
 		delete(s.dialing, t.dest.ID)
+		if s.pdb != nil && t.flags&dynDialedConn != 0 {
+			s.pdb.dialed(t.dest, now)
+		}
 	case *discoverTask:
 		s.lookupRunning = false
 		s.lookupBuf = append(s.lookupBuf, t.results...)
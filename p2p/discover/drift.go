@@ -0,0 +1,159 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package discover
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wanchain/go-wanchain/log"
+	"github.com/wanchain/go-wanchain/metrics"
+)
+
+const (
+	peerDriftSamples    = 64               // Number of peer IPs whose reported timestamps estimate the drift
+	peerDriftMinSamples = 8                // Peer timestamps needed before estimating the drift
+	ntpDriftLifetime    = time.Hour        // Time after which the NTP measurement is superseded by the peers
+	ntpCheckInterval    = 30 * time.Minute // Interval of the periodic NTP checks
+)
+
+var (
+	peerDriftGauge = metrics.NewGauge("p2p/clock/drift/peers") // Median drift from the peers, in milliseconds
+	ntpDriftGauge  = metrics.NewGauge("p2p/clock/drift/ntp")   // Drift from the NTP server, in milliseconds
+	driftAlarms    = metrics.NewCounter("p2p/clock/drift/alarms")
+)
+
+// localDrift tracks the drift of the local clock, measured against an NTP server
+// and against the timestamps reported by the bonded peers in their discovery
+// packets, one sample per IP so that a host can't outweigh the others.
+var localDrift = newDriftTracker()
+
+// ClockDrift returns the estimated drift of the local clock, positive if it is
+// ahead. The estimate comes from the latest NTP measurement if recent, or from
+// the peer reported timestamps otherwise. Known is false until either is
+// available.
+func ClockDrift() (drift time.Duration, known bool) {
+	return localDrift.estimate(time.Now())
+}
+
+// NTPClockDrift returns the drift of the local clock measured against the NTP
+// server, known being false unless measured recently. Unlike the peer estimate
+// it can't be skewed by remote nodes, so it is the one to act upon.
+func NTPClockDrift() (drift time.Duration, known bool) {
+	return localDrift.ntpEstimate(time.Now())
+}
+
+type driftTracker struct {
+	lock    sync.Mutex
+	peers   map[string]peerDrift // Latest drift from the peer timestamps, by IP
+	ntp     time.Duration        // Latest drift measured against the NTP server
+	ntpTime time.Time            // Time of the latest NTP measurement
+	alarm   time.Time            // Time of the latest drift warning
+}
+
+// peerDrift is the drift implied by the latest timestamp of a peer IP.
+type peerDrift struct {
+	drift time.Duration
+	time  time.Time
+}
+
+func newDriftTracker() *driftTracker {
+	return &driftTracker{peers: make(map[string]peerDrift)}
+}
+
+// addPeer records the drift implied by the expiration of a packet received at
+// now from ip, which the remote peer set to its own time plus the expiration
+// window. The timestamps have a one second resolution, so individual samples
+// are rough, but their median isn't. Only the latest sample of an IP is kept,
+// the oldest IP being dropped once there are enough.
+func (t *driftTracker) addPeer(ip net.IP, exp uint64, now time.Time) {
+	drift := now.Sub(time.Unix(int64(exp), 0).Add(-expiration))
+
+	t.lock.Lock()
+	key := ip.String()
+	if _, ok := t.peers[key]; !ok && len(t.peers) >= peerDriftSamples {
+		var oldest string
+		for k, sample := range t.peers {
+			if oldest == "" || sample.time.Before(t.peers[oldest].time) {
+				oldest = k
+			}
+		}
+		delete(t.peers, oldest)
+	}
+	t.peers[key] = peerDrift{drift: drift, time: now}
+	t.lock.Unlock()
+
+	t.check(now)
+}
+
+// addNTP records a drift measured against the NTP server.
+func (t *driftTracker) addNTP(drift time.Duration, now time.Time) {
+	t.lock.Lock()
+	t.ntp, t.ntpTime = drift, now
+	t.lock.Unlock()
+
+	ntpDriftGauge.Update(int64(drift / time.Millisecond))
+	t.check(now)
+}
+
+// peerMedian returns the median of the peer drifts, if there are enough.
+// The caller must hold the lock.
+func (t *driftTracker) peerMedian() (time.Duration, bool) {
+	if len(t.peers) < peerDriftMinSamples {
+		return 0, false
+	}
+	sorted := make([]time.Duration, 0, len(t.peers))
+	for _, sample := range t.peers {
+		sorted = append(sorted, sample.drift)
+	}
+	sort.Sort(durationSlice(sorted))
+	return sorted[len(sorted)/2], true
+}
+
+func (t *driftTracker) estimate(now time.Time) (time.Duration, bool) {
+	if drift, ok := t.ntpEstimate(now); ok {
+		return drift, true
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.peerMedian()
+}
+
+func (t *driftTracker) ntpEstimate(now time.Time) (time.Duration, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if !t.ntpTime.IsZero() && now.Sub(t.ntpTime) < ntpDriftLifetime {
+		return t.ntp, true
+	}
+	return 0, false
+}
+
+// check updates the metrics and warns the user if the estimated drift exceeds
+// the threshold, at most once per cooldown period.
+func (t *driftTracker) check(now time.Time) {
+	t.lock.Lock()
+	median, ok := t.peerMedian()
+	t.lock.Unlock()
+	if ok {
+		peerDriftGauge.Update(int64(median / time.Millisecond))
+	}
+	drift, ok := t.estimate(now)
+	if !ok || (drift >= -driftThreshold && drift <= driftThreshold) {
+		return
+	}
+	driftAlarms.Inc(1)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if now.Sub(t.alarm) < ntpWarningCooldown {
+		return
+	}
+	t.alarm = now
+	log.Warn(fmt.Sprintf("System clock seems off by %v, which can prevent network connectivity", drift))
+	log.Warn("Please enable network time synchronisation in system settings.")
+}
@@ -0,0 +1,68 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package discover
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// Tests that the clock drift is estimated from the median of the peer reported
+// timestamps, unless superseded by a recent NTP measurement.
+func TestDriftTracker(t *testing.T) {
+	var (
+		tracker = newDriftTracker()
+		now     = time.Unix(1500000000, 0)
+	)
+	ip := func(i int) net.IP { return net.IPv4(10, 0, byte(i>>8), byte(i)) }
+
+	// Peers whose clocks are 30s behind, plus a couple of outliers
+	for i := 0; i < peerDriftMinSamples-1; i++ {
+		tracker.addPeer(ip(i), uint64(now.Add(expiration-30*time.Second).Unix()), now)
+	}
+	if _, known := tracker.estimate(now); known {
+		t.Fatalf("drift estimated from too few samples")
+	}
+	tracker.addPeer(ip(100), uint64(now.Add(expiration+time.Hour).Unix()), now)
+	tracker.addPeer(ip(101), uint64(now.Add(expiration).Unix()), now)
+
+	if drift, known := tracker.estimate(now); !known || drift != 30*time.Second {
+		t.Errorf("peer drift mismatch: have %v (known %v), want %v", drift, known, 30*time.Second)
+	}
+	if tracker.alarm != now {
+		t.Errorf("drift alarm not raised")
+	}
+	// The peers alone don't count as a measured drift
+	if _, known := tracker.ntpEstimate(now); known {
+		t.Errorf("peer drift reported as measured")
+	}
+	// A recent NTP measurement takes precedence over the peers
+	tracker.addNTP(-time.Second, now)
+	if drift, _ := tracker.estimate(now.Add(ntpDriftLifetime - time.Minute)); drift != -time.Second {
+		t.Errorf("ntp drift mismatch: have %v, want %v", drift, -time.Second)
+	}
+	if drift, known := tracker.ntpEstimate(now.Add(ntpDriftLifetime - time.Minute)); !known || drift != -time.Second {
+		t.Errorf("measured drift mismatch: have %v (known %v), want %v", drift, known, -time.Second)
+	}
+	if drift, _ := tracker.estimate(now.Add(ntpDriftLifetime)); drift != 30*time.Second {
+		t.Errorf("stale ntp drift used: have %v", drift)
+	}
+	// A single IP only ever counts once
+	for i := 0; i < peerDriftSamples; i++ {
+		tracker.addPeer(ip(200), uint64(now.Add(expiration-time.Hour).Unix()), now)
+	}
+	if drift, _ := tracker.estimate(now.Add(ntpDriftLifetime)); drift != 30*time.Second {
+		t.Errorf("peer drift skewed by a single IP: have %v", drift)
+	}
+	// Only the latest IPs are kept
+	for i := 0; i < peerDriftSamples; i++ {
+		tracker.addPeer(ip(1000+i), uint64(now.Add(expiration).Unix()), now.Add(time.Second))
+	}
+	if len(tracker.peers) != peerDriftSamples {
+		t.Errorf("sample count mismatch: have %d, want %d", len(tracker.peers), peerDriftSamples)
+	}
+	if drift, _ := tracker.estimate(now.Add(ntpDriftLifetime)); drift != time.Second {
+		t.Errorf("peer drift mismatch after refill: have %v, want %v", drift, time.Second)
+	}
+}
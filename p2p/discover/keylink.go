@@ -0,0 +1,91 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package discover
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+
+	"github.com/wanchain/go-wanchain/common/hexutil"
+	"github.com/wanchain/go-wanchain/crypto"
+	"github.com/wanchain/go-wanchain/rlp"
+)
+
+// keyLinkPrefix separates the key link signatures from the other uses of the
+// node keys.
+var keyLinkPrefix = []byte("wanchain node key link")
+
+var (
+	errKeyLinkOldSig = errors.New("key link not signed by the old key")
+	errKeyLinkNewSig = errors.New("key link not signed by the new key")
+)
+
+// KeyLink records the rotation of a node key: the node identifiers before and
+// after, signed by both keys. Following a chain of links, peers and monitoring
+// systems track a node across its key rotations.
+type KeyLink struct {
+	Seq    uint64        `json:"seq"`    // Number of the rotation, from 1
+	Time   uint64        `json:"time"`   // Unix time of the rotation
+	Old    NodeID        `json:"old"`    // Node identifier before the rotation
+	New    NodeID        `json:"new"`    // Node identifier after the rotation
+	OldSig hexutil.Bytes `json:"oldSig"` // Signature of the link by the old key
+	NewSig hexutil.Bytes `json:"newSig"` // Signature of the link by the new key
+}
+
+// NewKeyLink creates the link of the rotation from the old to the new key.
+func NewKeyLink(seq, time uint64, old, new *ecdsa.PrivateKey) (*KeyLink, error) {
+	link := &KeyLink{
+		Seq:  seq,
+		Time: time,
+		Old:  PubkeyID(&old.PublicKey),
+		New:  PubkeyID(&new.PublicKey),
+	}
+	var err error
+	if link.OldSig, err = crypto.Sign(link.sigHash(), old); err != nil {
+		return nil, err
+	}
+	if link.NewSig, err = crypto.Sign(link.sigHash(), new); err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+// sigHash returns the hash signed by both keys.
+func (l *KeyLink) sigHash() []byte {
+	blob, _ := rlp.EncodeToBytes([]interface{}{l.Seq, l.Time, l.Old, l.New})
+	return crypto.Keccak256(keyLinkPrefix, blob)
+}
+
+// Verify checks the link is signed by both keys.
+func (l *KeyLink) Verify() error {
+	if id, err := recoverNodeID(l.sigHash(), l.OldSig); err != nil || id != l.Old {
+		return errKeyLinkOldSig
+	}
+	if id, err := recoverNodeID(l.sigHash(), l.NewSig); err != nil || id != l.New {
+		return errKeyLinkNewSig
+	}
+	return nil
+}
+
+// VerifyKeyLinks checks a chain of key links, each valid, numbered in sequence
+// and starting from the node identifier the previous one ended with. It returns
+// the node identifier the chain ends with.
+func VerifyKeyLinks(links []*KeyLink) (NodeID, error) {
+	var last NodeID
+	for i, link := range links {
+		if err := link.Verify(); err != nil {
+			return NodeID{}, fmt.Errorf("key link %d: %v", link.Seq, err)
+		}
+		if i > 0 {
+			if link.Seq != links[i-1].Seq+1 {
+				return NodeID{}, fmt.Errorf("key link %d follows %d", link.Seq, links[i-1].Seq)
+			}
+			if link.Old != last {
+				return NodeID{}, fmt.Errorf("key link %d starts from %x, want %x", link.Seq, link.Old[:8], last[:8])
+			}
+		}
+		last = link.New
+	}
+	return last, nil
+}
@@ -0,0 +1,53 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package discover
+
+import (
+	"crypto/ecdsa"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/crypto"
+	"github.com/wanchain/go-wanchain/rlp"
+)
+
+// Tests that chains of key links are verified: signed by both keys, numbered
+// in sequence and linking each node identity to the next.
+func TestKeyLinks(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 4)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	links := make([]*KeyLink, 3)
+	for i := range links {
+		link, err := NewKeyLink(uint64(i+1), 1500000000, keys[i], keys[i+1])
+		if err != nil {
+			t.Fatalf("failed to create key link: %v", err)
+		}
+		links[i] = link
+	}
+	if last, err := VerifyKeyLinks(links); err != nil || last != PubkeyID(&keys[3].PublicKey) {
+		t.Fatalf("valid chain rejected: %v", err)
+	}
+	// Links survive the handshake encoding
+	blob, _ := rlp.EncodeToBytes(links[1])
+	decoded := new(KeyLink)
+	if err := rlp.DecodeBytes(blob, decoded); err != nil {
+		t.Fatalf("failed to decode key link: %v", err)
+	}
+	if err := decoded.Verify(); err != nil {
+		t.Fatalf("decoded key link invalid: %v", err)
+	}
+	// Tampered, reordered and disconnected chains are rejected
+	forged := *links[1]
+	forged.New = PubkeyID(&keys[0].PublicKey)
+	if err := forged.Verify(); err != errKeyLinkOldSig {
+		t.Errorf("forged link error mismatch: have %v, want %v", err, errKeyLinkOldSig)
+	}
+	if _, err := VerifyKeyLinks([]*KeyLink{links[0], links[2]}); err == nil {
+		t.Errorf("chain with a missing link accepted")
+	}
+	skipped, _ := NewKeyLink(2, 1500000000, keys[2], keys[3])
+	if _, err := VerifyKeyLinks([]*KeyLink{links[0], skipped}); err == nil {
+		t.Errorf("disconnected chain accepted")
+	}
+}
@@ -20,7 +20,6 @@
 package discover
 
 import (
-	"fmt"
 	"net"
 	"sort"
 	"time"
@@ -41,19 +40,15 @@ func (s durationSlice) Len() int           { return len(s) }
 func (s durationSlice) Less(i, j int) bool { return s[i] < s[j] }
 func (s durationSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
 
-// checkClockDrift queries an NTP server for clock drifts and warns the user if
-// one large enough is detected.
+// checkClockDrift queries an NTP server for clock drifts and records it, warning
+// the user if one large enough is detected.
 func checkClockDrift() {
 	drift, err := sntpDrift(ntpChecks)
 	if err != nil {
 		return
 	}
-	if drift < -driftThreshold || drift > driftThreshold {
-		log.Warn(fmt.Sprintf("System clock seems off by %v, which can prevent network connectivity", drift))
-		log.Warn("Please enable network time synchronisation in system settings.")
-	} else {
-		log.Debug("NTP sanity check done", "drift", drift)
-	}
+	log.Debug("NTP sanity check done", "drift", drift)
+	localDrift.addNTP(drift, time.Now())
 }
 
 // sntpDrift does a naive time resolution against an NTP server and returns the
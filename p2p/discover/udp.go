@@ -354,9 +354,11 @@ func (t *udp) loop() {
 		nextTimeout  *pending // head of plist when timeout was last reset
 		contTimeouts = 0      // number of continuous timeouts to do NTP checks
 		ntpWarnTime  = time.Unix(0, 0)
+		ntpCheck     = time.NewTicker(ntpCheckInterval)
 	)
 	<-timeout.C // ignore first timeout
 	defer timeout.Stop()
+	defer ntpCheck.Stop()
 
 	resetTimeout := func() {
 		if plist.Front() == nil || nextTimeout == plist.Front().Value {
@@ -434,6 +436,10 @@ func (t *udp) loop() {
 				}
 				contTimeouts = 0
 			}
+
+		case <-ntpCheck.C:
+			// Measure the drift periodically, the peers alone can't be trusted
+			go checkClockDrift()
 		}
 	}
 }
@@ -580,6 +586,10 @@ func (req *ping) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byte) er
 	if expired(req.Expiration) {
 		return errExpired
 	}
+	if t.db.node(fromID) != nil {
+		// Only bonded peers are trusted with the clock estimate
+		localDrift.addPeer(from.IP, req.Expiration, time.Now())
+	}
 	t.send(from, pongPacket, &pong{
 		To:         makeEndpoint(from, req.From.TCP),
 		ReplyTok:   mac,
@@ -603,6 +613,9 @@ func (req *pong) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byte) er
 	if !t.handleReply(fromID, pongPacket, req) {
 		return errUnsolicitedReply
 	}
+	if t.db.node(fromID) != nil {
+		localDrift.addPeer(from.IP, req.Expiration, time.Now())
+	}
 	return nil
 }
 
@@ -415,7 +415,8 @@ type PeerInfo struct {
 		LocalAddress  string `json:"localAddress"`  // Local endpoint of the TCP data connection
 		RemoteAddress string `json:"remoteAddress"` // Remote endpoint of the TCP data connection
 	} `json:"network"`
-	Protocols map[string]interface{} `json:"protocols"` // Sub-protocol specific metadata fields
+	Protocols map[string]interface{} `json:"protocols"`         // Sub-protocol specific metadata fields
+	KeyLink   *discover.KeyLink      `json:"keyLink,omitempty"` // Last rotation of the node key, if announced
 }
 
 // Info gathers and returns a collection of metadata known about a peer.
@@ -431,6 +432,7 @@ func (p *Peer) Info() *PeerInfo {
 		Name:      p.Name(),
 		Caps:      caps,
 		Protocols: make(map[string]interface{}),
+		KeyLink:   p.rw.link,
 	}
 	info.Network.LocalAddress = p.LocalAddr().String()
 	info.Network.RemoteAddress = p.RemoteAddr().String()
@@ -0,0 +1,208 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package p2p
+
+import (
+	"math"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/errors"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+	"github.com/wanchain/go-wanchain/log"
+	"github.com/wanchain/go-wanchain/p2p/discover"
+	"github.com/wanchain/go-wanchain/rlp"
+)
+
+const (
+	peerRecordExpiry  = 30 * 24 * time.Hour // Time after which an untouched peer record is dropped
+	peerScoreHalfLife = 7 * 24 * time.Hour  // Time after which the score of an unseen peer halves
+	maxPeerRecords    = 1000                // Number of peer records kept, the best scored ones
+)
+
+// peerRecord is the dial quality history of a peer.
+type peerRecord struct {
+	IP       net.IP
+	TCP      uint16
+	Dials    uint64 // Completed dial attempts
+	Sessions uint64 // Sessions established by dialing the peer
+	Uptime   uint64 // Total duration of the sessions, in seconds
+	LastSeen uint64 // Unix time of the last session
+	Updated  uint64 // Unix time of the last update of the record
+}
+
+// score rates the peer as a dial candidate: the ratio of dials establishing a
+// session, weighted by the typical session length and decaying while the peer
+// isn't seen.
+func (r *peerRecord) score(now time.Time) float64 {
+	success := float64(r.Sessions+1) / float64(r.Dials+2)
+	session := float64(r.Uptime) / float64(r.Sessions+1)
+	age := now.Sub(time.Unix(int64(r.LastSeen), 0))
+	return success * math.Log1p(session) * math.Exp2(-float64(age)/float64(peerScoreHalfLife))
+}
+
+// peerDB keeps the dial quality history of the peers across restarts, so that
+// the dialer can reconnect to the good peers first. It's only accessed by the
+// server loop.
+type peerDB struct {
+	lvl   *leveldb.DB
+	peers map[discover.NodeID]*peerRecord
+	limit int // Number of peer records kept, the worst scored one being evicted on insert
+}
+
+// newPeerDB opens the peer database at path, or an in-memory one if path is
+// empty, dropping the expired records.
+func newPeerDB(path string) (*peerDB, error) {
+	var (
+		lvl *leveldb.DB
+		err error
+	)
+	if path == "" {
+		lvl, err = leveldb.Open(storage.NewMemStorage(), nil)
+	} else {
+		lvl, err = leveldb.OpenFile(path, &opt.Options{OpenFilesCacheCapacity: 5})
+		if _, corrupted := err.(*errors.ErrCorrupted); corrupted {
+			lvl, err = leveldb.RecoverFile(path, nil)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	db := &peerDB{lvl: lvl, peers: make(map[discover.NodeID]*peerRecord), limit: maxPeerRecords}
+
+	now := time.Now()
+	it := lvl.NewIterator(nil, nil)
+	for it.Next() {
+		var (
+			id     discover.NodeID
+			record = new(peerRecord)
+		)
+		copy(id[:], it.Key())
+		if len(it.Key()) != len(id) || rlp.DecodeBytes(it.Value(), record) != nil || now.Sub(time.Unix(int64(record.Updated), 0)) > peerRecordExpiry {
+			lvl.Delete(it.Key(), nil)
+			continue
+		}
+		db.peers[id] = record
+	}
+	it.Release()
+
+	if len(db.peers) > db.limit {
+		for _, id := range db.ranked(now)[db.limit:] {
+			delete(db.peers, id)
+			lvl.Delete(id[:], nil)
+		}
+	}
+	return db, nil
+}
+
+// record returns the record of a peer, creating it if needed and evicting the
+// worst scored record if the database is full.
+func (db *peerDB) record(id discover.NodeID, ip net.IP, tcp uint16, now time.Time) *peerRecord {
+	record := db.peers[id]
+	if record == nil {
+		if len(db.peers) >= db.limit {
+			db.evict(now)
+		}
+		record = new(peerRecord)
+		db.peers[id] = record
+	}
+	if ip != nil && tcp != 0 {
+		record.IP, record.TCP = ip, tcp
+	}
+	return record
+}
+
+// evict drops the worst scored record.
+func (db *peerDB) evict(now time.Time) {
+	var (
+		worst discover.NodeID
+		score float64
+		found bool
+	)
+	for id, record := range db.peers {
+		if s := record.score(now); !found || s < score {
+			worst, score, found = id, s, true
+		}
+	}
+	if !found {
+		return
+	}
+	delete(db.peers, worst)
+	if err := db.lvl.Delete(worst[:], nil); err != nil {
+		log.Warn("Failed to delete peer record", "id", worst, "err", err)
+	}
+}
+
+func (db *peerDB) store(id discover.NodeID, record *peerRecord, now time.Time) {
+	record.Updated = uint64(now.Unix())
+	blob, err := rlp.EncodeToBytes(record)
+	if err != nil {
+		log.Warn("Failed to encode peer record", "id", id, "err", err)
+		return
+	}
+	if err := db.lvl.Put(id[:], blob, nil); err != nil {
+		log.Warn("Failed to store peer record", "id", id, "err", err)
+	}
+}
+
+// dialed records a completed dial attempt.
+func (db *peerDB) dialed(n *discover.Node, now time.Time) {
+	record := db.record(n.ID, n.IP, n.TCP, now)
+	record.Dials++
+	db.store(n.ID, record, now)
+}
+
+// connected records a session established by dialing the peer at addr.
+func (db *peerDB) connected(id discover.NodeID, addr net.Addr, now time.Time) {
+	var record *peerRecord
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		record = db.record(id, tcp.IP, uint16(tcp.Port), now)
+	} else {
+		record = db.record(id, nil, 0, now)
+	}
+	record.Sessions++
+	record.LastSeen = uint64(now.Unix())
+	db.store(id, record, now)
+}
+
+// disconnected records the end of a session established by dialing the peer.
+func (db *peerDB) disconnected(id discover.NodeID, duration time.Duration, now time.Time) {
+	record := db.record(id, nil, 0, now)
+	record.Uptime += uint64(duration / time.Second)
+	record.LastSeen = uint64(now.Unix())
+	db.store(id, record, now)
+}
+
+// ranked returns the ids of the peers, best scored first.
+func (db *peerDB) ranked(now time.Time) []discover.NodeID {
+	ids := make([]discover.NodeID, 0, len(db.peers))
+	scores := make(map[discover.NodeID]float64, len(db.peers))
+	for id, record := range db.peers {
+		ids = append(ids, id)
+		scores[id] = record.score(now)
+	}
+	sort.Slice(ids, func(i, j int) bool { return scores[ids[i]] > scores[ids[j]] })
+	return ids
+}
+
+// best returns up to n of the best scored peers a session was ever established
+// with, to be dialed first.
+func (db *peerDB) best(n int, now time.Time) []*discover.Node {
+	var nodes []*discover.Node
+	for _, id := range db.ranked(now) {
+		if len(nodes) == n {
+			break
+		}
+		if record := db.peers[id]; record.Sessions > 0 && record.IP != nil {
+			nodes = append(nodes, discover.NewNode(id, record.IP, 0, record.TCP))
+		}
+	}
+	return nodes
+}
+
+func (db *peerDB) close() {
+	db.lvl.Close()
+}
@@ -0,0 +1,192 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package p2p
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wanchain/go-wanchain/p2p/discover"
+)
+
+// Tests that the dial history survives reopening the peer database and that
+// the peers are ranked by their dial quality.
+func TestPeerDBPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "peerdb-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "peers")
+
+	db, err := newPeerDB(path)
+	if err != nil {
+		t.Fatalf("failed to open peer database: %v", err)
+	}
+	var (
+		now    = time.Now()
+		stable = discover.NewNode(uintID(1), net.IP{10, 0, 0, 1}, 0, 30303)
+		flaky  = discover.NewNode(uintID(2), net.IP{10, 0, 0, 2}, 0, 30303)
+		dead   = discover.NewNode(uintID(3), net.IP{10, 0, 0, 3}, 0, 30303)
+	)
+	// The stable peer accepts every dial and keeps the sessions for hours
+	for i := 0; i < 3; i++ {
+		db.dialed(stable, now)
+		db.connected(stable.ID, &net.TCPAddr{IP: stable.IP, Port: int(stable.TCP)}, now)
+		db.disconnected(stable.ID, 2*time.Hour, now)
+	}
+	// The flaky peer accepts one dial out of five and drops the session quickly
+	for i := 0; i < 5; i++ {
+		db.dialed(flaky, now)
+	}
+	db.connected(flaky.ID, &net.TCPAddr{IP: flaky.IP, Port: int(flaky.TCP)}, now)
+	db.disconnected(flaky.ID, time.Minute, now)
+
+	// The dead peer never accepts a dial
+	db.dialed(dead, now)
+	db.close()
+
+	if db, err = newPeerDB(path); err != nil {
+		t.Fatalf("failed to reopen peer database: %v", err)
+	}
+	defer db.close()
+
+	best := db.best(10, now)
+	if len(best) != 2 {
+		t.Fatalf("best peers count mismatch: have %d, want 2", len(best))
+	}
+	if best[0].ID != stable.ID || best[1].ID != flaky.ID {
+		t.Errorf("best peers order mismatch: have %x, %x", best[0].ID[:4], best[1].ID[:4])
+	}
+	if !best[0].IP.Equal(stable.IP) || best[0].TCP != stable.TCP {
+		t.Errorf("endpoint mismatch: have %v:%d, want %v:%d", best[0].IP, best[0].TCP, stable.IP, stable.TCP)
+	}
+	if record := db.peers[stable.ID]; record.Dials != 3 || record.Sessions != 3 || record.Uptime != 3*7200 {
+		t.Errorf("record mismatch: have %+v", record)
+	}
+	// Peers not seen for a long time must rank below recently seen ones
+	later := now.Add(8 * peerScoreHalfLife)
+	db.connected(flaky.ID, &net.TCPAddr{IP: flaky.IP, Port: int(flaky.TCP)}, later)
+	if best := db.best(1, later); len(best) != 1 || best[0].ID != flaky.ID {
+		t.Errorf("best peer mismatch after decay")
+	}
+}
+
+// Tests that records not updated for a long time are dropped on open.
+func TestPeerDBExpiry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "peerdb-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "peers")
+
+	db, err := newPeerDB(path)
+	if err != nil {
+		t.Fatalf("failed to open peer database: %v", err)
+	}
+	var (
+		now   = time.Now()
+		fresh = discover.NewNode(uintID(1), net.IP{10, 0, 0, 1}, 0, 30303)
+		stale = discover.NewNode(uintID(2), net.IP{10, 0, 0, 2}, 0, 30303)
+	)
+	db.connected(fresh.ID, &net.TCPAddr{IP: fresh.IP, Port: int(fresh.TCP)}, now)
+	db.connected(stale.ID, &net.TCPAddr{IP: stale.IP, Port: int(stale.TCP)}, now.Add(-peerRecordExpiry-time.Hour))
+	db.close()
+
+	if db, err = newPeerDB(path); err != nil {
+		t.Fatalf("failed to reopen peer database: %v", err)
+	}
+	defer db.close()
+
+	if _, ok := db.peers[stale.ID]; ok {
+		t.Errorf("stale record not dropped")
+	}
+	if _, ok := db.peers[fresh.ID]; !ok {
+		t.Errorf("fresh record dropped")
+	}
+}
+
+// Tests that the dialer tries the best known peers before the discovery
+// results, and records the dials.
+func TestDialStateKnownPeers(t *testing.T) {
+	db, _ := newPeerDB("")
+	defer db.close()
+
+	var (
+		now   = time.Now()
+		known = discover.NewNode(uintID(1), net.IP{10, 0, 0, 1}, 0, 30303)
+	)
+	db.connected(known.ID, &net.TCPAddr{IP: known.IP, Port: int(known.TCP)}, now)
+	db.disconnected(known.ID, time.Hour, now)
+
+	s := newDialState(nil, nil, fakeTable{{ID: uintID(2)}, {ID: uintID(3)}}, 4, nil)
+	s.pdb = db
+
+	tasks := s.newTasks(0, nil, now)
+	if len(tasks) == 0 {
+		t.Fatal("no dial tasks")
+	}
+	dial, ok := tasks[0].(*dialTask)
+	if !ok || dial.dest.ID != known.ID {
+		t.Fatalf("first task mismatch: have %v, want dial to known peer", tasks[0])
+	}
+	s.taskDone(dial, now)
+	if record := db.peers[known.ID]; record.Dials != 1 {
+		t.Errorf("dial not recorded: have %d dials, want 1", record.Dials)
+	}
+}
+
+// Tests that the worst scored record is evicted when a peer is recorded into
+// a full database.
+func TestPeerDBEviction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "peerdb-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "peers")
+
+	db, err := newPeerDB(path)
+	if err != nil {
+		t.Fatalf("failed to open peer database: %v", err)
+	}
+	db.limit = 2
+
+	var (
+		now   = time.Now()
+		good  = discover.NewNode(uintID(1), net.IP{10, 0, 0, 1}, 0, 30303)
+		dead  = discover.NewNode(uintID(2), net.IP{10, 0, 0, 2}, 0, 30303)
+		fresh = discover.NewNode(uintID(3), net.IP{10, 0, 0, 3}, 0, 30303)
+	)
+	db.dialed(good, now)
+	db.connected(good.ID, &net.TCPAddr{IP: good.IP, Port: int(good.TCP)}, now)
+	db.disconnected(good.ID, time.Hour, now)
+	db.dialed(dead, now)
+
+	// Recording a third peer must evict the dead one, in memory and on disk
+	db.dialed(fresh, now)
+	if len(db.peers) != 2 {
+		t.Fatalf("record count mismatch: have %d, want 2", len(db.peers))
+	}
+	if _, ok := db.peers[dead.ID]; ok {
+		t.Errorf("worst record not evicted")
+	}
+	if _, ok := db.peers[good.ID]; !ok {
+		t.Errorf("best record evicted")
+	}
+	db.close()
+
+	if db, err = newPeerDB(path); err != nil {
+		t.Fatalf("failed to reopen peer database: %v", err)
+	}
+	defer db.close()
+
+	if _, ok := db.peers[dead.ID]; ok || len(db.peers) != 2 {
+		t.Errorf("evicted record persisted: have %d records", len(db.peers))
+	}
+}
@@ -33,6 +33,7 @@ import (
 	"github.com/wanchain/go-wanchain/p2p/discv5"
 	"github.com/wanchain/go-wanchain/p2p/nat"
 	"github.com/wanchain/go-wanchain/p2p/netutil"
+	"github.com/wanchain/go-wanchain/rlp"
 )
 
 const (
@@ -61,6 +62,10 @@ type Config struct {
 	// This field must be set to a valid secp256k1 private key.
 	PrivateKey *ecdsa.PrivateKey `toml:"-"`
 
+	// KeyLinks is the chain of the rotations of the node key, ending with the
+	// current one. The last link is sent to the peers in the handshake.
+	KeyLinks []*discover.KeyLink `toml:"-"`
+
 	// MaxPeers is the maximum number of peers that can be
 	// connected. It must be greater than zero.
 	MaxPeers int
@@ -111,6 +116,10 @@ type Config struct {
 	// live nodes in the network.
 	NodeDatabase string `toml:",omitempty"`
 
+	// PeerDatabase is the path to the database containing the dial quality
+	// history of the peers, used to redial the best ones first.
+	PeerDatabase string `toml:",omitempty"`
+
 	// Protocols should contain the protocols supported
 	// by the server. Matching protocols are launched for
 	// each peer.
@@ -155,6 +164,7 @@ type Server struct {
 	running bool
 
 	ntab         discoverTable
+	peerDB       *peerDB
 	listener     net.Listener
 	ourHandshake *protoHandshake
 	lastLookup   time.Time
@@ -197,10 +207,11 @@ type conn struct {
 	fd net.Conn
 	transport
 	flags connFlag
-	cont  chan error      // The run loop uses cont to signal errors to SetupConn.
-	id    discover.NodeID // valid after the encryption handshake
-	caps  []Cap           // valid after the protocol handshake
-	name  string          // valid after the protocol handshake
+	cont  chan error        // The run loop uses cont to signal errors to SetupConn.
+	id    discover.NodeID   // valid after the encryption handshake
+	caps  []Cap             // valid after the protocol handshake
+	name  string            // valid after the protocol handshake
+	link  *discover.KeyLink // valid after the protocol handshake, nil if not announced
 }
 
 type transport interface {
@@ -408,12 +419,26 @@ func (srv *Server) Start() (err error) {
 		dynPeers = 0
 	}
 	dialer := newDialState(srv.StaticNodes, srv.BootstrapNodes, srv.ntab, dynPeers, srv.NetRestrict)
+	if dynPeers > 0 {
+		pdb, err := newPeerDB(srv.PeerDatabase)
+		if err != nil {
+			return err
+		}
+		srv.peerDB, dialer.pdb = pdb, pdb
+	}
 
 	// handshake
 	srv.ourHandshake = &protoHandshake{Version: baseProtocolVersion, Name: srv.Name, ID: discover.PubkeyID(&srv.PrivateKey.PublicKey)}
 	for _, p := range srv.Protocols {
 		srv.ourHandshake.Caps = append(srv.ourHandshake.Caps, p.cap())
 	}
+	if n := len(srv.KeyLinks); n > 0 {
+		link, err := rlp.EncodeToBytes(srv.KeyLinks[n-1])
+		if err != nil {
+			return err
+		}
+		srv.ourHandshake.Rest = []rlp.RawValue{link}
+	}
 	// listen/dial
 	if srv.ListenAddr != "" {
 		if err := srv.startListening(); err != nil {
@@ -571,6 +596,9 @@ running:
 
 				log.Debug("Adding p2p peer", "id", c.id, "name", name, "addr", c.fd.RemoteAddr(), "peers", len(peers)+1)
 				peers[c.id] = p
+				if srv.peerDB != nil && c.is(dynDialedConn|staticDialedConn) {
+					srv.peerDB.connected(c.id, c.fd.RemoteAddr(), time.Now())
+				}
 				go srv.runPeer(p)
 			}
 			// The dialer logic relies on the assumption that
@@ -585,6 +613,7 @@ running:
 			// A peer disconnected.
 			d := common.PrettyDuration(mclock.Now() - pd.created)
 			pd.log.Debug("Removing p2p peer", "duration", d, "peers", len(peers)-1, "req", pd.requested, "err", pd.err)
+			srv.peerDisconnected(pd.Peer)
 			delete(peers, pd.ID())
 		}
 	}
@@ -608,8 +637,20 @@ running:
 	for len(peers) > 0 {
 		p := <-srv.delpeer
 		p.log.Trace("<-delpeer (spindown)", "remainingTasks", len(runningTasks))
+		srv.peerDisconnected(p.Peer)
 		delete(peers, p.ID())
 	}
+	if srv.peerDB != nil {
+		srv.peerDB.close()
+	}
+}
+
+// peerDisconnected records the session length of a dialed peer in the peer
+// database.
+func (srv *Server) peerDisconnected(p *Peer) {
+	if srv.peerDB != nil && p.rw.is(dynDialedConn|staticDialedConn) {
+		srv.peerDB.disconnected(p.ID(), time.Duration(mclock.Now()-p.created), time.Now())
+	}
 }
 
 func (srv *Server) protoHandshakeChecks(peers map[discover.NodeID]*Peer, c *conn) error {
@@ -744,6 +785,7 @@ func (srv *Server) SetupConn(fd net.Conn, flags connFlag, dialDest *discover.Nod
 		return
 	}
 	c.caps, c.name = phs.Caps, phs.Name
+	c.link = handshakeKeyLink(phs)
 	if err := srv.checkpoint(c, srv.addpeer); err != nil {
 		clog.Trace("Rejected peer", "err", err)
 		c.close(err)
@@ -753,6 +795,22 @@ func (srv *Server) SetupConn(fd net.Conn, flags connFlag, dialDest *discover.Nod
 	// launched by run.
 }
 
+// handshakeKeyLink returns the key link announced in a protocol handshake, if
+// valid and leading to the identity of the peer.
+func handshakeKeyLink(phs *protoHandshake) *discover.KeyLink {
+	if len(phs.Rest) == 0 {
+		return nil
+	}
+	link := new(discover.KeyLink)
+	if err := rlp.DecodeBytes(phs.Rest[0], link); err != nil {
+		return nil
+	}
+	if link.New != phs.ID || link.Verify() != nil {
+		return nil
+	}
+	return link
+}
+
 func truncateName(s string) string {
 	if len(s) > 20 {
 		return s[:20] + "..."
@@ -817,6 +875,7 @@ type NodeInfo struct {
 	} `json:"ports"`
 	ListenAddr string                 `json:"listenAddr"`
 	Protocols  map[string]interface{} `json:"protocols"`
+	KeyLinks   []*discover.KeyLink    `json:"keyLinks,omitempty"` // Rotations of the node key
 }
 
 // NodeInfo gathers and returns a collection of metadata known about the host.
@@ -831,6 +890,7 @@ func (srv *Server) NodeInfo() *NodeInfo {
 		IP:         node.IP.String(),
 		ListenAddr: srv.ListenAddr,
 		Protocols:  make(map[string]interface{}),
+		KeyLinks:   srv.KeyLinks,
 	}
 	info.Ports.Discovery = int(node.UDP)
 	info.Ports.Listener = int(node.TCP)
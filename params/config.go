@@ -118,7 +118,7 @@ var (
 	// means that all fields must be set at all times. This forces
 	// anyone adding flags to the config to also have to set these
 	// fields.
-	AllProtocolChanges = &ChainConfig{big.NewInt(1337) /* big.NewInt(0),*/ /*nil, false,*/ /* big.NewInt(0), common.Hash{},*/ /*big.NewInt(0),*/ /*big.NewInt(0),*/, big.NewInt(0), new(EthashConfig), nil, nil}
+	AllProtocolChanges = &ChainConfig{big.NewInt(1337) /* big.NewInt(0),*/ /*nil, false,*/ /* big.NewInt(0), common.Hash{},*/ /*big.NewInt(0),*/ /*big.NewInt(0),*/, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, nil, new(EthashConfig), nil, nil}
 
 	TestChainConfig = &ChainConfig{
 		ChainId:        big.NewInt(1),
@@ -151,12 +151,306 @@ type ChainConfig struct {
 
 	ByzantiumBlock *big.Int `json:"byzantiumBlock,omitempty"` // Byzantium switch block (nil = no fork, 0 = already on byzantium)
 
+	AccountAbstractionBlock *big.Int `json:"accountAbstractionBlock,omitempty"` // Account verification contracts switch block (nil = no fork)
+	PrivacyReceiptBlock     *big.Int `json:"privacyReceiptBlock,omitempty"`     // Privacy consumption receipt fields switch block (nil = no fork)
+	KeyImageAccBlock        *big.Int `json:"keyImageAccBlock,omitempty"`        // Header key image accumulator switch block (nil = no fork)
+	GovernanceBlock         *big.Int `json:"governanceBlock,omitempty"`         // Governance parameter contract switch block (nil = no fork)
+	VestingBlock            *big.Int `json:"vestingBlock,omitempty"`            // Foundation vesting contract switch block (nil = no fork)
+	ReturnLimitBlock        *big.Int `json:"returnLimitBlock,omitempty"`        // Precompile output size limit and gas switch block (nil = no fork)
+	PrivacyCapBlock         *big.Int `json:"privacyCapBlock,omitempty"`         // Per block privacy transaction cap switch block (nil = no fork)
+	WanParamsBlock          *big.Int `json:"wanParamsBlock,omitempty"`          // Privacy parameters precompile switch block (nil = no fork)
+	KeyImageStatusBlock     *big.Int `json:"keyImageStatusBlock,omitempty"`     // Key image status precompile switch block (nil = no fork)
+	RingLimitBlock          *big.Int `json:"ringLimitBlock,omitempty"`          // Ring signed data size limit and split refunds switch block (nil = no fork)
+
+	// Protocol changes activated by miner signaling
+	Deployments []*Deployment `json:"deployments,omitempty"`
+
+	// Signers allowed to change the governed runtime parameters
+	Governance *GovernanceConfig `json:"governance,omitempty"`
+
+	// Block reward schedule and its split with the treasury
+	Treasury *TreasuryConfig `json:"treasury,omitempty"`
+
+	// Release schedules of the genesis funds held by the vesting contract
+	Vesting []*VestingSchedule `json:"vesting,omitempty"`
+
+	// Bound on the privacy transactions a block may include
+	PrivacyCap *PrivacyCapConfig `json:"privacyCap,omitempty"`
+
 	// Various consensus engines
 	Ethash *EthashConfig `json:"ethash,omitempty"`
 	Clique *CliqueConfig `json:"clique,omitempty"`
 	Pluto  *PlutoConfig  `json:"pluto,omitempty"`
 }
 
+// Deployment is a protocol change activated once enough miners signal their
+// support for it. Blocks are grouped in windows of Window blocks; a deployment
+// starts being signaled in the first window at or after StartBlock, locks in
+// after a window with at least Threshold signaling blocks and is active from
+// the following window on. It fails if it isn't locked in by TimeoutBlock.
+type Deployment struct {
+	Name         string   `json:"name"`
+	Bit          uint     `json:"bit"`          // Signal bit, in range [0, 32)
+	StartBlock   *big.Int `json:"startBlock"`   // Block signaling can start at
+	TimeoutBlock *big.Int `json:"timeoutBlock"` // Block signaling fails at (nil = never)
+	Window       uint64   `json:"window"`       // Number of blocks per signaling window
+	Threshold    uint64   `json:"threshold"`    // Signaling blocks per window needed to lock in
+}
+
+// String implements the stringer interface, returning the deployment details.
+func (d *Deployment) String() string {
+	return fmt.Sprintf("%s(bit %d, %d/%d)", d.Name, d.Bit, d.Threshold, d.Window)
+}
+
+// PrivacyCapDeployment is the name of the deployment activating the privacy
+// cap once signaled, as an alternative to scheduling it at PrivacyCapBlock.
+const PrivacyCapDeployment = "privacyCap"
+
+// Deployment returns the deployment called name, or nil if not configured.
+func (c *ChainConfig) Deployment(name string) *Deployment {
+	for _, d := range c.Deployments {
+		if d.Name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// GovernanceConfig is the multisig allowed to change the governed runtime
+// parameters. A change takes effect Timelock blocks after Threshold of the
+// Signers approved it.
+type GovernanceConfig struct {
+	Signers   []common.Address `json:"signers"`
+	Threshold uint64           `json:"threshold"`
+	Timelock  uint64           `json:"timelock"`
+}
+
+// String implements the stringer interface, returning the governance details.
+func (c *GovernanceConfig) String() string {
+	return fmt.Sprintf("%d of %d, timelock %d", c.Threshold, len(c.Signers), c.Timelock)
+}
+
+// equal reports whether two governance configs have the same signers, in the
+// same order as their approvals are recorded by index, threshold and timelock.
+func (c *GovernanceConfig) equal(other *GovernanceConfig) bool {
+	if c == nil || other == nil {
+		return c == other
+	}
+	if c.Threshold != other.Threshold || c.Timelock != other.Timelock || len(c.Signers) != len(other.Signers) {
+		return false
+	}
+	for i, signer := range c.Signers {
+		if signer != other.Signers[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// TreasuryConfig is the block reward schedule. The reward of a block and the
+// share of it paid to the treasury are those of the last era started at or
+// before it, blocks before the first era aren't rewarded.
+type TreasuryConfig struct {
+	Address common.Address `json:"address"`
+	Eras    []*RewardEra   `json:"eras"` // Sorted by increasing start block
+}
+
+// RewardEra is a period of constant block reward.
+type RewardEra struct {
+	Block  *big.Int `json:"block"`  // First block of the era
+	Reward *big.Int `json:"reward"` // Block reward in wei
+	Share  uint64   `json:"share"`  // Percentage of the reward paid to the treasury
+}
+
+// String implements the stringer interface, returning the treasury details.
+func (c *TreasuryConfig) String() string {
+	return fmt.Sprintf("%x, %d eras", c.Address, len(c.Eras))
+}
+
+// Era returns the reward era of block num, nil if none started yet.
+func (c *TreasuryConfig) Era(num *big.Int) *RewardEra {
+	var era *RewardEra
+	for _, e := range c.Eras {
+		if !isForked(e.Block, num) {
+			break
+		}
+		era = e
+	}
+	return era
+}
+
+// Split returns the shares of the block reward of block num paid to the
+// coinbase and to the treasury.
+func (c *TreasuryConfig) Split(num *big.Int) (coinbase, treasury *big.Int) {
+	era := c.Era(num)
+	if era == nil || era.Reward == nil {
+		return new(big.Int), new(big.Int)
+	}
+	return era.split()
+}
+
+// Accrued returns the total block rewards paid to the treasury from block 1 up
+// to and including block num, the genesis block not being rewarded.
+func (c *TreasuryConfig) Accrued(num *big.Int) *big.Int {
+	total := new(big.Int)
+	for i, e := range c.Eras {
+		if !isForked(e.Block, num) {
+			break
+		}
+		first := e.Block
+		if first.Sign() == 0 {
+			first = big.NewInt(1)
+		}
+		// The era lasts up to the next one or block num, whichever comes first
+		last := num
+		if i+1 < len(c.Eras) && isForked(c.Eras[i+1].Block, num) {
+			last = new(big.Int).Sub(c.Eras[i+1].Block, big.NewInt(1))
+		}
+		if e.Reward == nil || last.Cmp(first) < 0 {
+			continue
+		}
+		blocks := new(big.Int).Sub(last, first)
+		blocks.Add(blocks, big.NewInt(1))
+
+		_, treasury := e.split()
+		total.Add(total, treasury.Mul(treasury, blocks))
+	}
+	return total
+}
+
+// checkCompatible returns an error if a reward era started at or before head in
+// either config differs, or if the treasury address changed once the first era
+// of either config started.
+func (c *TreasuryConfig) checkCompatible(newcfg *TreasuryConfig, head *big.Int) *ConfigCompatError {
+	var stored, updated []*RewardEra
+	if c != nil {
+		stored = c.Eras
+	}
+	if newcfg != nil {
+		updated = newcfg.Eras
+	}
+	for i := 0; i < len(stored) || i < len(updated); i++ {
+		var s, u *RewardEra
+		if i < len(stored) {
+			s = stored[i]
+		}
+		if i < len(updated) {
+			u = updated[i]
+		}
+		if !s.startedAt(head) && !u.startedAt(head) {
+			break
+		}
+		if !s.equal(u) {
+			return newCompatError("Treasury reward era", s.start(), u.start())
+		}
+		if i == 0 && c.address() != newcfg.address() {
+			return newCompatError("Treasury address", s.start(), u.start())
+		}
+	}
+	return nil
+}
+
+func (c *TreasuryConfig) address() common.Address {
+	if c == nil {
+		return common.Address{}
+	}
+	return c.Address
+}
+
+func (e *RewardEra) start() *big.Int {
+	if e == nil {
+		return nil
+	}
+	return e.Block
+}
+
+func (e *RewardEra) startedAt(head *big.Int) bool {
+	return e != nil && isForked(e.Block, head)
+}
+
+func (e *RewardEra) equal(other *RewardEra) bool {
+	if e == nil || other == nil {
+		return e == other
+	}
+	return e.Share == other.Share && configNumEqual(e.Block, other.Block) && configNumEqual(e.Reward, other.Reward)
+}
+
+func (e *RewardEra) split() (coinbase, treasury *big.Int) {
+	share := e.Share
+	if share > 100 {
+		share = 100
+	}
+	treasury = new(big.Int).Mul(e.Reward, new(big.Int).SetUint64(share))
+	treasury.Div(treasury, big.NewInt(100))
+	return new(big.Int).Sub(e.Reward, treasury), treasury
+}
+
+// VestingSchedule releases Amount to Beneficiary linearly over Duration blocks
+// from Start on, nothing being released before the Cliff block.
+type VestingSchedule struct {
+	Beneficiary common.Address `json:"beneficiary"`
+	Amount      *big.Int       `json:"amount"`
+	Start       *big.Int       `json:"start"`
+	Cliff       *big.Int       `json:"cliff"`
+	Duration    uint64         `json:"duration"`
+}
+
+// Vested returns the part of the schedule amount released by block num.
+func (s *VestingSchedule) Vested(num *big.Int) *big.Int {
+	if s.Amount == nil || s.Start == nil || !isForked(s.Start, num) || (s.Cliff != nil && !isForked(s.Cliff, num)) {
+		return new(big.Int)
+	}
+	elapsed := new(big.Int).Sub(num, s.Start)
+	if s.Duration == 0 || elapsed.Cmp(new(big.Int).SetUint64(s.Duration)) >= 0 {
+		return new(big.Int).Set(s.Amount)
+	}
+	vested := new(big.Int).Mul(s.Amount, elapsed)
+	return vested.Div(vested, new(big.Int).SetUint64(s.Duration))
+}
+
+// equal reports whether two deployments are the same.
+func (d *Deployment) equal(other *Deployment) bool {
+	return d.Name == other.Name && d.Bit == other.Bit && d.Window == other.Window && d.Threshold == other.Threshold &&
+		configNumEqual(d.StartBlock, other.StartBlock) && configNumEqual(d.TimeoutBlock, other.TimeoutBlock)
+}
+
+// deploymentStart returns the start block of the deployment called name, nil
+// if not configured.
+func (c *ChainConfig) deploymentStart(name string) *big.Int {
+	if d := c.Deployment(name); d != nil {
+		return d.StartBlock
+	}
+	return nil
+}
+
+// vestingEqual reports whether two lists of vesting schedules are the same,
+// the schedules being identified by their index.
+func vestingEqual(x, y []*VestingSchedule) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i].Beneficiary != y[i].Beneficiary || x[i].Duration != y[i].Duration ||
+			!configNumEqual(x[i].Amount, y[i].Amount) || !configNumEqual(x[i].Start, y[i].Start) || !configNumEqual(x[i].Cliff, y[i].Cliff) {
+			return false
+		}
+	}
+	return true
+}
+
+// PrivacyCapConfig bounds the privacy transactions, stamped transactions and
+// refunds, whose ring signature verification dominates the time to validate a
+// block. A zero bound is disabled.
+type PrivacyCapConfig struct {
+	MaxTxs      uint64 `json:"maxTxs"`      // Privacy transactions per block
+	MaxGasShare uint64 `json:"maxGasShare"` // Percentage of the block gas limit the privacy transactions may claim
+}
+
+// String implements the stringer interface, returning the cap details.
+func (c *PrivacyCapConfig) String() string {
+	return fmt.Sprintf("%d txs, %d%% gas", c.MaxTxs, c.MaxGasShare)
+}
+
 // EthashConfig is the consensus engine configs for proof-of-work based sealing.
 type EthashConfig struct{}
 
@@ -241,6 +535,69 @@ func (c *ChainConfig) String() string {
 //	return isForked(c.ByzantiumBlock, num)
 //}
 
+// IsAccountAbstraction returns whether num is either equal to the account
+// abstraction fork block or greater, enabling accounts to replace the sender
+// signature check with a verification contract.
+func (c *ChainConfig) IsAccountAbstraction(num *big.Int) bool {
+	return isForked(c.AccountAbstractionBlock, num)
+}
+
+// IsPrivacyReceipt returns whether num is either equal to the privacy receipt
+// fork block or greater, adding the stamps consumed and the key images spent
+// by a transaction to its receipt.
+func (c *ChainConfig) IsPrivacyReceipt(num *big.Int) bool {
+	return isForked(c.PrivacyReceiptBlock, num)
+}
+
+// IsKeyImageAcc returns whether num is either equal to the key image
+// accumulator fork block or greater, committing every header to the set of
+// key images spent so far.
+func (c *ChainConfig) IsKeyImageAcc(num *big.Int) bool {
+	return isForked(c.KeyImageAccBlock, num)
+}
+
+// IsGovernance returns whether num is either equal to the governance fork
+// block or greater, enabling the governance parameter contract.
+func (c *ChainConfig) IsGovernance(num *big.Int) bool {
+	return isForked(c.GovernanceBlock, num)
+}
+
+// IsVesting returns whether num is either equal to the vesting fork block or
+// greater, enabling the foundation vesting contract.
+func (c *ChainConfig) IsVesting(num *big.Int) bool {
+	return isForked(c.VestingBlock, num)
+}
+
+// IsReturnLimit returns whether num is either equal to the return limit fork
+// block or greater, bounding and charging the output of the Wanchain precompiles.
+func (c *ChainConfig) IsReturnLimit(num *big.Int) bool {
+	return isForked(c.ReturnLimitBlock, num)
+}
+
+// IsPrivacyCap returns whether num is either equal to the privacy cap fork
+// block or greater, bounding the privacy transactions per block.
+func (c *ChainConfig) IsPrivacyCap(num *big.Int) bool {
+	return isForked(c.PrivacyCapBlock, num)
+}
+
+// IsWanParams returns whether num is either equal to the privacy parameters
+// fork block or greater, enabling the WANPARAMS precompile.
+func (c *ChainConfig) IsWanParams(num *big.Int) bool {
+	return isForked(c.WanParamsBlock, num)
+}
+
+// IsKeyImageStatus returns whether num is either equal to the key image status
+// fork block or greater, enabling the key image status precompile.
+func (c *ChainConfig) IsKeyImageStatus(num *big.Int) bool {
+	return isForked(c.KeyImageStatusBlock, num)
+}
+
+// IsRingLimit returns whether num is either equal to the ring limit fork block
+// or greater, bounding the ring signed data and enabling the split refunds.
+func (c *ChainConfig) IsRingLimit(num *big.Int) bool {
+	return isForked(c.RingLimitBlock, num)
+}
+
 // GasTable returns the gas table corresponding to the current phase (homestead or homestead reprice).
 //
 // The returned GasTable's fields shouldn't, under any circumstances, be changed.
@@ -314,6 +671,61 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	//	return newCompatError("Byzantium fork block", c.ByzantiumBlock, newcfg.ByzantiumBlock)
 	//}
 
+	if isForkIncompatible(c.AccountAbstractionBlock, newcfg.AccountAbstractionBlock, head) {
+		return newCompatError("Account abstraction fork block", c.AccountAbstractionBlock, newcfg.AccountAbstractionBlock)
+	}
+
+	if isForkIncompatible(c.PrivacyReceiptBlock, newcfg.PrivacyReceiptBlock, head) {
+		return newCompatError("Privacy receipt fork block", c.PrivacyReceiptBlock, newcfg.PrivacyReceiptBlock)
+	}
+
+	if isForkIncompatible(c.KeyImageAccBlock, newcfg.KeyImageAccBlock, head) {
+		return newCompatError("Key image accumulator fork block", c.KeyImageAccBlock, newcfg.KeyImageAccBlock)
+	}
+
+	if isForkIncompatible(c.GovernanceBlock, newcfg.GovernanceBlock, head) {
+		return newCompatError("Governance fork block", c.GovernanceBlock, newcfg.GovernanceBlock)
+	}
+	if isForkIncompatible(c.VestingBlock, newcfg.VestingBlock, head) {
+		return newCompatError("Vesting fork block", c.VestingBlock, newcfg.VestingBlock)
+	}
+	if isForkIncompatible(c.ReturnLimitBlock, newcfg.ReturnLimitBlock, head) {
+		return newCompatError("Return limit fork block", c.ReturnLimitBlock, newcfg.ReturnLimitBlock)
+	}
+	if isForkIncompatible(c.PrivacyCapBlock, newcfg.PrivacyCapBlock, head) {
+		return newCompatError("Privacy cap fork block", c.PrivacyCapBlock, newcfg.PrivacyCapBlock)
+	}
+	if isForkIncompatible(c.WanParamsBlock, newcfg.WanParamsBlock, head) {
+		return newCompatError("Privacy parameters fork block", c.WanParamsBlock, newcfg.WanParamsBlock)
+	}
+	if isForkIncompatible(c.KeyImageStatusBlock, newcfg.KeyImageStatusBlock, head) {
+		return newCompatError("Key image status fork block", c.KeyImageStatusBlock, newcfg.KeyImageStatusBlock)
+	}
+	if isForkIncompatible(c.RingLimitBlock, newcfg.RingLimitBlock, head) {
+		return newCompatError("Ring limit fork block", c.RingLimitBlock, newcfg.RingLimitBlock)
+	}
+	if c.IsGovernance(head) && !c.Governance.equal(newcfg.Governance) {
+		return newCompatError("Governance signers", c.GovernanceBlock, newcfg.GovernanceBlock)
+	}
+	if c.IsVesting(head) && !vestingEqual(c.Vesting, newcfg.Vesting) {
+		return newCompatError("Vesting schedules", c.VestingBlock, newcfg.VestingBlock)
+	}
+	if err := c.Treasury.checkCompatible(newcfg.Treasury, head); err != nil {
+		return err
+	}
+	// Deployments can't change once signaling started, on either side
+	for _, cfg := range []*ChainConfig{c, newcfg} {
+		for _, d := range cfg.Deployments {
+			if !isForked(d.StartBlock, head) {
+				continue
+			}
+			stored, updated := c.Deployment(d.Name), newcfg.Deployment(d.Name)
+			if stored == nil || updated == nil || !stored.equal(updated) {
+				return newCompatError("Deployment "+d.Name, c.deploymentStart(d.Name), newcfg.deploymentStart(d.Name))
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -378,7 +790,9 @@ func (err *ConfigCompatError) Error() string {
 // Rules is a one time interface meaning that it shouldn't be used in between transition
 // phases.
 type Rules struct {
-	ChainId *big.Int
+	ChainId      *big.Int
+	IsGovernance bool
+	IsRingLimit  bool
 	//IsHomestead, IsEIP150, IsEIP155, IsEIP158 bool
 	//IsByzantium                               bool
 }
@@ -390,5 +804,5 @@ func (c *ChainConfig) Rules(num *big.Int) Rules {
 	}
 	//return Rules{ChainId: new(big.Int).Set(chainId), IsHomestead: /*c.IsHomestead(num)*/false, IsEIP150: false/*c.IsEIP150(num)*/, IsEIP155: false/*c.IsEIP155(num)*/, IsEIP158:false/* c.IsEIP158(num)*/, IsByzantium: c.IsByzantium(num)}
 
-	return Rules{ChainId: new(big.Int).Set(chainId)}
+	return Rules{ChainId: new(big.Int).Set(chainId), IsGovernance: c.IsGovernance(num), IsRingLimit: c.IsRingLimit(num)}
 }
@@ -20,6 +20,8 @@ import (
 	"math/big"
 	"reflect"
 	"testing"
+
+	"github.com/wanchain/go-wanchain/common"
 )
 
 func TestCheckCompatible(t *testing.T) {
@@ -37,6 +39,84 @@ func TestCheckCompatible(t *testing.T) {
 			head:    9,
 			wantErr: nil,
 		},
+		{
+			stored:  &ChainConfig{WanParamsBlock: big.NewInt(10)},
+			new:     &ChainConfig{WanParamsBlock: big.NewInt(20)},
+			head:    15,
+			wantErr: &ConfigCompatError{What: "Privacy parameters fork block", StoredConfig: big.NewInt(10), NewConfig: big.NewInt(20), RewindTo: 9},
+		},
+		{
+			stored:  &ChainConfig{GovernanceBlock: big.NewInt(10), Governance: &GovernanceConfig{Signers: []common.Address{{1}, {2}}, Threshold: 2}},
+			new:     &ChainConfig{GovernanceBlock: big.NewInt(10), Governance: &GovernanceConfig{Signers: []common.Address{{2}, {1}}, Threshold: 2}},
+			head:    15,
+			wantErr: &ConfigCompatError{What: "Governance signers", StoredConfig: big.NewInt(10), NewConfig: big.NewInt(10), RewindTo: 9},
+		},
+		{
+			stored:  &ChainConfig{VestingBlock: big.NewInt(10), Vesting: []*VestingSchedule{{Amount: big.NewInt(100), Start: big.NewInt(20)}}},
+			new:     &ChainConfig{VestingBlock: big.NewInt(10), Vesting: []*VestingSchedule{{Amount: big.NewInt(200), Start: big.NewInt(20)}}},
+			head:    15,
+			wantErr: &ConfigCompatError{What: "Vesting schedules", StoredConfig: big.NewInt(10), NewConfig: big.NewInt(10), RewindTo: 9},
+		},
+		{
+			stored:  &ChainConfig{Treasury: &TreasuryConfig{Eras: []*RewardEra{{Block: big.NewInt(10), Reward: big.NewInt(1000)}, {Block: big.NewInt(20), Reward: big.NewInt(500)}}}},
+			new:     &ChainConfig{Treasury: &TreasuryConfig{Eras: []*RewardEra{{Block: big.NewInt(10), Reward: big.NewInt(1000)}, {Block: big.NewInt(30), Reward: big.NewInt(500)}}}},
+			head:    15,
+			wantErr: nil,
+		},
+		{
+			stored:  &ChainConfig{Treasury: &TreasuryConfig{Eras: []*RewardEra{{Block: big.NewInt(10), Reward: big.NewInt(1000)}, {Block: big.NewInt(20), Reward: big.NewInt(500)}}}},
+			new:     &ChainConfig{Treasury: &TreasuryConfig{Eras: []*RewardEra{{Block: big.NewInt(10), Reward: big.NewInt(1000)}, {Block: big.NewInt(30), Reward: big.NewInt(500)}}}},
+			head:    25,
+			wantErr: &ConfigCompatError{What: "Treasury reward era", StoredConfig: big.NewInt(20), NewConfig: big.NewInt(30), RewindTo: 19},
+		},
+		{
+			stored:  &ChainConfig{Treasury: &TreasuryConfig{Eras: []*RewardEra{{Block: big.NewInt(10), Reward: big.NewInt(1000), Share: 20}}}},
+			new:     &ChainConfig{Treasury: &TreasuryConfig{Eras: []*RewardEra{{Block: big.NewInt(10), Reward: big.NewInt(1000), Share: 30}}}},
+			head:    10,
+			wantErr: &ConfigCompatError{What: "Treasury reward era", StoredConfig: big.NewInt(10), NewConfig: big.NewInt(10), RewindTo: 9},
+		},
+		{
+			stored:  &ChainConfig{Treasury: &TreasuryConfig{Address: common.Address{1}, Eras: []*RewardEra{{Block: big.NewInt(10), Reward: big.NewInt(1000)}}}},
+			new:     &ChainConfig{Treasury: &TreasuryConfig{Address: common.Address{2}, Eras: []*RewardEra{{Block: big.NewInt(10), Reward: big.NewInt(1000)}}}},
+			head:    9,
+			wantErr: nil,
+		},
+		{
+			stored:  &ChainConfig{Treasury: &TreasuryConfig{Address: common.Address{1}, Eras: []*RewardEra{{Block: big.NewInt(10), Reward: big.NewInt(1000)}}}},
+			new:     &ChainConfig{Treasury: &TreasuryConfig{Address: common.Address{2}, Eras: []*RewardEra{{Block: big.NewInt(10), Reward: big.NewInt(1000)}}}},
+			head:    12,
+			wantErr: &ConfigCompatError{What: "Treasury address", StoredConfig: big.NewInt(10), NewConfig: big.NewInt(10), RewindTo: 9},
+		},
+		{
+			stored:  &ChainConfig{},
+			new:     &ChainConfig{Treasury: &TreasuryConfig{Eras: []*RewardEra{{Block: big.NewInt(5), Reward: big.NewInt(1000)}}}},
+			head:    12,
+			wantErr: &ConfigCompatError{What: "Treasury reward era", NewConfig: big.NewInt(5), RewindTo: 4},
+		},
+		{
+			stored:  &ChainConfig{RingLimitBlock: big.NewInt(10)},
+			new:     &ChainConfig{},
+			head:    15,
+			wantErr: &ConfigCompatError{What: "Ring limit fork block", StoredConfig: big.NewInt(10), NewConfig: nil, RewindTo: 9},
+		},
+		{
+			stored:  &ChainConfig{Deployments: []*Deployment{{Name: "cap", StartBlock: big.NewInt(10), Window: 4, Threshold: 3}}},
+			new:     &ChainConfig{Deployments: []*Deployment{{Name: "cap", StartBlock: big.NewInt(10), Window: 4, Threshold: 2}}},
+			head:    15,
+			wantErr: &ConfigCompatError{What: "Deployment cap", StoredConfig: big.NewInt(10), NewConfig: big.NewInt(10), RewindTo: 9},
+		},
+		{
+			stored:  &ChainConfig{},
+			new:     &ChainConfig{Deployments: []*Deployment{{Name: "cap", StartBlock: big.NewInt(10), Window: 4, Threshold: 3}}},
+			head:    15,
+			wantErr: &ConfigCompatError{What: "Deployment cap", StoredConfig: nil, NewConfig: big.NewInt(10), RewindTo: 9},
+		},
+		{
+			stored:  &ChainConfig{Deployments: []*Deployment{{Name: "cap", StartBlock: big.NewInt(20), Window: 4, Threshold: 3}}},
+			new:     &ChainConfig{Deployments: []*Deployment{{Name: "cap", StartBlock: big.NewInt(30), Window: 4, Threshold: 3}}},
+			head:    15,
+			wantErr: nil,
+		},
 		//{
 		//	stored: AllProtocolChanges,
 		//	new:    &ChainConfig{ByzantiumBlock: nil},
@@ -79,3 +159,39 @@ func TestCheckCompatible(t *testing.T) {
 		}
 	}
 }
+
+func TestTreasurySplit(t *testing.T) {
+	config := &TreasuryConfig{Eras: []*RewardEra{
+		{Block: big.NewInt(10), Reward: big.NewInt(1000), Share: 20},
+		{Block: big.NewInt(20), Reward: big.NewInt(500), Share: 50},
+	}}
+	tests := []struct {
+		number                      int64
+		coinbase, treasury, accrued int64
+	}{
+		{0, 0, 0, 0},
+		{9, 0, 0, 0},
+		{10, 800, 200, 200},
+		{19, 800, 200, 2000},
+		{20, 250, 250, 2250},
+		{25, 250, 250, 3500},
+	}
+	for _, tt := range tests {
+		num := big.NewInt(tt.number)
+		coinbase, treasury := config.Split(num)
+		if coinbase.Int64() != tt.coinbase || treasury.Int64() != tt.treasury {
+			t.Errorf("block %d: split mismatch: have %v/%v, want %d/%d", tt.number, coinbase, treasury, tt.coinbase, tt.treasury)
+		}
+		if accrued := config.Accrued(num); accrued.Int64() != tt.accrued {
+			t.Errorf("block %d: accrued mismatch: have %v, want %d", tt.number, accrued, tt.accrued)
+		}
+	}
+	// The genesis block isn't rewarded from a genesis era
+	genesis := &TreasuryConfig{Eras: []*RewardEra{{Block: big.NewInt(0), Reward: big.NewInt(1000), Share: 20}}}
+	if accrued := genesis.Accrued(big.NewInt(0)); accrued.Sign() != 0 {
+		t.Errorf("genesis accrued mismatch: have %v, want 0", accrued)
+	}
+	if accrued := genesis.Accrued(big.NewInt(3)); accrued.Int64() != 600 {
+		t.Errorf("block 3 accrued mismatch: have %v, want 600", accrued)
+	}
+}
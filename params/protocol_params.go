@@ -72,6 +72,18 @@ const (
 
 	RequiredGasPerMixPub uint64 = 4000 // ring signature mix difficulty gas
 	GetOTAMixSetMaxSize  uint64 = 20   // Max number of mix ota set size from once getting
+
+	AccountVerifyGas uint64 = 200000 // Gas allowance of an account verification contract call
+	WanParamsGas     uint64 = 400    // Gas needed to query the privacy parameters
+	KeyImageGas      uint64 = 200    // Gas needed to look up the status of a key image, as an SLOAD
+	MinRingSize      uint64 = 1      // Minimum number of members of an OTA ring signature
+
+	MaxRingSignedDataSize        uint64 = 32 * 1024                 // Maximum size of the ring signed data carried by a single transaction
+	MaxChunkedRingSignedDataSize uint64 = 2 * MaxRingSignedDataSize // Maximum size of the ring signed data split over a commit/reveal pair
+	RingMembersStoreGasPerWord   uint64 = 3000                      // Per-word price for storing committed ring members until revealed
+
+	MaxPrecompileReturnSize uint64 = 32 * 1024 // Maximum output of a Wanchain precompile from the return limit fork on
+	PrecompileReturnWordGas uint64 = 3         // Per-word price of the output of a Wanchain precompile from the return limit fork on
 )
 
 var (
@@ -0,0 +1,68 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"github.com/wanchain/go-wanchain/metrics"
+)
+
+// methodMetrics are the metrics of the calls of an RPC method, registered as
+// rpc/<method>/<metric>, e.g. rpc/wan_getOTAMixSet/duration.
+type methodMetrics struct {
+	duration  gometrics.Timer     // Latency of the calls
+	errors    gometrics.Meter     // Calls answered with an error
+	requests  gometrics.Histogram // Size of the call parameters, in bytes
+	responses gometrics.Histogram // Size of the responses, in bytes
+}
+
+var (
+	methodMetricsLock sync.Mutex
+	methodMetricsSet  = make(map[string]*methodMetrics)
+)
+
+// metricsOf returns the metrics of an RPC method, registering them on first use.
+func metricsOf(method string) *methodMetrics {
+	methodMetricsLock.Lock()
+	defer methodMetricsLock.Unlock()
+
+	m, ok := methodMetricsSet[method]
+	if !ok {
+		m = &methodMetrics{
+			duration:  metrics.NewTimer("rpc/" + method + "/duration"),
+			errors:    metrics.NewMeter("rpc/" + method + "/errors"),
+			requests:  metrics.NewHistogram("rpc/" + method + "/requests"),
+			responses: metrics.NewHistogram("rpc/" + method + "/responses"),
+		}
+		methodMetricsSet[method] = m
+	}
+	return m
+}
+
+// serve executes a request like handle, recording the metrics of its method.
+// Only the calls of known methods are metered, so that clients can't fill the
+// registry with arbitrary method names.
+func (s *Server) serve(ctx context.Context, codec ServerCodec, req *serverRequest) (interface{}, func()) {
+	if !metrics.Enabled || req.method == "" {
+		return s.handle(ctx, codec, req)
+	}
+	start := time.Now()
+	response, callback := s.handle(ctx, codec, req)
+
+	m := metricsOf(req.method)
+	m.duration.UpdateSince(start)
+	m.requests.Update(int64(req.size))
+	if _, failed := response.(*jsonErrResponse); failed {
+		m.errors.Mark(1)
+	}
+	// The response is encoded again for its size, only paid with metrics enabled
+	if blob, err := json.Marshal(response); err == nil {
+		m.responses.Update(int64(len(blob)))
+	}
+	return response, callback
+}
@@ -0,0 +1,54 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package rpc
+
+import (
+	"testing"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"github.com/wanchain/go-wanchain/metrics"
+)
+
+// Tests that the calls of known methods are metered per method, counting the
+// failed ones, while unknown methods are not registered.
+func TestMethodMetrics(t *testing.T) {
+	defer func(enabled bool) { metrics.Enabled = enabled }(metrics.Enabled)
+	metrics.Enabled = true
+
+	server := NewServer()
+	if err := server.RegisterName("metered", new(Service)); err != nil {
+		t.Fatal(err)
+	}
+	client := DialInProc(server)
+	defer client.Close()
+
+	var result Result
+	for i := 0; i < 3; i++ {
+		if err := client.Call(&result, "metered_echo", "hello", i, &Args{"world"}); err != nil {
+			t.Fatalf("call failed: %v", err)
+		}
+	}
+	if err := client.Call(&result, "metered_echo", "missing arguments"); err == nil {
+		t.Fatalf("call with missing arguments succeeded")
+	}
+	if err := client.Call(&result, "metered_unknown"); err == nil {
+		t.Fatalf("call of unknown method succeeded")
+	}
+
+	registry := gometrics.DefaultRegistry
+	if duration, ok := registry.Get("rpc/metered_echo/duration").(gometrics.Timer); !ok || duration.Count() != 4 {
+		t.Errorf("call duration not metered: %v", registry.Get("rpc/metered_echo/duration"))
+	}
+	if errors, ok := registry.Get("rpc/metered_echo/errors").(gometrics.Meter); !ok || errors.Count() != 1 {
+		t.Errorf("call errors not metered: %v", registry.Get("rpc/metered_echo/errors"))
+	}
+	if requests, ok := registry.Get("rpc/metered_echo/requests").(gometrics.Histogram); !ok || requests.Count() != 4 || requests.Max() == 0 {
+		t.Errorf("request sizes not metered: %v", registry.Get("rpc/metered_echo/requests"))
+	}
+	if responses, ok := registry.Get("rpc/metered_echo/responses").(gometrics.Histogram); !ok || responses.Count() != 4 || responses.Min() == 0 {
+		t.Errorf("response sizes not metered: %v", registry.Get("rpc/metered_echo/responses"))
+	}
+	if registry.Get("rpc/metered_unknown/duration") != nil {
+		t.Errorf("unknown method metered")
+	}
+}
@@ -61,6 +61,8 @@ type serverRequest struct {
 	args          []reflect.Value
 	isUnsubscribe bool
 	err           Error
+	method        string // Full name of a known method call, for its metrics
+	size          int    // Size of the call parameters, in bytes
 }
 
 type serviceRegistry map[string]*service // collection of services
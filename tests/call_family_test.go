@@ -0,0 +1,234 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package tests
+
+import (
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core"
+	"github.com/wanchain/go-wanchain/core/state"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/core/vm"
+	"github.com/wanchain/go-wanchain/ethdb"
+)
+
+// callFamilyTestDirs are the state test directories exercising the semantics
+// of the call-family opcodes.
+var callFamilyTestDirs = []string{
+	"stCallCodes",
+	"stCallDelegateCodesCallCodeHomestead",
+	"stCallDelegateCodesHomestead",
+	"stDelegatecallTestHomestead",
+	"stStaticCall",
+}
+
+// TestCallFamilyState runs the call-family state tests at the fork level this
+// chain is at, so that a drift of the CALLCODE/DELEGATECALL semantics fails
+// fast without running the whole state test suite.
+func TestCallFamilyState(t *testing.T) {
+	t.Parallel()
+
+	for _, dir := range callFamilyTestDirs {
+		dir := dir
+		t.Run(dir, func(t *testing.T) {
+			st := new(testMatcher)
+			st.walk(t, filepath.Join(stateTestDir, dir), func(t *testing.T, name string, test *StateTest) {
+				for _, subtest := range test.Subtests() {
+					if _, ok := Forks[subtest.Fork]; !ok {
+						continue
+					}
+					subtest := subtest
+					key := fmt.Sprintf("%s/%d", subtest.Fork, subtest.Index)
+					name := dir + "/" + name + "/" + key
+					t.Run(key, func(t *testing.T) {
+						withTrace(t, test.gasLimit(subtest), func(vmconfig vm.Config) error {
+							_, err := test.Run(subtest, vmconfig)
+							return st.checkFailure(t, name, err)
+						})
+					})
+				}
+			})
+		})
+	}
+}
+
+var (
+	callSender = common.HexToAddress("0xa94f5374fce5edbc8e2a8697c15331677e6ebf0b")
+	callCaller = common.HexToAddress("0x1000000000000000000000000000000000000001")
+	callMiddle = common.HexToAddress("0x1000000000000000000000000000000000000002")
+	callCallee = common.HexToAddress("0x1000000000000000000000000000000000000003")
+
+	// callRecorder stores CALLER, CALLVALUE and ADDRESS in slots 0, 1 and 2
+	callRecorder = []byte{
+		byte(vm.CALLER), byte(vm.PUSH1), 0, byte(vm.SSTORE),
+		byte(vm.CALLVALUE), byte(vm.PUSH1), 1, byte(vm.SSTORE),
+		byte(vm.ADDRESS), byte(vm.PUSH1), 2, byte(vm.SSTORE),
+		byte(vm.STOP),
+	}
+	// callReverter records like callRecorder, then reverts
+	callReverter = append(append([]byte{}, callRecorder[:len(callRecorder)-1]...),
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.REVERT))
+)
+
+// callCode returns code invoking to with op, forwarding value for the opcodes
+// transferring value, and storing the success flag in the given slot.
+func callCode(op vm.OpCode, to common.Address, value byte, slot byte) []byte {
+	code := []byte{byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0}
+	if op == vm.CALL || op == vm.CALLCODE {
+		code = append(code, byte(vm.PUSH1), value)
+	}
+	code = append(code, byte(vm.PUSH20))
+	code = append(code, to.Bytes()...)
+	code = append(code, byte(vm.GAS), byte(op), byte(vm.PUSH1), slot, byte(vm.SSTORE), byte(vm.STOP))
+	return code
+}
+
+// TestCallFamilySemantics checks the execution context of the call-family
+// opcodes: the storage written, and the caller, value and address seen by the
+// executed code.
+func TestCallFamilySemantics(t *testing.T) {
+	value := big.NewInt(7)
+	addr := func(a common.Address) common.Hash { return a.Hash() }
+	num := func(n int64) common.Hash { return common.BigToHash(big.NewInt(n)) }
+
+	tests := []struct {
+		name    string
+		codes   map[common.Address][]byte
+		storage map[common.Address]map[common.Hash]common.Hash // Expected storage of all the accounts
+		balance map[common.Address]int64                       // Expected balances on top of the prestate
+	}{
+		{
+			name:  "CALL",
+			codes: map[common.Address][]byte{callCaller: callCode(vm.CALL, callCallee, 3, 3), callCallee: callRecorder},
+			storage: map[common.Address]map[common.Hash]common.Hash{
+				callCaller: {num(3): num(1)},
+				callCallee: {num(0): addr(callCaller), num(1): num(3), num(2): addr(callCallee)},
+			},
+			balance: map[common.Address]int64{callCaller: 4, callCallee: 3},
+		},
+		{
+			name:  "CALLCODE",
+			codes: map[common.Address][]byte{callCaller: callCode(vm.CALLCODE, callCallee, 3, 3), callCallee: callRecorder},
+			storage: map[common.Address]map[common.Hash]common.Hash{
+				callCaller: {num(0): addr(callCaller), num(1): num(3), num(2): addr(callCaller), num(3): num(1)},
+			},
+			balance: map[common.Address]int64{callCaller: 7},
+		},
+		{
+			name:  "DELEGATECALL",
+			codes: map[common.Address][]byte{callCaller: callCode(vm.DELEGATECALL, callCallee, 0, 3), callCallee: callRecorder},
+			storage: map[common.Address]map[common.Hash]common.Hash{
+				callCaller: {num(0): addr(callSender), num(1): num(7), num(2): addr(callCaller), num(3): num(1)},
+			},
+			balance: map[common.Address]int64{callCaller: 7},
+		},
+		{
+			name: "DELEGATECALL/DELEGATECALL",
+			codes: map[common.Address][]byte{
+				callCaller: callCode(vm.DELEGATECALL, callMiddle, 0, 3),
+				callMiddle: callCode(vm.DELEGATECALL, callCallee, 0, 4),
+				callCallee: callRecorder,
+			},
+			storage: map[common.Address]map[common.Hash]common.Hash{
+				callCaller: {num(0): addr(callSender), num(1): num(7), num(2): addr(callCaller), num(3): num(1), num(4): num(1)},
+			},
+			balance: map[common.Address]int64{callCaller: 7},
+		},
+		{
+			name: "DELEGATECALL/CALLCODE",
+			codes: map[common.Address][]byte{
+				callCaller: callCode(vm.DELEGATECALL, callMiddle, 0, 3),
+				callMiddle: callCode(vm.CALLCODE, callCallee, 2, 4),
+				callCallee: callRecorder,
+			},
+			storage: map[common.Address]map[common.Hash]common.Hash{
+				callCaller: {num(0): addr(callCaller), num(1): num(2), num(2): addr(callCaller), num(3): num(1), num(4): num(1)},
+			},
+			balance: map[common.Address]int64{callCaller: 7},
+		},
+		{
+			name: "CALLCODE/DELEGATECALL",
+			codes: map[common.Address][]byte{
+				callCaller: callCode(vm.CALLCODE, callMiddle, 2, 3),
+				callMiddle: callCode(vm.DELEGATECALL, callCallee, 0, 4),
+				callCallee: callRecorder,
+			},
+			storage: map[common.Address]map[common.Hash]common.Hash{
+				callCaller: {num(0): addr(callCaller), num(1): num(2), num(2): addr(callCaller), num(3): num(1), num(4): num(1)},
+			},
+			balance: map[common.Address]int64{callCaller: 7},
+		},
+		{
+			name:  "DELEGATECALL/REVERT",
+			codes: map[common.Address][]byte{callCaller: callCode(vm.DELEGATECALL, callCallee, 0, 3), callCallee: callReverter},
+			storage: map[common.Address]map[common.Hash]common.Hash{
+				callCaller: {},
+			},
+			balance: map[common.Address]int64{callCaller: 7},
+		},
+		{
+			name:  "STATICCALL",
+			codes: map[common.Address][]byte{callCaller: callCode(vm.STATICCALL, callCallee, 0, 3), callCallee: callRecorder},
+			storage: map[common.Address]map[common.Hash]common.Hash{
+				callCaller: {},
+			},
+			balance: map[common.Address]int64{callCaller: 7},
+		},
+	}
+	for _, tt := range tests {
+		pre := core.GenesisAlloc{callSender: {Balance: big.NewInt(1000000000000000000)}}
+		for address, code := range tt.codes {
+			pre[address] = core.GenesisAccount{Code: code, Balance: new(big.Int)}
+		}
+		statedb := runCallFamilyTx(t, pre, value)
+
+		for address := range tt.codes {
+			want := tt.storage[address]
+			have := make(map[common.Hash]common.Hash)
+			statedb.ForEachStorage(address, func(key, value common.Hash) bool {
+				if value != (common.Hash{}) {
+					have[key] = value
+				}
+				return true
+			})
+			if len(have) != len(want) {
+				t.Errorf("%s: storage of %x mismatch: have %x, want %x", tt.name, address, have, want)
+				continue
+			}
+			for key, value := range want {
+				if have[key] != value {
+					t.Errorf("%s: storage of %x at %x mismatch: have %x, want %x", tt.name, address, key, have[key], value)
+				}
+			}
+			if balance := statedb.GetBalance(address); balance.Int64() != tt.balance[address] {
+				t.Errorf("%s: balance of %x mismatch: have %v, want %d", tt.name, address, balance, tt.balance[address])
+			}
+		}
+	}
+}
+
+// runCallFamilyTx sends value from callSender to callCaller on top of the pre
+// state, at the Byzantium fork level.
+func runCallFamilyTx(t *testing.T, pre core.GenesisAlloc, value *big.Int) *state.StateDB {
+	config := Forks["Byzantium"]
+	genesis := &core.Genesis{Config: config, GasLimit: 10000000, Difficulty: big.NewInt(1), Alloc: pre}
+	block, _ := genesis.ToBlock()
+
+	db, _ := ethdb.NewMemDatabase()
+	statedb := makePreState(db, pre)
+
+	msg := types.NewMessage(callSender, &callCaller, 0, value, big.NewInt(1000000), big.NewInt(1), nil, true)
+	context := core.NewEVMContext(msg, block.Header(), nil, &common.Address{})
+	context.GetHash = vmTestBlockHash
+	evm := vm.NewEVM(context, statedb, config, vm.Config{})
+
+	gaspool := new(core.GasPool).AddGas(block.GasLimit())
+	if _, _, failed, err := core.ApplyMessage(evm, msg, gaspool); err != nil || failed {
+		t.Fatalf("transaction failed: %v", err)
+	}
+	return statedb
+}
@@ -0,0 +1,249 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package tests
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/wanchain/go-wanchain/accounts/keystore"
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/common/hexutil"
+	"github.com/wanchain/go-wanchain/common/math"
+	"github.com/wanchain/go-wanchain/core/vm"
+	"github.com/wanchain/go-wanchain/crypto"
+)
+
+// PrivacyVectorsVersion is the version of the privacy test vector format.
+const PrivacyVectorsVersion = 1
+
+// maxVectorRingSize is the number of OTAs in the rings of generated vectors.
+const maxVectorRingSize = 3
+
+// PrivacyVectors are test vectors of the OTA derivation and ring signatures,
+// for wallets to check their implementation against the node's.
+type PrivacyVectors struct {
+	Version   int          `json:"version"`
+	Generator string       `json:"generator,omitempty"`
+	OTAs      []OTAVector  `json:"otas"`
+	Rings     []RingVector `json:"rings"`
+}
+
+// OTAVector is the derivation of a one-time address from a wan address.
+type OTAVector struct {
+	PrivateKeyA   hexutil.Bytes `json:"privateKeyA"`   // a of the wan address (A, B)
+	PrivateKeyB   hexutil.Bytes `json:"privateKeyB"`   // b of the wan address (A, B)
+	WanAddress    hexutil.Bytes `json:"wanAddress"`    // Compressed A and B
+	Secret        hexutil.Bytes `json:"secret"`        // One-time secret r, R = [r]G
+	OTA           hexutil.Bytes `json:"ota"`           // Compressed A1 = [hash([r]B)]G+A and R
+	OTAPrivateKey hexutil.Bytes `json:"otaPrivateKey"` // hash([b]R)+a, A1 = [hash([b]R)+a]G
+}
+
+// RingVector is a ring signature over a message by the private key of one of
+// the ring members.
+type RingVector struct {
+	Message    hexutil.Bytes   `json:"message"`
+	PrivateKey hexutil.Bytes   `json:"privateKey"` // Key of the signing member
+	Ring       []hexutil.Bytes `json:"ring"`       // Uncompressed public keys, the signer's first
+	KeyImage   hexutil.Bytes   `json:"keyImage"`   // [x]hash(P) of the signer
+	Payload    string          `json:"payload"`    // Ring signed data, as carried by privacy transactions
+}
+
+// vectorKey derives the private key of a vector from the seed.
+func vectorKey(seed []byte, kind string, index int) (*ecdsa.PrivateKey, error) {
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], uint64(index))
+	return crypto.ToECDSA(crypto.Keccak256(seed, []byte(kind), n[:]))
+}
+
+// GeneratePrivacyVectors generates count OTA derivations from the wan addresses
+// of keys derived from the seed, and one ring signature by each of the OTAs.
+// The keys are reproducible from the seed, the one-time secrets and the ring
+// signatures are random.
+func GeneratePrivacyVectors(seed []byte, count int) (*PrivacyVectors, error) {
+	if count <= 0 {
+		return nil, fmt.Errorf("invalid vector count %d", count)
+	}
+	vectors := &PrivacyVectors{Version: PrivacyVectorsVersion}
+	otaKeys := make([]*ecdsa.PrivateKey, count)
+	for i := 0; i < count; i++ {
+		a, err := vectorKey(seed, "a", i)
+		if err != nil {
+			return nil, err
+		}
+		b, err := vectorKey(seed, "b", i)
+		if err != nil {
+			return nil, err
+		}
+		A1, R, r, err := crypto.GenerateOneTimeKeyWithSecret(&a.PublicKey, &b.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		if otaKeys[i], err = otaPrivateKey(a, b, A1, R); err != nil {
+			return nil, err
+		}
+		vectors.OTAs = append(vectors.OTAs, OTAVector{
+			PrivateKeyA:   crypto.FromECDSA(a),
+			PrivateKeyB:   crypto.FromECDSA(b),
+			WanAddress:    keystore.GenerateWaddressFromPK(&a.PublicKey, &b.PublicKey)[:],
+			Secret:        crypto.FromECDSA(r),
+			OTA:           keystore.GenerateWaddressFromPK(A1, R)[:],
+			OTAPrivateKey: crypto.FromECDSA(otaKeys[i]),
+		})
+	}
+	size := maxVectorRingSize
+	if count < size {
+		size = count
+	}
+	for i := 0; i < count; i++ {
+		// The ring of an OTA is made of the next ones, as a mix set would be
+		var (
+			ring    = make([]*ecdsa.PublicKey, size)
+			members = make([]hexutil.Bytes, size)
+		)
+		for j := 0; j < size; j++ {
+			key := otaKeys[(i+j)%count]
+			ring[j], members[j] = &key.PublicKey, crypto.FromECDSAPub(&key.PublicKey)
+		}
+		var n [8]byte
+		binary.BigEndian.PutUint64(n[:], uint64(i))
+		message := crypto.Keccak256(seed, []byte("message"), n[:])
+
+		pubs, image, w, q, err := crypto.RingSign(message, otaKeys[i].D, ring)
+		if err != nil {
+			return nil, err
+		}
+		vectors.Rings = append(vectors.Rings, RingVector{
+			Message:    message,
+			PrivateKey: crypto.FromECDSA(otaKeys[i]),
+			Ring:       members,
+			KeyImage:   crypto.FromECDSAPub(image),
+			Payload:    encodeRingSigned(pubs, image, w, q),
+		})
+	}
+	return vectors, nil
+}
+
+// otaPrivateKey derives the private key of the OTA (A1, R) of the wan address
+// of the private keys a and b.
+func otaPrivateKey(a, b *ecdsa.PrivateKey, A1, R *ecdsa.PublicKey) (*ecdsa.PrivateKey, error) {
+	x, _, err := crypto.GenerateOneTimePrivateKey2528(a, b, A1, R)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.ToECDSA(math.PaddedBigBytes(x.D, 32))
+}
+
+// encodeRingSigned encodes a ring signature as the payload of privacy
+// transactions, as decoded by vm.DecodeRingSignOut.
+func encodeRingSigned(pubs []*ecdsa.PublicKey, image *ecdsa.PublicKey, w, q []*big.Int) string {
+	var members, ws, qs []string
+	for i := range pubs {
+		members = append(members, common.ToHex(crypto.FromECDSAPub(pubs[i])))
+		ws = append(ws, hexutil.EncodeBig(w[i]))
+		qs = append(qs, hexutil.EncodeBig(q[i]))
+	}
+	return strings.Join([]string{strings.Join(members, "&"), common.ToHex(crypto.FromECDSAPub(image)), strings.Join(ws, "&"), strings.Join(qs, "&")}, "+")
+}
+
+// VerifyPrivacyVectors checks privacy test vectors, whoever produced them,
+// against the node's OTA derivation and ring signatures.
+func VerifyPrivacyVectors(vectors *PrivacyVectors) error {
+	if vectors.Version != PrivacyVectorsVersion {
+		return fmt.Errorf("unsupported vector version %d, want %d", vectors.Version, PrivacyVectorsVersion)
+	}
+	for i := range vectors.OTAs {
+		if err := verifyOTAVector(&vectors.OTAs[i]); err != nil {
+			return fmt.Errorf("ota vector %d: %v", i, err)
+		}
+	}
+	for i := range vectors.Rings {
+		if err := verifyRingVector(&vectors.Rings[i]); err != nil {
+			return fmt.Errorf("ring vector %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+func verifyOTAVector(v *OTAVector) error {
+	a, err := crypto.ToECDSA(v.PrivateKeyA)
+	if err != nil {
+		return fmt.Errorf("invalid private key a: %v", err)
+	}
+	b, err := crypto.ToECDSA(v.PrivateKeyB)
+	if err != nil {
+		return fmt.Errorf("invalid private key b: %v", err)
+	}
+	r, err := crypto.ToECDSA(v.Secret)
+	if err != nil {
+		return fmt.Errorf("invalid secret: %v", err)
+	}
+	if !bytes.Equal(keystore.GenerateWaddressFromPK(&a.PublicKey, &b.PublicKey)[:], v.WanAddress) {
+		return errors.New("wan address mismatch")
+	}
+	A1, R, err := keystore.GeneratePKPairFromWAddress(v.OTA)
+	if err != nil {
+		return fmt.Errorf("invalid OTA: %v", err)
+	}
+	if R.X.Cmp(r.PublicKey.X) != 0 || R.Y.Cmp(r.PublicKey.Y) != 0 {
+		return errors.New("OTA R doesn't match the secret")
+	}
+	if !crypto.CompareA1(r.D.Bytes(), &a.PublicKey, &b.PublicKey, A1) {
+		return errors.New("OTA A1 mismatch")
+	}
+	x, err := otaPrivateKey(a, b, A1, R)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(crypto.FromECDSA(x), v.OTAPrivateKey) {
+		return errors.New("OTA private key mismatch")
+	}
+	return nil
+}
+
+func verifyRingVector(v *RingVector) error {
+	x, err := crypto.ToECDSA(v.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("invalid private key: %v", err)
+	}
+	if len(v.Ring) == 0 || !bytes.Equal(v.Ring[0], crypto.FromECDSAPub(&x.PublicKey)) {
+		return errors.New("signer is not the first ring member")
+	}
+	// The key image only depends on the signer key: sign alone to compute it
+	_, image, _, _, err := crypto.RingSign(v.Message, x.D, []*ecdsa.PublicKey{&x.PublicKey})
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(crypto.FromECDSAPub(image), v.KeyImage) {
+		return errors.New("key image mismatch")
+	}
+	err, pubs, signedImage, w, q := vm.DecodeRingSignOut(v.Payload)
+	if err != nil {
+		return fmt.Errorf("invalid payload: %v", err)
+	}
+	if !bytes.Equal(crypto.FromECDSAPub(signedImage), v.KeyImage) {
+		return errors.New("payload key image mismatch")
+	}
+	// The payload ring is the vector one, shuffled by the signer
+	members := make(map[string]int)
+	for _, member := range v.Ring {
+		members[string(member)]++
+	}
+	for _, pub := range pubs {
+		members[string(crypto.FromECDSAPub(pub))]--
+	}
+	for _, count := range members {
+		if count != 0 || len(pubs) != len(v.Ring) {
+			return errors.New("payload ring mismatch")
+		}
+	}
+	if !crypto.VerifyRingSign(v.Message, pubs, signedImage, w, q) {
+		return errors.New("invalid ring signature")
+	}
+	return nil
+}
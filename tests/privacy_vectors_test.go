@@ -0,0 +1,59 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/crypto"
+)
+
+// Tests that generated privacy vectors verify, survive a JSON round trip, and
+// that tampered ones are rejected.
+func TestPrivacyVectors(t *testing.T) {
+	vectors, err := GeneratePrivacyVectors([]byte("seed"), 4)
+	if err != nil {
+		t.Fatalf("failed to generate vectors: %v", err)
+	}
+	if len(vectors.OTAs) != 4 || len(vectors.Rings) != 4 {
+		t.Fatalf("vector count mismatch: have %d OTAs and %d rings, want 4", len(vectors.OTAs), len(vectors.Rings))
+	}
+	blob, err := json.Marshal(vectors)
+	if err != nil {
+		t.Fatalf("failed to encode vectors: %v", err)
+	}
+	var decoded PrivacyVectors
+	if err := json.Unmarshal(blob, &decoded); err != nil {
+		t.Fatalf("failed to decode vectors: %v", err)
+	}
+	if err := VerifyPrivacyVectors(&decoded); err != nil {
+		t.Fatalf("generated vectors rejected: %v", err)
+	}
+
+	// The keys are reproducible from the seed
+	again, _ := GeneratePrivacyVectors([]byte("seed"), 1)
+	if !bytes.Equal(again.OTAs[0].PrivateKeyA, vectors.OTAs[0].PrivateKeyA) || !bytes.Equal(again.OTAs[0].PrivateKeyB, vectors.OTAs[0].PrivateKeyB) {
+		t.Errorf("keys not derived from the seed")
+	}
+
+	tampers := map[string]func(v *PrivacyVectors){
+		"version":     func(v *PrivacyVectors) { v.Version++ },
+		"wan address": func(v *PrivacyVectors) { v.OTAs[1].WanAddress = v.OTAs[2].WanAddress },
+		"ota":         func(v *PrivacyVectors) { v.OTAs[1].OTA = v.OTAs[2].OTA },
+		"ota key":     func(v *PrivacyVectors) { v.OTAs[1].OTAPrivateKey = v.OTAs[2].OTAPrivateKey },
+		"key image":   func(v *PrivacyVectors) { v.Rings[1].KeyImage = v.Rings[2].KeyImage },
+		"message":     func(v *PrivacyVectors) { v.Rings[1].Message = crypto.Keccak256([]byte("other")) },
+		"payload":     func(v *PrivacyVectors) { v.Rings[1].Payload = v.Rings[1].Payload[:len(v.Rings[1].Payload)-2] },
+		"ring":        func(v *PrivacyVectors) { v.Rings[1].Ring = v.Rings[1].Ring[:2] },
+	}
+	for name, tamper := range tampers {
+		var tampered PrivacyVectors
+		json.Unmarshal(blob, &tampered)
+		tamper(&tampered)
+		if err := VerifyPrivacyVectors(&tampered); err == nil {
+			t.Errorf("%s: tampered vectors accepted", name)
+		}
+	}
+}
@@ -21,6 +21,7 @@ import (
 
 	"github.com/wanchain/go-wanchain/common"
 	"github.com/wanchain/go-wanchain/log"
+	"github.com/wanchain/go-wanchain/rlp"
 )
 
 var secureKeyPrefix = []byte("secure-key-")
@@ -161,6 +162,12 @@ func (t *SecureTrie) Copy() *SecureTrie {
 	return &cpy
 }
 
+// Prove constructs a merkle proof for key, proving either its value or its
+// absence. The proof is checked by VerifyProof against the hash of key.
+func (t *SecureTrie) Prove(key []byte) []rlp.RawValue {
+	return t.trie.Prove(t.hashKey(key))
+}
+
 // NodeIterator returns an iterator that returns nodes of the underlying trie. Iteration
 // starts at the key after the given start key.
 func (t *SecureTrie) NodeIterator(start []byte) NodeIterator {
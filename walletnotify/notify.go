@@ -0,0 +1,333 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+// Package walletnotify implements a bridge pushing notifications of the OTA
+// deposits received by the locally watched accounts to external sinks.
+package walletnotify
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/wanchain/go-wanchain/accounts"
+	"github.com/wanchain/go-wanchain/accounts/keystore"
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/common/hexutil"
+	"github.com/wanchain/go-wanchain/core"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/core/vm"
+	"github.com/wanchain/go-wanchain/eth"
+	"github.com/wanchain/go-wanchain/event"
+	"github.com/wanchain/go-wanchain/log"
+	"github.com/wanchain/go-wanchain/p2p"
+	"github.com/wanchain/go-wanchain/rpc"
+)
+
+const (
+	// Deposit kinds
+	KindCoin  = "coin"  // Coin note deposit
+	KindStamp = "stamp" // Privacy transaction stamp deposit
+
+	chainHeadChanSize = 10
+	queueSize         = 1024 // Notifications waiting to be pushed before dropping new ones
+)
+
+var (
+	errNoSinks     = errors.New("no notification sink configured")
+	errNoPasswords = errors.New("no passphrase to decrypt the view keys of the watched accounts")
+)
+
+// Config are the configuration parameters of the notification bridge.
+type Config struct {
+	Accounts      []common.Address // Accounts watched for deposits, empty disables the bridge
+	Passwords     []string         `toml:"-"`          // Passphrases of the watched accounts, the last one being reused
+	Webhooks      []string         `toml:",omitempty"` // URLs the notifications are POSTed to as JSON
+	MQTTBroker    string           `toml:",omitempty"` // MQTT broker URL (tcp:// or tls://, with optional user info)
+	MQTTTopic     string           // MQTT topic the notifications are published to
+	Confirmations uint64           // Confirmations after which a deposit is notified again as confirmed
+}
+
+// DefaultConfig contains the default notification bridge settings.
+var DefaultConfig = Config{
+	MQTTTopic:     "wanchain/deposits",
+	Confirmations: 12,
+}
+
+// Notification is the notification of a deposit to an OTA of a watched account.
+// A deposit is notified once detected, and once confirmed. It is notified as
+// removed if its block is reorganised away before being confirmed.
+type Notification struct {
+	Account       common.Address `json:"account"`
+	Kind          string         `json:"kind"`
+	OTA           hexutil.Bytes  `json:"ota"`
+	Denomination  *hexutil.Big   `json:"denomination"`
+	TxHash        common.Hash    `json:"transactionHash"`
+	BlockNumber   hexutil.Uint64 `json:"blockNumber"`
+	BlockHash     common.Hash    `json:"blockHash"`
+	Confirmations uint64         `json:"confirmations"`
+	Removed       bool           `json:"removed"`
+}
+
+// sink is a destination of the notifications.
+type sink interface {
+	push(n *Notification) error
+	String() string
+}
+
+// chain is the part of the blockchain the deposits are scanned from.
+type chain interface {
+	GetBlockByNumber(number uint64) *types.Block
+	SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription
+}
+
+// Service is the notification bridge, running as a node service.
+type Service struct {
+	config   Config
+	chain    chain
+	receipts func(hash common.Hash, number uint64) types.Receipts
+	owns     func(account common.Address, ota []byte) bool
+	sinks    []sink
+
+	scanned  map[uint64]common.Hash        // Hashes of the recently scanned blocks
+	head     uint64                        // Number of the last scanned block
+	deposits map[common.Hash]*Notification // Deposits waiting for confirmation, by transaction hash
+
+	queue chan *Notification
+	quit  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// New creates a notification bridge scanning the chain of the given Ethereum
+// service for deposits to the configured accounts.
+func New(config *Config, ethServ *eth.Ethereum) (*Service, error) {
+	s := newService(config)
+	if len(s.sinks) == 0 {
+		return nil, errNoSinks
+	}
+	bc, db := ethServ.BlockChain(), ethServ.ChainDb()
+	s.chain = bc
+	s.receipts = func(hash common.Hash, number uint64) types.Receipts {
+		return core.GetBlockReceipts(db, hash, number)
+	}
+	// Only decrypt the view keys of the watched accounts, which detect their
+	// OTAs but can't spend them
+	if len(config.Passwords) == 0 {
+		return nil, errNoPasswords
+	}
+	am := ethServ.AccountManager()
+	viewKeys := make(map[common.Address]*keystore.OTAViewKey)
+	for i, account := range config.Accounts {
+		password := config.Passwords[len(config.Passwords)-1]
+		if i < len(config.Passwords) {
+			password = config.Passwords[i]
+		}
+		viewKey, err := keystore.AccountKeyStore(am, account).ViewKey(accounts.Account{Address: account}, password)
+		if err != nil {
+			return nil, fmt.Errorf("view key of %x: %v", account, err)
+		}
+		viewKeys[account] = viewKey
+	}
+	s.config.Passwords = nil
+	s.owns = func(account common.Address, ota []byte) bool {
+		owns, _ := viewKeys[account].Owns(ota)
+		return owns
+	}
+	if head := bc.CurrentBlock(); head != nil {
+		s.head = head.NumberU64()
+		s.scanned[s.head] = head.Hash()
+	}
+	return s, nil
+}
+
+func newService(config *Config) *Service {
+	s := &Service{
+		config:   *config,
+		scanned:  make(map[uint64]common.Hash),
+		deposits: make(map[common.Hash]*Notification),
+		queue:    make(chan *Notification, queueSize),
+		quit:     make(chan struct{}),
+	}
+	for _, url := range config.Webhooks {
+		s.sinks = append(s.sinks, newWebhook(url))
+	}
+	if config.MQTTBroker != "" {
+		s.sinks = append(s.sinks, newMQTT(config.MQTTBroker, config.MQTTTopic))
+	}
+	return s
+}
+
+// Protocols implements node.Service, returning the P2P network protocols used
+// by the bridge (nil as it doesn't use the devp2p overlay network).
+func (s *Service) Protocols() []p2p.Protocol { return nil }
+
+// APIs implements node.Service, returning the RPC API endpoints provided by the
+// bridge (nil as it provides none).
+func (s *Service) APIs() []rpc.API { return nil }
+
+// Start implements node.Service, starting to scan the new blocks.
+func (s *Service) Start(server *p2p.Server) error {
+	s.wg.Add(2)
+	go s.loop()
+	go s.pushLoop()
+
+	log.Info("Wallet notification bridge started", "accounts", len(s.config.Accounts), "sinks", len(s.sinks))
+	return nil
+}
+
+// Stop implements node.Service, terminating the bridge. The notifications not
+// pushed yet are dropped.
+func (s *Service) Stop() error {
+	close(s.quit)
+	s.wg.Wait()
+	log.Info("Wallet notification bridge stopped")
+	return nil
+}
+
+// loop scans the blocks as they become the chain head.
+func (s *Service) loop() {
+	defer s.wg.Done()
+
+	heads := make(chan core.ChainHeadEvent, chainHeadChanSize)
+	sub := s.chain.SubscribeChainHeadEvent(heads)
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case ev := <-heads:
+			s.process(ev.Block)
+		case <-sub.Err():
+			return
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// process scans the blocks up to the new head, rescanning the ones reorganised
+// since scanned, and notifies the deposits detected and confirmed.
+func (s *Service) process(head *types.Block) {
+	number := head.NumberU64()
+
+	// Find the first block to scan, the scanned ones still canonical are skipped
+	from := s.head + 1
+	for ; from > number+1; from-- {
+		delete(s.scanned, from-1)
+	}
+	for from > 1 {
+		hash, ok := s.scanned[from-1]
+		if !ok {
+			break
+		}
+		if block := s.chain.GetBlockByNumber(from - 1); block != nil && block.Hash() == hash {
+			break
+		}
+		delete(s.scanned, from-1)
+		from--
+	}
+	for hash, deposit := range s.deposits {
+		if uint64(deposit.BlockNumber) >= from {
+			removed := *deposit
+			removed.Removed = true
+			s.notify(&removed)
+			delete(s.deposits, hash)
+		}
+	}
+	// Scan the new blocks and notify the deposits reaching their confirmations
+	for n := from; n <= number; n++ {
+		block := head
+		if n != number {
+			if block = s.chain.GetBlockByNumber(n); block == nil {
+				continue
+			}
+		}
+		s.scan(block, number)
+		s.scanned[n] = block.Hash()
+	}
+	s.head = number
+
+	for hash, deposit := range s.deposits {
+		deposit.Confirmations = number - uint64(deposit.BlockNumber) + 1
+		if deposit.Confirmations >= s.config.Confirmations {
+			s.notify(deposit)
+			delete(s.deposits, hash)
+		}
+	}
+	for n := range s.scanned {
+		if n+s.config.Confirmations < number {
+			delete(s.scanned, n)
+		}
+	}
+}
+
+// scan notifies the successful deposits of a block to the watched accounts,
+// given the number of the chain head. Only the transactions calling the coin
+// and stamp contracts directly are detected: deposits made by the internal
+// calls of other contracts aren't traced, and so not notified.
+func (s *Service) scan(block *types.Block, head uint64) {
+	var receipts types.Receipts
+	for i, tx := range block.Transactions() {
+		ota, value, stamp, ok := vm.UnpackDeposit(tx.To(), tx.Data())
+		if !ok {
+			continue
+		}
+		if receipts == nil {
+			receipts = s.receipts(block.Hash(), block.NumberU64())
+		}
+		if i >= len(receipts) || receipts[i].Status != types.ReceiptStatusSuccessful {
+			continue
+		}
+		for _, account := range s.config.Accounts {
+			if !s.owns(account, ota) {
+				continue
+			}
+			deposit := &Notification{
+				Account:       account,
+				Kind:          KindCoin,
+				OTA:           ota,
+				Denomination:  (*hexutil.Big)(new(big.Int).Set(value)),
+				TxHash:        tx.Hash(),
+				BlockNumber:   hexutil.Uint64(block.NumberU64()),
+				BlockHash:     block.Hash(),
+				Confirmations: head - block.NumberU64() + 1,
+			}
+			if stamp {
+				deposit.Kind = KindStamp
+			}
+			s.notify(deposit)
+			if deposit.Confirmations < s.config.Confirmations {
+				s.deposits[deposit.TxHash] = deposit
+			}
+			break
+		}
+	}
+}
+
+// notify queues a notification to be pushed to the sinks.
+func (s *Service) notify(n *Notification) {
+	cpy := *n
+	select {
+	case s.queue <- &cpy:
+	default:
+		log.Warn("Wallet notification queue full, dropping", "tx", n.TxHash, "confirmations", n.Confirmations)
+	}
+}
+
+// pushLoop pushes the queued notifications to the sinks, keeping the slow
+// sinks from holding back the scanning.
+func (s *Service) pushLoop() {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case n := <-s.queue:
+			for _, sink := range s.sinks {
+				if err := sink.push(n); err != nil {
+					log.Warn("Failed to push wallet notification", "sink", sink, "tx", n.TxHash, "err", err)
+				}
+			}
+		case <-s.quit:
+			return
+		}
+	}
+}
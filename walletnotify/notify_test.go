@@ -0,0 +1,223 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package walletnotify
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/wanchain/go-wanchain/common"
+	"github.com/wanchain/go-wanchain/core"
+	"github.com/wanchain/go-wanchain/core/types"
+	"github.com/wanchain/go-wanchain/core/vm"
+	"github.com/wanchain/go-wanchain/event"
+	"github.com/wanchain/go-wanchain/params"
+)
+
+var (
+	watched   = common.HexToAddress("0x01")
+	coinValue = new(big.Int).Mul(big.NewInt(10), big.NewInt(params.Wan))
+)
+
+// testChain is a chain of blocks by number, receipts by block hash.
+type testChain struct {
+	blocks   map[uint64]*types.Block
+	receipts map[common.Hash]types.Receipts
+}
+
+func (c *testChain) GetBlockByNumber(number uint64) *types.Block { return c.blocks[number] }
+
+func (c *testChain) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
+	return new(event.Feed).Subscribe(ch)
+}
+
+// add sets the canonical block of a number, holding the given transactions
+// along with successful receipts. It returns the block.
+func (c *testChain) add(number uint64, fork byte, txs ...*types.Transaction) *types.Block {
+	receipts := make(types.Receipts, len(txs))
+	for i := range txs {
+		receipts[i] = types.NewReceipt(nil, false, new(big.Int))
+	}
+	header := &types.Header{Number: new(big.Int).SetUint64(number), Extra: []byte{fork}}
+	if parent := c.blocks[number-1]; parent != nil {
+		header.ParentHash = parent.Hash()
+	}
+	block := types.NewBlock(header, txs, nil, receipts)
+	c.blocks[number] = block
+	c.receipts[block.Hash()] = receipts
+	return block
+}
+
+type recordingSink struct{ pushed []*Notification }
+
+func (s *recordingSink) push(n *Notification) error {
+	s.pushed = append(s.pushed, n)
+	return nil
+}
+
+func (s *recordingSink) String() string { return "recording" }
+
+// ota returns a test OTA wan address, owned by the watched account if mine.
+func ota(mine bool, nonce byte) []byte {
+	ota := make([]byte, common.WAddressLength)
+	ota[1] = nonce
+	if mine {
+		ota[0] = 1
+	}
+	return ota
+}
+
+func depositTx(t *testing.T, nonce uint64, ota []byte, stamp bool) *types.Transaction {
+	var (
+		to    common.Address
+		data  []byte
+		err   error
+		value = coinValue
+	)
+	if stamp {
+		value = big.NewInt(90000000000000000)
+		to, data, err = vm.PackBuyStamp(ota, value)
+	} else {
+		to, data, err = vm.PackBuyCoinNote(ota, value)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return types.NewTransaction(nonce, to, value, big.NewInt(200000), big.NewInt(1), data)
+}
+
+func newTestService() (*Service, *testChain, *recordingSink) {
+	config := DefaultConfig
+	config.Accounts = []common.Address{watched}
+	config.Confirmations = 3
+
+	chain := &testChain{blocks: make(map[uint64]*types.Block), receipts: make(map[common.Hash]types.Receipts)}
+	recorder := new(recordingSink)
+
+	s := newService(&config)
+	s.chain = chain
+	s.receipts = func(hash common.Hash, number uint64) types.Receipts { return chain.receipts[hash] }
+	s.owns = func(account common.Address, ota []byte) bool { return account == watched && ota[0] == 1 }
+	s.sinks = []sink{recorder}
+
+	genesis := chain.add(0, 0)
+	s.scanned[0] = genesis.Hash()
+	return s, chain, recorder
+}
+
+// drain pushes the queued notifications, returning them.
+func drain(s *Service, sink *recordingSink) []*Notification {
+	sink.pushed = nil
+	for len(s.queue) > 0 {
+		sink.push(<-s.queue)
+	}
+	return sink.pushed
+}
+
+func TestDepositNotifications(t *testing.T) {
+	s, chain, sink := newTestService()
+
+	// A coin deposit to the watched account, a stamp to another
+	s.process(chain.add(1, 0, depositTx(t, 0, ota(true, 1), false), depositTx(t, 1, ota(false, 2), true)))
+	pushed := drain(s, sink)
+	if len(pushed) != 1 {
+		t.Fatalf("detection notifications mismatch: have %d, want 1", len(pushed))
+	}
+	if n := pushed[0]; n.Account != watched || n.Kind != KindCoin || n.Confirmations != 1 || n.Removed || n.Denomination.ToInt().Cmp(coinValue) != 0 || !bytes.Equal(n.OTA, ota(true, 1)) {
+		t.Errorf("detection notification mismatch: %+v", n)
+	}
+	// Confirmed after the third block, notified once
+	s.process(chain.add(2, 0))
+	if pushed := drain(s, sink); len(pushed) != 0 {
+		t.Errorf("unconfirmed deposit notified: %+v", pushed[0])
+	}
+	s.process(chain.add(3, 0))
+	pushed = drain(s, sink)
+	if len(pushed) != 1 || pushed[0].Confirmations != 3 || pushed[0].Kind != KindCoin {
+		t.Fatalf("confirmation notifications mismatch: %+v", pushed)
+	}
+	s.process(chain.add(4, 0))
+	if pushed := drain(s, sink); len(pushed) != 0 {
+		t.Errorf("deposit notified after its confirmation: %+v", pushed[0])
+	}
+}
+
+func TestDepositReorg(t *testing.T) {
+	s, chain, sink := newTestService()
+
+	s.process(chain.add(1, 0, depositTx(t, 0, ota(true, 1), true)))
+	if pushed := drain(s, sink); len(pushed) != 1 || pushed[0].Kind != KindStamp {
+		t.Fatalf("detection notifications mismatch: %+v", pushed)
+	}
+	// Reorganise the deposit away, then include it again two blocks later
+	chain.add(1, 1)
+	s.process(chain.add(2, 1))
+
+	pushed := drain(s, sink)
+	if len(pushed) != 1 || !pushed[0].Removed {
+		t.Fatalf("removal notifications mismatch: %+v", pushed)
+	}
+	s.process(chain.add(3, 1, depositTx(t, 0, ota(true, 1), true)))
+	pushed = drain(s, sink)
+	if len(pushed) != 1 || pushed[0].Removed || pushed[0].BlockNumber != 3 || pushed[0].Confirmations != 1 {
+		t.Fatalf("redetection notifications mismatch: %+v", pushed)
+	}
+	// Skipped blocks are scanned up to the new head
+	chain.add(4, 1)
+	s.process(chain.add(5, 1))
+	pushed = drain(s, sink)
+	if len(pushed) != 1 || pushed[0].BlockNumber != 3 || pushed[0].Confirmations != 3 {
+		t.Fatalf("confirmation notifications mismatch: %+v", pushed)
+	}
+}
+
+func TestMQTTPublish(t *testing.T) {
+	client, broker := net.Pipe()
+	defer client.Close()
+
+	connect := make(chan []byte, 1)
+	go func() {
+		packet := make([]byte, 256)
+		n, _ := broker.Read(packet)
+		connect <- packet[:n]
+		broker.Write([]byte{mqttConnack, 2, 0, 0})
+	}()
+	if err := mqttHandshake(client, url.UserPassword("user", "pass")); err != nil {
+		t.Fatalf("handshake failed: %v", err)
+	}
+	packet := <-connect
+	if packet[0] != mqttConnect || int(packet[1]) != len(packet)-2 {
+		t.Fatalf("invalid CONNECT packet %x", packet)
+	}
+	if flags := packet[2+6+1]; flags != 0xc2 {
+		t.Errorf("connect flags mismatch: have %x, want c2", flags)
+	}
+	// Publish a notification larger than a single byte length
+	m := &mqtt{topic: "deposits", conn: client}
+	n := &Notification{Account: watched, Kind: KindCoin, OTA: ota(true, 1)}
+
+	published := make(chan []byte, 1)
+	go func() {
+		data, _ := ioutil.ReadAll(broker)
+		published <- data
+	}()
+	if err := m.push(n); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+	client.Close()
+	data := <-published
+	if data[0] != mqttPublish || data[1]&0x80 == 0 {
+		t.Fatalf("invalid PUBLISH header %x", data[:3])
+	}
+	size := int(data[1]&0x7f) + int(data[2])*128
+	if size != len(data)-3 {
+		t.Errorf("remaining length mismatch: have %d, want %d", size, len(data)-3)
+	}
+	if topic := string(data[5 : 5+len("deposits")]); topic != "deposits" {
+		t.Errorf("topic mismatch: have %q", topic)
+	}
+}
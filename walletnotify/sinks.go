@@ -0,0 +1,199 @@
+// Copyright 2018 Wanchain Foundation Ltd
+
+package walletnotify
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/wanchain/go-wanchain/common"
+)
+
+const (
+	pushTimeout = 10 * time.Second // Timeout of pushing a notification to a sink
+
+	// MQTT 3.1.1 control packet types
+	mqttConnect = 0x10
+	mqttConnack = 0x20
+	mqttPublish = 0x30 // QoS 0, no retain
+)
+
+var errMQTTRefused = errors.New("connection refused by the MQTT broker")
+
+// webhook POSTs the notifications as JSON to an URL.
+type webhook struct {
+	url    string
+	client *http.Client
+}
+
+func newWebhook(url string) *webhook {
+	return &webhook{url: url, client: &http.Client{Timeout: pushTimeout}}
+}
+
+func (w *webhook) push(n *Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	res, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("webhook replied %s", res.Status)
+	}
+	return nil
+}
+
+func (w *webhook) String() string { return "webhook " + w.url }
+
+// mqtt publishes the notifications as JSON to a topic of an MQTT broker, at
+// most once. The connection is established on demand and dropped on failure.
+type mqtt struct {
+	broker string
+	topic  string
+	conn   net.Conn
+}
+
+func newMQTT(broker, topic string) *mqtt {
+	return &mqtt{broker: broker, topic: topic}
+}
+
+func (m *mqtt) push(n *Notification) error {
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	if m.conn == nil {
+		if m.conn, err = m.connect(); err != nil {
+			return err
+		}
+	}
+	var packet bytes.Buffer
+	writeMQTTString(&packet, m.topic)
+	packet.Write(payload)
+
+	m.conn.SetWriteDeadline(time.Now().Add(pushTimeout))
+	if err := writeMQTTPacket(m.conn, mqttPublish, packet.Bytes()); err != nil {
+		m.conn.Close()
+		m.conn = nil
+		return err
+	}
+	return nil
+}
+
+// connect dials the broker and opens an MQTT session, with keep alive disabled.
+func (m *mqtt) connect() (net.Conn, error) {
+	u, err := url.Parse(m.broker)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: pushTimeout}
+	var conn net.Conn
+	switch u.Scheme {
+	case "tcp", "mqtt":
+		conn, err = dialer.Dial("tcp", u.Host)
+	case "tls", "ssl", "mqtts":
+		conn, err = tls.DialWithDialer(dialer, "tcp", u.Host, nil)
+	default:
+		return nil, fmt.Errorf("unsupported MQTT broker scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(pushTimeout))
+	if err := mqttHandshake(conn, u.User); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+func (m *mqtt) String() string { return "mqtt " + m.topic }
+
+// mqttHandshake sends the CONNECT packet of a clean session and awaits the
+// acceptance of the broker.
+func mqttHandshake(conn io.ReadWriter, user *url.Userinfo) error {
+	var packet bytes.Buffer
+	writeMQTTString(&packet, "MQTT")
+	packet.WriteByte(4) // Protocol level 3.1.1
+
+	flags := byte(0x02) // Clean session
+	if user != nil {
+		flags |= 0x80
+		if _, ok := user.Password(); ok {
+			flags |= 0x40
+		}
+	}
+	packet.WriteByte(flags)
+	packet.Write([]byte{0, 0}) // Keep alive disabled
+
+	writeMQTTString(&packet, "gwan-"+common.Bytes2Hex(randomID()))
+	if user != nil {
+		writeMQTTString(&packet, user.Username())
+		if password, ok := user.Password(); ok {
+			writeMQTTString(&packet, password)
+		}
+	}
+	if err := writeMQTTPacket(conn, mqttConnect, packet.Bytes()); err != nil {
+		return err
+	}
+	// CONNACK: session present flag and return code
+	ack := make([]byte, 4)
+	if _, err := io.ReadFull(conn, ack); err != nil {
+		return err
+	}
+	if ack[0] != mqttConnack || ack[1] != 2 {
+		return fmt.Errorf("unexpected MQTT packet %x", ack)
+	}
+	if ack[3] != 0 {
+		return fmt.Errorf("%v: code %d", errMQTTRefused, ack[3])
+	}
+	return nil
+}
+
+// writeMQTTPacket writes a control packet: its type, its remaining length as a
+// variable length integer and its body.
+func writeMQTTPacket(w io.Writer, typ byte, body []byte) error {
+	packet := []byte{typ}
+	for size := len(body); ; {
+		digit := byte(size % 128)
+		if size /= 128; size > 0 {
+			digit |= 0x80
+		}
+		packet = append(packet, digit)
+		if size == 0 {
+			break
+		}
+	}
+	_, err := w.Write(append(packet, body...))
+	return err
+}
+
+// writeMQTTString writes a length prefixed UTF-8 string.
+func writeMQTTString(buf *bytes.Buffer, s string) {
+	binary.Write(buf, binary.BigEndian, uint16(len(s)))
+	buf.WriteString(s)
+}
+
+// randomID returns random bytes distinguishing the MQTT client.
+func randomID() []byte {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return id
+}